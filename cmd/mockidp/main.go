@@ -0,0 +1,48 @@
+// mockidp はローカル開発用のモック OpenID プロバイダー。
+// config.ini の [oidc] issuer に http://localhost:9000 を指定すると、パスワード入力なしで指定のユーザーとしてログインできる。
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"homework-manager/internal/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9000", "Listen address")
+	issuer := flag.String("issuer", "", "Issuer URL (default: http://<addr>)")
+	clientID := flag.String("client-id", "homework-manager", "Client ID")
+	clientSecret := flag.String("client-secret", "", "Client secret (empty: public client)")
+	sub := flag.String("sub", "mock-user", "Subject of the user who logs in")
+	email := flag.String("email", "mock-user@example.com", "Email of the user who logs in")
+	emailVerified := flag.Bool("email-verified", true, "Whether the email is verified")
+	name := flag.String("name", "Mock User", "Name of the user who logs in")
+	roles := flag.String("roles", "", "Comma-separated values of the \"roles\" claim")
+	flag.Parse()
+
+	if *issuer == "" {
+		*issuer = "http://" + *addr
+	}
+
+	idp := oidctest.New(*issuer, *clientID)
+	idp.ClientSecret = *clientSecret
+
+	claims := map[string]interface{}{
+		"sub":            *sub,
+		"email":          *email,
+		"email_verified": *emailVerified,
+		"name":           *name,
+	}
+	if *roles != "" {
+		claims["roles"] = strings.Split(*roles, ",")
+	}
+	idp.SetClaims(claims)
+
+	log.Printf("Mock OpenID provider listening on %s (issuer: %s)", *addr, *issuer)
+	if err := http.ListenAndServe(*addr, idp); err != nil {
+		log.Fatalf("Failed to start mock OpenID provider: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"log"
+	_ "time/tzdata" // ユーザーごとのタイムゾーンをOSのタイムゾーンデータなしでも読み込めるようにする
 
 	"homework-manager/internal/config"
 	"homework-manager/internal/database"
@@ -19,17 +20,21 @@ func main() {
 
 	// Connect to database
 	log.Printf("Connecting to database (driver: %s)", cfg.Database.Driver)
-	if err := database.Connect(cfg.Database, cfg.Debug); err != nil {
+	db, err := database.Connect(cfg.Database, cfg.Debug)
+	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
 	// Run migrations
-	if err := database.Migrate(); err != nil {
+	if err := database.Migrate(db); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
 	// Setup router
-	r := router.Setup(cfg)
+	r, err := router.Setup(cfg, db)
+	if err != nil {
+		log.Fatalf("Failed to set up server: %v", err)
+	}
 
 	// Start server
 	log.Printf("Server starting on http://localhost:%s", cfg.Port)
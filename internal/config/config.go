@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"gopkg.in/ini.v1"
 )
@@ -18,7 +20,27 @@ type DatabaseConfig struct {
 }
 
 type NotificationConfig struct {
-	TelegramBotToken string
+	TelegramBotToken      string
+	TelegramAPIURL        string // Bot API のURL（テスト用に偽のサーバーを指定できる）
+	TelegramBotMode       string // "off", "polling" or "webhook"
+	TelegramWebhookURL    string // webhook モードで Telegram に登録するURL
+	TelegramWebhookSecret string
+	MaxAttempts           int // 送信失敗時の最大試行回数（超えるとデッドレター）
+}
+
+type SMTPConfig struct {
+	Host       string
+	Port       int
+	Username   string
+	Password   string
+	From       string
+	FromName   string
+	Encryption string // "starttls", "tls" or "none"
+}
+
+type RecurringConfig struct {
+	GenerationEnabled  bool
+	GenerationInterval int // 分
 }
 
 type CaptchaConfig struct {
@@ -28,33 +50,124 @@ type CaptchaConfig struct {
 	TurnstileSecretKey string
 }
 
+// OIDCConfig は OpenID Connect プロバイダーによるシングルサインオンの設定。
+type OIDCConfig struct {
+	Enabled       bool
+	ProviderName  string // ログイン画面のボタンに表示する名前
+	Issuer        string // discovery（/.well-known/openid-configuration）はこの URL から取得する
+	ClientID      string
+	ClientSecret  string // 空の場合はパブリッククライアントとして PKCE のみで認証する
+	RedirectURL   string // https://example.com/auth/oidc/callback
+	Scopes        []string
+	AutoProvision bool     // 初回ログイン時にアカウントを自動作成する
+	LinkByEmail   bool     // 検証済みメールアドレスが一致する既存アカウントに紐付ける
+	RoleClaim     string   // ロールを判定するクレーム名。空の場合はロールを同期しない
+	AdminValues   []string // RoleClaim にこのいずれかの値が含まれていれば admin、含まれていなければ user
+	TeacherValues []string // admin でなく、RoleClaim にこのいずれかの値が含まれていれば teacher
+}
+
+// WebAuthnConfig はパスキー / セキュリティキーの設定。
+type WebAuthnConfig struct {
+	Enabled      bool
+	RPID         string // ドメイン名（例: homework.example.com）。登録後に変更すると既存のクレデンシャルは使えなくなる
+	RPName       string
+	Origin       string // https://homework.example.com
+	Passwordless bool   // パスキーだけでのログイン（パスワードなし）を許可する
+}
+
+// RateLimitPolicyConfig はルートグループごとのレート制限。Window 秒あたり Requests 回まで。Requests が 0 の場合は制限しない。
+type RateLimitPolicyConfig struct {
+	Requests int
+	Window   int    // 秒
+	By       string // 制限の単位: "ip", "user", "api_key"（ユーザー・APIキーが分からないリクエストは IP で数える）
+}
+
+// RateLimitConfig はレート制限の設定（[security] セクション）。
+type RateLimitConfig struct {
+	Enabled   bool
+	Store     string // "memory" or "database"（複数台で制限を共有する場合は database）
+	Algorithm string // "sliding_window" or "token_bucket"
+	Web       RateLimitPolicyConfig
+	Login     RateLimitPolicyConfig // ログイン・登録・パスワード再設定の送信
+	API       RateLimitPolicyConfig
+}
+
+// LockoutConfig はログイン失敗時の待ち時間・アカウントロック・CAPTCHA の設定（[security] セクション）。
+type LockoutConfig struct {
+	Threshold    int // 連続してこの回数失敗するとアカウントを一時的にロックする。0 の場合はロックしない
+	Duration     int // ロックする時間（分）。IP ごとの失敗回数もこの期間で数える
+	DelayAfter   int // 連続してこの回数失敗すると、次の試行まで待ち時間（1秒から倍々）を設ける。0 の場合は待たせない
+	MaxDelay     int // 待ち時間の上限（秒）
+	CaptchaAfter int // アカウントまたは IP でこの回数失敗すると CAPTCHA を求める（[captcha] が有効な場合）。0 の場合は常に求める
+}
+
+// StorageConfig は添付ファイルの保存先と上限の設定。
+type StorageConfig struct {
+	Backend     string // "local" or "s3"
+	LocalPath   string // local の保存先ディレクトリ
+	S3Endpoint  string // https://s3.ap-northeast-1.amazonaws.com、MinIO などは http://localhost:9000
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool // バケット名をパスに含める（MinIO などで必要）
+	MaxFileSize int  // 1 ファイルの上限（MB）
+	UserQuota   int  // 1 ユーザーの合計の上限（MB）。0 の場合は制限しない
+}
+
 type Config struct {
-	Port              string
-	SessionSecret     string
-	Debug             bool
-	AllowRegistration bool
-	HTTPS             bool
-	CSRFSecret        string
-	RateLimitEnabled  bool
-	RateLimitRequests int
-	RateLimitWindow   int
-	TrustedProxies    []string
-	Database          DatabaseConfig
-	Notification      NotificationConfig
-	Captcha           CaptchaConfig
+	Port                     string
+	BaseURL                  string // メール内のリンクに使う公開URL（例: https://homework.example.com）
+	SessionSecret            string
+	SessionStore             string // "database" or "memory"（メモリの場合は再起動でログアウトされる。テスト・開発用）
+	Debug                    bool
+	AllowRegistration        bool
+	RequireEmailVerification bool // メールアドレスの確認が済むまでログインできないようにする
+	PasswordResetTTL         int  // パスワード再設定リンクの有効期間（分）
+	EmailVerificationTTL     int  // メールアドレス確認リンクの有効期間（分）
+	AccountMailLimit         int  // 1 アカウントあたり 1 時間に送るパスワード再設定・確認メールの上限
+	HTTPS                    bool
+	CSRFSecret               string
+	RateLimit                RateLimitConfig
+	Lockout                  LockoutConfig
+	TrustedProxies           []string
+	Database                 DatabaseConfig
+	Notification             NotificationConfig
+	SMTP                     SMTPConfig
+	Recurring                RecurringConfig
+	Captcha                  CaptchaConfig
+	OIDC                     OIDCConfig
+	WebAuthn                 WebAuthnConfig
+	Storage                  StorageConfig
 }
 
 func Load(configPath string) *Config {
 	cfg := &Config{
-		Port:              "8080",
-		SessionSecret:     "",
-		Debug:             true,
-		AllowRegistration: true,
-		HTTPS:             false,
-		CSRFSecret:        "",
-		RateLimitEnabled:  true,
-		RateLimitRequests: 100,
-		RateLimitWindow:   60,
+		Port:                 "8080",
+		SessionSecret:        "",
+		SessionStore:         "database",
+		Debug:                true,
+		AllowRegistration:    true,
+		PasswordResetTTL:     60,
+		EmailVerificationTTL: 2880,
+		AccountMailLimit:     3,
+		HTTPS:                false,
+		CSRFSecret:           "",
+		RateLimit: RateLimitConfig{
+			Enabled:   true,
+			Store:     "memory",
+			Algorithm: "sliding_window",
+			Web:       RateLimitPolicyConfig{Requests: 100, Window: 60, By: "ip"},
+			Login:     RateLimitPolicyConfig{Requests: 10, Window: 60, By: "ip"},
+			API:       RateLimitPolicyConfig{Requests: 300, Window: 60, By: "api_key"},
+		},
+		Lockout: LockoutConfig{
+			Threshold:    10,
+			Duration:     15,
+			DelayAfter:   3,
+			MaxDelay:     30,
+			CaptchaAfter: 3,
+		},
 		Database: DatabaseConfig{
 			Driver:   "sqlite",
 			Path:     "homework.db",
@@ -64,10 +177,42 @@ func Load(configPath string) *Config {
 			Password: "",
 			Name:     "homework_manager",
 		},
+		Notification: NotificationConfig{
+			TelegramAPIURL:  "https://api.telegram.org",
+			TelegramBotMode: "off",
+			MaxAttempts:     5,
+		},
+		SMTP: SMTPConfig{
+			Port:       587,
+			FromName:   "Super-HomeworkManager",
+			Encryption: "starttls",
+		},
+		Recurring: RecurringConfig{
+			GenerationEnabled:  true,
+			GenerationInterval: 15,
+		},
 		Captcha: CaptchaConfig{
 			Enabled: false,
 			Type:    "image",
 		},
+		OIDC: OIDCConfig{
+			ProviderName:  "シングルサインオン",
+			Scopes:        []string{"openid", "email", "profile"},
+			AutoProvision: true,
+			LinkByEmail:   true,
+			AdminValues:   []string{"admin"},
+		},
+		WebAuthn: WebAuthnConfig{
+			RPName:       "Super-HomeworkManager",
+			Passwordless: true,
+		},
+		Storage: StorageConfig{
+			Backend:     "local",
+			LocalPath:   "uploads",
+			S3Region:    "us-east-1",
+			MaxFileSize: 10,
+			UserQuota:   100,
+		},
 	}
 
 	if configPath == "" {
@@ -84,6 +229,9 @@ func Load(configPath string) *Config {
 		if section.HasKey("debug") {
 			cfg.Debug = section.Key("debug").MustBool(true)
 		}
+		if section.HasKey("base_url") {
+			cfg.BaseURL = section.Key("base_url").String()
+		}
 
 		section = iniFile.Section("database")
 		if section.HasKey("driver") {
@@ -112,11 +260,26 @@ func Load(configPath string) *Config {
 		if section.HasKey("secret") {
 			cfg.SessionSecret = section.Key("secret").String()
 		}
+		if section.HasKey("store") {
+			cfg.SessionStore = section.Key("store").String()
+		}
 
 		section = iniFile.Section("auth")
 		if section.HasKey("allow_registration") {
 			cfg.AllowRegistration = section.Key("allow_registration").MustBool(true)
 		}
+		if section.HasKey("require_email_verification") {
+			cfg.RequireEmailVerification = section.Key("require_email_verification").MustBool(false)
+		}
+		if section.HasKey("password_reset_ttl") {
+			cfg.PasswordResetTTL = section.Key("password_reset_ttl").MustInt(60)
+		}
+		if section.HasKey("email_verification_ttl") {
+			cfg.EmailVerificationTTL = section.Key("email_verification_ttl").MustInt(2880)
+		}
+		if section.HasKey("account_mail_limit") {
+			cfg.AccountMailLimit = section.Key("account_mail_limit").MustInt(3)
+		}
 
 		section = iniFile.Section("security")
 		if section.HasKey("https") {
@@ -126,13 +289,56 @@ func Load(configPath string) *Config {
 			cfg.CSRFSecret = section.Key("csrf_secret").String()
 		}
 		if section.HasKey("rate_limit_enabled") {
-			cfg.RateLimitEnabled = section.Key("rate_limit_enabled").MustBool(true)
+			cfg.RateLimit.Enabled = section.Key("rate_limit_enabled").MustBool(true)
+		}
+		if section.HasKey("rate_limit_store") {
+			cfg.RateLimit.Store = section.Key("rate_limit_store").String()
+		}
+		if section.HasKey("rate_limit_algorithm") {
+			cfg.RateLimit.Algorithm = section.Key("rate_limit_algorithm").String()
 		}
+		// rate_limit_requests・rate_limit_window は画面（web）の制限
 		if section.HasKey("rate_limit_requests") {
-			cfg.RateLimitRequests = section.Key("rate_limit_requests").MustInt(100)
+			cfg.RateLimit.Web.Requests = section.Key("rate_limit_requests").MustInt(100)
 		}
 		if section.HasKey("rate_limit_window") {
-			cfg.RateLimitWindow = section.Key("rate_limit_window").MustInt(60)
+			cfg.RateLimit.Web.Window = section.Key("rate_limit_window").MustInt(60)
+		}
+		if section.HasKey("rate_limit_by") {
+			cfg.RateLimit.Web.By = section.Key("rate_limit_by").String()
+		}
+		if section.HasKey("rate_limit_login_requests") {
+			cfg.RateLimit.Login.Requests = section.Key("rate_limit_login_requests").MustInt(10)
+		}
+		if section.HasKey("rate_limit_login_window") {
+			cfg.RateLimit.Login.Window = section.Key("rate_limit_login_window").MustInt(60)
+		}
+		if section.HasKey("rate_limit_login_by") {
+			cfg.RateLimit.Login.By = section.Key("rate_limit_login_by").String()
+		}
+		if section.HasKey("rate_limit_api_requests") {
+			cfg.RateLimit.API.Requests = section.Key("rate_limit_api_requests").MustInt(300)
+		}
+		if section.HasKey("rate_limit_api_window") {
+			cfg.RateLimit.API.Window = section.Key("rate_limit_api_window").MustInt(60)
+		}
+		if section.HasKey("rate_limit_api_by") {
+			cfg.RateLimit.API.By = section.Key("rate_limit_api_by").String()
+		}
+		if section.HasKey("lockout_threshold") {
+			cfg.Lockout.Threshold = section.Key("lockout_threshold").MustInt(10)
+		}
+		if section.HasKey("lockout_duration") {
+			cfg.Lockout.Duration = section.Key("lockout_duration").MustInt(15)
+		}
+		if section.HasKey("login_delay_after") {
+			cfg.Lockout.DelayAfter = section.Key("login_delay_after").MustInt(3)
+		}
+		if section.HasKey("login_delay_max") {
+			cfg.Lockout.MaxDelay = section.Key("login_delay_max").MustInt(30)
+		}
+		if section.HasKey("captcha_after") {
+			cfg.Lockout.CaptchaAfter = section.Key("captcha_after").MustInt(3)
 		}
 		if section.HasKey("trusted_proxies") {
 			proxies := section.Key("trusted_proxies").String()
@@ -146,6 +352,54 @@ func Load(configPath string) *Config {
 		if section.HasKey("telegram_bot_token") {
 			cfg.Notification.TelegramBotToken = section.Key("telegram_bot_token").String()
 		}
+		if section.HasKey("telegram_api_url") {
+			cfg.Notification.TelegramAPIURL = section.Key("telegram_api_url").String()
+		}
+		if section.HasKey("telegram_bot_mode") {
+			cfg.Notification.TelegramBotMode = section.Key("telegram_bot_mode").String()
+		}
+		if section.HasKey("telegram_webhook_url") {
+			cfg.Notification.TelegramWebhookURL = section.Key("telegram_webhook_url").String()
+		}
+		if section.HasKey("telegram_webhook_secret") {
+			cfg.Notification.TelegramWebhookSecret = section.Key("telegram_webhook_secret").String()
+		}
+		if section.HasKey("max_attempts") {
+			cfg.Notification.MaxAttempts = section.Key("max_attempts").MustInt(5)
+		}
+
+		// SMTP section
+		section = iniFile.Section("smtp")
+		if section.HasKey("host") {
+			cfg.SMTP.Host = section.Key("host").String()
+		}
+		if section.HasKey("port") {
+			cfg.SMTP.Port = section.Key("port").MustInt(587)
+		}
+		if section.HasKey("username") {
+			cfg.SMTP.Username = section.Key("username").String()
+		}
+		if section.HasKey("password") {
+			cfg.SMTP.Password = section.Key("password").String()
+		}
+		if section.HasKey("from") {
+			cfg.SMTP.From = section.Key("from").String()
+		}
+		if section.HasKey("from_name") {
+			cfg.SMTP.FromName = section.Key("from_name").String()
+		}
+		if section.HasKey("encryption") {
+			cfg.SMTP.Encryption = section.Key("encryption").String()
+		}
+
+		// Recurring section
+		section = iniFile.Section("recurring")
+		if section.HasKey("generation_enabled") {
+			cfg.Recurring.GenerationEnabled = section.Key("generation_enabled").MustBool(true)
+		}
+		if section.HasKey("generation_interval") {
+			cfg.Recurring.GenerationInterval = section.Key("generation_interval").MustInt(15)
+		}
 
 		// Captcha section
 		section = iniFile.Section("captcha")
@@ -161,6 +415,96 @@ func Load(configPath string) *Config {
 		if section.HasKey("turnstile_secret_key") {
 			cfg.Captcha.TurnstileSecretKey = section.Key("turnstile_secret_key").String()
 		}
+
+		// OIDC section
+		section = iniFile.Section("oidc")
+		if section.HasKey("enabled") {
+			cfg.OIDC.Enabled = section.Key("enabled").MustBool(false)
+		}
+		if section.HasKey("provider_name") {
+			cfg.OIDC.ProviderName = section.Key("provider_name").String()
+		}
+		if section.HasKey("issuer") {
+			cfg.OIDC.Issuer = section.Key("issuer").String()
+		}
+		if section.HasKey("client_id") {
+			cfg.OIDC.ClientID = section.Key("client_id").String()
+		}
+		if section.HasKey("client_secret") {
+			cfg.OIDC.ClientSecret = section.Key("client_secret").String()
+		}
+		if section.HasKey("redirect_url") {
+			cfg.OIDC.RedirectURL = section.Key("redirect_url").String()
+		}
+		if section.HasKey("scopes") {
+			cfg.OIDC.Scopes = strings.Fields(section.Key("scopes").String())
+		}
+		if section.HasKey("auto_provision") {
+			cfg.OIDC.AutoProvision = section.Key("auto_provision").MustBool(true)
+		}
+		if section.HasKey("link_by_email") {
+			cfg.OIDC.LinkByEmail = section.Key("link_by_email").MustBool(true)
+		}
+		if section.HasKey("role_claim") {
+			cfg.OIDC.RoleClaim = section.Key("role_claim").String()
+		}
+		if section.HasKey("admin_values") {
+			cfg.OIDC.AdminValues = splitList(section.Key("admin_values").String())
+		}
+		if section.HasKey("teacher_values") {
+			cfg.OIDC.TeacherValues = splitList(section.Key("teacher_values").String())
+		}
+
+		// WebAuthn section
+		section = iniFile.Section("webauthn")
+		if section.HasKey("enabled") {
+			cfg.WebAuthn.Enabled = section.Key("enabled").MustBool(false)
+		}
+		if section.HasKey("rp_id") {
+			cfg.WebAuthn.RPID = section.Key("rp_id").String()
+		}
+		if section.HasKey("rp_name") {
+			cfg.WebAuthn.RPName = section.Key("rp_name").String()
+		}
+		if section.HasKey("origin") {
+			cfg.WebAuthn.Origin = section.Key("origin").String()
+		}
+		if section.HasKey("passwordless") {
+			cfg.WebAuthn.Passwordless = section.Key("passwordless").MustBool(true)
+		}
+
+		// Storage section
+		section = iniFile.Section("storage")
+		if section.HasKey("backend") {
+			cfg.Storage.Backend = section.Key("backend").String()
+		}
+		if section.HasKey("local_path") {
+			cfg.Storage.LocalPath = section.Key("local_path").String()
+		}
+		if section.HasKey("s3_endpoint") {
+			cfg.Storage.S3Endpoint = section.Key("s3_endpoint").String()
+		}
+		if section.HasKey("s3_region") {
+			cfg.Storage.S3Region = section.Key("s3_region").String()
+		}
+		if section.HasKey("s3_bucket") {
+			cfg.Storage.S3Bucket = section.Key("s3_bucket").String()
+		}
+		if section.HasKey("s3_access_key") {
+			cfg.Storage.S3AccessKey = section.Key("s3_access_key").String()
+		}
+		if section.HasKey("s3_secret_key") {
+			cfg.Storage.S3SecretKey = section.Key("s3_secret_key").String()
+		}
+		if section.HasKey("s3_path_style") {
+			cfg.Storage.S3PathStyle = section.Key("s3_path_style").MustBool(false)
+		}
+		if section.HasKey("max_file_size") {
+			cfg.Storage.MaxFileSize = section.Key("max_file_size").MustInt(10)
+		}
+		if section.HasKey("user_quota") {
+			cfg.Storage.UserQuota = section.Key("user_quota").MustInt(100)
+		}
 	} else {
 		log.Println("config.ini not found, using environment variables or defaults")
 	}
@@ -168,6 +512,9 @@ func Load(configPath string) *Config {
 	if port := os.Getenv("PORT"); port != "" {
 		cfg.Port = port
 	}
+	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
+		cfg.BaseURL = baseURL
+	}
 	if dbDriver := os.Getenv("DATABASE_DRIVER"); dbDriver != "" {
 		cfg.Database.Driver = dbDriver
 	}
@@ -192,24 +539,99 @@ func Load(configPath string) *Config {
 	if sessionSecret := os.Getenv("SESSION_SECRET"); sessionSecret != "" {
 		cfg.SessionSecret = sessionSecret
 	}
+	if sessionStore := os.Getenv("SESSION_STORE"); sessionStore != "" {
+		cfg.SessionStore = sessionStore
+	}
 	if os.Getenv("GIN_MODE") == "release" {
 		cfg.Debug = false
 	}
 	if allowReg := os.Getenv("ALLOW_REGISTRATION"); allowReg != "" {
 		cfg.AllowRegistration = allowReg == "true" || allowReg == "1"
 	}
+	if requireVerification := os.Getenv("REQUIRE_EMAIL_VERIFICATION"); requireVerification != "" {
+		cfg.RequireEmailVerification = requireVerification == "true" || requireVerification == "1"
+	}
 	if https := os.Getenv("HTTPS"); https != "" {
 		cfg.HTTPS = https == "true" || https == "1"
 	}
 	if csrfSecret := os.Getenv("CSRF_SECRET"); csrfSecret != "" {
 		cfg.CSRFSecret = csrfSecret
 	}
+	if rateLimitEnabled := os.Getenv("RATE_LIMIT_ENABLED"); rateLimitEnabled != "" {
+		cfg.RateLimit.Enabled = rateLimitEnabled == "true" || rateLimitEnabled == "1"
+	}
+	if rateLimitStore := os.Getenv("RATE_LIMIT_STORE"); rateLimitStore != "" {
+		cfg.RateLimit.Store = rateLimitStore
+	}
+	if rateLimitAlgorithm := os.Getenv("RATE_LIMIT_ALGORITHM"); rateLimitAlgorithm != "" {
+		cfg.RateLimit.Algorithm = rateLimitAlgorithm
+	}
+	if lockoutThreshold := os.Getenv("LOCKOUT_THRESHOLD"); lockoutThreshold != "" {
+		if v, err := strconv.Atoi(lockoutThreshold); err == nil {
+			cfg.Lockout.Threshold = v
+		}
+	}
+	if lockoutDuration := os.Getenv("LOCKOUT_DURATION"); lockoutDuration != "" {
+		if v, err := strconv.Atoi(lockoutDuration); err == nil {
+			cfg.Lockout.Duration = v
+		}
+	}
+	if captchaAfter := os.Getenv("CAPTCHA_AFTER"); captchaAfter != "" {
+		if v, err := strconv.Atoi(captchaAfter); err == nil {
+			cfg.Lockout.CaptchaAfter = v
+		}
+	}
 	if trustedProxies := os.Getenv("TRUSTED_PROXIES"); trustedProxies != "" {
 		cfg.TrustedProxies = []string{trustedProxies}
 	}
 	if telegramToken := os.Getenv("TELEGRAM_BOT_TOKEN"); telegramToken != "" {
 		cfg.Notification.TelegramBotToken = telegramToken
 	}
+	if telegramAPIURL := os.Getenv("TELEGRAM_API_URL"); telegramAPIURL != "" {
+		cfg.Notification.TelegramAPIURL = telegramAPIURL
+	}
+	if telegramBotMode := os.Getenv("TELEGRAM_BOT_MODE"); telegramBotMode != "" {
+		cfg.Notification.TelegramBotMode = telegramBotMode
+	}
+	if telegramWebhookURL := os.Getenv("TELEGRAM_WEBHOOK_URL"); telegramWebhookURL != "" {
+		cfg.Notification.TelegramWebhookURL = telegramWebhookURL
+	}
+	if telegramWebhookSecret := os.Getenv("TELEGRAM_WEBHOOK_SECRET"); telegramWebhookSecret != "" {
+		cfg.Notification.TelegramWebhookSecret = telegramWebhookSecret
+	}
+	if maxAttempts := os.Getenv("NOTIFICATION_MAX_ATTEMPTS"); maxAttempts != "" {
+		if v, err := strconv.Atoi(maxAttempts); err == nil {
+			cfg.Notification.MaxAttempts = v
+		}
+	}
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		cfg.SMTP.Host = smtpHost
+	}
+	if smtpPort := os.Getenv("SMTP_PORT"); smtpPort != "" {
+		if v, err := strconv.Atoi(smtpPort); err == nil {
+			cfg.SMTP.Port = v
+		}
+	}
+	if smtpUsername := os.Getenv("SMTP_USERNAME"); smtpUsername != "" {
+		cfg.SMTP.Username = smtpUsername
+	}
+	if smtpPassword := os.Getenv("SMTP_PASSWORD"); smtpPassword != "" {
+		cfg.SMTP.Password = smtpPassword
+	}
+	if smtpFrom := os.Getenv("SMTP_FROM"); smtpFrom != "" {
+		cfg.SMTP.From = smtpFrom
+	}
+	if smtpEncryption := os.Getenv("SMTP_ENCRYPTION"); smtpEncryption != "" {
+		cfg.SMTP.Encryption = smtpEncryption
+	}
+	if genEnabled := os.Getenv("RECURRING_GENERATION_ENABLED"); genEnabled != "" {
+		cfg.Recurring.GenerationEnabled = genEnabled == "true" || genEnabled == "1"
+	}
+	if genInterval := os.Getenv("RECURRING_GENERATION_INTERVAL"); genInterval != "" {
+		if v, err := strconv.Atoi(genInterval); err == nil {
+			cfg.Recurring.GenerationInterval = v
+		}
+	}
 	if captchaEnabled := os.Getenv("CAPTCHA_ENABLED"); captchaEnabled != "" {
 		cfg.Captcha.Enabled = captchaEnabled == "true" || captchaEnabled == "1"
 	}
@@ -223,13 +645,171 @@ func Load(configPath string) *Config {
 		cfg.Captcha.TurnstileSecretKey = turnstileSecretKey
 	}
 
+	if oidcEnabled := os.Getenv("OIDC_ENABLED"); oidcEnabled != "" {
+		cfg.OIDC.Enabled = oidcEnabled == "true" || oidcEnabled == "1"
+	}
+	if oidcIssuer := os.Getenv("OIDC_ISSUER"); oidcIssuer != "" {
+		cfg.OIDC.Issuer = oidcIssuer
+	}
+	if oidcClientID := os.Getenv("OIDC_CLIENT_ID"); oidcClientID != "" {
+		cfg.OIDC.ClientID = oidcClientID
+	}
+	if oidcClientSecret := os.Getenv("OIDC_CLIENT_SECRET"); oidcClientSecret != "" {
+		cfg.OIDC.ClientSecret = oidcClientSecret
+	}
+	if oidcRedirectURL := os.Getenv("OIDC_REDIRECT_URL"); oidcRedirectURL != "" {
+		cfg.OIDC.RedirectURL = oidcRedirectURL
+	}
+	if webauthnEnabled := os.Getenv("WEBAUTHN_ENABLED"); webauthnEnabled != "" {
+		cfg.WebAuthn.Enabled = webauthnEnabled == "true" || webauthnEnabled == "1"
+	}
+	if webauthnRPID := os.Getenv("WEBAUTHN_RP_ID"); webauthnRPID != "" {
+		cfg.WebAuthn.RPID = webauthnRPID
+	}
+	if webauthnOrigin := os.Getenv("WEBAUTHN_ORIGIN"); webauthnOrigin != "" {
+		cfg.WebAuthn.Origin = webauthnOrigin
+	}
+	if storageBackend := os.Getenv("STORAGE_BACKEND"); storageBackend != "" {
+		cfg.Storage.Backend = storageBackend
+	}
+	if storageLocalPath := os.Getenv("STORAGE_LOCAL_PATH"); storageLocalPath != "" {
+		cfg.Storage.LocalPath = storageLocalPath
+	}
+	if s3Endpoint := os.Getenv("S3_ENDPOINT"); s3Endpoint != "" {
+		cfg.Storage.S3Endpoint = s3Endpoint
+	}
+	if s3Region := os.Getenv("S3_REGION"); s3Region != "" {
+		cfg.Storage.S3Region = s3Region
+	}
+	if s3Bucket := os.Getenv("S3_BUCKET"); s3Bucket != "" {
+		cfg.Storage.S3Bucket = s3Bucket
+	}
+	if s3AccessKey := os.Getenv("S3_ACCESS_KEY"); s3AccessKey != "" {
+		cfg.Storage.S3AccessKey = s3AccessKey
+	}
+	if s3SecretKey := os.Getenv("S3_SECRET_KEY"); s3SecretKey != "" {
+		cfg.Storage.S3SecretKey = s3SecretKey
+	}
+	if s3PathStyle := os.Getenv("S3_PATH_STYLE"); s3PathStyle != "" {
+		cfg.Storage.S3PathStyle = s3PathStyle == "true" || s3PathStyle == "1"
+	}
+	if maxFileSize := os.Getenv("STORAGE_MAX_FILE_SIZE"); maxFileSize != "" {
+		if v, err := strconv.Atoi(maxFileSize); err == nil {
+			cfg.Storage.MaxFileSize = v
+		}
+	}
+	if userQuota := os.Getenv("STORAGE_USER_QUOTA"); userQuota != "" {
+		if v, err := strconv.Atoi(userQuota); err == nil {
+			cfg.Storage.UserQuota = v
+		}
+	}
+
+	if cfg.Notification.MaxAttempts < 1 {
+		cfg.Notification.MaxAttempts = 1
+	}
+	if cfg.Recurring.GenerationInterval < 1 {
+		cfg.Recurring.GenerationInterval = 1
+	}
+	if cfg.PasswordResetTTL < 1 {
+		cfg.PasswordResetTTL = 1
+	}
+	if cfg.EmailVerificationTTL < 1 {
+		cfg.EmailVerificationTTL = 1
+	}
+	if cfg.AccountMailLimit < 1 {
+		cfg.AccountMailLimit = 1
+	}
+	for _, policy := range []*RateLimitPolicyConfig{&cfg.RateLimit.Web, &cfg.RateLimit.Login, &cfg.RateLimit.API} {
+		if policy.Requests < 0 {
+			policy.Requests = 0
+		}
+		if policy.Window < 1 {
+			policy.Window = 1
+		}
+	}
+	if cfg.Lockout.Threshold < 0 {
+		cfg.Lockout.Threshold = 0
+	}
+	if cfg.Lockout.Duration < 1 {
+		cfg.Lockout.Duration = 1
+	}
+	if cfg.Lockout.DelayAfter < 0 {
+		cfg.Lockout.DelayAfter = 0
+	}
+	if cfg.Lockout.MaxDelay < 1 {
+		cfg.Lockout.MaxDelay = 1
+	}
+	if cfg.Lockout.CaptchaAfter < 0 {
+		cfg.Lockout.CaptchaAfter = 0
+	}
+	if cfg.Storage.MaxFileSize < 1 {
+		cfg.Storage.MaxFileSize = 1
+	}
+	if cfg.Storage.UserQuota < 0 {
+		cfg.Storage.UserQuota = 0
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+
 	if cfg.SessionSecret == "" {
 		log.Fatal("FATAL: Session secret is not set. Please set it in config.ini ([session] secret) or via SESSION_SECRET environment variable.")
 	}
+	if cfg.SessionStore != "database" && cfg.SessionStore != "memory" {
+		log.Fatalf("FATAL: Unknown session store %q ([session] store). Use \"database\" or \"memory\".", cfg.SessionStore)
+	}
+	if cfg.RateLimit.Store != "memory" && cfg.RateLimit.Store != "database" {
+		log.Fatalf("FATAL: Unknown rate limit store %q ([security] rate_limit_store). Use \"memory\" or \"database\".", cfg.RateLimit.Store)
+	}
+	if cfg.RateLimit.Algorithm != "sliding_window" && cfg.RateLimit.Algorithm != "token_bucket" {
+		log.Fatalf("FATAL: Unknown rate limit algorithm %q ([security] rate_limit_algorithm). Use \"sliding_window\" or \"token_bucket\".", cfg.RateLimit.Algorithm)
+	}
+	for _, by := range []string{cfg.RateLimit.Web.By, cfg.RateLimit.Login.By, cfg.RateLimit.API.By} {
+		if by != "ip" && by != "user" && by != "api_key" {
+			log.Fatalf("FATAL: Unknown rate limit key %q ([security] rate_limit_*by). Use \"ip\", \"user\" or \"api_key\".", by)
+		}
+	}
 	if cfg.CSRFSecret == "" {
 		log.Fatal("FATAL: CSRF secret is not set. Please set it in config.ini ([security] csrf_secret) or via CSRF_SECRET environment variable.")
 	}
 
+	if !containsString(cfg.OIDC.Scopes, "openid") {
+		cfg.OIDC.Scopes = append([]string{"openid"}, cfg.OIDC.Scopes...)
+	}
+	if cfg.OIDC.Enabled && (cfg.OIDC.Issuer == "" || cfg.OIDC.ClientID == "" || cfg.OIDC.RedirectURL == "") {
+		log.Fatal("FATAL: OIDC is enabled but issuer, client_id or redirect_url is not set in config.ini ([oidc]).")
+	}
+	if cfg.WebAuthn.Enabled && (cfg.WebAuthn.RPID == "" || cfg.WebAuthn.Origin == "") {
+		log.Fatal("FATAL: WebAuthn is enabled but rp_id or origin is not set in config.ini ([webauthn]).")
+	}
+	cfg.WebAuthn.Origin = strings.TrimRight(cfg.WebAuthn.Origin, "/")
+	if cfg.Storage.Backend != "local" && cfg.Storage.Backend != "s3" {
+		log.Fatalf("FATAL: Unknown storage backend %q ([storage] backend). Use \"local\" or \"s3\".", cfg.Storage.Backend)
+	}
+	if cfg.Storage.Backend == "s3" && (cfg.Storage.S3Endpoint == "" || cfg.Storage.S3Bucket == "" || cfg.Storage.S3AccessKey == "" || cfg.Storage.S3SecretKey == "") {
+		log.Fatal("FATAL: S3 storage is selected but s3_endpoint, s3_bucket, s3_access_key or s3_secret_key is not set in config.ini ([storage]).")
+	}
+	if cfg.RequireEmailVerification && (cfg.BaseURL == "" || cfg.SMTP.Host == "" || cfg.SMTP.From == "") {
+		log.Fatal("FATAL: Email verification is required but base_url ([server]) or SMTP ([smtp] host, from) is not set.")
+	}
+
 	return cfg
 }
 
+// splitList はカンマ区切りの値を空白を除いて分割する。
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
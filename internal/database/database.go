@@ -1,7 +1,11 @@
 package database
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"homework-manager/internal/config"
@@ -12,11 +16,11 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 )
 
-var DB *gorm.DB
-
-func Connect(dbConfig config.DatabaseConfig, debug bool) error {
+// Connect は設定に従ってデータベースに接続する。返した接続はリポジトリ・サービスに渡して使う。
+func Connect(dbConfig config.DatabaseConfig, debug bool) (*gorm.DB, error) {
 	var logMode logger.LogLevel
 	if debug {
 		logMode = logger.Info
@@ -26,6 +30,8 @@ func Connect(dbConfig config.DatabaseConfig, debug bool) error {
 
 	gormConfig := &gorm.Config{
 		Logger: logger.Default.LogMode(logMode),
+		// 日時は UTC で保存する（SQLite は文字列で比較するため、オフセットが混ざると大小関係が崩れる）
+		NowFunc: func() time.Time { return time.Now().UTC() },
 	}
 
 	var db *gorm.DB
@@ -59,32 +65,308 @@ func Connect(dbConfig config.DatabaseConfig, debug bool) error {
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	sqlDB.SetMaxIdleConns(10)
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	DB = db
-	return nil
+	return db, nil
 }
 
-func Migrate() error {
-	return DB.AutoMigrate(
+func Migrate(db *gorm.DB) error {
+	// メールアドレス確認の導入前からいるユーザーは確認済みとして扱う（列の追加時に一度だけ）
+	addingEmailVerification := !db.Migrator().HasColumn(&models.User{}, "email_verified_at")
+	// 科目を文字列で持っていた課題・繰り返し設定は科目を作って参照させる（列の追加時に一度だけ）
+	addingSubjects := !db.Migrator().HasColumn(&models.Assignment{}, "subject_id")
+	// 平文で保存していたカレンダー購読トークンはハッシュに置き換える（列の追加時に一度だけ）
+	hashingCalendarTokens := db.Migrator().HasColumn(&models.User{}, "calendar_token")
+
+	tables := []interface{}{
 		&models.User{},
+		&models.Subject{},
+		&models.Tag{},
 		&models.Assignment{},
+		&models.ChecklistItem{},
+		&models.Attachment{},
 		&models.RecurringAssignment{},
+		&models.Group{},
+		&models.Membership{},
+		&models.GroupAssignment{},
 		&models.APIKey{},
 		&models.UserNotificationSettings{},
-	)
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.NotificationOutbox{},
+		&models.WebAuthnCredential{},
+		&models.RecoveryCode{},
+		&models.UserToken{},
+		&models.Session{},
+		&models.LoginAttempt{},
+		&models.AuditEvent{},
+		&models.RateLimitBucket{},
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		return err
+	}
+
+	if addingEmailVerification {
+		if err := migrateEmailVerification(db); err != nil {
+			return err
+		}
+	}
+
+	if addingSubjects {
+		if err := migrateSubjects(db); err != nil {
+			return err
+		}
+	}
+
+	if hashingCalendarTokens {
+		if err := migrateCalendarTokens(db); err != nil {
+			return err
+		}
+	}
+
+	if err := migrateRecurringRules(db); err != nil {
+		return err
+	}
+	if err := migrateAPIKeyScopes(db); err != nil {
+		return err
+	}
+	return migrateTimesToUTC(db, tables...)
+}
+
+// migrateEmailVerification は既存のユーザーを登録日時で確認済みにし、確認を必須にしてもログインできるようにする。
+func migrateEmailVerification(db *gorm.DB) error {
+	result := db.Model(&models.User{}).Unscoped().Where("email_verified_at IS NULL").
+		UpdateColumn("email_verified_at", gorm.Expr("created_at"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Marked %d existing user(s) as email verified", result.RowsAffected)
+	}
+	return nil
+}
+
+// migrateSubjects は課題・繰り返し設定の科目名ごとに科目を作成し、SubjectID で参照させる。
+// 課題ごとに持っていたアーカイブ（assignments.is_archived）は、アーカイブした課題がある科目のアーカイブに移し、列を削除する。
+func migrateSubjects(db *gorm.DB) error {
+	type subjectKey struct {
+		UserID  uint
+		Subject string
+	}
+	var keys, recurringKeys []subjectKey
+	if err := db.Unscoped().Model(&models.Assignment{}).Distinct("user_id", "subject").
+		Where("subject <> ''").Scan(&keys).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&models.RecurringAssignment{}).Distinct("user_id", "subject").
+		Where("subject <> ''").Scan(&recurringKeys).Error; err != nil {
+		return err
+	}
+	hasLegacyArchive := db.Migrator().HasColumn(&models.Assignment{}, "is_archived")
+
+	seen := make(map[subjectKey]bool)
+	for _, key := range append(keys, recurringKeys...) {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		subject := models.Subject{UserID: key.UserID, Name: key.Subject, Color: models.DefaultSubjectColor}
+		if hasLegacyArchive {
+			var archived int64
+			if err := db.Unscoped().Model(&models.Assignment{}).
+				Where("user_id = ? AND subject = ? AND is_archived = ?", key.UserID, key.Subject, true).
+				Count(&archived).Error; err != nil {
+				return err
+			}
+			subject.IsArchived = archived > 0
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&subject).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Assignment{}).Where("user_id = ? AND subject = ?", key.UserID, key.Subject).
+				UpdateColumn("subject_id", subject.ID).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Model(&models.RecurringAssignment{}).Where("user_id = ? AND subject = ?", key.UserID, key.Subject).
+				UpdateColumn("subject_id", subject.ID).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if hasLegacyArchive {
+		if db.Migrator().HasIndex(&models.Assignment{}, "idx_assignments_is_archived") {
+			if err := db.Migrator().DropIndex(&models.Assignment{}, "idx_assignments_is_archived"); err != nil {
+				return err
+			}
+		}
+		if err := db.Migrator().DropColumn(&models.Assignment{}, "is_archived"); err != nil {
+			return err
+		}
+	}
+
+	if len(seen) > 0 {
+		log.Printf("Created %d subject(s) from existing assignments", len(seen))
+	}
+	return nil
+}
+
+// migrateCalendarTokens は平文の calendar_token を SHA-256 にして calendar_token_hash に移し、列を削除する。
+// 発行済みの購読URLはそのまま使える。
+func migrateCalendarTokens(db *gorm.DB) error {
+	var users []struct {
+		ID            uint
+		CalendarToken string
+	}
+	if err := db.Unscoped().Model(&models.User{}).Select("id", "calendar_token").
+		Where("calendar_token IS NOT NULL AND calendar_token <> ''").Scan(&users).Error; err != nil {
+		return err
+	}
+	for _, u := range users {
+		hash := sha256.Sum256([]byte(u.CalendarToken))
+		if err := db.Unscoped().Model(&models.User{}).Where("id = ?", u.ID).
+			UpdateColumn("calendar_token_hash", hex.EncodeToString(hash[:])).Error; err != nil {
+			return err
+		}
+	}
+
+	if db.Migrator().HasIndex(&models.User{}, "idx_users_calendar_token") {
+		if err := db.Migrator().DropIndex(&models.User{}, "idx_users_calendar_token"); err != nil {
+			return err
+		}
+	}
+	if err := db.Migrator().DropColumn(&models.User{}, "calendar_token"); err != nil {
+		return err
+	}
+
+	if len(users) > 0 {
+		log.Printf("Hashed %d existing calendar token(s)", len(users))
+	}
+	return nil
+}
+
+// migrateAPIKeyScopes はスコープ導入前に発行された APIキーに全スコープを付与し、従来どおり使えるようにする。
+func migrateAPIKeyScopes(db *gorm.DB) error {
+	result := db.Model(&models.APIKey{}).Where("scopes IS NULL OR scopes = ''").
+		Update("scopes", strings.Join(models.APIKeyScopes, " "))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Granted all scopes to %d existing API key(s)", result.RowsAffected)
+	}
+	return nil
 }
 
-func GetDB() *gorm.DB {
-	return DB
+// migrateRecurringRules は RRULE 導入前の繰り返し設定に RRULE と起点日時を設定する。
+func migrateRecurringRules(db *gorm.DB) error {
+	var recurrings []models.RecurringAssignment
+	if err := db.Where("rrule IS NULL OR rrule = ''").Find(&recurrings).Error; err != nil {
+		return err
+	}
+
+	for _, r := range recurrings {
+		updates := map[string]interface{}{
+			"rrule": r.LegacyRule().String(),
+		}
+		if r.StartDate == nil {
+			var first models.Assignment
+			err := db.Unscoped().Where("recurring_assignment_id = ?", r.ID).Order("due_date ASC").First(&first).Error
+			if err == nil {
+				updates["start_date"] = first.DueDate
+			} else {
+				updates["start_date"] = r.CreatedAt
+			}
+		}
+		if err := db.Model(&models.RecurringAssignment{}).Where("id = ?", r.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(recurrings) > 0 {
+		log.Printf("Migrated %d recurring assignment(s) to RRULE", len(recurrings))
+	}
+	return nil
+}
+
+// migrateTimesToUTC は SQLite に UTC 以外のオフセット付きで保存された日時を UTC に書き換える。
+// SQLite は日時を文字列のまま比較するため、オフセットが混ざると期限切れやリマインダーの判定が狂う。
+func migrateTimesToUTC(db *gorm.DB, tables ...interface{}) error {
+	if db.Dialector.Name() != "sqlite" {
+		return nil
+	}
+
+	converted := 0
+	for _, table := range tables {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(table); err != nil {
+			return err
+		}
+		pk := stmt.Schema.PrioritizedPrimaryField
+		if pk == nil {
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.DataType != schema.Time {
+				continue
+			}
+			n, err := convertColumnToUTC(db, stmt.Schema.Table, pk.DBName, field.DBName)
+			if err != nil {
+				return err
+			}
+			converted += n
+		}
+	}
+
+	if converted > 0 {
+		log.Printf("Converted %d stored time(s) to UTC", converted)
+	}
+	return nil
+}
+
+func convertColumnToUTC(db *gorm.DB, table, pk, column string) (int, error) {
+	type row struct {
+		id    interface{}
+		value time.Time
+	}
+	// UTC で保存した値は "+00:00" で終わる
+	rows, err := db.Table(table).Select(pk, column).
+		Where(column+" IS NOT NULL AND "+column+" NOT LIKE ?", "%+00:00").Rows()
+	if err != nil {
+		return 0, err
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.value); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range pending {
+		if err := db.Table(table).Where(pk+" = ?", r.id).UpdateColumn(column, r.value.UTC()).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(pending), nil
 }
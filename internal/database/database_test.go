@@ -0,0 +1,225 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 科目の導入前の課題・繰り返し設定のテーブル
+type legacyAssignment struct {
+	ID         uint
+	UserID     uint
+	Title      string
+	Subject    string
+	DueDate    time.Time
+	IsArchived bool `gorm:"default:false;index"`
+	DeletedAt  gorm.DeletedAt
+}
+
+func (legacyAssignment) TableName() string { return "assignments" }
+
+type legacyRecurringAssignment struct {
+	ID      uint
+	UserID  uint
+	Title   string
+	Subject string
+	DueTime string
+}
+
+func (legacyRecurringAssignment) TableName() string { return "recurring_assignments" }
+
+func TestMigrateSubjects(t *testing.T) {
+	db, err := Connect(config.DatabaseConfig{
+		Driver: "sqlite",
+		Path:   fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+	}, false)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&legacyAssignment{}, &legacyRecurringAssignment{}); err != nil {
+		t.Fatalf("create legacy tables: %v", err)
+	}
+	now := time.Now()
+	for _, a := range []legacyAssignment{
+		{UserID: 1, Title: "計算ドリル", Subject: "数学", DueDate: now},
+		{UserID: 1, Title: "証明", Subject: "数学", DueDate: now},
+		{UserID: 1, Title: "実験", Subject: "理科", DueDate: now, IsArchived: true},
+		{UserID: 1, Title: "削除した課題", Subject: "社会", DueDate: now, DeletedAt: gorm.DeletedAt{Time: now, Valid: true}},
+		{UserID: 1, Title: "科目なし", DueDate: now},
+		{UserID: 2, Title: "計算ドリル", Subject: "数学", DueDate: now},
+	} {
+		if err := db.Create(&a).Error; err != nil {
+			t.Fatalf("create legacy assignment: %v", err)
+		}
+	}
+	db.Create(&legacyRecurringAssignment{UserID: 1, Title: "単語テスト", Subject: "英語", DueTime: "23:59"})
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	var subjects []models.Subject
+	db.Order("user_id, name").Find(&subjects)
+	want := []struct {
+		userID   uint
+		name     string
+		archived bool
+	}{
+		{1, "数学", false}, {1, "理科", true}, {1, "社会", false}, {1, "英語", false}, {2, "数学", false},
+	}
+	if len(subjects) != len(want) {
+		t.Fatalf("subjects = %+v, want %d", subjects, len(want))
+	}
+	byKey := make(map[string]models.Subject)
+	for _, s := range subjects {
+		byKey[fmt.Sprint(s.UserID, s.Name)] = s
+	}
+	for _, w := range want {
+		s, ok := byKey[fmt.Sprint(w.userID, w.name)]
+		if !ok || s.IsArchived != w.archived || s.Color != models.DefaultSubjectColor {
+			t.Errorf("subject %d/%s = %+v, want archived=%v", w.userID, w.name, s, w.archived)
+		}
+	}
+
+	var assignments []models.Assignment
+	db.Unscoped().Find(&assignments)
+	for _, a := range assignments {
+		switch {
+		case a.Subject == "" && a.SubjectID != nil:
+			t.Errorf("assignment %q without subject refers to %d", a.Title, *a.SubjectID)
+		case a.Subject != "" && (a.SubjectID == nil || *a.SubjectID != byKey[fmt.Sprint(a.UserID, a.Subject)].ID):
+			t.Errorf("assignment %q refers to %v, want subject %s of user %d", a.Title, a.SubjectID, a.Subject, a.UserID)
+		}
+	}
+	var recurring models.RecurringAssignment
+	db.First(&recurring)
+	if recurring.SubjectID == nil || *recurring.SubjectID != byKey["1英語"].ID {
+		t.Errorf("recurring assignment refers to %v, want %d", recurring.SubjectID, byKey["1英語"].ID)
+	}
+
+	if db.Migrator().HasColumn(&models.Assignment{}, "is_archived") {
+		t.Error("assignments.is_archived is left after the migration")
+	}
+	// 2 回目以降は何もしない
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate again: %v", err)
+	}
+	var count int64
+	db.Model(&models.Subject{}).Count(&count)
+	if count != int64(len(want)) {
+		t.Errorf("%d subject(s) after migrating again, want %d", count, len(want))
+	}
+}
+
+// カレンダー購読トークンを平文で保存していたころのユーザーのテーブル
+type legacyCalendarUser struct {
+	ID            uint
+	Email         string
+	PasswordHash  string
+	Name          string
+	CalendarToken string `gorm:"size:64;index"`
+	DeletedAt     gorm.DeletedAt
+}
+
+func (legacyCalendarUser) TableName() string { return "users" }
+
+func TestMigrateCalendarTokens(t *testing.T) {
+	db, err := Connect(config.DatabaseConfig{
+		Driver: "sqlite",
+		Path:   fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+	}, false)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&legacyCalendarUser{}); err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+	token := "3f5a0c1b2d4e6f708192a3b4c5d6e7f8091a2b3c4d5e6f70"
+	db.Create(&legacyCalendarUser{Email: "feed@example.com", Name: "購読あり", CalendarToken: token})
+	db.Create(&legacyCalendarUser{Email: "nofeed@example.com", Name: "購読なし"})
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	if db.Migrator().HasColumn(&models.User{}, "calendar_token") {
+		t.Error("users.calendar_token is left after the migration")
+	}
+	sum := sha256.Sum256([]byte(token))
+	var user models.User
+	if err := db.Where("calendar_token_hash = ?", hex.EncodeToString(sum[:])).First(&user).Error; err != nil || user.Email != "feed@example.com" {
+		t.Errorf("user by hashed token = %+v, %v", user, err)
+	}
+	var other models.User
+	db.Where("email = ?", "nofeed@example.com").First(&other)
+	if other.CalendarTokenHash != "" {
+		t.Errorf("user without a token got hash %q", other.CalendarTokenHash)
+	}
+}
+
+func TestMigrateTimesToUTC(t *testing.T) {
+	db, err := Connect(config.DatabaseConfig{
+		Driver: "sqlite",
+		Path:   fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+	}, false)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	// 以前はユーザーのタイムゾーンのオフセット付きで保存していた
+	tokyo := time.FixedZone("JST", 9*60*60)
+	due := time.Now().Add(-30 * time.Minute).In(tokyo)
+	db.Create(&models.Assignment{UserID: 1, Title: "期限切れ", Priority: "medium", DueDate: due})
+	db.Create(&models.Session{ID: "legacy", ExpiresAt: due.Add(time.Hour)})
+
+	countOverdue := func() int64 {
+		var n int64
+		db.Model(&models.Assignment{}).Where("is_completed = ? AND due_date < ?", false, time.Now().UTC()).Count(&n)
+		return n
+	}
+	if n := countOverdue(); n != 0 {
+		t.Fatalf("overdue before the migration = %d; the mixed offsets no longer reproduce the bug", n)
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	var raw string
+	db.Raw("SELECT CAST(due_date AS TEXT) FROM assignments").Scan(&raw)
+	if !strings.HasSuffix(raw, "+00:00") {
+		t.Errorf("due_date = %q, want UTC", raw)
+	}
+	var assignment models.Assignment
+	db.First(&assignment)
+	if !assignment.DueDate.Equal(due) {
+		t.Errorf("due_date = %v, want the same instant as %v", assignment.DueDate, due)
+	}
+	if n := countOverdue(); n != 1 {
+		t.Errorf("overdue after the migration = %d, want 1", n)
+	}
+	db.Raw("SELECT CAST(expires_at AS TEXT) FROM sessions WHERE id = ?", "legacy").Scan(&raw)
+	if !strings.HasSuffix(raw, "+00:00") {
+		t.Errorf("sessions.expires_at = %q, want UTC", raw)
+	}
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// accountMailSentMessage はパスワード再設定・確認メールの再送を受け付けたときの表示。アカウントの有無にかかわらず同じ内容にする。
+const accountMailSentMessage = "入力されたメールアドレスのアカウントが存在する場合は、メールを送信しました。届かない場合は迷惑メールフォルダを確認するか、しばらくしてからもう一度お試しください。"
+
+// AccountHandler はパスワード再設定とメールアドレス確認の画面を扱う。
+type AccountHandler struct {
+	accountMail  *service.AccountMailService
+	auditService *service.AuditService
+}
+
+func NewAccountHandler(db *gorm.DB, accountMail *service.AccountMailService) *AccountHandler {
+	return &AccountHandler{
+		accountMail:  accountMail,
+		auditService: service.NewAuditService(db),
+	}
+}
+
+func (h *AccountHandler) ShowForgotPassword(c *gin.Context) {
+	RenderHTML(c, http.StatusOK, "forgot_password.html", gin.H{
+		"title": "パスワードの再設定",
+	})
+}
+
+func (h *AccountHandler) ForgotPassword(c *gin.Context) {
+	email := c.PostForm("email")
+	if email == "" {
+		RenderHTML(c, http.StatusOK, "forgot_password.html", gin.H{
+			"title": "パスワードの再設定",
+			"error": "メールアドレスを入力してください",
+		})
+		return
+	}
+
+	if err := h.accountMail.RequestPasswordReset(email); err != nil && !errors.Is(err, service.ErrAccountMailRateLimited) {
+		log.Printf("Password reset request failed: %v", err)
+	}
+
+	RenderHTML(c, http.StatusOK, "forgot_password.html", gin.H{
+		"title":   "パスワードの再設定",
+		"success": accountMailSentMessage,
+	})
+}
+
+func (h *AccountHandler) ShowResetPassword(c *gin.Context) {
+	token := c.Query("token")
+	data := gin.H{
+		"title": "新しいパスワードの設定",
+		"token": token,
+	}
+	if err := h.accountMail.CheckPasswordResetToken(token); err != nil {
+		data["invalidToken"] = true
+	}
+	RenderHTML(c, http.StatusOK, "reset_password.html", data)
+}
+
+func (h *AccountHandler) ResetPassword(c *gin.Context) {
+	token := c.PostForm("token")
+	password := c.PostForm("password")
+	passwordConfirm := c.PostForm("password_confirm")
+
+	renderError := func(msg string) {
+		RenderHTML(c, http.StatusOK, "reset_password.html", gin.H{
+			"title": "新しいパスワードの設定",
+			"token": token,
+			"error": msg,
+		})
+	}
+
+	if password != passwordConfirm {
+		renderError("パスワードが一致しません")
+		return
+	}
+	if len(password) < 8 {
+		renderError("パスワードは8文字以上で入力してください")
+		return
+	}
+
+	user, err := h.accountMail.ResetPassword(token, password)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			RenderHTML(c, http.StatusOK, "reset_password.html", gin.H{
+				"title":        "新しいパスワードの設定",
+				"invalidToken": true,
+			})
+			return
+		}
+		log.Printf("Password reset failed: %v", err)
+		renderError("パスワードの再設定に失敗しました")
+		return
+	}
+	// ログインしていないため、再設定したユーザー本人を操作者として記録する
+	actor := auditActor(c)
+	actor.UserID = &user.ID
+	h.auditService.Record(actor, models.AuditPasswordReset, service.UserAuditTarget(user), nil)
+
+	RenderHTML(c, http.StatusOK, "reset_password.html", gin.H{
+		"title": "新しいパスワードの設定",
+		"done":  true,
+	})
+}
+
+// VerifyEmail はメールのリンクからメールアドレスを確認する。ログインしていなくても使える。
+func (h *AccountHandler) VerifyEmail(c *gin.Context) {
+	if _, err := h.accountMail.VerifyEmail(c.Query("token")); err != nil {
+		if !errors.Is(err, service.ErrInvalidToken) {
+			log.Printf("Email verification failed: %v", err)
+		}
+		RenderHTML(c, http.StatusOK, "verify_email.html", gin.H{
+			"title": "メールアドレスの確認",
+			"error": "確認リンクが無効か、有効期限が切れています。確認メールを再送してください。",
+		})
+		return
+	}
+
+	RenderHTML(c, http.StatusOK, "verify_email.html", gin.H{
+		"title":    "メールアドレスの確認",
+		"verified": true,
+	})
+}
+
+func (h *AccountHandler) ShowResendVerification(c *gin.Context) {
+	RenderHTML(c, http.StatusOK, "verify_email.html", gin.H{
+		"title": "メールアドレスの確認",
+		"email": c.Query("email"),
+	})
+}
+
+func (h *AccountHandler) ResendVerification(c *gin.Context) {
+	email := c.PostForm("email")
+	if email == "" {
+		RenderHTML(c, http.StatusOK, "verify_email.html", gin.H{
+			"title": "メールアドレスの確認",
+			"error": "メールアドレスを入力してください",
+		})
+		return
+	}
+
+	if err := h.accountMail.RequestVerification(email); err != nil {
+		log.Printf("Verification mail request failed: %v", err)
+	}
+
+	RenderHTML(c, http.StatusOK, "verify_email.html", gin.H{
+		"title":   "メールアドレスの確認",
+		"success": accountMailSentMessage,
+	})
+}
@@ -1,24 +1,37 @@
 package handler
 
 import (
+	"html/template"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"homework-manager/internal/middleware"
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
 	"homework-manager/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type AdminHandler struct {
 	adminService  *service.AdminService
 	apiKeyService *service.APIKeyService
+	outboxService *service.NotificationOutboxService
+	loginGuard    *service.LoginGuardService
+	auditService  *service.AuditService
 }
 
-func NewAdminHandler() *AdminHandler {
+func NewAdminHandler(db *gorm.DB, loginGuard *service.LoginGuardService, attachments *service.AttachmentService) *AdminHandler {
 	return &AdminHandler{
-		adminService:  service.NewAdminService(),
-		apiKeyService: service.NewAPIKeyService(),
+		adminService:  service.NewAdminService(db, attachments),
+		apiKeyService: service.NewAPIKeyService(db),
+		outboxService: service.NewNotificationOutboxService(db),
+		loginGuard:    loginGuard,
+		auditService:  service.NewAuditService(db),
 	}
 }
 
@@ -33,12 +46,16 @@ func (h *AdminHandler) Index(c *gin.Context) {
 
 	name, _ := c.Get(middleware.UserNameKey)
 
+	webauthnCounts, _ := h.adminService.GetWebAuthnCredentialCounts()
+
 	RenderHTML(c, http.StatusOK, "admin/users.html", gin.H{
-		"title":         "ユーザー管理",
-		"users":         users,
-		"currentUserID": currentUserID,
-		"isAdmin":       true,
-		"userName":      name,
+		"title":          "ユーザー管理",
+		"users":          users,
+		"webauthnCounts": webauthnCounts,
+		"lockedUsers":    h.loginGuard.LockedUsers(users),
+		"currentUserID":  currentUserID,
+		"isAdmin":        true,
+		"userName":       name,
 	})
 }
 
@@ -50,21 +67,29 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	target, _ := h.adminService.GetUserByID(uint(targetID))
 	err = h.adminService.DeleteUser(adminID, uint(targetID))
 	if err != nil {
 		users, _ := h.adminService.GetAllUsers()
+		webauthnCounts, _ := h.adminService.GetWebAuthnCredentialCounts()
 		name, _ := c.Get(middleware.UserNameKey)
 
 		RenderHTML(c, http.StatusOK, "admin/users.html", gin.H{
-			"title":         "ユーザー管理",
-			"users":         users,
-			"currentUserID": adminID,
-			"error":         err.Error(),
-			"isAdmin":       true,
-			"userName":      name,
+			"title":          "ユーザー管理",
+			"users":          users,
+			"webauthnCounts": webauthnCounts,
+			"lockedUsers":    h.loginGuard.LockedUsers(users),
+			"currentUserID":  adminID,
+			"error":          err.Error(),
+			"isAdmin":        true,
+			"userName":       name,
 		})
 		return
 	}
+	h.auditService.Record(auditActor(c), models.AuditUserDelete, service.UserAuditTarget(target), map[string]interface{}{
+		"name": target.Name,
+		"role": target.Role,
+	})
 
 	c.Redirect(http.StatusFound, "/admin/users")
 }
@@ -78,25 +103,87 @@ func (h *AdminHandler) ChangeRole(c *gin.Context) {
 	}
 	newRole := c.PostForm("role")
 
+	target, _ := h.adminService.GetUserByID(uint(targetID))
 	err = h.adminService.ChangeRole(adminID, uint(targetID), newRole)
 	if err != nil {
 		users, _ := h.adminService.GetAllUsers()
+		webauthnCounts, _ := h.adminService.GetWebAuthnCredentialCounts()
 		name, _ := c.Get(middleware.UserNameKey)
 
 		RenderHTML(c, http.StatusOK, "admin/users.html", gin.H{
-			"title":         "ユーザー管理",
-			"users":         users,
-			"currentUserID": adminID,
-			"error":         err.Error(),
-			"isAdmin":       true,
-			"userName":      name,
+			"title":          "ユーザー管理",
+			"users":          users,
+			"webauthnCounts": webauthnCounts,
+			"lockedUsers":    h.loginGuard.LockedUsers(users),
+			"currentUserID":  adminID,
+			"error":          err.Error(),
+			"isAdmin":        true,
+			"userName":       name,
 		})
 		return
 	}
+	h.auditService.Record(auditActor(c), models.AuditUserRoleChange, service.UserAuditTarget(target), map[string]interface{}{
+		"from": target.Role,
+		"to":   newRole,
+	})
 
 	c.Redirect(http.StatusFound, "/admin/users")
 }
 
+// UnlockUser はログインの失敗で一時的にロックされたアカウントを解除し、連続失敗回数をリセットする。
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なユーザーID"})
+		return
+	}
+
+	target, err := h.adminService.GetUserByID(uint(targetID))
+	if err == nil {
+		err = h.loginGuard.Unlock(target.ID, loginMeta(c))
+	}
+	if err != nil {
+		adminID := h.getUserID(c)
+		users, _ := h.adminService.GetAllUsers()
+		webauthnCounts, _ := h.adminService.GetWebAuthnCredentialCounts()
+		name, _ := c.Get(middleware.UserNameKey)
+
+		RenderHTML(c, http.StatusOK, "admin/users.html", gin.H{
+			"title":          "ユーザー管理",
+			"users":          users,
+			"webauthnCounts": webauthnCounts,
+			"lockedUsers":    h.loginGuard.LockedUsers(users),
+			"currentUserID":  adminID,
+			"error":          "ユーザーが見つかりません",
+			"isAdmin":        true,
+			"userName":       name,
+		})
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditUserUnlock, service.UserAuditTarget(target), nil)
+
+	c.Redirect(http.StatusFound, "/admin/users")
+}
+
+// LoginAttempts はログイン試行の記録を新しい順に表示する。メールアドレス・IP・結果で絞り込める。
+func (h *AdminHandler) LoginAttempts(c *gin.Context) {
+	filter := repository.LoginAttemptFilter{
+		Email:  c.Query("email"),
+		IP:     c.Query("ip"),
+		Result: c.Query("result"),
+	}
+	attempts, _ := h.loginGuard.RecentAttempts(filter, 200)
+	name, _ := c.Get(middleware.UserNameKey)
+
+	RenderHTML(c, http.StatusOK, "admin/login_attempts.html", gin.H{
+		"title":    "ログイン履歴",
+		"attempts": attempts,
+		"filter":   filter,
+		"isAdmin":  true,
+		"userName": name,
+	})
+}
+
 func (h *AdminHandler) APIKeys(c *gin.Context) {
 	keys, _ := h.apiKeyService.GetAllAPIKeys()
 	name, _ := c.Get(middleware.UserNameKey)
@@ -113,7 +200,11 @@ func (h *AdminHandler) CreateAPIKey(c *gin.Context) {
 	userID := h.getUserID(c)
 	keyName := c.PostForm("name")
 
-	plainKey, _, err := h.apiKeyService.CreateAPIKey(userID, keyName)
+	// 管理画面で作成するキーには全スコープを付与する。スコープや有効期限を絞る場合はプロフィール画面から作成する
+	plainKey, apiKey, err := h.apiKeyService.CreateAPIKey(userID, keyName, models.APIKeyScopes, nil, "")
+	if err == nil {
+		auditAPIKeyCreate(c, h.auditService, apiKey)
+	}
 	keys, _ := h.apiKeyService.GetAllAPIKeys()
 	name, _ := c.Get(middleware.UserNameKey)
 
@@ -159,7 +250,131 @@ func (h *AdminHandler) DeleteAPIKey(c *gin.Context) {
 		})
 		return
 	}
+	h.auditService.Record(auditActor(c), models.AuditAPIKeyDelete, service.AuditTarget{
+		Type: models.AuditTargetAPIKey,
+		ID:   strconv.FormatUint(id, 10),
+	}, nil)
 
 	c.Redirect(http.StatusFound, "/admin/api-keys")
 }
 
+func (h *AdminHandler) Notifications(c *gin.Context) {
+	status := c.Query("status")
+	channel := c.Query("channel")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	result, _ := h.outboxService.Search(status, channel, page, 50)
+	counts, _ := h.outboxService.CountByStatus()
+	name, _ := c.Get(middleware.UserNameKey)
+
+	var totalPages int
+	if result != nil {
+		totalPages = result.TotalPages
+	}
+
+	RenderHTML(c, http.StatusOK, "admin/notifications.html", gin.H{
+		"title":       "通知履歴",
+		"result":      result,
+		"counts":      counts,
+		"filter":      status,
+		"channel":     channel,
+		"currentPage": page,
+		"hasPrev":     page > 1,
+		"hasNext":     page < totalPages,
+		"prevPage":    page - 1,
+		"nextPage":    page + 1,
+		"isAdmin":     true,
+		"userName":    name,
+	})
+}
+
+func (h *AdminHandler) RequeueNotification(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効な通知ID"})
+		return
+	}
+
+	h.outboxService.Requeue(uint(id))
+
+	redirect := "/admin/notifications"
+	if status := c.PostForm("status"); status != "" {
+		redirect += "?status=" + url.QueryEscape(status)
+	}
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// auditFilter はクエリから監査ログの絞り込み条件を作る。from・to は管理者のタイムゾーンの日付で、to の日も含める。
+func auditFilter(c *gin.Context) repository.AuditEventFilter {
+	filter := repository.AuditEventFilter{
+		Action:     c.Query("action"),
+		ActorEmail: strings.TrimSpace(c.Query("actor")),
+		TargetType: c.Query("target_type"),
+		TargetID:   strings.TrimSpace(c.Query("target_id")),
+		IP:         strings.TrimSpace(c.Query("ip")),
+	}
+	loc := getUserLocation(c)
+	if from, err := time.ParseInLocation("2006-01-02", c.Query("from"), loc); err == nil {
+		filter.From = from
+	}
+	if to, err := time.ParseInLocation("2006-01-02", c.Query("to"), loc); err == nil {
+		filter.To = to.AddDate(0, 0, 1)
+	}
+	return filter
+}
+
+// Audit は監査ログを新しい順に表示する。操作・操作者・対象・IP・期間で絞り込める。
+func (h *AdminHandler) Audit(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	result, _ := h.auditService.Search(auditFilter(c), page, 50)
+	name, _ := c.Get(middleware.UserNameKey)
+
+	var totalPages int
+	if result != nil {
+		totalPages = result.TotalPages
+	}
+
+	// ページ移動・エクスポートのリンクに絞り込み条件を引き継ぐ
+	query := c.Request.URL.Query()
+	query.Del("page")
+
+	RenderHTML(c, http.StatusOK, "admin/audit.html", gin.H{
+		"title":       "監査ログ",
+		"result":      result,
+		"actions":     service.AuditActions,
+		"targetTypes": service.AuditTargetTypes,
+		"query":       query,
+		"filterQuery": template.URL(query.Encode()),
+		"currentPage": page,
+		"hasPrev":     page > 1,
+		"hasNext":     page < totalPages,
+		"prevPage":    page - 1,
+		"nextPage":    page + 1,
+		"isAdmin":     true,
+		"userName":    name,
+	})
+}
+
+// ExportAudit は絞り込み条件に合う監査ログを JSON でダウンロードさせる。
+func (h *AdminHandler) ExportAudit(c *gin.Context) {
+	events, err := h.auditService.Export(auditFilter(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export audit events"})
+		return
+	}
+
+	filename := "audit-" + time.Now().In(getUserLocation(c)).Format("20060102-150405") + ".json"
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	RenderJSON(c, http.StatusOK, gin.H{
+		"exported_at": time.Now(),
+		"count":       len(events),
+		"events":      events,
+	})
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"homework-manager/internal/middleware"
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead はアップロードの本文のうちファイル以外（境界・ヘッダー）に見込むサイズ。
+const multipartOverhead = 1 << 20
+
+type APIAttachmentHandler struct {
+	attachmentService *service.AttachmentService
+}
+
+func NewAPIAttachmentHandler(attachmentService *service.AttachmentService) *APIAttachmentHandler {
+	return &APIAttachmentHandler{
+		attachmentService: attachmentService,
+	}
+}
+
+func (h *APIAttachmentHandler) getUserID(c *gin.Context) uint {
+	userID, _ := c.Get(middleware.UserIDKey)
+	return userID.(uint)
+}
+
+// parseIDs は :id と :attachmentId を読み取る。不正な場合はエラーレスポンスを返して false を返す。
+func (h *APIAttachmentHandler) parseIDs(c *gin.Context, withAttachment bool) (uint, uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+		return 0, 0, false
+	}
+	if !withAttachment {
+		return uint(id), 0, true
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return 0, 0, false
+	}
+	return uint(id), uint(attachmentID), true
+}
+
+func (h *APIAttachmentHandler) respondError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, service.ErrAssignmentNotFound), errors.Is(err, service.ErrUnauthorized):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+	case errors.Is(err, service.ErrAttachmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+	case errors.Is(err, service.ErrAttachmentTooLarge), errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": service.ErrAttachmentTooLarge.Error()})
+	case errors.Is(err, service.ErrAttachmentQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAttachmentTypeNotAllowed):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAttachmentEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process attachment"})
+	}
+}
+
+func (h *APIAttachmentHandler) ListAttachments(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, _, ok := h.parseIDs(c, false)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.attachmentService.List(userID, id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	usage, _ := h.attachmentService.Usage(userID)
+
+	RenderJSON(c, http.StatusOK, gin.H{
+		"attachments": attachments,
+		"count":       len(attachments),
+		"usage":       usage,
+	})
+}
+
+func (h *APIAttachmentHandler) UploadAttachment(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, _, ok := h.parseIDs(c, false)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.attachmentService.MaxFileSize()+multipartOverhead)
+	file, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required (multipart/form-data)"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	defer f.Close()
+
+	attachment, err := h.attachmentService.Upload(userID, id, file.Filename, f)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusCreated, attachment)
+}
+
+func (h *APIAttachmentHandler) DownloadAttachment(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, attachmentID, ok := h.parseIDs(c, true)
+	if !ok {
+		return
+	}
+
+	attachment, content, err := h.attachmentService.Open(userID, id, attachmentID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	serveAttachment(c, attachment, content)
+}
+
+func (h *APIAttachmentHandler) DeleteAttachment(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, attachmentID, ok := h.parseIDs(c, true)
+	if !ok {
+		return
+	}
+
+	if err := h.attachmentService.Delete(userID, id, attachmentID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, gin.H{"message": "Attachment deleted"})
+}
+
+// serveAttachment は添付ファイルの内容を返す。画像・PDF はブラウザで表示し、それ以外と ?download=1 はダウンロードさせる。
+func serveAttachment(c *gin.Context, attachment *models.Attachment, content io.ReadCloser) {
+	defer content.Close()
+
+	disposition := "attachment"
+	if attachment.IsInline() && c.Query("download") == "" {
+		disposition = "inline"
+	}
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}); value != "" {
+		disposition = value
+	}
+
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+		"Content-Disposition": disposition,
+		"Cache-Control":       "private, no-cache",
+	})
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"homework-manager/internal/middleware"
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+	"homework-manager/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type APIChecklistHandler struct {
+	checklistService *service.ChecklistService
+}
+
+func NewAPIChecklistHandler(db *gorm.DB) *APIChecklistHandler {
+	return &APIChecklistHandler{
+		checklistService: service.NewChecklistService(db),
+	}
+}
+
+func (h *APIChecklistHandler) getUserID(c *gin.Context) uint {
+	userID, _ := c.Get(middleware.UserIDKey)
+	return userID.(uint)
+}
+
+// parseIDs は :id と :itemId を読み取る。不正な場合はエラーレスポンスを返して false を返す。
+func (h *APIChecklistHandler) parseIDs(c *gin.Context, withItem bool) (uint, uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+		return 0, 0, false
+	}
+	if !withItem {
+		return uint(id), 0, true
+	}
+	itemID, err := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return 0, 0, false
+	}
+	return uint(id), uint(itemID), true
+}
+
+func (h *APIChecklistHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrAssignmentNotFound), errors.Is(err, service.ErrUnauthorized):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+	case errors.Is(err, service.ErrChecklistItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Checklist item not found"})
+	case errors.Is(err, service.ErrChecklistItemTitleRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update checklist"})
+	}
+}
+
+func (h *APIChecklistHandler) ListItems(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, _, ok := h.parseIDs(c, false)
+	if !ok {
+		return
+	}
+
+	items, err := h.checklistService.List(userID, id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, gin.H{
+		"items":    items,
+		"count":    len(items),
+		"progress": models.ChecklistProgressOf(items),
+	})
+}
+
+type CreateChecklistItemInput struct {
+	Title   string `json:"title" binding:"required"`
+	DueDate string `json:"due_date"`
+}
+
+func (h *APIChecklistHandler) CreateItem(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, _, ok := h.parseIDs(c, false)
+	if !ok {
+		return
+	}
+
+	var input CreateChecklistItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	if err := validation.ValidateField("title", input.Title, true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dueDate *time.Time
+	if input.DueDate != "" {
+		parsed, err := parseDateString(input.DueDate, getUserLocation(c))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid due_date format"})
+			return
+		}
+		dueDate = &parsed
+	}
+
+	item, err := h.checklistService.Create(userID, id, input.Title, dueDate)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusCreated, item)
+}
+
+type UpdateChecklistItemAPIInput struct {
+	Title    *string `json:"title"`
+	DueDate  *string `json:"due_date"` // 空文字で期限を解除
+	IsDone   *bool   `json:"is_done"`
+	Position *int    `json:"position"` // 0 始まり
+}
+
+func (h *APIChecklistHandler) UpdateItem(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, itemID, ok := h.parseIDs(c, true)
+	if !ok {
+		return
+	}
+
+	var input UpdateChecklistItemAPIInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	if input.Title != nil {
+		if err := validation.ValidateField("title", *input.Title, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	serviceInput := service.UpdateChecklistItemInput{
+		Title:    input.Title,
+		IsDone:   input.IsDone,
+		Position: input.Position,
+	}
+	if input.DueDate != nil {
+		if *input.DueDate == "" {
+			serviceInput.ClearDueDate = true
+		} else {
+			parsed, err := parseDateString(*input.DueDate, getUserLocation(c))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid due_date format"})
+				return
+			}
+			serviceInput.DueDate = &parsed
+		}
+	}
+
+	item, err := h.checklistService.Update(userID, id, itemID, serviceInput)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, item)
+}
+
+func (h *APIChecklistHandler) ToggleItem(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, itemID, ok := h.parseIDs(c, true)
+	if !ok {
+		return
+	}
+
+	item, err := h.checklistService.Toggle(userID, id, itemID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, item)
+}
+
+func (h *APIChecklistHandler) DeleteItem(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, itemID, ok := h.parseIDs(c, true)
+	if !ok {
+		return
+	}
+
+	if err := h.checklistService.Delete(userID, id, itemID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, gin.H{"message": "Checklist item deleted"})
+}
@@ -0,0 +1,334 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"homework-manager/internal/middleware"
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+	"homework-manager/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type APIGroupHandler struct {
+	groupService *service.GroupService
+}
+
+func NewAPIGroupHandler(db *gorm.DB) *APIGroupHandler {
+	return &APIGroupHandler{
+		groupService: service.NewGroupService(db),
+	}
+}
+
+func (h *APIGroupHandler) getUserID(c *gin.Context) uint {
+	userID, _ := c.Get(middleware.UserIDKey)
+	return userID.(uint)
+}
+
+// GroupResponse は API で返すグループ。参加コードは教師にだけ返す。
+type GroupResponse struct {
+	models.Group
+	Role string `json:"role"`
+}
+
+func groupResponse(group *models.Group, role string) GroupResponse {
+	response := GroupResponse{Group: *group, Role: role}
+	if role != models.MembershipRoleTeacher {
+		response.JoinCode = ""
+	}
+	return response
+}
+
+// GroupMemberResponse は API で返すメンバー。アカウントの設定は含めない。
+type GroupMemberResponse struct {
+	UserID   uint      `json:"user_id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+func (h *APIGroupHandler) respondError(c *gin.Context, err error) {
+	var validationErr *validation.ValidationError
+	switch {
+	case errors.As(err, &validationErr), errors.Is(err, service.ErrInvalidJoinCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNotTeacher):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+	case errors.Is(err, service.ErrGroupAssignmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group assignment not found"})
+	case errors.Is(err, service.ErrAlreadyMember), errors.Is(err, service.ErrLastTeacher):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+	}
+}
+
+func (h *APIGroupHandler) parseID(c *gin.Context, name, label string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + label + " ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *APIGroupHandler) ListGroups(c *gin.Context) {
+	memberships, err := h.groupService.ListForUser(h.getUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch groups"})
+		return
+	}
+
+	responses := make([]GroupResponse, 0, len(memberships))
+	for _, membership := range memberships {
+		if membership.Group != nil {
+			responses = append(responses, groupResponse(membership.Group, membership.Role))
+		}
+	}
+
+	RenderJSON(c, http.StatusOK, gin.H{
+		"groups": responses,
+		"count":  len(responses),
+	})
+}
+
+type GroupInput struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// CreateGroup はグループを作成する。教師・管理者だけが作成でき、作成したユーザーは教師として参加する。
+func (h *APIGroupHandler) CreateGroup(c *gin.Context) {
+	var input GroupInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	group, err := h.groupService.Create(h.getUserID(c), input.Name, input.Description)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusCreated, groupResponse(group, models.MembershipRoleTeacher))
+}
+
+type JoinGroupInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+func (h *APIGroupHandler) JoinGroup(c *gin.Context) {
+	var input JoinGroupInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	membership, err := h.groupService.Join(h.getUserID(c), input.Code)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusCreated, groupResponse(membership.Group, membership.Role))
+}
+
+// GetGroup はグループを返す。教師にはメンバーも返す。
+func (h *APIGroupHandler) GetGroup(c *gin.Context) {
+	userID := h.getUserID(c)
+	groupID, ok := h.parseID(c, "id", "group")
+	if !ok {
+		return
+	}
+
+	group, membership, err := h.groupService.Get(userID, groupID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	response := gin.H{"group": groupResponse(group, membership.Role)}
+	if membership.IsTeacher() {
+		members, err := h.groupService.Members(userID, groupID)
+		if err != nil {
+			h.respondError(c, err)
+			return
+		}
+		responses := make([]GroupMemberResponse, 0, len(members))
+		for _, member := range members {
+			entry := GroupMemberResponse{UserID: member.UserID, Role: member.Role, JoinedAt: member.CreatedAt}
+			if member.User != nil {
+				entry.Name = member.User.Name
+				entry.Email = member.User.Email
+			}
+			responses = append(responses, entry)
+		}
+		response["members"] = responses
+	}
+
+	RenderJSON(c, http.StatusOK, response)
+}
+
+func (h *APIGroupHandler) LeaveGroup(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id", "group")
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.Leave(h.getUserID(c), groupID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, gin.H{"message": "Left group"})
+}
+
+func (h *APIGroupHandler) ListAssignments(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id", "group")
+	if !ok {
+		return
+	}
+
+	assignments, err := h.groupService.ListAssignments(h.getUserID(c), groupID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, gin.H{
+		"assignments": assignments,
+		"count":       len(assignments),
+	})
+}
+
+type GroupAssignmentInput struct {
+	Title        string `json:"title" binding:"required"`
+	Description  string `json:"description"`
+	Subject      string `json:"subject"`
+	Priority     string `json:"priority"`
+	DueDate      string `json:"due_date" binding:"required"`
+	EditBehavior string `json:"edit_behavior"` // 更新時のみ: this_only, this_and_future, all (default: this_and_future)
+}
+
+func (h *APIGroupHandler) bindAssignmentInput(c *gin.Context) (*GroupAssignmentInput, service.GroupAssignmentInput, bool) {
+	var input GroupAssignmentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return nil, service.GroupAssignmentInput{}, false
+	}
+	dueDate, err := parseDateString(input.DueDate, getUserLocation(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid due_date format"})
+		return nil, service.GroupAssignmentInput{}, false
+	}
+	return &input, service.GroupAssignmentInput{
+		Title:       input.Title,
+		Description: input.Description,
+		Subject:     input.Subject,
+		Priority:    input.Priority,
+		DueDate:     dueDate,
+	}, true
+}
+
+// CreateAssignment はグループの生徒全員に課題を配布する。
+func (h *APIGroupHandler) CreateAssignment(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id", "group")
+	if !ok {
+		return
+	}
+	_, input, ok := h.bindAssignmentInput(c)
+	if !ok {
+		return
+	}
+
+	assignment, err := h.groupService.CreateAssignment(h.getUserID(c), groupID, input)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusCreated, assignment)
+}
+
+// GetAssignment は配布した課題と生徒ごとの提出状況を返す。
+func (h *APIGroupHandler) GetAssignment(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id", "group")
+	if !ok {
+		return
+	}
+	assignmentID, ok := h.parseID(c, "assignmentId", "assignment")
+	if !ok {
+		return
+	}
+
+	progress, err := h.groupService.Progress(h.getUserID(c), groupID, assignmentID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, progress)
+}
+
+// UpdateAssignment は配布した課題を更新し、edit_behavior に従って生徒の課題に反映する。
+func (h *APIGroupHandler) UpdateAssignment(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id", "group")
+	if !ok {
+		return
+	}
+	assignmentID, ok := h.parseID(c, "assignmentId", "assignment")
+	if !ok {
+		return
+	}
+	raw, input, ok := h.bindAssignmentInput(c)
+	if !ok {
+		return
+	}
+	editBehavior := raw.EditBehavior
+	switch editBehavior {
+	case "":
+		editBehavior = models.EditBehaviorThisAndFuture
+	case models.EditBehaviorThisOnly, models.EditBehaviorThisAndFuture, models.EditBehaviorAll:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid edit_behavior"})
+		return
+	}
+
+	assignment, updated, err := h.groupService.UpdateAssignment(h.getUserID(c), groupID, assignmentID, input, editBehavior)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, gin.H{
+		"assignment":          assignment,
+		"updated_assignments": updated,
+	})
+}
+
+// DeleteAssignment は配布を取り消す。生徒の未完了の課題は削除し、完了した課題は残す。
+func (h *APIGroupHandler) DeleteAssignment(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id", "group")
+	if !ok {
+		return
+	}
+	assignmentID, ok := h.parseID(c, "assignmentId", "assignment")
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.DeleteAssignment(h.getUserID(c), groupID, assignmentID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, gin.H{"message": "Group assignment deleted"})
+}
@@ -1,26 +1,45 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"homework-manager/internal/ical"
 	"homework-manager/internal/middleware"
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
 	"homework-manager/internal/service"
 	"homework-manager/internal/validation"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type APIHandler struct {
-	assignmentService *service.AssignmentService
-	recurringService  *service.RecurringAssignmentService
+	assignmentService   *service.AssignmentService
+	recurringService    *service.RecurringAssignmentService
+	calendarService     *service.CalendarService
+	dataTransferService *service.DataTransferService
+	checklistService    *service.ChecklistService
+	subjectService      *service.SubjectService
+	tagService          *service.TagService
+	auditService        *service.AuditService
 }
 
-func NewAPIHandler() *APIHandler {
+func NewAPIHandler(db *gorm.DB, telegramBot *service.TelegramBotService) *APIHandler {
 	return &APIHandler{
-		assignmentService: service.NewAssignmentService(),
-		recurringService:  service.NewRecurringAssignmentService(),
+		assignmentService:   service.NewAssignmentService(db),
+		recurringService:    service.NewRecurringAssignmentService(db),
+		calendarService:     service.NewCalendarService(db),
+		dataTransferService: service.NewDataTransferService(db, telegramBot),
+		checklistService:    service.NewChecklistService(db),
+		subjectService:      service.NewSubjectService(db),
+		tagService:          service.NewTagService(db),
+		auditService:        service.NewAuditService(db),
 	}
 }
 
@@ -45,6 +64,10 @@ func (h *APIHandler) ListAssignments(c *gin.Context) {
 		pageSize = 100
 	}
 
+	if h.listByTags(c, userID, filter, page, pageSize) {
+		return
+	}
+
 	switch filter {
 	case "completed":
 		result, err := h.assignmentService.GetCompletedByUserPaginated(userID, page, pageSize)
@@ -52,7 +75,7 @@ func (h *APIHandler) ListAssignments(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
+		RenderJSON(c, http.StatusOK, gin.H{
 			"assignments":  result.Assignments,
 			"count":        len(result.Assignments),
 			"total_count":  result.TotalCount,
@@ -67,7 +90,7 @@ func (h *APIHandler) ListAssignments(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
+		RenderJSON(c, http.StatusOK, gin.H{
 			"assignments":  result.Assignments,
 			"count":        len(result.Assignments),
 			"total_count":  result.TotalCount,
@@ -82,7 +105,7 @@ func (h *APIHandler) ListAssignments(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
+		RenderJSON(c, http.StatusOK, gin.H{
 			"assignments":  result.Assignments,
 			"count":        len(result.Assignments),
 			"total_count":  result.TotalCount,
@@ -109,7 +132,7 @@ func (h *APIHandler) ListAssignments(c *gin.Context) {
 			end = totalCount
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		RenderJSON(c, http.StatusOK, gin.H{
 			"assignments":  assignments[start:end],
 			"count":        end - start,
 			"total_count":  totalCount,
@@ -123,6 +146,9 @@ func (h *APIHandler) ListAssignments(c *gin.Context) {
 func (h *APIHandler) ListPendingAssignments(c *gin.Context) {
 	userID := h.getUserID(c)
 	page, pageSize := h.parsePagination(c)
+	if h.listByTags(c, userID, "pending", page, pageSize) {
+		return
+	}
 
 	result, err := h.assignmentService.GetPendingByUserPaginated(userID, page, pageSize)
 	if err != nil {
@@ -136,6 +162,9 @@ func (h *APIHandler) ListPendingAssignments(c *gin.Context) {
 func (h *APIHandler) ListCompletedAssignments(c *gin.Context) {
 	userID := h.getUserID(c)
 	page, pageSize := h.parsePagination(c)
+	if h.listByTags(c, userID, "completed", page, pageSize) {
+		return
+	}
 
 	result, err := h.assignmentService.GetCompletedByUserPaginated(userID, page, pageSize)
 	if err != nil {
@@ -149,6 +178,9 @@ func (h *APIHandler) ListCompletedAssignments(c *gin.Context) {
 func (h *APIHandler) ListOverdueAssignments(c *gin.Context) {
 	userID := h.getUserID(c)
 	page, pageSize := h.parsePagination(c)
+	if h.listByTags(c, userID, "overdue", page, pageSize) {
+		return
+	}
 
 	result, err := h.assignmentService.GetOverdueByUserPaginated(userID, page, pageSize)
 	if err != nil {
@@ -167,8 +199,9 @@ func (h *APIHandler) ListDueTodayAssignments(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
 		return
 	}
+	assignments = filterByTags(assignments, service.ParseTagFilter(c.Query("tags"), c.Query("tag_mode")))
 
-	c.JSON(http.StatusOK, gin.H{
+	RenderJSON(c, http.StatusOK, gin.H{
 		"assignments": assignments,
 		"count":       len(assignments),
 	})
@@ -182,13 +215,50 @@ func (h *APIHandler) ListDueThisWeekAssignments(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
 		return
 	}
+	assignments = filterByTags(assignments, service.ParseTagFilter(c.Query("tags"), c.Query("tag_mode")))
 
-	c.JSON(http.StatusOK, gin.H{
+	RenderJSON(c, http.StatusOK, gin.H{
 		"assignments": assignments,
 		"count":       len(assignments),
 	})
 }
 
+// listByTags は ?tags= が指定されている場合にタグで絞り込んだ課題を返し、true を返す。
+// filter は pending / completed / overdue（空の場合はすべて）。
+func (h *APIHandler) listByTags(c *gin.Context, userID uint, filter string, page, pageSize int) bool {
+	tags := service.ParseTagFilter(c.Query("tags"), c.Query("tag_mode"))
+	if tags.IsEmpty() {
+		return false
+	}
+	switch filter {
+	case "pending", "completed", "overdue":
+	default:
+		filter = "all"
+	}
+
+	result, err := h.assignmentService.SearchAssignments(userID, "", "", filter, tags, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
+		return true
+	}
+	h.sendPaginatedResponse(c, result)
+	return true
+}
+
+// filterByTags は読み込み済みの課題をタグで絞り込む。
+func filterByTags(assignments []models.Assignment, tags repository.TagFilter) []models.Assignment {
+	if tags.IsEmpty() {
+		return assignments
+	}
+	filtered := make([]models.Assignment, 0, len(assignments))
+	for _, assignment := range assignments {
+		if tags.Matches(assignment.Tags) {
+			filtered = append(filtered, assignment)
+		}
+	}
+	return filtered
+}
+
 func (h *APIHandler) parsePagination(c *gin.Context) (page int, pageSize int) {
 	page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "20"))
@@ -206,7 +276,7 @@ func (h *APIHandler) parsePagination(c *gin.Context) (page int, pageSize int) {
 }
 
 func (h *APIHandler) sendPaginatedResponse(c *gin.Context, result *service.PaginatedResult) {
-	c.JSON(http.StatusOK, gin.H{
+	RenderJSON(c, http.StatusOK, gin.H{
 		"assignments":  result.Assignments,
 		"count":        len(result.Assignments),
 		"total_count":  result.TotalCount,
@@ -229,8 +299,9 @@ func (h *APIHandler) GetAssignment(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
 		return
 	}
+	assignment.ChecklistItems, _ = h.checklistService.List(userID, assignment.ID)
 
-	c.JSON(http.StatusOK, assignment)
+	RenderJSON(c, http.StatusOK, assignment)
 }
 
 type CreateAssignmentInput struct {
@@ -240,14 +311,27 @@ type CreateAssignmentInput struct {
 	Priority    string `json:"priority"`
 	DueDate     string `json:"due_date" binding:"required"`
 
-	ReminderEnabled       bool   `json:"reminder_enabled"`
+	// 省略した場合は科目の既定のリマインダーにする
+	ReminderEnabled       *bool  `json:"reminder_enabled"`
 	ReminderAt            string `json:"reminder_at"`
 	UrgentReminderEnabled *bool  `json:"urgent_reminder_enabled"`
-	Recurrence            struct {
+
+	// チェックリストの項目のタイトル。繰り返し課題では毎回の課題にコピーする
+	Checklist             []string `json:"checklist"`
+	ChecklistAutoComplete bool     `json:"checklist_auto_complete"`
+
+	// タグの名前。まだないタグは作成する
+	Tags []string `json:"tags"`
+
+	Recurrence struct {
 		Type     string      `json:"type"`
 		Interval int         `json:"interval"`
 		Weekday  interface{} `json:"weekday"`
+		Weekdays []int       `json:"weekdays"`
 		Day      interface{} `json:"day"`
+		Ordinal  *int        `json:"ordinal"`
+		RRule    string      `json:"rrule"`
+		ExDates  []string    `json:"exdates"`
 		Until    struct {
 			Type  string `json:"type"`
 			Count int    `json:"count"`
@@ -269,22 +353,39 @@ func (h *APIHandler) CreateAssignment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	for _, itemTitle := range input.Checklist {
+		if err := validation.ValidateField("title", itemTitle, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "checklist " + err.Error()})
+			return
+		}
+	}
+	if err := validation.ValidateTags(input.Tags); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	dueDate, err := parseDateString(input.DueDate)
+	dueDate, err := parseDateString(input.DueDate, getUserLocation(c))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid due_date format. Use RFC3339 or 2006-01-02T15:04"})
 		return
 	}
 
+	reminderEnabled := input.ReminderEnabled != nil && *input.ReminderEnabled
 	var reminderAt *time.Time
-	if input.ReminderEnabled && input.ReminderAt != "" {
-		reminderTime, err := parseDateString(input.ReminderAt)
+	if reminderEnabled && input.ReminderAt != "" {
+		reminderTime, err := parseDateString(input.ReminderAt, getUserLocation(c))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reminder_at format"})
 			return
 		}
 		reminderAt = &reminderTime
 	}
+	if input.ReminderEnabled == nil {
+		if subject := h.subjectService.FindByName(userID, input.Subject); subject != nil && subject.DefaultReminderOffset != nil {
+			reminderEnabled = true
+			reminderAt = subject.DefaultReminderAt(dueDate)
+		}
+	}
 
 	urgentReminder := true
 	if input.UrgentReminderEnabled != nil {
@@ -298,12 +399,19 @@ func (h *APIHandler) CreateAssignment(c *gin.Context) {
 			Subject:               input.Subject,
 			Priority:              input.Priority,
 			FirstDueDate:          dueDate,
-			DueTime:               dueDate.Format("15:04"),
+			DueTime:               dueDate.In(getUserLocation(c)).Format("15:04"),
 			RecurrenceType:        input.Recurrence.Type,
 			RecurrenceInterval:    input.Recurrence.Interval,
-			ReminderEnabled:       input.ReminderEnabled,
+			RecurrenceWeekdays:    input.Recurrence.Weekdays,
+			RecurrenceOrdinal:     input.Recurrence.Ordinal,
+			RRule:                 input.Recurrence.RRule,
+			ExDates:               strings.Join(input.Recurrence.ExDates, ","),
+			ReminderEnabled:       reminderEnabled,
 			ReminderOffset:        nil,
 			UrgentReminderEnabled: urgentReminder,
+			Checklist:             strings.Join(input.Checklist, "\n"),
+			ChecklistAutoComplete: input.ChecklistAutoComplete,
+			Tags:                  input.Tags,
 		}
 
 		if serviceInput.RecurrenceInterval < 1 {
@@ -333,32 +441,139 @@ func (h *APIHandler) CreateAssignment(c *gin.Context) {
 			count := input.Recurrence.Until.Count
 			serviceInput.EndCount = &count
 		} else if serviceInput.EndType == "date" && input.Recurrence.Until.Date != "" {
-			endDate, err := parseDateString(input.Recurrence.Until.Date)
+			endDate, err := parseDateString(input.Recurrence.Until.Date, getUserLocation(c))
 			if err == nil {
 				serviceInput.EndDate = &endDate
 			}
 		}
 
 		recurring, err := h.recurringService.Create(userID, serviceInput)
+		if errors.Is(err, service.ErrInvalidRecurrenceType) || errors.Is(err, service.ErrInvalidRecurrenceRule) || errors.Is(err, service.ErrInvalidExDates) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recurring assignment: " + err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{
+		RenderJSON(c, http.StatusCreated, gin.H{
 			"message":              "Recurring assignment created",
 			"recurring_assignment": recurring,
 		})
 		return
 	}
 
-	assignment, err := h.assignmentService.Create(userID, input.Title, input.Description, input.Subject, input.Priority, dueDate, input.ReminderEnabled, reminderAt, urgentReminder)
+	assignment, err := h.assignmentService.Create(userID, input.Title, input.Description, input.Subject, input.Priority, dueDate, reminderEnabled, reminderAt, urgentReminder)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create assignment"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, assignment)
+	if len(input.Checklist) > 0 {
+		for _, itemTitle := range input.Checklist {
+			if _, err := h.checklistService.Create(userID, assignment.ID, itemTitle, nil); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create checklist item"})
+				return
+			}
+		}
+		assignment.ChecklistItems, _ = h.checklistService.List(userID, assignment.ID)
+	}
+	if input.ChecklistAutoComplete {
+		h.checklistService.SetAutoComplete(userID, assignment.ID, true)
+		assignment.ChecklistAutoComplete = true
+	}
+	if len(input.Tags) > 0 {
+		if assignment.Tags, err = h.tagService.SetAssignmentTags(userID, assignment.ID, input.Tags); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set tags"})
+			return
+		}
+	}
+
+	RenderJSON(c, http.StatusCreated, assignment)
+}
+
+// ImportAssignments は .ics を取り込む。multipart の file フィールド、またはリクエストボディをそのまま受け付ける。
+// ?dry_run=true の場合は保存せずに取り込み内容だけを返す。
+func (h *APIHandler) ImportAssignments(c *gin.Context) {
+	userID := h.getUserID(c)
+	dryRun := c.Query("dry_run") == "true"
+
+	var body io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, service.MaxICalImportSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	result, err := h.calendarService.ImportICal(userID, data, dryRun)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrImportTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large (max 2MB)"})
+		case errors.Is(err, ical.ErrInvalidCalendar):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import assignments"})
+		}
+		return
+	}
+	auditICalImport(c, h.auditService, userID, result)
+
+	RenderJSON(c, http.StatusOK, result)
+}
+
+// ExportData は課題・繰り返し設定・通知設定を書き出す。?format=csv の場合は CSV の ZIP を返す。
+func (h *APIHandler) ExportData(c *gin.Context) {
+	writeDataExport(c, h.dataTransferService, h.getUserID(c))
+}
+
+// ImportData は ExportData の出力（JSON または CSV の ZIP）を取り込む。multipart の file フィールド、またはリクエストボディをそのまま受け付ける。
+func (h *APIHandler) ImportData(c *gin.Context) {
+	userID := h.getUserID(c)
+
+	var body io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, service.MaxDataImportSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	result, err := h.dataTransferService.ImportData(userID, data)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrImportTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large (max 10MB)"})
+		case errors.Is(err, service.ErrUnsupportedExportVersion), errors.Is(err, service.ErrUnsupportedImportFormat):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import data"})
+		}
+		return
+	}
+	auditDataImport(c, h.auditService, userID, result)
+
+	RenderJSON(c, http.StatusOK, result)
 }
 
 type UpdateAssignmentInput struct {
@@ -370,6 +585,10 @@ type UpdateAssignmentInput struct {
 	ReminderEnabled       *bool  `json:"reminder_enabled"`
 	ReminderAt            string `json:"reminder_at"`
 	UrgentReminderEnabled *bool  `json:"urgent_reminder_enabled"`
+	ChecklistAutoComplete *bool  `json:"checklist_auto_complete"`
+
+	// 指定した場合はタグをこの名前に置き換える（空の配列ですべて外す）
+	Tags *[]string `json:"tags"`
 }
 
 func (h *APIHandler) UpdateAssignment(c *gin.Context) {
@@ -396,6 +615,12 @@ func (h *APIHandler) UpdateAssignment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if input.Tags != nil {
+		if err := validation.ValidateTags(*input.Tags); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	title := input.Title
 	if title == "" {
@@ -419,7 +644,7 @@ func (h *APIHandler) UpdateAssignment(c *gin.Context) {
 
 	dueDate := existing.DueDate
 	if input.DueDate != "" {
-		parsedDate, err := parseDateString(input.DueDate)
+		parsedDate, err := parseDateString(input.DueDate, getUserLocation(c))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid due_date format"})
 			return
@@ -434,7 +659,7 @@ func (h *APIHandler) UpdateAssignment(c *gin.Context) {
 
 	reminderAt := existing.ReminderAt
 	if input.ReminderAt != "" {
-		parsedReminderAt, err := parseDateString(input.ReminderAt)
+		parsedReminderAt, err := parseDateString(input.ReminderAt, getUserLocation(c))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reminder_at format"})
 			return
@@ -454,7 +679,24 @@ func (h *APIHandler) UpdateAssignment(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, assignment)
+	if input.ChecklistAutoComplete != nil {
+		if err := h.checklistService.SetAutoComplete(userID, uint(id), *input.ChecklistAutoComplete); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update assignment"})
+			return
+		}
+		if assignment, err = h.assignmentService.GetByID(userID, uint(id)); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+			return
+		}
+	}
+	if input.Tags != nil {
+		if assignment.Tags, err = h.tagService.SetAssignmentTags(userID, uint(id), *input.Tags); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set tags"})
+			return
+		}
+	}
+
+	RenderJSON(c, http.StatusOK, assignment)
 }
 
 func (h *APIHandler) DeleteAssignment(c *gin.Context) {
@@ -480,7 +722,7 @@ func (h *APIHandler) DeleteAssignment(c *gin.Context) {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete recurring assignment"})
 				return
 			}
-			c.JSON(http.StatusOK, gin.H{"message": "Assignment and recurring settings deleted"})
+			RenderJSON(c, http.StatusOK, gin.H{"message": "Assignment and recurring settings deleted"})
 			return
 		}
 	}
@@ -490,7 +732,7 @@ func (h *APIHandler) DeleteAssignment(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Assignment deleted"})
+	RenderJSON(c, http.StatusOK, gin.H{"message": "Assignment deleted"})
 }
 
 func (h *APIHandler) ToggleAssignment(c *gin.Context) {
@@ -507,7 +749,7 @@ func (h *APIHandler) ToggleAssignment(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, assignment)
+	RenderJSON(c, http.StatusOK, assignment)
 }
 
 func (h *APIHandler) GetStatistics(c *gin.Context) {
@@ -515,11 +757,12 @@ func (h *APIHandler) GetStatistics(c *gin.Context) {
 
 	filter := service.StatisticsFilter{
 		Subject:         c.Query("subject"),
+		Tag:             c.Query("tag"),
 		IncludeArchived: c.Query("include_archived") == "true",
 	}
 
 	if fromStr := c.Query("from"); fromStr != "" {
-		fromDate, err := time.ParseInLocation("2006-01-02", fromStr, time.Local)
+		fromDate, err := time.ParseInLocation("2006-01-02", fromStr, getUserLocation(c))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' date format. Use YYYY-MM-DD"})
 			return
@@ -528,7 +771,7 @@ func (h *APIHandler) GetStatistics(c *gin.Context) {
 	}
 
 	if toStr := c.Query("to"); toStr != "" {
-		toDate, err := time.ParseInLocation("2006-01-02", toStr, time.Local)
+		toDate, err := time.ParseInLocation("2006-01-02", toStr, getUserLocation(c))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' date format. Use YYYY-MM-DD"})
 			return
@@ -542,21 +785,22 @@ func (h *APIHandler) GetStatistics(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, stats)
+	RenderJSON(c, http.StatusOK, stats)
 }
 
-func parseDateString(dateStr string) (time.Time, error) {
+// parseDateString は RFC 3339、または loc の現地時刻 (YYYY-MM-DDTHH:MM / YYYY-MM-DD) を解析し、保存用に UTC で返す。
+func parseDateString(dateStr string, loc *time.Location) (time.Time, error) {
 	t, err := time.Parse(time.RFC3339, dateStr)
 	if err == nil {
-		return t, nil
+		return t.UTC(), nil
 	}
-	t, err = time.ParseInLocation("2006-01-02T15:04", dateStr, time.Local)
+	t, err = time.ParseInLocation("2006-01-02T15:04", dateStr, loc)
 	if err == nil {
-		return t, nil
+		return t.UTC(), nil
 	}
-	t, err = time.ParseInLocation("2006-01-02", dateStr, time.Local)
+	t, err = time.ParseInLocation("2006-01-02", dateStr, loc)
 	if err == nil {
-		return t.Add(23*time.Hour + 59*time.Minute), nil
+		return t.Add(23*time.Hour + 59*time.Minute).UTC(), nil
 	}
 	return time.Time{}, err
 }
@@ -1,23 +1,26 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"homework-manager/internal/middleware"
 	"homework-manager/internal/models"
 	"homework-manager/internal/service"
+	"homework-manager/internal/validation"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type APIRecurringHandler struct {
 	recurringService *service.RecurringAssignmentService
 }
 
-func NewAPIRecurringHandler() *APIRecurringHandler {
+func NewAPIRecurringHandler(db *gorm.DB) *APIRecurringHandler {
 	return &APIRecurringHandler{
-		recurringService: service.NewRecurringAssignmentService(),
+		recurringService: service.NewRecurringAssignmentService(db),
 	}
 }
 
@@ -35,7 +38,7 @@ func (h *APIRecurringHandler) ListRecurring(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	RenderJSON(c, http.StatusOK, gin.H{
 		"recurring_assignments": recurringList,
 		"count":                 len(recurringList),
 	})
@@ -55,27 +58,34 @@ func (h *APIRecurringHandler) GetRecurring(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, recurring)
+	RenderJSON(c, http.StatusOK, recurring)
 }
 
 type UpdateRecurringAPIInput struct {
-	Title                 *string `json:"title"`
-	Description           *string `json:"description"`
-	Subject               *string `json:"subject"`
-	Priority              *string `json:"priority"`
-	RecurrenceType        *string `json:"recurrence_type"`
-	RecurrenceInterval    *int    `json:"recurrence_interval"`
-	RecurrenceWeekday     *int    `json:"recurrence_weekday"`
-	RecurrenceDay         *int    `json:"recurrence_day"`
-	DueTime               *string `json:"due_time"`
-	EndType               *string `json:"end_type"`
-	EndCount              *int    `json:"end_count"`
-	EndDate               *string `json:"end_date"`  // YYYY-MM-DD
-	IsActive              *bool   `json:"is_active"` // To stop/resume
-	ReminderEnabled       *bool   `json:"reminder_enabled"`
-	ReminderOffset        *int    `json:"reminder_offset"`
-	UrgentReminderEnabled *bool   `json:"urgent_reminder_enabled"`
-	EditBehavior          string  `json:"edit_behavior"` // this_only, this_and_future, all (default: this_only)
+	Title                 *string   `json:"title"`
+	Description           *string   `json:"description"`
+	Subject               *string   `json:"subject"`
+	Priority              *string   `json:"priority"`
+	RecurrenceType        *string   `json:"recurrence_type"`
+	RecurrenceInterval    *int      `json:"recurrence_interval"`
+	RecurrenceWeekday     *int      `json:"recurrence_weekday"`
+	RecurrenceWeekdays    []int     `json:"recurrence_weekdays"`
+	RecurrenceDay         *int      `json:"recurrence_day"`
+	RecurrenceOrdinal     *int      `json:"recurrence_ordinal"` // 0 で曜日指定を解除
+	RRule                 *string   `json:"rrule"`
+	ExDates               *string   `json:"exdates"` // カンマ区切り (YYYYMMDD)
+	DueTime               *string   `json:"due_time"`
+	EndType               *string   `json:"end_type"`
+	EndCount              *int      `json:"end_count"`
+	EndDate               *string   `json:"end_date"`  // YYYY-MM-DD
+	IsActive              *bool     `json:"is_active"` // To stop/resume
+	ReminderEnabled       *bool     `json:"reminder_enabled"`
+	ReminderOffset        *int      `json:"reminder_offset"`
+	UrgentReminderEnabled *bool     `json:"urgent_reminder_enabled"`
+	Checklist             *string   `json:"checklist"` // 1行に1項目
+	ChecklistAutoComplete *bool     `json:"checklist_auto_complete"`
+	Tags                  *[]string `json:"tags"`          // 生成する課題に付けるタグ
+	EditBehavior          string    `json:"edit_behavior"` // this_only, this_and_future, all (default: this_only)
 }
 
 func (h *APIRecurringHandler) UpdateRecurring(c *gin.Context) {
@@ -92,6 +102,19 @@ func (h *APIRecurringHandler) UpdateRecurring(c *gin.Context) {
 		return
 	}
 
+	if input.Checklist != nil {
+		if err := validation.ValidateField("checklist", *input.Checklist, false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if input.Tags != nil {
+		if err := validation.ValidateTags(*input.Tags); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	existing, err := h.recurringService.GetByID(userID, uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Recurring assignment not found"})
@@ -106,6 +129,11 @@ func (h *APIRecurringHandler) UpdateRecurring(c *gin.Context) {
 		existing.IsActive = *input.IsActive
 	}
 
+	if input.RRule != nil && *input.RRule != "" && input.RecurrenceType == nil {
+		custom := models.RecurrenceCustom
+		input.RecurrenceType = &custom
+	}
+
 	serviceInput := service.UpdateRecurringInput{
 		Title:                 input.Title,
 		Description:           input.Description,
@@ -114,7 +142,11 @@ func (h *APIRecurringHandler) UpdateRecurring(c *gin.Context) {
 		RecurrenceType:        input.RecurrenceType,
 		RecurrenceInterval:    input.RecurrenceInterval,
 		RecurrenceWeekday:     input.RecurrenceWeekday,
+		RecurrenceWeekdays:    input.RecurrenceWeekdays,
 		RecurrenceDay:         input.RecurrenceDay,
+		RecurrenceOrdinal:     input.RecurrenceOrdinal,
+		RRule:                 input.RRule,
+		ExDates:               input.ExDates,
 		DueTime:               input.DueTime,
 		EndType:               input.EndType,
 		EndCount:              input.EndCount,
@@ -122,10 +154,13 @@ func (h *APIRecurringHandler) UpdateRecurring(c *gin.Context) {
 		ReminderEnabled:       input.ReminderEnabled,
 		ReminderOffset:        input.ReminderOffset,
 		UrgentReminderEnabled: input.UrgentReminderEnabled,
+		Checklist:             input.Checklist,
+		ChecklistAutoComplete: input.ChecklistAutoComplete,
+		Tags:                  input.Tags,
 	}
 
 	if input.EndDate != nil && *input.EndDate != "" {
-		endDate, err := parseDateString(*input.EndDate)
+		endDate, err := parseDateString(*input.EndDate, getUserLocation(c))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format"})
 			return
@@ -138,6 +173,10 @@ func (h *APIRecurringHandler) UpdateRecurring(c *gin.Context) {
 	}
 
 	updated, err := h.recurringService.Update(userID, uint(id), serviceInput)
+	if errors.Is(err, service.ErrInvalidRecurrenceRule) || errors.Is(err, service.ErrInvalidExDates) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recurring assignment"})
 		return
@@ -145,7 +184,7 @@ func (h *APIRecurringHandler) UpdateRecurring(c *gin.Context) {
 
 	updated.IsActive = existing.IsActive
 
-	c.JSON(http.StatusOK, updated)
+	RenderJSON(c, http.StatusOK, updated)
 }
 
 func (h *APIRecurringHandler) DeleteRecurring(c *gin.Context) {
@@ -163,5 +202,5 @@ func (h *APIRecurringHandler) DeleteRecurring(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Recurring assignment deleted"})
+	RenderJSON(c, http.StatusOK, gin.H{"message": "Recurring assignment deleted"})
 }
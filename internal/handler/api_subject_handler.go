@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"homework-manager/internal/middleware"
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+	"homework-manager/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type APISubjectHandler struct {
+	subjectService *service.SubjectService
+	auditService   *service.AuditService
+}
+
+func NewAPISubjectHandler(db *gorm.DB) *APISubjectHandler {
+	return &APISubjectHandler{
+		subjectService: service.NewSubjectService(db),
+		auditService:   service.NewAuditService(db),
+	}
+}
+
+func (h *APISubjectHandler) getUserID(c *gin.Context) uint {
+	userID, _ := c.Get(middleware.UserIDKey)
+	return userID.(uint)
+}
+
+// SubjectResponse は API で返す科目。科目の課題の数（削除した課題を除く）を付ける。
+type SubjectResponse struct {
+	models.Subject
+	AssignmentCount int64 `json:"assignment_count"`
+}
+
+func (h *APISubjectHandler) respondError(c *gin.Context, err error) {
+	var validationErr *validation.ValidationError
+	switch {
+	case errors.As(err, &validationErr), errors.Is(err, service.ErrSubjectMergeSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrSubjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subject not found"})
+	case errors.Is(err, service.ErrSubjectNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subject"})
+	}
+}
+
+func (h *APISubjectHandler) parseID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subject ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *APISubjectHandler) response(userID uint, subject *models.Subject) SubjectResponse {
+	return SubjectResponse{Subject: *subject, AssignmentCount: h.subjectService.Counts(userID)[subject.ID]}
+}
+
+func (h *APISubjectHandler) ListSubjects(c *gin.Context) {
+	userID := h.getUserID(c)
+
+	subjects, err := h.subjectService.List(userID, c.Query("include_archived") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subjects"})
+		return
+	}
+
+	counts := h.subjectService.Counts(userID)
+	responses := make([]SubjectResponse, 0, len(subjects))
+	for _, subject := range subjects {
+		responses = append(responses, SubjectResponse{Subject: subject, AssignmentCount: counts[subject.ID]})
+	}
+
+	RenderJSON(c, http.StatusOK, gin.H{
+		"subjects": responses,
+		"count":    len(responses),
+	})
+}
+
+func (h *APISubjectHandler) GetSubject(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	subject, err := h.subjectService.Get(userID, id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, h.response(userID, subject))
+}
+
+type CreateSubjectInput struct {
+	Name                  string `json:"name" binding:"required"`
+	Color                 string `json:"color"`
+	Teacher               string `json:"teacher"`
+	Room                  string `json:"room"`
+	DefaultPriority       string `json:"default_priority"`
+	DefaultReminderOffset *int   `json:"default_reminder_offset"` // 期限の何分前か
+}
+
+func (h *APISubjectHandler) CreateSubject(c *gin.Context) {
+	userID := h.getUserID(c)
+
+	var input CreateSubjectInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	subject, err := h.subjectService.Create(userID, service.SubjectInput{
+		Name:                  input.Name,
+		Color:                 input.Color,
+		Teacher:               input.Teacher,
+		Room:                  input.Room,
+		DefaultPriority:       input.DefaultPriority,
+		DefaultReminderOffset: input.DefaultReminderOffset,
+	})
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusCreated, h.response(userID, subject))
+}
+
+type UpdateSubjectInput struct {
+	Name                  *string `json:"name"`
+	Color                 *string `json:"color"`
+	Teacher               *string `json:"teacher"`
+	Room                  *string `json:"room"`
+	DefaultPriority       *string `json:"default_priority"`        // "" で指定なし
+	DefaultReminderOffset *int    `json:"default_reminder_offset"` // -1 で設定なし
+	IsArchived            *bool   `json:"is_archived"`
+}
+
+func (h *APISubjectHandler) UpdateSubject(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	var input UpdateSubjectInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	existing, err := h.subjectService.Get(userID, id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	update := service.SubjectInput{
+		Name:                  existing.Name,
+		Color:                 existing.Color,
+		Teacher:               existing.Teacher,
+		Room:                  existing.Room,
+		DefaultPriority:       existing.DefaultPriority,
+		DefaultReminderOffset: existing.DefaultReminderOffset,
+	}
+	if input.Name != nil {
+		update.Name = *input.Name
+	}
+	if input.Color != nil {
+		update.Color = *input.Color
+	}
+	if input.Teacher != nil {
+		update.Teacher = *input.Teacher
+	}
+	if input.Room != nil {
+		update.Room = *input.Room
+	}
+	if input.DefaultPriority != nil {
+		update.DefaultPriority = *input.DefaultPriority
+	}
+	if input.DefaultReminderOffset != nil {
+		update.DefaultReminderOffset = input.DefaultReminderOffset
+		if *input.DefaultReminderOffset == -1 {
+			update.DefaultReminderOffset = nil
+		}
+	}
+
+	subject, err := h.subjectService.Update(userID, id, update)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	if subject.Name != existing.Name {
+		h.auditService.Record(auditActor(c), models.AuditSubjectRename, service.SubjectAuditTarget(subject), map[string]interface{}{
+			"from": existing.Name,
+			"to":   subject.Name,
+		})
+	}
+
+	if input.IsArchived != nil && *input.IsArchived != subject.IsArchived {
+		subject, err = h.subjectService.SetArchived(userID, id, *input.IsArchived)
+		if err != nil {
+			h.respondError(c, err)
+			return
+		}
+		action := models.AuditSubjectArchive
+		if !subject.IsArchived {
+			action = models.AuditSubjectUnarchive
+		}
+		h.auditService.Record(auditActor(c), action, service.SubjectAuditTarget(subject), nil)
+	}
+
+	RenderJSON(c, http.StatusOK, h.response(userID, subject))
+}
+
+type MergeSubjectInput struct {
+	TargetID uint `json:"target_id" binding:"required"`
+}
+
+// MergeSubject は科目の課題・繰り返し設定を target_id の科目に移し、科目を削除する。統合先の科目を返す。
+func (h *APISubjectHandler) MergeSubject(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	var input MergeSubjectInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	source, err := h.subjectService.Get(userID, id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	target, err := h.subjectService.Merge(userID, id, input.TargetID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditSubjectMerge, service.SubjectAuditTarget(source), map[string]interface{}{
+		"into": target.Name,
+	})
+
+	RenderJSON(c, http.StatusOK, h.response(userID, target))
+}
+
+func (h *APISubjectHandler) DeleteSubject(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	subject, err := h.subjectService.Delete(userID, id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditSubjectDelete, service.SubjectAuditTarget(subject), nil)
+
+	RenderJSON(c, http.StatusOK, gin.H{"message": "Subject deleted"})
+}
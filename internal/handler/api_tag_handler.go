@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"homework-manager/internal/middleware"
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+	"homework-manager/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type APITagHandler struct {
+	tagService *service.TagService
+}
+
+func NewAPITagHandler(db *gorm.DB) *APITagHandler {
+	return &APITagHandler{
+		tagService: service.NewTagService(db),
+	}
+}
+
+func (h *APITagHandler) getUserID(c *gin.Context) uint {
+	userID, _ := c.Get(middleware.UserIDKey)
+	return userID.(uint)
+}
+
+// TagResponse は API で返すタグ。タグの付いた課題の数（削除した課題を除く）を付ける。
+type TagResponse struct {
+	models.Tag
+	AssignmentCount int64 `json:"assignment_count"`
+}
+
+func (h *APITagHandler) respondError(c *gin.Context, err error) {
+	var validationErr *validation.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrTagNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+	case errors.Is(err, service.ErrTagNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag"})
+	}
+}
+
+func (h *APITagHandler) parseID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *APITagHandler) ListTags(c *gin.Context) {
+	userID := h.getUserID(c)
+
+	tags, err := h.tagService.List(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
+		return
+	}
+
+	counts := h.tagService.Counts(userID)
+	responses := make([]TagResponse, 0, len(tags))
+	for _, tag := range tags {
+		responses = append(responses, TagResponse{Tag: tag, AssignmentCount: counts[tag.ID]})
+	}
+
+	RenderJSON(c, http.StatusOK, gin.H{
+		"tags":  responses,
+		"count": len(responses),
+	})
+}
+
+type TagInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func (h *APITagHandler) CreateTag(c *gin.Context) {
+	userID := h.getUserID(c)
+
+	var input TagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	tag, err := h.tagService.Create(userID, input.Name)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusCreated, TagResponse{Tag: *tag})
+}
+
+// UpdateTag はタグの名前を変える。課題に付いているタグもそのまま新しい名前になる。
+func (h *APITagHandler) UpdateTag(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	var input TagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	tag, err := h.tagService.Rename(userID, id, input.Name)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, TagResponse{Tag: *tag, AssignmentCount: h.tagService.Counts(userID)[tag.ID]})
+}
+
+// DeleteTag はタグを削除する。課題は削除しない。
+func (h *APITagHandler) DeleteTag(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.tagService.Delete(userID, id); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	RenderJSON(c, http.StatusOK, gin.H{"message": "Tag deleted"})
+}
@@ -1,30 +1,46 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"homework-manager/internal/ical"
 	"homework-manager/internal/middleware"
 	"homework-manager/internal/models"
 	"homework-manager/internal/service"
 	"homework-manager/internal/validation"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type AssignmentHandler struct {
 	assignmentService   *service.AssignmentService
 	notificationService *service.NotificationService
 	recurringService    *service.RecurringAssignmentService
+	calendarService     *service.CalendarService
+	checklistService    *service.ChecklistService
+	attachmentService   *service.AttachmentService
+	subjectService      *service.SubjectService
+	tagService          *service.TagService
+	auditService        *service.AuditService
 }
 
-func NewAssignmentHandler(notificationService *service.NotificationService) *AssignmentHandler {
+func NewAssignmentHandler(db *gorm.DB, notificationService *service.NotificationService, attachmentService *service.AttachmentService) *AssignmentHandler {
 	return &AssignmentHandler{
-		assignmentService:   service.NewAssignmentService(),
+		assignmentService:   service.NewAssignmentService(db),
 		notificationService: notificationService,
-		recurringService:    service.NewRecurringAssignmentService(),
+		recurringService:    service.NewRecurringAssignmentService(db),
+		calendarService:     service.NewCalendarService(db),
+		checklistService:    service.NewChecklistService(db),
+		attachmentService:   attachmentService,
+		subjectService:      service.NewSubjectService(db),
+		tagService:          service.NewTagService(db),
+		auditService:        service.NewAuditService(db),
 	}
 }
 
@@ -43,14 +59,18 @@ func (h *AssignmentHandler) Dashboard(c *gin.Context) {
 	role, _ := c.Get(middleware.UserRoleKey)
 	name, _ := c.Get(middleware.UserNameKey)
 
+	listed := append(append(append([]models.Assignment{}, dueToday...), overdue...), upcoming...)
+
 	RenderHTML(c, http.StatusOK, "dashboard.html", gin.H{
-		"title":    "ダッシュボード",
-		"stats":    stats,
-		"dueToday": dueToday,
-		"overdue":  overdue,
-		"upcoming": upcoming,
-		"isAdmin":  role == "admin",
-		"userName": name,
+		"title":         "ダッシュボード",
+		"stats":         stats,
+		"dueToday":      dueToday,
+		"overdue":       overdue,
+		"upcoming":      upcoming,
+		"progress":      h.checklistService.Progress(listed),
+		"subjectColors": h.subjectService.Colors(userID),
+		"isAdmin":       role == "admin",
+		"userName":      name,
 	})
 }
 
@@ -63,6 +83,8 @@ func (h *AssignmentHandler) Index(c *gin.Context) {
 	}
 	query := c.Query("q")
 	priority := c.Query("priority")
+	tags := c.Query("tags")
+	tagFilter := service.ParseTagFilter(tags, c.Query("tag_mode"))
 	pageStr := c.DefaultQuery("page", "1")
 	page, err := strconv.Atoi(pageStr)
 	if err != nil || page < 1 {
@@ -70,7 +92,7 @@ func (h *AssignmentHandler) Index(c *gin.Context) {
 	}
 	const pageSize = 10
 
-	result, err := h.assignmentService.SearchAssignments(userID, query, priority, filter, page, pageSize)
+	result, err := h.assignmentService.SearchAssignments(userID, query, priority, filter, tagFilter, page, pageSize)
 
 	var assignments []models.Assignment
 	var totalPages, currentPage int
@@ -84,33 +106,45 @@ func (h *AssignmentHandler) Index(c *gin.Context) {
 		currentPage = result.CurrentPage
 	}
 
+	allTags, _ := h.tagService.List(userID)
+
 	role, _ := c.Get(middleware.UserRoleKey)
 	name, _ := c.Get(middleware.UserNameKey)
 
 	RenderHTML(c, http.StatusOK, "assignments/index.html", gin.H{
-		"title":       "課題一覧",
-		"assignments": assignments,
-		"filter":      filter,
-		"query":       query,
-		"priority":    priority,
-		"isAdmin":     role == "admin",
-		"userName":    name,
-		"currentPage": currentPage,
-		"totalPages":  totalPages,
-		"hasPrev":     currentPage > 1,
-		"hasNext":     currentPage < totalPages,
-		"prevPage":    currentPage - 1,
-		"nextPage":    currentPage + 1,
+		"title":         "課題一覧",
+		"assignments":   assignments,
+		"progress":      h.checklistService.Progress(assignments),
+		"attachments":   h.attachmentService.Counts(assignments),
+		"subjectColors": h.subjectService.Colors(userID),
+		"filter":        filter,
+		"query":         query,
+		"priority":      priority,
+		"tags":          tags,
+		"tagMode":       tagFilter.Match,
+		"allTags":       allTags,
+		"isAdmin":       role == "admin",
+		"userName":      name,
+		"currentPage":   currentPage,
+		"totalPages":    totalPages,
+		"hasPrev":       currentPage > 1,
+		"hasNext":       currentPage < totalPages,
+		"prevPage":      currentPage - 1,
+		"nextPage":      currentPage + 1,
 	})
 }
 
 func (h *AssignmentHandler) New(c *gin.Context) {
 	role, _ := c.Get(middleware.UserRoleKey)
 	name, _ := c.Get(middleware.UserNameKey)
-	now := time.Now()
+	now := time.Now().In(getUserLocation(c))
+	subjects, _ := h.subjectService.List(h.getUserID(c), false)
+	allTags, _ := h.tagService.List(h.getUserID(c))
 
 	RenderHTML(c, http.StatusOK, "assignments/new.html", gin.H{
 		"title":          "課題登録",
+		"subjects":       subjects,
+		"allTags":        allTags,
 		"isAdmin":        role == "admin",
 		"userName":       name,
 		"currentWeekday": int(now.Weekday()),
@@ -126,19 +160,33 @@ func (h *AssignmentHandler) Create(c *gin.Context) {
 	subject := c.PostForm("subject")
 	priority := c.PostForm("priority")
 	dueDateStr := c.PostForm("due_date")
-
-	if err := validation.ValidateAssignmentInput(title, description, subject, priority); err != nil {
+	checklist := c.PostForm("checklist")
+	checklistAutoComplete := c.PostForm("checklist_auto_complete") == "on"
+	tags := c.PostForm("tags")
+	tagNames := models.ParseTagNames(tags)
+
+	err := validation.ValidateAssignmentInput(title, description, subject, priority)
+	if err == nil {
+		err = validation.ValidateField("checklist", checklist, false)
+	}
+	if err == nil {
+		err = validation.ValidateTags(tagNames)
+	}
+	if err != nil {
 		role, _ := c.Get(middleware.UserRoleKey)
 		name, _ := c.Get(middleware.UserNameKey)
 		RenderHTML(c, http.StatusOK, "assignments/new.html", gin.H{
-			"title":       "課題登録",
-			"error":       err.Error(),
-			"formTitle":   title,
-			"description": description,
-			"subject":     subject,
-			"priority":    priority,
-			"isAdmin":     role == "admin",
-			"userName":    name,
+			"title":                 "課題登録",
+			"error":                 err.Error(),
+			"formTitle":             title,
+			"description":           description,
+			"subject":               subject,
+			"priority":              priority,
+			"checklist":             checklist,
+			"checklistAutoComplete": checklistAutoComplete,
+			"tags":                  tags,
+			"isAdmin":               role == "admin",
+			"userName":              name,
 		})
 		return
 	}
@@ -147,15 +195,16 @@ func (h *AssignmentHandler) Create(c *gin.Context) {
 	reminderAtStr := c.PostForm("reminder_at")
 	var reminderAt *time.Time
 	if reminderEnabled && reminderAtStr != "" {
-		if parsed, err := time.ParseInLocation("2006-01-02T15:04", reminderAtStr, time.Local); err == nil {
+		if parsed, err := time.ParseInLocation("2006-01-02T15:04", reminderAtStr, getUserLocation(c)); err == nil {
+			parsed = parsed.UTC()
 			reminderAt = &parsed
 		}
 	}
 	urgentReminderEnabled := c.PostForm("urgent_reminder_enabled") == "on"
 
-	dueDate, err := time.ParseInLocation("2006-01-02T15:04", dueDateStr, time.Local)
+	dueDate, err := time.ParseInLocation("2006-01-02T15:04", dueDateStr, getUserLocation(c))
 	if err != nil {
-		dueDate, err = time.ParseInLocation("2006-01-02", dueDateStr, time.Local)
+		dueDate, err = time.ParseInLocation("2006-01-02", dueDateStr, getUserLocation(c))
 		if err != nil {
 			role, _ := c.Get(middleware.UserRoleKey)
 			name, _ := c.Get(middleware.UserNameKey)
@@ -173,6 +222,7 @@ func (h *AssignmentHandler) Create(c *gin.Context) {
 		}
 		dueDate = dueDate.Add(23*time.Hour + 59*time.Minute)
 	}
+	dueDate = dueDate.UTC()
 
 	recurrenceType := c.PostForm("recurrence_type")
 	if recurrenceType != "" && recurrenceType != "none" {
@@ -182,12 +232,7 @@ func (h *AssignmentHandler) Create(c *gin.Context) {
 			recurrenceInterval = v
 		}
 
-		var recurrenceWeekday *int
-		if wd := c.PostForm("recurrence_weekday"); wd != "" {
-			if v, err := strconv.Atoi(wd); err == nil && v >= 0 && v <= 6 {
-				recurrenceWeekday = &v
-			}
-		}
+		recurrenceWeekdays, recurrenceOrdinal := parseRecurrenceWeekdays(c, recurrenceType)
 
 		var recurrenceDay *int
 		if d := c.PostForm("recurrence_day"); d != "" {
@@ -210,14 +255,14 @@ func (h *AssignmentHandler) Create(c *gin.Context) {
 
 		var endDate *time.Time
 		if ed := c.PostForm("end_date"); ed != "" {
-			if v, err := time.ParseInLocation("2006-01-02", ed, time.Local); err == nil {
+			if v, err := time.ParseInLocation("2006-01-02", ed, getUserLocation(c)); err == nil {
+				v = v.UTC()
 				endDate = &v
 			}
 		}
 
-		dueTime := dueDate.Format("15:04")
+		dueTime := dueDate.In(getUserLocation(c)).Format("15:04")
 
-		recurringService := service.NewRecurringAssignmentService()
 		input := service.CreateRecurringAssignmentInput{
 			Title:                 title,
 			Description:           description,
@@ -225,18 +270,24 @@ func (h *AssignmentHandler) Create(c *gin.Context) {
 			Priority:              priority,
 			RecurrenceType:        recurrenceType,
 			RecurrenceInterval:    recurrenceInterval,
-			RecurrenceWeekday:     recurrenceWeekday,
+			RecurrenceWeekdays:    recurrenceWeekdays,
 			RecurrenceDay:         recurrenceDay,
+			RecurrenceOrdinal:     recurrenceOrdinal,
+			RRule:                 c.PostForm("rrule"),
+			ExDates:               c.PostForm("exdates"),
 			DueTime:               dueTime,
 			EndType:               endType,
 			EndCount:              endCount,
 			EndDate:               endDate,
 			ReminderEnabled:       reminderEnabled,
 			UrgentReminderEnabled: urgentReminderEnabled,
+			Checklist:             checklist,
+			ChecklistAutoComplete: checklistAutoComplete,
+			Tags:                  tagNames,
 			FirstDueDate:          dueDate,
 		}
 
-		_, err = recurringService.Create(userID, input)
+		_, err = h.recurringService.Create(userID, input)
 		if err != nil {
 			role, _ := c.Get(middleware.UserRoleKey)
 			name, _ := c.Get(middleware.UserNameKey)
@@ -270,6 +321,16 @@ func (h *AssignmentHandler) Create(c *gin.Context) {
 			return
 		}
 
+		for _, itemTitle := range models.ParseChecklistTitles(checklist) {
+			h.checklistService.Create(userID, assignment.ID, itemTitle, nil)
+		}
+		if checklistAutoComplete {
+			h.checklistService.SetAutoComplete(userID, assignment.ID, true)
+		}
+		if len(tagNames) > 0 {
+			h.tagService.SetAssignmentTags(userID, assignment.ID, tagNames)
+		}
+
 		if h.notificationService != nil {
 			go h.notificationService.SendAssignmentCreatedNotification(userID, assignment)
 		}
@@ -288,20 +349,41 @@ func (h *AssignmentHandler) Edit(c *gin.Context) {
 		return
 	}
 
+	h.renderEdit(c, assignment, "")
+}
+
+// renderEdit は課題の編集画面を表示する。attachmentError はファイルを添付できなかったときの表示。
+func (h *AssignmentHandler) renderEdit(c *gin.Context, assignment *models.Assignment, attachmentError string) {
+	userID := assignment.UserID
+
 	var recurring *models.RecurringAssignment
 	if assignment.RecurringAssignmentID != nil {
 		recurring, _ = h.recurringService.GetByID(userID, *assignment.RecurringAssignmentID)
 	}
 
+	items, _ := h.checklistService.List(userID, assignment.ID)
+	attachments, _ := h.attachmentService.List(userID, assignment.ID)
+	usage, _ := h.attachmentService.Usage(userID)
+	subjects, _ := h.subjectService.List(userID, false)
+	allTags, _ := h.tagService.List(userID)
+
 	role, _ := c.Get(middleware.UserRoleKey)
 	name, _ := c.Get(middleware.UserNameKey)
 
 	RenderHTML(c, http.StatusOK, "assignments/edit.html", gin.H{
-		"title":      "課題編集",
-		"assignment": assignment,
-		"recurring":  recurring,
-		"isAdmin":    role == "admin",
-		"userName":   name,
+		"title":           "課題編集",
+		"assignment":      assignment,
+		"recurring":       recurring,
+		"items":           items,
+		"progress":        models.ChecklistProgressOf(items),
+		"attachments":     attachments,
+		"usage":           usage,
+		"maxFileSize":     h.attachmentService.MaxFileSize(),
+		"subjects":        subjects,
+		"allTags":         allTags,
+		"attachmentError": attachmentError,
+		"isAdmin":         role == "admin",
+		"userName":        name,
 	})
 }
 
@@ -314,37 +396,46 @@ func (h *AssignmentHandler) Update(c *gin.Context) {
 	subject := c.PostForm("subject")
 	priority := c.PostForm("priority")
 	dueDateStr := c.PostForm("due_date")
+	tagNames := models.ParseTagNames(c.PostForm("tags"))
 
 	if err := validation.ValidateAssignmentInput(title, description, subject, priority); err != nil {
 		c.Redirect(http.StatusFound, "/assignments")
 		return
 	}
+	if err := validation.ValidateTags(tagNames); err != nil {
+		c.Redirect(http.StatusFound, "/assignments")
+		return
+	}
 
 	reminderEnabled := c.PostForm("reminder_enabled") == "on"
 	reminderAtStr := c.PostForm("reminder_at")
 	var reminderAt *time.Time
 	if reminderEnabled && reminderAtStr != "" {
-		if parsed, err := time.ParseInLocation("2006-01-02T15:04", reminderAtStr, time.Local); err == nil {
+		if parsed, err := time.ParseInLocation("2006-01-02T15:04", reminderAtStr, getUserLocation(c)); err == nil {
+			parsed = parsed.UTC()
 			reminderAt = &parsed
 		}
 	}
 	urgentReminderEnabled := c.PostForm("urgent_reminder_enabled") == "on"
 
-	dueDate, err := time.ParseInLocation("2006-01-02T15:04", dueDateStr, time.Local)
+	dueDate, err := time.ParseInLocation("2006-01-02T15:04", dueDateStr, getUserLocation(c))
 	if err != nil {
-		dueDate, err = time.ParseInLocation("2006-01-02", dueDateStr, time.Local)
+		dueDate, err = time.ParseInLocation("2006-01-02", dueDateStr, getUserLocation(c))
 		if err != nil {
 			c.Redirect(http.StatusFound, "/assignments")
 			return
 		}
 		dueDate = dueDate.Add(23*time.Hour + 59*time.Minute)
 	}
+	dueDate = dueDate.UTC()
 
 	_, err = h.assignmentService.Update(userID, uint(id), title, description, subject, priority, dueDate, reminderEnabled, reminderAt, urgentReminderEnabled)
 	if err != nil {
 		c.Redirect(http.StatusFound, "/assignments")
 		return
 	}
+	h.checklistService.SetAutoComplete(userID, uint(id), c.PostForm("checklist_auto_complete") == "on")
+	h.tagService.SetAssignmentTags(userID, uint(id), tagNames)
 
 	c.Redirect(http.StatusFound, "/assignments")
 }
@@ -379,6 +470,174 @@ func (h *AssignmentHandler) Delete(c *gin.Context) {
 	c.Redirect(http.StatusFound, "/assignments")
 }
 
+// checklistRedirect は項目の操作後に課題の編集画面のチェックリストに戻る。
+func checklistRedirect(c *gin.Context) {
+	c.Redirect(http.StatusFound, "/assignments/"+c.Param("id")+"/edit#checklist")
+}
+
+func (h *AssignmentHandler) CreateItem(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	title := c.PostForm("title")
+	if err := validation.ValidateField("title", title, true); err != nil {
+		checklistRedirect(c)
+		return
+	}
+
+	var dueDate *time.Time
+	if v := c.PostForm("due_date"); v != "" {
+		if parsed, err := parseDateString(v, getUserLocation(c)); err == nil {
+			dueDate = &parsed
+		}
+	}
+
+	h.checklistService.Create(userID, uint(id), title, dueDate)
+	checklistRedirect(c)
+}
+
+func (h *AssignmentHandler) UpdateItem(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	itemID, _ := strconv.ParseUint(c.Param("itemId"), 10, 32)
+
+	title := c.PostForm("title")
+	if err := validation.ValidateField("title", title, true); err != nil {
+		checklistRedirect(c)
+		return
+	}
+
+	input := service.UpdateChecklistItemInput{Title: &title, ClearDueDate: true}
+	if v := c.PostForm("due_date"); v != "" {
+		if parsed, err := parseDateString(v, getUserLocation(c)); err == nil {
+			input.DueDate = &parsed
+			input.ClearDueDate = false
+		}
+	}
+
+	h.checklistService.Update(userID, uint(id), uint(itemID), input)
+	checklistRedirect(c)
+}
+
+func (h *AssignmentHandler) ToggleItem(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	itemID, _ := strconv.ParseUint(c.Param("itemId"), 10, 32)
+
+	h.checklistService.Toggle(userID, uint(id), uint(itemID))
+	checklistRedirect(c)
+}
+
+// MoveItem は項目を1つ上 (direction=up) または1つ下 (direction=down) に移動する。
+func (h *AssignmentHandler) MoveItem(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	itemID, _ := strconv.ParseUint(c.Param("itemId"), 10, 32)
+
+	items, err := h.checklistService.List(userID, uint(id))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/assignments")
+		return
+	}
+
+	for i, item := range items {
+		if item.ID != uint(itemID) {
+			continue
+		}
+		position := i + 1
+		if c.PostForm("direction") == "up" {
+			position = i - 1
+		}
+		h.checklistService.Move(userID, uint(id), item.ID, position)
+		break
+	}
+	checklistRedirect(c)
+}
+
+func (h *AssignmentHandler) DeleteItem(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	itemID, _ := strconv.ParseUint(c.Param("itemId"), 10, 32)
+
+	h.checklistService.Delete(userID, uint(id), uint(itemID))
+	checklistRedirect(c)
+}
+
+// attachmentsRedirect は添付ファイルの操作後に課題の編集画面の添付ファイルに戻る。
+func attachmentsRedirect(c *gin.Context) {
+	c.Redirect(http.StatusFound, "/assignments/"+c.Param("id")+"/edit#attachments")
+}
+
+// attachmentErrorMessage はファイルを添付できなかった理由の表示を返す。
+func (h *AssignmentHandler) attachmentErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, service.ErrAttachmentTooLarge):
+		return "ファイルが大きすぎます（1ファイル " + service.FormatFileSize(h.attachmentService.MaxFileSize()) + " まで）"
+	case errors.Is(err, service.ErrAttachmentQuotaExceeded):
+		return "添付ファイルの保存容量の上限を超えるため、添付できません。不要なファイルを削除してください"
+	case errors.Is(err, service.ErrAttachmentTypeNotAllowed):
+		return "この種類のファイルは添付できません"
+	case errors.Is(err, service.ErrAttachmentEmpty):
+		return "空のファイルは添付できません"
+	default:
+		return "ファイルを添付できませんでした"
+	}
+}
+
+func (h *AssignmentHandler) UploadAttachment(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	assignment, err := h.assignmentService.GetByID(userID, uint(id))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/assignments")
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		h.renderEdit(c, assignment, "ファイルを選択してください")
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		h.renderEdit(c, assignment, h.attachmentErrorMessage(err))
+		return
+	}
+	defer f.Close()
+
+	if _, err := h.attachmentService.Upload(userID, assignment.ID, file.Filename, f); err != nil {
+		h.renderEdit(c, assignment, h.attachmentErrorMessage(err))
+		return
+	}
+	attachmentsRedirect(c)
+}
+
+func (h *AssignmentHandler) DownloadAttachment(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	attachmentID, _ := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+
+	attachment, content, err := h.attachmentService.Open(userID, uint(id), uint(attachmentID))
+	if err != nil {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"title":   "ファイルが見つかりません",
+			"message": "添付ファイルが見つかりません。",
+		})
+		return
+	}
+	serveAttachment(c, attachment, content)
+}
+
+func (h *AssignmentHandler) DeleteAttachment(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	attachmentID, _ := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+
+	h.attachmentService.Delete(userID, uint(id), uint(attachmentID))
+	attachmentsRedirect(c)
+}
+
 func (h *AssignmentHandler) Statistics(c *gin.Context) {
 	userID := h.getUserID(c)
 	role, _ := c.Get(middleware.UserRoleKey)
@@ -386,6 +645,7 @@ func (h *AssignmentHandler) Statistics(c *gin.Context) {
 
 	filter := service.StatisticsFilter{
 		Subject:         c.Query("subject"),
+		Tag:             c.Query("tag"),
 		IncludeArchived: c.Query("include_archived") == "true",
 	}
 
@@ -393,14 +653,14 @@ func (h *AssignmentHandler) Statistics(c *gin.Context) {
 	toStr := c.Query("to")
 
 	if fromStr != "" {
-		fromDate, err := time.ParseInLocation("2006-01-02", fromStr, time.Local)
+		fromDate, err := time.ParseInLocation("2006-01-02", fromStr, getUserLocation(c))
 		if err == nil {
 			filter.From = &fromDate
 		}
 	}
 
 	if toStr != "" {
-		toDate, err := time.ParseInLocation("2006-01-02", toStr, time.Local)
+		toDate, err := time.ParseInLocation("2006-01-02", toStr, getUserLocation(c))
 		if err == nil {
 			filter.To = &toDate
 		}
@@ -415,48 +675,50 @@ func (h *AssignmentHandler) Statistics(c *gin.Context) {
 		return
 	}
 
-	subjects, _ := h.assignmentService.GetSubjectsWithArchived(userID, false)
-	archivedSubjects, _ := h.assignmentService.GetArchivedSubjects(userID)
-
-	archivedMap := make(map[string]bool)
-	for _, s := range archivedSubjects {
-		archivedMap[s] = true
-	}
+	subjects, _ := h.subjectService.List(userID, filter.IncludeArchived)
+	tags, _ := h.tagService.List(userID)
 
 	RenderHTML(c, http.StatusOK, "assignments/statistics.html", gin.H{
-		"title":            "統計",
-		"stats":            stats,
-		"subjects":         subjects,
-		"archivedSubjects": archivedMap,
-		"selectedSubject":  filter.Subject,
-		"fromDate":         fromStr,
-		"toDate":           toStr,
-		"includeArchived":  filter.IncludeArchived,
-		"isAdmin":          role == "admin",
-		"userName":         name,
+		"title":           "統計",
+		"stats":           stats,
+		"subjects":        subjects,
+		"selectedSubject": filter.Subject,
+		"tags":            tags,
+		"selectedTag":     filter.Tag,
+		"fromDate":        fromStr,
+		"toDate":          toStr,
+		"includeArchived": filter.IncludeArchived,
+		"isAdmin":         role == "admin",
+		"userName":        name,
 	})
 }
 
 func (h *AssignmentHandler) ArchiveSubject(c *gin.Context) {
-	userID := h.getUserID(c)
-	subject := c.PostForm("subject")
-
-	if subject != "" {
-		h.assignmentService.ArchiveSubject(userID, subject)
-	}
-
+	h.setSubjectArchived(c, true)
 	c.Redirect(http.StatusFound, "/statistics")
 }
 
 func (h *AssignmentHandler) UnarchiveSubject(c *gin.Context) {
-	userID := h.getUserID(c)
-	subject := c.PostForm("subject")
+	h.setSubjectArchived(c, false)
+	c.Redirect(http.StatusFound, "/statistics?include_archived=true")
+}
 
-	if subject != "" {
-		h.assignmentService.UnarchiveSubject(userID, subject)
+func (h *AssignmentHandler) setSubjectArchived(c *gin.Context, archived bool) {
+	userID := h.getUserID(c)
+	subjectID, err := strconv.ParseUint(c.PostForm("subject_id"), 10, 32)
+	if err != nil {
+		return
 	}
 
-	c.Redirect(http.StatusFound, "/statistics?include_archived=true")
+	subject, err := h.subjectService.SetArchived(userID, uint(subjectID), archived)
+	if err != nil {
+		return
+	}
+	action := models.AuditSubjectArchive
+	if !archived {
+		action = models.AuditSubjectUnarchive
+	}
+	h.auditService.Record(auditActor(c), action, service.SubjectAuditTarget(subject), nil)
 }
 
 func (h *AssignmentHandler) StopRecurring(c *gin.Context) {
@@ -519,12 +781,18 @@ func (h *AssignmentHandler) EditRecurring(c *gin.Context) {
 		return
 	}
 
+	subjects, _ := h.subjectService.List(userID, false)
+	allTags, _ := h.tagService.List(userID)
+
 	role, _ := c.Get(middleware.UserRoleKey)
 	name, _ := c.Get(middleware.UserNameKey)
 
 	RenderHTML(c, http.StatusOK, "recurring/edit.html", gin.H{
 		"title":     "繰り返し課題の編集",
 		"recurring": recurring,
+		"subjects":  subjects,
+		"allTags":   allTags,
+		"spec":      service.RecurrenceSpecFor(recurring),
 		"isAdmin":   role == "admin",
 		"userName":  name,
 	})
@@ -545,18 +813,17 @@ func (h *AssignmentHandler) UpdateRecurring(c *gin.Context) {
 	recurrenceType := c.PostForm("recurrence_type")
 	dueTime := c.PostForm("due_time")
 	editBehavior := c.PostForm("edit_behavior")
+	rrule := c.PostForm("rrule")
+	exdates := c.PostForm("exdates")
+	checklist := c.PostForm("checklist")
+	checklistAutoComplete := c.PostForm("checklist_auto_complete") == "on"
 
 	recurrenceInterval := 1
 	if v, err := strconv.Atoi(c.PostForm("recurrence_interval")); err == nil && v > 0 {
 		recurrenceInterval = v
 	}
 
-	var recurrenceWeekday *int
-	if wd := c.PostForm("recurrence_weekday"); wd != "" {
-		if v, err := strconv.Atoi(wd); err == nil && v >= 0 && v <= 6 {
-			recurrenceWeekday = &v
-		}
-	}
+	recurrenceWeekdays, recurrenceOrdinal := parseRecurrenceWeekdays(c, recurrenceType)
 
 	var recurrenceDay *int
 	if d := c.PostForm("recurrence_day"); d != "" {
@@ -575,7 +842,8 @@ func (h *AssignmentHandler) UpdateRecurring(c *gin.Context) {
 
 	var endDate *time.Time
 	if ed := c.PostForm("end_date"); ed != "" {
-		if v, err := time.ParseInLocation("2006-01-02", ed, time.Local); err == nil {
+		if v, err := time.ParseInLocation("2006-01-02", ed, getUserLocation(c)); err == nil {
+			v = v.UTC()
 			endDate = &v
 		}
 	}
@@ -587,16 +855,44 @@ func (h *AssignmentHandler) UpdateRecurring(c *gin.Context) {
 		Priority:           &priority,
 		RecurrenceType:     &recurrenceType,
 		RecurrenceInterval: &recurrenceInterval,
-		RecurrenceWeekday:  recurrenceWeekday,
+		RecurrenceWeekdays: recurrenceWeekdays,
 		RecurrenceDay:      recurrenceDay,
+		RecurrenceOrdinal:  recurrenceOrdinal,
+		RRule:              &rrule,
+		ExDates:            &exdates,
 		DueTime:            &dueTime,
 		EndType:            &endType,
 		EndCount:           endCount,
 		EndDate:            endDate,
 		EditBehavior:       editBehavior,
 	}
+	if validation.ValidateField("checklist", checklist, false) == nil {
+		input.Checklist = &checklist
+		input.ChecklistAutoComplete = &checklistAutoComplete
+	}
+	if tagNames := models.ParseTagNames(c.PostForm("tags")); validation.ValidateTags(tagNames) == nil {
+		input.Tags = &tagNames
+	}
 
 	_, err = h.recurringService.Update(userID, uint(id), input)
+	if errors.Is(err, service.ErrInvalidRecurrenceRule) || errors.Is(err, service.ErrInvalidExDates) {
+		recurring, findErr := h.recurringService.GetByID(userID, uint(id))
+		if findErr != nil {
+			c.Redirect(http.StatusFound, "/assignments")
+			return
+		}
+		role, _ := c.Get(middleware.UserRoleKey)
+		name, _ := c.Get(middleware.UserNameKey)
+		RenderHTML(c, http.StatusOK, "recurring/edit.html", gin.H{
+			"title":     "繰り返し課題の編集",
+			"error":     "繰り返し条件が正しくありません: " + err.Error(),
+			"recurring": recurring,
+			"spec":      service.RecurrenceSpecFor(recurring),
+			"isAdmin":   role == "admin",
+			"userName":  name,
+		})
+		return
+	}
 	if err != nil {
 		c.Redirect(http.StatusFound, "/recurring/"+c.Param("id")+"/edit")
 		return
@@ -617,3 +913,146 @@ func (h *AssignmentHandler) DeleteRecurring(c *gin.Context) {
 
 	c.Redirect(http.StatusFound, "/assignments")
 }
+
+func (h *AssignmentHandler) ShowImport(c *gin.Context) {
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+
+	RenderHTML(c, http.StatusOK, "assignments/import.html", gin.H{
+		"title":    "カレンダーから取り込み",
+		"isAdmin":  role == "admin",
+		"userName": name,
+	})
+}
+
+// PreviewImport はアップロードされた .ics を保存せずに解析し、取り込み内容を確認画面に表示する。
+func (h *AssignmentHandler) PreviewImport(c *gin.Context) {
+	userID := h.getUserID(c)
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+
+	renderError := func(msg string) {
+		RenderHTML(c, http.StatusOK, "assignments/import.html", gin.H{
+			"title":    "カレンダーから取り込み",
+			"error":    msg,
+			"isAdmin":  role == "admin",
+			"userName": name,
+		})
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		renderError("ファイルを選択してください")
+		return
+	}
+	if file.Size > service.MaxICalImportSize {
+		renderError("ファイルサイズが大きすぎます（2MBまで）")
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		renderError("ファイルの読み込みに失敗しました")
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, service.MaxICalImportSize+1))
+	if err != nil {
+		renderError("ファイルの読み込みに失敗しました")
+		return
+	}
+
+	result, err := h.calendarService.ImportICal(userID, data, true)
+	if err != nil {
+		renderError(importErrorMessage(err))
+		return
+	}
+
+	RenderHTML(c, http.StatusOK, "assignments/import.html", gin.H{
+		"title":    "カレンダーから取り込み",
+		"preview":  result,
+		"icsData":  string(data),
+		"fileName": file.Filename,
+		"isAdmin":  role == "admin",
+		"userName": name,
+	})
+}
+
+func (h *AssignmentHandler) Import(c *gin.Context) {
+	userID := h.getUserID(c)
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+
+	result, err := h.calendarService.ImportICal(userID, []byte(c.PostForm("ics_data")), false)
+	if err != nil {
+		RenderHTML(c, http.StatusOK, "assignments/import.html", gin.H{
+			"title":    "カレンダーから取り込み",
+			"error":    importErrorMessage(err),
+			"isAdmin":  role == "admin",
+			"userName": name,
+		})
+		return
+	}
+	auditICalImport(c, h.auditService, userID, result)
+
+	RenderHTML(c, http.StatusOK, "assignments/import.html", gin.H{
+		"title":    "カレンダーから取り込み",
+		"result":   result,
+		"isAdmin":  role == "admin",
+		"userName": name,
+	})
+}
+
+// auditICalImport は iCalendar からの課題の取り込みを監査ログに残す。dry run は記録しない。
+func auditICalImport(c *gin.Context, auditService *service.AuditService, userID uint, result *service.ICalImportResult) {
+	if result.DryRun {
+		return
+	}
+	auditService.Record(auditActor(c), models.AuditAssignmentImport, service.AuditTarget{
+		Type: models.AuditTargetUser,
+		ID:   strconv.FormatUint(uint64(userID), 10),
+	}, map[string]interface{}{
+		"created":   result.Created,
+		"updated":   result.Updated,
+		"unchanged": result.Unchanged,
+		"skipped":   result.Skipped,
+	})
+}
+
+func importErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, service.ErrImportTooLarge):
+		return "ファイルサイズが大きすぎます（2MBまで）"
+	case errors.Is(err, ical.ErrInvalidCalendar):
+		return "iCalendar (.ics) ファイルとして読み込めませんでした"
+	default:
+		return "取り込みに失敗しました"
+	}
+}
+
+// parseRecurrenceWeekdays は繰り返しフォームの曜日指定を読み取る。
+// 毎月の「第N曜日」指定では序数も返す（日付指定の場合は 0）。
+func parseRecurrenceWeekdays(c *gin.Context, recurrenceType string) ([]int, *int) {
+	ordinal := 0
+	if recurrenceType == models.RecurrenceMonthly && c.PostForm("monthly_mode") == "weekday" {
+		if v, err := strconv.Atoi(c.PostForm("recurrence_ordinal")); err == nil {
+			ordinal = v
+		}
+		wd, err := strconv.Atoi(c.PostForm("monthly_weekday"))
+		switch {
+		case err != nil:
+			return []int{}, &ordinal
+		case wd == 7: // 平日
+			return []int{1, 2, 3, 4, 5}, &ordinal
+		default:
+			return []int{wd}, &ordinal
+		}
+	}
+
+	weekdays := []int{}
+	for _, wd := range c.PostFormArray("recurrence_weekday") {
+		if v, err := strconv.Atoi(wd); err == nil && v >= 0 && v <= 6 {
+			weekdays = append(weekdays, v)
+		}
+	}
+	return weekdays, &ordinal
+}
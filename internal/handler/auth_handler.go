@@ -1,32 +1,56 @@
 package handler
 
 import (
+	"errors"
+	"log"
 	"net/http"
 
 	"homework-manager/internal/config"
 	"homework-manager/internal/middleware"
+	"homework-manager/internal/models"
 	"homework-manager/internal/service"
+	"homework-manager/internal/sessionstore"
+	"homework-manager/internal/webauthn"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 const twoFAPendingKey = "2fa_pending_user_id"
 
+// webauthnChallengeKey はパスキーでの認証中のチャレンジ。検証に使ったら削除する。
+const webauthnChallengeKey = "webauthn_challenge"
+
+// OIDC の認可リクエストで生成し、コールバックで照合する値
+const (
+	oidcStateKey    = "oidc_state"
+	oidcNonceKey    = "oidc_nonce"
+	oidcVerifierKey = "oidc_verifier"
+)
+
 type AuthHandler struct {
-	authService    *service.AuthService
-	totpService    *service.TOTPService
-	captchaService *service.CaptchaService
-	captchaCfg     config.CaptchaConfig
+	authService         *service.AuthService
+	recoveryCodeService *service.RecoveryCodeService
+	captchaService      *service.CaptchaService
+	oidcService         *service.OIDCService
+	webauthnService     *service.WebAuthnService
+	accountMail         *service.AccountMailService
+	loginGuard          *service.LoginGuardService
+	captchaCfg          config.CaptchaConfig
 }
 
-func NewAuthHandler(captchaCfg config.CaptchaConfig) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, sessions sessionstore.Backend, captchaCfg config.CaptchaConfig, oidcCfg config.OIDCConfig, webauthnCfg config.WebAuthnConfig, accountMail *service.AccountMailService, loginGuard *service.LoginGuardService) *AuthHandler {
 	captchaSvc := service.NewCaptchaService(captchaCfg.Type, captchaCfg.TurnstileSecretKey)
 	return &AuthHandler{
-		authService:    service.NewAuthService(),
-		totpService:    service.NewTOTPService(),
-		captchaService: captchaSvc,
-		captchaCfg:     captchaCfg,
+		authService:         service.NewAuthService(db, sessions),
+		recoveryCodeService: service.NewRecoveryCodeService(db),
+		captchaService:      captchaSvc,
+		oidcService:         service.NewOIDCService(db, oidcCfg),
+		webauthnService:     service.NewWebAuthnService(db, webauthnCfg),
+		accountMail:         accountMail,
+		loginGuard:          loginGuard,
+		captchaCfg:          captchaCfg,
 	}
 }
 
@@ -44,6 +68,36 @@ func (h *AuthHandler) captchaData() gin.H {
 	return data
 }
 
+// loginData はログイン画面の共通データを追加する。CAPTCHA は不審な試行（アカウントまたは IP での連続失敗）があった場合だけ表示する。
+func (h *AuthHandler) loginData(c *gin.Context, data gin.H) gin.H {
+	email, _ := data["email"].(string)
+	if h.loginGuard.CaptchaRequired(email, c.ClientIP()) {
+		for k, v := range h.captchaData() {
+			data[k] = v
+		}
+	}
+	data["oidcEnabled"] = h.oidcService.Enabled()
+	data["oidcProviderName"] = h.oidcService.ProviderName()
+	data["passkeyEnabled"] = h.webauthnService.PasswordlessEnabled()
+	data["passwordResetEnabled"] = h.accountMail.Enabled()
+	return data
+}
+
+// requiresSecondFactor は TOTP かパスキーのどちらかが設定されていれば true を返す。
+func (h *AuthHandler) requiresSecondFactor(user *models.User) bool {
+	return user.TOTPEnabled || h.webauthnService.HasCredentials(user.ID)
+}
+
+func loginMeta(c *gin.Context) service.LoginMeta {
+	return service.LoginMeta{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
+
+func setLoginSession(session sessions.Session, user *models.User) {
+	session.Set(middleware.UserIDKey, user.ID)
+	session.Set(middleware.UserRoleKey, user.Role)
+	session.Set(middleware.UserNameKey, user.Name)
+}
+
 func (h *AuthHandler) verifyCaptcha(c *gin.Context) bool {
 	if !h.captchaCfg.Enabled {
 		return true
@@ -62,11 +116,7 @@ func (h *AuthHandler) verifyCaptcha(c *gin.Context) bool {
 }
 
 func (h *AuthHandler) ShowLogin(c *gin.Context) {
-	data := gin.H{"title": "ログイン"}
-	for k, v := range h.captchaData() {
-		data[k] = v
-	}
-	RenderHTML(c, http.StatusOK, "login.html", data)
+	RenderHTML(c, http.StatusOK, "login.html", h.loginData(c, gin.H{"title": "ログイン"}))
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -74,29 +124,44 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	password := c.PostForm("password")
 
 	renderLoginError := func(msg string) {
-		data := gin.H{
+		RenderHTML(c, http.StatusOK, "login.html", h.loginData(c, gin.H{
 			"title": "ログイン",
 			"error": msg,
 			"email": email,
-		}
-		for k, v := range h.captchaData() {
-			data[k] = v
-		}
-		RenderHTML(c, http.StatusOK, "login.html", data)
+		}))
 	}
 
-	if !h.verifyCaptcha(c) {
-		renderLoginError("CAPTCHAの検証に失敗しました。もう一度お試しください")
+	meta := loginMeta(c)
+	if h.loginGuard.CaptchaRequired(email, meta.IP) && !h.verifyCaptcha(c) {
+		h.loginGuard.RecordCaptchaFailure(email, meta)
+		if c.PostForm("captcha_id") == "" && c.PostForm("cf-turnstile-response") == "" {
+			renderLoginError("ログインの失敗が続いているため、確認のためCAPTCHAを入力してください")
+		} else {
+			renderLoginError("CAPTCHAの検証に失敗しました。もう一度お試しください")
+		}
 		return
 	}
 
-	user, err := h.authService.Login(email, password)
+	user, err := h.loginGuard.Authenticate(email, password, meta)
 	if err != nil {
+		if msg := service.BlockedMessage(err); msg != "" {
+			renderLoginError(msg)
+			return
+		}
 		renderLoginError("メールアドレスまたはパスワードが正しくありません")
 		return
 	}
+	if err := h.accountMail.CanLogin(user); err != nil {
+		RenderHTML(c, http.StatusOK, "login.html", h.loginData(c, gin.H{
+			"title":           "ログイン",
+			"error":           "メールアドレスの確認が完了していません。登録時に届いたメールのリンクを開いてください",
+			"email":           email,
+			"unverifiedEmail": user.Email,
+		}))
+		return
+	}
 
-	if user.TOTPEnabled {
+	if h.requiresSecondFactor(user) {
 		session := sessions.Default(c)
 		session.Set(twoFAPendingKey, user.ID)
 		session.Save()
@@ -104,61 +169,273 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	h.loginGuard.RecordSuccess(user, service.LoginMethodPassword, meta)
 	session := sessions.Default(c)
-	session.Set(middleware.UserIDKey, user.ID)
-	session.Set(middleware.UserRoleKey, user.Role)
-	session.Set(middleware.UserNameKey, user.Name)
+	setLoginSession(session, user)
 	session.Save()
 
 	c.Redirect(http.StatusFound, "/")
 }
 
-func (h *AuthHandler) ShowLogin2FA(c *gin.Context) {
-	session := sessions.Default(c)
-	if session.Get(twoFAPendingKey) == nil {
-		c.Redirect(http.StatusFound, "/login")
+// OIDCLogin は state、nonce、PKCE の code_verifier をセッションに保存して OpenID プロバイダーへリダイレクトする。
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	req, err := h.oidcService.BeginLogin()
+	if err != nil {
+		log.Printf("OIDC login could not be started: %v", err)
+		RenderHTML(c, http.StatusOK, "login.html", h.loginData(c, gin.H{
+			"title": "ログイン",
+			"error": "シングルサインオンを開始できませんでした。しばらくしてからもう一度お試しください",
+		}))
 		return
 	}
-	RenderHTML(c, http.StatusOK, "login_2fa.html", gin.H{
-		"title": "2段階認証",
-	})
+
+	session := sessions.Default(c)
+	session.Set(oidcStateKey, req.State)
+	session.Set(oidcNonceKey, req.Nonce)
+	session.Set(oidcVerifierKey, req.Verifier)
+	session.Save()
+
+	c.Redirect(http.StatusFound, req.URL)
 }
 
-func (h *AuthHandler) Login2FA(c *gin.Context) {
+// OIDCCallback は認可コードを検証してログインする。2段階認証（TOTP またはパスキー）が有効なアカウントは /login/2fa に進む。
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
 	session := sessions.Default(c)
-	pendingID := session.Get(twoFAPendingKey)
-	if pendingID == nil {
-		c.Redirect(http.StatusFound, "/login")
+	state, _ := session.Get(oidcStateKey).(string)
+	nonce, _ := session.Get(oidcNonceKey).(string)
+	verifier, _ := session.Get(oidcVerifierKey).(string)
+	session.Delete(oidcStateKey)
+	session.Delete(oidcNonceKey)
+	session.Delete(oidcVerifierKey)
+	session.Save()
+
+	renderLoginError := func(msg string) {
+		RenderHTML(c, http.StatusOK, "login.html", h.loginData(c, gin.H{
+			"title": "ログイン",
+			"error": msg,
+		}))
+	}
+
+	if c.Query("error") != "" {
+		renderLoginError("シングルサインオンがキャンセルされたか、拒否されました")
+		return
+	}
+	if state == "" || c.Query("state") != state || c.Query("code") == "" {
+		renderLoginError("シングルサインオンの要求が無効か期限切れです。もう一度お試しください")
+		return
+	}
+
+	user, err := h.oidcService.CompleteLogin(c.Query("code"), verifier, nonce)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOIDCEmailNotVerified):
+			renderLoginError("このメールアドレスのアカウントは既に存在しますが、メールアドレスが認証プロバイダーで確認されていないため連携できません")
+		case errors.Is(err, service.ErrOIDCAccountConflict):
+			renderLoginError("このメールアドレスのアカウントは既に別のシングルサインオンアカウントと連携されています")
+		case errors.Is(err, service.ErrOIDCAccountNotFound):
+			renderLoginError("このアカウントではログインできません。管理者にお問い合わせください")
+		case errors.Is(err, service.ErrOIDCMissingEmail):
+			renderLoginError("認証プロバイダーからメールアドレスを取得できませんでした")
+		default:
+			log.Printf("OIDC login failed: %v", err)
+			renderLoginError("シングルサインオンに失敗しました。もう一度お試しください")
+		}
 		return
 	}
+	if err := h.accountMail.CanLogin(user); err != nil {
+		renderLoginError("メールアドレスが認証プロバイダーで確認されていないため、ログインできません")
+		return
+	}
+
+	if h.requiresSecondFactor(user) {
+		session.Set(twoFAPendingKey, user.ID)
+		session.Save()
+		c.Redirect(http.StatusFound, "/login/2fa")
+		return
+	}
+
+	h.loginGuard.RecordSuccess(user, service.LoginMethodOIDC, loginMeta(c))
+	setLoginSession(session, user)
+	session.Save()
 
-	userID := pendingID.(uint)
-	user, err := h.authService.GetUserByID(userID)
+	c.Redirect(http.StatusFound, "/")
+}
+
+// pendingUser はパスワード（またはシングルサインオン）での認証を終えて 2 段階認証待ちのユーザーを返す。
+func (h *AuthHandler) pendingUser(session sessions.Session) (*models.User, bool) {
+	pendingID, ok := session.Get(twoFAPendingKey).(uint)
+	if !ok {
+		return nil, false
+	}
+	user, err := h.authService.GetUserByID(pendingID)
 	if err != nil {
 		session.Delete(twoFAPendingKey)
 		session.Save()
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *AuthHandler) render2FA(c *gin.Context, user *models.User, errMsg string) {
+	data := gin.H{
+		"title":           "2段階認証",
+		"totpEnabled":     user.TOTPEnabled,
+		"webauthnEnabled": h.webauthnService.HasCredentials(user.ID),
+		"recoveryOpen":    c.PostForm("recovery_code") != "",
+	}
+	if errMsg != "" {
+		data["error"] = errMsg
+	}
+	RenderHTML(c, http.StatusOK, "login_2fa.html", data)
+}
+
+func (h *AuthHandler) ShowLogin2FA(c *gin.Context) {
+	user, ok := h.pendingUser(sessions.Default(c))
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	h.render2FA(c, user, "")
+}
+
+func (h *AuthHandler) Login2FA(c *gin.Context) {
+	session := sessions.Default(c)
+	user, ok := h.pendingUser(session)
+	if !ok {
 		c.Redirect(http.StatusFound, "/login")
 		return
 	}
 
-	code := c.PostForm("totp_code")
-	if !h.totpService.Validate(user.TOTPSecret, code) {
-		RenderHTML(c, http.StatusOK, "login_2fa.html", gin.H{
-			"title": "2段階認証",
-			"error": "認証コードが正しくありません",
-		})
+	method := service.LoginMethodTOTP
+	recoveryCode := c.PostForm("recovery_code")
+	if recoveryCode != "" {
+		method = service.LoginMethodRecoveryCode
+	}
+	meta := loginMeta(c)
+	// 認証コードの総当たりもパスワードと同じく連続失敗回数に数えてロックする
+	if err := h.loginGuard.CheckSecondFactor(user, method, meta); err != nil {
+		h.render2FA(c, user, service.BlockedMessage(err))
+		return
+	}
+
+	// 認証アプリを使えない場合はリカバリーコードで代用できる
+	if recoveryCode != "" {
+		if !user.TOTPEnabled || !h.recoveryCodeService.Use(user.ID, recoveryCode) {
+			h.loginGuard.RecordFailure(user, method, meta)
+			h.render2FA(c, user, "リカバリーコードが正しくないか、既に使用されています")
+			return
+		}
+	} else if !h.authService.VerifyTOTP(user, c.PostForm("totp_code")) {
+		h.loginGuard.RecordFailure(user, method, meta)
+		h.render2FA(c, user, "認証コードが正しくありません")
 		return
 	}
 
+	h.loginGuard.RecordSuccess(user, method, meta)
 	session.Delete(twoFAPendingKey)
-	session.Set(middleware.UserIDKey, user.ID)
-	session.Set(middleware.UserRoleKey, user.Role)
-	session.Set(middleware.UserNameKey, user.Name)
+	setLoginSession(session, user)
 	session.Save()
 
 	c.Redirect(http.StatusFound, "/")
 }
 
+// WebAuthn2FABegin は 2段階認証に使うパスキーのオプションを返す。
+func (h *AuthHandler) WebAuthn2FABegin(c *gin.Context) {
+	session := sessions.Default(c)
+	user, ok := h.pendingUser(session)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "ログインからやり直してください"})
+		return
+	}
+
+	options, challenge, err := h.webauthnService.BeginLogin(user.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "パスキーが登録されていません"})
+		return
+	}
+	session.Set(webauthnChallengeKey, challenge)
+	session.Save()
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// WebAuthn2FAFinish はパスキーでの 2段階認証を検証してログインを完了する。
+func (h *AuthHandler) WebAuthn2FAFinish(c *gin.Context) {
+	session := sessions.Default(c)
+	user, ok := h.pendingUser(session)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "ログインからやり直してください"})
+		return
+	}
+	challenge, _ := session.Get(webauthnChallengeKey).(string)
+	session.Delete(webauthnChallengeKey)
+	session.Save()
+
+	var resp webauthn.AssertionResponse
+	if err := c.ShouldBindJSON(&resp); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不正なリクエストです"})
+		return
+	}
+	if err := h.webauthnService.FinishLogin(user.ID, &resp, challenge); err != nil {
+		log.Printf("WebAuthn second factor failed for user %d: %v", user.ID, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "パスキーでの認証に失敗しました"})
+		return
+	}
+
+	h.loginGuard.RecordSuccess(user, service.LoginMethodPasskey, loginMeta(c))
+	session.Delete(twoFAPendingKey)
+	setLoginSession(session, user)
+	session.Save()
+
+	c.JSON(http.StatusOK, gin.H{"redirect": "/"})
+}
+
+// PasskeyLoginBegin はパスワードなしでログインするためのオプションを返す。
+func (h *AuthHandler) PasskeyLoginBegin(c *gin.Context) {
+	options, challenge, err := h.webauthnService.BeginPasswordlessLogin()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "パスキーでのログインは利用できません"})
+		return
+	}
+	session := sessions.Default(c)
+	session.Set(webauthnChallengeKey, challenge)
+	session.Save()
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// PasskeyLoginFinish はパスキーでのログインを検証する。認証器で本人確認済みのため、2段階認証は求めない。
+func (h *AuthHandler) PasskeyLoginFinish(c *gin.Context) {
+	session := sessions.Default(c)
+	challenge, _ := session.Get(webauthnChallengeKey).(string)
+	session.Delete(webauthnChallengeKey)
+	session.Save()
+
+	var resp webauthn.AssertionResponse
+	if err := c.ShouldBindJSON(&resp); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不正なリクエストです"})
+		return
+	}
+	user, err := h.webauthnService.FinishPasswordlessLogin(&resp, challenge)
+	if err != nil {
+		if errors.Is(err, service.ErrWebAuthnVerification) {
+			log.Printf("Passkey login failed: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "パスキーでのログインに失敗しました。登録済みのパスキーを選択してください"})
+		return
+	}
+	if err := h.accountMail.CanLogin(user); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "メールアドレスの確認が完了していません"})
+		return
+	}
+
+	h.loginGuard.RecordSuccess(user, service.LoginMethodPasskey, loginMeta(c))
+	setLoginSession(session, user)
+	session.Save()
+
+	c.JSON(http.StatusOK, gin.H{"redirect": "/"})
+}
+
 func (h *AuthHandler) ShowRegister(c *gin.Context) {
 	data := gin.H{"title": "新規登録"}
 	for k, v := range h.captchaData() {
@@ -203,6 +480,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	user, err := h.authService.Register(email, password, name)
 	if err != nil {
+		// 確認を必須にしている場合は、登録済みのメールアドレスかどうかを画面から推測できないよう登録完了と同じ表示にする
+		if err == service.ErrEmailAlreadyExists && h.accountMail.VerificationRequired() {
+			h.renderVerificationSent(c, email)
+			return
+		}
 		errorMsg := "登録に失敗しました"
 		if err == service.ErrEmailAlreadyExists {
 			errorMsg = "このメールアドレスは既に使用されています"
@@ -211,15 +493,33 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	if h.accountMail.Enabled() {
+		if err := h.accountMail.SendVerification(user); err != nil {
+			log.Printf("Verification mail could not be sent: %v", err)
+		}
+	}
+	if h.accountMail.VerificationRequired() {
+		h.renderVerificationSent(c, user.Email)
+		return
+	}
+
 	session := sessions.Default(c)
-	session.Set(middleware.UserIDKey, user.ID)
-	session.Set(middleware.UserRoleKey, user.Role)
-	session.Set(middleware.UserNameKey, user.Name)
+	setLoginSession(session, user)
 	session.Save()
 
 	c.Redirect(http.StatusFound, "/")
 }
 
+// renderVerificationSent は確認メールを送ったことを表示する。確認が済むまでログインはさせない。
+func (h *AuthHandler) renderVerificationSent(c *gin.Context, email string) {
+	RenderHTML(c, http.StatusOK, "verify_email.html", gin.H{
+		"title":   "メールアドレスの確認",
+		"sent":    true,
+		"email":   email,
+		"success": "確認メールを送信しました。メールのリンクを開いて登録を完了してください。",
+	})
+}
+
 func (h *AuthHandler) Logout(c *gin.Context) {
 	session := sessions.Default(c)
 	session.Clear()
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"homework-manager/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CalendarHandler struct {
+	calendarService *service.CalendarService
+}
+
+func NewCalendarHandler(db *gorm.DB) *CalendarHandler {
+	return &CalendarHandler{
+		calendarService: service.NewCalendarService(db),
+	}
+}
+
+// Feed は GET /calendar/<token>.ics を返す。?type=todo で VTODO 形式になる。
+func (h *CalendarHandler) Feed(c *gin.Context) {
+	file := c.Param("file")
+	token := strings.TrimSuffix(file, ".ics")
+	if token == file {
+		c.String(http.StatusNotFound, "Not Found")
+		return
+	}
+
+	user, err := h.calendarService.FindUserByToken(token)
+	if err != nil {
+		c.String(http.StatusNotFound, "Not Found")
+		return
+	}
+
+	cal, err := h.calendarService.BuildFeed(user, c.Query("type") == "todo")
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to build calendar")
+		return
+	}
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Content-Disposition", `inline; filename="homework.ics"`)
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Status(http.StatusOK)
+	cal.Encode(c.Writer)
+}
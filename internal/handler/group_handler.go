@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"homework-manager/internal/middleware"
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+	"homework-manager/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type GroupHandler struct {
+	groupService   *service.GroupService
+	subjectService *service.SubjectService
+}
+
+func NewGroupHandler(db *gorm.DB) *GroupHandler {
+	return &GroupHandler{
+		groupService:   service.NewGroupService(db),
+		subjectService: service.NewSubjectService(db),
+	}
+}
+
+func (h *GroupHandler) getUserID(c *gin.Context) uint {
+	userID, _ := c.Get(middleware.UserIDKey)
+	return userID.(uint)
+}
+
+func groupErrorMessage(err error) string {
+	var validationErr *validation.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return err.Error()
+	case errors.Is(err, service.ErrNotTeacher):
+		return "この操作は教師だけができます"
+	case errors.Is(err, service.ErrInvalidJoinCode):
+		return "参加コードが正しくありません"
+	case errors.Is(err, service.ErrAlreadyMember):
+		return "すでにこのクラスに参加しています"
+	case errors.Is(err, service.ErrCannotPromote):
+		return "教師にできるのは教師・管理者のアカウントだけです"
+	case errors.Is(err, service.ErrLastTeacher):
+		return "最後の教師はクラスから抜けられません。クラスを削除してください"
+	case errors.Is(err, service.ErrGroupNotFound):
+		return "クラスが見つかりません"
+	case errors.Is(err, service.ErrGroupAssignmentNotFound):
+		return "課題が見つかりません"
+	default:
+		return "クラスの更新に失敗しました"
+	}
+}
+
+// groupAssignmentInputFromForm はフォームの入力を GroupAssignmentInput にする。期限が日付だけの場合はその日の 23:59 にする。
+func groupAssignmentInputFromForm(c *gin.Context) (service.GroupAssignmentInput, bool) {
+	input := service.GroupAssignmentInput{
+		Title:       c.PostForm("title"),
+		Description: c.PostForm("description"),
+		Subject:     c.PostForm("subject"),
+		Priority:    c.PostForm("priority"),
+	}
+	dueDate, err := parseDateString(c.PostForm("due_date"), getUserLocation(c))
+	if err != nil {
+		return input, false
+	}
+	input.DueDate = dueDate
+	return input, true
+}
+
+func (h *GroupHandler) render(c *gin.Context, name string, data gin.H) {
+	role, _ := c.Get(middleware.UserRoleKey)
+	userName, _ := c.Get(middleware.UserNameKey)
+	data["isAdmin"] = role == "admin"
+	data["canCreateGroups"] = role == "admin" || role == "teacher"
+	data["userName"] = userName
+	RenderHTML(c, http.StatusOK, name, data)
+}
+
+func (h *GroupHandler) renderIndex(c *gin.Context, data gin.H) {
+	memberships, _ := h.groupService.ListForUser(h.getUserID(c))
+	data["title"] = "クラス"
+	data["memberships"] = memberships
+	h.render(c, "groups/index.html", data)
+}
+
+func (h *GroupHandler) Index(c *gin.Context) {
+	h.renderIndex(c, gin.H{})
+}
+
+func (h *GroupHandler) Create(c *gin.Context) {
+	group, err := h.groupService.Create(h.getUserID(c), c.PostForm("name"), c.PostForm("description"))
+	if err != nil {
+		h.renderIndex(c, gin.H{"error": groupErrorMessage(err)})
+		return
+	}
+	c.Redirect(http.StatusFound, "/groups/"+strconv.FormatUint(uint64(group.ID), 10))
+}
+
+func (h *GroupHandler) Join(c *gin.Context) {
+	membership, err := h.groupService.Join(h.getUserID(c), c.PostForm("code"))
+	if err != nil {
+		h.renderIndex(c, gin.H{"error": groupErrorMessage(err)})
+		return
+	}
+	if membership.IsTeacher() {
+		c.Redirect(http.StatusFound, "/groups/"+strconv.FormatUint(uint64(membership.GroupID), 10))
+		return
+	}
+	c.Redirect(http.StatusFound, "/groups")
+}
+
+func (h *GroupHandler) parseID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/groups")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func groupPath(groupID uint) string {
+	return "/groups/" + strconv.FormatUint(uint64(groupID), 10)
+}
+
+// renderShow はクラスの画面（参加コード・メンバー・配布した課題）を表示する。教師だけが見られる。
+func (h *GroupHandler) renderShow(c *gin.Context, groupID uint, data gin.H) {
+	userID := h.getUserID(c)
+	group, membership, err := h.groupService.Get(userID, groupID)
+	if err != nil || !membership.IsTeacher() {
+		c.Redirect(http.StatusFound, "/groups")
+		return
+	}
+	members, _ := h.groupService.Members(userID, groupID)
+	assignments, _ := h.groupService.ListAssignments(userID, groupID)
+	subjects, _ := h.subjectService.List(userID, false)
+
+	now := time.Now().In(getUserLocation(c))
+	defaultDue := time.Date(now.Year(), now.Month(), now.Day()+7, 23, 59, 0, 0, now.Location())
+
+	data["title"] = "クラス: " + group.Name
+	data["group"] = group
+	data["members"] = members
+	data["assignments"] = assignments
+	data["subjects"] = subjects
+	data["defaultDue"] = defaultDue
+	data["currentUserID"] = userID
+	h.render(c, "groups/show.html", data)
+}
+
+func (h *GroupHandler) Show(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id")
+	if !ok {
+		return
+	}
+	h.renderShow(c, groupID, gin.H{})
+}
+
+func (h *GroupHandler) Leave(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.groupService.Leave(h.getUserID(c), groupID); err != nil {
+		h.renderIndex(c, gin.H{"error": groupErrorMessage(err)})
+		return
+	}
+	c.Redirect(http.StatusFound, "/groups")
+}
+
+func (h *GroupHandler) RegenerateCode(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id")
+	if !ok {
+		return
+	}
+	h.groupService.RegenerateJoinCode(h.getUserID(c), groupID)
+	c.Redirect(http.StatusFound, groupPath(groupID))
+}
+
+func (h *GroupHandler) RemoveMember(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id")
+	if !ok {
+		return
+	}
+	memberID, ok := h.parseID(c, "userId")
+	if !ok {
+		return
+	}
+	if err := h.groupService.RemoveMember(h.getUserID(c), groupID, memberID); err != nil {
+		h.renderShow(c, groupID, gin.H{"error": groupErrorMessage(err)})
+		return
+	}
+	c.Redirect(http.StatusFound, groupPath(groupID))
+}
+
+func (h *GroupHandler) PromoteMember(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id")
+	if !ok {
+		return
+	}
+	memberID, ok := h.parseID(c, "userId")
+	if !ok {
+		return
+	}
+	if err := h.groupService.PromoteMember(h.getUserID(c), groupID, memberID); err != nil {
+		h.renderShow(c, groupID, gin.H{"error": groupErrorMessage(err)})
+		return
+	}
+	c.Redirect(http.StatusFound, groupPath(groupID))
+}
+
+func (h *GroupHandler) Delete(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id")
+	if !ok {
+		return
+	}
+	h.groupService.Delete(h.getUserID(c), groupID)
+	c.Redirect(http.StatusFound, "/groups")
+}
+
+func (h *GroupHandler) CreateAssignment(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id")
+	if !ok {
+		return
+	}
+	input, ok := groupAssignmentInputFromForm(c)
+	if !ok {
+		h.renderShow(c, groupID, gin.H{"error": "提出期限の形式が正しくありません"})
+		return
+	}
+	if _, err := h.groupService.CreateAssignment(h.getUserID(c), groupID, input); err != nil {
+		h.renderShow(c, groupID, gin.H{"error": groupErrorMessage(err)})
+		return
+	}
+	c.Redirect(http.StatusFound, groupPath(groupID))
+}
+
+// renderAssignment は配布した課題の提出状況と編集フォームを表示する。
+func (h *GroupHandler) renderAssignment(c *gin.Context, groupID, assignmentID uint, data gin.H) {
+	userID := h.getUserID(c)
+	group, _, err := h.groupService.Get(userID, groupID)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/groups")
+		return
+	}
+	progress, err := h.groupService.Progress(userID, groupID, assignmentID)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/groups")
+		return
+	}
+	subjects, _ := h.subjectService.List(userID, false)
+
+	data["title"] = progress.Assignment.Title
+	data["group"] = group
+	data["progress"] = progress
+	data["assignment"] = progress.Assignment
+	data["subjects"] = subjects
+	data["editBehaviors"] = groupEditBehaviors
+	if updated, err := strconv.Atoi(c.Query("updated")); err == nil {
+		data["updated"] = updated
+	}
+	h.render(c, "groups/assignment.html", data)
+}
+
+func (h *GroupHandler) ShowAssignment(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id")
+	if !ok {
+		return
+	}
+	assignmentID, ok := h.parseID(c, "assignmentId")
+	if !ok {
+		return
+	}
+	h.renderAssignment(c, groupID, assignmentID, gin.H{})
+}
+
+func (h *GroupHandler) UpdateAssignment(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id")
+	if !ok {
+		return
+	}
+	assignmentID, ok := h.parseID(c, "assignmentId")
+	if !ok {
+		return
+	}
+	input, ok := groupAssignmentInputFromForm(c)
+	if !ok {
+		h.renderAssignment(c, groupID, assignmentID, gin.H{"error": "提出期限の形式が正しくありません"})
+		return
+	}
+	_, updated, err := h.groupService.UpdateAssignment(h.getUserID(c), groupID, assignmentID, input, c.PostForm("edit_behavior"))
+	if err != nil {
+		h.renderAssignment(c, groupID, assignmentID, gin.H{"error": groupErrorMessage(err)})
+		return
+	}
+	c.Redirect(http.StatusFound, groupPath(groupID)+"/assignments/"+strconv.FormatUint(uint64(assignmentID), 10)+"?updated="+strconv.Itoa(updated))
+}
+
+func (h *GroupHandler) DeleteAssignment(c *gin.Context) {
+	groupID, ok := h.parseID(c, "id")
+	if !ok {
+		return
+	}
+	assignmentID, ok := h.parseID(c, "assignmentId")
+	if !ok {
+		return
+	}
+	h.groupService.DeleteAssignment(h.getUserID(c), groupID, assignmentID)
+	c.Redirect(http.StatusFound, groupPath(groupID))
+}
+
+// groupEditBehaviors は配布した課題の編集を生徒の課題にどこまで反映するかの選択肢。
+var groupEditBehaviors = []struct {
+	Value string
+	Label string
+}{
+	{models.EditBehaviorThisAndFuture, "期限前の未完了の生徒の課題にも反映する"},
+	{models.EditBehaviorAll, "期限切れを含む未完了の生徒の課題すべてに反映する"},
+	{models.EditBehaviorThisOnly, "配布元だけを変更する（これから参加する生徒に反映）"},
+}
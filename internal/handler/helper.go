@@ -3,14 +3,48 @@ package handler
 import (
 	"fmt"
 	"html/template"
+	"net/http"
 	"time"
 
+	"homework-manager/internal/middleware"
+	"homework-manager/internal/service"
+	"homework-manager/internal/timezone"
+
 	"github.com/gin-gonic/gin"
 )
 
 const csrfTokenKey = "csrf_token"
 const csrfTokenFormKey = "_csrf"
 
+// getUserLocation はログイン中のユーザーのタイムゾーンを返す。未ログインの場合はサーバーのタイムゾーン。
+func getUserLocation(c *gin.Context) *time.Location {
+	if loc, exists := c.Get(middleware.UserLocationKey); exists {
+		return loc.(*time.Location)
+	}
+	return time.Local
+}
+
+// auditActor はリクエストの操作者（ログイン中のユーザー）と接続元を返す。
+func auditActor(c *gin.Context) service.AuditActor {
+	actor := service.AuditActor{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	if userID, exists := c.Get(middleware.UserIDKey); exists {
+		id := userID.(uint)
+		actor.UserID = &id
+	}
+	return actor
+}
+
+// RenderJSON は obj を JSON で返す。日時は RFC 3339 でユーザーのタイムゾーンのオフセット付きになる。
+func RenderJSON(c *gin.Context, code int, obj interface{}) {
+	body, err := timezone.MarshalJSON(obj, getUserLocation(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+	c.Data(code, "application/json; charset=utf-8", body)
+}
+
+// RenderHTML はテンプレートを描画する。テンプレートの formatDate などには $.loc（ユーザーのタイムゾーン）を渡して現地時刻で表示する。
 func RenderHTML(c *gin.Context, code int, name string, obj gin.H) {
 	if obj == nil {
 		obj = gin.H{}
@@ -28,6 +62,6 @@ func RenderHTML(c *gin.Context, code int, name string, obj gin.H) {
 		obj["csrfField"] = template.HTML(`<input type="hidden" name="` + csrfTokenFormKey + `" value="` + token.(string) + `">`)
 	}
 
+	obj["loc"] = getUserLocation(c)
 	c.HTML(code, name, obj)
 }
-
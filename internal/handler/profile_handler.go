@@ -1,28 +1,64 @@
 package handler
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"homework-manager/internal/config"
 	"homework-manager/internal/middleware"
 	"homework-manager/internal/models"
 	"homework-manager/internal/service"
+	"homework-manager/internal/sessionstore"
+	"homework-manager/internal/webauthn"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type ProfileHandler struct {
 	authService         *service.AuthService
 	totpService         *service.TOTPService
+	recoveryCodeService *service.RecoveryCodeService
 	notificationService *service.NotificationService
+	outboxService       *service.NotificationOutboxService
+	calendarService     *service.CalendarService
+	dataTransferService *service.DataTransferService
+	apiKeyService       *service.APIKeyService
+	webauthnService     *service.WebAuthnService
+	accountMail         *service.AccountMailService
+	sessionService      *service.SessionService
+	auditService        *service.AuditService
+	telegramBot         *service.TelegramBotService
 	appName             string
 }
 
-func NewProfileHandler(notificationService *service.NotificationService) *ProfileHandler {
+// webauthnRegistrationChallengeKey はパスキー登録中のチャレンジ。ログイン時のチャレンジとは別に保持する。
+const webauthnRegistrationChallengeKey = "webauthn_registration_challenge"
+
+// NewProfileHandler は telegramBot が nil の場合、Telegram の Chat ID を手入力する画面にする。
+func NewProfileHandler(db *gorm.DB, sessions sessionstore.Backend, notificationService *service.NotificationService, telegramBot *service.TelegramBotService, webauthnCfg config.WebAuthnConfig, accountMail *service.AccountMailService) *ProfileHandler {
 	return &ProfileHandler{
-		authService:         service.NewAuthService(),
+		authService:         service.NewAuthService(db, sessions),
 		totpService:         service.NewTOTPService(),
+		recoveryCodeService: service.NewRecoveryCodeService(db),
 		notificationService: notificationService,
+		outboxService:       service.NewNotificationOutboxService(db),
+		calendarService:     service.NewCalendarService(db),
+		dataTransferService: service.NewDataTransferService(db, telegramBot),
+		apiKeyService:       service.NewAPIKeyService(db),
+		webauthnService:     service.NewWebAuthnService(db, webauthnCfg),
+		accountMail:         accountMail,
+		sessionService:      service.NewSessionService(sessions),
+		auditService:        service.NewAuditService(db),
+		telegramBot:         telegramBot,
 		appName:             "Super-HomeworkManager",
 	}
 }
@@ -32,6 +68,33 @@ func (h *ProfileHandler) getUserID(c *gin.Context) uint {
 	return userID.(uint)
 }
 
+// renderProfile はプロフィール画面を描画する。どの操作の結果でも最近の通知履歴を表示する。
+func (h *ProfileHandler) renderProfile(c *gin.Context, data gin.H) {
+	notifications, _ := h.outboxService.GetRecentByUser(h.getUserID(c), 20)
+	apiKeys, _ := h.apiKeyService.GetAPIKeysByUser(h.getUserID(c))
+	data["notifications"] = notifications
+	data["apiKeys"] = apiKeys
+	data["apiKeyScopes"] = models.APIKeyScopes
+	if user, ok := data["user"].(*models.User); ok && user != nil && user.TOTPEnabled {
+		data["recoveryCodesRemaining"] = h.recoveryCodeService.Remaining(user.ID)
+	}
+	if h.webauthnService.Enabled() {
+		credentials, _ := h.webauthnService.GetCredentials(h.getUserID(c))
+		data["webauthnEnabled"] = true
+		data["webauthnCredentials"] = credentials
+	}
+	data["accountMailEnabled"] = h.accountMail.Enabled()
+	activeSessions, _ := h.sessionService.ListForUser(h.getUserID(c), sessions.Default(c).ID())
+	data["activeSessions"] = activeSessions
+	data["timezones"] = service.CommonTimezones
+	data["serverTimezone"] = time.Local.String()
+	if h.telegramBot != nil {
+		data["telegramBot"] = true
+		data["telegramBotUsername"] = h.telegramBot.Username()
+	}
+	RenderHTML(c, http.StatusOK, "profile.html", data)
+}
+
 func (h *ProfileHandler) Show(c *gin.Context) {
 	userID := h.getUserID(c)
 	user, _ := h.authService.GetUserByID(userID)
@@ -40,7 +103,7 @@ func (h *ProfileHandler) Show(c *gin.Context) {
 	role, _ := c.Get(middleware.UserRoleKey)
 	name, _ := c.Get(middleware.UserNameKey)
 
-	RenderHTML(c, http.StatusOK, "profile.html", gin.H{
+	h.renderProfile(c, gin.H{
 		"title":          "プロフィール",
 		"user":           user,
 		"isAdmin":        role == "admin",
@@ -53,17 +116,21 @@ func (h *ProfileHandler) Update(c *gin.Context) {
 	userID := h.getUserID(c)
 	name := c.PostForm("name")
 
-	err := h.authService.UpdateProfile(userID, name)
+	err := h.authService.UpdateProfile(userID, name, strings.TrimSpace(c.PostForm("timezone")))
 
 	role, _ := c.Get(middleware.UserRoleKey)
 	user, _ := h.authService.GetUserByID(userID)
 	notifySettings, _ := h.notificationService.GetUserSettings(userID)
 
 	if err != nil {
-		RenderHTML(c, http.StatusOK, "profile.html", gin.H{
+		errorMessage := "プロフィールの更新に失敗しました"
+		if errors.Is(err, service.ErrInvalidTimezone) {
+			errorMessage = "タイムゾーンは Asia/Tokyo のような IANA タイムゾーン名で入力してください"
+		}
+		h.renderProfile(c, gin.H{
 			"title":          "プロフィール",
 			"user":           user,
-			"error":          "プロフィールの更新に失敗しました",
+			"error":          errorMessage,
 			"isAdmin":        role == "admin",
 			"userName":       name,
 			"notifySettings": notifySettings,
@@ -71,7 +138,9 @@ func (h *ProfileHandler) Update(c *gin.Context) {
 		return
 	}
 
-	RenderHTML(c, http.StatusOK, "profile.html", gin.H{
+	// 変更後のタイムゾーンでこの画面を表示する
+	c.Set(middleware.UserLocationKey, user.Location())
+	h.renderProfile(c, gin.H{
 		"title":          "プロフィール",
 		"user":           user,
 		"success":        "プロフィールを更新しました",
@@ -93,7 +162,7 @@ func (h *ProfileHandler) ChangePassword(c *gin.Context) {
 	notifySettings, _ := h.notificationService.GetUserSettings(userID)
 
 	if newPassword != confirmPassword {
-		RenderHTML(c, http.StatusOK, "profile.html", gin.H{
+		h.renderProfile(c, gin.H{
 			"title":          "プロフィール",
 			"user":           user,
 			"passwordError":  "新しいパスワードが一致しません",
@@ -105,7 +174,7 @@ func (h *ProfileHandler) ChangePassword(c *gin.Context) {
 	}
 
 	if len(newPassword) < 8 {
-		RenderHTML(c, http.StatusOK, "profile.html", gin.H{
+		h.renderProfile(c, gin.H{
 			"title":          "プロフィール",
 			"user":           user,
 			"passwordError":  "パスワードは8文字以上で入力してください",
@@ -116,9 +185,9 @@ func (h *ProfileHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	err := h.authService.ChangePassword(userID, oldPassword, newPassword)
+	err := h.authService.ChangePassword(userID, sessions.Default(c).ID(), oldPassword, newPassword)
 	if err != nil {
-		RenderHTML(c, http.StatusOK, "profile.html", gin.H{
+		h.renderProfile(c, gin.H{
 			"title":          "プロフィール",
 			"user":           user,
 			"passwordError":  "現在のパスワードが正しくありません",
@@ -128,11 +197,12 @@ func (h *ProfileHandler) ChangePassword(c *gin.Context) {
 		})
 		return
 	}
+	h.auditService.Record(auditActor(c), models.AuditPasswordChange, service.UserAuditTarget(user), nil)
 
-	RenderHTML(c, http.StatusOK, "profile.html", gin.H{
+	h.renderProfile(c, gin.H{
 		"title":           "プロフィール",
 		"user":            user,
-		"passwordSuccess": "パスワードを変更しました",
+		"passwordSuccess": "パスワードを変更しました。他の端末からはログアウトしました",
 		"isAdmin":         role == "admin",
 		"userName":        name,
 		"notifySettings":  notifySettings,
@@ -145,18 +215,22 @@ func (h *ProfileHandler) UpdateNotificationSettings(c *gin.Context) {
 	name, _ := c.Get(middleware.UserNameKey)
 	user, _ := h.authService.GetUserByID(userID)
 
-	settings := &models.UserNotificationSettings{
-		TelegramEnabled: c.PostForm("telegram_enabled") == "on",
-		TelegramChatID:  c.PostForm("telegram_chat_id"),
-		NotifyOnCreate:  c.PostForm("notify_on_create") == "on",
+	settings, err := h.notificationService.GetUserSettings(userID)
+	if err == nil {
+		settings.TelegramEnabled = c.PostForm("telegram_enabled") == "on"
+		// ボット連携が有効な場合、Chat ID は連携コードでのみ設定する
+		if h.telegramBot == nil {
+			settings.TelegramChatID = c.PostForm("telegram_chat_id")
+		}
+		settings.EmailEnabled = c.PostForm("email_enabled") == "on"
+		settings.NotifyOnCreate = c.PostForm("notify_on_create") == "on"
+		err = h.notificationService.UpdateUserSettings(userID, settings)
 	}
 
-	err := h.notificationService.UpdateUserSettings(userID, settings)
-
 	notifySettings, _ := h.notificationService.GetUserSettings(userID)
 
 	if err != nil {
-		RenderHTML(c, http.StatusOK, "profile.html", gin.H{
+		h.renderProfile(c, gin.H{
 			"title":          "プロフィール",
 			"user":           user,
 			"notifyError":    "通知設定の更新に失敗しました",
@@ -167,7 +241,7 @@ func (h *ProfileHandler) UpdateNotificationSettings(c *gin.Context) {
 		return
 	}
 
-	RenderHTML(c, http.StatusOK, "profile.html", gin.H{
+	h.renderProfile(c, gin.H{
 		"title":          "プロフィール",
 		"user":           user,
 		"notifySuccess":  "通知設定を更新しました",
@@ -177,6 +251,151 @@ func (h *ProfileHandler) UpdateNotificationSettings(c *gin.Context) {
 	})
 }
 
+func (h *ProfileHandler) RotateCalendarToken(c *gin.Context) {
+	userID := h.getUserID(c)
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+	notifySettings, _ := h.notificationService.GetUserSettings(userID)
+
+	token, err := h.calendarService.RotateToken(userID)
+	user, _ := h.authService.GetUserByID(userID)
+
+	if err != nil {
+		h.renderProfile(c, gin.H{
+			"title":          "プロフィール",
+			"user":           user,
+			"calendarError":  "購読URLの再発行に失敗しました",
+			"isAdmin":        role == "admin",
+			"userName":       name,
+			"notifySettings": notifySettings,
+		})
+		return
+	}
+
+	h.renderProfile(c, gin.H{
+		"title":           "プロフィール",
+		"user":            user,
+		"calendarSuccess": "購読URLを発行しました。以前のURLは使用できません",
+		"calendarToken":   token,
+		"isAdmin":         role == "admin",
+		"userName":        name,
+		"notifySettings":  notifySettings,
+	})
+}
+
+// ExportData はユーザーのデータをダウンロードさせる。format=csv の場合は CSV の ZIP、それ以外は JSON。
+func (h *ProfileHandler) ExportData(c *gin.Context) {
+	writeDataExport(c, h.dataTransferService, h.getUserID(c))
+}
+
+func (h *ProfileHandler) ImportData(c *gin.Context) {
+	userID := h.getUserID(c)
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+
+	render := func(data gin.H) {
+		user, _ := h.authService.GetUserByID(userID)
+		notifySettings, _ := h.notificationService.GetUserSettings(userID)
+		data["title"] = "プロフィール"
+		data["user"] = user
+		data["isAdmin"] = role == "admin"
+		data["userName"] = name
+		data["notifySettings"] = notifySettings
+		h.renderProfile(c, data)
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		render(gin.H{"dataError": "ファイルを選択してください"})
+		return
+	}
+	if file.Size > service.MaxDataImportSize {
+		render(gin.H{"dataError": "ファイルサイズが大きすぎます（最大10MB）"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		render(gin.H{"dataError": "ファイルの読み込みに失敗しました"})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, service.MaxDataImportSize+1))
+	if err != nil {
+		render(gin.H{"dataError": "ファイルの読み込みに失敗しました"})
+		return
+	}
+
+	result, err := h.dataTransferService.ImportData(userID, data)
+	if err != nil {
+		render(gin.H{"dataError": dataImportErrorMessage(err)})
+		return
+	}
+	auditDataImport(c, h.auditService, userID, result)
+
+	render(gin.H{"importResult": result})
+}
+
+// auditDataImport はデータのインポートを監査ログに残す。件数だけを記録し、取り込んだ内容は残さない。
+func auditDataImport(c *gin.Context, auditService *service.AuditService, userID uint, result *service.DataImportResult) {
+	auditService.Record(auditActor(c), models.AuditDataImport, service.AuditTarget{
+		Type: models.AuditTargetUser,
+		ID:   strconv.FormatUint(uint64(userID), 10),
+	}, map[string]interface{}{
+		"assignments_created":           result.Assignments.Created,
+		"assignments_updated":           result.Assignments.Updated,
+		"recurring_assignments_created": result.RecurringAssignments.Created,
+		"recurring_assignments_updated": result.RecurringAssignments.Updated,
+		"errors":                        len(result.Errors),
+	})
+}
+
+func dataImportErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, service.ErrImportTooLarge):
+		return "ファイルサイズが大きすぎます（最大10MB）"
+	case errors.Is(err, service.ErrUnsupportedExportVersion):
+		return "このバージョンのエクスポートファイルには対応していません"
+	case errors.Is(err, service.ErrUnsupportedImportFormat):
+		return "エクスポートした JSON または CSV（ZIP）ファイルを選択してください"
+	default:
+		return "インポートに失敗しました"
+	}
+}
+
+// writeDataExport は Web と API で共通のエクスポート処理。
+func writeDataExport(c *gin.Context, dataTransferService *service.DataTransferService, userID uint) {
+	format := c.DefaultQuery("format", service.ExportFormatJSON)
+	if format != service.ExportFormatJSON && format != service.ExportFormatCSV {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+
+	doc, err := dataTransferService.BuildExport(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+		return
+	}
+
+	var buf bytes.Buffer
+	filename := "homework-export-" + doc.ExportedAt.Format("20060102")
+	contentType := "application/json; charset=utf-8"
+	if format == service.ExportFormatCSV {
+		err = dataTransferService.WriteCSVArchive(&buf, doc)
+		filename += ".zip"
+		contentType = "application/zip"
+	} else {
+		err = dataTransferService.WriteJSON(&buf, doc)
+		filename += ".json"
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
 const totpPendingSecretKey = "totp_pending_secret"
 
 func (h *ProfileHandler) ShowTOTPSetup(c *gin.Context) {
@@ -235,12 +454,13 @@ func (h *ProfileHandler) EnableTOTP(c *gin.Context) {
 	}
 
 	code := c.PostForm("totp_code")
-	if !h.totpService.Validate(secret, code) {
+	step, valid := h.totpService.ValidateStep(secret, code, 0)
+	if !valid {
 		renderSetupError("認証コードが正しくありません。もう一度試してください")
 		return
 	}
 
-	if err := h.authService.EnableTOTP(userID, secret); err != nil {
+	if err := h.authService.EnableTOTP(userID, secret, step); err != nil {
 		RenderHTML(c, http.StatusOK, "totp_setup.html", gin.H{
 			"title": "2段階認証の設定",
 			"error": "2段階認証の有効化に失敗しました",
@@ -250,19 +470,81 @@ func (h *ProfileHandler) EnableTOTP(c *gin.Context) {
 
 	session.Delete(totpPendingSecretKey)
 	session.Save()
+	h.auditService.Record(auditActor(c), models.AuditTOTPEnable, service.UserAuditTarget(user), nil)
 
+	codes, err := h.recoveryCodeService.Generate(userID)
+	if err != nil {
+		role, _ := c.Get(middleware.UserRoleKey)
+		name, _ := c.Get(middleware.UserNameKey)
+		notifySettings, _ := h.notificationService.GetUserSettings(userID)
+		user, _ = h.authService.GetUserByID(userID)
+		h.renderProfile(c, gin.H{
+			"title":          "プロフィール",
+			"user":           user,
+			"totpError":      "2段階認証を有効化しましたが、リカバリーコードの発行に失敗しました。再発行してください",
+			"isAdmin":        role == "admin",
+			"userName":       name,
+			"notifySettings": notifySettings,
+		})
+		return
+	}
+
+	h.renderRecoveryCodes(c, user, codes, "2段階認証を有効化しました")
+}
+
+// RegenerateRecoveryCodes はリカバリーコードを再発行する。以前のコードは使えなくなる。
+func (h *ProfileHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := h.getUserID(c)
 	role, _ := c.Get(middleware.UserRoleKey)
 	name, _ := c.Get(middleware.UserNameKey)
+	user, _ := h.authService.GetUserByID(userID)
 	notifySettings, _ := h.notificationService.GetUserSettings(userID)
-	user, _ = h.authService.GetUserByID(userID)
 
-	RenderHTML(c, http.StatusOK, "profile.html", gin.H{
-		"title":          "プロフィール",
-		"user":           user,
-		"totpSuccess":    "2段階認証を有効化しました",
-		"isAdmin":        role == "admin",
-		"userName":       name,
-		"notifySettings": notifySettings,
+	renderError := func(msg string) {
+		h.renderProfile(c, gin.H{
+			"title":          "プロフィール",
+			"user":           user,
+			"totpError":      msg,
+			"isAdmin":        role == "admin",
+			"userName":       name,
+			"notifySettings": notifySettings,
+		})
+	}
+
+	if !user.TOTPEnabled {
+		renderError("2段階認証が有効になっていません")
+		return
+	}
+	password := c.PostForm("password")
+	if _, err := h.authService.Login(user.Email, password); err != nil {
+		renderError("パスワードが正しくありません")
+		return
+	}
+
+	codes, err := h.recoveryCodeService.Generate(userID)
+	if err != nil {
+		renderError("リカバリーコードの発行に失敗しました")
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditRecoveryCodesRegenerate, service.UserAuditTarget(user), nil)
+
+	h.renderRecoveryCodes(c, user, codes, "リカバリーコードを再発行しました。以前のコードは使えません")
+}
+
+// renderRecoveryCodes は発行したリカバリーコードを表示する。平文を表示できるのはこの画面だけ。
+func (h *ProfileHandler) renderRecoveryCodes(c *gin.Context, user *models.User, codes []string, message string) {
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+
+	RenderHTML(c, http.StatusOK, "totp_recovery_codes.html", gin.H{
+		"title":    "リカバリーコード",
+		"codes":    codes,
+		"message":  message,
+		"filename": "recovery-codes.txt",
+		"account":  user.Email,
+		"appName":  h.appName,
+		"isAdmin":  role == "admin",
+		"userName": name,
 	})
 }
 
@@ -275,7 +557,7 @@ func (h *ProfileHandler) DisableTOTP(c *gin.Context) {
 
 	password := c.PostForm("password")
 	if _, err := h.authService.Login(user.Email, password); err != nil {
-		RenderHTML(c, http.StatusOK, "profile.html", gin.H{
+		h.renderProfile(c, gin.H{
 			"title":          "プロフィール",
 			"user":           user,
 			"totpError":      "パスワードが正しくありません",
@@ -286,8 +568,8 @@ func (h *ProfileHandler) DisableTOTP(c *gin.Context) {
 		return
 	}
 
-	if err := h.authService.DisableTOTP(userID); err != nil {
-		RenderHTML(c, http.StatusOK, "profile.html", gin.H{
+	if err := h.authService.DisableTOTP(userID, sessions.Default(c).ID()); err != nil {
+		h.renderProfile(c, gin.H{
 			"title":          "プロフィール",
 			"user":           user,
 			"totpError":      "2段階認証の無効化に失敗しました",
@@ -297,12 +579,366 @@ func (h *ProfileHandler) DisableTOTP(c *gin.Context) {
 		})
 		return
 	}
+	h.auditService.Record(auditActor(c), models.AuditTOTPDisable, service.UserAuditTarget(user), nil)
 
 	user, _ = h.authService.GetUserByID(userID)
-	RenderHTML(c, http.StatusOK, "profile.html", gin.H{
+	h.renderProfile(c, gin.H{
+		"title":          "プロフィール",
+		"user":           user,
+		"totpSuccess":    "2段階認証を無効化しました。他の端末からはログアウトしました",
+		"isAdmin":        role == "admin",
+		"userName":       name,
+		"notifySettings": notifySettings,
+	})
+}
+
+// RevokeSession はログイン中のセッションを 1 つログアウトさせる。この画面のセッションを指定した場合はログイン画面に戻る。
+func (h *ProfileHandler) RevokeSession(c *gin.Context) {
+	userID := h.getUserID(c)
+	session := sessions.Default(c)
+	id := c.Param("id")
+
+	if err := h.sessionService.Revoke(userID, id); err != nil {
+		h.renderSessionResult(c, userID, gin.H{"sessionError": "セッションが見つかりません"})
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditSessionRevoke, service.AuditTarget{Type: models.AuditTargetSession, ID: id}, nil)
+	if session.ID() != "" && id == sessionstore.HashID(session.ID()) {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	h.renderSessionResult(c, userID, gin.H{"sessionSuccess": "セッションをログアウトさせました"})
+}
+
+// RevokeOtherSessions はこの画面以外のすべてのセッションをログアウトさせる。
+func (h *ProfileHandler) RevokeOtherSessions(c *gin.Context) {
+	userID := h.getUserID(c)
+	revoked, err := h.sessionService.RevokeOthers(userID, sessions.Default(c).ID())
+	if err != nil {
+		h.renderSessionResult(c, userID, gin.H{"sessionError": "ログアウトに失敗しました"})
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditSessionRevokeOthers, service.AuditTarget{
+		Type: models.AuditTargetUser,
+		ID:   strconv.FormatUint(uint64(userID), 10),
+	}, map[string]interface{}{"revoked": revoked})
+	h.renderSessionResult(c, userID, gin.H{"sessionSuccess": fmt.Sprintf("他の端末のセッション %d 件をログアウトさせました", revoked)})
+}
+
+func (h *ProfileHandler) renderSessionResult(c *gin.Context, userID uint, data gin.H) {
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+	user, _ := h.authService.GetUserByID(userID)
+	notifySettings, _ := h.notificationService.GetUserSettings(userID)
+	data["title"] = "プロフィール"
+	data["user"] = user
+	data["isAdmin"] = role == "admin"
+	data["userName"] = name
+	data["notifySettings"] = notifySettings
+	h.renderProfile(c, data)
+}
+
+// SendEmailVerification はログイン中のユーザーに確認メールを再送する。
+func (h *ProfileHandler) SendEmailVerification(c *gin.Context) {
+	userID := h.getUserID(c)
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+	user, _ := h.authService.GetUserByID(userID)
+	notifySettings, _ := h.notificationService.GetUserSettings(userID)
+
+	data := gin.H{
+		"title":          "プロフィール",
+		"user":           user,
+		"isAdmin":        role == "admin",
+		"userName":       name,
+		"notifySettings": notifySettings,
+	}
+	switch err := h.accountMail.SendVerification(user); {
+	case err == nil:
+		data["success"] = "確認メールを送信しました。メールのリンクを開いてください"
+	case errors.Is(err, service.ErrAccountMailRateLimited):
+		data["error"] = "確認メールの送信回数が上限に達しました。しばらくしてからもう一度お試しください"
+	default:
+		log.Printf("Verification mail could not be sent: %v", err)
+		data["error"] = "確認メールの送信に失敗しました"
+	}
+	h.renderProfile(c, data)
+}
+
+func (h *ProfileHandler) CreateTelegramLinkCode(c *gin.Context) {
+	userID := h.getUserID(c)
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+	user, _ := h.authService.GetUserByID(userID)
+
+	data := gin.H{
+		"title":    "プロフィール",
+		"user":     user,
+		"isAdmin":  role == "admin",
+		"userName": name,
+	}
+
+	if h.telegramBot == nil {
+		data["notifySettings"], _ = h.notificationService.GetUserSettings(userID)
+		data["telegramError"] = "Telegramボットが設定されていません"
+		h.renderProfile(c, data)
+		return
+	}
+
+	code, expiresAt, err := h.notificationService.CreateTelegramLinkCode(userID)
+	data["notifySettings"], _ = h.notificationService.GetUserSettings(userID)
+	if err != nil {
+		data["telegramError"] = "連携コードの発行に失敗しました"
+		h.renderProfile(c, data)
+		return
+	}
+
+	data["telegramLinkCode"] = code
+	data["telegramLinkExpiresAt"] = expiresAt
+	data["telegramLinkURL"] = h.telegramBot.LinkURL(code)
+	h.renderProfile(c, data)
+}
+
+func (h *ProfileHandler) UnlinkTelegram(c *gin.Context) {
+	userID := h.getUserID(c)
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+	user, _ := h.authService.GetUserByID(userID)
+
+	data := gin.H{
+		"title":    "プロフィール",
+		"user":     user,
+		"isAdmin":  role == "admin",
+		"userName": name,
+	}
+	if err := h.notificationService.UnlinkTelegram(userID); err != nil {
+		data["telegramError"] = "連携の解除に失敗しました"
+	} else {
+		data["telegramSuccess"] = "Telegramとの連携を解除しました"
+	}
+	data["notifySettings"], _ = h.notificationService.GetUserSettings(userID)
+	h.renderProfile(c, data)
+}
+
+func (h *ProfileHandler) CreateAPIKey(c *gin.Context) {
+	userID := h.getUserID(c)
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+	user, _ := h.authService.GetUserByID(userID)
+	notifySettings, _ := h.notificationService.GetUserSettings(userID)
+
+	data := gin.H{
+		"title":          "プロフィール",
+		"user":           user,
+		"isAdmin":        role == "admin",
+		"userName":       name,
+		"notifySettings": notifySettings,
+	}
+
+	var expiresAt *time.Time
+	if expiresStr := c.PostForm("expires_at"); expiresStr != "" {
+		date, err := time.ParseInLocation("2006-01-02", expiresStr, getUserLocation(c))
+		if err != nil {
+			data["apiKeyError"] = "有効期限の形式が正しくありません"
+			h.renderProfile(c, data)
+			return
+		}
+		// 指定した日の終わりまで有効
+		end := date.AddDate(0, 0, 1).UTC()
+		expiresAt = &end
+	}
+
+	keyName := c.PostForm("name")
+	plainKey, apiKey, err := h.apiKeyService.CreateAPIKey(userID, keyName, c.PostFormArray("scopes[]"), expiresAt, c.PostForm("allowed_ips"))
+	if err != nil {
+		data["apiKeyError"] = err.Error()
+		h.renderProfile(c, data)
+		return
+	}
+	auditAPIKeyCreate(c, h.auditService, apiKey)
+
+	data["newAPIKey"] = plainKey
+	data["newAPIKeyName"] = strings.TrimSpace(keyName)
+	h.renderProfile(c, data)
+}
+
+func (h *ProfileHandler) DeleteAPIKey(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/profile")
+		return
+	}
+
+	if err := h.apiKeyService.DeleteUserAPIKey(userID, uint(id)); err != nil {
+		role, _ := c.Get(middleware.UserRoleKey)
+		name, _ := c.Get(middleware.UserNameKey)
+		user, _ := h.authService.GetUserByID(userID)
+		notifySettings, _ := h.notificationService.GetUserSettings(userID)
+		h.renderProfile(c, gin.H{
+			"title":          "プロフィール",
+			"user":           user,
+			"apiKeyError":    err.Error(),
+			"isAdmin":        role == "admin",
+			"userName":       name,
+			"notifySettings": notifySettings,
+		})
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditAPIKeyDelete, service.AuditTarget{
+		Type: models.AuditTargetAPIKey,
+		ID:   strconv.FormatUint(id, 10),
+	}, nil)
+
+	c.Redirect(http.StatusFound, "/profile")
+}
+
+// auditAPIKeyCreate は API キーの作成を監査ログに残す。キー本体は記録しない。
+func auditAPIKeyCreate(c *gin.Context, auditService *service.AuditService, apiKey *models.APIKey) {
+	details := map[string]interface{}{
+		"owner_id": apiKey.UserID,
+		"scopes":   apiKey.Scopes,
+	}
+	if apiKey.ExpiresAt != nil {
+		details["expires_at"] = apiKey.ExpiresAt
+	}
+	if apiKey.AllowedIPs != "" {
+		details["allowed_ips"] = apiKey.AllowedIPs
+	}
+	auditService.Record(auditActor(c), models.AuditAPIKeyCreate, service.AuditTarget{
+		Type:  models.AuditTargetAPIKey,
+		ID:    strconv.FormatUint(uint64(apiKey.ID), 10),
+		Label: apiKey.Name,
+	}, details)
+}
+
+// BeginWebAuthnRegistration はパスキー登録用のオプションを返す。パスワードのあるアカウントは、TOTP の有効化と同じく現在のパスワードを確認する。
+func (h *ProfileHandler) BeginWebAuthnRegistration(c *gin.Context) {
+	userID := h.getUserID(c)
+	user, err := h.authService.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ユーザーが見つかりません"})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	c.ShouldBindJSON(&req)
+	if user.HasPassword() {
+		if _, err := h.authService.Login(user.Email, req.Password); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "パスワードが正しくありません"})
+			return
+		}
+	}
+
+	options, challenge, err := h.webauthnService.BeginRegistration(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "パスキーの登録を開始できませんでした"})
+		return
+	}
+	session := sessions.Default(c)
+	session.Set(webauthnRegistrationChallengeKey, challenge)
+	session.Save()
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+func (h *ProfileHandler) FinishWebAuthnRegistration(c *gin.Context) {
+	userID := h.getUserID(c)
+	session := sessions.Default(c)
+	challenge, _ := session.Get(webauthnRegistrationChallengeKey).(string)
+	session.Delete(webauthnRegistrationChallengeKey)
+	session.Save()
+
+	var req struct {
+		Name       string                        `json:"name"`
+		Credential webauthn.RegistrationResponse `json:"credential"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不正なリクエストです"})
+		return
+	}
+
+	credential, err := h.webauthnService.FinishRegistration(userID, req.Name, &req.Credential, challenge)
+	if err != nil {
+		message := "パスキーの登録に失敗しました"
+		switch {
+		case errors.Is(err, service.ErrWebAuthnCredentialExists):
+			message = "このパスキーは既に登録されています"
+		case errors.Is(err, service.ErrWebAuthnNameTooLong):
+			message = "名前は100文字以内で入力してください"
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditPasskeyRegister, service.AuditTarget{
+		Type:  models.AuditTargetPasskey,
+		ID:    strconv.FormatUint(uint64(credential.ID), 10),
+		Label: credential.Name,
+	}, nil)
+
+	c.JSON(http.StatusOK, gin.H{"id": credential.ID, "name": credential.Name})
+}
+
+func (h *ProfileHandler) RenameWebAuthnCredential(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/profile")
+		return
+	}
+
+	if err := h.webauthnService.RenameCredential(userID, uint(id), c.PostForm("name")); err != nil {
+		message := "パスキーの名前を変更できませんでした"
+		if errors.Is(err, service.ErrWebAuthnNameTooLong) {
+			message = "名前は100文字以内で入力してください"
+		}
+		h.renderWebAuthnError(c, userID, message)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/profile")
+}
+
+// DeleteWebAuthnCredential はパスキーを削除する。パスワードのあるアカウントは、TOTP の無効化と同じく現在のパスワードを確認する。
+func (h *ProfileHandler) DeleteWebAuthnCredential(c *gin.Context) {
+	userID := h.getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/profile")
+		return
+	}
+
+	user, _ := h.authService.GetUserByID(userID)
+	if user.HasPassword() {
+		if _, err := h.authService.Login(user.Email, c.PostForm("password")); err != nil {
+			h.renderWebAuthnError(c, userID, "パスワードが正しくありません")
+			return
+		}
+	}
+
+	if err := h.webauthnService.DeleteCredential(userID, uint(id)); err != nil {
+		h.renderWebAuthnError(c, userID, "パスキーが見つかりません")
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditPasskeyDelete, service.AuditTarget{
+		Type: models.AuditTargetPasskey,
+		ID:   strconv.FormatUint(id, 10),
+	}, nil)
+
+	c.Redirect(http.StatusFound, "/profile")
+}
+
+func (h *ProfileHandler) renderWebAuthnError(c *gin.Context, userID uint, message string) {
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+	user, _ := h.authService.GetUserByID(userID)
+	notifySettings, _ := h.notificationService.GetUserSettings(userID)
+	h.renderProfile(c, gin.H{
 		"title":          "プロフィール",
 		"user":           user,
-		"totpSuccess":    "2段階認証を無効化しました",
+		"webauthnError":  message,
 		"isAdmin":        role == "admin",
 		"userName":       name,
 		"notifySettings": notifySettings,
@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"homework-manager/internal/middleware"
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+	"homework-manager/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SubjectHandler struct {
+	subjectService *service.SubjectService
+	auditService   *service.AuditService
+}
+
+func NewSubjectHandler(db *gorm.DB) *SubjectHandler {
+	return &SubjectHandler{
+		subjectService: service.NewSubjectService(db),
+		auditService:   service.NewAuditService(db),
+	}
+}
+
+func (h *SubjectHandler) getUserID(c *gin.Context) uint {
+	userID, _ := c.Get(middleware.UserIDKey)
+	return userID.(uint)
+}
+
+func subjectErrorMessage(err error) string {
+	var validationErr *validation.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return err.Error()
+	case errors.Is(err, service.ErrSubjectNameTaken):
+		return "同じ名前の科目がすでにあります"
+	case errors.Is(err, service.ErrSubjectMergeSelf):
+		return "統合先には別の科目を選んでください"
+	case errors.Is(err, service.ErrSubjectNotFound):
+		return "科目が見つかりません"
+	default:
+		return "科目の保存に失敗しました"
+	}
+}
+
+// subjectInputFromForm はフォームの入力を SubjectInput にする。既定のリマインダーは空の場合は設定なしにする。
+func subjectInputFromForm(c *gin.Context) service.SubjectInput {
+	input := service.SubjectInput{
+		Name:            c.PostForm("name"),
+		Color:           c.PostForm("color"),
+		Teacher:         c.PostForm("teacher"),
+		Room:            c.PostForm("room"),
+		DefaultPriority: c.PostForm("default_priority"),
+	}
+	if offset, err := strconv.Atoi(c.PostForm("default_reminder_offset")); err == nil {
+		input.DefaultReminderOffset = &offset
+	}
+	return input
+}
+
+func (h *SubjectHandler) renderIndex(c *gin.Context, data gin.H) {
+	userID := h.getUserID(c)
+	subjects, _ := h.subjectService.List(userID, true)
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+
+	data["title"] = "科目"
+	data["subjects"] = subjects
+	data["counts"] = h.subjectService.Counts(userID)
+	data["reminderOffsets"] = service.SubjectReminderOffsets
+	data["defaultColor"] = models.DefaultSubjectColor
+	data["isAdmin"] = role == "admin"
+	data["userName"] = name
+	RenderHTML(c, http.StatusOK, "subjects/index.html", data)
+}
+
+func (h *SubjectHandler) Index(c *gin.Context) {
+	h.renderIndex(c, gin.H{})
+}
+
+func (h *SubjectHandler) Create(c *gin.Context) {
+	if _, err := h.subjectService.Create(h.getUserID(c), subjectInputFromForm(c)); err != nil {
+		h.renderIndex(c, gin.H{"error": subjectErrorMessage(err)})
+		return
+	}
+	c.Redirect(http.StatusFound, "/subjects")
+}
+
+func (h *SubjectHandler) renderEdit(c *gin.Context, subject *models.Subject, data gin.H) {
+	userID := h.getUserID(c)
+	subjects, _ := h.subjectService.List(userID, true)
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+
+	data["title"] = "科目: " + subject.Name
+	data["subject"] = subject
+	data["subjects"] = subjects
+	data["count"] = h.subjectService.Counts(userID)[subject.ID]
+	// API で一覧にない時間を設定した場合も選択肢に含める
+	offsets := service.SubjectReminderOffsets
+	if subject.DefaultReminderOffset != nil && !slices.Contains(offsets, *subject.DefaultReminderOffset) {
+		offsets = append(slices.Clone(offsets), *subject.DefaultReminderOffset)
+		slices.Sort(offsets)
+	}
+	data["reminderOffsets"] = offsets
+	data["isAdmin"] = role == "admin"
+	data["userName"] = name
+	RenderHTML(c, http.StatusOK, "subjects/edit.html", data)
+}
+
+func (h *SubjectHandler) loadSubject(c *gin.Context) (*models.Subject, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/subjects")
+		return nil, false
+	}
+	subject, err := h.subjectService.Get(h.getUserID(c), uint(id))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/subjects")
+		return nil, false
+	}
+	return subject, true
+}
+
+func (h *SubjectHandler) Edit(c *gin.Context) {
+	subject, ok := h.loadSubject(c)
+	if !ok {
+		return
+	}
+	h.renderEdit(c, subject, gin.H{})
+}
+
+func (h *SubjectHandler) Update(c *gin.Context) {
+	subject, ok := h.loadSubject(c)
+	if !ok {
+		return
+	}
+
+	oldName := subject.Name
+	updated, err := h.subjectService.Update(subject.UserID, subject.ID, subjectInputFromForm(c))
+	if err != nil {
+		h.renderEdit(c, subject, gin.H{"error": subjectErrorMessage(err)})
+		return
+	}
+	if updated.Name != oldName {
+		h.auditService.Record(auditActor(c), models.AuditSubjectRename, service.SubjectAuditTarget(updated), map[string]interface{}{
+			"from": oldName,
+			"to":   updated.Name,
+		})
+	}
+
+	c.Redirect(http.StatusFound, "/subjects")
+}
+
+func (h *SubjectHandler) Archive(c *gin.Context) {
+	h.setArchived(c, true)
+}
+
+func (h *SubjectHandler) Unarchive(c *gin.Context) {
+	h.setArchived(c, false)
+}
+
+func (h *SubjectHandler) setArchived(c *gin.Context, archived bool) {
+	subject, ok := h.loadSubject(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.subjectService.SetArchived(subject.UserID, subject.ID, archived); err == nil {
+		action := models.AuditSubjectArchive
+		if !archived {
+			action = models.AuditSubjectUnarchive
+		}
+		h.auditService.Record(auditActor(c), action, service.SubjectAuditTarget(subject), nil)
+	}
+
+	c.Redirect(http.StatusFound, "/subjects")
+}
+
+func (h *SubjectHandler) Merge(c *gin.Context) {
+	subject, ok := h.loadSubject(c)
+	if !ok {
+		return
+	}
+
+	targetID, _ := strconv.ParseUint(c.PostForm("target_id"), 10, 32)
+	target, err := h.subjectService.Merge(subject.UserID, subject.ID, uint(targetID))
+	if err != nil {
+		h.renderEdit(c, subject, gin.H{"error": subjectErrorMessage(err)})
+		return
+	}
+	h.auditService.Record(auditActor(c), models.AuditSubjectMerge, service.SubjectAuditTarget(subject), map[string]interface{}{
+		"into": target.Name,
+	})
+
+	c.Redirect(http.StatusFound, "/subjects")
+}
+
+func (h *SubjectHandler) Delete(c *gin.Context) {
+	subject, ok := h.loadSubject(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.subjectService.Delete(subject.UserID, subject.ID); err == nil {
+		h.auditService.Record(auditActor(c), models.AuditSubjectDelete, service.SubjectAuditTarget(subject), nil)
+	}
+
+	c.Redirect(http.StatusFound, "/subjects")
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+
+	"homework-manager/internal/service"
+	"homework-manager/internal/telegram"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TelegramHandler は Telegram ボットの Webhook（telegram_bot_mode = webhook の場合）を受け付ける。
+type TelegramHandler struct {
+	bot *service.TelegramBotService
+}
+
+func NewTelegramHandler(bot *service.TelegramBotService) *TelegramHandler {
+	return &TelegramHandler{bot: bot}
+}
+
+func (h *TelegramHandler) Webhook(c *gin.Context) {
+	if !h.bot.VerifyWebhookSecret(c.GetHeader(telegram.SecretTokenHeader)) {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	var update telegram.Update
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	h.bot.HandleUpdate(&update)
+	c.Status(http.StatusOK)
+}
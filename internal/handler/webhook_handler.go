@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"homework-manager/internal/middleware"
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const webhookDeliveriesPageSize = 20
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+func NewWebhookHandler(db *gorm.DB) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: service.NewWebhookService(db),
+	}
+}
+
+func (h *WebhookHandler) getUserID(c *gin.Context) uint {
+	userID, _ := c.Get(middleware.UserIDKey)
+	return userID.(uint)
+}
+
+func webhookErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, service.ErrInvalidWebhookURL):
+		return "URLは http:// または https:// で始まる形式で入力してください"
+	case errors.Is(err, service.ErrWebhookAddressNotAllowed):
+		return "ローカルネットワークや内部アドレスへのURLは登録できません"
+	case errors.Is(err, service.ErrInvalidWebhookEvent):
+		return "不正なイベントが指定されています"
+	default:
+		return "Webhookの保存に失敗しました"
+	}
+}
+
+func (h *WebhookHandler) renderIndex(c *gin.Context, status int, data gin.H) {
+	userID := h.getUserID(c)
+	webhooks, _ := h.webhookService.GetAllByUser(userID)
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+
+	data["title"] = "Webhook"
+	data["webhooks"] = webhooks
+	data["events"] = models.WebhookEvents
+	data["isAdmin"] = role == "admin"
+	data["userName"] = name
+	RenderHTML(c, status, "webhooks/index.html", data)
+}
+
+func (h *WebhookHandler) Index(c *gin.Context) {
+	h.renderIndex(c, http.StatusOK, gin.H{})
+}
+
+func (h *WebhookHandler) Create(c *gin.Context) {
+	userID := h.getUserID(c)
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		h.renderIndex(c, http.StatusOK, gin.H{"error": "名前を入力してください"})
+		return
+	}
+
+	webhook, err := h.webhookService.Create(userID, name, c.PostForm("url"), c.PostFormArray("events[]"))
+	if err != nil {
+		h.renderIndex(c, http.StatusOK, gin.H{"error": webhookErrorMessage(err)})
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/webhooks/"+strconv.FormatUint(uint64(webhook.ID), 10))
+}
+
+func (h *WebhookHandler) renderShow(c *gin.Context, webhook *models.Webhook, data gin.H) {
+	userID := h.getUserID(c)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	deliveries, _ := h.webhookService.GetDeliveries(userID, webhook.ID, page, webhookDeliveriesPageSize)
+	role, _ := c.Get(middleware.UserRoleKey)
+	name, _ := c.Get(middleware.UserNameKey)
+
+	data["title"] = "Webhook: " + webhook.Name
+	data["webhook"] = webhook
+	data["events"] = models.WebhookEvents
+	data["deliveries"] = deliveries
+	data["currentPage"] = page
+	data["hasPrev"] = page > 1
+	data["hasNext"] = deliveries != nil && page < deliveries.TotalPages
+	data["prevPage"] = page - 1
+	data["nextPage"] = page + 1
+	data["isAdmin"] = role == "admin"
+	data["userName"] = name
+	RenderHTML(c, http.StatusOK, "webhooks/show.html", data)
+}
+
+func (h *WebhookHandler) loadWebhook(c *gin.Context) (*models.Webhook, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/webhooks")
+		return nil, false
+	}
+	webhook, err := h.webhookService.GetByID(h.getUserID(c), uint(id))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/webhooks")
+		return nil, false
+	}
+	return webhook, true
+}
+
+func (h *WebhookHandler) Show(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	h.renderShow(c, webhook, gin.H{})
+}
+
+func (h *WebhookHandler) Update(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		h.renderShow(c, webhook, gin.H{"error": "名前を入力してください"})
+		return
+	}
+
+	updated, err := h.webhookService.Update(webhook.UserID, webhook.ID, name, c.PostForm("url"), c.PostFormArray("events[]"), c.PostForm("is_active") == "on")
+	if err != nil {
+		h.renderShow(c, webhook, gin.H{"error": webhookErrorMessage(err)})
+		return
+	}
+
+	h.renderShow(c, updated, gin.H{"success": "Webhookを更新しました"})
+}
+
+func (h *WebhookHandler) RotateSecret(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.webhookService.RotateSecret(webhook.UserID, webhook.ID)
+	if err != nil {
+		h.renderShow(c, webhook, gin.H{"error": "署名シークレットの再発行に失敗しました"})
+		return
+	}
+
+	h.renderShow(c, updated, gin.H{"success": "署名シークレットを再発行しました"})
+}
+
+func (h *WebhookHandler) Ping(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.webhookService.Ping(webhook.UserID, webhook.ID); err != nil {
+		h.renderShow(c, webhook, gin.H{"error": "テスト送信に失敗しました"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/webhooks/"+c.Param("id"))
+}
+
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.ParseUint(c.Param("deliveryId"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/webhooks/"+c.Param("id"))
+		return
+	}
+
+	if _, err := h.webhookService.Redeliver(webhook.UserID, uint(deliveryID)); err != nil {
+		h.renderShow(c, webhook, gin.H{"error": "再送に失敗しました"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/webhooks/"+c.Param("id"))
+}
+
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	h.webhookService.Delete(webhook.UserID, webhook.ID)
+	c.Redirect(http.StatusFound, "/webhooks")
+}
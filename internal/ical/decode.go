@@ -0,0 +1,205 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCalendar = errors.New("invalid iCalendar data")
+
+// Decode は iCalendar 形式のデータを解析し、最上位のコンポーネント（通常は VCALENDAR）を返す。
+func Decode(r io.Reader) (*Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var root *Component
+	var stack []*Component
+	for n, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidCalendar, n+1, err)
+		}
+
+		switch strings.ToUpper(prop.Name) {
+		case "BEGIN":
+			c := NewComponent(strings.ToUpper(prop.Value))
+			if len(stack) > 0 {
+				stack[len(stack)-1].AddChild(c)
+			} else if root == nil {
+				root = c
+			} else {
+				return nil, fmt.Errorf("%w: multiple top-level components", ErrInvalidCalendar)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("%w: unexpected END:%s", ErrInvalidCalendar, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: property outside of component", ErrInvalidCalendar)
+			}
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, prop)
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("%w: no component found", ErrInvalidCalendar)
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: missing END:%s", ErrInvalidCalendar, stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// unfold は折り返された行（空白またはタブで始まる行）を連結する。
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func parseLine(line string) (Property, error) {
+	var prop Property
+
+	// 名前とパラメータ部分は引用符の外にある最初の ':' まで
+	inQuote := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		} else if r == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return prop, errors.New("missing ':'")
+	}
+	prop.Value = line[colon+1:]
+
+	parts := splitOutsideQuotes(line[:colon], ';')
+	prop.Name = strings.ToUpper(strings.TrimSpace(parts[0]))
+	if prop.Name == "" {
+		return prop, errors.New("missing property name")
+	}
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			return prop, fmt.Errorf("malformed parameter %q", p)
+		}
+		prop.Params = append(prop.Params, Param{
+			Name:  strings.ToUpper(kv[0]),
+			Value: strings.Trim(kv[1], `"`),
+		})
+	}
+	return prop, nil
+}
+
+func splitOutsideQuotes(s string, sep rune) []string {
+	var parts []string
+	inQuote := false
+	start := 0
+	for i, r := range s {
+		if r == '"' {
+			inQuote = !inQuote
+		} else if r == sep && !inQuote {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func UnescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
+
+// Text はテキスト値をアンエスケープして返す。
+func (p *Property) Text() string {
+	return UnescapeText(p.Value)
+}
+
+// Texts はカンマ区切りのテキスト値（CATEGORIES など）を分割して返す。
+func (p *Property) Texts() []string {
+	var values []string
+	var b strings.Builder
+	escaped := false
+	for _, r := range p.Value {
+		switch {
+		case escaped:
+			b.WriteRune('\\')
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			values = append(values, UnescapeText(b.String()))
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	values = append(values, UnescapeText(b.String()))
+	return values
+}
+
+// Time は DATE / DATE-TIME 値を解析する。TZID パラメータがあればそのタイムゾーン、
+// UTC 指定もタイムゾーン指定もない場合は loc で解釈する。2番目の戻り値は DATE（終日）の場合に true。
+func (p *Property) Time(loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(p.Value)
+	if strings.EqualFold(p.Param("VALUE"), "DATE") || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+
+	if tzid := p.Param("TZID"); tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			loc = tz
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// Components は name に一致する子コンポーネントを返す。
+func (c *Component) Components(name string) []*Component {
+	var result []*Component
+	for _, child := range c.Children {
+		if child.Name == name {
+			result = append(result, child)
+		}
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeUnfoldsLines(t *testing.T) {
+	data := "\uFEFFBEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"SUMMARY:長いタイト\r\n" +
+		" ルの課題\r\n" +
+		"DESCRIPTION:1行目\\n2行目\\, カンマ\\; セミコロン\r\n" +
+		"\t続き\r\n" +
+		"CATEGORIES:数学,英語\\,リーディング\r\n" +
+		"DUE;TZID=\"Asia/Tokyo\":20260410T090000\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	root, err := Decode(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	todos := root.Components("VTODO")
+	if root.Name != "VCALENDAR" || len(todos) != 1 {
+		t.Fatalf("root = %s with %d VTODO(s)", root.Name, len(todos))
+	}
+	todo := todos[0]
+
+	if got := todo.Get("SUMMARY").Text(); got != "長いタイトルの課題" {
+		t.Errorf("SUMMARY = %q", got)
+	}
+	if got := todo.Get("DESCRIPTION").Text(); got != "1行目\n2行目, カンマ; セミコロン続き" {
+		t.Errorf("DESCRIPTION = %q", got)
+	}
+	if got := todo.Get("CATEGORIES").Texts(); len(got) != 2 || got[0] != "数学" || got[1] != "英語,リーディング" {
+		t.Errorf("CATEGORIES = %q", got)
+	}
+	if got := todo.Get("DUE").Param("TZID"); got != "Asia/Tokyo" {
+		t.Errorf("TZID = %q", got)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"空", ""},
+		{"END がない", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VCALENDAR\r\n"},
+		{"コロンがない", "BEGIN:VCALENDAR\r\nSUMMARY\r\nEND:VCALENDAR\r\n"},
+		{"コンポーネントの外のプロパティ", "SUMMARY:課題\r\n"},
+		{"最上位のコンポーネントが複数", "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\nBEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(strings.NewReader(tt.data)); !errors.Is(err, ErrInvalidCalendar) {
+				t.Errorf("Decode error = %v, want ErrInvalidCalendar", err)
+			}
+		})
+	}
+}
+
+func TestPropertyTime(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		line       string
+		want       time.Time
+		wantAllDay bool
+	}{
+		{"UTC", "DUE:20260410T090000Z", time.Date(2026, 4, 10, 9, 0, 0, 0, time.UTC), false},
+		{"TZID", "DUE;TZID=America/New_York:20260410T090000", time.Date(2026, 4, 10, 9, 0, 0, 0, newYork), false},
+		{"不明な TZID は loc で解釈", "DUE;TZID=Nowhere/Unknown:20260410T090000", time.Date(2026, 4, 10, 9, 0, 0, 0, tokyo), false},
+		{"フローティング", "DTSTART:20260410T090000", time.Date(2026, 4, 10, 9, 0, 0, 0, tokyo), false},
+		{"終日", "DTSTART;VALUE=DATE:20260410", time.Date(2026, 4, 10, 0, 0, 0, 0, tokyo), true},
+		{"VALUE のない日付", "DTSTART:20260410", time.Date(2026, 4, 10, 0, 0, 0, 0, tokyo), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prop, err := parseLine(tt.line)
+			if err != nil {
+				t.Fatalf("parseLine: %v", err)
+			}
+			got, allDay, err := prop.Time(tokyo)
+			if err != nil {
+				t.Fatalf("Time: %v", err)
+			}
+			if !got.Equal(tt.want) || allDay != tt.wantAllDay {
+				t.Errorf("Time = %v, %v, want %v, %v", got, allDay, tt.want, tt.wantAllDay)
+			}
+		})
+	}
+
+	prop, _ := parseLine("DUE:2026-04-10")
+	if _, _, err := prop.Time(tokyo); err == nil {
+		t.Error("Time accepted a malformed value")
+	}
+}
@@ -0,0 +1,146 @@
+// Package ical は iCalendar (RFC 5545) の生成と解析を行う。
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateTimeFormat = "20060102T150405Z"
+	dateFormat     = "20060102"
+	maxLineOctets  = 75
+)
+
+type Param struct {
+	Name  string
+	Value string
+}
+
+type Property struct {
+	Name   string
+	Params []Param
+	Value  string
+}
+
+// Param は名前が一致する最初のパラメータの値を返す。
+func (p *Property) Param(name string) string {
+	for _, param := range p.Params {
+		if strings.EqualFold(param.Name, name) {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+type Component struct {
+	Name       string
+	Properties []Property
+	Children   []*Component
+}
+
+func NewComponent(name string) *Component {
+	return &Component{Name: name}
+}
+
+// NewCalendar は VERSION / PRODID を設定した VCALENDAR を返す。
+func NewCalendar(prodID string) *Component {
+	cal := NewComponent("VCALENDAR")
+	cal.Set("VERSION", "2.0")
+	cal.Set("PRODID", prodID)
+	cal.Set("CALSCALE", "GREGORIAN")
+	return cal
+}
+
+// Set は値をそのまま（エスケープせずに）追加する。
+func (c *Component) Set(name, value string, params ...Param) {
+	c.Properties = append(c.Properties, Property{Name: name, Params: params, Value: value})
+}
+
+// SetText はテキスト値をエスケープして追加する。
+func (c *Component) SetText(name, value string, params ...Param) {
+	c.Set(name, EscapeText(value), params...)
+}
+
+// SetDateTime は UTC の DATE-TIME 値を追加する。
+func (c *Component) SetDateTime(name string, t time.Time) {
+	c.Set(name, FormatDateTime(t))
+}
+
+// SetDate は DATE 値（終日）を追加する。
+func (c *Component) SetDate(name string, t time.Time) {
+	c.Set(name, t.Format(dateFormat), Param{Name: "VALUE", Value: "DATE"})
+}
+
+func (c *Component) AddChild(child *Component) {
+	c.Children = append(c.Children, child)
+}
+
+// Get は名前が一致する最初のプロパティを返す。
+func (c *Component) Get(name string) *Property {
+	for i := range c.Properties {
+		if strings.EqualFold(c.Properties[i].Name, name) {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// Encode は CRLF 改行・75オクテットで折り返した iCalendar 形式で書き出す。
+func (c *Component) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	c.encode(bw)
+	return bw.Flush()
+}
+
+func (c *Component) encode(w *bufio.Writer) {
+	writeLine(w, "BEGIN:"+c.Name)
+	for _, p := range c.Properties {
+		var b strings.Builder
+		b.WriteString(p.Name)
+		for _, param := range p.Params {
+			b.WriteString(";" + param.Name + "=" + quoteParam(param.Value))
+		}
+		b.WriteString(":" + p.Value)
+		writeLine(w, b.String())
+	}
+	for _, child := range c.Children {
+		child.encode(w)
+	}
+	writeLine(w, "END:"+c.Name)
+}
+
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// 継続行は先頭の空白1文字を含めて75オクテット
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line + "\r\n")
+}
+
+func quoteParam(v string) string {
+	if strings.ContainsAny(v, ";:,") {
+		return `"` + strings.ReplaceAll(v, `"`, "") + `"`
+	}
+	return v
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func EscapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat)
+}
@@ -0,0 +1,196 @@
+// Package mail は SMTP でメールを送信する。本文はプレーンテキストと HTML の multipart/alternative で送る。
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"homework-manager/internal/config"
+)
+
+const (
+	EncryptionNone     = "none"
+	EncryptionSTARTTLS = "starttls"
+	EncryptionTLS      = "tls"
+)
+
+var ErrNotConfigured = errors.New("SMTP is not configured")
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Sender struct {
+	config  config.SMTPConfig
+	timeout time.Duration
+}
+
+func NewSender(cfg config.SMTPConfig) *Sender {
+	return &Sender{config: cfg, timeout: 30 * time.Second}
+}
+
+// Configured は送信に必要な設定（ホストと送信元）が揃っているかを返す。
+func (s *Sender) Configured() bool {
+	return s.config.Host != "" && s.config.From != ""
+}
+
+func (s *Sender) Send(msg *Message) error {
+	if !s.Configured() {
+		return ErrNotConfigured
+	}
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	body, err := s.build(msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	dialer := &net.Dialer{Timeout: s.timeout}
+	var conn net.Conn
+	if s.config.Encryption == EncryptionTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: s.config.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(s.timeout))
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if s.config.Encryption == EncryptionSTARTTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+			return err
+		}
+	}
+	if s.config.Username != "" {
+		auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	from, err := mail.ParseAddress(s.config.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	to, _ := mail.ParseAddress(msg.To)
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (s *Sender) build(msg *Message) ([]byte, error) {
+	var buf bytes.Buffer
+
+	from := s.config.From
+	if s.config.FromName != "" {
+		if addr, err := mail.ParseAddress(s.config.From); err == nil {
+			from = (&mail.Address{Name: s.config.FromName, Address: addr.Address}).String()
+		}
+	}
+
+	header := func(key, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(s.config.From))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", `text/plain; charset="UTF-8"`)
+		header("Content-Transfer-Encoding", "base64")
+		buf.WriteString("\r\n")
+		writeBase64(&buf, msg.Text)
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", `multipart/alternative; boundary="`+mw.Boundary()+`"`)
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + `; charset="UTF-8"`},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var encoded bytes.Buffer
+		writeBase64(&encoded, part.body)
+		w.Write(encoded.Bytes())
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64 は本文を 76 文字ごとに改行した base64 で書き出す。
+func writeBase64(buf *bytes.Buffer, s string) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(s))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndex(addr.Address, "@"); i >= 0 {
+			domain = addr.Address[i+1:]
+		}
+	}
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
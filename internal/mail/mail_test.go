@@ -0,0 +1,54 @@
+package mail
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/mail/mailtest"
+)
+
+func TestSend(t *testing.T) {
+	sink := mailtest.Start(t)
+	sender := NewSender(config.SMTPConfig{
+		Host:       sink.Host(),
+		Port:       sink.Port(),
+		From:       "noreply@example.com",
+		FromName:   "課題管理",
+		Encryption: EncryptionNone,
+	})
+
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{"テキストのみ", Message{To: "student@example.com", Subject: "課題の期限が近づいています", Text: strings.Repeat("数学のプリント ", 20)}},
+		{"テキストと HTML", Message{To: "Student <student@example.com>", Subject: "リマインダー", Text: "本文", HTML: "<p>本文</p>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := sender.Send(&tt.msg); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			got := sink.Wait(t, 5*time.Second)
+			if got.From != "noreply@example.com" || len(got.To) != 1 || got.To[0] != "student@example.com" {
+				t.Errorf("envelope = %q -> %v", got.From, got.To)
+			}
+			if got.Subject != tt.msg.Subject || got.Text != tt.msg.Text || got.HTML != tt.msg.HTML {
+				t.Errorf("message = %q / %q / %q", got.Subject, got.Text, got.HTML)
+			}
+			if from := got.Header.Get("From"); !strings.Contains(from, "<noreply@example.com>") {
+				t.Errorf("From header = %q", from)
+			}
+		})
+	}
+
+	if err := sender.Send(&Message{To: "not an address", Text: "x"}); err == nil {
+		t.Error("Send accepted an invalid recipient")
+	}
+	if err := NewSender(config.SMTPConfig{}).Send(&Message{To: "student@example.com"}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("unconfigured sender: err = %v, want %v", err, ErrNotConfigured)
+	}
+}
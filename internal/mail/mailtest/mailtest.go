@@ -0,0 +1,218 @@
+// Package mailtest はテスト用の SMTP サーバー。受け取ったメールを配送せずにメモリに保持する。
+// 暗号化と認証には対応しないため、送信側は Encryption を "none" にして Username を空にする。
+package mailtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Message は受け取ったメール。Text と HTML は転送エンコーディングを戻した本文。
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Header  mail.Header
+	Text    string
+	HTML    string
+	Raw     []byte
+}
+
+type Server struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []*Message
+	received chan *Message
+}
+
+// Start は 127.0.0.1 の空いているポートで SMTP サーバーを起動し、テストの終了時に止める。
+func Start(t testing.TB) *Server {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start SMTP sink: %v", err)
+	}
+	s := &Server{listener: listener, received: make(chan *Message, 100)}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *Server) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Messages は受け取ったメールを受信順に返す。
+func (s *Server) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Message(nil), s.messages...)
+}
+
+// Wait はまだ Wait で返していないメールを受信順に 1 通返す。timeout までに届かなければテストを失敗させる。
+func (s *Server) Wait(t testing.TB, timeout time.Duration) *Message {
+	t.Helper()
+	select {
+	case msg := <-s.received:
+		return msg
+	case <-time.After(timeout):
+		t.Fatalf("no mail received within %s", timeout)
+		return nil
+	}
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(code int, text string) {
+		fmt.Fprintf(conn, "%d %s\r\n", code, text)
+	}
+
+	reply(220, "mailtest ESMTP")
+	var from string
+	var to []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			fmt.Fprintf(conn, "250-mailtest\r\n250 8BITMIME\r\n")
+		case "HELO", "NOOP":
+			reply(250, "OK")
+		case "MAIL":
+			from = addressArg(arg)
+			to = nil
+			reply(250, "OK")
+		case "RCPT":
+			to = append(to, addressArg(arg))
+			reply(250, "OK")
+		case "RSET":
+			from, to = "", nil
+			reply(250, "OK")
+		case "DATA":
+			reply(354, "End data with <CR><LF>.<CR><LF>")
+			raw, err := readData(r)
+			if err != nil {
+				return
+			}
+			msg, err := parse(raw)
+			if err != nil {
+				reply(554, err.Error())
+				continue
+			}
+			msg.From, msg.To = from, to
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			s.received <- msg
+			reply(250, "OK: queued")
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		default:
+			reply(502, "Command not implemented")
+		}
+	}
+}
+
+// addressArg は "FROM:<a@example.com>" のような引数からアドレスを取り出す。
+func addressArg(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+	return strings.Trim(addr, "<>")
+}
+
+func readData(r *bufio.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == ".\r\n" {
+			return buf.Bytes(), nil
+		}
+		buf.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+func parse(raw []byte) (*Message, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{Subject: subject, Header: m.Header, Raw: raw}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		msg.Text, err = decodeBody(m.Header.Get("Content-Transfer-Encoding"), m.Body)
+		return msg, err
+	}
+
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return msg, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		body, err := decodeBody(part.Header.Get("Content-Transfer-Encoding"), part)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/html") {
+			msg.HTML = body
+		} else {
+			msg.Text = body
+		}
+	}
+}
+
+func decodeBody(encoding string, r io.Reader) (string, error) {
+	if strings.EqualFold(encoding, "base64") {
+		// base64 の行末の改行はデコーダーが読み飛ばす
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+	body, err := io.ReadAll(r)
+	return string(body), err
+}
@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 
+	"homework-manager/internal/models"
 	"homework-manager/internal/service"
 
 	"github.com/gin-contrib/sessions"
@@ -13,6 +15,12 @@ const UserIDKey = "user_id"
 const UserRoleKey = "user_role"
 const UserNameKey = "user_name"
 
+// UserLocationKey にはユーザーのタイムゾーン (*time.Location) が入る
+const UserLocationKey = "user_location"
+
+// APIKeyKey には IdentifyAPIKey で認証した APIキー (*models.APIKey) が入る
+const APIKeyKey = "api_key"
+
 func AuthRequired(authService *service.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		session := sessions.Default(c)
@@ -36,6 +44,19 @@ func AuthRequired(authService *service.AuthService) gin.HandlerFunc {
 		c.Set(UserIDKey, user.ID)
 		c.Set(UserRoleKey, user.Role)
 		c.Set(UserNameKey, user.Name)
+		c.Set(UserLocationKey, user.Location())
+		c.Next()
+	}
+}
+
+type SessionTracker interface {
+	Touch(sessionID, ip, userAgent string) error
+}
+
+// TrackSession はログイン中のセッションの最終アクセス日時・IP・ユーザーエージェントを記録する。AuthRequired の後に使う。
+func TrackSession(tracker SessionTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tracker.Touch(sessions.Default(c).ID(), c.ClientIP(), c.Request.UserAgent())
 		c.Next()
 	}
 }
@@ -86,36 +107,98 @@ func InjectUserInfo() gin.HandlerFunc {
 }
 
 type APIKeyValidator interface {
-	ValidateAPIKey(key string) (uint, error)
+	ValidateAPIKey(key, clientIP string) (*models.APIKey, error)
 }
 
-func APIKeyAuth(validator APIKeyValidator) gin.HandlerFunc {
+// apiKeyErrorKey には IdentifyAPIKey で APIキーを認証できなかったときの応答 (apiKeyError) が入る
+const apiKeyErrorKey = "api_key_error"
+
+type apiKeyError struct {
+	status  int
+	message string
+}
+
+// IdentifyAPIKey は Authorization ヘッダーの APIキーを検証し、有効なら APIKeyKey と UserIDKey を設定する。
+// 無効なキーでもリクエストは止めず、拒否は APIKeyAuth で行う。間にレート制限を挟み、有効なキーのないリクエストを IP で数えるため。
+func IdentifyAPIKey(validator APIKeyValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			c.Set(apiKeyErrorKey, apiKeyError{http.StatusUnauthorized, "Authorization header required"})
+			c.Next()
 			return
 		}
 
 		const bearerPrefix = "Bearer "
 		if len(authHeader) <= len(bearerPrefix) || authHeader[:len(bearerPrefix)] != bearerPrefix {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format. Use: Bearer <api_key>"})
-			c.Abort()
+			c.Set(apiKeyErrorKey, apiKeyError{http.StatusUnauthorized, "Invalid authorization format. Use: Bearer <api_key>"})
+			c.Next()
 			return
 		}
 
 		apiKey := authHeader[len(bearerPrefix):]
 
-		userID, err := validator.ValidateAPIKey(apiKey)
+		key, err := validator.ValidateAPIKey(apiKey, c.ClientIP())
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
-			c.Abort()
+			switch {
+			case errors.Is(err, service.ErrAPIKeyExpired):
+				c.Set(apiKeyErrorKey, apiKeyError{http.StatusUnauthorized, "API key expired"})
+			case errors.Is(err, service.ErrAPIKeyIPNotAllowed):
+				c.Set(apiKeyErrorKey, apiKeyError{http.StatusForbidden, "IP address not allowed for this API key"})
+			default:
+				c.Set(apiKeyErrorKey, apiKeyError{http.StatusUnauthorized, "Invalid API key"})
+			}
+			c.Next()
+			return
+		}
+
+		c.Set(UserIDKey, key.UserID)
+		c.Set(APIKeyKey, key)
+		c.Next()
+	}
+}
+
+// APIKeyAuth は IdentifyAPIKey で有効な APIキーが見つからなかったリクエストを拒否する。IdentifyAPIKey の後に使う。
+func APIKeyAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get(APIKeyKey); exists {
+			c.Next()
 			return
 		}
+		failure, ok := c.Value(apiKeyErrorKey).(apiKeyError)
+		if !ok {
+			failure = apiKeyError{http.StatusUnauthorized, "Authorization header required"}
+		}
+		c.JSON(failure.status, gin.H{"error": failure.message})
+		c.Abort()
+	}
+}
 
-		c.Set(UserIDKey, userID)
+// RequireScope は APIキーに scope が付与されていない場合に 403 を返す。APIKeyAuth の後に使う。
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get(APIKeyKey)
+		key, ok := value.(*models.APIKey)
+		if !exists || !ok || !key.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":         "Missing required scope: " + scope,
+				"missing_scope": scope,
+			})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
+// InjectUserLocation は APIKeyAuth で認証したユーザーのタイムゾーンを UserLocationKey に設定する。
+func InjectUserLocation(authService *service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userID, exists := c.Get(UserIDKey); exists {
+			if user, err := authService.GetUserByID(userID.(uint)); err == nil {
+				c.Set(UserLocationKey, user.Location())
+			}
+		}
+		c.Next()
+	}
+}
@@ -1,87 +1,142 @@
 package middleware
 
 import (
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
+	"homework-manager/internal/models"
+
+	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
-type RateLimitConfig struct {
-	Enabled  bool
-	Requests int
-	Window   int
-}
+// レート制限のアルゴリズム
+const (
+	RateLimitSlidingWindow = "sliding_window" // 直前の窓の回数を経過時間で按分して加える
+	RateLimitTokenBucket   = "token_bucket"   // Window で Requests 個のペースでトークンを補充し、最大 Requests 回まで連続して受け付ける
+)
+
+// レート制限の単位
+const (
+	RateLimitByIP     = "ip"
+	RateLimitByUser   = "user"
+	RateLimitByAPIKey = "api_key"
+)
 
-type rateLimitEntry struct {
-	count     int
-	expiresAt time.Time
+// RateLimitStore はレート制限の状態を保存する。複数台のサーバーで制限を共有する場合は共有のストア（データベース）を使う。
+type RateLimitStore interface {
+	// Update は id の状態を fn で書き換えて保存する。同じ id への他の Update とは排他する。
+	// 状態がない場合は回数・トークンが 0 で、Timestamp がゼロ値または十分に古いものを fn に渡す。
+	Update(id string, fn func(bucket *models.RateLimitBucket)) error
+	DeleteExpired(now time.Time) error
 }
 
-type rateLimiter struct {
-	entries map[string]*rateLimitEntry
+// MemoryRateLimitStore はプロセス内のレート制限のストア。再起動で状態は消え、複数台では共有されない。
+type MemoryRateLimitStore struct {
 	mu      sync.Mutex
-	config  RateLimitConfig
+	buckets map[string]*models.RateLimitBucket
 }
 
-func newRateLimiter(config RateLimitConfig) *rateLimiter {
-	rl := &rateLimiter{
-		entries: make(map[string]*rateLimitEntry),
-		config:  config,
-	}
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*models.RateLimitBucket)}
+}
 
-	go rl.cleanup()
+func (s *MemoryRateLimitStore) Update(id string, fn func(bucket *models.RateLimitBucket)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return rl
+	bucket, exists := s.buckets[id]
+	if !exists {
+		bucket = &models.RateLimitBucket{ID: id}
+		s.buckets[id] = bucket
+	}
+	fn(bucket)
+	return nil
 }
 
-func (rl *rateLimiter) cleanup() {
-	ticker := time.NewTicker(time.Minute)
-	for range ticker.C {
-		rl.mu.Lock()
-		now := time.Now()
-		for key, entry := range rl.entries {
-			if now.After(entry.expiresAt) {
-				delete(rl.entries, key)
-			}
+func (s *MemoryRateLimitStore) DeleteExpired(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, bucket := range s.buckets {
+		if now.After(bucket.ExpiresAt) {
+			delete(s.buckets, id)
 		}
-		rl.mu.Unlock()
 	}
+	return nil
+}
+
+// RateLimitPolicy はルートグループごとの制限。Window あたり Requests 回まで受け付ける。Requests が 0 の場合は制限しない。
+type RateLimitPolicy struct {
+	Name     string // ストアのキーと RateLimit-Policy ヘッダーに使う（"web", "login", "api"）
+	Requests int
+	Window   time.Duration
+	By       string // RateLimitByIP, RateLimitByUser, RateLimitByAPIKey
 }
 
-func (rl *rateLimiter) allow(key string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+type RateLimitConfig struct {
+	Enabled   bool
+	Algorithm string // RateLimitSlidingWindow or RateLimitTokenBucket
+	Store     RateLimitStore
+}
 
-	now := time.Now()
-	entry, exists := rl.entries[key]
+// RateLimiter はポリシーごとのレート制限のミドルウェアを作る。状態は RateLimitConfig.Store に保存する。
+type RateLimiter struct {
+	config RateLimitConfig
+	now    func() time.Time
+}
 
-	if !exists || now.After(entry.expiresAt) {
-		rl.entries[key] = &rateLimitEntry{
-			count:     1,
-			expiresAt: now.Add(time.Duration(rl.config.Window) * time.Second),
-		}
-		return true
-	}
+// NewRateLimiter は状態の日時を UTC で扱う。SQLite のストアは日時を文字列として比較するため、オフセットを揃えておく。
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return &RateLimiter{config: config, now: func() time.Time { return time.Now().UTC() }}
+}
 
-	entry.count++
-	return entry.count <= rl.config.Requests
+// rateLimitResult は 1 回のリクエストの判定結果。
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration // 制限が元に戻るまでの時間
+	retryAfter time.Duration // 拒否した場合、次に受け付けるまでの時間
 }
 
-func RateLimit(config RateLimitConfig) gin.HandlerFunc {
-	if !config.Enabled {
+// Limit は policy で制限するミドルウェアを返す。応答には RateLimit-* ヘッダーを付け、超過した場合は 429 と Retry-After を返す。
+// ストアの障害時はリクエストを止めない。
+func (l *RateLimiter) Limit(policy RateLimitPolicy) gin.HandlerFunc {
+	if !l.config.Enabled || policy.Requests <= 0 {
 		return func(c *gin.Context) {
 			c.Next()
 		}
 	}
 
-	limiter := newRateLimiter(config)
+	policyHeader := strconv.Itoa(policy.Requests) + ";w=" + strconv.Itoa(int(policy.Window/time.Second))
 
 	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
+		var result rateLimitResult
+		id := policy.Name + ":" + rateLimitIdentity(c, policy.By)
+		err := l.config.Store.Update(id, func(bucket *models.RateLimitBucket) {
+			result = l.take(bucket, policy, l.now())
+		})
+		if err != nil {
+			log.Printf("Rate limit store error: %v", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", policyHeader)
+		c.Header("RateLimit-Limit", strconv.Itoa(policy.Requests))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.reset)))
 
-		if !limiter.allow(clientIP) {
+		if !result.allowed {
+			retryAfter := ceilSeconds(result.retryAfter)
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error": "リクエスト数が制限を超えました。しばらくしてからお試しください。",
 			})
@@ -92,3 +147,117 @@ func RateLimit(config RateLimitConfig) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+func (l *RateLimiter) take(bucket *models.RateLimitBucket, policy RateLimitPolicy, now time.Time) rateLimitResult {
+	if l.config.Algorithm == RateLimitTokenBucket {
+		return takeTokenBucket(bucket, policy, now)
+	}
+	return takeSlidingWindow(bucket, policy, now)
+}
+
+// StartCleanup は期限切れの状態を定期的に削除する。
+func (l *RateLimiter) StartCleanup(interval time.Duration) {
+	if !l.config.Enabled {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := l.config.Store.DeleteExpired(l.now()); err != nil {
+				log.Printf("Error deleting expired rate limit buckets: %v", err)
+			}
+		}
+	}()
+}
+
+// rateLimitIdentity はリクエストを数える単位を返す。有効な APIキー・ログイン中のユーザーが分からないリクエストは IP で数える。
+func rateLimitIdentity(c *gin.Context, by string) string {
+	switch by {
+	case RateLimitByAPIKey:
+		if key, ok := c.Value(APIKeyKey).(*models.APIKey); ok {
+			return "api_key:" + strconv.FormatUint(uint64(key.ID), 10)
+		}
+	case RateLimitByUser:
+		if userID, ok := c.Value(UserIDKey).(uint); ok {
+			return "user:" + strconv.FormatUint(uint64(userID), 10)
+		}
+		if userID, ok := sessions.Default(c).Get(UserIDKey).(uint); ok {
+			return "user:" + strconv.FormatUint(uint64(userID), 10)
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// takeSlidingWindow は現在の窓の回数に、直前の窓の回数を残り時間の割合で按分して加えた値で判定する。
+func takeSlidingWindow(bucket *models.RateLimitBucket, policy RateLimitPolicy, now time.Time) rateLimitResult {
+	window := policy.Window
+	limit := float64(policy.Requests)
+	start := now.Truncate(window)
+
+	switch {
+	case bucket.Timestamp.Equal(start):
+	case bucket.Timestamp.Equal(start.Add(-window)):
+		bucket.Previous, bucket.Value = bucket.Value, 0
+		bucket.Timestamp = start
+	default:
+		bucket.Previous, bucket.Value = 0, 0
+		bucket.Timestamp = start
+	}
+	bucket.ExpiresAt = start.Add(2 * window)
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(window)
+	count := bucket.Previous*weight + bucket.Value
+	result := rateLimitResult{reset: window - elapsed}
+
+	if count+1 > limit {
+		// 直前の窓の按分が減って 1 回分の空きができるまで。現在の窓だけで上限に達している場合は次の窓まで待つ
+		if bucket.Value+1 <= limit && bucket.Previous > 0 {
+			wait := float64(window)*(1-(limit-1-bucket.Value)/bucket.Previous) - float64(elapsed)
+			result.retryAfter = time.Duration(math.Max(wait, 0))
+		} else {
+			result.retryAfter = window - elapsed + time.Duration(float64(window)*math.Max(1-(limit-1)/bucket.Value, 0))
+		}
+		return result
+	}
+
+	bucket.Value++
+	result.allowed = true
+	result.remaining = int(limit - (count + 1))
+	return result
+}
+
+// takeTokenBucket は経過時間に応じてトークンを補充し、1 つ消費できれば受け付ける。最初は満タンにする。
+func takeTokenBucket(bucket *models.RateLimitBucket, policy RateLimitPolicy, now time.Time) rateLimitResult {
+	capacity := float64(policy.Requests)
+	perSecond := capacity / policy.Window.Seconds()
+
+	if bucket.Timestamp.IsZero() {
+		bucket.Value = capacity
+	} else if elapsed := now.Sub(bucket.Timestamp); elapsed > 0 {
+		bucket.Value = math.Min(capacity, bucket.Value+elapsed.Seconds()*perSecond)
+	}
+	bucket.Timestamp = now
+
+	result := rateLimitResult{}
+	if bucket.Value >= 1 {
+		bucket.Value--
+		result.allowed = true
+	} else {
+		result.retryAfter = secondsDuration((1 - bucket.Value) / perSecond)
+	}
+	result.remaining = int(bucket.Value)
+	result.reset = secondsDuration((capacity - bucket.Value) / perSecond)
+	bucket.ExpiresAt = now.Add(result.reset)
+	return result
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
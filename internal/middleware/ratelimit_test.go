@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+const rateLimitTestIP = "192.0.2.1"
+
+// rateLimitStores はテストするストアを返す。SQL のストアはインメモリの SQLite を使う。
+func rateLimitStores(t *testing.T) map[string]func() RateLimitStore {
+	return map[string]func() RateLimitStore{
+		"memory": func() RateLimitStore { return NewMemoryRateLimitStore() },
+		"sql":    func() RateLimitStore { return repository.NewRateLimitRepository(testutil.OpenDB(t)) },
+	}
+}
+
+// rateLimitStep は elapsed の時刻のリクエストと、その応答に期待する値。wantRetry は拒否した場合の Retry-After（秒）。
+type rateLimitStep struct {
+	elapsed       time.Duration
+	wantStatus    int
+	wantRemaining int
+	wantReset     int
+	wantRetry     int
+}
+
+// newRateLimitEngine は policy で制限した GET / を持つエンジンと、時刻を進める関数を返す。
+func newRateLimitEngine(store RateLimitStore, algorithm string, policy RateLimitPolicy, base time.Time) (*gin.Engine, func(time.Duration)) {
+	now := base
+	limiter := NewRateLimiter(RateLimitConfig{Enabled: true, Algorithm: algorithm, Store: store})
+	limiter.now = func() time.Time { return now }
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/", limiter.Limit(policy), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return engine, func(elapsed time.Duration) { now = base.Add(elapsed) }
+}
+
+func serveRateLimited(engine *gin.Engine) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = rateLimitTestIP + ":12345"
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func headerInt(t *testing.T, rec *httptest.ResponseRecorder, name string) int {
+	t.Helper()
+	value := rec.Header().Get(name)
+	if value == "" {
+		return -1
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		t.Fatalf("%s: %q is not a number", name, value)
+	}
+	return n
+}
+
+func TestRateLimit(t *testing.T) {
+	// UTC より遅れたタイムゾーンの時計でも、時刻の比較とストアの期限切れの判定がずれない
+	base := time.Date(2026, 4, 10, 12, 0, 0, 0, time.FixedZone("EST", -5*60*60))
+
+	tests := []struct {
+		name      string
+		algorithm string
+		policy    RateLimitPolicy
+		steps     []rateLimitStep
+	}{
+		{
+			name:      "スライディングウィンドウ",
+			algorithm: RateLimitSlidingWindow,
+			policy:    RateLimitPolicy{Name: "web", Requests: 4, Window: time.Minute, By: RateLimitByIP},
+			steps: []rateLimitStep{
+				{elapsed: 0, wantStatus: http.StatusOK, wantRemaining: 3, wantReset: 60},
+				{elapsed: 15 * time.Second, wantStatus: http.StatusOK, wantRemaining: 2, wantReset: 45},
+				{elapsed: 15 * time.Second, wantStatus: http.StatusOK, wantRemaining: 1, wantReset: 45},
+				{elapsed: 15 * time.Second, wantStatus: http.StatusOK, wantRemaining: 0, wantReset: 45},
+				// 現在の窓だけで上限に達したため、次の窓で直前の窓の按分が 3 回分になるまで待つ
+				{elapsed: 30 * time.Second, wantStatus: http.StatusTooManyRequests, wantRemaining: 0, wantReset: 30, wantRetry: 45},
+				// 次の窓では直前の 4 回を残り時間の割合（3/4）で数える
+				{elapsed: 75 * time.Second, wantStatus: http.StatusOK, wantRemaining: 0, wantReset: 45},
+				{elapsed: 75 * time.Second, wantStatus: http.StatusTooManyRequests, wantRemaining: 0, wantReset: 45, wantRetry: 15},
+				{elapsed: 90 * time.Second, wantStatus: http.StatusOK, wantRemaining: 0, wantReset: 30},
+				// 窓を 2 つ以上空けると数え直す
+				{elapsed: 200 * time.Second, wantStatus: http.StatusOK, wantRemaining: 3, wantReset: 40},
+			},
+		},
+		{
+			name:      "トークンバケット",
+			algorithm: RateLimitTokenBucket,
+			policy:    RateLimitPolicy{Name: "api", Requests: 3, Window: 3 * time.Second, By: RateLimitByIP},
+			steps: []rateLimitStep{
+				{elapsed: 0, wantStatus: http.StatusOK, wantRemaining: 2, wantReset: 1},
+				{elapsed: 0, wantStatus: http.StatusOK, wantRemaining: 1, wantReset: 2},
+				{elapsed: 0, wantStatus: http.StatusOK, wantRemaining: 0, wantReset: 3},
+				{elapsed: 500 * time.Millisecond, wantStatus: http.StatusTooManyRequests, wantRemaining: 0, wantReset: 3, wantRetry: 1},
+				{elapsed: time.Second, wantStatus: http.StatusOK, wantRemaining: 0, wantReset: 3},
+				// 補充は容量で打ち切る
+				{elapsed: time.Minute, wantStatus: http.StatusOK, wantRemaining: 2, wantReset: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for storeName, newStore := range rateLimitStores(t) {
+			t.Run(tt.name+"/"+storeName, func(t *testing.T) {
+				store := newStore()
+				engine, setElapsed := newRateLimitEngine(store, tt.algorithm, tt.policy, base)
+
+				for i, step := range tt.steps {
+					setElapsed(step.elapsed)
+					// 期限切れの削除を挟んでも、有効な状態は残る
+					if err := store.DeleteExpired(base.Add(step.elapsed)); err != nil {
+						t.Fatalf("DeleteExpired: %v", err)
+					}
+
+					rec := serveRateLimited(engine)
+					if rec.Code != step.wantStatus {
+						t.Fatalf("step %d: status %d, want %d", i, rec.Code, step.wantStatus)
+					}
+					if got := headerInt(t, rec, "RateLimit-Limit"); got != tt.policy.Requests {
+						t.Errorf("step %d: RateLimit-Limit = %d, want %d", i, got, tt.policy.Requests)
+					}
+					if got := headerInt(t, rec, "RateLimit-Remaining"); got != step.wantRemaining {
+						t.Errorf("step %d: RateLimit-Remaining = %d, want %d", i, got, step.wantRemaining)
+					}
+					if got := headerInt(t, rec, "RateLimit-Reset"); got != step.wantReset {
+						t.Errorf("step %d: RateLimit-Reset = %d, want %d", i, got, step.wantReset)
+					}
+					wantRetry := step.wantRetry
+					if step.wantStatus == http.StatusOK {
+						wantRetry = -1
+					}
+					if got := headerInt(t, rec, "Retry-After"); got != wantRetry {
+						t.Errorf("step %d: Retry-After = %d, want %d", i, got, wantRetry)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRateLimitSeparatesIdentities(t *testing.T) {
+	policy := RateLimitPolicy{Name: "login", Requests: 1, Window: time.Minute, By: RateLimitByIP}
+	engine, _ := newRateLimitEngine(NewMemoryRateLimitStore(), RateLimitSlidingWindow, policy, time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC))
+
+	if rec := serveRateLimited(engine); rec.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", rec.Code)
+	}
+	if rec := serveRateLimited(engine); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "198.51.100.1:12345"
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("request from another IP: status %d", rec.Code)
+	}
+}
+
+// bucketValue はストアにある id の現在の回数を返す。状態がない場合は 0。
+func bucketValue(t *testing.T, store RateLimitStore, id string) float64 {
+	t.Helper()
+	var value float64
+	if err := store.Update(id, func(bucket *models.RateLimitBucket) { value = bucket.Value }); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	return value
+}
+
+func TestRateLimitStoreDeleteExpired(t *testing.T) {
+	policy := RateLimitPolicy{Name: "web", Requests: 10, Window: time.Minute, By: RateLimitByIP}
+	id := policy.Name + ":ip:" + rateLimitTestIP
+
+	zones := []*time.Location{
+		time.UTC,
+		time.FixedZone("EST", -5*60*60),
+		time.FixedZone("JST", 9*60*60),
+	}
+	for _, zone := range zones {
+		for storeName, newStore := range rateLimitStores(t) {
+			t.Run(zone.String()+"/"+storeName, func(t *testing.T) {
+				store := newStore()
+				base := time.Date(2026, 4, 10, 12, 0, 0, 0, zone)
+				engine, _ := newRateLimitEngine(store, RateLimitSlidingWindow, policy, base)
+				if rec := serveRateLimited(engine); rec.Code != http.StatusOK {
+					t.Fatalf("status %d", rec.Code)
+				}
+
+				// スライディングウィンドウの状態は次の窓の終わりまで有効
+				if err := store.DeleteExpired(base.Add(2 * time.Minute).UTC()); err != nil {
+					t.Fatalf("DeleteExpired: %v", err)
+				}
+				if got := bucketValue(t, store, id); got != 1 {
+					t.Fatalf("live bucket deleted: value %v", got)
+				}
+
+				if err := store.DeleteExpired(base.Add(2*time.Minute + time.Second).UTC()); err != nil {
+					t.Fatalf("DeleteExpired: %v", err)
+				}
+				if got := bucketValue(t, store, id); got != 0 {
+					t.Errorf("expired bucket kept: value %v", got)
+				}
+			})
+		}
+	}
+}
+
+func TestNewRateLimiterUsesUTC(t *testing.T) {
+	if loc := NewRateLimiter(RateLimitConfig{}).now().Location(); loc != time.UTC {
+		t.Errorf("now() location = %v, want UTC", loc)
+	}
+}
@@ -1,19 +1,95 @@
 package models
 
 import (
+	"net"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// APIキーのスコープ。ルートごとに必要なスコープを middleware.RequireScope で指定する。
+const (
+	ScopeAssignmentsRead  = "assignments:read"
+	ScopeAssignmentsWrite = "assignments:write"
+	ScopeRecurringRead    = "recurring:read"
+	ScopeRecurringWrite   = "recurring:write"
+	ScopeStatisticsRead   = "statistics:read"
+)
+
+// APIKeyScopes は発行時に選択できるスコープ（表示順）。
+var APIKeyScopes = []string{
+	ScopeAssignmentsRead,
+	ScopeAssignmentsWrite,
+	ScopeRecurringRead,
+	ScopeRecurringWrite,
+	ScopeStatisticsRead,
+}
+
 type APIKey struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	UserID    uint           `gorm:"not null;index" json:"user_id"`
-	Name      string         `gorm:"not null" json:"name"`
-	KeyHash   string         `gorm:"not null;uniqueIndex;size:255" json:"-"`
-	LastUsed  *time.Time     `json:"last_used,omitempty"`
-	CreatedAt time.Time      `json:"created_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID         uint           `gorm:"primarykey" json:"id"`
+	UserID     uint           `gorm:"not null;index" json:"user_id"`
+	Name       string         `gorm:"not null" json:"name"`
+	KeyHash    string         `gorm:"not null;uniqueIndex;size:255" json:"-"`
+	Scopes     string         `gorm:"size:255" json:"scopes"`                 // スペース区切り
+	AllowedIPs string         `gorm:"type:text" json:"allowed_ips,omitempty"` // 許可する IP / CIDR（カンマ区切り）。空なら制限しない
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	LastUsed   *time.Time     `json:"last_used,omitempty"`
+	LastUsedIP string         `gorm:"size:45" json:"last_used_ip,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 
 	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
+
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && !time.Now().Before(*k.ExpiresAt)
+}
+
+// AllowedIPList は許可リストの各エントリ（IP アドレスまたは CIDR）を返す。
+func (k *APIKey) AllowedIPList() []string {
+	var entries []string
+	for _, entry := range strings.Split(k.AllowedIPs, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// AllowsIP は ip からの接続が許可リストで許可されているかを返す。許可リストが空なら常に true。
+func (k *APIKey) AllowsIP(ip string) bool {
+	entries := k.AllowedIPList()
+	if len(entries) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, entry := range entries {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,22 +12,38 @@ type Assignment struct {
 	UserID                 uint       `gorm:"not null;index" json:"user_id"`
 	Title                  string     `gorm:"not null" json:"title"`
 	Description            string     `json:"description"`
-	Subject                string     `json:"subject"`
+	SubjectID              *uint      `gorm:"index" json:"subject_id,omitempty"`
+	Subject                string     `json:"subject"` // SubjectID の科目の名前（一覧・通知用に保持し、科目の名前の変更・統合で書き換える）
 	Priority               string     `gorm:"not null;default:medium" json:"priority"`
 	DueDate                time.Time  `gorm:"not null" json:"due_date"`
 	IsCompleted            bool       `gorm:"default:false" json:"is_completed"`
-	IsArchived             bool       `gorm:"default:false;index" json:"is_archived"`
 	CompletedAt            *time.Time `json:"completed_at,omitempty"`
 	ReminderEnabled        bool       `gorm:"default:false" json:"reminder_enabled"`
 	ReminderAt             *time.Time `json:"reminder_at,omitempty"`
 	ReminderSent           bool       `gorm:"default:false;index" json:"reminder_sent"`
 	UrgentReminderEnabled  bool       `gorm:"default:true" json:"urgent_reminder_enabled"`
 	LastUrgentReminderSent *time.Time `json:"last_urgent_reminder_sent,omitempty"`
+	// スヌーズ中は督促通知を送らない
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+	// 期限切れイベント (Webhook) を送信した日時。期限を変更するとクリアする
+	OverdueNotifiedAt *time.Time `json:"-"`
+
+	// インポート元の UID（再インポート時の重複判定に使用）
+	ExternalUID string `gorm:"size:255;index" json:"external_uid,omitempty"`
+
+	// チェックリストの項目がすべて完了したら課題を完了にする
+	ChecklistAutoComplete bool            `gorm:"default:false" json:"checklist_auto_complete"`
+	ChecklistItems        []ChecklistItem `gorm:"foreignKey:AssignmentID" json:"checklist_items,omitempty"`
+
+	Tags []Tag `gorm:"many2many:assignment_tags" json:"tags"`
 
 	// Recurring assignment reference
 	RecurringAssignmentID *uint                `gorm:"index" json:"recurring_assignment_id,omitempty"`
 	RecurringAssignment   *RecurringAssignment `gorm:"foreignKey:RecurringAssignmentID" json:"-"`
 
+	// グループに配布された課題の場合の配布元
+	GroupAssignmentID *uint `gorm:"index" json:"group_assignment_id,omitempty"`
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
@@ -34,15 +51,34 @@ type Assignment struct {
 	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
 
+// UID はカレンダー出力・エクスポートで使う識別子を返す。インポートした課題は元の UID を引き継ぐ。
+func (a *Assignment) UID() string {
+	if a.ExternalUID != "" {
+		return a.ExternalUID
+	}
+	return fmt.Sprintf("assignment-%d@homework-manager", a.ID)
+}
+
+// ParseAssignmentUID は UID() が生成した UID から課題IDを取り出す。
+func ParseAssignmentUID(uid string) (uint, bool) {
+	var id uint
+	if _, err := fmt.Sscanf(uid, "assignment-%d@homework-manager", &id); err != nil || id == 0 {
+		return 0, false
+	}
+	return id, uid == fmt.Sprintf("assignment-%d@homework-manager", id)
+}
+
 func (a *Assignment) IsOverdue() bool {
 	return !a.IsCompleted && time.Now().After(a.DueDate)
 }
 
-func (a *Assignment) IsDueToday() bool {
-	now := time.Now()
-	return a.DueDate.Year() == now.Year() &&
-		a.DueDate.Month() == now.Month() &&
-		a.DueDate.Day() == now.Day()
+// IsDueToday は loc での今日が期限かを返す。
+func (a *Assignment) IsDueToday(loc *time.Location) bool {
+	now := time.Now().In(loc)
+	due := a.DueDate.In(loc)
+	return due.Year() == now.Year() &&
+		due.Month() == now.Month() &&
+		due.Day() == now.Day()
 }
 
 func (a *Assignment) IsDueThisWeek() bool {
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Attachment は課題に添付したファイル。内容は Storage に StorageKey で保存する。
+type Attachment struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	AssignmentID uint      `gorm:"not null;index" json:"assignment_id"`
+	FileName     string    `gorm:"not null;size:255" json:"file_name"`
+	ContentType  string    `gorm:"not null;size:100" json:"content_type"` // 内容から判定した MIME タイプ
+	Size         int64     `gorm:"not null" json:"size"`
+	StorageKey   string    `gorm:"not null;size:255;uniqueIndex" json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// IsImage は画像のファイルかを返す。
+func (a *Attachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
+
+// IsInline はブラウザで直接表示するファイル（画像・PDF）かを返す。それ以外はダウンロードさせる。
+func (a *Attachment) IsInline() bool {
+	return a.IsImage() || a.ContentType == "application/pdf"
+}
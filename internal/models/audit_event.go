@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// 監査ログの操作
+const (
+	AuditLoginSuccess            = "login.success"
+	AuditLoginFailure            = "login.failure"
+	AuditLoginBlocked            = "login.blocked"
+	AuditPasswordChange          = "password.change"
+	AuditPasswordReset           = "password.reset"
+	AuditTOTPEnable              = "totp.enable"
+	AuditTOTPDisable             = "totp.disable"
+	AuditRecoveryCodesRegenerate = "recovery_codes.regenerate"
+	AuditPasskeyRegister         = "passkey.register"
+	AuditPasskeyDelete           = "passkey.delete"
+	AuditSessionRevoke           = "session.revoke"
+	AuditSessionRevokeOthers     = "session.revoke_others"
+	AuditAPIKeyCreate            = "api_key.create"
+	AuditAPIKeyDelete            = "api_key.delete"
+	AuditUserRoleChange          = "user.role_change"
+	AuditUserDelete              = "user.delete"
+	AuditUserUnlock              = "user.unlock"
+	AuditSubjectArchive          = "subject.archive"
+	AuditSubjectUnarchive        = "subject.unarchive"
+	AuditSubjectRename           = "subject.rename"
+	AuditSubjectMerge            = "subject.merge"
+	AuditSubjectDelete           = "subject.delete"
+	AuditAssignmentImport        = "assignment.import"
+	AuditDataImport              = "data.import"
+)
+
+// 監査ログの操作対象の種類
+const (
+	AuditTargetUser    = "user"
+	AuditTargetAPIKey  = "api_key"
+	AuditTargetPasskey = "passkey"
+	AuditTargetSession = "session"
+	AuditTargetSubject = "subject"
+)
+
+// AuditEvent は「誰が・何に・何をしたか」の記録。セキュリティに関わる操作と管理者の操作を残す。
+// 操作者・対象のメールアドレスや名前は記録時点の値を保存し、ユーザーが削除された後も読めるようにする。
+type AuditEvent struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Action      string    `gorm:"not null;size:50;index" json:"action"`
+	ActorID     *uint     `gorm:"index" json:"actor_id,omitempty"` // 未ログイン・存在しないアカウントへのログイン試行は nil
+	ActorEmail  string    `gorm:"size:255;index" json:"actor_email,omitempty"`
+	TargetType  string    `gorm:"size:30;index:idx_audit_events_target" json:"target_type,omitempty"`
+	TargetID    string    `gorm:"size:100;index:idx_audit_events_target" json:"target_id,omitempty"`
+	TargetLabel string    `gorm:"size:255" json:"target_label,omitempty"`
+	IP          string    `gorm:"size:64" json:"ip"`
+	UserAgent   string    `gorm:"size:255" json:"user_agent"`
+	Details     string    `gorm:"type:text" json:"-"` // JSON オブジェクト
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
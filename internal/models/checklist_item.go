@@ -0,0 +1,59 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// ChecklistItem は課題を細かく分けたチェックリストの項目。Position の昇順に表示する。
+type ChecklistItem struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	AssignmentID uint       `gorm:"not null;index" json:"assignment_id"`
+	Title        string     `gorm:"not null;size:200" json:"title"`
+	Position     int        `gorm:"not null;default:0" json:"position"`
+	IsDone       bool       `gorm:"default:false" json:"is_done"`
+	DoneAt       *time.Time `json:"done_at,omitempty"`
+	DueDate      *time.Time `json:"due_date,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (i *ChecklistItem) IsOverdue() bool {
+	return !i.IsDone && i.DueDate != nil && time.Now().After(*i.DueDate)
+}
+
+// ChecklistProgress は課題のチェックリストの進捗。
+type ChecklistProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// ChecklistProgressOf は items の進捗を返す。
+func ChecklistProgressOf(items []ChecklistItem) ChecklistProgress {
+	progress := ChecklistProgress{Total: len(items)}
+	for _, item := range items {
+		if item.IsDone {
+			progress.Done++
+		}
+	}
+	return progress
+}
+
+// Percent は完了した項目の割合 (0〜100) を返す。項目がない場合は 0。
+func (p ChecklistProgress) Percent() int {
+	if p.Total == 0 {
+		return 0
+	}
+	return p.Done * 100 / p.Total
+}
+
+// ParseChecklistTitles は1行に1項目のテキストから項目のタイトルを取り出す。空行は無視する。
+func ParseChecklistTitles(text string) []string {
+	var titles []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			titles = append(titles, line)
+		}
+	}
+	return titles
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// グループでの役割
+const (
+	MembershipRoleTeacher = "teacher" // 課題を配布し、提出状況を確認する
+	MembershipRoleStudent = "student" // 配布された課題を受け取る
+)
+
+// Group はクラスなどのグループ。教師が参加コードを配り、生徒はコードを入力して参加する。
+type Group struct {
+	ID          uint   `gorm:"primarykey" json:"id"`
+	Name        string `gorm:"not null;size:100" json:"name"`
+	Description string `gorm:"type:text" json:"description"`
+	JoinCode    string `gorm:"not null;size:16;uniqueIndex" json:"join_code,omitempty"` // 教師にだけ返す
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Membership はユーザーのグループへの参加。
+type Membership struct {
+	ID      uint   `gorm:"primarykey" json:"id"`
+	GroupID uint   `gorm:"not null;uniqueIndex:idx_memberships_group_user" json:"group_id"`
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_memberships_group_user;index" json:"user_id"`
+	Role    string `gorm:"not null;size:20;default:student" json:"role"` // MembershipRoleTeacher・MembershipRoleStudent
+
+	CreatedAt time.Time `json:"created_at"`
+
+	Group *Group `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+	User  *User  `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (m *Membership) IsTeacher() bool {
+	return m.Role == MembershipRoleTeacher
+}
+
+// GroupAssignment は教師がグループに配布した課題（配布元）。生徒ごとの課題は Assignment.GroupAssignmentID でこれを参照する。
+type GroupAssignment struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	GroupID     uint      `gorm:"not null;index" json:"group_id"`
+	CreatedByID uint      `gorm:"not null" json:"created_by_id"`
+	Title       string    `gorm:"not null" json:"title"`
+	Description string    `json:"description"`
+	Subject     string    `json:"subject"` // 生徒ごとに同じ名前の科目を参照させる
+	Priority    string    `gorm:"not null;default:medium" json:"priority"`
+	DueDate     time.Time `gorm:"not null" json:"due_date"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
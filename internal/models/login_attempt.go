@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ログイン試行の結果
+const (
+	LoginResultSuccess  = "success"  // ログイン成功（連続失敗回数をリセットする）
+	LoginResultFailure  = "failure"  // パスワード・認証コードの誤り（連続失敗回数に数える）
+	LoginResultBlocked  = "blocked"  // ロック中・待ち時間中・CAPTCHA の失敗で検証せずに拒否した
+	LoginResultUnlocked = "unlocked" // 管理者がロックを解除した（連続失敗回数をリセットする）
+)
+
+// LoginAttempt はログインの試行記録。アカウントごと・IP ごとの失敗回数の判定と監査に使う。
+// 存在しないメールアドレスへの試行も記録し、アカウントの有無にかかわらず同じようにロックする。
+type LoginAttempt struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    *uint     `gorm:"index" json:"user_id,omitempty"`
+	Email     string    `gorm:"not null;size:255;index:idx_login_attempts_email_created" json:"email"` // 小文字に正規化
+	IP        string    `gorm:"size:64;index:idx_login_attempts_ip_created" json:"ip"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	Method    string    `gorm:"not null;size:20" json:"method"` // password, totp, recovery_code, passkey, oidc, admin
+	Result    string    `gorm:"not null;size:20" json:"result"`
+	Reason    string    `gorm:"size:50" json:"reason,omitempty"` // invalid_credentials, invalid_code, locked, throttled, captcha
+	CreatedAt time.Time `gorm:"index:idx_login_attempts_email_created;index:idx_login_attempts_ip_created" json:"created_at"`
+}
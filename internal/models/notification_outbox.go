@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	NotificationKindReminder          = "reminder"
+	NotificationKindUrgentReminder    = "urgent_reminder"
+	NotificationKindAssignmentCreated = "assignment_created"
+)
+
+const (
+	NotificationStatusPending = "pending"
+	NotificationStatusSent    = "sent"
+	// NotificationStatusDead は再送回数の上限に達したか、送信できない状態になった通知（デッドレター）
+	NotificationStatusDead = "dead"
+)
+
+// NotificationOutbox は送信待ち・送信済みの通知。チャネルごとに1行作成する。
+type NotificationOutbox struct {
+	ID           uint   `gorm:"primarykey" json:"id"`
+	UserID       uint   `gorm:"not null;index" json:"user_id"`
+	AssignmentID *uint  `gorm:"index" json:"assignment_id,omitempty"`
+	Kind         string `gorm:"not null;size:32" json:"kind"`
+	Channel      string `gorm:"not null;size:32" json:"channel"`
+	Subject      string `json:"subject"`
+	Text         string `gorm:"type:text" json:"text"`
+	HTML         string `gorm:"type:text" json:"-"`
+	Status       string `gorm:"not null;default:pending;index" json:"status"`
+	Attempts     int    `gorm:"default:0" json:"attempts"`
+	// 次回送信日時。送信中はリース期限として使う
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	Error         string     `gorm:"type:text" json:"error"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (NotificationOutbox) TableName() string {
+	return "notification_outbox"
+}
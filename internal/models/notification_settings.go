@@ -10,7 +10,11 @@ type UserNotificationSettings struct {
 	ID              uint   `gorm:"primarykey" json:"id"`
 	UserID          uint   `gorm:"uniqueIndex;not null" json:"user_id"`
 	TelegramEnabled bool   `gorm:"default:false" json:"telegram_enabled"`
-	TelegramChatID  string `json:"telegram_chat_id"`
+	TelegramChatID  string `gorm:"index" json:"telegram_chat_id"`
+	EmailEnabled    bool   `gorm:"default:false" json:"email_enabled"`
+	// Telegram ボット連携用のワンタイムコード（SHA-256）と有効期限
+	TelegramLinkCodeHash      string     `gorm:"index" json:"-"`
+	TelegramLinkCodeExpiresAt *time.Time `json:"-"`
 
 	NotifyOnCreate bool           `gorm:"default:true" json:"notify_on_create"`
 	CreatedAt      time.Time      `json:"created_at"`
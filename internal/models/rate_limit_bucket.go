@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RateLimitBucket はレート制限のキーごとの状態。複数台のサーバーで制限を共有する場合はデータベースに保存する。
+// 使うフィールドはアルゴリズムによって異なる。
+type RateLimitBucket struct {
+	ID        string    `gorm:"primarykey;size:191"` // ポリシー名・単位・値（例: "api:api_key:12"）
+	Value     float64   // トークンバケット: 残りのトークン / スライディングウィンドウ: 現在の窓のリクエスト数
+	Previous  float64   // スライディングウィンドウ: 直前の窓のリクエスト数
+	Timestamp time.Time // トークンバケット: 最後に補充した日時 / スライディングウィンドウ: 現在の窓の開始日時
+	ExpiresAt time.Time `gorm:"index"` // これ以降は状態を捨てても結果が変わらない
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RecoveryCode は認証アプリを紛失したときに 2 段階認証の代わりに使う使い捨てのコード。平文は発行時にだけ表示する。
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null;size:64" json:"-"` // SHA-256
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
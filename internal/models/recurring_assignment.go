@@ -1,8 +1,11 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
+	"homework-manager/internal/rrule"
+
 	"gorm.io/gorm"
 )
 
@@ -11,6 +14,7 @@ const (
 	RecurrenceDaily   = "daily"
 	RecurrenceWeekly  = "weekly"
 	RecurrenceMonthly = "monthly"
+	RecurrenceCustom  = "custom"
 )
 
 const (
@@ -30,7 +34,8 @@ type RecurringAssignment struct {
 	UserID      uint   `gorm:"not null;index" json:"user_id"`
 	Title       string `gorm:"not null" json:"title"`
 	Description string `json:"description"`
-	Subject     string `json:"subject"`
+	SubjectID   *uint  `gorm:"index" json:"subject_id,omitempty"`
+	Subject     string `json:"subject"` // SubjectID の科目の名前
 	Priority    string `gorm:"not null;default:medium" json:"priority"`
 
 	RecurrenceType     string `gorm:"not null;default:none" json:"recurrence_type"`
@@ -39,12 +44,27 @@ type RecurringAssignment struct {
 	RecurrenceDay      *int   `json:"recurrence_day,omitempty"`
 	DueTime            string `gorm:"not null" json:"due_time"`
 
+	// 繰り返し条件の実体 (RFC 5545)。RecurrenceType 等は入力フォーム向けの補助情報
+	RRule     string     `gorm:"column:rrule;type:text" json:"rrule"`
+	ExDates   string     `gorm:"type:text" json:"exdates,omitempty"`
+	StartDate *time.Time `json:"start_date,omitempty"`
+
+	// インポート元の UID（再インポート時の重複判定に使用）
+	ExternalUID string `gorm:"size:255;index" json:"external_uid,omitempty"`
+
 	EndType        string     `gorm:"not null;default:never" json:"end_type"`
 	EndCount       *int       `json:"end_count,omitempty"`
 	EndDate        *time.Time `json:"end_date,omitempty"`
 	GeneratedCount int        `gorm:"default:0" json:"generated_count"`
 	EditBehavior   string     `gorm:"not null;default:this_only" json:"edit_behavior"`
 
+	// 生成する課題にコピーするチェックリスト（1行に1項目）
+	Checklist             string `gorm:"type:text" json:"checklist,omitempty"`
+	ChecklistAutoComplete bool   `gorm:"default:false" json:"checklist_auto_complete"`
+
+	// 生成する課題に付けるタグ（カンマ区切り）
+	Tags string `gorm:"type:text" json:"tags,omitempty"`
+
 	ReminderEnabled       bool           `gorm:"default:false" json:"reminder_enabled"`
 	ReminderOffset        *int           `json:"reminder_offset,omitempty"`
 	UrgentReminderEnabled bool           `gorm:"default:true" json:"urgent_reminder_enabled"`
@@ -57,6 +77,33 @@ type RecurringAssignment struct {
 	Assignments []Assignment `gorm:"foreignKey:RecurringAssignmentID" json:"assignments,omitempty"`
 }
 
+// UID はカレンダー出力・エクスポートで使う識別子を返す。インポートした設定は元の UID を引き継ぐ。
+func (r *RecurringAssignment) UID() string {
+	if r.ExternalUID != "" {
+		return r.ExternalUID
+	}
+	return fmt.Sprintf("recurring-%d@homework-manager", r.ID)
+}
+
+// ParseRecurringUID は UID() が生成した UID から繰り返し設定IDを取り出す。
+func ParseRecurringUID(uid string) (uint, bool) {
+	var id uint
+	if _, err := fmt.Sscanf(uid, "recurring-%d@homework-manager", &id); err != nil || id == 0 {
+		return 0, false
+	}
+	return id, uid == fmt.Sprintf("recurring-%d@homework-manager", id)
+}
+
+// ChecklistTitles は生成する課題にコピーするチェックリストの項目を返す。
+func (r *RecurringAssignment) ChecklistTitles() []string {
+	return ParseChecklistTitles(r.Checklist)
+}
+
+// TagNames は生成する課題に付けるタグの名前を返す。
+func (r *RecurringAssignment) TagNames() []string {
+	return ParseTagNames(r.Tags)
+}
+
 func (r *RecurringAssignment) ShouldGenerateNext() bool {
 	if !r.IsActive || r.RecurrenceType == RecurrenceNone {
 		return false
@@ -76,27 +123,89 @@ func (r *RecurringAssignment) ShouldGenerateNext() bool {
 	return true
 }
 
-func (r *RecurringAssignment) CalculateNextDueDate(lastDueDate time.Time) time.Time {
-	var nextDate time.Time
+// Rule は保存されている RRULE を返す。未設定（移行前）の行は旧フィールドから組み立てる。
+func (r *RecurringAssignment) Rule() (*rrule.Rule, error) {
+	if r.RRule != "" {
+		return rrule.Parse(r.RRule)
+	}
+	return r.LegacyRule(), nil
+}
+
+// LegacyRule は RecurrenceType / RecurrenceWeekday / RecurrenceDay / EndType から RRULE を組み立てる。
+func (r *RecurringAssignment) LegacyRule() *rrule.Rule {
+	rule := &rrule.Rule{Interval: r.RecurrenceInterval, WeekStart: time.Monday}
+	if rule.Interval < 1 {
+		rule.Interval = 1
+	}
 
 	switch r.RecurrenceType {
-	case RecurrenceDaily:
-		nextDate = lastDueDate.AddDate(0, 0, r.RecurrenceInterval)
 	case RecurrenceWeekly:
-		nextDate = lastDueDate.AddDate(0, 0, 7*r.RecurrenceInterval)
+		rule.Freq = rrule.Weekly
+		if r.RecurrenceWeekday != nil && *r.RecurrenceWeekday >= 0 && *r.RecurrenceWeekday <= 6 {
+			rule.ByDay = []rrule.Weekday{{Day: time.Weekday(*r.RecurrenceWeekday)}}
+		}
 	case RecurrenceMonthly:
-		nextDate = lastDueDate.AddDate(0, r.RecurrenceInterval, 0)
-		if r.RecurrenceDay != nil {
-			day := *r.RecurrenceDay
-			lastDayOfMonth := time.Date(nextDate.Year(), nextDate.Month()+1, 0, 0, 0, 0, 0, nextDate.Location()).Day()
-			if day > lastDayOfMonth {
-				day = lastDayOfMonth
-			}
-			nextDate = time.Date(nextDate.Year(), nextDate.Month(), day, nextDate.Hour(), nextDate.Minute(), 0, 0, nextDate.Location())
+		rule.Freq = rrule.Monthly
+		if r.RecurrenceDay != nil && *r.RecurrenceDay >= 1 && *r.RecurrenceDay <= 31 {
+			rule.ByMonthDay, rule.BySetPos = rrule.ClampedMonthDay(*r.RecurrenceDay)
 		}
 	default:
+		rule.Freq = rrule.Daily
+	}
+
+	switch r.EndType {
+	case EndTypeCount:
+		if r.EndCount != nil && *r.EndCount > 0 {
+			rule.Count = *r.EndCount
+		}
+	case EndTypeDate:
+		if r.EndDate != nil {
+			rule.Until = EndOfDay(*r.EndDate)
+		}
+	}
+
+	return rule
+}
+
+// RuleSet は EXDATE を含めた展開用の集合を返す。StartDate が未設定の場合は fallbackStart を起点にする。
+func (r *RecurringAssignment) RuleSet(fallbackStart time.Time) (*rrule.Set, error) {
+	rule, err := r.Rule()
+	if err != nil {
+		return nil, err
+	}
+
+	dtstart := fallbackStart
+	if r.StartDate != nil {
+		dtstart = r.StartDate.In(fallbackStart.Location())
+	}
+
+	exdates, err := rrule.ParseExDates(r.ExDates, dtstart.Location())
+	if err != nil {
+		return nil, err
+	}
+
+	return rrule.NewSet(rule, dtstart, exdates), nil
+}
+
+// CalculateNextDueDate は lastDueDate の翌日以降で最初の発生日時を返す。次回がない場合は lastDueDate をそのまま返す。
+// 時刻は StartDate の時刻になるため、呼び出し側で DueTime を適用すること。
+func (r *RecurringAssignment) CalculateNextDueDate(lastDueDate time.Time) time.Time {
+	if r.RecurrenceType == RecurrenceNone {
+		return lastDueDate
+	}
+
+	set, err := r.RuleSet(lastDueDate)
+	if err != nil {
+		return lastDueDate
+	}
+
+	next, ok := set.After(EndOfDay(lastDueDate))
+	if !ok {
 		return lastDueDate
 	}
+	return next
+}
 
-	return nextDate
+func EndOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 0, t.Location())
 }
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestCalculateNextDueDate(t *testing.T) {
+	at := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		recurring RecurringAssignment
+		last      time.Time
+		want      time.Time
+	}{
+		{
+			name:      "繰り返しなしは同じ日時",
+			recurring: RecurringAssignment{RecurrenceType: RecurrenceNone},
+			last:      at(2026, 4, 1, 9),
+			want:      at(2026, 4, 1, 9),
+		},
+		{
+			name:      "毎日",
+			recurring: RecurringAssignment{RecurrenceType: RecurrenceDaily, RecurrenceInterval: 1},
+			last:      at(2026, 4, 1, 9),
+			want:      at(2026, 4, 2, 9),
+		},
+		{
+			name:      "3日ごと",
+			recurring: RecurringAssignment{RecurrenceType: RecurrenceDaily, RecurrenceInterval: 3},
+			last:      at(2026, 4, 1, 9),
+			want:      at(2026, 4, 4, 9),
+		},
+		{
+			name:      "間隔 0 は 1 として扱う",
+			recurring: RecurringAssignment{RecurrenceType: RecurrenceDaily},
+			last:      at(2026, 4, 1, 9),
+			want:      at(2026, 4, 2, 9),
+		},
+		{
+			name:      "毎週 同じ曜日",
+			recurring: RecurringAssignment{RecurrenceType: RecurrenceWeekly, RecurrenceInterval: 1, RecurrenceWeekday: intPtr(int(time.Wednesday))},
+			last:      at(2026, 4, 1, 9), // 水曜日
+			want:      at(2026, 4, 8, 9),
+		},
+		{
+			name:      "隔週",
+			recurring: RecurringAssignment{RecurrenceType: RecurrenceWeekly, RecurrenceInterval: 2, RecurrenceWeekday: intPtr(int(time.Wednesday))},
+			last:      at(2026, 4, 1, 9),
+			want:      at(2026, 4, 15, 9),
+		},
+		{
+			name:      "毎月 15日",
+			recurring: RecurringAssignment{RecurrenceType: RecurrenceMonthly, RecurrenceInterval: 1, RecurrenceDay: intPtr(15)},
+			last:      at(2026, 4, 15, 9),
+			want:      at(2026, 5, 15, 9),
+		},
+		{
+			name:      "毎月 31日は短い月の末日に丸める",
+			recurring: RecurringAssignment{RecurrenceType: RecurrenceMonthly, RecurrenceInterval: 1, RecurrenceDay: intPtr(31)},
+			last:      at(2026, 1, 31, 9),
+			want:      at(2026, 2, 28, 9),
+		},
+		{
+			name:      "RRULE の複数曜日",
+			recurring: RecurringAssignment{RecurrenceType: RecurrenceCustom, RRule: "FREQ=WEEKLY;BYDAY=MO,TH"},
+			last:      at(2026, 4, 6, 9), // 月曜日
+			want:      at(2026, 4, 9, 9),
+		},
+		{
+			name: "EXDATE の日は飛ばす",
+			recurring: RecurringAssignment{
+				RecurrenceType: RecurrenceCustom,
+				RRule:          "FREQ=DAILY",
+				ExDates:        "20260402",
+			},
+			last: at(2026, 4, 1, 9),
+			want: at(2026, 4, 3, 9),
+		},
+		{
+			name: "時刻は StartDate に合わせる",
+			recurring: RecurringAssignment{
+				RecurrenceType: RecurrenceDaily,
+				RRule:          "FREQ=DAILY",
+				StartDate:      timePtr(at(2026, 3, 1, 18)),
+			},
+			last: at(2026, 4, 1, 9),
+			want: at(2026, 4, 2, 18),
+		},
+		{
+			name: "回数の上限に達したら同じ日時",
+			recurring: RecurringAssignment{
+				RecurrenceType: RecurrenceDaily,
+				RRule:          "FREQ=DAILY;COUNT=3",
+				StartDate:      timePtr(at(2026, 4, 1, 9)),
+			},
+			last: at(2026, 4, 3, 9),
+			want: at(2026, 4, 3, 9),
+		},
+		{
+			name: "終了日を過ぎたら同じ日時",
+			recurring: RecurringAssignment{
+				RecurrenceType: RecurrenceWeekly,
+				EndType:        EndTypeDate,
+				EndDate:        timePtr(at(2026, 4, 10, 0)),
+			},
+			last: at(2026, 4, 8, 9),
+			want: at(2026, 4, 8, 9),
+		},
+		{
+			name:      "不正な RRULE は同じ日時",
+			recurring: RecurringAssignment{RecurrenceType: RecurrenceCustom, RRule: "FREQ=SOMETIMES"},
+			last:      at(2026, 4, 1, 9),
+			want:      at(2026, 4, 1, 9),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.recurring.CalculateNextDueDate(tt.last)
+			if !got.Equal(tt.want) {
+				t.Errorf("CalculateNextDueDate(%v) = %v, want %v", tt.last, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldGenerateNext(t *testing.T) {
+	tests := []struct {
+		name      string
+		recurring RecurringAssignment
+		want      bool
+	}{
+		{"有効", RecurringAssignment{IsActive: true, RecurrenceType: RecurrenceDaily, EndType: EndTypeNever}, true},
+		{"停止中", RecurringAssignment{IsActive: false, RecurrenceType: RecurrenceDaily}, false},
+		{"繰り返しなし", RecurringAssignment{IsActive: true, RecurrenceType: RecurrenceNone}, false},
+		{"回数の上限", RecurringAssignment{IsActive: true, RecurrenceType: RecurrenceDaily, EndType: EndTypeCount, EndCount: intPtr(3), GeneratedCount: 3}, false},
+		{"回数の上限前", RecurringAssignment{IsActive: true, RecurrenceType: RecurrenceDaily, EndType: EndTypeCount, EndCount: intPtr(3), GeneratedCount: 2}, true},
+		{"終了日を過ぎた", RecurringAssignment{IsActive: true, RecurrenceType: RecurrenceDaily, EndType: EndTypeDate, EndDate: timePtr(time.Now().Add(-time.Hour))}, false},
+		{"終了日前", RecurringAssignment{IsActive: true, RecurrenceType: RecurrenceDaily, EndType: EndTypeDate, EndDate: timePtr(time.Now().Add(time.Hour))}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.recurring.ShouldGenerateNext(); got != tt.want {
+				t.Errorf("ShouldGenerateNext() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
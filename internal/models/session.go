@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session はサーバー側に保存するログインセッション。Cookie にはランダムな ID だけを入れ、
+// ID はその SHA-256（16 進数）で保存するため、データベースが漏れてもセッションを乗っ取れない。
+type Session struct {
+	ID         string    `gorm:"primarykey;size:64" json:"-"`
+	UserID     uint      `gorm:"not null;default:0;index" json:"user_id"` // ログイン前のセッションは 0
+	Data       string    `gorm:"type:text" json:"-"`                      // 署名付きでエンコードしたセッションの値
+	UserAgent  string    `gorm:"size:255" json:"user_agent"`
+	IP         string    `gorm:"size:64" json:"ip"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// DefaultSubjectColor は色を指定していない科目の色。
+const DefaultSubjectColor = "#6c757d"
+
+// Subject はユーザーごとの科目。課題・繰り返し設定は SubjectID で参照する。
+type Subject struct {
+	ID      uint   `gorm:"primarykey" json:"id"`
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_subjects_user_name" json:"user_id"`
+	Name    string `gorm:"not null;size:100;uniqueIndex:idx_subjects_user_name" json:"name"`
+	Color   string `gorm:"not null;size:7" json:"color"` // #rrggbb
+	Teacher string `gorm:"size:100" json:"teacher"`
+	Room    string `gorm:"size:100" json:"room"`
+
+	// 課題を作るときの重要度の初期値（空の場合は指定なし）
+	DefaultPriority string `gorm:"size:20" json:"default_priority"`
+	// 課題を作るときに期限の何分前にリマインダーを設定するか（nil の場合は設定しない）
+	DefaultReminderOffset *int `json:"default_reminder_offset"`
+
+	// アーカイブした科目の課題は統計・カレンダーに含めない
+	IsArchived bool `gorm:"default:false;index" json:"is_archived"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DefaultReminderAt は期限が dueDate の課題に設定する既定のリマインダー日時を返す。設定がない場合は nil を返す。
+func (s *Subject) DefaultReminderAt(dueDate time.Time) *time.Time {
+	if s == nil || s.DefaultReminderOffset == nil {
+		return nil
+	}
+	reminderAt := dueDate.Add(-time.Duration(*s.DefaultReminderOffset) * time.Minute)
+	return &reminderAt
+}
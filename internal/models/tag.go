@@ -0,0 +1,38 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Tag はユーザーごとのタグ（「テスト」「グループワーク」など）。課題とは多対多で assignment_tags で結び付ける。
+type Tag struct {
+	ID     uint   `gorm:"primarykey" json:"id"`
+	UserID uint   `gorm:"not null;uniqueIndex:idx_tags_user_name" json:"user_id"`
+	Name   string `gorm:"not null;size:50;uniqueIndex:idx_tags_user_name" json:"name"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ParseTagNames はカンマ（「、」も可）区切りのテキストからタグ名を取り出す。空の名前と重複は除く。
+func ParseTagNames(text string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '、' }) {
+		if name = strings.TrimSpace(name); name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// TagNames はタグの名前を返す。
+func TagNames(tags []Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
@@ -3,20 +3,27 @@ package models
 import (
 	"time"
 
+	"homework-manager/internal/timezone"
+
 	"gorm.io/gorm"
 )
 
 type User struct {
-	ID           uint           `gorm:"primarykey" json:"id"`
-	Email        string         `gorm:"uniqueIndex;not null;size:255" json:"email"`
-	PasswordHash string         `gorm:"not null" json:"-"`
-	Name         string         `gorm:"not null" json:"name"`
-	Role         string         `gorm:"not null;default:user" json:"role"` // "admin" or "user"
-	TOTPSecret   string         `gorm:"size:100" json:"-"`
-	TOTPEnabled  bool           `gorm:"default:false" json:"totp_enabled"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uint           `gorm:"primarykey" json:"id"`
+	Email             string         `gorm:"uniqueIndex;not null;size:255" json:"email"`
+	PasswordHash      string         `gorm:"not null" json:"-"` // OIDC で自動作成したアカウントは空（パスワードではログインできない）
+	Name              string         `gorm:"not null" json:"name"`
+	Role              string         `gorm:"not null;default:user" json:"role"` // "admin", "teacher" or "user"
+	TOTPSecret        string         `gorm:"size:100" json:"-"`
+	TOTPEnabled       bool           `gorm:"default:false" json:"totp_enabled"`
+	TOTPLastStep      int64          `gorm:"not null;default:0" json:"-"`                 // 最後に受け付けた TOTP のタイムステップ。同じコードの再利用を防ぐ
+	CalendarTokenHash string         `gorm:"size:64;index" json:"-"`                      // カレンダー購読トークンの SHA-256。トークン本体は発行時にだけ表示する
+	Timezone          string         `gorm:"size:64" json:"timezone"`                     // IANA タイムゾーン名。空ならサーバーのタイムゾーン
+	OIDCSubject       string         `gorm:"column:oidc_subject;size:255;index" json:"-"` // OpenID プロバイダーの sub。空なら未連携
+	EmailVerifiedAt   *time.Time     `json:"email_verified_at,omitempty"`                 // メールアドレスを確認した日時。未確認なら nil
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
 
 	Assignments []Assignment `gorm:"foreignKey:UserID" json:"assignments,omitempty"`
 }
@@ -25,6 +32,31 @@ func (u *User) IsAdmin() bool {
 	return u.Role == "admin"
 }
 
+// CanCreateGroups はグループを作成して課題を配布できるか（教師・管理者）を返す。
+func (u *User) CanCreateGroups() bool {
+	return u.Role == "teacher" || u.Role == "admin"
+}
+
+// HasPassword はパスワードでログインできるアカウントかを返す。
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != ""
+}
+
+// EmailVerified はメールアドレスの確認が済んでいるかを返す。
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// OIDCLinked は OpenID プロバイダーのアカウントと連携しているかを返す。
+func (u *User) OIDCLinked() bool {
+	return u.OIDCSubject != ""
+}
+
 func (u *User) GetID() uint {
 	return u.ID
 }
+
+// Location はユーザーのタイムゾーン。日付の区切り、繰り返しの展開、表示はこのタイムゾーンで行う。
+func (u *User) Location() *time.Location {
+	return timezone.Load(u.Timezone)
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// UserToken の用途
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// UserToken はメールで送る使い捨てのトークン（パスワード再設定、メールアドレス確認）。平文は保存せずハッシュで照合する。
+type UserToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Purpose   string     `gorm:"not null;size:32;index" json:"purpose"`
+	TokenHash string     `gorm:"not null;uniqueIndex;size:64" json:"-"` // SHA-256
+	Email     string     `gorm:"not null;size:255" json:"-"`            // 発行時のメールアドレス。変更後は無効
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
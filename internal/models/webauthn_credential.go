@@ -0,0 +1,26 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// WebAuthnCredential はユーザーが登録したパスキー / セキュリティキー。1 人のユーザーが複数登録できる。
+type WebAuthnCredential struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	Name         string     `gorm:"not null;size:100" json:"name"`
+	CredentialID string     `gorm:"not null;uniqueIndex;size:255" json:"-"` // base64url
+	PublicKey    []byte     `gorm:"not null" json:"-"`                      // COSE_Key
+	SignCount    uint32     `gorm:"not null;default:0" json:"-"`
+	Transports   string     `gorm:"size:255" json:"transports,omitempty"` // スペース区切り（usb、nfc、ble、internal、hybrid）
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (c *WebAuthnCredential) TransportList() []string {
+	return strings.Fields(c.Transports)
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookEventAssignmentCreated     = "assignment.created"
+	WebhookEventAssignmentUpdated     = "assignment.updated"
+	WebhookEventAssignmentCompleted   = "assignment.completed"
+	WebhookEventAssignmentUncompleted = "assignment.uncompleted"
+	WebhookEventAssignmentDeleted     = "assignment.deleted"
+	WebhookEventAssignmentOverdue     = "assignment.overdue"
+	WebhookEventRecurringGenerated    = "recurring.generated"
+)
+
+// WebhookEvents は購読できるイベントの一覧（表示順）。
+var WebhookEvents = []string{
+	WebhookEventAssignmentCreated,
+	WebhookEventAssignmentUpdated,
+	WebhookEventAssignmentCompleted,
+	WebhookEventAssignmentUncompleted,
+	WebhookEventAssignmentDeleted,
+	WebhookEventAssignmentOverdue,
+	WebhookEventRecurringGenerated,
+}
+
+const (
+	WebhookDeliveryPending = "pending"
+	WebhookDeliverySuccess = "success"
+	WebhookDeliveryFailed  = "failed"
+)
+
+type Webhook struct {
+	ID     uint   `gorm:"primarykey" json:"id"`
+	UserID uint   `gorm:"not null;index" json:"user_id"`
+	Name   string `gorm:"not null" json:"name"`
+	URL    string `gorm:"not null;size:2048" json:"url"`
+	// 署名 (HMAC-SHA256) の鍵
+	Secret string `gorm:"not null;size:128" json:"-"`
+	// 購読するイベント（カンマ区切り）。空の場合は全イベント
+	Events    string         `json:"events"`
+	IsActive  bool           `gorm:"default:true" json:"is_active"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	User *User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (w *Webhook) EventList() []string {
+	if w.Events == "" {
+		return nil
+	}
+	return strings.Split(w.Events, ",")
+}
+
+func (w *Webhook) Subscribes(event string) bool {
+	if w.Events == "" {
+		return true
+	}
+	for _, e := range w.EventList() {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	WebhookID      uint       `gorm:"not null;index" json:"webhook_id"`
+	Event          string     `gorm:"not null;size:64" json:"event"`
+	Payload        string     `gorm:"type:text" json:"payload"`
+	Status         string     `gorm:"not null;default:pending;index" json:"status"`
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseStatus int        `json:"response_status"`
+	ResponseBody   string     `gorm:"type:text" json:"response_body"`
+	Error          string     `gorm:"type:text" json:"error"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	Webhook *Webhook `gorm:"foreignKey:WebhookID" json:"-"`
+}
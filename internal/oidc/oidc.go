@@ -0,0 +1,587 @@
+// Package oidc は OpenID Connect の認可コードフロー（PKCE 付き）のクライアント。
+// discovery、トークン交換、ID トークンの署名・クレームの検証、UserInfo の取得を行う。
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"homework-manager/internal/config"
+)
+
+// clockSkew は exp / iat の検証で許容する IdP との時刻のずれ。
+const clockSkew = 2 * time.Minute
+
+var (
+	ErrNotConfigured     = errors.New("oidc provider is not configured")
+	ErrInvalidIDToken    = errors.New("invalid id token")
+	ErrNonceMismatch     = errors.New("id token nonce does not match")
+	ErrMissingIDToken    = errors.New("token response does not contain an id_token")
+	ErrUnknownSigningKey = errors.New("id token is signed with an unknown key")
+)
+
+// TokenError はトークンエンドポイントや UserInfo エンドポイントがエラーを返したときのエラー。
+type TokenError struct {
+	Endpoint    string
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+func (e *TokenError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oidc %s failed (status %d): %s: %s", e.Endpoint, e.StatusCode, e.Code, e.Description)
+	}
+	return fmt.Sprintf("oidc %s failed (status %d): %s", e.Endpoint, e.StatusCode, e.Code)
+}
+
+type discoveryDocument struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserInfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// TokenResponse はトークンエンドポイントの応答。
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Claims は検証済みの ID トークン（と UserInfo）のクレーム。
+type Claims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Nonce         string
+	// Raw はすべてのクレーム。ロールの判定など任意のクレームの参照に使う
+	Raw map[string]interface{}
+}
+
+// StringValues は name のクレームを文字列の一覧として返す。値は文字列、文字列の配列、
+// スペース区切りの文字列のいずれでもよい。name に "." を含む場合はネストしたオブジェクトをたどる
+// （例: Keycloak の "realm_access.roles"）。
+func (c *Claims) StringValues(name string) []string {
+	var value interface{} = c.Raw
+	for _, part := range strings.Split(name, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = obj[part]
+	}
+
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// Provider は 1 つの OpenID プロバイダーのクライアント。discovery と JWKS は初回利用時に取得してキャッシュする。
+type Provider struct {
+	cfg  config.OIDCConfig
+	http *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]crypto.PublicKey
+	keysAt    time.Time
+}
+
+// NewProvider は cfg.Issuer の OpenID プロバイダーを使うクライアントを返す。
+// テストでは Issuer にローカルのモック IdP（oidctest パッケージ）を指定する。
+func NewProvider(cfg config.OIDCConfig) *Provider {
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	return &Provider{
+		cfg:  cfg,
+		http: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *Provider) Configured() bool {
+	return p.cfg.Issuer != "" && p.cfg.ClientID != "" && p.cfg.RedirectURL != ""
+}
+
+// RandomString は state、nonce、PKCE の code_verifier に使う URL セーフな乱数文字列を返す。
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge は code_verifier から S256 の code_challenge を求める。
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL はユーザーをリダイレクトする認可エンドポイントの URL を返す。
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.cfg.ClientID)
+	params.Set("redirect_uri", p.cfg.RedirectURL)
+	params.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(verifier))
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return doc.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange は認可コードをトークンと交換する。
+func (p *Provider) Exchange(code, verifier string) (*TokenResponse, error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", p.cfg.ClientID)
+
+	useBasicAuth := p.cfg.ClientSecret != "" && !onlySupportsPost(doc.TokenAuthMethods)
+	if p.cfg.ClientSecret != "" && !useBasicAuth {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if useBasicAuth {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newTokenError("token", resp.StatusCode, body)
+	}
+
+	var token TokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("oidc token: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, ErrMissingIDToken
+	}
+	return &token, nil
+}
+
+// VerifyIDToken は ID トークンの署名、iss、aud、有効期限、nonce を検証してクレームを返す。
+func (p *Provider) VerifyIDToken(rawToken, nonce string) (*Claims, error) {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidIDToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	key, err := p.signingKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	var raw map[string]interface{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	claims := claimsFromRaw(raw)
+
+	if claims.Issuer != doc.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	}
+	audiences := claims.StringValues("aud")
+	if !contains(audiences, p.cfg.ClientID) {
+		return nil, fmt.Errorf("%w: audience does not contain the client id", ErrInvalidIDToken)
+	}
+	if azp, ok := raw["azp"].(string); ok && len(audiences) > 1 && azp != p.cfg.ClientID {
+		return nil, fmt.Errorf("%w: unexpected authorized party %q", ErrInvalidIDToken, azp)
+	}
+	now := time.Now()
+	exp, ok := numericDate(raw["exp"])
+	if !ok || now.After(exp.Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: token is expired", ErrInvalidIDToken)
+	}
+	if iat, ok := numericDate(raw["iat"]); ok && iat.After(now.Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: token is issued in the future", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	if claims.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	return claims, nil
+}
+
+// UserInfo は UserInfo エンドポイントのクレームを claims に取り込む。ID トークンにない値だけを補う。
+// UserInfo の sub が ID トークンと異なる場合はエラーにする。
+func (p *Provider) UserInfo(accessToken string, claims *Claims) error {
+	doc, err := p.getDiscovery()
+	if err != nil {
+		return err
+	}
+	if doc.UserInfoEndpoint == "" || accessToken == "" {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, doc.UserInfoEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newTokenError("userinfo", resp.StatusCode, body)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return fmt.Errorf("oidc userinfo: %w", err)
+	}
+	if sub, _ := raw["sub"].(string); sub != claims.Subject {
+		return fmt.Errorf("oidc userinfo: subject does not match the id token")
+	}
+
+	for name, value := range raw {
+		if _, exists := claims.Raw[name]; !exists {
+			claims.Raw[name] = value
+		}
+	}
+	merged := claimsFromRaw(claims.Raw)
+	merged.Nonce = claims.Nonce
+	*claims = *merged
+	return nil
+}
+
+func (p *Provider) getDiscovery() (*discoveryDocument, error) {
+	if !p.Configured() {
+		return nil, ErrNotConfigured
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var doc discoveryDocument
+	if err := p.getJSON(p.cfg.Issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match the configured issuer", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: required endpoints are missing")
+	}
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+// signingKey は kid の公開鍵を返す。見つからない場合は鍵のローテーションを考慮して JWKS を取得し直す
+// （連続した再取得は 1 分に 1 回まで）。
+func (p *Provider) signingKey(kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if p.keys != nil && time.Since(p.keysAt) < time.Minute {
+		return nil, ErrUnknownSigningKey
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(p.discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	p.keys = keys
+	p.keysAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, ErrUnknownSigningKey
+}
+
+// lookupKey は kid の鍵を探す。kid が空の場合は鍵が 1 つだけのときに限りその鍵を使う。
+func (p *Provider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(endpoint string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, endpoint)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		// "none" や HS256 などは受け付けない
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %q does not match an RSA key", alg)
+		}
+		return rsa.VerifyPKCS1v15(k, hash, digest, signature)
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("algorithm %q does not match an EC key", alg)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("signature verification failed")
+		}
+		return nil
+	}
+	return errors.New("unsupported key")
+}
+
+func claimsFromRaw(raw map[string]interface{}) *Claims {
+	claims := &Claims{Raw: raw}
+	claims.Issuer, _ = raw["iss"].(string)
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Email, _ = raw["email"].(string)
+	claims.Name, _ = raw["name"].(string)
+	claims.Nonce, _ = raw["nonce"].(string)
+	if claims.Name == "" {
+		claims.Name, _ = raw["preferred_username"].(string)
+	}
+	// email_verified を文字列で返すプロバイダーもある
+	switch v := raw["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = v
+	case string:
+		claims.EmailVerified = v == "true"
+	}
+	return claims
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func numericDate(v interface{}) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+func newTokenError(endpoint string, status int, body []byte) error {
+	var payload struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	json.Unmarshal(body, &payload)
+	if payload.Error == "" {
+		payload.Error = http.StatusText(status)
+	}
+	return &TokenError{Endpoint: endpoint, StatusCode: status, Code: payload.Error, Description: payload.ErrorDescription}
+}
+
+func onlySupportsPost(methods []string) bool {
+	return contains(methods, "client_secret_post") && !contains(methods, "client_secret_basic")
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
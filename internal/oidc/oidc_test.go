@@ -0,0 +1,254 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/oidc/oidctest"
+)
+
+const testClientID = "homework-manager"
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.IdP, string) {
+	t.Helper()
+	idp, server := oidctest.NewServer(testClientID)
+	t.Cleanup(server.Close)
+	provider := NewProvider(config.OIDCConfig{
+		Issuer:      server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://homework.test/auth/oidc/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+	})
+	return provider, idp, server.URL
+}
+
+// authorize は認可エンドポイントにアクセスし、リダイレクト先の code と state を返す。
+func authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("authorize: status %d: %s", resp.StatusCode, body)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+// unsignedToken は header と claims から署名部分が signature の JWT を組み立てる。
+func unsignedToken(header, claims map[string]interface{}, signature []byte) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	provider, _, _ := newTestProvider(t)
+
+	authURL, err := provider.AuthCodeURL("state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	parsed, _ := url.Parse(authURL)
+	q := parsed.Query()
+	if q.Get("code_challenge") != CodeChallenge("verifier-1") || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("authorization request does not use S256 PKCE: %s", authURL)
+	}
+	if q.Get("state") != "state-1" || q.Get("nonce") != "nonce-1" || q.Get("scope") != "openid email profile" {
+		t.Errorf("authorization request = %s", authURL)
+	}
+
+	code, state := authorize(t, authURL)
+	if state != "state-1" {
+		t.Errorf("state = %q, want %q", state, "state-1")
+	}
+	token, err := provider.Exchange(code, "verifier-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims, err := provider.VerifyIDToken(token.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.Subject != "mock-user" || claims.Email != "mock-user@example.com" || !claims.EmailVerified {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// 認可コードは 1 回しか使えない
+	var tokenErr *TokenError
+	if _, err := provider.Exchange(code, "verifier-1"); !errors.As(err, &tokenErr) || tokenErr.Code != "invalid_grant" {
+		t.Errorf("reused code: err = %v, want invalid_grant", err)
+	}
+
+	// code_verifier が code_challenge と一致しなければ交換できない
+	code, _ = authorize(t, authURL)
+	if _, err := provider.Exchange(code, "another-verifier"); !errors.As(err, &tokenErr) || tokenErr.Code != "invalid_grant" {
+		t.Errorf("wrong verifier: err = %v, want invalid_grant", err)
+	}
+}
+
+func TestExchangeWithClientSecret(t *testing.T) {
+	idp, server := oidctest.NewServer(testClientID)
+	defer server.Close()
+	idp.ClientSecret = "s3cret"
+
+	cfg := config.OIDCConfig{Issuer: server.URL, ClientID: testClientID, RedirectURL: "http://homework.test/auth/oidc/callback"}
+	authURL, _ := NewProvider(cfg).AuthCodeURL("state", "nonce", "verifier")
+
+	var tokenErr *TokenError
+	code, _ := authorize(t, authURL)
+	if _, err := NewProvider(cfg).Exchange(code, "verifier"); !errors.As(err, &tokenErr) || tokenErr.Code != "invalid_client" {
+		t.Errorf("without secret: err = %v, want invalid_client", err)
+	}
+
+	cfg.ClientSecret = "s3cret"
+	code, _ = authorize(t, authURL)
+	if _, err := NewProvider(cfg).Exchange(code, "verifier"); err != nil {
+		t.Errorf("with secret: %v", err)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	provider, idp, issuer := newTestProvider(t)
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":   issuer,
+			"aud":   testClientID,
+			"sub":   "mock-user",
+			"nonce": "nonce-1",
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	// HS256 の鍵に公開鍵（JWKS）を使う、アルゴリズム取り違え攻撃のトークン
+	resp, err := http.Get(issuer + "/jwks")
+	if err != nil {
+		t.Fatalf("get jwks: %v", err)
+	}
+	jwks, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	hs256 := func() string {
+		token := unsignedToken(map[string]interface{}{"alg": "HS256", "typ": "JWT", "kid": "mock-key"}, claims(nil), nil)
+		signingInput := token[:strings.LastIndex(token, ".")]
+		mac := hmac.New(sha256.New, jwks)
+		mac.Write([]byte(signingInput))
+		return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}()
+
+	valid := idp.Sign(claims(nil))
+	parts := strings.Split(valid, ".")
+	tamperedPayload, _ := json.Marshal(claims(map[string]interface{}{"sub": "admin"}))
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString(tamperedPayload) + "." + parts[2]
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+		want  error
+	}{
+		{"alg=none", unsignedToken(map[string]interface{}{"alg": "none", "typ": "JWT"}, claims(nil), nil), "nonce-1", ErrInvalidIDToken},
+		{"鍵 ID 付きの alg=none", unsignedToken(map[string]interface{}{"alg": "none", "kid": "mock-key"}, claims(nil), nil), "nonce-1", ErrInvalidIDToken},
+		{"HS256", hs256, "nonce-1", ErrInvalidIDToken},
+		{"改ざんされたペイロード", tampered, "nonce-1", ErrInvalidIDToken},
+		{"知らない鍵 ID", unsignedToken(map[string]interface{}{"alg": "RS256", "kid": "other-key"}, claims(nil), []byte("sig")), "nonce-1", ErrUnknownSigningKey},
+		{"nonce が違う", valid, "nonce-2", ErrNonceMismatch},
+		{"nonce がない", idp.Sign(claims(map[string]interface{}{"nonce": nil})), "nonce-1", ErrNonceMismatch},
+		{"iss が違う", idp.Sign(claims(map[string]interface{}{"iss": "https://evil.example.com"})), "nonce-1", ErrInvalidIDToken},
+		{"aud が違う", idp.Sign(claims(map[string]interface{}{"aud": "other-client"})), "nonce-1", ErrInvalidIDToken},
+		{"複数の aud で azp が違う", idp.Sign(claims(map[string]interface{}{"aud": []string{testClientID, "other-client"}, "azp": "other-client"})), "nonce-1", ErrInvalidIDToken},
+		{"期限切れ", idp.Sign(claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})), "nonce-1", ErrInvalidIDToken},
+		{"exp がない", idp.Sign(claims(map[string]interface{}{"exp": nil})), "nonce-1", ErrInvalidIDToken},
+		{"未来の iat", idp.Sign(claims(map[string]interface{}{"iat": time.Now().Add(time.Hour).Unix()})), "nonce-1", ErrInvalidIDToken},
+		{"sub がない", idp.Sign(claims(map[string]interface{}{"sub": nil})), "nonce-1", ErrInvalidIDToken},
+		{"形式が不正", "not-a-jwt", "nonce-1", ErrInvalidIDToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := provider.VerifyIDToken(tt.token, tt.nonce); !errors.Is(err, tt.want) {
+				t.Errorf("VerifyIDToken = %+v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := provider.VerifyIDToken(valid, "nonce-1"); err != nil {
+		t.Errorf("valid token: %v", err)
+	}
+}
+
+func TestUserInfo(t *testing.T) {
+	provider, idp, _ := newTestProvider(t)
+	// ID トークンにはメールアドレスがなく、UserInfo で補う
+	idp.SetClaims(map[string]interface{}{"sub": "mock-user", "email": "userinfo@example.com", "email_verified": true})
+
+	authURL, _ := provider.AuthCodeURL("state", "nonce", "verifier")
+	code, _ := authorize(t, authURL)
+	token, err := provider.Exchange(code, "verifier")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims := &Claims{Subject: "mock-user", Nonce: "nonce", Raw: map[string]interface{}{"sub": "mock-user", "name": "ID Token Name"}}
+	if err := provider.UserInfo(token.AccessToken, claims); err != nil {
+		t.Fatalf("UserInfo: %v", err)
+	}
+	if claims.Email != "userinfo@example.com" || !claims.EmailVerified || claims.Name != "ID Token Name" || claims.Nonce != "nonce" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// sub が ID トークンと違う UserInfo は取り込まない
+	other := &Claims{Subject: "someone-else", Raw: map[string]interface{}{"sub": "someone-else"}}
+	if err := provider.UserInfo(token.AccessToken, other); err == nil || other.Email != "" {
+		t.Errorf("mismatched subject: err = %v, claims = %+v", err, other)
+	}
+}
+
+func TestClaimsStringValues(t *testing.T) {
+	claims := &Claims{Raw: map[string]interface{}{
+		"roles":        []interface{}{"teacher", 1, "staff"},
+		"groups":       "admins staff",
+		"realm_access": map[string]interface{}{"roles": []interface{}{"hm-admin"}},
+	}}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"roles", []string{"teacher", "staff"}},
+		{"groups", []string{"admins", "staff"}},
+		{"realm_access.roles", []string{"hm-admin"}},
+		{"realm_access.missing", nil},
+		{"roles.nested", nil},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		if got := claims.StringValues(tt.name); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("StringValues(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Package oidctest はテストとローカル開発用のモック OpenID プロバイダー。
+// 認可エンドポイントは画面を出さずに、設定されたユーザーとして即座に認可コードを発行する。
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const keyID = "mock-key"
+
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	claims        map[string]interface{}
+}
+
+// IdP はモック OpenID プロバイダー。http.Handler として任意のサーバーに載せられる。
+type IdP struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // 空の場合はクライアント認証を要求しない
+
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims map[string]interface{}
+	codes  map[string]*authorization
+	tokens map[string]map[string]interface{}
+}
+
+// New は issuer で動くモック IdP を返す。ログインするユーザーは SetClaims で設定する。
+func New(issuer, clientID string) *IdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic("oidctest: failed to generate key: " + err.Error())
+	}
+	return &IdP{
+		Issuer:   strings.TrimRight(issuer, "/"),
+		ClientID: clientID,
+		key:      key,
+		claims: map[string]interface{}{
+			"sub":            "mock-user",
+			"email":          "mock-user@example.com",
+			"email_verified": true,
+			"name":           "Mock User",
+		},
+		codes:  map[string]*authorization{},
+		tokens: map[string]map[string]interface{}{},
+	}
+}
+
+// NewServer はモック IdP を httptest.Server で起動する。Issuer はサーバーの URL になる。
+func NewServer(clientID string) (*IdP, *httptest.Server) {
+	server := httptest.NewUnstartedServer(nil)
+	idp := New("http://"+server.Listener.Addr().String(), clientID)
+	server.Config.Handler = idp
+	server.Start()
+	return idp, server
+}
+
+// SetClaims は次回以降のログインで ID トークンに入れるクレームを設定する（sub を含めること）。
+func (p *IdP) SetClaims(claims map[string]interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.claims = claims
+}
+
+func (p *IdP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		p.discovery(w)
+	case "/jwks":
+		p.jwks(w)
+	case "/authorize":
+		p.authorize(w, r)
+	case "/token":
+		p.token(w, r)
+	case "/userinfo":
+		p.userinfo(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *IdP) discovery(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"userinfo_endpoint":                     p.Issuer + "/userinfo",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+func (p *IdP) jwks(w http.ResponseWriter) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize は設定されたユーザーとして認可し、redirect_uri に code と state を付けてリダイレクトする。
+// login_hint を指定すると、そのメールアドレス（sub も同じ値）のユーザーとして認可する。
+func (p *IdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != p.ClientID || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE (S256) is required", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	claims := make(map[string]interface{}, len(p.claims))
+	for k, v := range p.claims {
+		claims[k] = v
+	}
+	if hint := q.Get("login_hint"); hint != "" {
+		claims["sub"] = hint
+		claims["email"] = hint
+	}
+	code := randomString()
+	p.codes[code] = &authorization{
+		clientID:      q.Get("client_id"),
+		redirectURI:   q.Get("redirect_uri"),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+		claims:        claims,
+	}
+	p.mu.Unlock()
+
+	target, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := target.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	target.RawQuery = params.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (p *IdP) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || (p.ClientSecret != "" && clientSecret != p.ClientSecret) {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	p.mu.Lock()
+	code := r.PostForm.Get("code")
+	auth, found := p.codes[code]
+	delete(p.codes, code) // 認可コードは 1 回限り
+	p.mu.Unlock()
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+	if !found || auth.clientID != clientID || auth.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.codeChallenge {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	idClaims := map[string]interface{}{}
+	for k, v := range auth.claims {
+		idClaims[k] = v
+	}
+	idClaims["iss"] = p.Issuer
+	idClaims["aud"] = clientID
+	idClaims["iat"] = now.Unix()
+	idClaims["exp"] = now.Add(5 * time.Minute).Unix()
+	if auth.nonce != "" {
+		idClaims["nonce"] = auth.nonce
+	}
+
+	accessToken := randomString()
+	p.mu.Lock()
+	p.tokens[accessToken] = auth.claims
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     p.Sign(idClaims),
+	})
+}
+
+func (p *IdP) userinfo(w http.ResponseWriter, r *http.Request) {
+	accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	p.mu.Lock()
+	claims, ok := p.tokens[accessToken]
+	p.mu.Unlock()
+	if !ok {
+		tokenError(w, http.StatusUnauthorized, "invalid_token")
+		return
+	}
+	writeJSON(w, http.StatusOK, claims)
+}
+
+// Sign は claims を RS256 で署名した JWT を返す。不正なトークンを使うテストで利用する。
+func (p *IdP) Sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic("oidctest: failed to sign token: " + err.Error())
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func tokenError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -3,7 +3,6 @@ package repository
 import (
 	"time"
 
-	"homework-manager/internal/database"
 	"homework-manager/internal/models"
 
 	"gorm.io/gorm"
@@ -13,8 +12,8 @@ type AssignmentRepository struct {
 	db *gorm.DB
 }
 
-func NewAssignmentRepository() *AssignmentRepository {
-	return &AssignmentRepository{db: database.GetDB()}
+func NewAssignmentRepository(db *gorm.DB) *AssignmentRepository {
+	return &AssignmentRepository{db: db}
 }
 
 func (r *AssignmentRepository) Create(assignment *models.Assignment) error {
@@ -23,22 +22,42 @@ func (r *AssignmentRepository) Create(assignment *models.Assignment) error {
 
 func (r *AssignmentRepository) FindByID(id uint) (*models.Assignment, error) {
 	var assignment models.Assignment
-	err := r.db.First(&assignment, id).Error
+	err := preloadTags(r.db).First(&assignment, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &assignment, nil
 }
 
+// FindByUID は UID が一致するユーザーの課題を返す。インポート元の UID、またはこのサーバーが発行した UID で検索する。
+func (r *AssignmentRepository) FindByUID(userID uint, uid string) (*models.Assignment, error) {
+	var assignment models.Assignment
+	err := r.db.Where("user_id = ? AND external_uid = ?", userID, uid).First(&assignment).Error
+	if err == gorm.ErrRecordNotFound {
+		id, ok := models.ParseAssignmentUID(uid)
+		if !ok {
+			return nil, nil
+		}
+		err = r.db.Where("id = ? AND user_id = ? AND (external_uid = '' OR external_uid IS NULL)", id, userID).First(&assignment).Error
+	}
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &assignment, nil
+}
+
 func (r *AssignmentRepository) FindByUserID(userID uint) ([]models.Assignment, error) {
 	var assignments []models.Assignment
-	err := r.db.Where("user_id = ?", userID).Order("due_date ASC").Find(&assignments).Error
+	err := preloadTags(r.db).Where("user_id = ?", userID).Order("due_date ASC").Find(&assignments).Error
 	return assignments, err
 }
 
 func (r *AssignmentRepository) FindPendingByUserID(userID uint, limit, offset int) ([]models.Assignment, error) {
 	var assignments []models.Assignment
-	query := r.db.Where("user_id = ? AND is_completed = ?", userID, false).
+	query := preloadTags(r.db).Where("user_id = ? AND is_completed = ?", userID, false).
 		Order("due_date ASC")
 	if limit > 0 {
 		query = query.Limit(limit).Offset(offset)
@@ -49,7 +68,7 @@ func (r *AssignmentRepository) FindPendingByUserID(userID uint, limit, offset in
 
 func (r *AssignmentRepository) FindCompletedByUserID(userID uint, limit, offset int) ([]models.Assignment, error) {
 	var assignments []models.Assignment
-	query := r.db.Where("user_id = ? AND is_completed = ?", userID, true).
+	query := preloadTags(r.db).Where("user_id = ? AND is_completed = ?", userID, true).
 		Order("completed_at DESC")
 	if limit > 0 {
 		query = query.Limit(limit).Offset(offset)
@@ -58,35 +77,37 @@ func (r *AssignmentRepository) FindCompletedByUserID(userID uint, limit, offset
 	return assignments, err
 }
 
-func (r *AssignmentRepository) FindDueTodayByUserID(userID uint) ([]models.Assignment, error) {
-	now := time.Now()
+// FindDueTodayByUserID は loc での今日が期限の未完了課題を返す。
+func (r *AssignmentRepository) FindDueTodayByUserID(userID uint, loc *time.Location) ([]models.Assignment, error) {
+	now := time.Now().In(loc)
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	endOfDay := startOfDay.AddDate(0, 0, 1)
 
 	var assignments []models.Assignment
-	err := r.db.Where("user_id = ? AND is_completed = ? AND due_date >= ? AND due_date < ?",
-		userID, false, startOfDay, endOfDay).
+	err := preloadTags(r.db).Where("user_id = ? AND is_completed = ? AND due_date >= ? AND due_date < ?",
+		userID, false, startOfDay.UTC(), endOfDay.UTC()).
 		Order("due_date ASC").Find(&assignments).Error
 	return assignments, err
 }
 
-func (r *AssignmentRepository) FindDueThisWeekByUserID(userID uint) ([]models.Assignment, error) {
-	now := time.Now()
+// FindDueThisWeekByUserID は loc での今日から 7 日以内が期限の未完了課題を返す。
+func (r *AssignmentRepository) FindDueThisWeekByUserID(userID uint, loc *time.Location) ([]models.Assignment, error) {
+	now := time.Now().In(loc)
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	weekLater := startOfDay.AddDate(0, 0, 7)
 
 	var assignments []models.Assignment
-	err := r.db.Where("user_id = ? AND is_completed = ? AND due_date >= ? AND due_date < ?",
-		userID, false, startOfDay, weekLater).
+	err := preloadTags(r.db).Where("user_id = ? AND is_completed = ? AND due_date >= ? AND due_date < ?",
+		userID, false, startOfDay.UTC(), weekLater.UTC()).
 		Order("due_date ASC").Find(&assignments).Error
 	return assignments, err
 }
 
 func (r *AssignmentRepository) FindOverdueByUserID(userID uint, limit, offset int) ([]models.Assignment, error) {
-	now := time.Now()
+	now := time.Now().UTC()
 
 	var assignments []models.Assignment
-	query := r.db.Where("user_id = ? AND is_completed = ? AND due_date < ?",
+	query := preloadTags(r.db).Where("user_id = ? AND is_completed = ? AND due_date < ?",
 		userID, false, now).
 		Order("due_date ASC")
 	if limit > 0 {
@@ -96,8 +117,9 @@ func (r *AssignmentRepository) FindOverdueByUserID(userID uint, limit, offset in
 	return assignments, err
 }
 
+// Update は課題を保存する。タグは SetAssignmentTags で変更するため保存しない。
 func (r *AssignmentRepository) Update(assignment *models.Assignment) error {
-	return r.db.Save(assignment).Error
+	return r.db.Omit("Tags").Save(assignment).Error
 }
 
 func (r *AssignmentRepository) Delete(id uint) error {
@@ -117,15 +139,6 @@ func (r *AssignmentRepository) CountPendingByUserID(userID uint) (int64, error)
 	return count, err
 }
 
-func (r *AssignmentRepository) GetSubjectsByUserID(userID uint) ([]string, error) {
-	var subjects []string
-	err := r.db.Model(&models.Assignment{}).
-		Where("user_id = ? AND subject != ''", userID).
-		Distinct("subject").
-		Pluck("subject", &subjects).Error
-	return subjects, err
-}
-
 func (r *AssignmentRepository) CountCompletedByUserID(userID uint) (int64, error) {
 	var count int64
 	err := r.db.Model(&models.Assignment{}).
@@ -133,7 +146,7 @@ func (r *AssignmentRepository) CountCompletedByUserID(userID uint) (int64, error
 	return count, err
 }
 
-func (r *AssignmentRepository) Search(userID uint, queryStr, priority, filter string, page, pageSize int) ([]models.Assignment, int64, error) {
+func (r *AssignmentRepository) Search(userID uint, queryStr, priority, filter string, tags TagFilter, page, pageSize int, loc *time.Location) ([]models.Assignment, int64, error) {
 	var assignments []models.Assignment
 	var totalCount int64
 
@@ -147,7 +160,9 @@ func (r *AssignmentRepository) Search(userID uint, queryStr, priority, filter st
 		dbQuery = dbQuery.Where("priority = ?", priority)
 	}
 
-	now := time.Now()
+	dbQuery = applyTagFilter(r.db, dbQuery, userID, tags)
+
+	now := time.Now().In(loc)
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	endOfDay := startOfDay.AddDate(0, 0, 1)
 	weekLater := startOfDay.AddDate(0, 0, 7)
@@ -156,11 +171,12 @@ func (r *AssignmentRepository) Search(userID uint, queryStr, priority, filter st
 	case "completed":
 		dbQuery = dbQuery.Where("is_completed = ?", true)
 	case "overdue":
-		dbQuery = dbQuery.Where("is_completed = ? AND due_date < ?", false, now)
+		dbQuery = dbQuery.Where("is_completed = ? AND due_date < ?", false, now.UTC())
 	case "due_today":
-		dbQuery = dbQuery.Where("is_completed = ? AND due_date >= ? AND due_date < ?", false, startOfDay, endOfDay)
+		dbQuery = dbQuery.Where("is_completed = ? AND due_date >= ? AND due_date < ?", false, startOfDay.UTC(), endOfDay.UTC())
 	case "due_this_week":
-		dbQuery = dbQuery.Where("is_completed = ? AND due_date >= ? AND due_date < ?", false, startOfDay, weekLater)
+		dbQuery = dbQuery.Where("is_completed = ? AND due_date >= ? AND due_date < ?", false, startOfDay.UTC(), weekLater.UTC())
+	case "all":
 	default: // pending
 		dbQuery = dbQuery.Where("is_completed = ?", false)
 	}
@@ -183,20 +199,21 @@ func (r *AssignmentRepository) Search(userID uint, queryStr, priority, filter st
 	}
 	offset := (page - 1) * pageSize
 
-	err := dbQuery.Limit(pageSize).Offset(offset).Find(&assignments).Error
+	err := preloadTags(dbQuery).Limit(pageSize).Offset(offset).Find(&assignments).Error
 	return assignments, totalCount, err
 }
 
 func (r *AssignmentRepository) CountOverdueByUserID(userID uint) (int64, error) {
 	var count int64
-	now := time.Now()
+	now := time.Now().UTC()
 	err := r.db.Model(&models.Assignment{}).
 		Where("user_id = ? AND is_completed = ? AND due_date < ?", userID, false, now).Count(&count).Error
 	return count, err
 }
 
 type StatisticsFilter struct {
-	Subject         string
+	SubjectID       *uint
+	TagID           *uint
 	From            *time.Time
 	To              *time.Time
 	IncludeArchived bool
@@ -211,8 +228,18 @@ type AssignmentStatistics struct {
 	OnTimeCompletionRate float64
 }
 
+type TagStatistics struct {
+	Tag                  models.Tag
+	Total                int64
+	Completed            int64
+	Pending              int64
+	Overdue              int64
+	CompletedOnTime      int64
+	OnTimeCompletionRate float64
+}
+
 type SubjectStatistics struct {
-	Subject              string
+	Subject              models.Subject
 	Total                int64
 	Completed            int64
 	Pending              int64
@@ -222,23 +249,26 @@ type SubjectStatistics struct {
 }
 
 func (r *AssignmentRepository) GetStatistics(userID uint, filter StatisticsFilter) (*AssignmentStatistics, error) {
-	now := time.Now()
+	now := time.Now().UTC()
 	stats := &AssignmentStatistics{}
 	baseQuery := r.db.Model(&models.Assignment{}).Where("user_id = ?", userID)
 
-	if filter.Subject != "" {
-		baseQuery = baseQuery.Where("subject = ?", filter.Subject)
+	if filter.SubjectID != nil {
+		baseQuery = baseQuery.Where("subject_id = ?", *filter.SubjectID)
+	}
+	if filter.TagID != nil {
+		baseQuery = baseQuery.Where("id IN (?)", r.db.Table("assignment_tags").Select("assignment_id").Where("tag_id = ?", *filter.TagID))
 	}
 
 	if filter.From != nil {
-		baseQuery = baseQuery.Where("created_at >= ?", *filter.From)
+		baseQuery = baseQuery.Where("created_at >= ?", filter.From.UTC())
 	}
 	if filter.To != nil {
-		toEnd := filter.To.AddDate(0, 0, 1)
+		toEnd := filter.To.AddDate(0, 0, 1).UTC()
 		baseQuery = baseQuery.Where("created_at < ?", toEnd)
 	}
 	if !filter.IncludeArchived {
-		baseQuery = baseQuery.Where("is_archived = ?", false)
+		baseQuery = baseQuery.Where("(subject_id IS NULL OR subject_id NOT IN (?))", archivedSubjectIDs(r.db, userID))
 	}
 
 	if err := baseQuery.Count(&stats.Total).Error; err != nil {
@@ -270,9 +300,9 @@ func (r *AssignmentRepository) GetStatistics(userID uint, filter StatisticsFilte
 	return stats, nil
 }
 
+// GetStatisticsBySubjects は課題のある科目ごとの統計を返す。アーカイブした科目は filter.IncludeArchived の場合だけ含める。
 func (r *AssignmentRepository) GetStatisticsBySubjects(userID uint, filter StatisticsFilter) ([]SubjectStatistics, error) {
-	now := time.Now()
-	subjects, err := r.GetSubjectsByUserID(userID)
+	subjects, err := NewSubjectRepository(r.db).FindByUserID(userID, filter.IncludeArchived)
 	if err != nil {
 		return nil, err
 	}
@@ -280,33 +310,25 @@ func (r *AssignmentRepository) GetStatisticsBySubjects(userID uint, filter Stati
 	var results []SubjectStatistics
 	for _, subject := range subjects {
 		subjectFilter := StatisticsFilter{
-			Subject: subject,
-			From:    filter.From,
-			To:      filter.To,
+			SubjectID:       &subject.ID,
+			From:            filter.From,
+			To:              filter.To,
+			IncludeArchived: filter.IncludeArchived,
 		}
 		stats, err := r.GetStatistics(userID, subjectFilter)
 		if err != nil {
 			return nil, err
 		}
-
-		overdueQuery := r.db.Model(&models.Assignment{}).
-			Where("user_id = ? AND subject = ? AND is_completed = ? AND due_date < ?", userID, subject, false, now)
-		if filter.From != nil {
-			overdueQuery = overdueQuery.Where("created_at >= ?", *filter.From)
-		}
-		if filter.To != nil {
-			toEnd := filter.To.AddDate(0, 0, 1)
-			overdueQuery = overdueQuery.Where("created_at < ?", toEnd)
+		if stats.Total == 0 {
+			continue
 		}
-		var overdueCount int64
-		overdueQuery.Count(&overdueCount)
 
 		results = append(results, SubjectStatistics{
 			Subject:              subject,
 			Total:                stats.Total,
 			Completed:            stats.Completed,
 			Pending:              stats.Pending,
-			Overdue:              overdueCount,
+			Overdue:              stats.Overdue,
 			CompletedOnTime:      stats.CompletedOnTime,
 			OnTimeCompletionRate: stats.OnTimeCompletionRate,
 		})
@@ -315,39 +337,40 @@ func (r *AssignmentRepository) GetStatisticsBySubjects(userID uint, filter Stati
 	return results, nil
 }
 
-func (r *AssignmentRepository) ArchiveBySubject(userID uint, subject string) error {
-	return r.db.Model(&models.Assignment{}).
-		Where("user_id = ? AND subject = ?", userID, subject).
-		Update("is_archived", true).Error
-}
-
-func (r *AssignmentRepository) UnarchiveBySubject(userID uint, subject string) error {
-	return r.db.Model(&models.Assignment{}).
-		Where("user_id = ? AND subject = ?", userID, subject).
-		Update("is_archived", false).Error
-}
+// GetStatisticsByTags は課題のあるタグごとの統計を返す。
+func (r *AssignmentRepository) GetStatisticsByTags(userID uint, filter StatisticsFilter) ([]TagStatistics, error) {
+	tags, err := NewTagRepository(r.db).FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
 
-func (r *AssignmentRepository) GetArchivedSubjects(userID uint) ([]string, error) {
-	var subjects []string
-	err := r.db.Model(&models.Assignment{}).
-		Where("user_id = ? AND is_archived = ? AND subject != ''", userID, true).
-		Distinct("subject").
-		Pluck("subject", &subjects).Error
-	return subjects, err
-}
+	var results []TagStatistics
+	for _, tag := range tags {
+		tagFilter := filter
+		tagFilter.TagID = &tag.ID
+		stats, err := r.GetStatistics(userID, tagFilter)
+		if err != nil {
+			return nil, err
+		}
+		if stats.Total == 0 {
+			continue
+		}
 
-func (r *AssignmentRepository) GetSubjectsByUserIDWithArchived(userID uint, includeArchived bool) ([]string, error) {
-	var subjects []string
-	query := r.db.Model(&models.Assignment{}).
-		Where("user_id = ? AND subject != ''", userID)
-	if !includeArchived {
-		query = query.Where("is_archived = ?", false)
+		results = append(results, TagStatistics{
+			Tag:                  tag,
+			Total:                stats.Total,
+			Completed:            stats.Completed,
+			Pending:              stats.Pending,
+			Overdue:              stats.Overdue,
+			CompletedOnTime:      stats.CompletedOnTime,
+			OnTimeCompletionRate: stats.OnTimeCompletionRate,
+		})
 	}
-	err := query.Distinct("subject").Pluck("subject", &subjects).Error
-	return subjects, err
+
+	return results, nil
 }
 
-func (r *AssignmentRepository) SearchWithPreload(userID uint, queryStr, priority, filter string, page, pageSize int) ([]models.Assignment, int64, error) {
+func (r *AssignmentRepository) SearchWithPreload(userID uint, queryStr, priority, filter string, tags TagFilter, page, pageSize int, loc *time.Location) ([]models.Assignment, int64, error) {
 	var assignments []models.Assignment
 	var totalCount int64
 
@@ -361,7 +384,9 @@ func (r *AssignmentRepository) SearchWithPreload(userID uint, queryStr, priority
 		dbQuery = dbQuery.Where("priority = ?", priority)
 	}
 
-	now := time.Now()
+	dbQuery = applyTagFilter(r.db, dbQuery, userID, tags)
+
+	now := time.Now().In(loc)
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	endOfDay := startOfDay.AddDate(0, 0, 1)
 	weekLater := startOfDay.AddDate(0, 0, 7)
@@ -370,13 +395,14 @@ func (r *AssignmentRepository) SearchWithPreload(userID uint, queryStr, priority
 	case "completed":
 		dbQuery = dbQuery.Where("is_completed = ?", true)
 	case "overdue":
-		dbQuery = dbQuery.Where("is_completed = ? AND due_date < ?", false, now)
+		dbQuery = dbQuery.Where("is_completed = ? AND due_date < ?", false, now.UTC())
 	case "due_today":
-		dbQuery = dbQuery.Where("is_completed = ? AND due_date >= ? AND due_date < ?", false, startOfDay, endOfDay)
+		dbQuery = dbQuery.Where("is_completed = ? AND due_date >= ? AND due_date < ?", false, startOfDay.UTC(), endOfDay.UTC())
 	case "due_this_week":
-		dbQuery = dbQuery.Where("is_completed = ? AND due_date >= ? AND due_date < ?", false, startOfDay, weekLater)
+		dbQuery = dbQuery.Where("is_completed = ? AND due_date >= ? AND due_date < ?", false, startOfDay.UTC(), weekLater.UTC())
 	case "recurring":
 		dbQuery = dbQuery.Where("recurring_assignment_id IS NOT NULL")
+	case "all":
 	default:
 		dbQuery = dbQuery.Where("is_completed = ?", false)
 	}
@@ -399,6 +425,6 @@ func (r *AssignmentRepository) SearchWithPreload(userID uint, queryStr, priority
 	}
 	offset := (page - 1) * pageSize
 
-	err := dbQuery.Preload("RecurringAssignment").Limit(pageSize).Offset(offset).Find(&assignments).Error
+	err := preloadTags(dbQuery).Preload("RecurringAssignment").Limit(pageSize).Offset(offset).Find(&assignments).Error
 	return assignments, totalCount, err
 }
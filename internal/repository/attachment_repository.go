@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type AttachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+func (r *AttachmentRepository) Create(attachment *models.Attachment) error {
+	return r.db.Create(attachment).Error
+}
+
+func (r *AttachmentRepository) FindByID(id uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := r.db.First(&attachment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (r *AttachmentRepository) FindByAssignmentID(assignmentID uint) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := r.db.Where("assignment_id = ?", assignmentID).Order("created_at ASC, id ASC").Find(&attachments).Error
+	return attachments, err
+}
+
+// FindByUserID は削除済みの課題のものも含めて、ユーザーのすべての添付ファイルを返す。
+func (r *AttachmentRepository) FindByUserID(userID uint) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := r.db.Where("user_id = ?", userID).Find(&attachments).Error
+	return attachments, err
+}
+
+// TotalSizeByUserID はユーザーのすべての添付ファイルの合計サイズを返す。
+// 削除した課題の添付ファイルも、保存先から消して行を削除するまでは含める。
+func (r *AttachmentRepository) TotalSizeByUserID(userID uint) (int64, error) {
+	var total *int64
+	err := r.db.Model(&models.Attachment{}).
+		Where("user_id = ?", userID).
+		Select("SUM(size)").
+		Scan(&total).Error
+	if err != nil || total == nil {
+		return 0, err
+	}
+	return *total, nil
+}
+
+// FindOfDeletedAssignments は削除（論理削除）された課題の添付ファイルを返す。
+func (r *AttachmentRepository) FindOfDeletedAssignments(limit int) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := r.db.Where("assignment_id IN (?)", r.db.Unscoped().Model(&models.Assignment{}).Select("id").Where("deleted_at IS NOT NULL")).
+		Order("id ASC").Limit(limit).Find(&attachments).Error
+	return attachments, err
+}
+
+// CountByAssignmentIDs は課題ごとの添付ファイルの数を返す。添付ファイルのない課題は含まない。
+func (r *AttachmentRepository) CountByAssignmentIDs(assignmentIDs []uint) (map[uint]int, error) {
+	counts := make(map[uint]int)
+	if len(assignmentIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		AssignmentID uint
+		Count        int
+	}
+	err := r.db.Model(&models.Attachment{}).
+		Select("assignment_id, COUNT(*) AS count").
+		Where("assignment_id IN ?", assignmentIDs).
+		Group("assignment_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.AssignmentID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *AttachmentRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Attachment{}, id).Error
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"time"
+
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AuditEventFilter は監査ログを絞り込む条件。空のフィールド・ゼロ値は条件にしない。
+type AuditEventFilter struct {
+	Action     string
+	ActorEmail string // 部分一致
+	TargetType string
+	TargetID   string
+	IP         string
+	From       time.Time // この日時以降
+	To         time.Time // この日時より前
+}
+
+type AuditEventRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditEventRepository(db *gorm.DB) *AuditEventRepository {
+	return &AuditEventRepository{db: db}
+}
+
+func (r *AuditEventRepository) Create(event *models.AuditEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r *AuditEventRepository) filtered(filter AuditEventFilter) *gorm.DB {
+	query := r.db.Model(&models.AuditEvent{})
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ActorEmail != "" {
+		query = query.Where("actor_email LIKE ?", "%"+filter.ActorEmail+"%")
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To.UTC())
+	}
+	return query
+}
+
+// Search は条件に合う監査ログを新しい順に返す。件数は limit・offset を適用する前の総数。
+func (r *AuditEventRepository) Search(filter AuditEventFilter, limit, offset int) ([]models.AuditEvent, int64, error) {
+	var events []models.AuditEvent
+	var totalCount int64
+
+	query := r.filtered(filter)
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&events).Error
+	return events, totalCount, err
+}
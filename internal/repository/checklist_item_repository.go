@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type ChecklistItemRepository struct {
+	db *gorm.DB
+}
+
+func NewChecklistItemRepository(db *gorm.DB) *ChecklistItemRepository {
+	return &ChecklistItemRepository{db: db}
+}
+
+func (r *ChecklistItemRepository) Create(item *models.ChecklistItem) error {
+	return r.db.Create(item).Error
+}
+
+func (r *ChecklistItemRepository) FindByID(id uint) (*models.ChecklistItem, error) {
+	var item models.ChecklistItem
+	err := r.db.First(&item, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *ChecklistItemRepository) FindByAssignmentID(assignmentID uint) ([]models.ChecklistItem, error) {
+	var items []models.ChecklistItem
+	err := r.db.Where("assignment_id = ?", assignmentID).Order("position ASC, id ASC").Find(&items).Error
+	return items, err
+}
+
+// NextPosition は課題の末尾に追加する項目の Position を返す。
+func (r *ChecklistItemRepository) NextPosition(assignmentID uint) (int, error) {
+	var maxPosition *int
+	err := r.db.Model(&models.ChecklistItem{}).
+		Where("assignment_id = ?", assignmentID).
+		Select("MAX(position)").
+		Scan(&maxPosition).Error
+	if err != nil || maxPosition == nil {
+		return 0, err
+	}
+	return *maxPosition + 1, nil
+}
+
+func (r *ChecklistItemRepository) Update(item *models.ChecklistItem) error {
+	return r.db.Save(item).Error
+}
+
+// UpdatePositions は items の並び順どおりに Position を振り直す。
+func (r *ChecklistItemRepository) UpdatePositions(items []models.ChecklistItem) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range items {
+			items[i].Position = i
+			if err := tx.Model(&models.ChecklistItem{}).Where("id = ?", items[i].ID).
+				UpdateColumn("position", i).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *ChecklistItemRepository) Delete(id uint) error {
+	return r.db.Delete(&models.ChecklistItem{}, id).Error
+}
+
+// ProgressByAssignmentIDs は課題ごとのチェックリストの進捗を返す。項目のない課題は含まない。
+func (r *ChecklistItemRepository) ProgressByAssignmentIDs(assignmentIDs []uint) (map[uint]models.ChecklistProgress, error) {
+	progress := make(map[uint]models.ChecklistProgress)
+	if len(assignmentIDs) == 0 {
+		return progress, nil
+	}
+
+	var rows []struct {
+		AssignmentID uint
+		Total        int
+		Done         int
+	}
+	err := r.db.Model(&models.ChecklistItem{}).
+		Select("assignment_id, COUNT(*) AS total, SUM(CASE WHEN is_done = ? THEN 1 ELSE 0 END) AS done", true).
+		Where("assignment_id IN ?", assignmentIDs).
+		Group("assignment_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		progress[row.AssignmentID] = models.ChecklistProgress{Done: row.Done, Total: row.Total}
+	}
+	return progress, nil
+}
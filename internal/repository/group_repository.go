@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GroupRepository struct {
+	db *gorm.DB
+}
+
+func NewGroupRepository(db *gorm.DB) *GroupRepository {
+	return &GroupRepository{db: db}
+}
+
+func (r *GroupRepository) Create(group *models.Group) error {
+	return r.db.Create(group).Error
+}
+
+func (r *GroupRepository) FindByID(id uint) (*models.Group, error) {
+	var group models.Group
+	err := r.db.First(&group, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// FindByIDForUpdate はグループの行をロックして返す。トランザクションの中で使い、同じグループのメンバーの変更を直列にする。
+func (r *GroupRepository) FindByIDForUpdate(id uint) (*models.Group, error) {
+	var group models.Group
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&group, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *GroupRepository) FindByJoinCode(code string) (*models.Group, error) {
+	var group models.Group
+	err := r.db.Where("join_code = ?", code).First(&group).Error
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *GroupRepository) Update(group *models.Group) error {
+	return r.db.Save(group).Error
+}
+
+// Delete はグループとメンバー・配布した課題を削除する。生徒の課題は配布元の削除とは別に扱う。
+func (r *GroupRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", id).Delete(&models.GroupAssignment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", id).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Group{}, id).Error
+	})
+}
+
+func (r *GroupRepository) CreateMembership(membership *models.Membership) error {
+	return r.db.Create(membership).Error
+}
+
+func (r *GroupRepository) FindMembership(groupID, userID uint) (*models.Membership, error) {
+	var membership models.Membership
+	err := r.db.Where("group_id = ? AND user_id = ?", groupID, userID).First(&membership).Error
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// FindMembershipsByUserID はユーザーが参加しているグループを、グループを読み込んで参加順に返す。
+func (r *GroupRepository) FindMembershipsByUserID(userID uint) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := r.db.Preload("Group").Where("user_id = ?", userID).Order("created_at ASC, id ASC").Find(&memberships).Error
+	return memberships, err
+}
+
+// FindMembers はグループのメンバーを、ユーザーを読み込んで教師・生徒の順に返す。
+func (r *GroupRepository) FindMembers(groupID uint) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := r.db.Preload("User").Where("group_id = ?", groupID).
+		Order("CASE WHEN role = 'teacher' THEN 0 ELSE 1 END, id ASC").
+		Find(&memberships).Error
+	return memberships, err
+}
+
+func (r *GroupRepository) CountMembers(groupID uint, role string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Membership{}).Where("group_id = ? AND role = ?", groupID, role).Count(&count).Error
+	return count, err
+}
+
+func (r *GroupRepository) UpdateMembership(membership *models.Membership) error {
+	return r.db.Save(membership).Error
+}
+
+func (r *GroupRepository) DeleteMembership(id uint) error {
+	return r.db.Delete(&models.Membership{}, id).Error
+}
+
+func (r *GroupRepository) CreateAssignment(assignment *models.GroupAssignment) error {
+	return r.db.Create(assignment).Error
+}
+
+func (r *GroupRepository) FindAssignmentByID(id uint) (*models.GroupAssignment, error) {
+	var assignment models.GroupAssignment
+	err := r.db.First(&assignment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// FindAssignments はグループに配布した課題を期限の近い順に返す。
+func (r *GroupRepository) FindAssignments(groupID uint) ([]models.GroupAssignment, error) {
+	var assignments []models.GroupAssignment
+	err := r.db.Where("group_id = ?", groupID).Order("due_date ASC, id ASC").Find(&assignments).Error
+	return assignments, err
+}
+
+func (r *GroupRepository) UpdateAssignment(assignment *models.GroupAssignment) error {
+	return r.db.Save(assignment).Error
+}
+
+func (r *GroupRepository) DeleteAssignment(id uint) error {
+	return r.db.Delete(&models.GroupAssignment{}, id).Error
+}
+
+// FindCopies は配布した課題から作った生徒ごとの課題を返す。includeDeleted の場合は生徒が削除した課題も含める。
+func (r *GroupRepository) FindCopies(groupAssignmentID uint, includeDeleted bool) ([]models.Assignment, error) {
+	query := r.db
+	if includeDeleted {
+		query = query.Unscoped()
+	}
+	var assignments []models.Assignment
+	err := query.Where("group_assignment_id = ?", groupAssignmentID).Order("id ASC").Find(&assignments).Error
+	return assignments, err
+}
+
+// HasCopy はユーザーが配布した課題のコピーを持っているかを返す。削除した課題は数えない。
+func (r *GroupRepository) HasCopy(groupAssignmentID, userID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Assignment{}).
+		Where("group_assignment_id = ? AND user_id = ?", groupAssignmentID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// FindPendingCopiesByUserID はユーザーの、グループ groupID から配布された未完了の課題を返す。
+func (r *GroupRepository) FindPendingCopiesByUserID(groupID, userID uint) ([]models.Assignment, error) {
+	var assignments []models.Assignment
+	err := r.db.Where("user_id = ? AND is_completed = ?", userID, false).
+		Where("group_assignment_id IN (?)", r.db.Unscoped().Model(&models.GroupAssignment{}).Select("id").Where("group_id = ?", groupID)).
+		Find(&assignments).Error
+	return assignments, err
+}
+
+// CopyCount は配布した課題から作った生徒ごとの課題の数。
+type CopyCount struct {
+	Total     int64
+	Completed int64
+}
+
+// CountCopies は配布した課題ごとの生徒の課題の数を返す。削除した課題は数えない。
+func (r *GroupRepository) CountCopies(groupID uint) (map[uint]CopyCount, error) {
+	var rows []struct {
+		GroupAssignmentID uint
+		Total             int64
+		Completed         int64
+	}
+	err := r.db.Model(&models.Assignment{}).
+		Select("group_assignment_id, COUNT(*) AS total, SUM(CASE WHEN is_completed THEN 1 ELSE 0 END) AS completed").
+		Where("group_assignment_id IN (?)", r.db.Model(&models.GroupAssignment{}).Select("id").Where("group_id = ?", groupID)).
+		Group("group_assignment_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]CopyCount, len(rows))
+	for _, row := range rows {
+		counts[row.GroupAssignmentID] = CopyCount{Total: row.Total, Completed: row.Completed}
+	}
+	return counts, nil
+}
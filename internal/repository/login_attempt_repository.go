@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"time"
+
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// LoginAttemptFilter は管理画面でログイン試行を絞り込む条件。空のフィールドは条件にしない。
+type LoginAttemptFilter struct {
+	Email  string
+	IP     string
+	Result string
+}
+
+type LoginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+func (r *LoginAttemptRepository) Create(attempt *models.LoginAttempt) error {
+	return r.db.Create(attempt).Error
+}
+
+// LastReset は連続失敗回数をリセットした最後の試行（ログイン成功・ロック解除）の日時を返す。ない場合はゼロ値を返す。
+func (r *LoginAttemptRepository) LastReset(email string) (time.Time, error) {
+	var attempts []models.LoginAttempt
+	err := r.db.Where("email = ? AND result IN ?", email, []string{models.LoginResultSuccess, models.LoginResultUnlocked}).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&attempts).Error
+	if err != nil || len(attempts) == 0 {
+		return time.Time{}, err
+	}
+	return attempts[0].CreatedAt, nil
+}
+
+// FailuresSince は since より後の失敗回数と、最後に失敗した日時を返す。
+func (r *LoginAttemptRepository) FailuresSince(email string, since time.Time) (int64, time.Time, error) {
+	var count int64
+	query := r.db.Model(&models.LoginAttempt{}).
+		Where("email = ? AND result = ? AND created_at > ?", email, models.LoginResultFailure, since.UTC())
+	if err := query.Count(&count).Error; err != nil || count == 0 {
+		return 0, time.Time{}, err
+	}
+	var last models.LoginAttempt
+	err := r.db.Where("email = ? AND result = ? AND created_at > ?", email, models.LoginResultFailure, since.UTC()).
+		Order("created_at DESC").
+		First(&last).Error
+	return count, last.CreatedAt, err
+}
+
+func (r *LoginAttemptRepository) CountIPFailuresSince(ip string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.LoginAttempt{}).
+		Where("ip = ? AND result = ? AND created_at > ?", ip, models.LoginResultFailure, since.UTC()).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *LoginAttemptRepository) FindRecent(filter LoginAttemptFilter, limit int) ([]models.LoginAttempt, error) {
+	query := r.db.Model(&models.LoginAttempt{})
+	if filter.Email != "" {
+		query = query.Where("email = ?", filter.Email)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.Result != "" {
+		query = query.Where("result = ?", filter.Result)
+	}
+	var attempts []models.LoginAttempt
+	err := query.Order("created_at DESC").Limit(limit).Find(&attempts).Error
+	return attempts, err
+}
+
+func (r *LoginAttemptRepository) DeleteBefore(before time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", before.UTC()).Delete(&models.LoginAttempt{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"time"
+
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type NotificationOutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationOutboxRepository(db *gorm.DB) *NotificationOutboxRepository {
+	return &NotificationOutboxRepository{db: db}
+}
+
+// WithTx は tx を使うリポジトリを返す。課題の送信済みフラグと同じトランザクションで登録するために使う。
+func (r *NotificationOutboxRepository) WithTx(tx *gorm.DB) *NotificationOutboxRepository {
+	return &NotificationOutboxRepository{db: tx}
+}
+
+func (r *NotificationOutboxRepository) Create(entry *models.NotificationOutbox) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *NotificationOutboxRepository) FindByID(id uint) (*models.NotificationOutbox, error) {
+	var entry models.NotificationOutbox
+	err := r.db.First(&entry, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *NotificationOutboxRepository) Update(entry *models.NotificationOutbox) error {
+	return r.db.Save(entry).Error
+}
+
+// FindDue は送信時刻を過ぎた送信待ちの通知を古い順に返す。
+func (r *NotificationOutboxRepository) FindDue(now time.Time, limit int) ([]models.NotificationOutbox, error) {
+	var entries []models.NotificationOutbox
+	err := r.db.Where("status = ? AND next_attempt_at <= ?", models.NotificationStatusPending, now.UTC()).
+		Order("next_attempt_at ASC, id ASC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// Claim は通知の試行回数を1つ進め、lease まで他のワーカーが取得しないようにする。
+// 他のワーカーが先に確保していれば false を返す。
+func (r *NotificationOutboxRepository) Claim(entry *models.NotificationOutbox, lease time.Time) (bool, error) {
+	result := r.db.Model(&models.NotificationOutbox{}).
+		Where("id = ? AND status = ? AND attempts = ?", entry.ID, models.NotificationStatusPending, entry.Attempts).
+		Updates(map[string]interface{}{
+			"attempts":        entry.Attempts + 1,
+			"next_attempt_at": lease.UTC(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	entry.Attempts++
+	entry.NextAttemptAt = &lease
+	return true, nil
+}
+
+func (r *NotificationOutboxRepository) FindRecentByUserID(userID uint, limit int) ([]models.NotificationOutbox, error) {
+	var entries []models.NotificationOutbox
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// Search は全ユーザーの通知を新しい順に返す。status が空の場合は全状態が対象。
+func (r *NotificationOutboxRepository) Search(status, channel string, limit, offset int) ([]models.NotificationOutbox, int64, error) {
+	var entries []models.NotificationOutbox
+	var totalCount int64
+
+	query := r.db.Model(&models.NotificationOutbox{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Preload("User").Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&entries).Error
+	return entries, totalCount, err
+}
+
+func (r *NotificationOutboxRepository) CountByStatus() (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.Model(&models.NotificationOutbox{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// Requeue はデッドレターの通知を送信待ちに戻し、試行回数をリセットする。
+func (r *NotificationOutboxRepository) Requeue(id uint, now time.Time) (bool, error) {
+	result := r.db.Model(&models.NotificationOutbox{}).
+		Where("id = ? AND status = ?", id, models.NotificationStatusDead).
+		Updates(map[string]interface{}{
+			"status":          models.NotificationStatusPending,
+			"attempts":        0,
+			"next_attempt_at": now.UTC(),
+			"error":           "",
+		})
+	return result.RowsAffected > 0, result.Error
+}
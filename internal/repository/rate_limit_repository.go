@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"time"
+
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitRepository はレート制限の状態をデータベースに保存する middleware.RateLimitStore の実装。
+// 複数台のサーバーで同じデータベースを使うと、制限を共有できる。
+type RateLimitRepository struct {
+	db *gorm.DB
+}
+
+func NewRateLimitRepository(db *gorm.DB) *RateLimitRepository {
+	return &RateLimitRepository{db: db}
+}
+
+// Update は行をロックしてから fn で書き換える。行がない場合は先に作成し、同時に作成しようとした他のリクエストとの衝突を避ける。
+// 日時は DeleteExpired と比較できるよう UTC で保存する。
+func (r *RateLimitRepository) Update(id string, fn func(bucket *models.RateLimitBucket)) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// ゼロ値の日時は MySQL の strict モードで保存できないため、十分に古い日時を入れておく（アルゴリズムは状態がない場合と同じに扱う）
+		epoch := time.Unix(0, 0).UTC()
+		placeholder := &models.RateLimitBucket{ID: id, Timestamp: epoch, ExpiresAt: epoch}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(placeholder).Error; err != nil {
+			return err
+		}
+
+		var bucket models.RateLimitBucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&bucket).Error; err != nil {
+			return err
+		}
+		fn(&bucket)
+		return tx.Model(&models.RateLimitBucket{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"value":      bucket.Value,
+			"previous":   bucket.Previous,
+			"timestamp":  bucket.Timestamp.UTC(),
+			"expires_at": bucket.ExpiresAt.UTC(),
+		}).Error
+	})
+}
+
+func (r *RateLimitRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at < ?", now.UTC()).Delete(&models.RateLimitBucket{}).Error
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"time"
+
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{db: db}
+}
+
+// Replace はユーザーのリカバリーコードをすべて削除し、codes に置き換える。
+func (r *RecoveryCodeRepository) Replace(userID uint, codes []models.RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *RecoveryCodeRepository) DeleteByUserID(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+func (r *RecoveryCodeRepository) CountUnused(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkUsed は未使用のコードを使用済みにする。一致するコードがない、または既に使われていた場合は false を返す。
+func (r *RecoveryCodeRepository) MarkUsed(userID uint, codeHash string, usedAt time.Time) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		UpdateColumn("used_at", usedAt.UTC())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -3,7 +3,6 @@ package repository
 import (
 	"time"
 
-	"homework-manager/internal/database"
 	"homework-manager/internal/models"
 
 	"gorm.io/gorm"
@@ -13,8 +12,8 @@ type RecurringAssignmentRepository struct {
 	db *gorm.DB
 }
 
-func NewRecurringAssignmentRepository() *RecurringAssignmentRepository {
-	return &RecurringAssignmentRepository{db: database.GetDB()}
+func NewRecurringAssignmentRepository(db *gorm.DB) *RecurringAssignmentRepository {
+	return &RecurringAssignmentRepository{db: db}
 }
 
 func (r *RecurringAssignmentRepository) Create(recurring *models.RecurringAssignment) error {
@@ -30,6 +29,26 @@ func (r *RecurringAssignmentRepository) FindByID(id uint) (*models.RecurringAssi
 	return &recurring, nil
 }
 
+// FindByUID は UID が一致するユーザーの繰り返し設定を返す。インポート元の UID、またはこのサーバーが発行した UID で検索する。
+func (r *RecurringAssignmentRepository) FindByUID(userID uint, uid string) (*models.RecurringAssignment, error) {
+	var recurring models.RecurringAssignment
+	err := r.db.Where("user_id = ? AND external_uid = ?", userID, uid).First(&recurring).Error
+	if err == gorm.ErrRecordNotFound {
+		id, ok := models.ParseRecurringUID(uid)
+		if !ok {
+			return nil, nil
+		}
+		err = r.db.Where("id = ? AND user_id = ? AND (external_uid = '' OR external_uid IS NULL)", id, userID).First(&recurring).Error
+	}
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &recurring, nil
+}
+
 func (r *RecurringAssignmentRepository) FindByUserID(userID uint) ([]models.RecurringAssignment, error) {
 	var recurrings []models.RecurringAssignment
 	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&recurrings).Error
@@ -87,9 +106,10 @@ func (r *RecurringAssignmentRepository) FindDueForGeneration() ([]models.Recurri
 	return result, nil
 }
 
+// 削除済みの課題も含めて最新の回を返す（削除した回が再生成されないようにするため）
 func (r *RecurringAssignmentRepository) GetLatestAssignmentByRecurringID(recurringID uint) (*models.Assignment, error) {
 	var assignment models.Assignment
-	err := r.db.Where("recurring_assignment_id = ?", recurringID).
+	err := r.db.Unscoped().Where("recurring_assignment_id = ?", recurringID).
 		Order("due_date DESC").
 		First(&assignment).Error
 	if err != nil {
@@ -111,12 +131,25 @@ func (r *RecurringAssignmentRepository) GetAssignmentsByRecurringID(recurringID
 
 func (r *RecurringAssignmentRepository) GetFutureAssignmentsByRecurringID(recurringID uint, fromDate time.Time) ([]models.Assignment, error) {
 	var assignments []models.Assignment
-	err := r.db.Where("recurring_assignment_id = ? AND due_date >= ?", recurringID, fromDate).
+	err := r.db.Where("recurring_assignment_id = ? AND due_date >= ?", recurringID, fromDate.UTC()).
 		Order("due_date ASC").
 		Find(&assignments).Error
 	return assignments, err
 }
 
+func (r *RecurringAssignmentRepository) FindAssignmentByDueDate(recurringID uint, dueDate time.Time) (*models.Assignment, error) {
+	var assignment models.Assignment
+	err := r.db.Unscoped().Where("recurring_assignment_id = ? AND due_date = ?", recurringID, dueDate.UTC()).
+		First(&assignment).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &assignment, nil
+}
+
 func (r *RecurringAssignmentRepository) CountPendingByRecurringID(recurringID uint) (int64, error) {
 	var count int64
 	err := r.db.Model(&models.Assignment{}).
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/sessionstore"
+
+	"gorm.io/gorm"
+)
+
+// SessionRepository はセッションをデータベースに保存する sessionstore.Backend の実装。
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) Find(id string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.Where("id = ? AND expires_at > ?", id, time.Now().UTC()).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, sessionstore.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *SessionRepository) Create(session *models.Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *SessionRepository) Update(session *models.Session) error {
+	result := r.db.Model(&models.Session{}).
+		Where("id = ?", session.ID).
+		Updates(map[string]interface{}{
+			"user_id":    session.UserID,
+			"data":       session.Data,
+			"expires_at": session.ExpiresAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return sessionstore.ErrNotFound
+	}
+	return nil
+}
+
+func (r *SessionRepository) Delete(id string) error {
+	return r.db.Where("id = ?", id).Delete(&models.Session{}).Error
+}
+
+func (r *SessionRepository) Touch(id, ip, userAgent string, seenAt time.Time) error {
+	return r.db.Model(&models.Session{}).
+		Where("id = ? AND (last_seen_at < ? OR ip <> ? OR user_agent <> ?)", id, seenAt.Add(-sessionstore.TouchInterval).UTC(), ip, userAgent).
+		UpdateColumns(map[string]interface{}{
+			"ip":           ip,
+			"user_agent":   userAgent,
+			"last_seen_at": seenAt.UTC(),
+		}).Error
+}
+
+func (r *SessionRepository) ListByUser(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Where("user_id = ? AND expires_at > ?", userID, time.Now().UTC()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *SessionRepository) DeleteByUser(userID uint, exceptID string) (int64, error) {
+	result := r.db.Where("user_id = ? AND id <> ?", userID, exceptID).Delete(&models.Session{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *SessionRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := r.db.Where("expires_at <= ?", now.UTC()).Delete(&models.Session{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type SubjectRepository struct {
+	db *gorm.DB
+}
+
+func NewSubjectRepository(db *gorm.DB) *SubjectRepository {
+	return &SubjectRepository{db: db}
+}
+
+func (r *SubjectRepository) Create(subject *models.Subject) error {
+	return r.db.Create(subject).Error
+}
+
+func (r *SubjectRepository) FindByID(id uint) (*models.Subject, error) {
+	var subject models.Subject
+	err := r.db.First(&subject, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &subject, nil
+}
+
+func (r *SubjectRepository) FindByName(userID uint, name string) (*models.Subject, error) {
+	var subject models.Subject
+	err := r.db.Where("user_id = ? AND name = ?", userID, name).First(&subject).Error
+	if err != nil {
+		return nil, err
+	}
+	return &subject, nil
+}
+
+// FindByUserID はユーザーの科目を名前順に返す。アーカイブした科目は includeArchived の場合だけ含める。
+func (r *SubjectRepository) FindByUserID(userID uint, includeArchived bool) ([]models.Subject, error) {
+	var subjects []models.Subject
+	query := r.db.Where("user_id = ?", userID)
+	if !includeArchived {
+		query = query.Where("is_archived = ?", false)
+	}
+	err := query.Order("name ASC").Find(&subjects).Error
+	return subjects, err
+}
+
+// ArchivedIDs はアーカイブした科目の ID を検索条件に使うサブクエリを返す。
+func (r *SubjectRepository) ArchivedIDs(userID uint) *gorm.DB {
+	return archivedSubjectIDs(r.db, userID)
+}
+
+func archivedSubjectIDs(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&models.Subject{}).Select("id").Where("user_id = ? AND is_archived = ?", userID, true)
+}
+
+func (r *SubjectRepository) Update(subject *models.Subject) error {
+	return r.db.Save(subject).Error
+}
+
+// Rename は科目を保存し、参照している課題・繰り返し設定に保持している科目名を書き換える。
+func (r *SubjectRepository) Rename(subject *models.Subject) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(subject).Error; err != nil {
+			return err
+		}
+		return setSubjectRef(tx, subject.ID, subject)
+	})
+}
+
+// Merge は source を参照している課題・繰り返し設定を target に付け替え、source を削除する。
+func (r *SubjectRepository) Merge(source, target *models.Subject) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := setSubjectRef(tx, source.ID, target); err != nil {
+			return err
+		}
+		return tx.Delete(&models.Subject{}, source.ID).Error
+	})
+}
+
+// Delete は科目を削除し、参照している課題・繰り返し設定を科目なしにする。
+func (r *SubjectRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := setSubjectRef(tx, id, nil); err != nil {
+			return err
+		}
+		return tx.Delete(&models.Subject{}, id).Error
+	})
+}
+
+// setSubjectRef は subjectID を参照している課題（削除済みを含む）・繰り返し設定の参照先を subject にする。nil の場合は科目なしにする。
+func setSubjectRef(tx *gorm.DB, subjectID uint, subject *models.Subject) error {
+	columns := map[string]interface{}{"subject_id": nil, "subject": ""}
+	if subject != nil {
+		columns = map[string]interface{}{"subject_id": subject.ID, "subject": subject.Name}
+	}
+	if err := tx.Unscoped().Model(&models.Assignment{}).Where("subject_id = ?", subjectID).
+		UpdateColumns(columns).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&models.RecurringAssignment{}).Where("subject_id = ?", subjectID).
+		UpdateColumns(columns).Error
+}
+
+// CountAssignmentsByUserID は科目ごとの課題の数を返す。削除した課題は数えない。
+func (r *SubjectRepository) CountAssignmentsByUserID(userID uint) (map[uint]int64, error) {
+	var rows []struct {
+		SubjectID uint
+		Count     int64
+	}
+	err := r.db.Model(&models.Assignment{}).
+		Select("subject_id, COUNT(*) AS count").
+		Where("user_id = ? AND subject_id IS NOT NULL", userID).
+		Group("subject_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.SubjectID] = row.Count
+	}
+	return counts, nil
+}
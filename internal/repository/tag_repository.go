@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// タグでの絞り込み方
+const (
+	TagMatchAny  = "any"  // いずれかのタグが付いている
+	TagMatchAll  = "all"  // すべてのタグが付いている
+	TagMatchNone = "none" // どのタグも付いていない
+)
+
+// TagFilter は課題をタグの名前で絞り込む条件。Names が空の場合は絞り込まない。
+type TagFilter struct {
+	Names []string
+	Match string // TagMatchAny・TagMatchAll・TagMatchNone（空の場合は TagMatchAny）
+}
+
+func (f TagFilter) IsEmpty() bool {
+	return len(f.Names) == 0
+}
+
+// Matches は tags（課題に付いているタグ）が条件に合うかを返す。読み込み済みの課題を絞り込むのに使う。
+func (f TagFilter) Matches(tags []models.Tag) bool {
+	if f.IsEmpty() {
+		return true
+	}
+	has := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		has[tag.Name] = true
+	}
+	matched := 0
+	for _, name := range f.Names {
+		if has[name] {
+			matched++
+		}
+	}
+	switch f.Match {
+	case TagMatchAll:
+		return matched == len(f.Names)
+	case TagMatchNone:
+		return matched == 0
+	default:
+		return matched > 0
+	}
+}
+
+// applyTagFilter は query（課題の検索）に tags の条件を加える。
+func applyTagFilter(db, query *gorm.DB, userID uint, tags TagFilter) *gorm.DB {
+	if tags.IsEmpty() {
+		return query
+	}
+	tagged := db.Table("assignment_tags").
+		Select("assignment_tags.assignment_id").
+		Joins("JOIN tags ON tags.id = assignment_tags.tag_id").
+		Where("tags.user_id = ? AND tags.name IN ?", userID, tags.Names)
+
+	switch tags.Match {
+	case TagMatchAll:
+		tagged = tagged.Group("assignment_tags.assignment_id").Having("COUNT(DISTINCT tags.id) = ?", len(tags.Names))
+		return query.Where("assignments.id IN (?)", tagged)
+	case TagMatchNone:
+		return query.Where("assignments.id NOT IN (?)", tagged)
+	default:
+		return query.Where("assignments.id IN (?)", tagged)
+	}
+}
+
+// preloadTags はタグを名前順に読み込む。
+func preloadTags(query *gorm.DB) *gorm.DB {
+	return query.Preload("Tags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tags.name ASC")
+	})
+}
+
+type TagRepository struct {
+	db *gorm.DB
+}
+
+func NewTagRepository(db *gorm.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+func (r *TagRepository) Create(tag *models.Tag) error {
+	return r.db.Create(tag).Error
+}
+
+func (r *TagRepository) FindByID(id uint) (*models.Tag, error) {
+	var tag models.Tag
+	err := r.db.First(&tag, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+func (r *TagRepository) FindByName(userID uint, name string) (*models.Tag, error) {
+	var tag models.Tag
+	err := r.db.Where("user_id = ? AND name = ?", userID, name).First(&tag).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// FindByUserID はユーザーのタグを名前順に返す。
+func (r *TagRepository) FindByUserID(userID uint) ([]models.Tag, error) {
+	var tags []models.Tag
+	err := r.db.Where("user_id = ?", userID).Order("name ASC").Find(&tags).Error
+	return tags, err
+}
+
+func (r *TagRepository) Update(tag *models.Tag) error {
+	return r.db.Save(tag).Error
+}
+
+// Delete はタグを削除し、課題との結び付けを外す。
+func (r *TagRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM assignment_tags WHERE tag_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Tag{}, id).Error
+	})
+}
+
+// SetAssignmentTags は課題のタグを tags に置き換える。
+func (r *TagRepository) SetAssignmentTags(assignmentID uint, tags []models.Tag) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM assignment_tags WHERE assignment_id = ?", assignmentID).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		rows := make([]map[string]interface{}, 0, len(tags))
+		for _, tag := range tags {
+			rows = append(rows, map[string]interface{}{"assignment_id": assignmentID, "tag_id": tag.ID})
+		}
+		return tx.Table("assignment_tags").Create(&rows).Error
+	})
+}
+
+// CountAssignmentsByUserID はタグごとの課題の数を返す。削除した課題は数えない。
+func (r *TagRepository) CountAssignmentsByUserID(userID uint) (map[uint]int64, error) {
+	var rows []struct {
+		TagID uint
+		Count int64
+	}
+	err := r.db.Table("assignment_tags").
+		Select("assignment_tags.tag_id, COUNT(*) AS count").
+		Joins("JOIN assignments ON assignments.id = assignment_tags.assignment_id").
+		Where("assignments.user_id = ? AND assignments.deleted_at IS NULL", userID).
+		Group("assignment_tags.tag_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.TagID] = row.Count
+	}
+	return counts, nil
+}
@@ -1,7 +1,8 @@
 package repository
 
 import (
-	"homework-manager/internal/database"
+	"errors"
+
 	"homework-manager/internal/models"
 
 	"gorm.io/gorm"
@@ -11,8 +12,8 @@ type UserRepository struct {
 	db *gorm.DB
 }
 
-func NewUserRepository() *UserRepository {
-	return &UserRepository{db: database.GetDB()}
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
 }
 
 func (r *UserRepository) Create(user *models.User) error {
@@ -37,6 +38,24 @@ func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+func (r *UserRepository) FindByOIDCSubject(subject string) (*models.User, error) {
+	var user models.User
+	err := r.db.Where("oidc_subject = ?", subject).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) FindByCalendarTokenHash(hash string) (*models.User, error) {
+	var user models.User
+	err := r.db.Where("calendar_token_hash = ?", hash).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *UserRepository) FindAll() ([]models.User, error) {
 	var users []models.User
 	err := r.db.Find(&users).Error
@@ -47,11 +66,96 @@ func (r *UserRepository) Update(user *models.User) error {
 	return r.db.Save(user).Error
 }
 
+// AdvanceTOTPStep は最後に受け付けた TOTP のタイムステップを step に進める。
+// 既に step 以降のコードを受け付けている場合は false を返す（同時に送られた同じコードも 1 回しか通らない）。
+func (r *UserRepository) AdvanceTOTPStep(id uint, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		UpdateColumn("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Delete はユーザーと、課題などユーザーが持つデータを1つのトランザクションで完全に削除する。
 func (r *UserRepository) Delete(id uint) error {
-	if err := r.db.Unscoped().Where("user_id = ?", id).Delete(&models.Assignment{}).Error; err != nil {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		assignmentIDs := tx.Unscoped().Model(&models.Assignment{}).Select("id").Where("user_id = ?", id)
+		if err := tx.Where("assignment_id IN (?)", assignmentIDs).Delete(&models.ChecklistItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM assignment_tags WHERE assignment_id IN (?)", assignmentIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.Attachment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&models.Assignment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.Subject{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.Tag{}).Error; err != nil {
+			return err
+		}
+		if err := deleteTeacherGroups(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.WebAuthnCredential{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.UserToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.User{}, id).Error
+	})
+}
+
+// deleteTeacherGroups はユーザーが教師として参加しているグループを片付ける。
+// 他に教師がいるグループは、ユーザーが配布した課題の作成者を最も古くから参加している教師に付け替える。
+// 他に教師がいないグループは削除し、生徒の未完了の配布課題も削除する（完了した課題は生徒の記録として残す）。Webhook は送らない。
+func deleteTeacherGroups(tx *gorm.DB, userID uint) error {
+	var groupIDs []uint
+	if err := tx.Model(&models.Membership{}).Where("user_id = ? AND role = ?", userID, models.MembershipRoleTeacher).
+		Pluck("group_id", &groupIDs).Error; err != nil {
 		return err
 	}
-	return r.db.Unscoped().Delete(&models.User{}, id).Error
+	for _, groupID := range groupIDs {
+		var successor models.Membership
+		err := tx.Where("group_id = ? AND role = ? AND user_id <> ?", groupID, models.MembershipRoleTeacher, userID).
+			Order("created_at ASC, id ASC").First(&successor).Error
+		if err == nil {
+			if err := tx.Unscoped().Model(&models.GroupAssignment{}).
+				Where("group_id = ? AND created_by_id = ?", groupID, userID).
+				Update("created_by_id", successor.UserID).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		sources := tx.Unscoped().Model(&models.GroupAssignment{}).Select("id").Where("group_id = ?", groupID)
+		if err := tx.Where("group_assignment_id IN (?) AND is_completed = ?", sources, false).Delete(&models.Assignment{}).Error; err != nil {
+			return err
+		}
+		if err := NewGroupRepository(tx).Delete(groupID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *UserRepository) Count() (int64, error) {
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"time"
+
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type UserTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) *UserTokenRepository {
+	return &UserTokenRepository{db: db}
+}
+
+func (r *UserTokenRepository) Create(token *models.UserToken) error {
+	return r.db.Create(token).Error
+}
+
+// FindValid は未使用で有効期限内のトークンを返す。
+func (r *UserTokenRepository) FindValid(purpose, tokenHash string, now time.Time) (*models.UserToken, error) {
+	var token models.UserToken
+	err := r.db.Where("purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", purpose, tokenHash, now.UTC()).
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed はトークンを使用済みにする。既に使われていた場合は false を返す（同時に送られた同じトークンも 1 回しか通らない）。
+func (r *UserTokenRepository) MarkUsed(id uint, usedAt time.Time) (bool, error) {
+	result := r.db.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		UpdateColumn("used_at", usedAt.UTC())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// InvalidateByUser はユーザーの未使用のトークンを使用済みにする。パスワードの再設定後に古いリンクを無効にする。
+func (r *UserTokenRepository) InvalidateByUser(userID uint, purpose string, now time.Time) error {
+	return r.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		UpdateColumn("used_at", now.UTC()).Error
+}
+
+// CountSince は since 以降にユーザーに発行したトークンの数を返す。アカウントごとの送信数の制限に使う。
+func (r *UserTokenRepository) CountSince(userID uint, purpose string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at >= ?", userID, purpose, since.UTC()).
+		Count(&count).Error
+	return count, err
+}
+
+// DeleteExpired は期限切れのトークンを削除する。
+func (r *UserTokenRepository) DeleteExpired(before time.Time) error {
+	return r.db.Where("expires_at < ?", before.UTC()).Delete(&models.UserToken{}).Error
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type WebAuthnCredentialRepository struct {
+	db *gorm.DB
+}
+
+func NewWebAuthnCredentialRepository(db *gorm.DB) *WebAuthnCredentialRepository {
+	return &WebAuthnCredentialRepository{db: db}
+}
+
+func (r *WebAuthnCredentialRepository) Create(credential *models.WebAuthnCredential) error {
+	return r.db.Create(credential).Error
+}
+
+func (r *WebAuthnCredentialRepository) FindByID(id uint) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := r.db.First(&credential, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *WebAuthnCredentialRepository) FindByCredentialID(credentialID string) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := r.db.Where("credential_id = ?", credentialID).First(&credential).Error
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *WebAuthnCredentialRepository) FindByUserID(userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&credentials).Error
+	return credentials, err
+}
+
+func (r *WebAuthnCredentialRepository) CountByUserID(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// CountGroupedByUser はユーザーごとの登録数を返す（登録のないユーザーは含まない）。
+func (r *WebAuthnCredentialRepository) CountGroupedByUser() (map[uint]int64, error) {
+	var rows []struct {
+		UserID uint
+		Count  int64
+	}
+	err := r.db.Model(&models.WebAuthnCredential{}).
+		Select("user_id, COUNT(*) AS count").
+		Group("user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.UserID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *WebAuthnCredentialRepository) Update(credential *models.WebAuthnCredential) error {
+	return r.db.Save(credential).Error
+}
+
+func (r *WebAuthnCredentialRepository) Delete(id uint) error {
+	return r.db.Delete(&models.WebAuthnCredential{}, id).Error
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"time"
+
+	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) Create(webhook *models.Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *WebhookRepository) FindByID(id uint) (*models.Webhook, error) {
+	var webhook models.Webhook
+	err := r.db.First(&webhook, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *WebhookRepository) FindByUserID(userID uint) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *WebhookRepository) FindActiveByUserID(userID uint) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := r.db.Where("user_id = ? AND is_active = ?", userID, true).Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *WebhookRepository) Update(webhook *models.Webhook) error {
+	return r.db.Save(webhook).Error
+}
+
+func (r *WebhookRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Webhook{}, id).Error
+}
+
+func (r *WebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+func (r *WebhookRepository) FindDeliveryByID(id uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.db.First(&delivery, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *WebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
+
+func (r *WebhookRepository) FindDeliveriesByWebhookID(webhookID uint, limit, offset int) ([]models.WebhookDelivery, int64, error) {
+	var deliveries []models.WebhookDelivery
+	var totalCount int64
+
+	query := r.db.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", webhookID)
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&deliveries).Error
+	return deliveries, totalCount, err
+}
+
+// FindDueDeliveries は再送時刻を過ぎた送信待ちの配信を返す。
+func (r *WebhookRepository) FindDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now.UTC()).
+		Order("next_attempt_at ASC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ClaimDelivery は配信の試行回数を1つ進め、lease まで他のワーカーが取得しないようにする。
+// 他のワーカーが先に確保していれば false を返す。
+func (r *WebhookRepository) ClaimDelivery(delivery *models.WebhookDelivery, lease time.Time) (bool, error) {
+	result := r.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ?", delivery.ID, models.WebhookDeliveryPending, delivery.Attempts).
+		Updates(map[string]interface{}{
+			"attempts":        delivery.Attempts + 1,
+			"next_attempt_at": lease.UTC(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	delivery.Attempts++
+	delivery.NextAttemptAt = &lease
+	return true, nil
+}
+
+// FindNewlyOverdueAssignments は期限切れイベントを未送信の課題のうち、since 以降に期限を過ぎたものを返す。
+// 有効な Webhook を持つユーザーの課題のみが対象。
+func (r *WebhookRepository) FindNewlyOverdueAssignments(since, now time.Time) ([]models.Assignment, error) {
+	var assignments []models.Assignment
+	err := r.db.Where("is_completed = ? AND overdue_notified_at IS NULL AND due_date > ? AND due_date <= ?", false, since.UTC(), now.UTC()).
+		Where("user_id IN (?)", r.db.Model(&models.Webhook{}).Select("user_id").Where("is_active = ?", true)).
+		Find(&assignments).Error
+	return assignments, err
+}
+
+func (r *WebhookRepository) MarkOverdueNotified(assignmentID uint, at time.Time) error {
+	return r.db.Model(&models.Assignment{}).Where("id = ?", assignmentID).Update("overdue_notified_at", at.UTC()).Error
+}
@@ -0,0 +1,142 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/mail/mailtest"
+	"homework-manager/internal/models"
+)
+
+const mailBaseURL = "https://homework.example.com"
+
+var mailLinkPattern = regexp.MustCompile(regexp.QuoteMeta(mailBaseURL) + `(/[^\s"]+)`)
+
+// mailLink はメール本文のリンクを、テストサーバーに送れるパスとクエリにして返す。
+func mailLink(t *testing.T, msg *mailtest.Message, path string) string {
+	t.Helper()
+	match := mailLinkPattern.FindStringSubmatch(msg.Text)
+	if match == nil || !strings.HasPrefix(match[1], path+"?token=") {
+		t.Fatalf("link to %s not found in mail:\n%s", path, msg.Text)
+	}
+	if !strings.Contains(msg.HTML, match[1]) {
+		t.Errorf("HTML part does not contain the link %s", match[1])
+	}
+	return match[1]
+}
+
+func TestAccountMailRoundTrip(t *testing.T) {
+	sink := mailtest.Start(t)
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.BaseURL = mailBaseURL
+		cfg.RequireEmailVerification = true
+		cfg.SMTP = config.SMTPConfig{
+			Host:       sink.Host(),
+			Port:       sink.Port(),
+			From:       "noreply@example.com",
+			FromName:   "Super-HomeworkManager",
+			Encryption: "none",
+		}
+	})
+	const email = "mail@example.com"
+
+	resp, body := ts.postForm("/register", url.Values{
+		"_csrf":            {ts.csrfToken("/register")},
+		"email":            {email},
+		"password":         {"password123"},
+		"password_confirm": {"password123"},
+		"name":             {"メール確認"},
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("register: status %d\n%s", resp.StatusCode, body)
+	}
+
+	// メールアドレスの確認
+	msg := sink.Wait(t, 5*time.Second)
+	if len(msg.To) != 1 || msg.To[0] != email || msg.From != "noreply@example.com" || !strings.Contains(msg.Subject, "メールアドレスの確認") {
+		t.Fatalf("verification mail: from %q to %v, subject %q", msg.From, msg.To, msg.Subject)
+	}
+	verifyLink := mailLink(t, msg, "/verify-email")
+	if resp := ts.login(email, "password123"); resp.StatusCode != http.StatusOK {
+		t.Errorf("login before verification: status %d, want the login page", resp.StatusCode)
+	}
+	if _, body := ts.newSession().get(verifyLink); !strings.Contains(body, "メールアドレスを確認しました") {
+		t.Fatalf("verify: %s", body)
+	}
+	var user models.User
+	ts.db.Where("email = ?", email).First(&user)
+	if !user.EmailVerified() {
+		t.Fatal("email not verified after opening the link")
+	}
+	if _, body := ts.get(verifyLink); !strings.Contains(body, "確認リンクが無効か、有効期限が切れています") {
+		t.Error("verification link accepted twice")
+	}
+
+	// パスワードの再設定
+	guest := ts.newSession()
+	requestReset := func() string {
+		t.Helper()
+		resp, body := guest.postForm("/password/forgot", url.Values{
+			"_csrf": {guest.csrfToken("/password/forgot")},
+			"email": {email},
+		})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("forgot password: status %d\n%s", resp.StatusCode, body)
+		}
+		return mailLink(t, sink.Wait(t, 5*time.Second), "/password/reset")
+	}
+	resetPassword := func(link, password string) string {
+		t.Helper()
+		token, _ := url.QueryUnescape(strings.TrimPrefix(link, "/password/reset?token="))
+		_, body := guest.postForm("/password/reset", url.Values{
+			"_csrf":            {guest.csrfToken("/password/forgot")},
+			"token":            {token},
+			"password":         {password},
+			"password_confirm": {password},
+		})
+		return body
+	}
+	const invalidLink = "再設定リンクが無効か、有効期限が切れています"
+
+	resetLink := requestReset()
+	if _, body := guest.get(resetLink); strings.Contains(body, invalidLink) || !strings.Contains(body, `name="token"`) {
+		t.Fatalf("reset form: %s", body)
+	}
+	if body := resetPassword(resetLink, "new-password456"); !strings.Contains(body, "パスワードを再設定しました") {
+		t.Fatalf("reset: %s", body)
+	}
+	if resp := ts.newSession().login(email, "new-password456"); resp.StatusCode != http.StatusFound {
+		t.Errorf("login with the new password: status %d", resp.StatusCode)
+	}
+	if resp := ts.newSession().login(email, "password123"); resp.StatusCode == http.StatusFound {
+		t.Error("old password still works")
+	}
+
+	// 使用済みのリンクは使えない
+	if body := resetPassword(resetLink, "another-password789"); !strings.Contains(body, invalidLink) {
+		t.Error("reset link accepted twice")
+	}
+
+	// 有効期限が切れたリンクは使えない
+	expiredLink := requestReset()
+	ts.db.Model(&models.UserToken{}).Where("purpose = ? AND used_at IS NULL", models.TokenPurposePasswordReset).
+		Update("expires_at", time.Now().Add(-time.Minute))
+	if _, body := guest.get(expiredLink); !strings.Contains(body, invalidLink) {
+		t.Error("expired reset link shows the form")
+	}
+	if body := resetPassword(expiredLink, "another-password789"); !strings.Contains(body, invalidLink) {
+		t.Error("expired reset link accepted")
+	}
+	if resp := ts.newSession().login(email, "new-password456"); resp.StatusCode != http.StatusFound {
+		t.Errorf("password changed by a rejected link: status %d", resp.StatusCode)
+	}
+
+	if len(sink.Messages()) != 3 {
+		t.Errorf("%d mails sent, want 3", len(sink.Messages()))
+	}
+}
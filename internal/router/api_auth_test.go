@@ -0,0 +1,170 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.register("api@example.com", "password123")
+	keys := service.NewAPIKeyService(ts.db)
+
+	createKey := func(scopes []string, allowedIPs string) (string, *models.APIKey) {
+		t.Helper()
+		plain, key, err := keys.CreateAPIKey(user.ID, "test", scopes, nil, allowedIPs)
+		if err != nil {
+			t.Fatalf("CreateAPIKey: %v", err)
+		}
+		return plain, key
+	}
+
+	readKey, _ := createKey([]string{models.ScopeAssignmentsRead}, "")
+	writeKey, _ := createKey([]string{models.ScopeAssignmentsRead, models.ScopeAssignmentsWrite}, "")
+	localKey, local := createKey(models.APIKeyScopes, "127.0.0.1")
+	remoteKey, _ := createKey(models.APIKeyScopes, "192.0.2.0/24")
+
+	expiredKey, expired := createKey(models.APIKeyScopes, "")
+	ts.db.Model(expired).Update("expires_at", time.Now().Add(-time.Hour))
+
+	deletedKey, deleted := createKey(models.APIKeyScopes, "")
+	if err := keys.DeleteAPIKey(deleted.ID); err != nil {
+		t.Fatalf("DeleteAPIKey: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		body          string
+		wantStatus    int
+		wantError     string
+	}{
+		{"ヘッダーなし", "GET", "/api/v1/assignments", "", "", http.StatusUnauthorized, "Authorization header required"},
+		{"Bearer 以外", "GET", "/api/v1/assignments", "Basic " + readKey, "", http.StatusUnauthorized, "Invalid authorization format. Use: Bearer <api_key>"},
+		{"キーが空", "GET", "/api/v1/assignments", "Bearer ", "", http.StatusUnauthorized, "Invalid authorization format. Use: Bearer <api_key>"},
+		{"存在しないキー", "GET", "/api/v1/assignments", "Bearer hm_invalid", "", http.StatusUnauthorized, "Invalid API key"},
+		{"削除したキー", "GET", "/api/v1/assignments", "Bearer " + deletedKey, "", http.StatusUnauthorized, "Invalid API key"},
+		{"期限切れのキー", "GET", "/api/v1/assignments", "Bearer " + expiredKey, "", http.StatusUnauthorized, "API key expired"},
+		{"許可されていない IP", "GET", "/api/v1/assignments", "Bearer " + remoteKey, "", http.StatusForbidden, "IP address not allowed for this API key"},
+		{"許可された IP", "GET", "/api/v1/assignments", "Bearer " + localKey, "", http.StatusOK, ""},
+		{"読み取りスコープ", "GET", "/api/v1/assignments", "Bearer " + readKey, "", http.StatusOK, ""},
+		{"スコープ不足（統計）", "GET", "/api/v1/statistics", "Bearer " + readKey, "", http.StatusForbidden, "Missing required scope: statistics:read"},
+		{"スコープ不足（作成）", "POST", "/api/v1/assignments", "Bearer " + readKey, `{"title":"API","due_date":"2030-01-01T09:00:00Z"}`, http.StatusForbidden, "Missing required scope: assignments:write"},
+		{"書き込みスコープ", "POST", "/api/v1/assignments", "Bearer " + writeKey, `{"title":"API","due_date":"2030-01-01T09:00:00Z"}`, http.StatusCreated, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, respBody := ts.api(tt.method, tt.path, tt.authorization, tt.body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d\n%s", resp.StatusCode, tt.wantStatus, respBody)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var payload struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(respBody), &payload); err != nil {
+				t.Fatalf("decode error response: %v\n%s", err, respBody)
+			}
+			if payload.Error != tt.wantError {
+				t.Errorf("error = %q, want %q", payload.Error, tt.wantError)
+			}
+		})
+	}
+
+	// 使用日時と接続元を記録する
+	ts.db.First(local, local.ID)
+	if local.LastUsed == nil || local.LastUsedIP != "127.0.0.1" {
+		t.Errorf("LastUsed/LastUsedIP = %v/%q, want recorded", local.LastUsed, local.LastUsedIP)
+	}
+}
+
+func TestAPIKeyUserIsolation(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.register("owner@example.com", "password123")
+	other := ts.newSession().register("other@example.com", "password123")
+
+	keys := service.NewAPIKeyService(ts.db)
+	ownerKey, _, err := keys.CreateAPIKey(owner.ID, "owner", models.APIKeyScopes, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	otherKey, _, err := keys.CreateAPIKey(other.ID, "other", models.APIKeyScopes, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	resp, body := ts.api("POST", "/api/v1/assignments", "Bearer "+ownerKey, `{"title":"owner","due_date":"2030-01-01T09:00:00Z"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: status %d\n%s", resp.StatusCode, body)
+	}
+	var created models.Assignment
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatalf("decode: %v\n%s", err, body)
+	}
+
+	path := "/api/v1/assignments/" + strconv.FormatUint(uint64(created.ID), 10)
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{"所有者", ownerKey, http.StatusOK},
+		{"他のユーザー", otherKey, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := ts.api("GET", path, "Bearer "+tt.key, "")
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d\n%s", resp.StatusCode, tt.wantStatus, body)
+			}
+		})
+	}
+}
+
+func TestAPIRateLimitBeforeAuth(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.RateLimit.Enabled = true
+		cfg.RateLimit.API = config.RateLimitPolicyConfig{Requests: 2, Window: 60, By: "api_key"}
+	})
+	user := ts.register("limit@example.com", "password123")
+	validKey, _, err := service.NewAPIKeyService(ts.db).CreateAPIKey(user.ID, "limit", models.APIKeyScopes, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	// 無効なキー・ヘッダーなしのリクエストは同じ IP の枠で数え、制限を超えたら認証の前に 429 を返す
+	for i, authorization := range []string{"Bearer hm_guess1", ""} {
+		if resp, body := ts.api("GET", "/api/v1/assignments", authorization, ""); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("request %d: status = %d, want 401\n%s", i, resp.StatusCode, body)
+		}
+	}
+	resp, body := ts.api("GET", "/api/v1/assignments", "Bearer hm_guess2", "")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429\n%s", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("Retry-After header missing")
+	}
+
+	// 有効なキーはキーごとに数える
+	for i := 0; i < 2; i++ {
+		if resp, body := ts.api("GET", "/api/v1/assignments", "Bearer "+validKey, ""); resp.StatusCode != http.StatusOK {
+			t.Fatalf("valid key request %d: status = %d, want 200\n%s", i, resp.StatusCode, body)
+		}
+	}
+	if resp, _ := ts.api("GET", "/api/v1/assignments", "Bearer "+validKey, ""); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("valid key over limit: status = %d, want 429", resp.StatusCode)
+	}
+}
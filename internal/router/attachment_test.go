@@ -0,0 +1,227 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+	"homework-manager/internal/storage/s3test"
+	"homework-manager/internal/testutil"
+)
+
+func TestSetupRejectsInvalidStorage(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage config.StorageConfig
+	}{
+		{"保存先の種類が不明", config.StorageConfig{Backend: "ftp"}},
+		{"ローカルのパスが未設定", config.StorageConfig{Backend: "local"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testutil.Config()
+			cfg.Storage = tt.storage
+			if _, err := Setup(cfg, testutil.OpenDB(t)); err == nil {
+				t.Error("Setup succeeded with an invalid storage config")
+			}
+		})
+	}
+}
+
+func TestAttachmentAPIWithS3(t *testing.T) {
+	mock := s3test.New("test-access", "test-secret", "us-east-1", "attachments")
+	s3 := httptest.NewServer(mock)
+	t.Cleanup(s3.Close)
+
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Storage = config.StorageConfig{
+			Backend:     "s3",
+			S3Endpoint:  s3.URL,
+			S3Region:    "us-east-1",
+			S3Bucket:    "attachments",
+			S3AccessKey: "test-access",
+			S3SecretKey: "test-secret",
+			S3PathStyle: true,
+			MaxFileSize: 1,
+			UserQuota:   100,
+		}
+	})
+	owner := ts.register("attachments@example.com", "password123")
+	other := ts.newSession().register("other@example.com", "password123")
+
+	keys := service.NewAPIKeyService(ts.db)
+	ownerKey, _, err := keys.CreateAPIKey(owner.ID, "owner", models.APIKeyScopes, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	otherKey, _, err := keys.CreateAPIKey(other.ID, "other", models.APIKeyScopes, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	auth := "Bearer " + ownerKey
+
+	resp, body := ts.api("POST", "/api/v1/assignments", auth, `{"title":"実験レポート","due_date":"2030-01-01"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create assignment: status %d\n%s", resp.StatusCode, body)
+	}
+	var assignment models.Assignment
+	json.Unmarshal([]byte(body), &assignment)
+	attachments := "/api/v1/assignments/" + strconv.FormatUint(uint64(assignment.ID), 10) + "/attachments"
+
+	pdf := []byte("%PDF-1.4\n%worksheet")
+	resp, body = ts.upload(attachments, auth, nil, "プリント.pdf", pdf)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload: status %d\n%s", resp.StatusCode, body)
+	}
+	var created models.Attachment
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatalf("decode: %v\n%s", err, body)
+	}
+	if created.ContentType != "application/pdf" || created.FileName != "プリント.pdf" || mock.Len("attachments") != 1 {
+		t.Fatalf("created = %+v, %d object(s) in S3", created, mock.Len("attachments"))
+	}
+	file := attachments + "/" + strconv.FormatUint(uint64(created.ID), 10)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		wantStatus int
+	}{
+		{"一覧", "GET", attachments, ownerKey, http.StatusOK},
+		{"他のユーザーの一覧", "GET", attachments, otherKey, http.StatusNotFound},
+		{"他のユーザーはダウンロードできない", "GET", file, otherKey, http.StatusNotFound},
+		{"他のユーザーは削除できない", "DELETE", file, otherKey, http.StatusNotFound},
+		{"存在しない添付ファイル", "GET", attachments + "/999999", ownerKey, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := ts.api(tt.method, tt.path, "Bearer "+tt.key, "")
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d\n%s", resp.StatusCode, tt.wantStatus, body)
+			}
+		})
+	}
+
+	uploads := []struct {
+		name       string
+		fileName   string
+		content    []byte
+		wantStatus int
+	}{
+		{"HTML は添付できない", "page.pdf", []byte("<!DOCTYPE html><script>alert(1)</script>"), http.StatusUnsupportedMediaType},
+		{"上限を超える", "large.pdf", append([]byte("%PDF-1.4\n"), make([]byte, 1<<20)...), http.StatusRequestEntityTooLarge},
+		{"空のファイル", "empty.txt", nil, http.StatusBadRequest},
+	}
+	for _, tt := range uploads {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := ts.upload(attachments, auth, nil, tt.fileName, tt.content)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d\n%s", resp.StatusCode, tt.wantStatus, body)
+			}
+		})
+	}
+
+	resp, body = ts.api("GET", file, auth, "")
+	if resp.StatusCode != http.StatusOK || body != string(pdf) {
+		t.Fatalf("download: status %d, body %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/pdf" {
+		t.Errorf("Content-Type = %q, want application/pdf", got)
+	}
+	if got := resp.Header.Get("Content-Disposition"); !strings.HasPrefix(got, "inline; filename*=utf-8''") {
+		t.Errorf("Content-Disposition = %q, want inline with an encoded file name", got)
+	}
+
+	if resp, body := ts.api("DELETE", file, auth, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete: status %d\n%s", resp.StatusCode, body)
+	}
+	if mock.Len("attachments") != 0 {
+		t.Errorf("%d object(s) left in S3 after delete", mock.Len("attachments"))
+	}
+}
+
+func TestAttachmentPages(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("pages@example.com", "password123")
+	stranger := ts.newSession()
+	stranger.register("stranger@example.com", "password123")
+
+	resp, body := ts.postForm("/assignments", url.Values{
+		"_csrf":    {ts.csrfToken("/assignments/new")},
+		"title":    {"観察日記"},
+		"priority": {"medium"},
+		"due_date": {"2030-01-01T09:00"},
+	})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("create: status %d\n%s", resp.StatusCode, body)
+	}
+	var assignment models.Assignment
+	if err := ts.db.Where("title = ?", "観察日記").First(&assignment).Error; err != nil {
+		t.Fatalf("find assignment: %v", err)
+	}
+	base := "/assignments/" + strconv.FormatUint(uint64(assignment.ID), 10)
+	token := ts.csrfToken(base + "/edit")
+
+	resp, body = ts.upload(base+"/attachments", "", url.Values{"_csrf": {token}}, "memo.txt", []byte("朝顔が咲いた"))
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != base+"/edit#attachments" {
+		t.Fatalf("upload: status %d, location %q\n%s", resp.StatusCode, resp.Header.Get("Location"), body)
+	}
+	resp, body = ts.upload(base+"/attachments", "", url.Values{"_csrf": {token}}, "script.txt", []byte("<html><body>x</body></html>"))
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "この種類のファイルは添付できません") {
+		t.Errorf("upload of HTML: status %d, error message not shown", resp.StatusCode)
+	}
+
+	var attachment models.Attachment
+	if err := ts.db.Where("assignment_id = ?", assignment.ID).First(&attachment).Error; err != nil {
+		t.Fatalf("find attachment: %v", err)
+	}
+	file := base + "/attachments/" + strconv.FormatUint(uint64(attachment.ID), 10)
+
+	pages := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"編集", base + "/edit", "memo.txt"},
+		{"一覧", "/assignments", "bi-paperclip"},
+	}
+	for _, p := range pages {
+		t.Run(p.name, func(t *testing.T) {
+			resp, body := ts.get(p.path)
+			if resp.StatusCode != http.StatusOK || !strings.Contains(body, p.want) {
+				t.Errorf("%s: status %d, %q not found", p.path, resp.StatusCode, p.want)
+			}
+		})
+	}
+
+	resp, body = ts.get(file)
+	if resp.StatusCode != http.StatusOK || body != "朝顔が咲いた" {
+		t.Fatalf("download: status %d, body %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Disposition"); got != `attachment; filename=memo.txt` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if resp, _ := stranger.get(file); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("download by another user: status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if resp, _ := ts.newSession().get(file); resp.StatusCode != http.StatusFound {
+		t.Errorf("download without login: status %d, want redirect to login", resp.StatusCode)
+	}
+
+	resp, _ = ts.postForm(file+"/delete", url.Values{"_csrf": {token}})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	if resp, _ := ts.get(file); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("download after delete: status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
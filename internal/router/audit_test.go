@@ -0,0 +1,159 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+)
+
+// userAgentTransport はすべてのリクエストに User-Agent を付ける。
+type userAgentTransport struct {
+	userAgent string
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// withUserAgent はこのセッションのリクエストに userAgent を付ける。
+func (ts *testServer) withUserAgent(userAgent string) *testServer {
+	ts.client.Transport = userAgentTransport{userAgent: userAgent}
+	return ts
+}
+
+// auditEvents は /admin/audit/export で query に合う監査ログを新しい順に返す。
+func (ts *testServer) auditEvents(query url.Values) []models.AuditEvent {
+	ts.t.Helper()
+	resp, body := ts.get("/admin/audit/export?" + query.Encode())
+	if resp.StatusCode != http.StatusOK {
+		ts.t.Fatalf("audit export: status %d\n%s", resp.StatusCode, body)
+	}
+	var export struct {
+		Count  int                 `json:"count"`
+		Events []models.AuditEvent `json:"events"`
+	}
+	if err := json.Unmarshal([]byte(body), &export); err != nil {
+		ts.t.Fatalf("decode audit export: %v", err)
+	}
+	return export.Events
+}
+
+func TestAuditEventsRecordActorTargetAndClient(t *testing.T) {
+	const password = "password123"
+	ts := newTestServer(t)
+	admin := ts.withUserAgent("AdminBrowser/1.0").register("admin@example.com", password)
+	student := ts.newSession().withUserAgent("StudentBrowser/1.0").register("student@example.com", password)
+	ts.newSession().register("delete@example.com", password)
+	var deleted models.User
+	ts.db.Where("email = ?", "delete@example.com").First(&deleted)
+
+	// ログインの失敗と成功
+	browser := ts.newSession().withUserAgent("StudentBrowser/2.0")
+	browser.login(student.Email, "wrong-password")
+	if resp := browser.login(student.Email, password); resp.StatusCode != http.StatusFound {
+		t.Fatalf("login: status %d", resp.StatusCode)
+	}
+	// 管理者以外は監査ログを見られない
+	if resp, _ := browser.get("/admin/audit"); resp.StatusCode == http.StatusOK {
+		t.Error("a student could open the audit log")
+	}
+
+	// 権限の変更、ユーザーの削除
+	ts.postForm(fmt.Sprintf("/admin/users/%d/role", student.ID), url.Values{"_csrf": {ts.csrfToken("/admin/users")}, "role": {"admin"}})
+	ts.postForm(fmt.Sprintf("/admin/users/%d/delete", deleted.ID), url.Values{"_csrf": {ts.csrfToken("/admin/users")}})
+
+	// 科目のアーカイブ
+	subject := &models.Subject{UserID: admin.ID, Name: "数学"}
+	if err := ts.db.Create(subject).Error; err != nil {
+		t.Fatalf("create subject: %v", err)
+	}
+	ts.postForm(fmt.Sprintf("/subjects/%d/archive", subject.ID), url.Values{"_csrf": {ts.csrfToken("/subjects")}})
+
+	studentID := strconv.FormatUint(uint64(student.ID), 10)
+	tests := []struct {
+		action     string
+		actorID    *uint
+		actorEmail string
+		targetType string
+		targetID   string
+		userAgent  string
+	}{
+		{models.AuditLoginFailure, &student.ID, student.Email, models.AuditTargetUser, studentID, "StudentBrowser/2.0"},
+		{models.AuditLoginSuccess, &student.ID, student.Email, models.AuditTargetUser, studentID, "StudentBrowser/2.0"},
+		{models.AuditUserRoleChange, &admin.ID, admin.Email, models.AuditTargetUser, studentID, "AdminBrowser/1.0"},
+		{models.AuditUserDelete, &admin.ID, admin.Email, models.AuditTargetUser, strconv.FormatUint(uint64(deleted.ID), 10), "AdminBrowser/1.0"},
+		{models.AuditSubjectArchive, &admin.ID, admin.Email, models.AuditTargetSubject, strconv.FormatUint(uint64(subject.ID), 10), "AdminBrowser/1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			events := ts.auditEvents(url.Values{"action": {tt.action}})
+			if len(events) != 1 {
+				t.Fatalf("%d event(s), want 1", len(events))
+			}
+			event := events[0]
+			if event.ActorID == nil || *event.ActorID != *tt.actorID || event.ActorEmail != tt.actorEmail {
+				t.Errorf("actor = %v %q, want %d %q", event.ActorID, event.ActorEmail, *tt.actorID, tt.actorEmail)
+			}
+			if event.TargetType != tt.targetType || event.TargetID != tt.targetID || event.TargetLabel == "" {
+				t.Errorf("target = %s %s %q, want %s %s", event.TargetType, event.TargetID, event.TargetLabel, tt.targetType, tt.targetID)
+			}
+			if event.IP != "127.0.0.1" || event.UserAgent != tt.userAgent {
+				t.Errorf("client = %s %q, want 127.0.0.1 %q", event.IP, event.UserAgent, tt.userAgent)
+			}
+		})
+	}
+}
+
+func TestAuditLogFilterAndPagination(t *testing.T) {
+	ts := newTestServer(t)
+	admin := ts.register("admin@example.com", "password123")
+
+	audit := service.NewAuditService(ts.db)
+	for i := 1; i <= 60; i++ {
+		audit.Record(service.AuditActor{UserID: &admin.ID, IP: "192.0.2.1"}, models.AuditAPIKeyCreate,
+			service.AuditTarget{Type: models.AuditTargetAPIKey, ID: strconv.Itoa(i), Label: fmt.Sprintf("key-%02d", i)}, nil)
+	}
+	audit.Record(service.AuditActor{UserID: &admin.ID, IP: "198.51.100.1"}, models.AuditAPIKeyDelete,
+		service.AuditTarget{Type: models.AuditTargetAPIKey, ID: "1", Label: "key-01"}, nil)
+
+	tests := []struct {
+		name      string
+		query     url.Values
+		wantCount int
+	}{
+		{"操作", url.Values{"action": {models.AuditAPIKeyDelete}}, 1},
+		{"操作者の部分一致", url.Values{"action": {models.AuditAPIKeyCreate}, "actor": {"admin@"}}, 60},
+		{"対象", url.Values{"target_type": {models.AuditTargetAPIKey}, "target_id": {"1"}}, 2},
+		{"IP", url.Values{"ip": {"198.51.100.1"}}, 1},
+		{"一致しない操作者", url.Values{"actor": {"nobody@"}}, 0},
+	}
+	for _, tt := range tests {
+		if got := ts.auditEvents(tt.query); len(got) != tt.wantCount {
+			t.Errorf("%s: %d event(s), want %d", tt.name, len(got), tt.wantCount)
+		}
+	}
+
+	// 1ページ50件で、2ページ目には古い10件を表示し、ページ移動のリンクに絞り込み条件を引き継ぐ
+	resp, body := ts.get("/admin/audit?action=" + models.AuditAPIKeyCreate + "&page=2")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("audit page: status %d", resp.StatusCode)
+	}
+	if !strings.Contains(body, "2 / 2") {
+		t.Error("page indicator 2 / 2 not found")
+	}
+	if !strings.Contains(body, "key-10") || strings.Contains(body, "key-11") {
+		t.Error("page 2 does not show exactly the 10 oldest events")
+	}
+	if !strings.Contains(body, "action="+models.AuditAPIKeyCreate+"&page=1") {
+		t.Error("link to the previous page does not keep the filter")
+	}
+}
@@ -0,0 +1,56 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"homework-manager/internal/models"
+)
+
+var calendarPathPattern = regexp.MustCompile(`data-path="(/calendar/[0-9a-f]+\.ics)"`)
+
+func TestCalendarFeedToken(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.register("calendar@example.com", "password123")
+
+	rotate := func() string {
+		t.Helper()
+		resp, body := ts.postForm("/profile/calendar/rotate", url.Values{"_csrf": {ts.csrfToken("/profile")}})
+		match := calendarPathPattern.FindStringSubmatch(body)
+		if resp.StatusCode != http.StatusOK || match == nil {
+			t.Fatalf("rotate: status %d, feed URL not shown", resp.StatusCode)
+		}
+		return match[1]
+	}
+
+	if _, body := ts.get("/profile"); calendarPathPattern.MatchString(body) {
+		t.Fatal("feed URL shown before it was issued")
+	}
+	feed := rotate()
+	token := strings.TrimSuffix(strings.TrimPrefix(feed, "/calendar/"), ".ics")
+
+	var stored models.User
+	ts.db.First(&stored, user.ID)
+	if stored.CalendarTokenHash == "" || stored.CalendarTokenHash == token {
+		t.Errorf("stored token hash = %q", stored.CalendarTokenHash)
+	}
+	if _, body := ts.get("/profile"); strings.Contains(body, token) {
+		t.Error("feed token shown again after it was issued")
+	}
+
+	resp, body := ts.get(feed)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "BEGIN:VCALENDAR") {
+		t.Fatalf("feed: status %d\n%s", resp.StatusCode, body)
+	}
+
+	rotated := rotate()
+	if resp, _ := ts.get(feed); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("old feed URL: status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if resp, _ := ts.get(rotated); resp.StatusCode != http.StatusOK {
+		t.Errorf("new feed URL: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,191 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+)
+
+func TestChecklistAPI(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.register("checklist@example.com", "password123")
+	other := ts.newSession().register("other@example.com", "password123")
+
+	keys := service.NewAPIKeyService(ts.db)
+	ownerKey, _, err := keys.CreateAPIKey(owner.ID, "owner", models.APIKeyScopes, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	otherKey, _, err := keys.CreateAPIKey(other.ID, "other", models.APIKeyScopes, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	auth := "Bearer " + ownerKey
+
+	resp, body := ts.api("POST", "/api/v1/assignments", auth,
+		`{"title":"レポート","due_date":"2030-01-01T09:00:00Z","checklist":["調べる","書く"],"checklist_auto_complete":true}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create assignment: status %d\n%s", resp.StatusCode, body)
+	}
+	var assignment models.Assignment
+	if err := json.Unmarshal([]byte(body), &assignment); err != nil {
+		t.Fatalf("decode: %v\n%s", err, body)
+	}
+	if len(assignment.ChecklistItems) != 2 || !assignment.ChecklistAutoComplete {
+		t.Fatalf("created assignment = %+v, want 2 items with auto complete", assignment)
+	}
+	items := "/api/v1/assignments/" + strconv.FormatUint(uint64(assignment.ID), 10) + "/items"
+
+	resp, body = ts.api("POST", items, auth, `{"title":"提出する","due_date":"2029-12-31T18:00"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create item: status %d\n%s", resp.StatusCode, body)
+	}
+	var created models.ChecklistItem
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatalf("decode: %v\n%s", err, body)
+	}
+	if created.Position != 2 || created.DueDate == nil {
+		t.Errorf("created item = %+v, want position 2 with due date", created)
+	}
+	itemPath := items + "/" + strconv.FormatUint(uint64(created.ID), 10)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		body       string
+		wantStatus int
+	}{
+		{"一覧", "GET", items, ownerKey, "", http.StatusOK},
+		{"他のユーザーの一覧", "GET", items, otherKey, "", http.StatusNotFound},
+		{"他のユーザーは追加できない", "POST", items, otherKey, `{"title":"x"}`, http.StatusNotFound},
+		{"タイトルなし", "POST", items, ownerKey, `{"title":""}`, http.StatusBadRequest},
+		{"不正な期限", "POST", items, ownerKey, `{"title":"x","due_date":"tomorrow"}`, http.StatusBadRequest},
+		{"先頭へ移動して期限を解除", "PUT", itemPath, ownerKey, `{"position":0,"due_date":""}`, http.StatusOK},
+		{"他のユーザーは更新できない", "PUT", itemPath, otherKey, `{"title":"x"}`, http.StatusNotFound},
+		{"存在しない項目", "PATCH", items + "/999999/toggle", ownerKey, "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := ts.api(tt.method, tt.path, "Bearer "+tt.key, tt.body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d\n%s", resp.StatusCode, tt.wantStatus, body)
+			}
+		})
+	}
+
+	// すべての項目を完了にすると課題も完了になる
+	var list struct {
+		Items    []models.ChecklistItem   `json:"items"`
+		Progress models.ChecklistProgress `json:"progress"`
+	}
+	_, body = ts.api("GET", items, auth, "")
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		t.Fatalf("decode: %v\n%s", err, body)
+	}
+	if len(list.Items) != 3 || list.Items[0].ID != created.ID || list.Items[0].DueDate != nil {
+		t.Fatalf("items = %+v, want moved item first without due date", list.Items)
+	}
+	for _, item := range list.Items {
+		path := items + "/" + strconv.FormatUint(uint64(item.ID), 10)
+		if resp, body := ts.api("PUT", path, auth, `{"is_done":true}`); resp.StatusCode != http.StatusOK {
+			t.Fatalf("check item: status %d\n%s", resp.StatusCode, body)
+		}
+	}
+
+	_, body = ts.api("GET", "/api/v1/assignments/"+strconv.FormatUint(uint64(assignment.ID), 10), auth, "")
+	if err := json.Unmarshal([]byte(body), &assignment); err != nil {
+		t.Fatalf("decode: %v\n%s", err, body)
+	}
+	if !assignment.IsCompleted || len(assignment.ChecklistItems) != 3 {
+		t.Errorf("assignment = %+v, want completed with 3 items", assignment)
+	}
+
+	if resp, body := ts.api("DELETE", itemPath, auth, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete item: status %d\n%s", resp.StatusCode, body)
+	}
+	var count int64
+	ts.db.Model(&models.ChecklistItem{}).Where("assignment_id = ?", assignment.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("%d item(s) left, want 2", count)
+	}
+}
+
+func TestChecklistPages(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("pages@example.com", "password123")
+
+	resp, body := ts.postForm("/assignments", url.Values{
+		"_csrf":     {ts.csrfToken("/assignments/new")},
+		"title":     {"自由研究"},
+		"priority":  {"medium"},
+		"due_date":  {"2030-01-01T09:00"},
+		"checklist": {"テーマを決める\n実験する"},
+	})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("create: status %d\n%s", resp.StatusCode, body)
+	}
+
+	var assignment models.Assignment
+	if err := ts.db.Preload("ChecklistItems").Where("title = ?", "自由研究").First(&assignment).Error; err != nil {
+		t.Fatalf("find assignment: %v", err)
+	}
+	if len(assignment.ChecklistItems) != 2 {
+		t.Fatalf("created %d item(s), want 2", len(assignment.ChecklistItems))
+	}
+	base := "/assignments/" + strconv.FormatUint(uint64(assignment.ID), 10)
+	first := base + "/items/" + strconv.FormatUint(uint64(assignment.ChecklistItems[0].ID), 10)
+
+	token := ts.csrfToken(base + "/edit")
+	for _, step := range []struct {
+		path string
+		form url.Values
+	}{
+		{base + "/items", url.Values{"title": {"まとめる"}}},
+		{first + "/toggle", url.Values{}},
+		{first + "/move", url.Values{"direction": {"down"}}},
+	} {
+		step.form.Set("_csrf", token)
+		resp, body := ts.postForm(step.path, step.form)
+		if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != base+"/edit#checklist" {
+			t.Fatalf("POST %s: status %d, location %q\n%s", step.path, resp.StatusCode, resp.Header.Get("Location"), body)
+		}
+	}
+
+	pages := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"編集", base + "/edit", "1/3（33%）"},
+		{"一覧", "/assignments", "33%"},
+		{"ダッシュボード", "/", ""},
+	}
+	for _, p := range pages {
+		t.Run(p.name, func(t *testing.T) {
+			resp, body := ts.get(p.path)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d\n%s", resp.StatusCode, body)
+			}
+			if !strings.Contains(body, p.want) {
+				t.Errorf("%s does not contain %q", p.path, p.want)
+			}
+		})
+	}
+
+	items, _ := service.NewChecklistService(ts.db).List(assignment.UserID, assignment.ID)
+	var titles []string
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+	if strings.Join(titles, ",") != "実験する,テーマを決める,まとめる" {
+		t.Errorf("titles = %v", titles)
+	}
+}
@@ -0,0 +1,91 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("csrf@example.com", "password123")
+	token := ts.csrfToken("/assignments/new")
+
+	// 別のセッションで発行された、署名としては正しいトークン
+	otherToken := ts.newSession().csrfToken("/login")
+
+	form := func(csrf string) url.Values {
+		values := url.Values{
+			"title":    {"CSRF テスト"},
+			"subject":  {"数学"},
+			"priority": {"medium"},
+			"due_date": {"2030-01-01T09:00"},
+		}
+		if csrf != "" {
+			values.Set("_csrf", csrf)
+		}
+		return values
+	}
+
+	tests := []struct {
+		name       string
+		formToken  string
+		header     string
+		wantStatus int
+	}{
+		{"トークンなし", "", "", http.StatusForbidden},
+		{"不正なトークン", "invalid", "", http.StatusForbidden},
+		{"他のセッションのトークン", otherToken, "", http.StatusForbidden},
+		{"フォームのトークン", token, "", http.StatusFound},
+		{"ヘッダーのトークン", "", token, http.StatusFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.server.URL+"/assignments", strings.NewReader(form(tt.formToken).Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.header != "" {
+				req.Header.Set("X-CSRF-Token", tt.header)
+			}
+
+			resp, body := ts.do(ts.client, req)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d\n%s", resp.StatusCode, tt.wantStatus, body)
+			}
+		})
+	}
+
+	var count int64
+	ts.db.Table("assignments").Count(&count)
+	if count != 2 {
+		t.Errorf("created %d assignment(s), want 2", count)
+	}
+}
+
+func TestCSRFGuestForms(t *testing.T) {
+	ts := newTestServer(t)
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"ログイン", "/login"},
+		{"登録", "/register"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := ts.postForm(tt.path, url.Values{
+				"email":    {"guest@example.com"},
+				"password": {"password123"},
+			})
+			if resp.StatusCode != http.StatusForbidden {
+				t.Errorf("POST %s without token: status = %d, want %d", tt.path, resp.StatusCode, http.StatusForbidden)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+)
+
+func TestGroupAPI(t *testing.T) {
+	ts := newTestServer(t)
+	teacher := ts.register("teacher@example.com", "password123")
+	ts.db.Model(teacher).Update("role", "teacher")
+	student := ts.newSession().register("student@example.com", "password123")
+	outsider := ts.newSession().register("outsider@example.com", "password123")
+
+	keys := service.NewAPIKeyService(ts.db)
+	key := func(user *models.User) string {
+		k, _, err := keys.CreateAPIKey(user.ID, "key", models.APIKeyScopes, nil, "")
+		if err != nil {
+			t.Fatalf("CreateAPIKey: %v", err)
+		}
+		return "Bearer " + k
+	}
+	teacherAuth, studentAuth, outsiderAuth := key(teacher), key(student), key(outsider)
+
+	resp, body := ts.api("POST", "/api/v1/groups", studentAuth, `{"name":"1年2組"}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("student creating a group: status %d\n%s", resp.StatusCode, body)
+	}
+	resp, body = ts.api("POST", "/api/v1/groups", teacherAuth, `{"name":"1年2組"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create group: status %d\n%s", resp.StatusCode, body)
+	}
+	var group struct {
+		ID       uint   `json:"id"`
+		JoinCode string `json:"join_code"`
+		Role     string `json:"role"`
+	}
+	json.Unmarshal([]byte(body), &group)
+	groupPath := "/api/v1/groups/" + strconv.FormatUint(uint64(group.ID), 10)
+
+	resp, body = ts.api("POST", "/api/v1/groups/join", studentAuth, `{"code":"`+group.JoinCode+`"}`)
+	if resp.StatusCode != http.StatusCreated || !strings.Contains(body, `"role":"student"`) || strings.Contains(body, group.JoinCode) {
+		t.Fatalf("join: status %d\n%s", resp.StatusCode, body)
+	}
+
+	resp, body = ts.api("POST", groupPath+"/assignments", teacherAuth, `{"title":"漢字ドリル","subject":"国語","due_date":"2030-01-10T09:00"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("distribute: status %d\n%s", resp.StatusCode, body)
+	}
+	var source models.GroupAssignment
+	json.Unmarshal([]byte(body), &source)
+	sourcePath := groupPath + "/assignments/" + strconv.FormatUint(uint64(source.ID), 10)
+
+	resp, body = ts.api("GET", "/api/v1/assignments", studentAuth, "")
+	if !strings.Contains(body, "漢字ドリル") || !strings.Contains(body, `"group_assignment_id":`+strconv.FormatUint(uint64(source.ID), 10)) {
+		t.Fatalf("student's assignments: status %d\n%s", resp.StatusCode, body)
+	}
+
+	resp, body = ts.api("PUT", sourcePath, teacherAuth, `{"title":"漢字ドリル p.20","due_date":"2030-01-11T09:00","edit_behavior":"all"}`)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"updated_assignments":1`) {
+		t.Fatalf("update: status %d\n%s", resp.StatusCode, body)
+	}
+	if _, body = ts.api("GET", "/api/v1/assignments", studentAuth, ""); !strings.Contains(body, "漢字ドリル p.20") {
+		t.Errorf("edit did not propagate: %s", body)
+	}
+
+	resp, body = ts.api("GET", sourcePath, teacherAuth, "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"status":"pending"`) || !strings.Contains(body, `"total":1`) {
+		t.Errorf("progress: status %d\n%s", resp.StatusCode, body)
+	}
+	resp, body = ts.api("GET", groupPath, teacherAuth, "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"email":"student@example.com"`) || strings.Contains(body, "password") {
+		t.Errorf("group for the teacher: status %d\n%s", resp.StatusCode, body)
+	}
+	resp, body = ts.api("GET", groupPath, studentAuth, "")
+	if resp.StatusCode != http.StatusOK || strings.Contains(body, `"members"`) || strings.Contains(body, group.JoinCode) {
+		t.Errorf("group for a student: status %d\n%s", resp.StatusCode, body)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		auth       string
+		body       string
+		wantStatus int
+	}{
+		{"一覧", "GET", "/api/v1/groups", studentAuth, "", http.StatusOK},
+		{"配布した課題の一覧", "GET", groupPath + "/assignments", teacherAuth, "", http.StatusOK},
+		{"生徒は提出状況を見られない", "GET", sourcePath, studentAuth, "", http.StatusForbidden},
+		{"生徒は配布できない", "POST", groupPath + "/assignments", studentAuth, `{"title":"x","due_date":"2030-01-10"}`, http.StatusForbidden},
+		{"参加していないグループ", "GET", groupPath, outsiderAuth, "", http.StatusNotFound},
+		{"参加コードの誤り", "POST", "/api/v1/groups/join", outsiderAuth, `{"code":"NOPE"}`, http.StatusBadRequest},
+		{"二重参加", "POST", "/api/v1/groups/join", studentAuth, `{"code":"` + group.JoinCode + `"}`, http.StatusConflict},
+		{"不正な反映方法", "PUT", sourcePath, teacherAuth, `{"title":"x","due_date":"2030-01-10","edit_behavior":"some"}`, http.StatusBadRequest},
+		{"最後の教師は抜けられない", "POST", groupPath + "/leave", teacherAuth, "", http.StatusConflict},
+		{"生徒が抜ける", "POST", groupPath + "/leave", studentAuth, "", http.StatusOK},
+		{"配布の取り消し", "DELETE", sourcePath, teacherAuth, "", http.StatusOK},
+		{"取り消した課題", "GET", sourcePath, teacherAuth, "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := ts.api(tt.method, tt.path, tt.auth, tt.body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d\n%s", resp.StatusCode, tt.wantStatus, body)
+			}
+		})
+	}
+
+	if _, body = ts.api("GET", "/api/v1/assignments", studentAuth, ""); strings.Contains(body, "漢字ドリル") {
+		t.Errorf("pending copy remained after leaving: %s", body)
+	}
+}
+
+func TestGroupPages(t *testing.T) {
+	ts := newTestServer(t)
+	teacher := ts.register("teacher-pages@example.com", "password123")
+	ts.db.Model(teacher).Update("role", "teacher")
+	student := ts.newSession()
+	studentUser := student.register("student-pages@example.com", "password123")
+
+	resp, body := ts.postForm("/groups", url.Values{
+		"_csrf": {ts.csrfToken("/groups")},
+		"name":  {"3年4組"},
+	})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("create: status %d\n%s", resp.StatusCode, body)
+	}
+	var group models.Group
+	if err := ts.db.Where("name = ?", "3年4組").First(&group).Error; err != nil {
+		t.Fatalf("find group: %v", err)
+	}
+	base := "/groups/" + strconv.FormatUint(uint64(group.ID), 10)
+
+	resp, body = student.postForm("/groups/join", url.Values{
+		"_csrf": {student.csrfToken("/groups")},
+		"code":  {strings.ToLower(group.JoinCode)},
+	})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("join: status %d\n%s", resp.StatusCode, body)
+	}
+
+	resp, body = ts.postForm(base+"/assignments", url.Values{
+		"_csrf":    {ts.csrfToken(base)},
+		"title":    {"自由研究"},
+		"priority": {"high"},
+		"due_date": {"2030-08-31T09:00"},
+	})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("distribute: status %d\n%s", resp.StatusCode, body)
+	}
+	var source models.GroupAssignment
+	ts.db.Where("group_id = ?", group.ID).First(&source)
+	sourcePath := base + "/assignments/" + strconv.FormatUint(uint64(source.ID), 10)
+
+	resp, body = ts.postForm(sourcePath, url.Values{
+		"_csrf":         {ts.csrfToken(sourcePath)},
+		"title":         {"自由研究（レポート）"},
+		"priority":      {"high"},
+		"due_date":      {"2030-08-31T09:00"},
+		"edit_behavior": {models.EditBehaviorThisAndFuture},
+	})
+	if resp.StatusCode != http.StatusFound || !strings.Contains(resp.Header.Get("Location"), "updated=1") {
+		t.Fatalf("update: status %d, location %q\n%s", resp.StatusCode, resp.Header.Get("Location"), body)
+	}
+
+	pages := []struct {
+		name   string
+		client *testServer
+		path   string
+		want   string
+	}{
+		{"教師の一覧", ts, "/groups", "3年4組"},
+		{"クラス", ts, base, group.JoinCode},
+		{"提出状況", ts, sourcePath, "student-pages@example.com"},
+		{"生徒の一覧", student, "/groups", "生徒"},
+		{"生徒の課題一覧", student, "/assignments", "自由研究（レポート）"},
+	}
+	for _, p := range pages {
+		t.Run(p.name, func(t *testing.T) {
+			resp, body := p.client.get(p.path)
+			if resp.StatusCode != http.StatusOK || !strings.Contains(body, p.want) {
+				t.Errorf("%s: status %d, %q not found", p.path, resp.StatusCode, p.want)
+			}
+		})
+	}
+
+	if _, body := student.get(base); strings.Contains(body, group.JoinCode) {
+		t.Error("a student can see the group's management page")
+	}
+
+	var got models.Assignment
+	if err := ts.db.Where("user_id = ? AND group_assignment_id = ?", studentUser.ID, source.ID).First(&got).Error; err != nil || got.Priority != "high" {
+		t.Errorf("student's copy = %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/oidc/oidctest"
+)
+
+// newOIDCTestServer はモック IdP でのシングルサインオンを有効にしたテスト用のサーバーを起動する。
+func newOIDCTestServer(t *testing.T) (*testServer, *oidctest.IdP) {
+	t.Helper()
+	idp, idpServer := oidctest.NewServer("homework-manager")
+	t.Cleanup(idpServer.Close)
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.OIDC = config.OIDCConfig{
+			Enabled:       true,
+			ProviderName:  "Mock IdP",
+			Issuer:        idpServer.URL,
+			ClientID:      "homework-manager",
+			RedirectURL:   "http://homework.test/auth/oidc/callback",
+			Scopes:        []string{"openid", "email", "profile"},
+			AutoProvision: true,
+		}
+	})
+	return ts, idp
+}
+
+// startOIDCLogin は /auth/oidc/login から IdP の認可までを進め、コールバックの URL を返す。
+func (ts *testServer) startOIDCLogin() *url.URL {
+	ts.t.Helper()
+	resp, body := ts.get("/auth/oidc/login")
+	if resp.StatusCode != http.StatusFound {
+		ts.t.Fatalf("/auth/oidc/login: status %d\n%s", resp.StatusCode, body)
+	}
+	req, err := http.NewRequest(http.MethodGet, resp.Header.Get("Location"), nil)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	resp, body = ts.do(ts.client, req)
+	if resp.StatusCode != http.StatusFound {
+		ts.t.Fatalf("authorize: status %d\n%s", resp.StatusCode, body)
+	}
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || callback.Path != "/auth/oidc/callback" {
+		ts.t.Fatalf("authorize redirected to %q", resp.Header.Get("Location"))
+	}
+	return callback
+}
+
+func TestOIDCLogin(t *testing.T) {
+	ts, _ := newOIDCTestServer(t)
+
+	callback := ts.startOIDCLogin()
+	resp, body := ts.get(callback.RequestURI())
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/" {
+		t.Fatalf("callback: status %d, location %q\n%s", resp.StatusCode, resp.Header.Get("Location"), body)
+	}
+
+	var user models.User
+	if err := ts.db.Where("oidc_subject = ?", "mock-user").First(&user).Error; err != nil {
+		t.Fatalf("provisioned user: %v", err)
+	}
+	if user.Email != "mock-user@example.com" {
+		t.Errorf("provisioned email = %q", user.Email)
+	}
+	if resp, _ := ts.get("/assignments"); resp.StatusCode != http.StatusOK {
+		t.Errorf("/assignments after login: status %d", resp.StatusCode)
+	}
+
+	// 使用済みのコールバックは再利用できない
+	other := ts.newSession()
+	if _, body := other.get(callback.RequestURI()); !strings.Contains(body, "シングルサインオンの要求が無効か期限切れです") {
+		t.Errorf("replayed callback in another session was not rejected:\n%s", body)
+	}
+}
+
+func TestOIDCCallbackRejectsStateMismatch(t *testing.T) {
+	ts, _ := newOIDCTestServer(t)
+
+	callback := ts.startOIDCLogin()
+	tampered := callback.Query()
+	tampered.Set("state", "attacker-state")
+	resp, body := ts.get(callback.Path + "?" + tampered.Encode())
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "シングルサインオンの要求が無効か期限切れです") {
+		t.Fatalf("state mismatch: status %d\n%s", resp.StatusCode, body)
+	}
+
+	// 失敗したら state は破棄され、正しい state でもやり直しになる
+	if _, body := ts.get(callback.RequestURI()); !strings.Contains(body, "シングルサインオンの要求が無効か期限切れです") {
+		t.Errorf("state was reused after a mismatch:\n%s", body)
+	}
+
+	var count int64
+	ts.db.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d users were created", count)
+	}
+
+	// IdP がエラーを返した場合
+	if _, body := ts.get("/auth/oidc/callback?error=access_denied&state=x"); !strings.Contains(body, "シングルサインオンがキャンセルされたか、拒否されました") {
+		t.Errorf("error response was not shown:\n%s", body)
+	}
+}
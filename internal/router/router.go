@@ -1,7 +1,9 @@
 package router
 
 import (
+	"fmt"
 	"html/template"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -11,25 +13,31 @@ import (
 
 	"homework-manager/internal/config"
 	"homework-manager/internal/handler"
+	"homework-manager/internal/mail"
 	"homework-manager/internal/middleware"
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
 	"homework-manager/internal/service"
+	"homework-manager/internal/sessionstore"
+	"homework-manager/internal/storage"
 
 	"github.com/dchest/captcha"
 	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func getFuncMap() template.FuncMap {
 	return template.FuncMap{
-		"formatDate": func(t time.Time) string {
-			return t.Format("2006/01/02")
+		// 日時は UTC で保存しているため、表示するユーザーのタイムゾーン（RenderHTML が渡す $.loc）に変換して書式化する
+		"formatDate": func(t time.Time, loc *time.Location) string {
+			return t.In(loc).Format("2006/01/02")
 		},
-		"formatDateTime": func(t time.Time) string {
-			return t.Format("2006/01/02 15:04")
+		"formatDateTime": func(t time.Time, loc *time.Location) string {
+			return t.In(loc).Format("2006/01/02 15:04")
 		},
-		"formatDateInput": func(t time.Time) string {
-			return t.Format("2006-01-02T15:04")
+		"formatDateInput": func(t time.Time, loc *time.Location) string {
+			return t.In(loc).Format("2006-01-02T15:04")
 		},
 		"isOverdue": func(t time.Time, completed bool) bool {
 			return !completed && time.Now().After(t)
@@ -46,9 +54,28 @@ func getFuncMap() template.FuncMap {
 		"multiplyFloat": func(a float64, b float64) float64 {
 			return a * b
 		},
-		"recurringLabel":   service.GetRecurrenceTypeLabel,
-		"endTypeLabel":     service.GetEndTypeLabel,
-		"recurringSummary": service.FormatRecurringSummary,
+		"recurringLabel":        service.GetRecurrenceTypeLabel,
+		"endTypeLabel":          service.GetEndTypeLabel,
+		"recurringSummary":      service.FormatRecurringSummary,
+		"webhookEventLabel":     service.GetWebhookEventLabel,
+		"notificationKindLabel": service.GetNotificationKindLabel,
+		"apiKeyScopeLabel":      service.GetAPIKeyScopeLabel,
+		"loginMethodLabel":      service.GetLoginMethodLabel,
+		"loginReasonLabel":      service.GetLoginReasonLabel,
+		"auditActionLabel":      service.GetAuditActionLabel,
+		"auditTargetTypeLabel":  service.GetAuditTargetTypeLabel,
+		"formatFileSize":        service.FormatFileSize,
+		"priorityLabel":         service.GetPriorityLabel,
+		"reminderOffsetLabel":   service.FormatReminderOffset,
+		"subjectColor": func(colors map[string]string, subject string) string {
+			if color, ok := colors[subject]; ok {
+				return color
+			}
+			return models.DefaultSubjectColor
+		},
+		"joinTags": func(tags []models.Tag) string {
+			return strings.Join(models.TagNames(tags), ", ")
+		},
 		"derefInt": func(i *int) int {
 			if i == nil {
 				return 0
@@ -83,6 +110,9 @@ func loadTemplates() (*template.Template, error) {
 		{"web/templates/assignments/*.html", "assignments/"},
 		{"web/templates/recurring/*.html", "recurring/"},
 		{"web/templates/admin/*.html", "admin/"},
+		{"web/templates/webhooks/*.html", "webhooks/"},
+		{"web/templates/subjects/*.html", "subjects/"},
+		{"web/templates/groups/*.html", "groups/"},
 	}
 
 	for _, dir := range templateDirs {
@@ -146,7 +176,24 @@ func loadTemplates() (*template.Template, error) {
 	return tmpl, nil
 }
 
-func Setup(cfg *config.Config) *gin.Engine {
+// rateLimitPolicy は設定からルートグループのレート制限のポリシーを作る。
+func rateLimitPolicy(name string, policy config.RateLimitPolicyConfig) middleware.RateLimitPolicy {
+	return middleware.RateLimitPolicy{
+		Name:     name,
+		Requests: policy.Requests,
+		Window:   time.Duration(policy.Window) * time.Second,
+		By:       policy.By,
+	}
+}
+
+// Setup はルーティングを組み立てる。リポジトリ・サービスは db を使う。
+// テンプレートや添付ファイルの保存先を用意できない場合は、バックグラウンドの処理を始める前にエラーを返す。
+func Setup(cfg *config.Config, db *gorm.DB) (*gin.Engine, error) {
+	attachmentStorage, err := storage.New(cfg.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("set up attachment storage: %w", err)
+	}
+
 	if !cfg.Debug {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -158,13 +205,19 @@ func Setup(cfg *config.Config) *gin.Engine {
 	}
 	tmpl, err := loadTemplates()
 	if err != nil {
-		panic("Failed to load templates: " + err.Error())
+		return nil, fmt.Errorf("load templates: %w", err)
 	}
 	r.SetHTMLTemplate(tmpl)
 
 	r.Static("/static", "web/static")
 
-	store := cookie.NewStore([]byte(cfg.SessionSecret))
+	var sessionBackend sessionstore.Backend
+	if cfg.SessionStore == "memory" {
+		sessionBackend = sessionstore.NewMemoryBackend()
+	} else {
+		sessionBackend = repository.NewSessionRepository(db)
+	}
+	store := sessionstore.NewStore(sessionBackend, middleware.UserIDKey, []byte(cfg.SessionSecret))
 	store.Options(sessions.Options{
 		Path:     "/",
 		MaxAge:   86400 * 7, // 7 days
@@ -182,47 +235,132 @@ func Setup(cfg *config.Config) *gin.Engine {
 	r.Use(middleware.SecurityHeaders(securityConfig))
 	r.Use(middleware.ForceHTTPS(securityConfig))
 
-	r.Use(middleware.RateLimit(middleware.RateLimitConfig{
-		Enabled:  cfg.RateLimitEnabled,
-		Requests: cfg.RateLimitRequests,
-		Window:   cfg.RateLimitWindow,
-	}))
+	var rateLimitStore middleware.RateLimitStore
+	if cfg.RateLimit.Store == "database" {
+		rateLimitStore = repository.NewRateLimitRepository(db)
+	} else {
+		rateLimitStore = middleware.NewMemoryRateLimitStore()
+	}
+	rateLimiter := middleware.NewRateLimiter(middleware.RateLimitConfig{
+		Enabled:   cfg.RateLimit.Enabled,
+		Algorithm: cfg.RateLimit.Algorithm,
+		Store:     rateLimitStore,
+	})
+	rateLimiter.StartCleanup(time.Minute)
+	webRateLimit := rateLimiter.Limit(rateLimitPolicy("web", cfg.RateLimit.Web))
+	loginRateLimit := rateLimiter.Limit(rateLimitPolicy("login", cfg.RateLimit.Login))
+	apiRateLimit := rateLimiter.Limit(rateLimitPolicy("api", cfg.RateLimit.API))
 
 	csrfMiddleware := middleware.CSRF(middleware.CSRFConfig{
 		Secret: cfg.CSRFSecret,
 	})
 
-	authService := service.NewAuthService()
-	apiKeyService := service.NewAPIKeyService()
-	notificationService := service.NewNotificationService(cfg.Notification.TelegramBotToken)
+	authService := service.NewAuthService(db, sessionBackend)
+	sessionService := service.NewSessionService(sessionBackend)
+	sessionService.StartCleanupScheduler(time.Hour)
+	apiKeyService := service.NewAPIKeyService(db)
+	notificationService := service.NewNotificationService(db, cfg.Notification)
+	mailSender := mail.NewSender(cfg.SMTP)
+	if mailSender.Configured() {
+		notificationService.RegisterNotifier(service.NewEmailNotifier(mailSender))
+	}
+	accountMail := service.NewAccountMailService(db, sessionBackend, cfg, mailSender)
+	if mailSender.Configured() && !accountMail.Enabled() {
+		log.Println("Password reset and email verification are disabled: [server] base_url is not set")
+	}
 
 	notificationService.StartReminderScheduler()
 
-	authHandler := handler.NewAuthHandler(cfg.Captcha)
-	assignmentHandler := handler.NewAssignmentHandler(notificationService)
-	adminHandler := handler.NewAdminHandler()
-	profileHandler := handler.NewProfileHandler(notificationService)
-	apiHandler := handler.NewAPIHandler()
-	apiRecurringHandler := handler.NewAPIRecurringHandler()
+	service.NewWebhookService(db).StartDeliveryWorker(30 * time.Second)
+
+	var telegramBot *service.TelegramBotService
+	if cfg.Notification.TelegramBotToken != "" && cfg.Notification.TelegramBotMode != service.TelegramBotModeOff {
+		telegramBot = service.NewTelegramBotService(db, cfg.Notification, notificationService)
+		if err := telegramBot.Start(); err != nil {
+			log.Printf("Telegram bot could not be started: %v", err)
+			telegramBot = nil
+		}
+	}
+
+	if cfg.Recurring.GenerationEnabled {
+		service.NewRecurringAssignmentService(db).StartGenerationScheduler(time.Duration(cfg.Recurring.GenerationInterval) * time.Minute)
+	}
 
-	r.GET("/captcha/:file", gin.WrapH(captcha.Server(captcha.StdWidth, captcha.StdHeight)))
-	r.GET("/captcha-new", func(c *gin.Context) {
+	loginGuard := service.NewLoginGuardService(db, sessionBackend, cfg.Lockout, cfg.Captcha)
+	loginGuard.StartCleanupScheduler(24 * time.Hour)
+
+	authHandler := handler.NewAuthHandler(db, sessionBackend, cfg.Captcha, cfg.OIDC, cfg.WebAuthn, accountMail, loginGuard)
+	attachmentService := service.NewAttachmentService(db, attachmentStorage, cfg.Storage)
+	attachmentService.StartPurgeScheduler(10 * time.Minute)
+
+	assignmentHandler := handler.NewAssignmentHandler(db, notificationService, attachmentService)
+	adminHandler := handler.NewAdminHandler(db, loginGuard, attachmentService)
+	profileHandler := handler.NewProfileHandler(db, sessionBackend, notificationService, telegramBot, cfg.WebAuthn, accountMail)
+	apiHandler := handler.NewAPIHandler(db, telegramBot)
+	apiRecurringHandler := handler.NewAPIRecurringHandler(db)
+	apiChecklistHandler := handler.NewAPIChecklistHandler(db)
+	apiAttachmentHandler := handler.NewAPIAttachmentHandler(attachmentService)
+	apiSubjectHandler := handler.NewAPISubjectHandler(db)
+	apiTagHandler := handler.NewAPITagHandler(db)
+	apiGroupHandler := handler.NewAPIGroupHandler(db)
+	calendarHandler := handler.NewCalendarHandler(db)
+	webhookHandler := handler.NewWebhookHandler(db)
+	subjectHandler := handler.NewSubjectHandler(db)
+	groupHandler := handler.NewGroupHandler(db)
+	accountHandler := handler.NewAccountHandler(db, accountMail)
+
+	// 画面（web）のルート。API は下の api グループで別のポリシーを使う
+	web := r.Group("/")
+	web.Use(webRateLimit)
+
+	web.GET("/captcha/:file", gin.WrapH(captcha.Server(captcha.StdWidth, captcha.StdHeight)))
+	web.GET("/captcha-new", func(c *gin.Context) {
 		id := captcha.New()
 		c.String(http.StatusOK, id)
 	})
 
-	r.GET("/login/2fa", csrfMiddleware, authHandler.ShowLogin2FA)
-	r.POST("/login/2fa", csrfMiddleware, authHandler.Login2FA)
+	web.GET("/calendar/:file", calendarHandler.Feed)
 
-	guest := r.Group("/")
+	if telegramBot != nil && cfg.Notification.TelegramBotMode == service.TelegramBotModeWebhook {
+		web.POST("/telegram/webhook", handler.NewTelegramHandler(telegramBot).Webhook)
+	}
+
+	web.GET("/login/2fa", csrfMiddleware, authHandler.ShowLogin2FA)
+	web.POST("/login/2fa", loginRateLimit, csrfMiddleware, authHandler.Login2FA)
+	if cfg.WebAuthn.Enabled {
+		web.POST("/login/2fa/webauthn/begin", csrfMiddleware, authHandler.WebAuthn2FABegin)
+		web.POST("/login/2fa/webauthn/finish", loginRateLimit, csrfMiddleware, authHandler.WebAuthn2FAFinish)
+	}
+
+	if accountMail.Enabled() {
+		web.GET("/verify-email", csrfMiddleware, accountHandler.VerifyEmail)
+	}
+
+	guest := web.Group("/")
 	guest.Use(middleware.GuestOnly())
 	guest.Use(csrfMiddleware)
 	{
 		guest.GET("/login", authHandler.ShowLogin)
-		guest.POST("/login", authHandler.Login)
+		guest.POST("/login", loginRateLimit, authHandler.Login)
+		if cfg.WebAuthn.Enabled && cfg.WebAuthn.Passwordless {
+			guest.POST("/login/passkey/begin", authHandler.PasskeyLoginBegin)
+			guest.POST("/login/passkey/finish", loginRateLimit, authHandler.PasskeyLoginFinish)
+		}
+		if accountMail.Enabled() {
+			guest.GET("/password/forgot", accountHandler.ShowForgotPassword)
+			guest.POST("/password/forgot", loginRateLimit, accountHandler.ForgotPassword)
+			guest.GET("/password/reset", accountHandler.ShowResetPassword)
+			guest.POST("/password/reset", loginRateLimit, accountHandler.ResetPassword)
+			guest.GET("/verify-email/resend", accountHandler.ShowResendVerification)
+			guest.POST("/verify-email/resend", loginRateLimit, accountHandler.ResendVerification)
+		}
+		if cfg.OIDC.Enabled {
+			guest.GET("/auth/oidc/login", authHandler.OIDCLogin)
+			guest.GET("/auth/oidc/callback", authHandler.OIDCCallback)
+		}
 		if cfg.AllowRegistration {
 			guest.GET("/register", authHandler.ShowRegister)
-			guest.POST("/register", authHandler.Register)
+			guest.POST("/register", loginRateLimit, authHandler.Register)
 		} else {
 			guest.GET("/register", func(c *gin.Context) {
 				c.HTML(http.StatusForbidden, "error.html", gin.H{
@@ -233,8 +371,9 @@ func Setup(cfg *config.Config) *gin.Engine {
 		}
 	}
 
-	auth := r.Group("/")
+	auth := web.Group("/")
 	auth.Use(middleware.AuthRequired(authService))
+	auth.Use(middleware.TrackSession(sessionService))
 	auth.Use(csrfMiddleware)
 	{
 		auth.GET("/", assignmentHandler.Dashboard)
@@ -243,15 +382,49 @@ func Setup(cfg *config.Config) *gin.Engine {
 		auth.GET("/assignments", assignmentHandler.Index)
 		auth.GET("/assignments/new", assignmentHandler.New)
 		auth.POST("/assignments", assignmentHandler.Create)
+		auth.GET("/assignments/import", assignmentHandler.ShowImport)
+		auth.POST("/assignments/import/preview", assignmentHandler.PreviewImport)
+		auth.POST("/assignments/import", assignmentHandler.Import)
 		auth.GET("/assignments/:id/edit", assignmentHandler.Edit)
 		auth.POST("/assignments/:id", assignmentHandler.Update)
 		auth.POST("/assignments/:id/toggle", assignmentHandler.Toggle)
 		auth.POST("/assignments/:id/delete", assignmentHandler.Delete)
+		auth.POST("/assignments/:id/items", assignmentHandler.CreateItem)
+		auth.POST("/assignments/:id/items/:itemId", assignmentHandler.UpdateItem)
+		auth.POST("/assignments/:id/items/:itemId/toggle", assignmentHandler.ToggleItem)
+		auth.POST("/assignments/:id/items/:itemId/move", assignmentHandler.MoveItem)
+		auth.POST("/assignments/:id/items/:itemId/delete", assignmentHandler.DeleteItem)
+		auth.POST("/assignments/:id/attachments", assignmentHandler.UploadAttachment)
+		auth.GET("/assignments/:id/attachments/:attachmentId", assignmentHandler.DownloadAttachment)
+		auth.POST("/assignments/:id/attachments/:attachmentId/delete", assignmentHandler.DeleteAttachment)
 
 		auth.GET("/statistics", assignmentHandler.Statistics)
 		auth.POST("/statistics/archive-subject", assignmentHandler.ArchiveSubject)
 		auth.POST("/statistics/unarchive-subject", assignmentHandler.UnarchiveSubject)
 
+		auth.GET("/subjects", subjectHandler.Index)
+		auth.POST("/subjects", subjectHandler.Create)
+		auth.GET("/subjects/:id/edit", subjectHandler.Edit)
+		auth.POST("/subjects/:id", subjectHandler.Update)
+		auth.POST("/subjects/:id/archive", subjectHandler.Archive)
+		auth.POST("/subjects/:id/unarchive", subjectHandler.Unarchive)
+		auth.POST("/subjects/:id/merge", subjectHandler.Merge)
+		auth.POST("/subjects/:id/delete", subjectHandler.Delete)
+
+		auth.GET("/groups", groupHandler.Index)
+		auth.POST("/groups", groupHandler.Create)
+		auth.POST("/groups/join", groupHandler.Join)
+		auth.GET("/groups/:id", groupHandler.Show)
+		auth.POST("/groups/:id/leave", groupHandler.Leave)
+		auth.POST("/groups/:id/code", groupHandler.RegenerateCode)
+		auth.POST("/groups/:id/delete", groupHandler.Delete)
+		auth.POST("/groups/:id/members/:userId/remove", groupHandler.RemoveMember)
+		auth.POST("/groups/:id/members/:userId/promote", groupHandler.PromoteMember)
+		auth.POST("/groups/:id/assignments", groupHandler.CreateAssignment)
+		auth.GET("/groups/:id/assignments/:assignmentId", groupHandler.ShowAssignment)
+		auth.POST("/groups/:id/assignments/:assignmentId", groupHandler.UpdateAssignment)
+		auth.POST("/groups/:id/assignments/:assignmentId/delete", groupHandler.DeleteAssignment)
+
 		auth.POST("/recurring/:id/stop", assignmentHandler.StopRecurring)
 		auth.POST("/recurring/:id/resume", assignmentHandler.ResumeRecurring)
 		auth.POST("/recurring/:id/delete", assignmentHandler.DeleteRecurring)
@@ -259,13 +432,41 @@ func Setup(cfg *config.Config) *gin.Engine {
 		auth.GET("/recurring/:id/edit", assignmentHandler.EditRecurring)
 		auth.POST("/recurring/:id", assignmentHandler.UpdateRecurring)
 
+		auth.GET("/webhooks", webhookHandler.Index)
+		auth.POST("/webhooks", webhookHandler.Create)
+		auth.GET("/webhooks/:id", webhookHandler.Show)
+		auth.POST("/webhooks/:id", webhookHandler.Update)
+		auth.POST("/webhooks/:id/rotate", webhookHandler.RotateSecret)
+		auth.POST("/webhooks/:id/ping", webhookHandler.Ping)
+		auth.POST("/webhooks/:id/delete", webhookHandler.Delete)
+		auth.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+
 		auth.GET("/profile", profileHandler.Show)
 		auth.POST("/profile", profileHandler.Update)
 		auth.POST("/profile/password", profileHandler.ChangePassword)
+		auth.POST("/profile/sessions/revoke-others", profileHandler.RevokeOtherSessions)
+		auth.POST("/profile/sessions/:id/revoke", profileHandler.RevokeSession)
+		if accountMail.Enabled() {
+			auth.POST("/profile/email/verify", profileHandler.SendEmailVerification)
+		}
 		auth.POST("/profile/notifications", profileHandler.UpdateNotificationSettings)
+		auth.POST("/profile/telegram/link", profileHandler.CreateTelegramLinkCode)
+		auth.POST("/profile/telegram/unlink", profileHandler.UnlinkTelegram)
+		auth.POST("/profile/calendar/rotate", profileHandler.RotateCalendarToken)
+		auth.POST("/profile/api-keys", profileHandler.CreateAPIKey)
+		auth.POST("/profile/api-keys/:id/delete", profileHandler.DeleteAPIKey)
+		auth.GET("/profile/export", profileHandler.ExportData)
+		auth.POST("/profile/import", profileHandler.ImportData)
 		auth.GET("/profile/totp/setup", profileHandler.ShowTOTPSetup)
 		auth.POST("/profile/totp/setup", profileHandler.EnableTOTP)
 		auth.POST("/profile/totp/disable", profileHandler.DisableTOTP)
+		auth.POST("/profile/totp/recovery-codes", profileHandler.RegenerateRecoveryCodes)
+		if cfg.WebAuthn.Enabled {
+			auth.POST("/profile/webauthn/register/begin", profileHandler.BeginWebAuthnRegistration)
+			auth.POST("/profile/webauthn/register/finish", profileHandler.FinishWebAuthnRegistration)
+			auth.POST("/profile/webauthn/:id", profileHandler.RenameWebAuthnCredential)
+			auth.POST("/profile/webauthn/:id/delete", profileHandler.DeleteWebAuthnCredential)
+		}
 
 		admin := auth.Group("/admin")
 		admin.Use(middleware.AdminRequired())
@@ -273,35 +474,87 @@ func Setup(cfg *config.Config) *gin.Engine {
 			admin.GET("/users", adminHandler.Index)
 			admin.POST("/users/:id/delete", adminHandler.DeleteUser)
 			admin.POST("/users/:id/role", adminHandler.ChangeRole)
+			admin.POST("/users/:id/unlock", adminHandler.UnlockUser)
+			admin.GET("/login-attempts", adminHandler.LoginAttempts)
 
 			admin.GET("/api-keys", adminHandler.APIKeys)
 			admin.POST("/api-keys", adminHandler.CreateAPIKey)
 			admin.POST("/api-keys/:id/delete", adminHandler.DeleteAPIKey)
+
+			admin.GET("/notifications", adminHandler.Notifications)
+			admin.POST("/notifications/:id/requeue", adminHandler.RequeueNotification)
+
+			admin.GET("/audit", adminHandler.Audit)
+			admin.GET("/audit/export", adminHandler.ExportAudit)
 		}
 	}
 
 	api := r.Group("/api/v1")
-	api.Use(middleware.APIKeyAuth(apiKeyService))
+	api.Use(middleware.IdentifyAPIKey(apiKeyService), apiRateLimit, middleware.APIKeyAuth(), middleware.InjectUserLocation(authService))
 	{
-		api.GET("/assignments", apiHandler.ListAssignments)
-		api.GET("/assignments/pending", apiHandler.ListPendingAssignments)
-		api.GET("/assignments/completed", apiHandler.ListCompletedAssignments)
-		api.GET("/assignments/overdue", apiHandler.ListOverdueAssignments)
-		api.GET("/assignments/due-today", apiHandler.ListDueTodayAssignments)
-		api.GET("/assignments/due-this-week", apiHandler.ListDueThisWeekAssignments)
-		api.GET("/assignments/:id", apiHandler.GetAssignment)
-		api.POST("/assignments", apiHandler.CreateAssignment)
-		api.PUT("/assignments/:id", apiHandler.UpdateAssignment)
-		api.DELETE("/assignments/:id", apiHandler.DeleteAssignment)
-		api.PATCH("/assignments/:id/toggle", apiHandler.ToggleAssignment)
-
-		api.GET("/statistics", apiHandler.GetStatistics)
-
-		api.GET("/recurring", apiRecurringHandler.ListRecurring)
-		api.GET("/recurring/:id", apiRecurringHandler.GetRecurring)
-		api.PUT("/recurring/:id", apiRecurringHandler.UpdateRecurring)
-		api.DELETE("/recurring/:id", apiRecurringHandler.DeleteRecurring)
+		assignmentsRead := middleware.RequireScope(models.ScopeAssignmentsRead)
+		assignmentsWrite := middleware.RequireScope(models.ScopeAssignmentsWrite)
+		recurringRead := middleware.RequireScope(models.ScopeRecurringRead)
+		recurringWrite := middleware.RequireScope(models.ScopeRecurringWrite)
+		statisticsRead := middleware.RequireScope(models.ScopeStatisticsRead)
+
+		api.GET("/assignments", assignmentsRead, apiHandler.ListAssignments)
+		api.GET("/assignments/pending", assignmentsRead, apiHandler.ListPendingAssignments)
+		api.GET("/assignments/completed", assignmentsRead, apiHandler.ListCompletedAssignments)
+		api.GET("/assignments/overdue", assignmentsRead, apiHandler.ListOverdueAssignments)
+		api.GET("/assignments/due-today", assignmentsRead, apiHandler.ListDueTodayAssignments)
+		api.GET("/assignments/due-this-week", assignmentsRead, apiHandler.ListDueThisWeekAssignments)
+		api.GET("/assignments/:id", assignmentsRead, apiHandler.GetAssignment)
+		api.POST("/assignments", assignmentsWrite, apiHandler.CreateAssignment)
+		api.POST("/assignments/import", assignmentsWrite, apiHandler.ImportAssignments)
+		api.PUT("/assignments/:id", assignmentsWrite, apiHandler.UpdateAssignment)
+		api.DELETE("/assignments/:id", assignmentsWrite, apiHandler.DeleteAssignment)
+		api.PATCH("/assignments/:id/toggle", assignmentsWrite, apiHandler.ToggleAssignment)
+
+		api.GET("/assignments/:id/items", assignmentsRead, apiChecklistHandler.ListItems)
+		api.POST("/assignments/:id/items", assignmentsWrite, apiChecklistHandler.CreateItem)
+		api.PUT("/assignments/:id/items/:itemId", assignmentsWrite, apiChecklistHandler.UpdateItem)
+		api.PATCH("/assignments/:id/items/:itemId/toggle", assignmentsWrite, apiChecklistHandler.ToggleItem)
+		api.DELETE("/assignments/:id/items/:itemId", assignmentsWrite, apiChecklistHandler.DeleteItem)
+
+		api.GET("/assignments/:id/attachments", assignmentsRead, apiAttachmentHandler.ListAttachments)
+		api.POST("/assignments/:id/attachments", assignmentsWrite, apiAttachmentHandler.UploadAttachment)
+		api.GET("/assignments/:id/attachments/:attachmentId", assignmentsRead, apiAttachmentHandler.DownloadAttachment)
+		api.DELETE("/assignments/:id/attachments/:attachmentId", assignmentsWrite, apiAttachmentHandler.DeleteAttachment)
+
+		api.GET("/subjects", assignmentsRead, apiSubjectHandler.ListSubjects)
+		api.GET("/subjects/:id", assignmentsRead, apiSubjectHandler.GetSubject)
+		api.POST("/subjects", assignmentsWrite, apiSubjectHandler.CreateSubject)
+		api.PUT("/subjects/:id", assignmentsWrite, apiSubjectHandler.UpdateSubject)
+		api.DELETE("/subjects/:id", assignmentsWrite, apiSubjectHandler.DeleteSubject)
+		api.POST("/subjects/:id/merge", assignmentsWrite, apiSubjectHandler.MergeSubject)
+
+		api.GET("/tags", assignmentsRead, apiTagHandler.ListTags)
+		api.POST("/tags", assignmentsWrite, apiTagHandler.CreateTag)
+		api.PUT("/tags/:id", assignmentsWrite, apiTagHandler.UpdateTag)
+		api.DELETE("/tags/:id", assignmentsWrite, apiTagHandler.DeleteTag)
+
+		api.GET("/groups", assignmentsRead, apiGroupHandler.ListGroups)
+		api.POST("/groups", assignmentsWrite, apiGroupHandler.CreateGroup)
+		api.POST("/groups/join", assignmentsWrite, apiGroupHandler.JoinGroup)
+		api.GET("/groups/:id", assignmentsRead, apiGroupHandler.GetGroup)
+		api.POST("/groups/:id/leave", assignmentsWrite, apiGroupHandler.LeaveGroup)
+		api.GET("/groups/:id/assignments", assignmentsRead, apiGroupHandler.ListAssignments)
+		api.POST("/groups/:id/assignments", assignmentsWrite, apiGroupHandler.CreateAssignment)
+		api.GET("/groups/:id/assignments/:assignmentId", assignmentsRead, apiGroupHandler.GetAssignment)
+		api.PUT("/groups/:id/assignments/:assignmentId", assignmentsWrite, apiGroupHandler.UpdateAssignment)
+		api.DELETE("/groups/:id/assignments/:assignmentId", assignmentsWrite, apiGroupHandler.DeleteAssignment)
+
+		api.GET("/statistics", statisticsRead, apiHandler.GetStatistics)
+
+		api.GET("/export", assignmentsRead, recurringRead, apiHandler.ExportData)
+		api.POST("/import", assignmentsWrite, recurringWrite, apiHandler.ImportData)
+
+		api.GET("/recurring", recurringRead, apiRecurringHandler.ListRecurring)
+		api.GET("/recurring/:id", recurringRead, apiRecurringHandler.GetRecurring)
+		api.PUT("/recurring/:id", recurringWrite, apiRecurringHandler.UpdateRecurring)
+		api.DELETE("/recurring/:id", recurringWrite, apiRecurringHandler.DeleteRecurring)
 	}
 
-	return r
+	return r, nil
 }
@@ -0,0 +1,220 @@
+package router
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// テンプレートと静的ファイルはリポジトリのルートからの相対パスで読み込むため、ルートに移動してから実行する
+func TestMain(m *testing.M) {
+	dir, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			panic("go.mod not found")
+		}
+		dir = parent
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+var csrfFieldPattern = regexp.MustCompile(`name="_csrf" value="([^"]+)"`)
+
+// testServer は router.Setup をインメモリの SQLite に対して起動したテスト用のサーバー。
+// client は Cookie を保持し、リダイレクトは追わない。
+type testServer struct {
+	t      *testing.T
+	db     *gorm.DB
+	server *httptest.Server
+	client *http.Client
+}
+
+// newTestServer はテスト用のサーバーを起動する。configure で testutil.Config の設定を変更できる。
+func newTestServer(t *testing.T, configure ...func(*config.Config)) *testServer {
+	t.Helper()
+
+	db := testutil.OpenDB(t)
+	cfg := testutil.Config()
+	cfg.Storage.LocalPath = t.TempDir()
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	engine, err := Setup(cfg, db)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	return &testServer{t: t, db: db, server: server, client: newTestClient(t)}
+}
+
+// newSession は同じサーバーに別のブラウザー（Cookie）で接続する testServer を返す。
+func (ts *testServer) newSession() *testServer {
+	return &testServer{t: ts.t, db: ts.db, server: ts.server, client: newTestClient(ts.t)}
+}
+
+func newTestClient(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("cookie jar: %v", err)
+	}
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (ts *testServer) do(client *http.Client, req *http.Request) (*http.Response, string) {
+	ts.t.Helper()
+	resp, err := client.Do(req)
+	if err != nil {
+		ts.t.Fatalf("%s %s: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		ts.t.Fatalf("read body: %v", err)
+	}
+	return resp, string(body)
+}
+
+func (ts *testServer) get(path string) (*http.Response, string) {
+	ts.t.Helper()
+	req, err := http.NewRequest(http.MethodGet, ts.server.URL+path, nil)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	return ts.do(ts.client, req)
+}
+
+func (ts *testServer) postForm(path string, form url.Values) (*http.Response, string) {
+	ts.t.Helper()
+	req, err := http.NewRequest(http.MethodPost, ts.server.URL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return ts.do(ts.client, req)
+}
+
+// upload は fields と fileName のファイルを multipart/form-data で送る。authorization が空の場合はこのセッションの Cookie で送る。
+func (ts *testServer) upload(path, authorization string, fields url.Values, fileName string, content []byte) (*http.Response, string) {
+	ts.t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, values := range fields {
+		for _, value := range values {
+			w.WriteField(name, value)
+		}
+	}
+	part, err := w.CreateFormFile("file", fileName)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	part.Write(content)
+	w.Close()
+
+	req, err := http.NewRequest(http.MethodPost, ts.server.URL+path, &body)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if authorization == "" {
+		return ts.do(ts.client, req)
+	}
+	req.Header.Set("Authorization", authorization)
+	return ts.do(http.DefaultClient, req)
+}
+
+// csrfToken は path の画面に埋め込まれた CSRF トークンを返す。
+func (ts *testServer) csrfToken(path string) string {
+	ts.t.Helper()
+	_, body := ts.get(path)
+	match := csrfFieldPattern.FindStringSubmatch(body)
+	if match == nil {
+		ts.t.Fatalf("CSRF token not found in %s", path)
+	}
+	return match[1]
+}
+
+// register はユーザーを登録してログインした状態にする。最初に登録したユーザーは管理者になる。
+func (ts *testServer) register(email, password string) *models.User {
+	ts.t.Helper()
+	resp, body := ts.postForm("/register", url.Values{
+		"_csrf":            {ts.csrfToken("/register")},
+		"email":            {email},
+		"password":         {password},
+		"password_confirm": {password},
+		"name":             {"テストユーザー"},
+	})
+	if resp.StatusCode != http.StatusFound {
+		ts.t.Fatalf("register %s: status %d\n%s", email, resp.StatusCode, body)
+	}
+
+	var user models.User
+	if err := ts.db.Where("email = ?", email).First(&user).Error; err != nil {
+		ts.t.Fatalf("find registered user: %v", err)
+	}
+	return &user
+}
+
+// login はメールアドレスとパスワードでログインフォームを送信する。
+func (ts *testServer) login(email, password string) *http.Response {
+	ts.t.Helper()
+	resp, _ := ts.postForm("/login", url.Values{
+		"_csrf":    {ts.csrfToken("/login")},
+		"email":    {email},
+		"password": {password},
+	})
+	return resp
+}
+
+// api は Authorization ヘッダーを付けて API を呼び出す。authorization が空の場合はヘッダーを付けず、body が空の場合は本文を送らない。
+func (ts *testServer) api(method, path, authorization, body string) (*http.Response, string) {
+	ts.t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, ts.server.URL+path, reader)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return ts.do(http.DefaultClient, req)
+}
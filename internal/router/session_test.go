@@ -0,0 +1,73 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// loggedIn はプロフィール画面を表示できる（ログイン中の）場合に true を返す。
+func (ts *testServer) loggedIn() bool {
+	ts.t.Helper()
+	resp, _ := ts.get("/profile")
+	return resp.StatusCode == http.StatusOK
+}
+
+func TestSessionRevocationFromProfile(t *testing.T) {
+	const email, password = "sessions@example.com", "password123"
+
+	tests := []struct {
+		name     string
+		path     string
+		form     url.Values
+		wantBody string
+	}{
+		{
+			name:     "他のセッションをすべてログアウト",
+			path:     "/profile/sessions/revoke-others",
+			wantBody: "他の端末のセッション 2 件をログアウトさせました",
+		},
+		{
+			name: "パスワードの変更",
+			path: "/profile/password",
+			form: url.Values{
+				"old_password":     {password},
+				"new_password":     {"new-password"},
+				"confirm_password": {"new-password"},
+			},
+			wantBody: "パスワードを変更しました",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			ts.register(email, password)
+			laptop, phone := ts.newSession(), ts.newSession()
+			for _, other := range []*testServer{laptop, phone} {
+				if resp := other.login(email, password); resp.StatusCode != http.StatusFound {
+					t.Fatalf("login: status %d", resp.StatusCode)
+				}
+			}
+
+			form := url.Values{"_csrf": {ts.csrfToken("/profile")}}
+			for key, values := range tt.form {
+				form[key] = values
+			}
+			resp, body := ts.postForm(tt.path, form)
+			if resp.StatusCode != http.StatusOK || !strings.Contains(body, tt.wantBody) {
+				t.Fatalf("%s: status %d, body does not contain %q", tt.path, resp.StatusCode, tt.wantBody)
+			}
+
+			if !ts.loggedIn() {
+				t.Error("the current session was logged out")
+			}
+			for _, other := range []*testServer{laptop, phone} {
+				if other.loggedIn() {
+					t.Error("another session is still logged in")
+				}
+			}
+		})
+	}
+}
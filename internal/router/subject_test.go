@@ -0,0 +1,179 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+)
+
+func TestSubjectAPI(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.register("subjects@example.com", "password123")
+	other := ts.newSession().register("other@example.com", "password123")
+
+	keys := service.NewAPIKeyService(ts.db)
+	ownerKey, _, err := keys.CreateAPIKey(owner.ID, "owner", models.APIKeyScopes, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	otherKey, _, err := keys.CreateAPIKey(other.ID, "other", models.APIKeyScopes, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	readKey, _, err := keys.CreateAPIKey(owner.ID, "read", []string{models.ScopeAssignmentsRead}, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	auth := "Bearer " + ownerKey
+
+	resp, body := ts.api("POST", "/api/v1/subjects", auth, `{"name":"英語","color":"#0D6EFD","default_priority":"high","default_reminder_offset":60}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create subject: status %d\n%s", resp.StatusCode, body)
+	}
+	var english models.Subject
+	json.Unmarshal([]byte(body), &english)
+	subjectPath := "/api/v1/subjects/" + strconv.FormatUint(uint64(english.ID), 10)
+
+	// 科目の初期値が課題に入る
+	resp, body = ts.api("POST", "/api/v1/assignments", auth, `{"title":"単語テスト","subject":"英語","due_date":"2030-01-10T09:00"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create assignment: status %d\n%s", resp.StatusCode, body)
+	}
+	var assignment models.Assignment
+	json.Unmarshal([]byte(body), &assignment)
+	if assignment.SubjectID == nil || *assignment.SubjectID != english.ID || assignment.Priority != "high" ||
+		!assignment.ReminderEnabled || assignment.ReminderAt == nil || !assignment.ReminderAt.Equal(assignment.DueDate.Add(-time.Hour)) {
+		t.Errorf("assignment = %+v, want the subject's defaults", assignment)
+	}
+	ts.api("POST", "/api/v1/assignments", auth, `{"title":"長文読解","subject":"リーディング","due_date":"2030-01-11"}`)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		body       string
+		wantStatus int
+	}{
+		{"一覧", "GET", "/api/v1/subjects", ownerKey, "", http.StatusOK},
+		{"取得", "GET", subjectPath, ownerKey, "", http.StatusOK},
+		{"他のユーザーの科目", "GET", subjectPath, otherKey, "", http.StatusNotFound},
+		{"他のユーザーは変更できない", "PUT", subjectPath, otherKey, `{"name":"x"}`, http.StatusNotFound},
+		{"他のユーザーは削除できない", "DELETE", subjectPath, otherKey, "", http.StatusNotFound},
+		{"同じ名前", "POST", "/api/v1/subjects", ownerKey, `{"name":"英語"}`, http.StatusConflict},
+		{"色の形式", "POST", "/api/v1/subjects", ownerKey, `{"name":"数学","color":"red"}`, http.StatusBadRequest},
+		{"自分自身に統合", "POST", subjectPath + "/merge", ownerKey, `{"target_id":` + strconv.FormatUint(uint64(english.ID), 10) + `}`, http.StatusBadRequest},
+		{"読み取りのスコープでは作成できない", "POST", "/api/v1/subjects", readKey, `{"name":"国語"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := ts.api(tt.method, tt.path, "Bearer "+tt.key, tt.body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d\n%s", resp.StatusCode, tt.wantStatus, body)
+			}
+		})
+	}
+
+	// 名前の変更は課題の科目名も変える
+	resp, body = ts.api("PUT", subjectPath, auth, `{"name":"英語表現","is_archived":true}`)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"is_archived":true`) {
+		t.Fatalf("rename: status %d\n%s", resp.StatusCode, body)
+	}
+	resp, body = ts.api("GET", "/api/v1/assignments/"+strconv.FormatUint(uint64(assignment.ID), 10), auth, "")
+	if !strings.Contains(body, `"subject":"英語表現"`) {
+		t.Errorf("assignment after rename: %s", body)
+	}
+	resp, body = ts.api("GET", "/api/v1/subjects", auth, "")
+	if strings.Contains(body, "英語表現") {
+		t.Errorf("archived subject listed without include_archived: %s", body)
+	}
+
+	// 統合すると統合元の科目はなくなる
+	var reading models.Subject
+	ts.db.Where("user_id = ? AND name = ?", owner.ID, "リーディング").First(&reading)
+	resp, body = ts.api("POST", "/api/v1/subjects/"+strconv.FormatUint(uint64(reading.ID), 10)+"/merge", auth,
+		`{"target_id":`+strconv.FormatUint(uint64(english.ID), 10)+`}`)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"assignment_count":2`) {
+		t.Fatalf("merge: status %d\n%s", resp.StatusCode, body)
+	}
+	if resp, _ := ts.api("GET", "/api/v1/subjects/"+strconv.FormatUint(uint64(reading.ID), 10), auth, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("merged subject: status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	if resp, body := ts.api("DELETE", subjectPath, auth, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete: status %d\n%s", resp.StatusCode, body)
+	}
+}
+
+func TestSubjectPages(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.register("subject-pages@example.com", "password123")
+	stranger := ts.newSession()
+	stranger.register("stranger@example.com", "password123")
+
+	token := ts.csrfToken("/subjects")
+	resp, body := ts.postForm("/subjects", url.Values{
+		"_csrf":                   {token},
+		"name":                    {"数学"},
+		"color":                   {"#198754"},
+		"teacher":                 {"山田先生"},
+		"default_reminder_offset": {"1440"},
+	})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("create: status %d\n%s", resp.StatusCode, body)
+	}
+	var subject models.Subject
+	if err := ts.db.Where("user_id = ? AND name = ?", user.ID, "数学").First(&subject).Error; err != nil {
+		t.Fatalf("find subject: %v", err)
+	}
+	base := "/subjects/" + strconv.FormatUint(uint64(subject.ID), 10)
+
+	pages := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"一覧", "/subjects", "山田先生"},
+		{"編集", base + "/edit", "期限の1日前"},
+		{"課題登録", "/assignments/new", `data-reminder-offset="1440"`},
+		{"統計", "/statistics", `<option value="数学"`},
+	}
+	for _, p := range pages {
+		t.Run(p.name, func(t *testing.T) {
+			resp, body := ts.get(p.path)
+			if resp.StatusCode != http.StatusOK || !strings.Contains(body, p.want) {
+				t.Errorf("%s: status %d, %q not found", p.path, resp.StatusCode, p.want)
+			}
+		})
+	}
+
+	resp, body = ts.postForm(base, url.Values{"_csrf": {token}, "name": {"数学A"}, "color": {"#198754"}})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("update: status %d\n%s", resp.StatusCode, body)
+	}
+	if resp, body := stranger.get(base + "/edit"); resp.StatusCode != http.StatusFound || strings.Contains(body, "数学A") {
+		t.Errorf("edit page of another user's subject: status %d", resp.StatusCode)
+	}
+
+	resp, _ = ts.postForm(base+"/archive", url.Values{"_csrf": {token}})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("archive: status %d", resp.StatusCode)
+	}
+	ts.db.First(&subject, subject.ID)
+	if subject.Name != "数学A" || !subject.IsArchived {
+		t.Errorf("subject = %+v, want renamed and archived", subject)
+	}
+	var events int64
+	ts.db.Model(&models.AuditEvent{}).Where("target_type = ? AND action IN ?", models.AuditTargetSubject,
+		[]string{models.AuditSubjectRename, models.AuditSubjectArchive}).Count(&events)
+	if events != 2 {
+		t.Errorf("%d audit event(s) for the subject, want 2", events)
+	}
+}
@@ -0,0 +1,184 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/service"
+)
+
+func TestTagAPI(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.register("tags@example.com", "password123")
+	other := ts.newSession().register("other@example.com", "password123")
+
+	keys := service.NewAPIKeyService(ts.db)
+	ownerKey, _, err := keys.CreateAPIKey(owner.ID, "owner", models.APIKeyScopes, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	otherKey, _, err := keys.CreateAPIKey(other.ID, "other", models.APIKeyScopes, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	auth := "Bearer " + ownerKey
+
+	resp, body := ts.api("POST", "/api/v1/assignments", auth, `{"title":"単語テスト","due_date":"2030-01-10T09:00","tags":["テスト","英語"]}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create assignment: status %d\n%s", resp.StatusCode, body)
+	}
+	var tagged models.Assignment
+	json.Unmarshal([]byte(body), &tagged)
+	if names := models.TagNames(tagged.Tags); strings.Join(names, ",") != "テスト,英語" {
+		t.Errorf("tags = %v, want [テスト 英語]", names)
+	}
+	ts.api("POST", "/api/v1/assignments", auth, `{"title":"計算ドリル","due_date":"2030-01-11T09:00","tags":["テスト"]}`)
+	ts.api("POST", "/api/v1/assignments", auth, `{"title":"日記","due_date":"2030-01-12T09:00"}`)
+	assignmentPath := "/api/v1/assignments/" + strconv.FormatUint(uint64(tagged.ID), 10)
+
+	lists := []struct {
+		name  string
+		path  string
+		count int
+	}{
+		{"いずれか", "/api/v1/assignments?tags=テスト,英語", 2},
+		{"すべて", "/api/v1/assignments?tags=テスト,英語&tag_mode=all", 1},
+		{"除く", "/api/v1/assignments?tags=英語&tag_mode=none", 2},
+		{"未完了", "/api/v1/assignments/pending?tags=テスト", 2},
+		{"完了", "/api/v1/assignments/completed?tags=テスト", 0},
+		{"今週", "/api/v1/assignments/due-this-week?tags=テスト", 0},
+		{"絞り込みなし", "/api/v1/assignments", 3},
+	}
+	for _, l := range lists {
+		t.Run(l.name, func(t *testing.T) {
+			resp, body := ts.api("GET", l.path, auth, "")
+			var result struct {
+				Count int `json:"count"`
+			}
+			json.Unmarshal([]byte(body), &result)
+			if resp.StatusCode != http.StatusOK || result.Count != l.count {
+				t.Errorf("GET %s: status %d, count %d, want %d\n%s", l.path, resp.StatusCode, result.Count, l.count, body)
+			}
+		})
+	}
+
+	// tags を省略した更新ではタグは変わらず、指定すると置き換わる
+	resp, body = ts.api("PUT", assignmentPath, auth, `{"title":"単語テスト（改）"}`)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"name":"英語"`) {
+		t.Fatalf("update without tags: status %d\n%s", resp.StatusCode, body)
+	}
+	resp, body = ts.api("PUT", assignmentPath, auth, `{"title":"単語テスト（改）","tags":["英語","暗記"]}`)
+	if resp.StatusCode != http.StatusOK || strings.Contains(body, `"name":"テスト"`) || !strings.Contains(body, `"name":"暗記"`) {
+		t.Fatalf("update tags: status %d\n%s", resp.StatusCode, body)
+	}
+
+	resp, body = ts.api("GET", "/api/v1/statistics?tag=テスト", auth, "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"total_assignments":1`) || !strings.Contains(body, `"tag":"テスト"`) {
+		t.Errorf("statistics by tag: status %d\n%s", resp.StatusCode, body)
+	}
+	resp, body = ts.api("GET", "/api/v1/statistics", auth, "")
+	if !strings.Contains(body, `"tags":[`) {
+		t.Errorf("statistics without a tag filter has no breakdown: %s", body)
+	}
+
+	resp, body = ts.api("GET", "/api/v1/tags", auth, "")
+	var list struct {
+		Tags []struct {
+			ID              uint   `json:"id"`
+			Name            string `json:"name"`
+			AssignmentCount int64  `json:"assignment_count"`
+		} `json:"tags"`
+	}
+	json.Unmarshal([]byte(body), &list)
+	if resp.StatusCode != http.StatusOK || len(list.Tags) != 3 {
+		t.Fatalf("list tags: status %d\n%s", resp.StatusCode, body)
+	}
+	tagPath := "/api/v1/tags/" + strconv.FormatUint(uint64(list.Tags[0].ID), 10)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		body       string
+		wantStatus int
+	}{
+		{"作成", "POST", "/api/v1/tags", ownerKey, `{"name":"部活"}`, http.StatusCreated},
+		{"同じ名前", "POST", "/api/v1/tags", ownerKey, `{"name":"部活"}`, http.StatusConflict},
+		{"カンマを含む名前", "POST", "/api/v1/tags", ownerKey, `{"name":"a,b"}`, http.StatusBadRequest},
+		{"タグが多すぎる", "PUT", assignmentPath, ownerKey, `{"title":"単語テスト","tags":["1","2","3","4","5","6","7","8","9","10","11"]}`, http.StatusBadRequest},
+		{"他のユーザーは変更できない", "PUT", tagPath, otherKey, `{"name":"x"}`, http.StatusNotFound},
+		{"他のユーザーは削除できない", "DELETE", tagPath, otherKey, "", http.StatusNotFound},
+		{"名前の変更", "PUT", tagPath, ownerKey, `{"name":"小テスト"}`, http.StatusOK},
+		{"削除", "DELETE", tagPath, ownerKey, "", http.StatusOK},
+		{"削除したタグ", "DELETE", tagPath, ownerKey, "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := ts.api(tt.method, tt.path, "Bearer "+tt.key, tt.body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d\n%s", resp.StatusCode, tt.wantStatus, body)
+			}
+		})
+	}
+}
+
+func TestTagPages(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.register("tag-pages@example.com", "password123")
+
+	resp, body := ts.postForm("/assignments", url.Values{
+		"_csrf":    {ts.csrfToken("/assignments/new")},
+		"title":    {"実験レポート"},
+		"priority": {"medium"},
+		"due_date": {"2030-01-01T09:00"},
+		"tags":     {"レポート、理科"},
+	})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("create: status %d\n%s", resp.StatusCode, body)
+	}
+	var assignment models.Assignment
+	if err := ts.db.Preload("Tags").Where("user_id = ? AND title = ?", user.ID, "実験レポート").First(&assignment).Error; err != nil {
+		t.Fatalf("find assignment: %v", err)
+	}
+	if len(assignment.Tags) != 2 {
+		t.Fatalf("created with %d tag(s), want 2", len(assignment.Tags))
+	}
+	base := "/assignments/" + strconv.FormatUint(uint64(assignment.ID), 10)
+
+	resp, body = ts.postForm(base, url.Values{
+		"_csrf":    {ts.csrfToken(base + "/edit")},
+		"title":    {"実験レポート"},
+		"priority": {"medium"},
+		"due_date": {"2030-01-01T09:00"},
+		"tags":     {"レポート, 提出"},
+	})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("update: status %d\n%s", resp.StatusCode, body)
+	}
+
+	pages := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"編集", base + "/edit", `value="レポート, 提出"`},
+		{"一覧", "/assignments?filter=all&tags=提出", "実験レポート"},
+		{"除く", "/assignments?filter=all&tags=提出&tag_mode=none", "課題なし"},
+		{"統計", "/statistics", "タグ別"},
+		{"統計の絞り込み", "/statistics?tag=提出", `<option value="提出" selected`},
+	}
+	for _, p := range pages {
+		t.Run(p.name, func(t *testing.T) {
+			resp, body := ts.get(p.path)
+			if resp.StatusCode != http.StatusOK || !strings.Contains(body, p.want) {
+				t.Errorf("%s: status %d, %q not found", p.path, resp.StatusCode, p.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/telegram"
+	"homework-manager/internal/telegram/telegramtest"
+)
+
+const telegramWebhookSecret = "webhook-secret"
+
+// newTelegramWebhookServer は偽の Bot API に webhook モードで接続したテストサーバーを起動する。
+func newTelegramWebhookServer(t *testing.T, secret string) (*testServer, *telegramtest.Server) {
+	t.Helper()
+	bot := telegramtest.New("123:test-token", "homework_bot")
+	api := httptest.NewServer(bot)
+	t.Cleanup(api.Close)
+
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.Notification.TelegramBotToken = bot.Token
+		cfg.Notification.TelegramAPIURL = api.URL
+		cfg.Notification.TelegramBotMode = "webhook"
+		cfg.Notification.TelegramWebhookURL = "https://homework.example.com/telegram/webhook"
+		cfg.Notification.TelegramWebhookSecret = secret
+	})
+	return ts, bot
+}
+
+func (ts *testServer) postTelegramUpdate(secret string, setHeader bool) *http.Response {
+	ts.t.Helper()
+	body, err := json.Marshal(telegram.Update{
+		UpdateID: 1,
+		Message:  &telegram.Message{MessageID: 1, Chat: telegram.Chat{ID: 42, Type: "private"}, Text: "/help"},
+	})
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, ts.server.URL+"/telegram/webhook", bytes.NewReader(body))
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if setHeader {
+		req.Header.Set(telegram.SecretTokenHeader, secret)
+	}
+	resp, _ := ts.do(ts.client, req)
+	return resp
+}
+
+func TestTelegramWebhookSecret(t *testing.T) {
+	ts, bot := newTelegramWebhookServer(t, telegramWebhookSecret)
+
+	tests := []struct {
+		name      string
+		secret    string
+		setHeader bool
+		want      int
+	}{
+		{"missing secret", "", false, http.StatusUnauthorized},
+		{"empty secret", "", true, http.StatusUnauthorized},
+		{"wrong secret", "wrong-secret", true, http.StatusUnauthorized},
+		{"correct secret", telegramWebhookSecret, true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := ts.postTelegramUpdate(tt.secret, tt.setHeader); resp.StatusCode != tt.want {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	// 認証に成功した更新だけが処理される
+	if sent := bot.Sent(); len(sent) != 1 || sent[0].ChatID != "42" {
+		t.Errorf("sent messages = %+v, want one reply to chat 42", sent)
+	}
+}
+
+func TestTelegramWebhookRequiresSecret(t *testing.T) {
+	ts, bot := newTelegramWebhookServer(t, "")
+
+	for _, call := range bot.Calls() {
+		if call == "setWebhook" {
+			t.Errorf("setWebhook was called without a secret")
+		}
+	}
+	// ボットが起動しないため Webhook は受け付けない
+	if resp := ts.postTelegramUpdate("", false); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if sent := bot.Sent(); len(sent) != 0 {
+		t.Errorf("sent messages = %+v, want none", sent)
+	}
+}
@@ -0,0 +1,62 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/service"
+	"homework-manager/internal/timezone"
+)
+
+func TestUserTimezone(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.register("tz@example.com", "password123")
+	ts.db.Model(user).Update("timezone", "Asia/Tokyo")
+	key, _, err := service.NewAPIKeyService(ts.db).CreateAPIKey(user.ID, "tz", models.APIKeyScopes, nil, "")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	auth := "Bearer " + key
+
+	// 現地時刻で 30 分前が期限の課題は、サーバーのタイムゾーンに関係なく期限切れになる
+	tokyo := timezone.Load("Asia/Tokyo")
+	due := time.Now().In(tokyo).Add(-30 * time.Minute).Truncate(time.Minute)
+	resp, body := ts.api("POST", "/api/v1/assignments", auth,
+		`{"title":"期限切れ","due_date":"`+due.Format("2006-01-02T15:04")+`"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create assignment: status %d\n%s", resp.StatusCode, body)
+	}
+	if want := `"due_date":"` + due.Format(time.RFC3339) + `"`; !strings.Contains(body, want) {
+		t.Errorf("response = %s, want %s", body, want)
+	}
+	var created models.Assignment
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatalf("decode: %v\n%s", err, body)
+	}
+
+	var stored string
+	ts.db.Raw("SELECT CAST(due_date AS TEXT) FROM assignments WHERE id = ?", created.ID).Scan(&stored)
+	if !strings.HasSuffix(stored, "+00:00") {
+		t.Errorf("stored due_date = %q, want UTC", stored)
+	}
+	if n, _ := repository.NewAssignmentRepository(ts.db).CountOverdueByUserID(user.ID); n != 1 {
+		t.Errorf("CountOverdueByUserID = %d, want 1", n)
+	}
+
+	resp, body = ts.api("GET", "/api/v1/assignments?filter=overdue", auth, "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"id":`+strconv.FormatUint(uint64(created.ID), 10)) {
+		t.Errorf("overdue list: status %d\n%s", resp.StatusCode, body)
+	}
+
+	// 画面はユーザーのタイムゾーンで表示する
+	resp, body = ts.get("/assignments/" + strconv.FormatUint(uint64(created.ID), 10) + "/edit")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `value="`+due.Format("2006-01-02T15:04")+`"`) {
+		t.Errorf("edit page: status %d, want due date input %s", resp.StatusCode, due.Format("2006-01-02T15:04"))
+	}
+}
@@ -0,0 +1,316 @@
+package rrule
+
+import (
+	"sort"
+	"time"
+)
+
+// 発生日が1件もない期間がこれだけ続いたら展開を打ち切る（満たせないルールでの無限ループ防止）
+const maxEmptyPeriods = 5000
+
+// Set は DTSTART を起点に Rule を展開し、ExDates を除外した発生日時の集合。
+// 発生日時の時刻は DTSTART の時刻に揃えられる。
+type Set struct {
+	Rule    *Rule
+	DTStart time.Time
+	ExDates []ExDate
+}
+
+func NewSet(rule *Rule, dtstart time.Time, exdates []ExDate) *Set {
+	return &Set{Rule: rule, DTStart: dtstart, ExDates: exdates}
+}
+
+// After は t より後の最初の発生日時を返す。存在しなければ false。
+func (s *Set) After(t time.Time) (time.Time, bool) {
+	var result time.Time
+	found := false
+	s.iterate(func(occ time.Time) bool {
+		if occ.After(t) {
+			result = occ
+			found = true
+			return false
+		}
+		return true
+	})
+	return result, found
+}
+
+// Between は from 以上 to 以下の発生日時を返す。
+func (s *Set) Between(from, to time.Time) []time.Time {
+	var result []time.Time
+	s.iterate(func(occ time.Time) bool {
+		if occ.After(to) {
+			return false
+		}
+		if !occ.Before(from) {
+			result = append(result, occ)
+		}
+		return true
+	})
+	return result
+}
+
+// All は先頭から最大 limit 件の発生日時を返す。
+func (s *Set) All(limit int) []time.Time {
+	var result []time.Time
+	s.iterate(func(occ time.Time) bool {
+		result = append(result, occ)
+		return len(result) < limit
+	})
+	return result
+}
+
+func (s *Set) excluded(t time.Time) bool {
+	for _, ex := range s.ExDates {
+		if ex.AllDay {
+			exDay := ex.Time
+			tDay := t.In(exDay.Location())
+			if tDay.Year() == exDay.Year() && tDay.Month() == exDay.Month() && tDay.Day() == exDay.Day() {
+				return true
+			}
+		} else if ex.Time.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Set) iterate(fn func(time.Time) bool) {
+	r := s.Rule
+	if r == nil {
+		return
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	count := 0
+	empty := 0
+	for period := 0; empty < maxEmptyPeriods; period++ {
+		candidates := r.periodCandidates(s.DTStart, period*interval)
+		if len(candidates) == 0 {
+			empty++
+			continue
+		}
+		empty = 0
+
+		for _, occ := range candidates {
+			if occ.Before(s.DTStart) {
+				continue
+			}
+			if !r.Until.IsZero() && occ.After(r.Until) {
+				return
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return
+			}
+			if s.excluded(occ) {
+				continue
+			}
+			if !fn(occ) {
+				return
+			}
+		}
+	}
+}
+
+// periodCandidates は DTSTART から offset 単位（日・週・月・年）進めた期間に含まれる発生日時を昇順で返す。
+func (r *Rule) periodCandidates(dtstart time.Time, offset int) []time.Time {
+	loc := dtstart.Location()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, loc)
+	}
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		day := at(dtstart.Year(), dtstart.Month(), dtstart.Day()+offset)
+		if r.matchesMonth(day.Month()) && r.matchesMonthDay(day) && r.matchesWeekday(day.Weekday()) {
+			days = append(days, day)
+		}
+
+	case Weekly:
+		shift := (int(dtstart.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := at(dtstart.Year(), dtstart.Month(), dtstart.Day()-shift+offset*7)
+		for i := 0; i < 7; i++ {
+			day := weekStart.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 {
+				if day.Weekday() != dtstart.Weekday() {
+					continue
+				}
+			} else if !r.matchesWeekday(day.Weekday()) {
+				continue
+			}
+			if r.matchesMonth(day.Month()) {
+				days = append(days, day)
+			}
+		}
+
+	case Monthly:
+		first := at(dtstart.Year(), dtstart.Month()+time.Month(offset), 1)
+		if r.matchesMonth(first.Month()) {
+			days = r.expandMonth(first.Year(), first.Month(), dtstart, at)
+		}
+
+	case Yearly:
+		year := dtstart.Year() + offset
+		hasOrdinal := false
+		for _, wd := range r.ByDay {
+			if wd.N != 0 {
+				hasOrdinal = true
+			}
+		}
+		if hasOrdinal && len(r.ByMonth) == 0 {
+			days = r.expandYearWeekdays(year, at)
+			break
+		}
+		months := r.ByMonth
+		if len(months) == 0 {
+			if len(r.ByMonthDay) > 0 || len(r.ByDay) > 0 {
+				for m := time.January; m <= time.December; m++ {
+					months = append(months, m)
+				}
+			} else {
+				months = []time.Month{dtstart.Month()}
+			}
+		}
+		for _, m := range months {
+			days = append(days, r.expandMonth(year, m, dtstart, at)...)
+		}
+	}
+
+	days = sortUnique(days)
+	return applySetPos(days, r.BySetPos)
+}
+
+func (r *Rule) expandMonth(year int, month time.Month, dtstart time.Time, at func(int, time.Month, int) time.Time) []time.Time {
+	lastDay := at(year, month+1, 0).Day()
+	var days []time.Time
+
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, md := range r.ByMonthDay {
+			d := md
+			if d < 0 {
+				d = lastDay + md + 1
+			}
+			if d < 1 || d > lastDay {
+				continue
+			}
+			day := at(year, month, d)
+			if len(r.ByDay) == 0 || r.matchesWeekday(day.Weekday()) {
+				days = append(days, day)
+			}
+		}
+
+	case len(r.ByDay) > 0:
+		for _, wd := range r.ByDay {
+			var matches []time.Time
+			for d := 1; d <= lastDay; d++ {
+				day := at(year, month, d)
+				if day.Weekday() == wd.Day {
+					matches = append(matches, day)
+				}
+			}
+			days = append(days, pickOrdinal(matches, wd.N)...)
+		}
+
+	default:
+		if dtstart.Day() <= lastDay {
+			days = append(days, at(year, month, dtstart.Day()))
+		}
+	}
+	return days
+}
+
+func (r *Rule) expandYearWeekdays(year int, at func(int, time.Month, int) time.Time) []time.Time {
+	var days []time.Time
+	for _, wd := range r.ByDay {
+		var matches []time.Time
+		for day := at(year, time.January, 1); day.Year() == year; day = day.AddDate(0, 0, 1) {
+			if day.Weekday() == wd.Day {
+				matches = append(matches, day)
+			}
+		}
+		days = append(days, pickOrdinal(matches, wd.N)...)
+	}
+	return days
+}
+
+func pickOrdinal(matches []time.Time, n int) []time.Time {
+	switch {
+	case n == 0:
+		return matches
+	case n > 0 && n <= len(matches):
+		return []time.Time{matches[n-1]}
+	case n < 0 && -n <= len(matches):
+		return []time.Time{matches[len(matches)+n]}
+	}
+	return nil
+}
+
+func (r *Rule) matchesMonth(m time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, bm := range r.ByMonth {
+		if bm == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	lastDay := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	for _, md := range r.ByMonthDay {
+		if md == t.Day() || (md < 0 && lastDay+md+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(wd time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, bd := range r.ByDay {
+		if bd.Day == wd {
+			return true
+		}
+	}
+	return false
+}
+
+func sortUnique(days []time.Time) []time.Time {
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	result := days[:0]
+	for i, d := range days {
+		if i == 0 || !d.Equal(days[i-1]) {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+func applySetPos(days []time.Time, setPos []int) []time.Time {
+	if len(setPos) == 0 || len(days) == 0 {
+		return days
+	}
+	var result []time.Time
+	for _, pos := range setPos {
+		switch {
+		case pos > 0 && pos <= len(days):
+			result = append(result, days[pos-1])
+		case pos < 0 && -pos <= len(days):
+			result = append(result, days[len(days)+pos])
+		}
+	}
+	return sortUnique(result)
+}
@@ -0,0 +1,344 @@
+// Package rrule は RFC 5545 の RRULE / EXDATE を解析・展開する。
+// 課題の繰り返しに必要な FREQ (DAILY/WEEKLY/MONTHLY/YEARLY)、INTERVAL、COUNT、UNTIL、
+// BYDAY、BYMONTHDAY、BYMONTH、BYSETPOS、WKST をサポートする。
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencyNames = map[Frequency]string{
+	Daily:   "DAILY",
+	Weekly:  "WEEKLY",
+	Monthly: "MONTHLY",
+	Yearly:  "YEARLY",
+}
+
+func (f Frequency) String() string {
+	return frequencyNames[f]
+}
+
+var weekdayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Weekday は BYDAY の1要素。N は序数（2 = 第2、-1 = 最終、0 = 序数なし）。
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+func (w Weekday) String() string {
+	if w.N == 0 {
+		return weekdayCodes[w.Day]
+	}
+	return strconv.Itoa(w.N) + weekdayCodes[w.Day]
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []Weekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+var ErrInvalidRule = errors.New("invalid RRULE")
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRule, fmt.Sprintf(format, args...))
+}
+
+// Parse は RRULE 文字列を解析する。タイムゾーン指定のない UNTIL はローカル時刻として扱う。
+func Parse(s string) (*Rule, error) {
+	return ParseInLocation(s, time.Local)
+}
+
+func ParseInLocation(s string, loc *time.Location) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "RRULE:"), "rrule:")
+	if s == "" {
+		return nil, invalid("empty rule")
+	}
+
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	hasFreq := false
+
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, invalid("malformed part %q", part)
+		}
+		key, value := strings.ToUpper(strings.TrimSpace(kv[0])), strings.ToUpper(strings.TrimSpace(kv[1]))
+
+		switch key {
+		case "FREQ":
+			found := false
+			for f, name := range frequencyNames {
+				if name == value {
+					rule.Freq = f
+					found = true
+				}
+			}
+			if !found {
+				return nil, invalid("unsupported FREQ %q", value)
+			}
+			hasFreq = true
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, invalid("INTERVAL must be a positive integer")
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, invalid("COUNT must be a positive integer")
+			}
+			rule.Count = n
+		case "UNTIL":
+			t, allDay, err := parseDateTime(value, loc)
+			if err != nil {
+				return nil, invalid("UNTIL: %v", err)
+			}
+			if allDay {
+				// 日付のみの UNTIL はその日の発生を含める
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			rule.Until = t
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				wd, err := parseWeekday(v)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			days, err := parseIntList(value, -31, 31)
+			if err != nil {
+				return nil, invalid("BYMONTHDAY: %v", err)
+			}
+			rule.ByMonthDay = days
+		case "BYMONTH":
+			months, err := parseIntList(value, 1, 12)
+			if err != nil {
+				return nil, invalid("BYMONTH: %v", err)
+			}
+			for _, m := range months {
+				rule.ByMonth = append(rule.ByMonth, time.Month(m))
+			}
+		case "BYSETPOS":
+			pos, err := parseIntList(value, -366, 366)
+			if err != nil {
+				return nil, invalid("BYSETPOS: %v", err)
+			}
+			rule.BySetPos = pos
+		case "WKST":
+			wd, err := parseWeekday(value)
+			if err != nil || wd.N != 0 {
+				return nil, invalid("WKST must be a weekday")
+			}
+			rule.WeekStart = wd.Day
+		default:
+			return nil, invalid("unsupported part %q", key)
+		}
+	}
+
+	if !hasFreq {
+		return nil, invalid("FREQ is required")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, invalid("COUNT and UNTIL must not both be set")
+	}
+	if len(rule.BySetPos) > 0 && len(rule.ByDay) == 0 && len(rule.ByMonthDay) == 0 && len(rule.ByMonth) == 0 {
+		return nil, invalid("BYSETPOS requires another BYxxx part")
+	}
+	for _, wd := range rule.ByDay {
+		if wd.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return nil, invalid("ordinal BYDAY is only allowed with MONTHLY or YEARLY")
+		}
+	}
+
+	return rule, nil
+}
+
+func parseWeekday(s string) (Weekday, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return Weekday{}, invalid("invalid weekday %q", s)
+	}
+	code := s[len(s)-2:]
+	for i, c := range weekdayCodes {
+		if c != code {
+			continue
+		}
+		wd := Weekday{Day: time.Weekday(i)}
+		if prefix := s[:len(s)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return Weekday{}, invalid("invalid weekday ordinal %q", s)
+			}
+			wd.N = n
+		}
+		return wd, nil
+	}
+	return Weekday{}, invalid("invalid weekday %q", s)
+}
+
+func parseIntList(s string, min, max int) ([]int, error) {
+	var result []int
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("value %q out of range", v)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+// parseDateTime は DATE (YYYYMMDD)、ローカル DATE-TIME、UTC DATE-TIME (末尾 Z) を解析する。
+// 2番目の戻り値は DATE 形式（終日）だった場合に true。
+func parseDateTime(s string, loc *time.Location) (time.Time, bool, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case len(s) == 8:
+		t, err := time.ParseInLocation("20060102", s, loc)
+		return t, true, err
+	case len(s) == 10 && strings.Count(s, "-") == 2:
+		t, err := time.ParseInLocation("2006-01-02", s, loc)
+		return t, true, err
+	case strings.HasSuffix(s, "Z"):
+		t, err := time.Parse("20060102T150405Z", s)
+		return t, false, err
+	default:
+		t, err := time.ParseInLocation("20060102T150405", s, loc)
+		return t, false, err
+	}
+}
+
+func (r *Rule) String() string {
+	return r.format(false)
+}
+
+// FloatingString は UNTIL をタイムゾーンなしの現地時刻で出力する（DTSTART がフローティング時刻の場合に使う）。
+func (r *Rule) FloatingString() string {
+	return r.format(true)
+}
+
+func (r *Rule) format(floating bool) string {
+	parts := []string{"FREQ=" + r.Freq.String()}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		if floating {
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405"))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+		}
+	}
+	if len(r.ByMonth) > 0 {
+		var months []string
+		for _, m := range r.ByMonth {
+			months = append(months, strconv.Itoa(int(m)))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, wd := range r.ByDay {
+			days = append(days, wd.String())
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayCodes[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+func joinInts(values []int) string {
+	var s []string
+	for _, v := range values {
+		s = append(s, strconv.Itoa(v))
+	}
+	return strings.Join(s, ",")
+}
+
+// ClampedMonthDay は「毎月 day 日（その月に存在しなければ月末）」を表す BYMONTHDAY / BYSETPOS を返す。
+func ClampedMonthDay(day int) (byMonthDay []int, bySetPos []int) {
+	if day <= 28 {
+		return []int{day}, nil
+	}
+	for d := 28; d <= day; d++ {
+		byMonthDay = append(byMonthDay, d)
+	}
+	return byMonthDay, []int{-1}
+}
+
+// ExDate は EXDATE の1要素。AllDay の場合はその日の発生をすべて除外する。
+type ExDate struct {
+	Time   time.Time
+	AllDay bool
+}
+
+// ParseExDates はカンマまたは空白区切りの EXDATE 一覧を解析する。
+func ParseExDates(s string, loc *time.Location) ([]ExDate, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "EXDATE:")
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+
+	var result []ExDate
+	for _, f := range fields {
+		t, allDay, err := parseDateTime(f, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EXDATE %q", f)
+		}
+		result = append(result, ExDate{Time: t, AllDay: allDay})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Time.Before(result[j].Time) })
+	return result, nil
+}
+
+func FormatExDates(exdates []ExDate) string {
+	var parts []string
+	for _, ex := range exdates {
+		if ex.AllDay {
+			parts = append(parts, ex.Time.Format("20060102"))
+		} else {
+			parts = append(parts, ex.Time.Format("20060102T150405"))
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,218 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 9, 0, 0, 0, time.UTC)
+}
+
+func TestSetAll(t *testing.T) {
+	monday := day(2026, time.January, 5)
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		exdates string
+		limit   int
+		want    []time.Time
+	}{
+		{
+			name:    "BYDAY の複数曜日",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=5",
+			dtstart: monday,
+			want:    []time.Time{day(2026, 1, 5), day(2026, 1, 7), day(2026, 1, 9), day(2026, 1, 12), day(2026, 1, 14)},
+		},
+		{
+			name:    "隔週の火・木曜日",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;COUNT=4",
+			dtstart: monday,
+			want:    []time.Time{day(2026, 1, 6), day(2026, 1, 8), day(2026, 1, 20), day(2026, 1, 22)},
+		},
+		{
+			name:    "3日ごと",
+			rule:    "FREQ=DAILY;INTERVAL=3;COUNT=4",
+			dtstart: monday,
+			want:    []time.Time{day(2026, 1, 5), day(2026, 1, 8), day(2026, 1, 11), day(2026, 1, 14)},
+		},
+		{
+			name:    "第2火曜日",
+			rule:    "FREQ=MONTHLY;BYDAY=2TU;COUNT=3",
+			dtstart: monday,
+			want:    []time.Time{day(2026, 1, 13), day(2026, 2, 10), day(2026, 3, 10)},
+		},
+		{
+			name:    "最終金曜日",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			dtstart: monday,
+			want:    []time.Time{day(2026, 1, 30), day(2026, 2, 27), day(2026, 3, 27)},
+		},
+		{
+			name:    "BYSETPOS で最終平日",
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=3",
+			dtstart: monday,
+			want:    []time.Time{day(2026, 1, 30), day(2026, 2, 27), day(2026, 3, 31)},
+		},
+		{
+			name:    "BYSETPOS で毎月31日（なければ月末）",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1;COUNT=4",
+			dtstart: day(2026, 1, 31),
+			want:    []time.Time{day(2026, 1, 31), day(2026, 2, 28), day(2026, 3, 31), day(2026, 4, 30)},
+		},
+		{
+			name:    "毎年11月の第4木曜日",
+			rule:    "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=2",
+			dtstart: monday,
+			want:    []time.Time{day(2026, 11, 26), day(2027, 11, 25)},
+		},
+		{
+			name:    "日付だけの UNTIL はその日を含む",
+			rule:    "FREQ=DAILY;UNTIL=20260108",
+			dtstart: monday,
+			want:    []time.Time{day(2026, 1, 5), day(2026, 1, 6), day(2026, 1, 7), day(2026, 1, 8)},
+		},
+		{
+			name:    "日時の UNTIL は同時刻まで含む",
+			rule:    "FREQ=DAILY;UNTIL=20260107T090000Z",
+			dtstart: monday,
+			want:    []time.Time{day(2026, 1, 5), day(2026, 1, 6), day(2026, 1, 7)},
+		},
+		{
+			name:    "COUNT は EXDATE で除外した日も数える",
+			rule:    "FREQ=DAILY;COUNT=4",
+			dtstart: monday,
+			exdates: "20260106",
+			want:    []time.Time{day(2026, 1, 5), day(2026, 1, 7), day(2026, 1, 8)},
+		},
+		{
+			name:    "日時の EXDATE は同時刻だけを除外する",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: monday,
+			exdates: "20260106T090000Z,20260107T100000Z",
+			want:    []time.Time{day(2026, 1, 5), day(2026, 1, 7)},
+		},
+		{
+			name:    "満たせないルールは打ち切る",
+			rule:    "FREQ=MONTHLY;BYMONTH=2;BYMONTHDAY=30",
+			dtstart: monday,
+			want:    nil,
+		},
+		{
+			name:    "COUNT も UNTIL もなければ limit 件",
+			rule:    "FREQ=WEEKLY",
+			dtstart: monday,
+			limit:   2,
+			want:    []time.Time{day(2026, 1, 5), day(2026, 1, 12)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseInLocation(tt.rule, time.UTC)
+			if err != nil {
+				t.Fatalf("ParseInLocation(%q): %v", tt.rule, err)
+			}
+			exdates, err := ParseExDates(tt.exdates, time.UTC)
+			if err != nil {
+				t.Fatalf("ParseExDates(%q): %v", tt.exdates, err)
+			}
+			limit := tt.limit
+			if limit == 0 {
+				limit = 100
+			}
+
+			got := NewSet(rule, tt.dtstart, exdates).All(limit)
+			if len(got) != len(tt.want) {
+				t.Fatalf("All() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("All()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSetAfterAndBetween(t *testing.T) {
+	rule, _ := ParseInLocation("FREQ=WEEKLY;BYDAY=MO,TH", time.UTC)
+	set := NewSet(rule, day(2026, 1, 5), nil)
+
+	if next, ok := set.After(day(2026, 1, 5)); !ok || !next.Equal(day(2026, 1, 8)) {
+		t.Errorf("After = %v, %v", next, ok)
+	}
+	got := set.Between(day(2026, 1, 8), day(2026, 1, 15))
+	if len(got) != 3 || !got[0].Equal(day(2026, 1, 8)) || !got[2].Equal(day(2026, 1, 15)) {
+		t.Errorf("Between = %v", got)
+	}
+
+	// 発生日のないルールでも maxEmptyPeriods で止まる
+	never, _ := ParseInLocation("FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=31", time.UTC)
+	if next, ok := NewSet(never, day(2026, 1, 5), nil).After(day(2026, 1, 5)); ok {
+		t.Errorf("After on an unsatisfiable rule = %v", next)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{"空", ""},
+		{"FREQ なし", "BYDAY=MO"},
+		{"未対応の FREQ", "FREQ=HOURLY"},
+		{"INTERVAL が 0", "FREQ=DAILY;INTERVAL=0"},
+		{"COUNT と UNTIL の両方", "FREQ=DAILY;COUNT=3;UNTIL=20260110"},
+		{"WEEKLY で序数付き BYDAY", "FREQ=WEEKLY;BYDAY=2MO"},
+		{"BYSETPOS だけ", "FREQ=MONTHLY;BYSETPOS=1"},
+		{"不正な曜日", "FREQ=WEEKLY;BYDAY=XX"},
+		{"範囲外の BYMONTHDAY", "FREQ=MONTHLY;BYMONTHDAY=32"},
+		{"未対応の要素", "FREQ=DAILY;BYHOUR=9"},
+		{"= のない要素", "FREQ=DAILY;COUNT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.rule); !errors.Is(err, ErrInvalidRule) {
+				t.Errorf("Parse(%q) err = %v, want %v", tt.rule, err, ErrInvalidRule)
+			}
+		})
+	}
+}
+
+func TestRuleStringRoundTrip(t *testing.T) {
+	for _, s := range []string{
+		"FREQ=DAILY",
+		"FREQ=WEEKLY;INTERVAL=2;COUNT=10;BYDAY=MO,WE,FR",
+		"FREQ=MONTHLY;BYDAY=-1FR",
+		"FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1",
+		"FREQ=YEARLY;UNTIL=20301231T150000Z;BYMONTH=11;BYDAY=4TH;WKST=SU",
+	} {
+		rule, err := ParseInLocation(s, time.UTC)
+		if err != nil {
+			t.Fatalf("ParseInLocation(%q): %v", s, err)
+		}
+		if got := rule.String(); got != s {
+			t.Errorf("String() = %q, want %q", got, s)
+		}
+	}
+}
+
+func TestParseExDates(t *testing.T) {
+	exdates, err := ParseExDates("EXDATE:20260110T090000Z, 20260105", time.UTC)
+	if err != nil {
+		t.Fatalf("ParseExDates: %v", err)
+	}
+	if len(exdates) != 2 || !exdates[0].AllDay || exdates[1].AllDay || !exdates[1].Time.Equal(day(2026, 1, 10)) {
+		t.Errorf("ParseExDates = %+v", exdates)
+	}
+	if got := FormatExDates(exdates); got != "20260105,20260110T090000" {
+		t.Errorf("FormatExDates = %q", got)
+	}
+	if _, err := ParseExDates("2026-13-40", time.UTC); err == nil {
+		t.Error("ParseExDates accepted an invalid date")
+	}
+}
@@ -0,0 +1,125 @@
+package rrule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var weekdayLabels = []string{"日", "月", "火", "水", "木", "金", "土"}
+
+// Summary は画面表示用の日本語の説明を返す（例: "2週間ごと (月・水・金曜日)"、"毎月 (第2火曜日)"）。
+// COUNT / UNTIL は含めない。
+func (r *Rule) Summary() string {
+	var parts []string
+
+	if r.Interval > 1 {
+		switch r.Freq {
+		case Daily:
+			parts = append(parts, fmt.Sprintf("%d日ごと", r.Interval))
+		case Weekly:
+			parts = append(parts, fmt.Sprintf("%d週間ごと", r.Interval))
+		case Monthly:
+			parts = append(parts, fmt.Sprintf("%dヶ月ごと", r.Interval))
+		case Yearly:
+			parts = append(parts, fmt.Sprintf("%d年ごと", r.Interval))
+		}
+	} else {
+		parts = append(parts, map[Frequency]string{
+			Daily:   "毎日",
+			Weekly:  "毎週",
+			Monthly: "毎月",
+			Yearly:  "毎年",
+		}[r.Freq])
+	}
+
+	if len(r.ByMonth) > 0 {
+		var months []string
+		for _, m := range r.ByMonth {
+			months = append(months, fmt.Sprintf("%d月", m))
+		}
+		parts = append(parts, "("+strings.Join(months, "・")+")")
+	}
+
+	if detail := r.dayDetail(); detail != "" {
+		parts = append(parts, "("+detail+")")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (r *Rule) dayDetail() string {
+	if len(r.ByMonthDay) > 0 {
+		if len(r.BySetPos) == 1 && r.BySetPos[0] == -1 && len(r.ByMonthDay) > 1 && r.ByMonthDay[0] == 28 {
+			// ClampedMonthDay で生成したルール
+			return fmt.Sprintf("%d日", r.ByMonthDay[len(r.ByMonthDay)-1])
+		}
+		var days []string
+		for _, d := range r.ByMonthDay {
+			if d == -1 {
+				days = append(days, "末日")
+			} else if d < 0 {
+				days = append(days, fmt.Sprintf("末日から%d日前", -d-1))
+			} else {
+				days = append(days, fmt.Sprintf("%d日", d))
+			}
+		}
+		return strings.Join(days, "・")
+	}
+
+	if len(r.ByDay) == 0 {
+		return ""
+	}
+
+	if len(r.BySetPos) == 1 && isWeekdaysOnly(r.ByDay) {
+		return ordinalLabel(r.BySetPos[0]) + "平日"
+	}
+
+	var labels []string
+	for _, wd := range r.ByDay {
+		if wd.N != 0 {
+			labels = append(labels, ordinalLabel(wd.N)+weekdayLabels[wd.Day]+"曜日")
+		}
+	}
+	if len(labels) > 0 {
+		return strings.Join(labels, "・")
+	}
+
+	days := make([]int, 0, len(r.ByDay))
+	for _, wd := range r.ByDay {
+		days = append(days, int(wd.Day))
+	}
+	sort.Ints(days)
+	for _, d := range days {
+		labels = append(labels, weekdayLabels[d])
+	}
+	detail := strings.Join(labels, "・") + "曜日"
+	if len(r.BySetPos) == 1 {
+		detail = ordinalLabel(r.BySetPos[0]) + "の" + detail
+	}
+	return detail
+}
+
+func isWeekdaysOnly(days []Weekday) bool {
+	if len(days) != 5 {
+		return false
+	}
+	for _, wd := range days {
+		if wd.N != 0 || wd.Day == time.Saturday || wd.Day == time.Sunday {
+			return false
+		}
+	}
+	return true
+}
+
+func ordinalLabel(n int) string {
+	switch {
+	case n == -1:
+		return "最終"
+	case n < 0:
+		return fmt.Sprintf("最後から%d番目の", -n)
+	default:
+		return fmt.Sprintf("第%d", n)
+	}
+}
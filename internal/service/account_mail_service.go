@@ -0,0 +1,328 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/mail"
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/sessionstore"
+
+	"gorm.io/gorm"
+)
+
+// accountMailWindow はアカウントごとの送信数を数える期間。
+const accountMailWindow = time.Hour
+
+var (
+	ErrAccountMailDisabled    = errors.New("account mail is not configured")
+	ErrAccountMailRateLimited = errors.New("too many account mails requested")
+	ErrInvalidToken           = errors.New("invalid or expired token")
+	ErrEmailNotVerified       = errors.New("email address is not verified")
+)
+
+// AccountMailService はパスワード再設定とメールアドレス確認のメールを送り、リンクのトークンを検証する。
+//
+// トークンは「乱数.署名」の形式で、署名は用途ごとに SESSION_SECRET で計算した HMAC。
+// 改ざんされたトークンはデータベースを引かずに拒否し、正しいトークンもハッシュで照合して 1 回だけ使える。
+type AccountMailService struct {
+	sender              *mail.Sender
+	authService         *AuthService
+	userRepo            *repository.UserRepository
+	tokenRepo           *repository.UserTokenRepository
+	secret              []byte
+	baseURL             string
+	resetTTL            time.Duration
+	verificationTTL     time.Duration
+	limit               int
+	requireVerification bool
+}
+
+func NewAccountMailService(db *gorm.DB, sessions sessionstore.Backend, cfg *config.Config, sender *mail.Sender) *AccountMailService {
+	return &AccountMailService{
+		sender:              sender,
+		authService:         NewAuthService(db, sessions),
+		userRepo:            repository.NewUserRepository(db),
+		tokenRepo:           repository.NewUserTokenRepository(db),
+		secret:              []byte(cfg.SessionSecret),
+		baseURL:             cfg.BaseURL,
+		resetTTL:            time.Duration(cfg.PasswordResetTTL) * time.Minute,
+		verificationTTL:     time.Duration(cfg.EmailVerificationTTL) * time.Minute,
+		limit:               cfg.AccountMailLimit,
+		requireVerification: cfg.RequireEmailVerification,
+	}
+}
+
+// Enabled はメールを送れる（SMTP と公開URLが設定されている）かを返す。
+func (s *AccountMailService) Enabled() bool {
+	return s.sender != nil && s.sender.Configured() && s.baseURL != ""
+}
+
+// VerificationRequired はメールアドレスの確認が済むまでログインできない設定かを返す。
+func (s *AccountMailService) VerificationRequired() bool {
+	return s.requireVerification
+}
+
+// CanLogin はメールアドレスの確認が必要な設定で、未確認のユーザーを拒否する。
+func (s *AccountMailService) CanLogin(user *models.User) error {
+	if s.requireVerification && !user.EmailVerified() {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// RequestPasswordReset はパスワード再設定のメールを送る。
+// メールアドレスが登録されているかを推測されないよう、該当するアカウントがない場合や送信数の上限に達した場合も呼び出し側には成功と同じ扱いをさせる。
+func (s *AccountMailService) RequestPasswordReset(email string) error {
+	if !s.Enabled() {
+		return ErrAccountMailDisabled
+	}
+	user, err := s.userRepo.FindByEmail(strings.TrimSpace(email))
+	if err != nil || !user.HasPassword() {
+		// シングルサインオンで作成したアカウントはプロバイダー側でパスワードを管理する
+		return nil
+	}
+
+	token, err := s.issue(user, models.TokenPurposePasswordReset, s.resetTTL)
+	if err != nil {
+		return err
+	}
+	link := s.baseURL + "/password/reset?token=" + url.QueryEscape(token)
+	s.sendAsync(user.Email, accountMessage(
+		"パスワードの再設定",
+		"パスワードの再設定が要求されました。下のリンクから新しいパスワードを設定してください。",
+		link,
+		fmt.Sprintf("リンクの有効期限は%sです。心当たりがない場合はこのメールを無視してください。パスワードは変更されません。", formatTTL(s.resetTTL)),
+	))
+	return nil
+}
+
+// CheckPasswordResetToken はパスワード再設定のトークンが使えるかを確認する。トークンは消費しない。
+func (s *AccountMailService) CheckPasswordResetToken(token string) error {
+	_, _, err := s.lookup(models.TokenPurposePasswordReset, token)
+	return err
+}
+
+// ResetPassword はトークンを消費して新しいパスワードを設定する。同じユーザーの他の再設定リンクも無効にする。
+func (s *AccountMailService) ResetPassword(token, newPassword string) (*models.User, error) {
+	record, user, err := s.consume(models.TokenPurposePasswordReset, token)
+	if err != nil {
+		return nil, err
+	}
+	// 再設定のメールを受け取れたので、メールアドレスも確認済みとする
+	if !user.EmailVerified() {
+		if err := s.markVerified(user, record.UsedAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.authService.ResetPassword(user.ID, newPassword); err != nil {
+		return nil, err
+	}
+	s.tokenRepo.InvalidateByUser(user.ID, models.TokenPurposePasswordReset, time.Now())
+	return s.userRepo.FindByID(user.ID)
+}
+
+// SendVerification はメールアドレス確認のメールを送る。送信数の上限に達している場合は ErrAccountMailRateLimited を返す。
+func (s *AccountMailService) SendVerification(user *models.User) error {
+	if !s.Enabled() {
+		return ErrAccountMailDisabled
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+
+	token, err := s.issue(user, models.TokenPurposeEmailVerification, s.verificationTTL)
+	if err != nil {
+		return err
+	}
+	link := s.baseURL + "/verify-email?token=" + url.QueryEscape(token)
+	s.sendAsync(user.Email, accountMessage(
+		"メールアドレスの確認",
+		"Super-HomeworkManager へのご登録ありがとうございます。下のリンクを開いてメールアドレスを確認してください。",
+		link,
+		fmt.Sprintf("リンクの有効期限は%sです。心当たりがない場合はこのメールを無視してください。", formatTTL(s.verificationTTL)),
+	))
+	return nil
+}
+
+// RequestVerification はメールアドレスを指定して確認メールを再送する。RequestPasswordReset と同じく、結果を呼び出し側に区別させない。
+func (s *AccountMailService) RequestVerification(email string) error {
+	if !s.Enabled() {
+		return ErrAccountMailDisabled
+	}
+	user, err := s.userRepo.FindByEmail(strings.TrimSpace(email))
+	if err != nil {
+		return nil
+	}
+	if err := s.SendVerification(user); err != nil && !errors.Is(err, ErrAccountMailRateLimited) {
+		return err
+	}
+	return nil
+}
+
+// VerifyEmail はトークンを消費してメールアドレスを確認済みにする。
+func (s *AccountMailService) VerifyEmail(token string) (*models.User, error) {
+	record, user, err := s.consume(models.TokenPurposeEmailVerification, token)
+	if err != nil {
+		return nil, err
+	}
+	if !user.EmailVerified() {
+		if err := s.markVerified(user, record.UsedAt); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+func (s *AccountMailService) markVerified(user *models.User, at *time.Time) error {
+	now := time.Now().UTC()
+	if at == nil {
+		at = &now
+	}
+	user.EmailVerifiedAt = at
+	return s.userRepo.Update(user)
+}
+
+// issue はトークンを発行して保存し、平文を返す。直近 1 時間の発行数が上限に達している場合は発行しない。
+func (s *AccountMailService) issue(user *models.User, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now().UTC()
+	// 送信数の集計に使う期間を過ぎた期限切れのトークンは不要なので、発行のついでに削除する
+	if err := s.tokenRepo.DeleteExpired(now.Add(-accountMailWindow)); err != nil {
+		log.Printf("Error deleting expired user tokens: %v", err)
+	}
+	count, err := s.tokenRepo.CountSince(user.ID, purpose, now.Add(-accountMailWindow))
+	if err != nil {
+		return "", err
+	}
+	if count >= int64(s.limit) {
+		return "", ErrAccountMailRateLimited
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(random)
+	token := nonce + "." + s.sign(purpose, nonce)
+
+	record := &models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		Email:     user.Email,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.tokenRepo.Create(record); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// lookup は署名と保存済みのトークンを照合する。発行後にメールアドレスが変わったトークンは無効とする。
+func (s *AccountMailService) lookup(purpose, token string) (*models.UserToken, *models.User, error) {
+	nonce, signature, ok := strings.Cut(token, ".")
+	if !ok || nonce == "" || !hmac.Equal([]byte(signature), []byte(s.sign(purpose, nonce))) {
+		return nil, nil, ErrInvalidToken
+	}
+	record, err := s.tokenRepo.FindValid(purpose, hashToken(token), time.Now())
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	user, err := s.userRepo.FindByID(record.UserID)
+	if err != nil || !strings.EqualFold(user.Email, record.Email) {
+		return nil, nil, ErrInvalidToken
+	}
+	return record, user, nil
+}
+
+func (s *AccountMailService) consume(purpose, token string) (*models.UserToken, *models.User, error) {
+	record, user, err := s.lookup(purpose, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now().UTC()
+	used, err := s.tokenRepo.MarkUsed(record.ID, now)
+	if err != nil || !used {
+		return nil, nil, ErrInvalidToken
+	}
+	record.UsedAt = &now
+	return record, user, nil
+}
+
+func (s *AccountMailService) sign(purpose, nonce string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(purpose + "." + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sendAsync はメールをバックグラウンドで送る。応答時間の差からアカウントの有無を推測されないようにする。
+func (s *AccountMailService) sendAsync(to string, msg *NotificationMessage) {
+	go func() {
+		err := s.sender.Send(&mail.Message{
+			To:      to,
+			Subject: msg.Subject,
+			Text:    msg.Text,
+			HTML:    msg.HTML,
+		})
+		if err != nil {
+			log.Printf("Error sending account mail: %v", err)
+		}
+	}()
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func formatTTL(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d時間", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d分", int(d.Minutes()))
+}
+
+var accountMailHTMLTemplate = template.Must(template.New("account").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<body style="font-family: sans-serif; color: #212529;">
+<h2 style="font-size: 18px;">{{.Heading}}</h2>
+<p>{{.Body}}</p>
+<p><a href="{{.Link}}" style="display: inline-block; padding: 8px 16px; background: #0d6efd; color: #fff; text-decoration: none; border-radius: 4px;">{{.Heading}}</a></p>
+<p style="color: #6c757d; font-size: 12px; word-break: break-all;">{{.Link}}</p>
+<p style="color: #6c757d;">{{.Footer}}</p>
+</body>
+</html>
+`))
+
+// accountMessage はアカウント関連のメールの本文をテキストと HTML の両方で組み立てる。
+func accountMessage(heading, body, link, footer string) *NotificationMessage {
+	var htmlBody bytes.Buffer
+	err := accountMailHTMLTemplate.Execute(&htmlBody, map[string]interface{}{
+		"Heading": heading,
+		"Body":    body,
+		"Link":    template.URL(link),
+		"Footer":  footer,
+	})
+	if err != nil {
+		htmlBody.Reset()
+	}
+
+	return &NotificationMessage{
+		Subject: "【Super-HomeworkManager】" + heading,
+		Text:    heading + "\n\n" + body + "\n\n" + link + "\n\n" + footer,
+		HTML:    htmlBody.String(),
+	}
+}
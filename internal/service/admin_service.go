@@ -5,20 +5,27 @@ import (
 
 	"homework-manager/internal/models"
 	"homework-manager/internal/repository"
+
+	"gorm.io/gorm"
 )
 
 var (
-	ErrCannotDeleteSelf = errors.New("cannot delete yourself")
+	ErrCannotDeleteSelf     = errors.New("cannot delete yourself")
 	ErrCannotChangeSelfRole = errors.New("cannot change your own role")
 )
 
 type AdminService struct {
-	userRepo *repository.UserRepository
+	userRepo     *repository.UserRepository
+	webauthnRepo *repository.WebAuthnCredentialRepository
+	attachments  *AttachmentService
 }
 
-func NewAdminService() *AdminService {
+// NewAdminService は AdminService を返す。ユーザーを削除するときは attachments で添付ファイルも削除する。
+func NewAdminService(db *gorm.DB, attachments *AttachmentService) *AdminService {
 	return &AdminService{
-		userRepo: repository.NewUserRepository(),
+		userRepo:     repository.NewUserRepository(db),
+		webauthnRepo: repository.NewWebAuthnCredentialRepository(db),
+		attachments:  attachments,
 	}
 }
 
@@ -26,6 +33,11 @@ func (s *AdminService) GetAllUsers() ([]models.User, error) {
 	return s.userRepo.FindAll()
 }
 
+// GetWebAuthnCredentialCounts はユーザーIDごとの登録済みパスキーの数を返す。
+func (s *AdminService) GetWebAuthnCredentialCounts() (map[uint]int64, error) {
+	return s.webauthnRepo.CountGroupedByUser()
+}
+
 func (s *AdminService) GetUserByID(id uint) (*models.User, error) {
 	return s.userRepo.FindByID(id)
 }
@@ -40,6 +52,9 @@ func (s *AdminService) DeleteUser(adminID, targetID uint) error {
 		return ErrUserNotFound
 	}
 
+	if err := s.attachments.DeleteByUser(targetID); err != nil {
+		return err
+	}
 	return s.userRepo.Delete(targetID)
 }
 
@@ -48,7 +63,7 @@ func (s *AdminService) ChangeRole(adminID, targetID uint, newRole string) error
 		return ErrCannotChangeSelfRole
 	}
 
-	if newRole != "admin" && newRole != "user" {
+	if newRole != "admin" && newRole != "teacher" && newRole != "user" {
 		return errors.New("invalid role")
 	}
 
@@ -5,18 +5,31 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"net"
+	"strings"
 	"time"
 
-	"homework-manager/internal/database"
 	"homework-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAPIKey      = errors.New("invalid API key")
+	ErrAPIKeyExpired      = errors.New("API key expired")
+	ErrAPIKeyIPNotAllowed = errors.New("IP address not allowed for this API key")
 )
 
-type APIKeyService struct{}
+// maxAPIKeyAllowedIPs は IP 許可リストに登録できるエントリ数の上限。
+const maxAPIKeyAllowedIPs = 20
 
-func NewAPIKeyService() *APIKeyService {
-	return &APIKeyService{}
+type APIKeyService struct {
+	db *gorm.DB
 }
 
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
 
 func (s *APIKeyService) generateRandomKey() (string, error) {
 	bytes := make([]byte, 32)
@@ -31,10 +44,76 @@ func (s *APIKeyService) hashKey(key string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-func (s *APIKeyService) CreateAPIKey(userID uint, name string) (string, *models.APIKey, error) {
+// normalizeScopes は選択されたスコープを定義順に並べ、重複と未知のスコープを除く。
+func normalizeScopes(scopes []string) ([]string, error) {
+	selected := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		selected[strings.TrimSpace(scope)] = true
+	}
+
+	var result []string
+	for _, scope := range models.APIKeyScopes {
+		if selected[scope] {
+			result = append(result, scope)
+			delete(selected, scope)
+		}
+	}
+	delete(selected, "")
+	if len(selected) > 0 {
+		return nil, errors.New("不正なスコープが指定されています")
+	}
+	if len(result) == 0 {
+		return nil, errors.New("スコープを1つ以上選択してください")
+	}
+	return result, nil
+}
+
+// ParseAllowedIPs はカンマ・空白・改行区切りの IP アドレス / CIDR を検証し、保存用のカンマ区切りに整形する。
+func ParseAllowedIPs(value string) (string, error) {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+	if len(fields) > maxAPIKeyAllowedIPs {
+		return "", errors.New("IP許可リストは20件までです")
+	}
+
+	entries := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if _, network, err := net.ParseCIDR(field); err == nil {
+			entries = append(entries, network.String())
+			continue
+		}
+		ip := net.ParseIP(field)
+		if ip == nil {
+			return "", errors.New("IP許可リストの形式が正しくありません: " + field)
+		}
+		entries = append(entries, ip.String())
+	}
+	return strings.Join(entries, ","), nil
+}
+
+// CreateAPIKey は APIキーを発行し、平文のキーを返す。平文は保存しないため、この時だけ表示できる。
+// expiresAt が nil の場合は無期限、allowedIPs が空の場合は接続元を制限しない。
+func (s *APIKeyService) CreateAPIKey(userID uint, name string, scopes []string, expiresAt *time.Time, allowedIPs string) (string, *models.APIKey, error) {
+	name = strings.TrimSpace(name)
 	if name == "" {
 		return "", nil, errors.New("キー名を入力してください")
 	}
+	if len([]rune(name)) > 100 {
+		return "", nil, errors.New("キー名は100文字以内で入力してください")
+	}
+
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", nil, errors.New("有効期限には未来の日付を指定してください")
+	}
+	allowedIPs, err = ParseAllowedIPs(allowedIPs)
+	if err != nil {
+		return "", nil, err
+	}
 
 	plainKey, err := s.generateRandomKey()
 	if err != nil {
@@ -42,46 +121,86 @@ func (s *APIKeyService) CreateAPIKey(userID uint, name string) (string, *models.
 	}
 
 	apiKey := &models.APIKey{
-		UserID:  userID,
-		Name:    name,
-		KeyHash: s.hashKey(plainKey),
+		UserID:     userID,
+		Name:       name,
+		KeyHash:    s.hashKey(plainKey),
+		Scopes:     strings.Join(scopes, " "),
+		AllowedIPs: allowedIPs,
+		ExpiresAt:  utcPtr(expiresAt),
 	}
 
-	if err := database.GetDB().Create(apiKey).Error; err != nil {
+	if err := s.db.Create(apiKey).Error; err != nil {
 		return "", nil, errors.New("キーの保存に失敗しました")
 	}
 
 	return plainKey, apiKey, nil
 }
 
-func (s *APIKeyService) ValidateAPIKey(plainKey string) (uint, error) {
+// ValidateAPIKey はキーを検証して APIキーを返す。期限切れ、または clientIP が許可リストにない場合はエラー。
+func (s *APIKeyService) ValidateAPIKey(plainKey, clientIP string) (*models.APIKey, error) {
 	hash := s.hashKey(plainKey)
 
 	var apiKey models.APIKey
-	if err := database.GetDB().Where("key_hash = ?", hash).First(&apiKey).Error; err != nil {
-		return 0, errors.New("無効なAPIキーです")
+	if err := s.db.Where("key_hash = ?", hash).First(&apiKey).Error; err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if apiKey.IsExpired() {
+		return nil, ErrAPIKeyExpired
+	}
+	if !apiKey.AllowsIP(clientIP) {
+		return nil, ErrAPIKeyIPNotAllowed
 	}
 
-	now := time.Now()
-	database.GetDB().Model(&apiKey).Update("last_used", now)
+	now := time.Now().UTC()
+	s.db.Model(&apiKey).Updates(map[string]interface{}{"last_used": now, "last_used_ip": clientIP})
 
-	return apiKey.UserID, nil
+	return &apiKey, nil
 }
 
 func (s *APIKeyService) GetAllAPIKeys() ([]models.APIKey, error) {
 	var keys []models.APIKey
-	err := database.GetDB().Preload("User").Order("created_at desc").Find(&keys).Error
+	err := s.db.Preload("User").Order("created_at desc").Find(&keys).Error
 	return keys, err
 }
 
 func (s *APIKeyService) GetAPIKeysByUser(userID uint) ([]models.APIKey, error) {
 	var keys []models.APIKey
-	err := database.GetDB().Where("user_id = ?", userID).Order("created_at desc").Find(&keys).Error
+	err := s.db.Where("user_id = ?", userID).Order("created_at desc").Find(&keys).Error
 	return keys, err
 }
 
+// DeleteUserAPIKey はユーザー自身の APIキーを削除する。
+func (s *APIKeyService) DeleteUserAPIKey(userID, id uint) error {
+	result := s.db.Where("user_id = ?", userID).Delete(&models.APIKey{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("APIキーが見つかりません")
+	}
+	return nil
+}
+
+// GetAPIKeyScopeLabel はスコープの表示名を返す。
+func GetAPIKeyScopeLabel(scope string) string {
+	switch scope {
+	case models.ScopeAssignmentsRead:
+		return "課題の閲覧"
+	case models.ScopeAssignmentsWrite:
+		return "課題の作成・更新・削除"
+	case models.ScopeRecurringRead:
+		return "繰り返し設定の閲覧"
+	case models.ScopeRecurringWrite:
+		return "繰り返し設定の更新・削除"
+	case models.ScopeStatisticsRead:
+		return "統計の閲覧"
+	default:
+		return scope
+	}
+}
+
 func (s *APIKeyService) DeleteAPIKey(id uint) error {
-	result := database.GetDB().Delete(&models.APIKey{}, id)
+	result := s.db.Delete(&models.APIKey{}, id)
 	if result.RowsAffected == 0 {
 		return errors.New("APIキーが見つかりません")
 	}
@@ -6,6 +6,8 @@ import (
 
 	"homework-manager/internal/models"
 	"homework-manager/internal/repository"
+
+	"gorm.io/gorm"
 )
 
 var (
@@ -23,28 +25,73 @@ type PaginatedResult struct {
 
 type AssignmentService struct {
 	assignmentRepo *repository.AssignmentRepository
+	subjectRepo    *repository.SubjectRepository
+	tagRepo        *repository.TagRepository
+	userRepo       *repository.UserRepository
+	webhookService *WebhookService
 }
 
-func NewAssignmentService() *AssignmentService {
+func NewAssignmentService(db *gorm.DB) *AssignmentService {
 	return &AssignmentService{
-		assignmentRepo: repository.NewAssignmentRepository(),
+		assignmentRepo: repository.NewAssignmentRepository(db),
+		subjectRepo:    repository.NewSubjectRepository(db),
+		tagRepo:        repository.NewTagRepository(db),
+		userRepo:       repository.NewUserRepository(db),
+		webhookService: NewWebhookService(db),
+	}
+}
+
+// userLocation はユーザーのタイムゾーンを返す。ユーザーが見つからなければサーバーのタイムゾーン。
+func userLocation(userRepo *repository.UserRepository, userID uint) *time.Location {
+	user, err := userRepo.FindByID(userID)
+	if err != nil {
+		return time.Local
 	}
+	return user.Location()
 }
 
+// utcPtr は t を UTC にしたコピーを返す。日時は UTC で保存するため、保存する前に通す。
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// inLocation は t を loc に変換したコピーを返す。表示・書き出し用で、t が nil の場合は nil。
+func inLocation(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	local := t.In(loc)
+	return &local
+}
+
+// Create は課題を作成する。subject は科目名で、まだない科目は作成する。priority が空の場合は科目の既定の重要度にする。
 func (s *AssignmentService) Create(userID uint, title, description, subject, priority string, dueDate time.Time, reminderEnabled bool, reminderAt *time.Time, urgentReminderEnabled bool) (*models.Assignment, error) {
+	subjectModel, err := resolveSubject(s.subjectRepo, userID, subject)
+	if err != nil {
+		return nil, err
+	}
+	if priority == "" && subjectModel != nil {
+		priority = subjectModel.DefaultPriority
+	}
 	if priority == "" {
 		priority = "medium"
 	}
+	subjectID, subjectName := subjectRef(subjectModel)
 	assignment := &models.Assignment{
 		UserID:                userID,
 		Title:                 title,
 		Description:           description,
-		Subject:               subject,
+		SubjectID:             subjectID,
+		Subject:               subjectName,
 		Priority:              priority,
-		DueDate:               dueDate,
+		DueDate:               dueDate.UTC(),
 		IsCompleted:           false,
 		ReminderEnabled:       reminderEnabled,
-		ReminderAt:            reminderAt,
+		ReminderAt:            utcPtr(reminderAt),
 		ReminderSent:          false,
 		UrgentReminderEnabled: urgentReminderEnabled,
 	}
@@ -53,6 +100,7 @@ func (s *AssignmentService) Create(userID uint, title, description, subject, pri
 		return nil, err
 	}
 
+	s.webhookService.Dispatch(userID, models.WebhookEventAssignmentCreated, assignment)
 	return assignment, nil
 }
 
@@ -134,11 +182,11 @@ func (s *AssignmentService) GetCompletedByUserPaginated(userID uint, page, pageS
 }
 
 func (s *AssignmentService) GetDueTodayByUser(userID uint) ([]models.Assignment, error) {
-	return s.assignmentRepo.FindDueTodayByUserID(userID)
+	return s.assignmentRepo.FindDueTodayByUserID(userID, userLocation(s.userRepo, userID))
 }
 
 func (s *AssignmentService) GetDueThisWeekByUser(userID uint) ([]models.Assignment, error) {
-	return s.assignmentRepo.FindDueThisWeekByUserID(userID)
+	return s.assignmentRepo.FindDueThisWeekByUserID(userID, userLocation(s.userRepo, userID))
 }
 
 func (s *AssignmentService) GetOverdueByUser(userID uint) ([]models.Assignment, error) {
@@ -171,7 +219,8 @@ func (s *AssignmentService) GetOverdueByUserPaginated(userID uint, page, pageSiz
 	}, nil
 }
 
-func (s *AssignmentService) SearchAssignments(userID uint, query, priority, filter string, page, pageSize int) (*PaginatedResult, error) {
+// SearchAssignments は課題を検索する。filter は pending・completed・overdue・due_today・due_this_week・recurring・all のいずれか。
+func (s *AssignmentService) SearchAssignments(userID uint, query, priority, filter string, tags repository.TagFilter, page, pageSize int) (*PaginatedResult, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -179,7 +228,7 @@ func (s *AssignmentService) SearchAssignments(userID uint, query, priority, filt
 		pageSize = 10
 	}
 
-	assignments, totalCount, err := s.assignmentRepo.SearchWithPreload(userID, query, priority, filter, page, pageSize)
+	assignments, totalCount, err := s.assignmentRepo.SearchWithPreload(userID, query, priority, filter, tags, page, pageSize, userLocation(s.userRepo, userID))
 	if err != nil {
 		return nil, err
 	}
@@ -201,13 +250,21 @@ func (s *AssignmentService) Update(userID, assignmentID uint, title, description
 		return nil, err
 	}
 
+	subjectModel, err := resolveSubject(s.subjectRepo, userID, subject)
+	if err != nil {
+		return nil, err
+	}
+
 	assignment.Title = title
 	assignment.Description = description
-	assignment.Subject = subject
+	assignment.SubjectID, assignment.Subject = subjectRef(subjectModel)
 	assignment.Priority = priority
-	assignment.DueDate = dueDate
+	if !assignment.DueDate.Equal(dueDate) {
+		assignment.OverdueNotifiedAt = nil
+	}
+	assignment.DueDate = dueDate.UTC()
 	assignment.ReminderEnabled = reminderEnabled
-	assignment.ReminderAt = reminderAt
+	assignment.ReminderAt = utcPtr(reminderAt)
 	assignment.UrgentReminderEnabled = urgentReminderEnabled
 	if reminderEnabled && reminderAt != nil {
 		assignment.ReminderSent = false
@@ -217,6 +274,7 @@ func (s *AssignmentService) Update(userID, assignmentID uint, title, description
 		return nil, err
 	}
 
+	s.webhookService.Dispatch(userID, models.WebhookEventAssignmentUpdated, assignment)
 	return assignment, nil
 }
 
@@ -228,7 +286,7 @@ func (s *AssignmentService) ToggleComplete(userID, assignmentID uint) (*models.A
 
 	assignment.IsCompleted = !assignment.IsCompleted
 	if assignment.IsCompleted {
-		now := time.Now()
+		now := time.Now().UTC()
 		assignment.CompletedAt = &now
 	} else {
 		assignment.CompletedAt = nil
@@ -238,6 +296,32 @@ func (s *AssignmentService) ToggleComplete(userID, assignmentID uint) (*models.A
 		return nil, err
 	}
 
+	event := models.WebhookEventAssignmentUncompleted
+	if assignment.IsCompleted {
+		event = models.WebhookEventAssignmentCompleted
+	}
+	s.webhookService.Dispatch(userID, event, assignment)
+	return assignment, nil
+}
+
+// Snooze は until まで督促通知を止め、until にリマインダーを送るよう設定する。
+func (s *AssignmentService) Snooze(userID, assignmentID uint, until time.Time) (*models.Assignment, error) {
+	assignment, err := s.GetByID(userID, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	until = until.UTC()
+	assignment.SnoozedUntil = &until
+	assignment.ReminderEnabled = true
+	assignment.ReminderAt = &until
+	assignment.ReminderSent = false
+
+	if err := s.assignmentRepo.Update(assignment); err != nil {
+		return nil, err
+	}
+
+	s.webhookService.Dispatch(userID, models.WebhookEventAssignmentUpdated, assignment)
 	return assignment, nil
 }
 
@@ -247,11 +331,12 @@ func (s *AssignmentService) Delete(userID, assignmentID uint) error {
 		return err
 	}
 
-	return s.assignmentRepo.Delete(assignment.ID)
-}
+	if err := s.assignmentRepo.Delete(assignment.ID); err != nil {
+		return err
+	}
 
-func (s *AssignmentService) GetSubjectsByUser(userID uint) ([]string, error) {
-	return s.assignmentRepo.GetSubjectsByUserID(userID)
+	s.webhookService.Dispatch(userID, models.WebhookEventAssignmentDeleted, assignment)
+	return nil
 }
 
 type DashboardStats struct {
@@ -263,30 +348,37 @@ type DashboardStats struct {
 }
 
 func (s *AssignmentService) GetDashboardStats(userID uint) (*DashboardStats, error) {
+	loc := userLocation(s.userRepo, userID)
 	pending, _ := s.assignmentRepo.CountPendingByUserID(userID)
-	dueToday, _ := s.assignmentRepo.FindDueTodayByUserID(userID)
-	dueThisWeek, _ := s.assignmentRepo.FindDueThisWeekByUserID(userID)
+	dueToday, _ := s.assignmentRepo.FindDueTodayByUserID(userID, loc)
+	dueThisWeek, _ := s.assignmentRepo.FindDueThisWeekByUserID(userID, loc)
 	overdueCount, _ := s.assignmentRepo.CountOverdueByUserID(userID)
-	subjects, _ := s.assignmentRepo.GetSubjectsByUserID(userID)
+	subjects, _ := s.subjectRepo.FindByUserID(userID, false)
 
-	return &DashboardStats{
+	stats := &DashboardStats{
 		TotalPending: pending,
 		DueToday:     len(dueToday),
 		DueThisWeek:  len(dueThisWeek),
 		Overdue:      int(overdueCount),
-		Subjects:     subjects,
-	}, nil
+	}
+	for _, subject := range subjects {
+		stats.Subjects = append(stats.Subjects, subject.Name)
+	}
+	return stats, nil
 }
 
 type StatisticsFilter struct {
 	Subject         string
+	Tag             string
 	From            *time.Time
 	To              *time.Time
 	IncludeArchived bool
 }
 
 type SubjectStats struct {
+	SubjectID            uint    `json:"subject_id"`
 	Subject              string  `json:"subject"`
+	Color                string  `json:"color"`
 	Total                int64   `json:"total"`
 	Completed            int64   `json:"completed"`
 	Pending              int64   `json:"pending"`
@@ -295,6 +387,16 @@ type SubjectStats struct {
 	IsArchived           bool    `json:"is_archived,omitempty"`
 }
 
+type TagStats struct {
+	TagID                uint    `json:"tag_id"`
+	Tag                  string  `json:"tag"`
+	Total                int64   `json:"total"`
+	Completed            int64   `json:"completed"`
+	Pending              int64   `json:"pending"`
+	Overdue              int64   `json:"overdue"`
+	OnTimeCompletionRate float64 `json:"on_time_completion_rate"`
+}
+
 type StatisticsSummary struct {
 	TotalAssignments     int64          `json:"total_assignments"`
 	CompletedAssignments int64          `json:"completed_assignments"`
@@ -303,10 +405,12 @@ type StatisticsSummary struct {
 	OnTimeCompletionRate float64        `json:"on_time_completion_rate"`
 	Filter               *FilterInfo    `json:"filter,omitempty"`
 	Subjects             []SubjectStats `json:"subjects,omitempty"`
+	Tags                 []TagStats     `json:"tags,omitempty"`
 }
 
 type FilterInfo struct {
 	Subject         *string `json:"subject"`
+	Tag             *string `json:"tag,omitempty"`
 	From            *string `json:"from"`
 	To              *string `json:"to"`
 	IncludeArchived bool    `json:"include_archived"`
@@ -314,11 +418,26 @@ type FilterInfo struct {
 
 func (s *AssignmentService) GetStatistics(userID uint, filter StatisticsFilter) (*StatisticsSummary, error) {
 	repoFilter := repository.StatisticsFilter{
-		Subject:         filter.Subject,
 		From:            filter.From,
 		To:              filter.To,
 		IncludeArchived: filter.IncludeArchived,
 	}
+	if filter.Subject != "" {
+		// 存在しない科目の場合は 0 件の統計を返す
+		var subjectID uint
+		if subject, err := s.subjectRepo.FindByName(userID, filter.Subject); err == nil {
+			subjectID = subject.ID
+		}
+		repoFilter.SubjectID = &subjectID
+	}
+	if filter.Tag != "" {
+		// 存在しないタグの場合は 0 件の統計を返す
+		var tagID uint
+		if tag, err := s.tagRepo.FindByName(userID, filter.Tag); err == nil {
+			tagID = tag.ID
+		}
+		repoFilter.TagID = &tagID
+	}
 
 	stats, err := s.assignmentRepo.GetStatistics(userID, repoFilter)
 	if err != nil {
@@ -339,6 +458,10 @@ func (s *AssignmentService) GetStatistics(userID uint, filter StatisticsFilter)
 		filterInfo.Subject = &filter.Subject
 		hasFilter = true
 	}
+	if filter.Tag != "" {
+		filterInfo.Tag = &filter.Tag
+		hasFilter = true
+	}
 	if filter.From != nil {
 		fromStr := filter.From.Format("2006-01-02")
 		filterInfo.From = &fromStr
@@ -365,7 +488,10 @@ func (s *AssignmentService) GetStatistics(userID uint, filter StatisticsFilter)
 
 		for _, ss := range subjectStats {
 			summary.Subjects = append(summary.Subjects, SubjectStats{
-				Subject:              ss.Subject,
+				SubjectID:            ss.Subject.ID,
+				Subject:              ss.Subject.Name,
+				Color:                ss.Subject.Color,
+				IsArchived:           ss.Subject.IsArchived,
 				Total:                ss.Total,
 				Completed:            ss.Completed,
 				Pending:              ss.Pending,
@@ -375,20 +501,24 @@ func (s *AssignmentService) GetStatistics(userID uint, filter StatisticsFilter)
 		}
 	}
 
-	return summary, nil
-}
-
-func (s *AssignmentService) ArchiveSubject(userID uint, subject string) error {
-	return s.assignmentRepo.ArchiveBySubject(userID, subject)
-}
-func (s *AssignmentService) UnarchiveSubject(userID uint, subject string) error {
-	return s.assignmentRepo.UnarchiveBySubject(userID, subject)
-}
+	if filter.Tag == "" {
+		tagStats, err := s.assignmentRepo.GetStatisticsByTags(userID, repoFilter)
+		if err != nil {
+			return nil, err
+		}
 
-func (s *AssignmentService) GetSubjectsWithArchived(userID uint, includeArchived bool) ([]string, error) {
-	return s.assignmentRepo.GetSubjectsByUserIDWithArchived(userID, includeArchived)
-}
+		for _, ts := range tagStats {
+			summary.Tags = append(summary.Tags, TagStats{
+				TagID:                ts.Tag.ID,
+				Tag:                  ts.Tag.Name,
+				Total:                ts.Total,
+				Completed:            ts.Completed,
+				Pending:              ts.Pending,
+				Overdue:              ts.Overdue,
+				OnTimeCompletionRate: ts.OnTimeCompletionRate,
+			})
+		}
+	}
 
-func (s *AssignmentService) GetArchivedSubjects(userID uint) ([]string, error) {
-	return s.assignmentRepo.GetArchivedSubjects(userID)
+	return summary, nil
 }
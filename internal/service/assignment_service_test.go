@@ -0,0 +1,168 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// createTestUser はテスト用のユーザーを作る。タイムゾーンは UTC にする。
+func createTestUser(t *testing.T, db *gorm.DB, email string) *models.User {
+	t.Helper()
+	user := &models.User{Email: email, PasswordHash: "x", Name: email, Role: "user", Timezone: "UTC"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// createTestAssignment はテスト用の課題を作る。Subject を指定した場合はその名前の科目を参照させる。
+func createTestAssignment(t *testing.T, db *gorm.DB, assignment *models.Assignment) *models.Assignment {
+	t.Helper()
+	if assignment.Title == "" {
+		assignment.Title = "課題"
+	}
+	if assignment.Subject != "" && assignment.SubjectID == nil {
+		subject, err := resolveSubject(repository.NewSubjectRepository(db), assignment.UserID, assignment.Subject)
+		if err != nil {
+			t.Fatalf("resolve subject: %v", err)
+		}
+		assignment.SubjectID, assignment.Subject = subjectRef(subject)
+	}
+	if err := db.Create(assignment).Error; err != nil {
+		t.Fatalf("create assignment: %v", err)
+	}
+	return assignment
+}
+
+func TestGetStatistics(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "stats@example.com")
+	other := createTestUser(t, db, "other@example.com")
+
+	now := time.Now().UTC()
+	day := 24 * time.Hour
+	recent := now.Add(-2 * day)
+	old := now.Add(-60 * day)
+	completedAt := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	for _, a := range []*models.Assignment{
+		// 数学: 期限内に完了・期限後に完了・期限切れ・未完了
+		{UserID: user.ID, Subject: "数学", DueDate: now.Add(day), IsCompleted: true, CompletedAt: completedAt(-day), CreatedAt: recent},
+		{UserID: user.ID, Subject: "数学", DueDate: now.Add(-2 * day), IsCompleted: true, CompletedAt: completedAt(-day), CreatedAt: recent},
+		{UserID: user.ID, Subject: "数学", DueDate: now.Add(-day), CreatedAt: old},
+		{UserID: user.ID, Subject: "数学", DueDate: now.Add(3 * day), CreatedAt: recent},
+		// 英語: 期限切れ・期限内に完了
+		{UserID: user.ID, Subject: "英語", DueDate: now.Add(-day), CreatedAt: recent},
+		{UserID: user.ID, Subject: "英語", DueDate: now.Add(day), IsCompleted: true, CompletedAt: completedAt(-day), CreatedAt: recent},
+		// 理科: 期限切れ（科目をアーカイブする）
+		{UserID: user.ID, Subject: "理科", DueDate: now.Add(-day), CreatedAt: recent},
+		// 他のユーザーの課題は数えない
+		{UserID: other.ID, Subject: "数学", DueDate: now.Add(-day), CreatedAt: recent},
+	} {
+		createTestAssignment(t, db, a)
+	}
+	subjects := NewSubjectService(db)
+	if _, err := subjects.SetArchived(user.ID, subjects.FindByName(user.ID, "理科").ID, true); err != nil {
+		t.Fatalf("SetArchived: %v", err)
+	}
+
+	from := now.Add(-7 * day)
+	tests := []struct {
+		name         string
+		filter       StatisticsFilter
+		total        int64
+		completed    int64
+		pending      int64
+		overdue      int64
+		onTimeRate   float64
+		subjects     map[string]int64 // 科目ごとの総数。nil の場合は科目別の統計がないこと
+		subjectsOver map[string]int64 // 科目ごとの期限切れ数
+	}{
+		{
+			name:         "絞り込みなし",
+			total:        6,
+			completed:    3,
+			pending:      3,
+			overdue:      2,
+			onTimeRate:   200.0 / 3,
+			subjects:     map[string]int64{"数学": 4, "英語": 2},
+			subjectsOver: map[string]int64{"数学": 1, "英語": 1},
+		},
+		{
+			name:         "アーカイブを含む",
+			filter:       StatisticsFilter{IncludeArchived: true},
+			total:        7,
+			completed:    3,
+			pending:      4,
+			overdue:      3,
+			onTimeRate:   200.0 / 3,
+			subjects:     map[string]int64{"数学": 4, "英語": 2, "理科": 1},
+			subjectsOver: map[string]int64{"数学": 1, "英語": 1, "理科": 1},
+		},
+		{
+			name:       "科目で絞り込み",
+			filter:     StatisticsFilter{Subject: "数学"},
+			total:      4,
+			completed:  2,
+			pending:    2,
+			overdue:    1,
+			onTimeRate: 50,
+		},
+		{
+			name:         "登録日で絞り込み",
+			filter:       StatisticsFilter{From: &from},
+			total:        5,
+			completed:    3,
+			pending:      2,
+			overdue:      1,
+			onTimeRate:   200.0 / 3,
+			subjects:     map[string]int64{"数学": 3, "英語": 2},
+			subjectsOver: map[string]int64{"数学": 0, "英語": 1},
+		},
+	}
+
+	svc := NewAssignmentService(db)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := svc.GetStatistics(user.ID, tt.filter)
+			if err != nil {
+				t.Fatalf("GetStatistics: %v", err)
+			}
+			if summary.TotalAssignments != tt.total || summary.CompletedAssignments != tt.completed ||
+				summary.PendingAssignments != tt.pending || summary.OverdueAssignments != tt.overdue {
+				t.Errorf("total/completed/pending/overdue = %d/%d/%d/%d, want %d/%d/%d/%d",
+					summary.TotalAssignments, summary.CompletedAssignments, summary.PendingAssignments, summary.OverdueAssignments,
+					tt.total, tt.completed, tt.pending, tt.overdue)
+			}
+			if math.Abs(summary.OnTimeCompletionRate-tt.onTimeRate) > 0.01 {
+				t.Errorf("OnTimeCompletionRate = %v, want %v", summary.OnTimeCompletionRate, tt.onTimeRate)
+			}
+
+			if tt.subjects == nil {
+				if len(summary.Subjects) != 0 {
+					t.Errorf("Subjects = %+v, want none", summary.Subjects)
+				}
+				return
+			}
+			if len(summary.Subjects) != len(tt.subjects) {
+				t.Fatalf("Subjects = %+v, want %d subjects", summary.Subjects, len(tt.subjects))
+			}
+			for _, ss := range summary.Subjects {
+				if ss.Total != tt.subjects[ss.Subject] || ss.Overdue != tt.subjectsOver[ss.Subject] {
+					t.Errorf("subject %s total/overdue = %d/%d, want %d/%d",
+						ss.Subject, ss.Total, ss.Overdue, tt.subjects[ss.Subject], tt.subjectsOver[ss.Subject])
+				}
+			}
+		})
+	}
+}
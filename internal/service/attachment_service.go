@@ -0,0 +1,333 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAttachmentNotFound       = errors.New("attachment not found")
+	ErrAttachmentEmpty          = errors.New("attachment is empty")
+	ErrAttachmentTooLarge       = errors.New("attachment is too large")
+	ErrAttachmentQuotaExceeded  = errors.New("attachment quota exceeded")
+	ErrAttachmentTypeNotAllowed = errors.New("attachment type is not allowed")
+)
+
+const maxAttachmentFileNameLength = 200
+
+// attachmentPurgeBatchSize は PurgeDeletedAssignments で1回に削除する添付ファイルの数。
+const attachmentPurgeBatchSize = 100
+
+// attachmentTypes は添付できるファイルの種類。送られてきた Content-Type ではなく、内容から判定した MIME タイプで確認する。
+var attachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+	"application/zip": true,
+}
+
+// officeTypes は ZIP 形式の Office 文書の MIME タイプ。内容からは application/zip としか判定できないため拡張子で補う。
+var officeTypes = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// AttachmentUsage は添付ファイルの使用量（バイト）。Quota が 0 の場合は制限しない。
+type AttachmentUsage struct {
+	Used  int64 `json:"used"`
+	Quota int64 `json:"quota"`
+}
+
+func (u AttachmentUsage) Percent() int {
+	if u.Quota <= 0 {
+		return 0
+	}
+	percent := int(u.Used * 100 / u.Quota)
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
+
+type AttachmentService struct {
+	attachmentRepo    *repository.AttachmentRepository
+	assignmentService *AssignmentService
+	storage           storage.Storage
+	maxFileSize       int64
+	userQuota         int64
+}
+
+// NewAttachmentService は store に保存する AttachmentService を返す。上限は cfg の MB 単位の値を使う。
+func NewAttachmentService(db *gorm.DB, store storage.Storage, cfg config.StorageConfig) *AttachmentService {
+	return &AttachmentService{
+		attachmentRepo:    repository.NewAttachmentRepository(db),
+		assignmentService: NewAssignmentService(db),
+		storage:           store,
+		maxFileSize:       int64(cfg.MaxFileSize) << 20,
+		userQuota:         int64(cfg.UserQuota) << 20,
+	}
+}
+
+// MaxFileSize は 1 ファイルの上限（バイト）を返す。
+func (s *AttachmentService) MaxFileSize() int64 {
+	return s.maxFileSize
+}
+
+func (s *AttachmentService) List(userID, assignmentID uint) ([]models.Attachment, error) {
+	if _, err := s.assignmentService.GetByID(userID, assignmentID); err != nil {
+		return nil, err
+	}
+	return s.attachmentRepo.FindByAssignmentID(assignmentID)
+}
+
+// Counts は課題ごとの添付ファイルの数を返す。一覧画面で表示している課題を渡す。
+func (s *AttachmentService) Counts(assignments []models.Assignment) map[uint]int {
+	ids := make([]uint, 0, len(assignments))
+	for _, a := range assignments {
+		ids = append(ids, a.ID)
+	}
+	counts, err := s.attachmentRepo.CountByAssignmentIDs(ids)
+	if err != nil {
+		return map[uint]int{}
+	}
+	return counts
+}
+
+// Usage はユーザーの使用量を返す。削除した課題の添付ファイルも、PurgeDeletedAssignments で消すまでは含める。
+func (s *AttachmentService) Usage(userID uint) (AttachmentUsage, error) {
+	used, err := s.attachmentRepo.TotalSizeByUserID(userID)
+	if err != nil {
+		return AttachmentUsage{}, err
+	}
+	return AttachmentUsage{Used: used, Quota: s.userQuota}, nil
+}
+
+// Upload は r の内容を課題に添付する。種類は内容から判定し、サイズとユーザーごとの上限を確認してから保存する。
+func (s *AttachmentService) Upload(userID, assignmentID uint, fileName string, r io.Reader) (*models.Attachment, error) {
+	if _, err := s.assignmentService.GetByID(userID, assignmentID); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, s.maxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, ErrAttachmentEmpty
+	}
+	size := int64(len(data))
+	if size > s.maxFileSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	fileName = sanitizeFileName(fileName)
+	contentType, ok := detectAttachmentType(fileName, data)
+	if !ok {
+		return nil, ErrAttachmentTypeNotAllowed
+	}
+
+	if s.userQuota > 0 {
+		used, err := s.attachmentRepo.TotalSizeByUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if used+size > s.userQuota {
+			return nil, ErrAttachmentQuotaExceeded
+		}
+	}
+
+	key, err := generateAttachmentKey(userID, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storage.Put(key, bytes.NewReader(data), size, contentType); err != nil {
+		return nil, err
+	}
+
+	attachment := &models.Attachment{
+		UserID:       userID,
+		AssignmentID: assignmentID,
+		FileName:     fileName,
+		ContentType:  contentType,
+		Size:         size,
+		StorageKey:   key,
+	}
+	if err := s.attachmentRepo.Create(attachment); err != nil {
+		s.storage.Delete(key)
+		return nil, err
+	}
+	return attachment, nil
+}
+
+func (s *AttachmentService) get(userID, assignmentID, attachmentID uint) (*models.Attachment, error) {
+	if _, err := s.assignmentService.GetByID(userID, assignmentID); err != nil {
+		return nil, err
+	}
+	attachment, err := s.attachmentRepo.FindByID(attachmentID)
+	if err != nil || attachment.AssignmentID != assignmentID {
+		return nil, ErrAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+// Open は添付ファイルとその内容を返す。呼び出し側で内容を Close する。
+func (s *AttachmentService) Open(userID, assignmentID, attachmentID uint) (*models.Attachment, io.ReadCloser, error) {
+	attachment, err := s.get(userID, assignmentID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := s.storage.Get(attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, content, nil
+}
+
+func (s *AttachmentService) Delete(userID, assignmentID, attachmentID uint) error {
+	attachment, err := s.get(userID, assignmentID, attachmentID)
+	if err != nil {
+		return err
+	}
+	// 保存先から消せなかった場合はレコードを残し、もう一度削除できるようにする
+	if err := s.storage.Delete(attachment.StorageKey); err != nil {
+		return err
+	}
+	return s.attachmentRepo.Delete(attachment.ID)
+}
+
+// DeleteByUser はユーザーの課題を完全に削除する前に、すべての添付ファイルを保存先から削除する。
+// 保存先から消せなかったファイルはログに残し、ユーザーの削除は止めない。レコードはユーザーと一緒に削除される。
+func (s *AttachmentService) DeleteByUser(userID uint) error {
+	attachments, err := s.attachmentRepo.FindByUserID(userID)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		if err := s.storage.Delete(attachment.StorageKey); err != nil {
+			log.Printf("Failed to delete attachment %s of user %d: %v", attachment.StorageKey, userID, err)
+		}
+	}
+	return nil
+}
+
+// PurgeDeletedAssignments は削除された課題の添付ファイルを保存先とデータベースから削除する。
+// 課題はどの経路（課題・繰り返し設定・クラスの削除）でも論理削除されるため、ここでまとめて消す。
+// 保存先から消せなかったファイルはレコードを残し、次の実行で再び削除する。
+func (s *AttachmentService) PurgeDeletedAssignments() {
+	attachments, err := s.attachmentRepo.FindOfDeletedAssignments(attachmentPurgeBatchSize)
+	if err != nil {
+		log.Printf("Error fetching attachments of deleted assignments: %v", err)
+		return
+	}
+	for _, attachment := range attachments {
+		if err := s.storage.Delete(attachment.StorageKey); err != nil {
+			log.Printf("Failed to delete attachment %s of deleted assignment %d: %v", attachment.StorageKey, attachment.AssignmentID, err)
+			continue
+		}
+		if err := s.attachmentRepo.Delete(attachment.ID); err != nil {
+			log.Printf("Error deleting attachment %d: %v", attachment.ID, err)
+		}
+	}
+}
+
+// StartPurgeScheduler は削除された課題の添付ファイルを定期的に削除する。
+func (s *AttachmentService) StartPurgeScheduler(interval time.Duration) {
+	go func() {
+		s.PurgeDeletedAssignments()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.PurgeDeletedAssignments()
+		}
+	}()
+	log.Printf("Attachment purge scheduler started (interval: %s)", interval)
+}
+
+// detectAttachmentType は内容から MIME タイプを判定し、添付できる種類かを返す。
+func detectAttachmentType(fileName string, data []byte) (string, bool) {
+	contentType := http.DetectContentType(data)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !attachmentTypes[mediaType] {
+		return "", false
+	}
+	if mediaType == "application/zip" {
+		if officeType, ok := officeTypes[strings.ToLower(filepath.Ext(fileName))]; ok {
+			return officeType, true
+		}
+	}
+	return contentType, true
+}
+
+// sanitizeFileName はパスと制御文字を取り除いたファイル名を返す。
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	if runes := []rune(name); len(runes) > maxAttachmentFileNameLength {
+		ext := []rune(filepath.Ext(name))
+		if len(ext) > 10 {
+			ext = nil
+		}
+		name = string(runes[:maxAttachmentFileNameLength-len(ext)]) + string(ext)
+	}
+	return name
+}
+
+func generateAttachmentKey(userID, assignmentID uint) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%d/%s", userID, assignmentID, hex.EncodeToString(random)), nil
+}
+
+// FormatFileSize はバイト数を B・KB・MB の表示にする。
+func FormatFileSize(size int64) string {
+	format := func(value float64, unit string) string {
+		return strings.TrimSuffix(strconv.FormatFloat(value, 'f', 1, 64), ".0") + " " + unit
+	}
+	switch {
+	case size >= 1<<20:
+		return format(float64(size)/(1<<20), "MB")
+	case size >= 1<<10:
+		return format(float64(size)/(1<<10), "KB")
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
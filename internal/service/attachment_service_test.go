@@ -0,0 +1,237 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/storage"
+	"homework-manager/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+// fileOf は header で始まる size バイトの内容を返す。
+func fileOf(header []byte, size int) []byte {
+	return append(append([]byte{}, header...), bytes.Repeat([]byte{0}, size-len(header))...)
+}
+
+func newTestAttachmentService(t *testing.T, db *gorm.DB) (*AttachmentService, storage.Storage) {
+	t.Helper()
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	return NewAttachmentService(db, store, config.StorageConfig{MaxFileSize: 1, UserQuota: 2}), store
+}
+
+func TestAttachmentUpload(t *testing.T) {
+	tests := []struct {
+		name            string
+		fileName        string
+		content         []byte
+		wantErr         error
+		wantContentType string
+	}{
+		{"画像", "whiteboard.png", fileOf(pngHeader, 1000), nil, "image/png"},
+		{"PDF", "worksheet.pdf", []byte("%PDF-1.4\n%test"), nil, "application/pdf"},
+		{"テキスト", "memo.txt", []byte("第5章の問題"), nil, "text/plain; charset=utf-8"},
+		{"Office 文書は拡張子で補う", "report.docx", fileOf([]byte("PK\x03\x04"), 100), nil,
+			"application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"拡張子ではなく内容で判定", "page.png", []byte("<html><script>alert(1)</script></html>"), ErrAttachmentTypeNotAllowed, ""},
+		{"空のファイル", "empty.txt", nil, ErrAttachmentEmpty, ""},
+		{"上限を超える", "large.png", fileOf(pngHeader, 1<<20+1), ErrAttachmentTooLarge, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			user := createTestUser(t, db, "attachment@example.com")
+			assignment := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: time.Now()})
+			svc, store := newTestAttachmentService(t, db)
+
+			attachment, err := svc.Upload(user.ID, assignment.ID, tt.fileName, bytes.NewReader(tt.content))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Upload: err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if attachment.ContentType != tt.wantContentType || attachment.Size != int64(len(tt.content)) {
+				t.Errorf("attachment = %+v, want %s of %d bytes", attachment, tt.wantContentType, len(tt.content))
+			}
+
+			r, err := store.Get(attachment.StorageKey)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			defer r.Close()
+			if data, _ := io.ReadAll(r); !bytes.Equal(data, tt.content) {
+				t.Error("stored content differs from the uploaded file")
+			}
+		})
+	}
+}
+
+func TestAttachmentQuota(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "quota@example.com")
+	assignment := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: time.Now()})
+	deleted := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: time.Now()})
+	svc, _ := newTestAttachmentService(t, db)
+
+	file := fileOf(pngHeader, 900<<10)
+	if _, err := svc.Upload(user.ID, deleted.ID, "a.png", bytes.NewReader(file)); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if _, err := svc.Upload(user.ID, assignment.ID, "b.png", bytes.NewReader(file)); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if _, err := svc.Upload(user.ID, assignment.ID, "c.png", bytes.NewReader(file)); !errors.Is(err, ErrAttachmentQuotaExceeded) {
+		t.Fatalf("Upload over quota: err = %v, want %v", err, ErrAttachmentQuotaExceeded)
+	}
+
+	// 課題を削除しただけでは保存先のファイルは残るため、使用量は減らない
+	if err := NewAssignmentService(db).Delete(user.ID, deleted.ID); err != nil {
+		t.Fatalf("Delete assignment: %v", err)
+	}
+	usage, _ := svc.Usage(user.ID)
+	if usage.Used != 2*int64(len(file)) || usage.Quota != 2<<20 {
+		t.Errorf("usage = %+v, want %d of %d bytes", usage, 2*len(file), 2<<20)
+	}
+	if _, err := svc.Upload(user.ID, assignment.ID, "c.png", bytes.NewReader(file)); !errors.Is(err, ErrAttachmentQuotaExceeded) {
+		t.Fatalf("Upload after deleting an assignment: err = %v, want %v", err, ErrAttachmentQuotaExceeded)
+	}
+
+	// 削除した課題の添付ファイルを保存先から消すと空く
+	svc.PurgeDeletedAssignments()
+	usage, _ = svc.Usage(user.ID)
+	if usage.Used != int64(len(file)) {
+		t.Errorf("usage after purge = %+v, want %d bytes", usage, len(file))
+	}
+	if _, err := svc.Upload(user.ID, assignment.ID, "c.png", bytes.NewReader(file)); err != nil {
+		t.Errorf("Upload after purge: %v", err)
+	}
+}
+
+func TestPurgeDeletedAssignmentAttachments(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "purge@example.com")
+	kept := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: time.Now()})
+	deleted := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: time.Now()})
+	svc, store := newTestAttachmentService(t, db)
+
+	keep, err := svc.Upload(user.ID, kept.ID, "keep.txt", bytes.NewReader([]byte("keep")))
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	purge, err := svc.Upload(user.ID, deleted.ID, "purge.txt", bytes.NewReader([]byte("purge")))
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if err := NewAssignmentService(db).Delete(user.ID, deleted.ID); err != nil {
+		t.Fatalf("Delete assignment: %v", err)
+	}
+
+	svc.PurgeDeletedAssignments()
+
+	if _, err := store.Get(purge.StorageKey); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Get(%s) after purge: err = %v, want %v", purge.StorageKey, err, storage.ErrNotFound)
+	}
+	if err := db.First(&models.Attachment{}, purge.ID).Error; err == nil {
+		t.Error("attachment record of the deleted assignment is left")
+	}
+	if r, err := store.Get(keep.StorageKey); err != nil {
+		t.Errorf("Get(%s) of a live assignment: %v", keep.StorageKey, err)
+	} else {
+		r.Close()
+	}
+	if err := db.First(&models.Attachment{}, keep.ID).Error; err != nil {
+		t.Errorf("attachment record of a live assignment: %v", err)
+	}
+}
+
+func TestAttachmentOwnership(t *testing.T) {
+	db := testutil.OpenDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	assignment := createTestAssignment(t, db, &models.Assignment{UserID: owner.ID, DueDate: time.Now()})
+	otherAssignment := createTestAssignment(t, db, &models.Assignment{UserID: other.ID, DueDate: time.Now()})
+	svc, _ := newTestAttachmentService(t, db)
+
+	attachment, err := svc.Upload(owner.ID, assignment.ID, "memo.txt", bytes.NewReader([]byte("memo")))
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	if _, err := svc.Upload(other.ID, assignment.ID, "x.txt", bytes.NewReader([]byte("x"))); err != ErrUnauthorized {
+		t.Errorf("Upload to other user's assignment: err = %v, want %v", err, ErrUnauthorized)
+	}
+	if _, _, err := svc.Open(other.ID, assignment.ID, attachment.ID); err != ErrUnauthorized {
+		t.Errorf("Open other user's attachment: err = %v, want %v", err, ErrUnauthorized)
+	}
+	if _, _, err := svc.Open(other.ID, otherAssignment.ID, attachment.ID); err != ErrAttachmentNotFound {
+		t.Errorf("Open through other assignment: err = %v, want %v", err, ErrAttachmentNotFound)
+	}
+	if err := svc.Delete(other.ID, otherAssignment.ID, attachment.ID); err != ErrAttachmentNotFound {
+		t.Errorf("Delete through other assignment: err = %v, want %v", err, ErrAttachmentNotFound)
+	}
+}
+
+func TestDeleteUserRemovesAttachments(t *testing.T) {
+	db := testutil.OpenDB(t)
+	admin := createTestUser(t, db, "admin@example.com")
+	user := createTestUser(t, db, "student@example.com")
+	assignment := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: time.Now()})
+	svc, store := newTestAttachmentService(t, db)
+
+	var keys []string
+	for _, name := range []string{"a.txt", "b.txt"} {
+		attachment, err := svc.Upload(user.ID, assignment.ID, name, bytes.NewReader([]byte(name)))
+		if err != nil {
+			t.Fatalf("Upload: %v", err)
+		}
+		keys = append(keys, attachment.StorageKey)
+	}
+	// 課題を削除（論理削除）しても添付ファイルはユーザーの削除まで残る
+	NewAssignmentService(db).Delete(user.ID, assignment.ID)
+
+	if err := NewAdminService(db, svc).DeleteUser(admin.ID, user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	for _, key := range keys {
+		if _, err := store.Get(key); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("Get(%s) after deleting the user: err = %v, want %v", key, err, storage.ErrNotFound)
+		}
+	}
+	var count int64
+	db.Model(&models.Attachment{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("%d attachment record(s) left", count)
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"worksheet.pdf", "worksheet.pdf"},
+		{`C:\Users\student\板書.jpg`, "板書.jpg"},
+		{"../../etc/passwd", "passwd"},
+		{"re\x00port\n.txt", "report.txt"},
+		{"  ", "file"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeFileName(tt.name); got != tt.want {
+			t.Errorf("sanitizeFileName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// auditExportLimit はエクスポートする監査ログの最大件数。
+const auditExportLimit = 10000
+
+// AuditActor は操作した人と接続元。UserID が nil の場合は Email（ログイン試行で入力されたメールアドレスなど）だけを記録する。
+type AuditActor struct {
+	UserID    *uint
+	Email     string
+	IP        string
+	UserAgent string
+}
+
+// AuditTarget は操作の対象。
+type AuditTarget struct {
+	Type  string
+	ID    string
+	Label string
+}
+
+// UserAuditTarget はユーザーを対象とする AuditTarget を返す。
+func UserAuditTarget(user *models.User) AuditTarget {
+	return AuditTarget{Type: models.AuditTargetUser, ID: strconv.FormatUint(uint64(user.ID), 10), Label: user.Email}
+}
+
+// SubjectAuditTarget は科目を対象とする AuditTarget を返す。
+func SubjectAuditTarget(subject *models.Subject) AuditTarget {
+	return AuditTarget{Type: models.AuditTargetSubject, ID: strconv.FormatUint(uint64(subject.ID), 10), Label: subject.Name}
+}
+
+type AuditService struct {
+	auditRepo *repository.AuditEventRepository
+	userRepo  *repository.UserRepository
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{
+		auditRepo: repository.NewAuditEventRepository(db),
+		userRepo:  repository.NewUserRepository(db),
+	}
+}
+
+// Record は監査ログを 1 件記録する。記録に失敗しても操作自体は止めず、ログに出力するだけにする。
+func (s *AuditService) Record(actor AuditActor, action string, target AuditTarget, details map[string]interface{}) {
+	email := actor.Email
+	if email == "" && actor.UserID != nil {
+		if user, err := s.userRepo.FindByID(*actor.UserID); err == nil {
+			email = user.Email
+		}
+	}
+
+	event := &models.AuditEvent{
+		Action:      action,
+		ActorID:     actor.UserID,
+		ActorEmail:  truncateRunes(normalizeLoginEmail(email), 255),
+		TargetType:  target.Type,
+		TargetID:    truncateRunes(target.ID, 100),
+		TargetLabel: truncateRunes(target.Label, 255),
+		IP:          actor.IP,
+		UserAgent:   truncateRunes(actor.UserAgent, 255),
+	}
+	if len(details) > 0 {
+		if data, err := json.Marshal(details); err == nil {
+			event.Details = string(data)
+		}
+	}
+
+	if err := s.auditRepo.Create(event); err != nil {
+		log.Printf("Failed to record audit event %s: %v", action, err)
+	}
+}
+
+type AuditEventPage struct {
+	Entries     []models.AuditEvent
+	TotalCount  int64
+	TotalPages  int
+	CurrentPage int
+	PageSize    int
+}
+
+func (s *AuditService) Search(filter repository.AuditEventFilter, page, pageSize int) (*AuditEventPage, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 50
+	}
+
+	entries, totalCount, err := s.auditRepo.Search(filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int((totalCount + int64(pageSize) - 1) / int64(pageSize))
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	return &AuditEventPage{
+		Entries:     entries,
+		TotalCount:  totalCount,
+		TotalPages:  totalPages,
+		CurrentPage: page,
+		PageSize:    pageSize,
+	}, nil
+}
+
+// AuditEventExport はエクスポートする監査ログ 1 件。Details は JSON オブジェクトのまま出力する。
+type AuditEventExport struct {
+	models.AuditEvent
+	Details json.RawMessage `json:"details,omitempty"`
+}
+
+// Export は条件に合う監査ログを新しい順に最大 auditExportLimit 件返す。
+func (s *AuditService) Export(filter repository.AuditEventFilter) ([]AuditEventExport, error) {
+	entries, _, err := s.auditRepo.Search(filter, auditExportLimit, 0)
+	if err != nil {
+		return nil, err
+	}
+	exported := make([]AuditEventExport, len(entries))
+	for i, entry := range entries {
+		exported[i] = AuditEventExport{AuditEvent: entry}
+		if entry.Details != "" {
+			exported[i].Details = json.RawMessage(entry.Details)
+		}
+	}
+	return exported, nil
+}
+
+// AuditActions は管理画面の絞り込みに並べる操作の一覧。
+var AuditActions = []string{
+	models.AuditLoginSuccess,
+	models.AuditLoginFailure,
+	models.AuditLoginBlocked,
+	models.AuditPasswordChange,
+	models.AuditPasswordReset,
+	models.AuditTOTPEnable,
+	models.AuditTOTPDisable,
+	models.AuditRecoveryCodesRegenerate,
+	models.AuditPasskeyRegister,
+	models.AuditPasskeyDelete,
+	models.AuditSessionRevoke,
+	models.AuditSessionRevokeOthers,
+	models.AuditAPIKeyCreate,
+	models.AuditAPIKeyDelete,
+	models.AuditUserRoleChange,
+	models.AuditUserDelete,
+	models.AuditUserUnlock,
+	models.AuditSubjectArchive,
+	models.AuditSubjectUnarchive,
+	models.AuditSubjectRename,
+	models.AuditSubjectMerge,
+	models.AuditSubjectDelete,
+	models.AuditAssignmentImport,
+	models.AuditDataImport,
+}
+
+// AuditTargetTypes は管理画面の絞り込みに並べる対象の種類の一覧。
+var AuditTargetTypes = []string{
+	models.AuditTargetUser,
+	models.AuditTargetAPIKey,
+	models.AuditTargetPasskey,
+	models.AuditTargetSession,
+	models.AuditTargetSubject,
+}
+
+// GetAuditActionLabel は監査ログの操作の表示名を返す。
+func GetAuditActionLabel(action string) string {
+	switch action {
+	case models.AuditLoginSuccess:
+		return "ログイン成功"
+	case models.AuditLoginFailure:
+		return "ログイン失敗"
+	case models.AuditLoginBlocked:
+		return "ログイン拒否"
+	case models.AuditPasswordChange:
+		return "パスワード変更"
+	case models.AuditPasswordReset:
+		return "パスワード再設定"
+	case models.AuditTOTPEnable:
+		return "2段階認証の有効化"
+	case models.AuditTOTPDisable:
+		return "2段階認証の無効化"
+	case models.AuditRecoveryCodesRegenerate:
+		return "リカバリーコードの再発行"
+	case models.AuditPasskeyRegister:
+		return "パスキーの登録"
+	case models.AuditPasskeyDelete:
+		return "パスキーの削除"
+	case models.AuditSessionRevoke:
+		return "セッションのログアウト"
+	case models.AuditSessionRevokeOthers:
+		return "他の端末からのログアウト"
+	case models.AuditAPIKeyCreate:
+		return "APIキーの作成"
+	case models.AuditAPIKeyDelete:
+		return "APIキーの削除"
+	case models.AuditUserRoleChange:
+		return "権限の変更"
+	case models.AuditUserDelete:
+		return "ユーザーの削除"
+	case models.AuditUserUnlock:
+		return "ロックの解除"
+	case models.AuditSubjectArchive:
+		return "科目のアーカイブ"
+	case models.AuditSubjectUnarchive:
+		return "科目のアーカイブ解除"
+	case models.AuditSubjectRename:
+		return "科目の名前の変更"
+	case models.AuditSubjectMerge:
+		return "科目の統合"
+	case models.AuditSubjectDelete:
+		return "科目の削除"
+	case models.AuditAssignmentImport:
+		return "課題のインポート"
+	case models.AuditDataImport:
+		return "データのインポート"
+	default:
+		return action
+	}
+}
+
+// GetAuditTargetTypeLabel は監査ログの対象の種類の表示名を返す。
+func GetAuditTargetTypeLabel(targetType string) string {
+	switch targetType {
+	case models.AuditTargetUser:
+		return "ユーザー"
+	case models.AuditTargetAPIKey:
+		return "APIキー"
+	case models.AuditTargetPasskey:
+		return "パスキー"
+	case models.AuditTargetSession:
+		return "セッション"
+	case models.AuditTargetSubject:
+		return "科目"
+	default:
+		return targetType
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/testutil"
+)
+
+func TestAuditRecord(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "Actor@Example.com")
+	svc := NewAuditService(db)
+
+	// メールアドレスを省略するとユーザーから補い、小文字にそろえる
+	svc.Record(AuditActor{UserID: &user.ID, IP: "192.0.2.1", UserAgent: strings.Repeat("a", 300)},
+		models.AuditUserRoleChange, UserAuditTarget(user), map[string]interface{}{"from": "user", "to": "admin"})
+
+	page, err := svc.Search(repository.AuditEventFilter{}, 1, 10)
+	if err != nil || len(page.Entries) != 1 {
+		t.Fatalf("Search = %+v, %v", page, err)
+	}
+	event := page.Entries[0]
+	if event.ActorID == nil || *event.ActorID != user.ID || event.ActorEmail != "actor@example.com" {
+		t.Errorf("actor = %v %q", event.ActorID, event.ActorEmail)
+	}
+	if event.TargetType != models.AuditTargetUser || event.TargetID != strconv.FormatUint(uint64(user.ID), 10) || event.TargetLabel != user.Email {
+		t.Errorf("target = %s %s %q", event.TargetType, event.TargetID, event.TargetLabel)
+	}
+	if event.IP != "192.0.2.1" || utf8.RuneCountInString(event.UserAgent) != 255 || event.Details != `{"from":"user","to":"admin"}` {
+		t.Errorf("ip %q, user agent length %d, details %s", event.IP, utf8.RuneCountInString(event.UserAgent), event.Details)
+	}
+}
+
+func TestAuditSearch(t *testing.T) {
+	db := testutil.OpenDB(t)
+	svc := NewAuditService(db)
+	base := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	// 1時間おきに 25 件記録する
+	for i := 0; i < 25; i++ {
+		action := models.AuditLoginSuccess
+		if i%5 == 0 {
+			action = models.AuditLoginFailure
+		}
+		svc.Record(AuditActor{Email: "user" + strconv.Itoa(i%2) + "@example.com", IP: "192.0.2." + strconv.Itoa(i%3)}, action,
+			AuditTarget{Type: models.AuditTargetUser, ID: strconv.Itoa(i)}, nil)
+		db.Model(&models.AuditEvent{}).Where("target_id = ?", strconv.Itoa(i)).Update("created_at", base.Add(time.Duration(i)*time.Hour))
+	}
+
+	tests := []struct {
+		name      string
+		filter    repository.AuditEventFilter
+		page      int
+		wantTotal int64
+		wantPages int
+		wantIDs   []string // 新しい順
+	}{
+		{name: "条件なしの1ページ目", page: 1, wantTotal: 25, wantPages: 3, wantIDs: []string{"24", "23", "22", "21", "20", "19", "18", "17", "16", "15"}},
+		{name: "最後のページ", page: 3, wantTotal: 25, wantPages: 3, wantIDs: []string{"4", "3", "2", "1", "0"}},
+		{name: "ページ番号の補正", page: 0, wantTotal: 25, wantPages: 3, wantIDs: []string{"24", "23", "22", "21", "20", "19", "18", "17", "16", "15"}},
+		{name: "操作", filter: repository.AuditEventFilter{Action: models.AuditLoginFailure}, page: 1, wantTotal: 5, wantPages: 1, wantIDs: []string{"20", "15", "10", "5", "0"}},
+		{name: "操作者の部分一致", filter: repository.AuditEventFilter{Action: models.AuditLoginFailure, ActorEmail: "user1@"}, page: 1, wantTotal: 2, wantPages: 1, wantIDs: []string{"15", "5"}},
+		{name: "対象", filter: repository.AuditEventFilter{TargetType: models.AuditTargetUser, TargetID: "7"}, page: 1, wantTotal: 1, wantPages: 1, wantIDs: []string{"7"}},
+		{name: "IP", filter: repository.AuditEventFilter{IP: "192.0.2.2", From: base.Add(12 * time.Hour)}, page: 1, wantTotal: 4, wantPages: 1, wantIDs: []string{"23", "20", "17", "14"}},
+		{name: "期間", filter: repository.AuditEventFilter{From: base.Add(3 * time.Hour), To: base.Add(6 * time.Hour)}, page: 1, wantTotal: 3, wantPages: 1, wantIDs: []string{"5", "4", "3"}},
+		{name: "一致なし", filter: repository.AuditEventFilter{ActorEmail: "nobody"}, page: 1, wantTotal: 0, wantPages: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.Search(tt.filter, tt.page, 10)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			var ids []string
+			for _, entry := range result.Entries {
+				ids = append(ids, entry.TargetID)
+			}
+			if result.TotalCount != tt.wantTotal || result.TotalPages != tt.wantPages || strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("total %d, pages %d, ids %v; want %d, %d, %v", result.TotalCount, result.TotalPages, ids, tt.wantTotal, tt.wantPages, tt.wantIDs)
+			}
+		})
+	}
+}
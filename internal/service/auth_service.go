@@ -5,23 +5,57 @@ import (
 
 	"homework-manager/internal/models"
 	"homework-manager/internal/repository"
+	"homework-manager/internal/sessionstore"
+	"homework-manager/internal/timezone"
 
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrEmailAlreadyExists = errors.New("email already exists")
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidTimezone    = errors.New("invalid time zone")
 )
 
+// CommonTimezones はプロフィール画面で候補として表示するタイムゾーン。これ以外の IANA 名も入力できる。
+var CommonTimezones = []string{
+	"Asia/Tokyo",
+	"Asia/Seoul",
+	"Asia/Shanghai",
+	"Asia/Taipei",
+	"Asia/Singapore",
+	"Asia/Bangkok",
+	"Asia/Kolkata",
+	"Australia/Sydney",
+	"Pacific/Auckland",
+	"Pacific/Honolulu",
+	"America/Los_Angeles",
+	"America/Denver",
+	"America/Chicago",
+	"America/New_York",
+	"America/Sao_Paulo",
+	"Europe/London",
+	"Europe/Paris",
+	"Europe/Berlin",
+	"UTC",
+}
+
 type AuthService struct {
-	userRepo *repository.UserRepository
+	userRepo         *repository.UserRepository
+	recoveryCodeRepo *repository.RecoveryCodeRepository
+	totpService      *TOTPService
+	sessionService   *SessionService
 }
 
-func NewAuthService() *AuthService {
+// NewAuthService の sessions はパスワード変更時などにセッションを無効化するのに使う。
+func NewAuthService(db *gorm.DB, sessions sessionstore.Backend) *AuthService {
 	return &AuthService{
-		userRepo: repository.NewUserRepository(),
+		userRepo:         repository.NewUserRepository(db),
+		recoveryCodeRepo: repository.NewRecoveryCodeRepository(db),
+		totpService:      NewTOTPService(),
+		sessionService:   NewSessionService(sessions),
 	}
 }
 
@@ -76,7 +110,8 @@ func (s *AuthService) GetUserByID(id uint) (*models.User, error) {
 	return s.userRepo.FindByID(id)
 }
 
-func (s *AuthService) ChangePassword(userID uint, oldPassword, newPassword string) error {
+// ChangePassword はパスワードを変更し、currentSessionID 以外のセッションをすべてログアウトさせる。
+func (s *AuthService) ChangePassword(userID uint, currentSessionID, oldPassword, newPassword string) error {
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil {
 		return ErrUserNotFound
@@ -86,6 +121,28 @@ func (s *AuthService) ChangePassword(userID uint, oldPassword, newPassword strin
 		return ErrInvalidCredentials
 	}
 
+	if err := s.setPassword(user, newPassword); err != nil {
+		return err
+	}
+	_, err = s.sessionService.RevokeOthers(userID, currentSessionID)
+	return err
+}
+
+// ResetPassword は現在のパスワードを確認せずに新しいパスワードを設定する。パスワード再設定のリンクから使う。
+// 再設定はログインしていない状態で行うため、すべてのセッションをログアウトさせる。
+func (s *AuthService) ResetPassword(userID uint, newPassword string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := s.setPassword(user, newPassword); err != nil {
+		return err
+	}
+	_, err = s.sessionService.RevokeAll(userID)
+	return err
+}
+
+func (s *AuthService) setPassword(user *models.User, newPassword string) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return err
@@ -95,32 +152,65 @@ func (s *AuthService) ChangePassword(userID uint, oldPassword, newPassword strin
 	return s.userRepo.Update(user)
 }
 
-func (s *AuthService) UpdateProfile(userID uint, name string) error {
+// UpdateProfile は名前とタイムゾーンを更新する。timezone が空の場合はサーバーのタイムゾーンを使う。
+func (s *AuthService) UpdateProfile(userID uint, name, tz string) error {
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil {
 		return ErrUserNotFound
 	}
+	if tz != "" && !timezone.Valid(tz) {
+		return ErrInvalidTimezone
+	}
 
 	user.Name = name
+	user.Timezone = tz
 	return s.userRepo.Update(user)
 }
 
-func (s *AuthService) EnableTOTP(userID uint, secret string) error {
+// EnableTOTP は 2 段階認証を有効にする。step は設定時に確認したコードのタイムステップで、同じコードでのログインを防ぐ。
+func (s *AuthService) EnableTOTP(userID uint, secret string, step int64) error {
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil {
 		return ErrUserNotFound
 	}
 	user.TOTPSecret = secret
 	user.TOTPEnabled = true
+	user.TOTPLastStep = step
 	return s.userRepo.Update(user)
 }
 
-func (s *AuthService) DisableTOTP(userID uint) error {
+// VerifyTOTP はログイン時の認証コードを検証する。受け付けたコードのタイムステップを記録し、同じコードの再利用を拒否する。
+func (s *AuthService) VerifyTOTP(user *models.User, code string) bool {
+	if !user.TOTPEnabled {
+		return false
+	}
+	step, ok := s.totpService.ValidateStep(user.TOTPSecret, code, user.TOTPLastStep)
+	if !ok {
+		return false
+	}
+	advanced, err := s.userRepo.AdvanceTOTPStep(user.ID, step)
+	if err != nil || !advanced {
+		return false
+	}
+	user.TOTPLastStep = step
+	return true
+}
+
+// DisableTOTP は 2 段階認証を無効にし、currentSessionID 以外のセッションをすべてログアウトさせる。
+func (s *AuthService) DisableTOTP(userID uint, currentSessionID string) error {
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil {
 		return ErrUserNotFound
 	}
 	user.TOTPSecret = ""
 	user.TOTPEnabled = false
-	return s.userRepo.Update(user)
+	user.TOTPLastStep = 0
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	if err := s.recoveryCodeRepo.DeleteByUserID(userID); err != nil {
+		return err
+	}
+	_, err = s.sessionService.RevokeOthers(userID, currentSessionID)
+	return err
 }
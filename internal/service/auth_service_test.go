@@ -0,0 +1,92 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"homework-manager/internal/sessionstore"
+	"homework-manager/internal/testutil"
+
+	totplib "github.com/pquerna/otp/totp"
+)
+
+// 一度受け付けた認証コードと、それより前のタイムステップのコードは拒否する
+func TestVerifyTOTPRejectsReusedStep(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "totp@example.com")
+	auth := NewAuthService(db, sessionstore.NewMemoryBackend())
+
+	setup, err := auth.totpService.GenerateSecret(user.Email, "test")
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	base := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+	code := func(step int) string {
+		c, err := totplib.GenerateCode(setup.Secret, base.Add(time.Duration(step*totpPeriod)*time.Second))
+		if err != nil {
+			t.Fatalf("GenerateCode: %v", err)
+		}
+		return c
+	}
+
+	// 設定時に確認したコードのステップを記録する
+	if err := auth.EnableTOTP(user.ID, setup.Secret, base.Unix()/totpPeriod); err != nil {
+		t.Fatalf("EnableTOTP: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		at   int // 現在時刻のステップ（base からの差）
+		code int // コードのステップ（base からの差）
+		want bool
+	}{
+		{"設定時に確認したコード", 0, 0, false},
+		{"次のステップのコード", 1, 1, true},
+		{"同じコードの再利用", 1, 1, false},
+		{"時計のずれの範囲内でも受け付け済みのステップ", 2, 1, false},
+		{"新しいステップのコード", 2, 2, true},
+		{"1つ前のステップは時計のずれとして受け付ける", 4, 3, true},
+		{"2ステップ以上ずれたコード", 10, 7, false},
+	}
+	for _, tt := range tests {
+		now := base.Add(time.Duration(tt.at*totpPeriod) * time.Second)
+		auth.totpService.now = func() time.Time { return now }
+		stored, err := auth.GetUserByID(user.ID)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if got := auth.VerifyTOTP(stored, code(tt.code)); got != tt.want {
+			t.Errorf("%s: VerifyTOTP = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 同時に送られた同じコードは、読み込み済みのユーザーが古い状態でも1回しか受け付けない
+func TestVerifyTOTPConcurrentReplay(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "totp-race@example.com")
+	auth := NewAuthService(db, sessionstore.NewMemoryBackend())
+
+	setup, err := auth.totpService.GenerateSecret(user.Email, "test")
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+	auth.totpService.now = func() time.Time { return now }
+	if err := auth.EnableTOTP(user.ID, setup.Secret, 0); err != nil {
+		t.Fatalf("EnableTOTP: %v", err)
+	}
+	code, err := totplib.GenerateCode(setup.Secret, now)
+	if err != nil {
+		t.Fatalf("GenerateCode: %v", err)
+	}
+
+	first, _ := auth.GetUserByID(user.ID)
+	second, _ := auth.GetUserByID(user.ID)
+	if !auth.VerifyTOTP(first, code) {
+		t.Fatal("first VerifyTOTP failed")
+	}
+	if auth.VerifyTOTP(second, code) {
+		t.Error("the same code was accepted twice")
+	}
+}
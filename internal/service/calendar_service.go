@@ -0,0 +1,504 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"homework-manager/internal/ical"
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/rrule"
+	"homework-manager/internal/validation"
+
+	"gorm.io/gorm"
+)
+
+var ErrCalendarTokenNotFound = errors.New("calendar token not found")
+
+const calendarProdID = "-//Super-HomeworkManager//Homework Calendar//JA"
+
+// フローティング時刻（タイムゾーンなしの現地時刻）
+const floatingDateTimeFormat = "20060102T150405"
+
+type CalendarService struct {
+	userRepo       *repository.UserRepository
+	assignmentRepo *repository.AssignmentRepository
+	recurringRepo  *repository.RecurringAssignmentRepository
+	subjectRepo    *repository.SubjectRepository
+	webhookService *WebhookService
+}
+
+func NewCalendarService(db *gorm.DB) *CalendarService {
+	return &CalendarService{
+		userRepo:       repository.NewUserRepository(db),
+		assignmentRepo: repository.NewAssignmentRepository(db),
+		recurringRepo:  repository.NewRecurringAssignmentRepository(db),
+		subjectRepo:    repository.NewSubjectRepository(db),
+		webhookService: NewWebhookService(db),
+	}
+}
+
+func generateCalendarToken() (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// RotateToken はカレンダー購読用トークンを発行する。以前の購読URLは無効になる。
+// トークンはハッシュだけを保存するため、平文を返すのはこのときだけ。
+func (s *CalendarService) RotateToken(userID uint) (string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return "", ErrUserNotFound
+	}
+
+	token, err := generateCalendarToken()
+	if err != nil {
+		return "", err
+	}
+	user.CalendarTokenHash = hashToken(token)
+	if err := s.userRepo.Update(user); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *CalendarService) FindUserByToken(token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrCalendarTokenNotFound
+	}
+	user, err := s.userRepo.FindByCalendarTokenHash(hashToken(token))
+	if err != nil {
+		return nil, ErrCalendarTokenNotFound
+	}
+	return user, nil
+}
+
+// BuildFeed はユーザーの課題を iCalendar に変換する。asTodo が true の場合は VTODO、それ以外は VEVENT で出力する。
+// 有効な繰り返し設定は、まだ生成されていない回を RRULE 付きの1件として出力する。
+func (s *CalendarService) BuildFeed(user *models.User, asTodo bool) (*ical.Component, error) {
+	assignments, err := s.assignmentRepo.FindByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	recurrings, err := s.recurringRepo.FindActiveByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	subjects, err := s.subjectRepo.FindByUserID(user.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	archived := make(map[uint]bool)
+	for _, subject := range subjects {
+		archived[subject.ID] = subject.IsArchived
+	}
+
+	cal := ical.NewCalendar(calendarProdID)
+	cal.SetText("X-WR-CALNAME", "課題 ("+user.Name+")")
+	loc := user.Location()
+	if name := loc.String(); name != "Local" && name != "UTC" {
+		cal.Set("X-WR-TIMEZONE", name)
+	}
+
+	for i := range assignments {
+		if id := assignments[i].SubjectID; id != nil && archived[*id] {
+			continue
+		}
+		cal.AddChild(assignmentComponent(&assignments[i], asTodo))
+	}
+
+	for i := range recurrings {
+		component, err := s.recurringComponent(&recurrings[i], asTodo, loc)
+		if err != nil {
+			return nil, err
+		}
+		if component != nil {
+			cal.AddChild(component)
+		}
+	}
+
+	return cal, nil
+}
+
+func assignmentComponent(a *models.Assignment, asTodo bool) *ical.Component {
+	var c *ical.Component
+	if asTodo {
+		c = ical.NewComponent("VTODO")
+	} else {
+		c = ical.NewComponent("VEVENT")
+	}
+
+	c.Set("UID", a.UID())
+	c.SetDateTime("DTSTAMP", a.UpdatedAt)
+	c.SetDateTime("CREATED", a.CreatedAt)
+	c.SetDateTime("LAST-MODIFIED", a.UpdatedAt)
+
+	summary := a.Title
+	if a.IsCompleted && !asTodo {
+		// VEVENT には完了状態がないため、タイトルで区別する
+		summary = "[完了] " + a.Title
+	}
+	setCommonProperties(c, summary, a.Description, a.Subject, a.Priority)
+
+	if asTodo {
+		c.SetDateTime("DTSTART", a.DueDate)
+		c.SetDateTime("DUE", a.DueDate)
+		if a.IsCompleted {
+			c.Set("STATUS", "COMPLETED")
+			c.Set("PERCENT-COMPLETE", "100")
+			if a.CompletedAt != nil {
+				c.SetDateTime("COMPLETED", *a.CompletedAt)
+			}
+		} else {
+			c.Set("STATUS", "NEEDS-ACTION")
+		}
+	} else {
+		c.SetDateTime("DTSTART", a.DueDate)
+		c.SetDateTime("DTEND", a.DueDate)
+		c.Set("TRANSP", "TRANSPARENT")
+	}
+
+	return c
+}
+
+// recurringComponent は繰り返し設定を RRULE 付きの1件に変換する。日時は所有者のタイムゾーン loc のフローティング時刻で出力する。
+func (s *CalendarService) recurringComponent(r *models.RecurringAssignment, asTodo bool, loc *time.Location) (*ical.Component, error) {
+	if !r.ShouldGenerateNext() {
+		return nil, nil
+	}
+
+	rule, err := r.Rule()
+	if err != nil {
+		// 解析できない RRULE はフィード全体を壊さないよう出力しない
+		return nil, nil
+	}
+
+	latest, err := s.recurringRepo.GetLatestAssignmentByRecurringID(r.ID)
+	if err != nil {
+		return nil, err
+	}
+	if latest == nil {
+		return nil, nil
+	}
+
+	// 生成済みの回は個別の課題として出力されるため、次の未生成の回を起点にする
+	next := applyDueTime(r.DueTime, r.CalculateNextDueDate(latest.DueDate.In(loc)))
+	if !next.After(latest.DueDate) {
+		return nil, nil
+	}
+	if rule.Count > 0 {
+		rule.Count -= r.GeneratedCount
+		if rule.Count <= 0 {
+			return nil, nil
+		}
+	}
+	if !rule.Until.IsZero() && next.After(rule.Until) {
+		return nil, nil
+	}
+
+	var c *ical.Component
+	if asTodo {
+		c = ical.NewComponent("VTODO")
+	} else {
+		c = ical.NewComponent("VEVENT")
+	}
+
+	c.Set("UID", r.UID())
+	c.SetDateTime("DTSTAMP", r.UpdatedAt)
+	c.SetDateTime("CREATED", r.CreatedAt)
+	c.SetDateTime("LAST-MODIFIED", r.UpdatedAt)
+	setCommonProperties(c, r.Title, r.Description, r.Subject, r.Priority)
+
+	// BYDAY 等を現地の曜日・日付で評価させるため、繰り返しの日時はフローティング時刻で出力する
+	start := next.In(loc).Format(floatingDateTimeFormat)
+	c.Set("DTSTART", start)
+	if asTodo {
+		c.Set("DUE", start)
+		c.Set("STATUS", "NEEDS-ACTION")
+	} else {
+		c.Set("DTEND", start)
+		c.Set("TRANSP", "TRANSPARENT")
+	}
+	if !rule.Until.IsZero() {
+		rule.Until = rule.Until.In(loc)
+	}
+	c.Set("RRULE", rule.FloatingString())
+
+	exdates, err := rrule.ParseExDates(r.ExDates, loc)
+	if err == nil {
+		for _, ex := range exdates {
+			t := ex.Time
+			if ex.AllDay {
+				t = time.Date(t.Year(), t.Month(), t.Day(), next.Hour(), next.Minute(), next.Second(), 0, loc)
+			}
+			c.Set("EXDATE", t.Format(floatingDateTimeFormat))
+		}
+	}
+
+	return c, nil
+}
+
+func setCommonProperties(c *ical.Component, title, description, subject, priority string) {
+	c.SetText("SUMMARY", title)
+	if description != "" {
+		c.SetText("DESCRIPTION", description)
+	}
+	if subject != "" {
+		c.SetText("CATEGORIES", subject)
+	}
+	c.Set("PRIORITY", icalPriority(priority))
+}
+
+// icalPriority は重要度を iCalendar の PRIORITY (1 = 最高, 9 = 最低) に変換する。
+func icalPriority(priority string) string {
+	switch priority {
+	case "high":
+		return "1"
+	case "low":
+		return "9"
+	default:
+		return "5"
+	}
+}
+
+var ErrImportTooLarge = errors.New("import file is too large")
+
+// インポートできる .ics ファイルの最大サイズ
+const MaxICalImportSize = 2 << 20
+
+const (
+	ImportActionCreate    = "create"
+	ImportActionUpdate    = "update"
+	ImportActionUnchanged = "unchanged"
+	ImportActionSkip      = "skip"
+)
+
+type ICalImportItem struct {
+	UID          string    `json:"uid,omitempty"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	Subject      string    `json:"subject"`
+	Priority     string    `json:"priority"`
+	DueDate      time.Time `json:"due_date"`
+	Action       string    `json:"action"`
+	AssignmentID uint      `json:"assignment_id,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type ICalImportResult struct {
+	DryRun    bool             `json:"dry_run"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Unchanged int              `json:"unchanged"`
+	Skipped   int              `json:"skipped"`
+	Items     []ICalImportItem `json:"items"`
+}
+
+// ImportICal は VEVENT / VTODO を課題として取り込む。UID が同じ課題が既にあれば更新する。
+// dryRun が true の場合は保存せず、各項目の処理内容だけを返す。
+func (s *CalendarService) ImportICal(userID uint, data []byte, dryRun bool) (*ICalImportResult, error) {
+	if len(data) > MaxICalImportSize {
+		return nil, ErrImportTooLarge
+	}
+
+	root, err := ical.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if root.Name != "VCALENDAR" {
+		return nil, fmt.Errorf("%w: VCALENDAR not found", ical.ErrInvalidCalendar)
+	}
+
+	result := &ICalImportResult{DryRun: dryRun, Items: []ICalImportItem{}}
+	seen := make(map[string]bool)
+	loc := userLocation(s.userRepo, userID)
+
+	for _, component := range root.Children {
+		if component.Name != "VEVENT" && component.Name != "VTODO" {
+			continue
+		}
+		// 繰り返しの個別の回の上書き (RECURRENCE-ID) は取り込まない
+		if component.Get("RECURRENCE-ID") != nil {
+			continue
+		}
+
+		item := parseICalItem(component, loc)
+		switch {
+		case item.Error != "":
+		case item.UID != "" && seen[item.UID]:
+			item.Error = "同じ UID の項目がファイル内で重複しています"
+		default:
+			if item.UID != "" {
+				seen[item.UID] = true
+			}
+			if err := s.applyImportItem(userID, &item, dryRun); err != nil {
+				return nil, err
+			}
+		}
+
+		if item.Error != "" {
+			item.Action = ImportActionSkip
+		}
+		switch item.Action {
+		case ImportActionCreate:
+			result.Created++
+		case ImportActionUpdate:
+			result.Updated++
+		case ImportActionUnchanged:
+			result.Unchanged++
+		default:
+			result.Skipped++
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	return result, nil
+}
+
+// parseICalItem は VEVENT / VTODO を取り込み項目に変換する。フローティング時刻と日付は loc の時刻として扱う。
+func parseICalItem(c *ical.Component, loc *time.Location) ICalImportItem {
+	item := ICalImportItem{Priority: "medium"}
+
+	if p := c.Get("UID"); p != nil {
+		item.UID = strings.TrimSpace(p.Text())
+	}
+	if p := c.Get("SUMMARY"); p != nil {
+		item.Title = strings.TrimSpace(p.Text())
+	}
+	if p := c.Get("DESCRIPTION"); p != nil {
+		item.Description = strings.TrimSpace(p.Text())
+	}
+	if p := c.Get("CATEGORIES"); p != nil {
+		for _, category := range p.Texts() {
+			if category = strings.TrimSpace(category); category != "" {
+				item.Subject = category
+				break
+			}
+		}
+	}
+	if p := c.Get("PRIORITY"); p != nil {
+		item.Priority = priorityFromICal(strings.TrimSpace(p.Value))
+	}
+
+	due := c.Get("DUE")
+	if due == nil {
+		due = c.Get("DTSTART")
+	}
+	if due == nil {
+		item.Error = "期限 (DUE / DTSTART) がありません"
+		return item
+	}
+	dueDate, allDay, err := due.Time(loc)
+	if err != nil {
+		item.Error = "期限の形式が正しくありません"
+		return item
+	}
+	if allDay {
+		// 日付のみの場合はフォームからの登録と同様にその日の 23:59 を期限にする
+		dueDate = dueDate.Add(23*time.Hour + 59*time.Minute)
+	}
+	item.DueDate = dueDate.In(loc)
+
+	if item.Title == "" {
+		item.Error = "タイトル (SUMMARY) がありません"
+		return item
+	}
+	if err := validation.ValidateAssignmentInput(item.Title, item.Description, item.Subject, item.Priority); err != nil {
+		item.Error = err.Error()
+	}
+	return item
+}
+
+func (s *CalendarService) applyImportItem(userID uint, item *ICalImportItem, dryRun bool) error {
+	var existing *models.Assignment
+	if item.UID != "" {
+		var err error
+		existing, err = s.assignmentRepo.FindByUID(userID, item.UID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if existing == nil {
+		item.Action = ImportActionCreate
+		if dryRun {
+			return nil
+		}
+		subject, err := resolveSubject(s.subjectRepo, userID, item.Subject)
+		if err != nil {
+			return err
+		}
+		subjectID, subjectName := subjectRef(subject)
+		assignment := &models.Assignment{
+			UserID:                userID,
+			Title:                 item.Title,
+			Description:           item.Description,
+			SubjectID:             subjectID,
+			Subject:               subjectName,
+			Priority:              item.Priority,
+			DueDate:               item.DueDate.UTC(),
+			UrgentReminderEnabled: true,
+			ExternalUID:           item.UID,
+		}
+		if err := s.assignmentRepo.Create(assignment); err != nil {
+			return err
+		}
+		item.AssignmentID = assignment.ID
+		s.webhookService.Dispatch(userID, models.WebhookEventAssignmentCreated, assignment)
+		return nil
+	}
+
+	item.AssignmentID = existing.ID
+	if existing.Title == item.Title && existing.Description == item.Description &&
+		existing.Subject == item.Subject && existing.Priority == item.Priority &&
+		existing.DueDate.Equal(item.DueDate) {
+		item.Action = ImportActionUnchanged
+		return nil
+	}
+
+	item.Action = ImportActionUpdate
+	if dryRun {
+		return nil
+	}
+	subject, err := resolveSubject(s.subjectRepo, userID, item.Subject)
+	if err != nil {
+		return err
+	}
+	existing.Title = item.Title
+	existing.Description = item.Description
+	existing.SubjectID, existing.Subject = subjectRef(subject)
+	existing.Priority = item.Priority
+	if !existing.DueDate.Equal(item.DueDate) {
+		existing.OverdueNotifiedAt = nil
+	}
+	existing.DueDate = item.DueDate.UTC()
+	if err := s.assignmentRepo.Update(existing); err != nil {
+		return err
+	}
+	s.webhookService.Dispatch(userID, models.WebhookEventAssignmentUpdated, existing)
+	return nil
+}
+
+// priorityFromICal は PRIORITY (1-9, 0 = 未定義) を重要度に変換する。
+func priorityFromICal(value string) string {
+	n, err := strconv.Atoi(value)
+	switch {
+	case err != nil || n == 0:
+		return "medium"
+	case n <= 4:
+		return "high"
+	case n == 5:
+		return "medium"
+	default:
+		return "low"
+	}
+}
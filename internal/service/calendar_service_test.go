@@ -0,0 +1,189 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/testutil"
+	"homework-manager/internal/timezone"
+)
+
+// icsCalendar は components を VCALENDAR で囲んだ .ics を返す。
+func icsCalendar(components ...string) []byte {
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN"}
+	lines = append(lines, components...)
+	lines = append(lines, "END:VCALENDAR", "")
+	return []byte(strings.Join(lines, "\r\n"))
+}
+
+func TestImportICal(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "ical@example.com")
+	db.Model(user).Update("timezone", "Asia/Tokyo")
+	tokyo := timezone.Load("Asia/Tokyo")
+
+	notifiedAt := time.Date(2026, 4, 10, 1, 0, 0, 0, time.UTC)
+	updated := createTestAssignment(t, db, &models.Assignment{
+		UserID: user.ID, Title: "旧タイトル", Priority: "medium", ExternalUID: "update@example.com",
+		DueDate: time.Date(2026, 4, 10, 0, 0, 0, 0, time.UTC), OverdueNotifiedAt: &notifiedAt,
+	})
+	unchanged := createTestAssignment(t, db, &models.Assignment{
+		UserID: user.ID, Title: "変更なし", Priority: "medium", ExternalUID: "same@example.com",
+		DueDate: time.Date(2026, 4, 20, 0, 0, 0, 0, time.UTC),
+	})
+	// このサーバーが出力した UID で自分の課題を更新できる
+	own := createTestAssignment(t, db, &models.Assignment{
+		UserID: user.ID, Title: "出力した課題", Priority: "medium",
+		DueDate: time.Date(2026, 4, 12, 0, 0, 0, 0, time.UTC),
+	})
+
+	data := icsCalendar(
+		"BEGIN:VTODO", "UID:update@example.com", "SUMMARY:新しいタイトル", "DUE;TZID=Asia/Tokyo:20260411T090000", "END:VTODO",
+		"BEGIN:VTODO", "UID:same@example.com", "SUMMARY:変更なし", "DUE:20260420T000000Z", "END:VTODO",
+		"BEGIN:VEVENT", "UID:new@example.com", "SUMMARY:新しい", " 課題", "CATEGORIES:数学", "PRIORITY:1", "DTSTART;VALUE=DATE:20260415", "END:VEVENT",
+		"BEGIN:VTODO", "UID:"+own.UID(), "SUMMARY:名前を変えた課題", "DUE:20260412T000000Z", "END:VTODO",
+		"BEGIN:VTODO", "UID:new@example.com", "SUMMARY:重複", "DUE:20260416T000000Z", "END:VTODO",
+		"BEGIN:VEVENT", "UID:new@example.com", "RECURRENCE-ID:20260422", "SUMMARY:個別の回", "DTSTART:20260422", "END:VEVENT",
+		"BEGIN:VTODO", "UID:nodue@example.com", "SUMMARY:期限なし", "END:VTODO",
+	)
+
+	type wantItem struct {
+		uid    string
+		action string
+		due    time.Time
+	}
+	wantItems := []wantItem{
+		{"update@example.com", ImportActionUpdate, time.Date(2026, 4, 11, 9, 0, 0, 0, tokyo)},
+		{"same@example.com", ImportActionUnchanged, time.Date(2026, 4, 20, 0, 0, 0, 0, time.UTC)},
+		// 終日はユーザーのタイムゾーンでその日の 23:59
+		{"new@example.com", ImportActionCreate, time.Date(2026, 4, 15, 23, 59, 0, 0, tokyo)},
+		{own.UID(), ImportActionUpdate, time.Date(2026, 4, 12, 0, 0, 0, 0, time.UTC)},
+		{"new@example.com", ImportActionSkip, time.Date(2026, 4, 16, 0, 0, 0, 0, time.UTC)},
+		{"nodue@example.com", ImportActionSkip, time.Time{}},
+	}
+
+	svc := NewCalendarService(db)
+	for _, dryRun := range []bool{true, false} {
+		result, err := svc.ImportICal(user.ID, data, dryRun)
+		if err != nil {
+			t.Fatalf("ImportICal(dryRun=%v): %v", dryRun, err)
+		}
+		if result.DryRun != dryRun || result.Created != 1 || result.Updated != 2 || result.Unchanged != 1 || result.Skipped != 2 {
+			t.Errorf("dryRun=%v: counts = %+v", dryRun, result)
+		}
+		if len(result.Items) != len(wantItems) {
+			t.Fatalf("dryRun=%v: %d item(s), want %d", dryRun, len(result.Items), len(wantItems))
+		}
+		for i, want := range wantItems {
+			item := result.Items[i]
+			if item.UID != want.uid || item.Action != want.action || !item.DueDate.Equal(want.due) {
+				t.Errorf("dryRun=%v: item %d = %s %s %v, want %s %s %v", dryRun, i, item.UID, item.Action, item.DueDate, want.uid, want.action, want.due)
+			}
+		}
+		if item := result.Items[2]; item.Title != "新しい課題" || item.Subject != "数学" || item.Priority != "high" {
+			t.Errorf("dryRun=%v: new item = %+v", dryRun, item)
+		}
+
+		if dryRun {
+			// プレビューでは課題も科目も保存しない
+			var assignments, subjects int64
+			db.Model(&models.Assignment{}).Count(&assignments)
+			db.Model(&models.Subject{}).Count(&subjects)
+			if assignments != 3 || subjects != 0 {
+				t.Fatalf("dry run wrote %d assignment(s) and %d subject(s)", assignments, subjects)
+			}
+			var stored models.Assignment
+			db.First(&stored, updated.ID)
+			if stored.Title != "旧タイトル" || !stored.DueDate.Equal(updated.DueDate) {
+				t.Fatalf("dry run updated an assignment: %+v", stored)
+			}
+		}
+	}
+
+	var stored models.Assignment
+	db.First(&stored, updated.ID)
+	if stored.Title != "新しいタイトル" || !stored.DueDate.Equal(time.Date(2026, 4, 11, 0, 0, 0, 0, time.UTC)) || stored.OverdueNotifiedAt != nil {
+		t.Errorf("updated assignment = %+v", stored)
+	}
+	var renamed models.Assignment
+	db.First(&renamed, own.ID)
+	if renamed.Title != "名前を変えた課題" || renamed.ExternalUID != "" {
+		t.Errorf("own assignment = %+v", renamed)
+	}
+	var kept models.Assignment
+	db.First(&kept, unchanged.ID)
+	if !kept.UpdatedAt.Equal(unchanged.UpdatedAt) {
+		t.Error("unchanged assignment was saved")
+	}
+	var created models.Assignment
+	if err := db.Where("user_id = ? AND external_uid = ?", user.ID, "new@example.com").First(&created).Error; err != nil {
+		t.Fatalf("created assignment: %v", err)
+	}
+	if created.Title != "新しい課題" || created.Subject != "数学" || created.SubjectID == nil || created.Priority != "high" ||
+		!created.DueDate.Equal(time.Date(2026, 4, 15, 14, 59, 0, 0, time.UTC)) {
+		t.Errorf("created assignment = %+v", created)
+	}
+
+	// 同じファイルをもう一度取り込んでも課題は増えない
+	result, err := svc.ImportICal(user.ID, data, false)
+	if err != nil {
+		t.Fatalf("ImportICal again: %v", err)
+	}
+	if result.Created != 0 || result.Updated != 0 || result.Unchanged != 4 || result.Skipped != 2 {
+		t.Errorf("second import counts = %+v", result)
+	}
+	var count int64
+	db.Model(&models.Assignment{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 4 {
+		t.Errorf("%d assignment(s) after importing twice, want 4", count)
+	}
+}
+
+func TestImportICalInvalid(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "ical-invalid@example.com")
+	svc := NewCalendarService(db)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"VCALENDAR ではない", []byte("BEGIN:VTODO\r\nEND:VTODO\r\n")},
+		{"END がない", []byte("BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\n")},
+		{"大きすぎる", append(icsCalendar(), make([]byte, MaxICalImportSize)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.ImportICal(user.ID, tt.data, false); err == nil {
+				t.Error("ImportICal accepted invalid data")
+			}
+		})
+	}
+	var count int64
+	db.Model(&models.Assignment{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d assignment(s) created from invalid data", count)
+	}
+}
+
+func TestPriorityFromICal(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", "medium"},
+		{"0", "medium"},
+		{"1", "high"},
+		{"4", "high"},
+		{"5", "medium"},
+		{"6", "low"},
+		{"9", "low"},
+	}
+	for _, tt := range tests {
+		if got := priorityFromICal(tt.value); got != tt.want {
+			t.Errorf("priorityFromICal(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrChecklistItemNotFound      = errors.New("checklist item not found")
+	ErrChecklistItemTitleRequired = errors.New("checklist item title is required")
+)
+
+type ChecklistService struct {
+	itemRepo          *repository.ChecklistItemRepository
+	assignmentRepo    *repository.AssignmentRepository
+	assignmentService *AssignmentService
+}
+
+func NewChecklistService(db *gorm.DB) *ChecklistService {
+	return &ChecklistService{
+		itemRepo:          repository.NewChecklistItemRepository(db),
+		assignmentRepo:    repository.NewAssignmentRepository(db),
+		assignmentService: NewAssignmentService(db),
+	}
+}
+
+func (s *ChecklistService) List(userID, assignmentID uint) ([]models.ChecklistItem, error) {
+	if _, err := s.assignmentService.GetByID(userID, assignmentID); err != nil {
+		return nil, err
+	}
+	return s.itemRepo.FindByAssignmentID(assignmentID)
+}
+
+// Progress は課題ごとのチェックリストの進捗を返す。一覧画面で表示している課題の ID を渡す。
+func (s *ChecklistService) Progress(assignments []models.Assignment) map[uint]models.ChecklistProgress {
+	ids := make([]uint, 0, len(assignments))
+	for _, a := range assignments {
+		ids = append(ids, a.ID)
+	}
+	progress, err := s.itemRepo.ProgressByAssignmentIDs(ids)
+	if err != nil {
+		return map[uint]models.ChecklistProgress{}
+	}
+	return progress
+}
+
+func (s *ChecklistService) Create(userID, assignmentID uint, title string, dueDate *time.Time) (*models.ChecklistItem, error) {
+	if _, err := s.assignmentService.GetByID(userID, assignmentID); err != nil {
+		return nil, err
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, ErrChecklistItemTitleRequired
+	}
+
+	position, err := s.itemRepo.NextPosition(assignmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &models.ChecklistItem{
+		AssignmentID: assignmentID,
+		Title:        title,
+		Position:     position,
+		DueDate:      utcPtr(dueDate),
+	}
+	if err := s.itemRepo.Create(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+type UpdateChecklistItemInput struct {
+	Title        *string
+	DueDate      *time.Time
+	ClearDueDate bool
+	IsDone       *bool
+	Position     *int
+}
+
+func (s *ChecklistService) Update(userID, assignmentID, itemID uint, input UpdateChecklistItemInput) (*models.ChecklistItem, error) {
+	item, err := s.getItem(userID, assignmentID, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Title != nil {
+		title := strings.TrimSpace(*input.Title)
+		if title == "" {
+			return nil, ErrChecklistItemTitleRequired
+		}
+		item.Title = title
+	}
+	if input.ClearDueDate {
+		item.DueDate = nil
+	} else if input.DueDate != nil {
+		item.DueDate = utcPtr(input.DueDate)
+	}
+	if input.IsDone != nil {
+		setItemDone(item, *input.IsDone)
+	}
+
+	if err := s.itemRepo.Update(item); err != nil {
+		return nil, err
+	}
+
+	if input.Position != nil {
+		if err := s.move(item, *input.Position); err != nil {
+			return nil, err
+		}
+	}
+
+	if input.IsDone != nil && *input.IsDone {
+		s.autoComplete(userID, assignmentID)
+	}
+	return item, nil
+}
+
+func (s *ChecklistService) Toggle(userID, assignmentID, itemID uint) (*models.ChecklistItem, error) {
+	item, err := s.getItem(userID, assignmentID, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	setItemDone(item, !item.IsDone)
+	if err := s.itemRepo.Update(item); err != nil {
+		return nil, err
+	}
+
+	if item.IsDone {
+		s.autoComplete(userID, assignmentID)
+	}
+	return item, nil
+}
+
+// Move は項目を position 番目（0 始まり）に移動する。範囲外の場合は先頭または末尾に移動する。
+func (s *ChecklistService) Move(userID, assignmentID, itemID uint, position int) error {
+	item, err := s.getItem(userID, assignmentID, itemID)
+	if err != nil {
+		return err
+	}
+	return s.move(item, position)
+}
+
+func (s *ChecklistService) move(item *models.ChecklistItem, position int) error {
+	items, err := s.itemRepo.FindByAssignmentID(item.AssignmentID)
+	if err != nil {
+		return err
+	}
+
+	ordered := make([]models.ChecklistItem, 0, len(items))
+	for _, it := range items {
+		if it.ID != item.ID {
+			ordered = append(ordered, it)
+		}
+	}
+	if position < 0 {
+		position = 0
+	}
+	if position > len(ordered) {
+		position = len(ordered)
+	}
+	ordered = append(ordered[:position], append([]models.ChecklistItem{*item}, ordered[position:]...)...)
+
+	if err := s.itemRepo.UpdatePositions(ordered); err != nil {
+		return err
+	}
+	item.Position = position
+	return nil
+}
+
+func (s *ChecklistService) Delete(userID, assignmentID, itemID uint) error {
+	item, err := s.getItem(userID, assignmentID, itemID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.itemRepo.Delete(item.ID); err != nil {
+		return err
+	}
+
+	// 未完了の項目を削除すると、残りがすべて完了済みになる場合がある
+	if !item.IsDone {
+		s.autoComplete(userID, assignmentID)
+	}
+	return nil
+}
+
+// SetAutoComplete はチェックリストの完了で課題を自動的に完了にするかを設定する。
+// 有効にした時点ですべての項目が完了済みであれば、課題を完了にする。
+func (s *ChecklistService) SetAutoComplete(userID, assignmentID uint, enabled bool) error {
+	assignment, err := s.assignmentService.GetByID(userID, assignmentID)
+	if err != nil {
+		return err
+	}
+	if assignment.ChecklistAutoComplete == enabled {
+		return nil
+	}
+
+	assignment.ChecklistAutoComplete = enabled
+	if err := s.assignmentRepo.Update(assignment); err != nil {
+		return err
+	}
+
+	if enabled {
+		s.autoComplete(userID, assignmentID)
+	}
+	return nil
+}
+
+func (s *ChecklistService) getItem(userID, assignmentID, itemID uint) (*models.ChecklistItem, error) {
+	if _, err := s.assignmentService.GetByID(userID, assignmentID); err != nil {
+		return nil, err
+	}
+
+	item, err := s.itemRepo.FindByID(itemID)
+	if err != nil || item.AssignmentID != assignmentID {
+		return nil, ErrChecklistItemNotFound
+	}
+	return item, nil
+}
+
+// autoComplete は自動完了が有効な課題で、すべての項目が完了していれば課題を完了にする。
+func (s *ChecklistService) autoComplete(userID, assignmentID uint) {
+	assignment, err := s.assignmentService.GetByID(userID, assignmentID)
+	if err != nil || !assignment.ChecklistAutoComplete || assignment.IsCompleted {
+		return
+	}
+
+	progress, err := s.itemRepo.ProgressByAssignmentIDs([]uint{assignmentID})
+	if err != nil {
+		return
+	}
+	if p := progress[assignmentID]; p.Total > 0 && p.Done == p.Total {
+		s.assignmentService.ToggleComplete(userID, assignmentID)
+	}
+}
+
+func setItemDone(item *models.ChecklistItem, done bool) {
+	if item.IsDone == done {
+		return
+	}
+	item.IsDone = done
+	if done {
+		now := time.Now().UTC()
+		item.DoneAt = &now
+	} else {
+		item.DoneAt = nil
+	}
+}
+
+// checklistItemsFromTitles は繰り返し設定のチェックリストから課題にコピーする項目を作る。
+func checklistItemsFromTitles(titles []string) []models.ChecklistItem {
+	items := make([]models.ChecklistItem, 0, len(titles))
+	for i, title := range titles {
+		items = append(items, models.ChecklistItem{Title: title, Position: i})
+	}
+	return items
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/testutil"
+)
+
+func TestChecklistAutoComplete(t *testing.T) {
+	tests := []struct {
+		name          string
+		autoComplete  bool
+		check         []bool // 各項目を完了にするか
+		wantCompleted bool
+	}{
+		{"すべて完了", true, []bool{true, true}, true},
+		{"一部のみ完了", true, []bool{true, false}, false},
+		{"自動完了が無効", false, []bool{true, true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			user := createTestUser(t, db, "checklist@example.com")
+			assignment := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: time.Now().Add(24 * time.Hour)})
+			svc := NewChecklistService(db)
+
+			if err := svc.SetAutoComplete(user.ID, assignment.ID, tt.autoComplete); err != nil {
+				t.Fatalf("SetAutoComplete: %v", err)
+			}
+			var items []*models.ChecklistItem
+			for _, title := range []string{"調べる", "書く"} {
+				item, err := svc.Create(user.ID, assignment.ID, title, nil)
+				if err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+				items = append(items, item)
+			}
+			for i, check := range tt.check {
+				if !check {
+					continue
+				}
+				if _, err := svc.Toggle(user.ID, assignment.ID, items[i].ID); err != nil {
+					t.Fatalf("Toggle: %v", err)
+				}
+			}
+
+			var got models.Assignment
+			db.First(&got, assignment.ID)
+			if got.IsCompleted != tt.wantCompleted {
+				t.Errorf("IsCompleted = %v, want %v", got.IsCompleted, tt.wantCompleted)
+			}
+
+			progress := svc.Progress([]models.Assignment{got})[assignment.ID]
+			done := 0
+			for _, check := range tt.check {
+				if check {
+					done++
+				}
+			}
+			if progress.Done != done || progress.Total != len(items) {
+				t.Errorf("progress = %+v, want %d/%d", progress, done, len(items))
+			}
+		})
+	}
+}
+
+func TestChecklistAutoCompleteAfterDeletingPendingItem(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "checklist@example.com")
+	assignment := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: time.Now().Add(24 * time.Hour)})
+	svc := NewChecklistService(db)
+	svc.SetAutoComplete(user.ID, assignment.ID, true)
+
+	done, _ := svc.Create(user.ID, assignment.ID, "完了済み", nil)
+	pending, _ := svc.Create(user.ID, assignment.ID, "不要になった", nil)
+	svc.Toggle(user.ID, assignment.ID, done.ID)
+
+	if err := svc.Delete(user.ID, assignment.ID, pending.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	var got models.Assignment
+	db.First(&got, assignment.ID)
+	if !got.IsCompleted {
+		t.Error("assignment not completed after deleting the last pending item")
+	}
+}
+
+func TestChecklistMove(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     int
+		position int
+		want     []string
+	}{
+		{"先頭へ", 2, 0, []string{"C", "A", "B"}},
+		{"末尾へ", 0, 2, []string{"B", "C", "A"}},
+		{"範囲外は末尾", 0, 10, []string{"B", "C", "A"}},
+		{"負の値は先頭", 1, -1, []string{"B", "A", "C"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			user := createTestUser(t, db, "move@example.com")
+			assignment := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: time.Now()})
+			svc := NewChecklistService(db)
+
+			var ids []uint
+			for _, title := range []string{"A", "B", "C"} {
+				item, err := svc.Create(user.ID, assignment.ID, title, nil)
+				if err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+				ids = append(ids, item.ID)
+			}
+
+			if err := svc.Move(user.ID, assignment.ID, ids[tt.item], tt.position); err != nil {
+				t.Fatalf("Move: %v", err)
+			}
+
+			items, err := svc.List(user.ID, assignment.ID)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			var got []string
+			for _, item := range items {
+				got = append(got, item.Title)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("titles = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("titles = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestChecklistOwnership(t *testing.T) {
+	db := testutil.OpenDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	assignment := createTestAssignment(t, db, &models.Assignment{UserID: owner.ID, DueDate: time.Now()})
+	otherAssignment := createTestAssignment(t, db, &models.Assignment{UserID: other.ID, DueDate: time.Now()})
+	svc := NewChecklistService(db)
+
+	item, err := svc.Create(owner.ID, assignment.ID, "項目", nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := svc.Create(other.ID, assignment.ID, "横取り", nil); err != ErrUnauthorized {
+		t.Errorf("Create on other user's assignment: err = %v, want %v", err, ErrUnauthorized)
+	}
+	if _, err := svc.Toggle(other.ID, otherAssignment.ID, item.ID); err != ErrChecklistItemNotFound {
+		t.Errorf("Toggle through other assignment: err = %v, want %v", err, ErrChecklistItemNotFound)
+	}
+	if _, err := svc.Create(owner.ID, assignment.ID, "  ", nil); err != ErrChecklistItemTitleRequired {
+		t.Errorf("Create with blank title: err = %v, want %v", err, ErrChecklistItemTitleRequired)
+	}
+}
+
+func TestRecurringChecklistIsCopied(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "recurring-checklist@example.com")
+	svc := NewRecurringAssignmentService(db)
+
+	recurring, err := svc.Create(user.ID, CreateRecurringAssignmentInput{
+		Title:                 "レポート",
+		Priority:              "medium",
+		RecurrenceType:        models.RecurrenceWeekly,
+		DueTime:               "09:00",
+		EndType:               models.EndTypeNever,
+		Checklist:             "調べる\n\n  書く  \n提出する",
+		ChecklistAutoComplete: true,
+		FirstDueDate:          time.Date(2026, 4, 10, 9, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if recurring.Checklist != "調べる\n書く\n提出する" {
+		t.Errorf("Checklist = %q, want blank lines and spaces removed", recurring.Checklist)
+	}
+
+	var generated models.Assignment
+	if err := db.Preload("ChecklistItems").Where("recurring_assignment_id = ?", recurring.ID).First(&generated).Error; err != nil {
+		t.Fatalf("find generated assignment: %v", err)
+	}
+	if !generated.ChecklistAutoComplete {
+		t.Error("ChecklistAutoComplete not copied")
+	}
+	want := []string{"調べる", "書く", "提出する"}
+	if len(generated.ChecklistItems) != len(want) {
+		t.Fatalf("copied %d item(s), want %d", len(generated.ChecklistItems), len(want))
+	}
+	for i, item := range generated.ChecklistItems {
+		if item.Title != want[i] || item.Position != i || item.IsDone {
+			t.Errorf("item[%d] = %+v, want %q at position %d", i, item, want[i], i)
+		}
+	}
+}
@@ -0,0 +1,884 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/rrule"
+	"homework-manager/internal/validation"
+
+	"gorm.io/gorm"
+)
+
+// ExportFormatVersion はエクスポート形式のバージョン。形式を変更したら上げること。
+// 2: 科目 (subjects) を追加
+// 3: 課題・繰り返し設定のタグ (tags) を追加
+const ExportFormatVersion = 3
+
+const MaxDataImportSize = 10 << 20
+
+const (
+	ExportFormatJSON = "json"
+	ExportFormatCSV  = "csv"
+)
+
+const (
+	csvSubjectsFile             = "subjects.csv"
+	csvAssignmentsFile          = "assignments.csv"
+	csvRecurringAssignmentsFile = "recurring_assignments.csv"
+	csvNotificationSettingsFile = "notification_settings.csv"
+)
+
+var (
+	ErrUnsupportedImportFormat  = errors.New("unsupported import format")
+	ErrUnsupportedExportVersion = errors.New("unsupported export version")
+)
+
+// ExportDocument はエクスポートファイル（JSON）の全体。CSV では各配列が1ファイルになる。
+// 課題と繰り返し設定は UID、科目は名前で結び付け、ID はインポート先で振り直す。
+type ExportDocument struct {
+	Version              int                         `json:"version"`
+	ExportedAt           time.Time                   `json:"exported_at"`
+	Subjects             []ExportSubject             `json:"subjects"`
+	Assignments          []ExportAssignment          `json:"assignments"`
+	RecurringAssignments []ExportRecurringAssignment `json:"recurring_assignments"`
+	NotificationSettings *ExportNotificationSettings `json:"notification_settings,omitempty"`
+}
+
+type ExportSubject struct {
+	Name                  string `json:"name"`
+	Color                 string `json:"color"`
+	Teacher               string `json:"teacher"`
+	Room                  string `json:"room"`
+	DefaultPriority       string `json:"default_priority"`
+	DefaultReminderOffset *int   `json:"default_reminder_offset"`
+	IsArchived            bool   `json:"is_archived"`
+}
+
+type ExportAssignment struct {
+	UID                   string     `json:"uid"`
+	Title                 string     `json:"title"`
+	Description           string     `json:"description"`
+	Subject               string     `json:"subject"`
+	Priority              string     `json:"priority"`
+	DueDate               time.Time  `json:"due_date"`
+	IsCompleted           bool       `json:"is_completed"`
+	CompletedAt           *time.Time `json:"completed_at"`
+	IsArchived            bool       `json:"is_archived"` // 科目がアーカイブされているか（バージョン 1 との互換のため）
+	ReminderEnabled       bool       `json:"reminder_enabled"`
+	ReminderAt            *time.Time `json:"reminder_at"`
+	UrgentReminderEnabled bool       `json:"urgent_reminder_enabled"`
+	ChecklistAutoComplete bool       `json:"checklist_auto_complete"`
+	Tags                  string     `json:"tags"` // カンマ区切り
+	RecurringUID          string     `json:"recurring_uid"`
+}
+
+type ExportRecurringAssignment struct {
+	UID                   string     `json:"uid"`
+	Title                 string     `json:"title"`
+	Description           string     `json:"description"`
+	Subject               string     `json:"subject"`
+	Priority              string     `json:"priority"`
+	RecurrenceType        string     `json:"recurrence_type"`
+	RecurrenceInterval    int        `json:"recurrence_interval"`
+	RecurrenceWeekday     *int       `json:"recurrence_weekday"`
+	RecurrenceDay         *int       `json:"recurrence_day"`
+	RRule                 string     `json:"rrule"`
+	ExDates               string     `json:"exdates"`
+	StartDate             *time.Time `json:"start_date"`
+	DueTime               string     `json:"due_time"`
+	EndType               string     `json:"end_type"`
+	EndCount              *int       `json:"end_count"`
+	EndDate               *time.Time `json:"end_date"`
+	GeneratedCount        int        `json:"generated_count"`
+	EditBehavior          string     `json:"edit_behavior"`
+	ReminderEnabled       bool       `json:"reminder_enabled"`
+	ReminderOffset        *int       `json:"reminder_offset"`
+	UrgentReminderEnabled bool       `json:"urgent_reminder_enabled"`
+	Checklist             string     `json:"checklist"`
+	ChecklistAutoComplete bool       `json:"checklist_auto_complete"`
+	Tags                  string     `json:"tags"` // カンマ区切り
+	IsActive              bool       `json:"is_active"`
+}
+
+type ExportNotificationSettings struct {
+	TelegramEnabled bool   `json:"telegram_enabled"`
+	TelegramChatID  string `json:"telegram_chat_id"`
+	EmailEnabled    bool   `json:"email_enabled"`
+	NotifyOnCreate  bool   `json:"notify_on_create"`
+}
+
+const (
+	ImportEntitySubject              = "subject"
+	ImportEntityAssignment           = "assignment"
+	ImportEntityRecurringAssignment  = "recurring_assignment"
+	ImportEntityNotificationSettings = "notification_settings"
+)
+
+// DataImportError は取り込めなかった行。Row はファイル内の1始まりの位置（CSV ではヘッダーを除く）。
+type DataImportError struct {
+	Entity string `json:"entity"`
+	Row    int    `json:"row"`
+	Error  string `json:"error"`
+}
+
+type DataImportCounts struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+type DataImportResult struct {
+	Subjects             DataImportCounts  `json:"subjects"`
+	Assignments          DataImportCounts  `json:"assignments"`
+	RecurringAssignments DataImportCounts  `json:"recurring_assignments"`
+	NotificationSettings bool              `json:"notification_settings"`
+	Errors               []DataImportError `json:"errors"`
+}
+
+func (r *DataImportResult) addError(entity string, row int, err error) {
+	r.Errors = append(r.Errors, DataImportError{Entity: entity, Row: row, Error: err.Error()})
+}
+
+type DataTransferService struct {
+	assignmentRepo      *repository.AssignmentRepository
+	recurringRepo       *repository.RecurringAssignmentRepository
+	subjectRepo         *repository.SubjectRepository
+	tagRepo             *repository.TagRepository
+	userRepo            *repository.UserRepository
+	notificationService *NotificationService
+	webhookService      *WebhookService
+	telegramBot         *TelegramBotService
+}
+
+// NewDataTransferService は telegramBot が nil でない場合、インポートで Telegram の Chat ID を変更しない。
+func NewDataTransferService(db *gorm.DB, telegramBot *TelegramBotService) *DataTransferService {
+	return &DataTransferService{
+		assignmentRepo:      repository.NewAssignmentRepository(db),
+		recurringRepo:       repository.NewRecurringAssignmentRepository(db),
+		subjectRepo:         repository.NewSubjectRepository(db),
+		tagRepo:             repository.NewTagRepository(db),
+		userRepo:            repository.NewUserRepository(db),
+		notificationService: NewNotificationService(db, config.NotificationConfig{}),
+		webhookService:      NewWebhookService(db),
+		telegramBot:         telegramBot,
+	}
+}
+
+// BuildExport はユーザーのデータをエクスポートする。日時はユーザーのタイムゾーンのオフセット付きで書き出す。
+func (s *DataTransferService) BuildExport(userID uint) (*ExportDocument, error) {
+	loc := userLocation(s.userRepo, userID)
+	doc := &ExportDocument{
+		Version:              ExportFormatVersion,
+		ExportedAt:           time.Now().In(loc),
+		Subjects:             []ExportSubject{},
+		Assignments:          []ExportAssignment{},
+		RecurringAssignments: []ExportRecurringAssignment{},
+	}
+
+	subjects, err := s.subjectRepo.FindByUserID(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	archived := make(map[uint]bool, len(subjects))
+	for _, subject := range subjects {
+		archived[subject.ID] = subject.IsArchived
+		doc.Subjects = append(doc.Subjects, ExportSubject{
+			Name:                  subject.Name,
+			Color:                 subject.Color,
+			Teacher:               subject.Teacher,
+			Room:                  subject.Room,
+			DefaultPriority:       subject.DefaultPriority,
+			DefaultReminderOffset: subject.DefaultReminderOffset,
+			IsArchived:            subject.IsArchived,
+		})
+	}
+
+	recurrings, err := s.recurringRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	recurringUIDs := make(map[uint]string, len(recurrings))
+	for i := range recurrings {
+		r := &recurrings[i]
+		recurringUIDs[r.ID] = r.UID()
+		doc.RecurringAssignments = append(doc.RecurringAssignments, ExportRecurringAssignment{
+			UID:                   r.UID(),
+			Title:                 r.Title,
+			Description:           r.Description,
+			Subject:               r.Subject,
+			Priority:              r.Priority,
+			RecurrenceType:        r.RecurrenceType,
+			RecurrenceInterval:    r.RecurrenceInterval,
+			RecurrenceWeekday:     r.RecurrenceWeekday,
+			RecurrenceDay:         r.RecurrenceDay,
+			RRule:                 r.RRule,
+			ExDates:               r.ExDates,
+			StartDate:             inLocation(r.StartDate, loc),
+			DueTime:               r.DueTime,
+			EndType:               r.EndType,
+			EndCount:              r.EndCount,
+			EndDate:               inLocation(r.EndDate, loc),
+			GeneratedCount:        r.GeneratedCount,
+			EditBehavior:          r.EditBehavior,
+			ReminderEnabled:       r.ReminderEnabled,
+			ReminderOffset:        r.ReminderOffset,
+			UrgentReminderEnabled: r.UrgentReminderEnabled,
+			Checklist:             r.Checklist,
+			ChecklistAutoComplete: r.ChecklistAutoComplete,
+			Tags:                  r.Tags,
+			IsActive:              r.IsActive,
+		})
+	}
+
+	assignments, err := s.assignmentRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range assignments {
+		a := &assignments[i]
+		item := ExportAssignment{
+			UID:                   a.UID(),
+			Title:                 a.Title,
+			Description:           a.Description,
+			Subject:               a.Subject,
+			Priority:              a.Priority,
+			DueDate:               a.DueDate.In(loc),
+			IsCompleted:           a.IsCompleted,
+			CompletedAt:           inLocation(a.CompletedAt, loc),
+			ReminderEnabled:       a.ReminderEnabled,
+			ReminderAt:            inLocation(a.ReminderAt, loc),
+			UrgentReminderEnabled: a.UrgentReminderEnabled,
+			ChecklistAutoComplete: a.ChecklistAutoComplete,
+			Tags:                  joinTagNames(models.TagNames(a.Tags)),
+		}
+		if a.SubjectID != nil {
+			item.IsArchived = archived[*a.SubjectID]
+		}
+		if a.RecurringAssignmentID != nil {
+			item.RecurringUID = recurringUIDs[*a.RecurringAssignmentID]
+		}
+		doc.Assignments = append(doc.Assignments, item)
+	}
+
+	settings, err := s.notificationService.GetUserSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	doc.NotificationSettings = &ExportNotificationSettings{
+		TelegramEnabled: settings.TelegramEnabled,
+		TelegramChatID:  settings.TelegramChatID,
+		EmailEnabled:    settings.EmailEnabled,
+		NotifyOnCreate:  settings.NotifyOnCreate,
+	}
+
+	return doc, nil
+}
+
+// WriteJSON はエクスポートを JSON で書き出す。
+func (s *DataTransferService) WriteJSON(w io.Writer, doc *ExportDocument) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// WriteCSVArchive はエクスポートをエンティティごとの CSV にして ZIP で書き出す。
+func (s *DataTransferService) WriteCSVArchive(w io.Writer, doc *ExportDocument) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		rows interface{}
+	}{
+		{csvSubjectsFile, doc.Subjects},
+		{csvAssignmentsFile, doc.Assignments},
+		{csvRecurringAssignmentsFile, doc.RecurringAssignments},
+	}
+	if doc.NotificationSettings != nil {
+		files = append(files, struct {
+			name string
+			rows interface{}
+		}{csvNotificationSettingsFile, []ExportNotificationSettings{*doc.NotificationSettings}})
+	}
+
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: doc.ExportedAt})
+		if err != nil {
+			return err
+		}
+		if err := writeCSVRows(fw, f.rows); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// ImportData は JSON または CSV の ZIP を取り込む。同じ UID の課題・繰り返し設定と同じ名前の科目は上書きし、それ以外は新規作成する。
+// 不正な行はスキップして Errors に記録する。
+func (s *DataTransferService) ImportData(userID uint, data []byte) (*DataImportResult, error) {
+	if len(data) > MaxDataImportSize {
+		return nil, ErrImportTooLarge
+	}
+
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return s.importCSVArchive(userID, data)
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\uFEFF")))
+	if !bytes.HasPrefix(trimmed, []byte("{")) {
+		return nil, ErrUnsupportedImportFormat
+	}
+
+	var doc ExportDocument
+	if err := json.Unmarshal(trimmed, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImportFormat, err)
+	}
+	if doc.Version < 1 || doc.Version > ExportFormatVersion {
+		return nil, ErrUnsupportedExportVersion
+	}
+
+	result := &DataImportResult{Errors: []DataImportError{}}
+	if doc.NotificationSettings != nil {
+		s.importNotificationSettings(userID, 1, doc.NotificationSettings, result)
+	}
+	for i := range doc.Subjects {
+		s.importSubject(userID, i+1, &doc.Subjects[i], result)
+	}
+	for i := range doc.RecurringAssignments {
+		s.importRecurring(userID, i+1, &doc.RecurringAssignments[i], result)
+	}
+	for i := range doc.Assignments {
+		s.importAssignment(userID, i+1, &doc.Assignments[i], result)
+	}
+	return result, nil
+}
+
+func (s *DataTransferService) importCSVArchive(userID uint, data []byte) (*DataImportResult, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImportFormat, err)
+	}
+
+	files := make(map[string][][]string)
+	for _, f := range zr.File {
+		name := f.Name[strings.LastIndex(f.Name, "/")+1:]
+		switch name {
+		case csvSubjectsFile, csvAssignmentsFile, csvRecurringAssignmentsFile, csvNotificationSettingsFile:
+		default:
+			continue
+		}
+		if f.UncompressedSize64 > MaxDataImportSize {
+			return nil, ErrImportTooLarge
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedImportFormat, err)
+		}
+		r := csv.NewReader(io.LimitReader(rc, MaxDataImportSize))
+		r.FieldsPerRecord = -1
+		records, err := r.ReadAll()
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrUnsupportedImportFormat, name, err)
+		}
+		files[name] = records
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: no CSV files found", ErrUnsupportedImportFormat)
+	}
+
+	result := &DataImportResult{Errors: []DataImportError{}}
+
+	eachRow(files[csvNotificationSettingsFile], func(row int, get func(string) string) {
+		var item ExportNotificationSettings
+		if err := decodeCSVRow(get, &item); err != nil {
+			result.addError(ImportEntityNotificationSettings, row, err)
+			return
+		}
+		s.importNotificationSettings(userID, row, &item, result)
+	})
+	eachRow(files[csvSubjectsFile], func(row int, get func(string) string) {
+		var item ExportSubject
+		if err := decodeCSVRow(get, &item); err != nil {
+			result.addError(ImportEntitySubject, row, err)
+			return
+		}
+		s.importSubject(userID, row, &item, result)
+	})
+	eachRow(files[csvRecurringAssignmentsFile], func(row int, get func(string) string) {
+		var item ExportRecurringAssignment
+		if err := decodeCSVRow(get, &item); err != nil {
+			result.addError(ImportEntityRecurringAssignment, row, err)
+			return
+		}
+		s.importRecurring(userID, row, &item, result)
+	})
+	eachRow(files[csvAssignmentsFile], func(row int, get func(string) string) {
+		var item ExportAssignment
+		if err := decodeCSVRow(get, &item); err != nil {
+			result.addError(ImportEntityAssignment, row, err)
+			return
+		}
+		s.importAssignment(userID, row, &item, result)
+	})
+
+	return result, nil
+}
+
+func isValidPriority(priority string) bool {
+	switch priority {
+	case "low", "medium", "high":
+		return true
+	}
+	return false
+}
+
+func (s *DataTransferService) importSubject(userID uint, row int, item *ExportSubject, result *DataImportResult) {
+	fail := func(err error) { result.addError(ImportEntitySubject, row, err) }
+
+	item.Name = strings.TrimSpace(item.Name)
+	if err := validation.ValidateSubjectInput(item.Name, item.Color, item.Teacher, item.Room, item.DefaultPriority, item.DefaultReminderOffset); err != nil {
+		fail(err)
+		return
+	}
+
+	existing, err := s.subjectRepo.FindByName(userID, item.Name)
+	subject := existing
+	if err != nil {
+		subject = &models.Subject{UserID: userID}
+	}
+	applySubjectInput(subject, SubjectInput{
+		Name:                  item.Name,
+		Color:                 item.Color,
+		Teacher:               item.Teacher,
+		Room:                  item.Room,
+		DefaultPriority:       item.DefaultPriority,
+		DefaultReminderOffset: item.DefaultReminderOffset,
+	})
+	subject.IsArchived = item.IsArchived
+
+	if existing != nil {
+		if err := s.subjectRepo.Update(subject); err != nil {
+			fail(err)
+			return
+		}
+		result.Subjects.Updated++
+		return
+	}
+	if err := s.subjectRepo.Create(subject); err != nil {
+		fail(err)
+		return
+	}
+	result.Subjects.Created++
+}
+
+// importSubjectRef は課題・繰り返し設定の科目名から科目を探し、なければ作成する。
+// バージョン 1 のファイルではアーカイブ済みの課題の科目をアーカイブする。
+func (s *DataTransferService) importSubjectRef(userID uint, name string, archived bool) (*models.Subject, error) {
+	subject, err := resolveSubject(s.subjectRepo, userID, name)
+	if err != nil || subject == nil || !archived || subject.IsArchived {
+		return subject, err
+	}
+	subject.IsArchived = true
+	return subject, s.subjectRepo.Update(subject)
+}
+
+func (s *DataTransferService) importAssignment(userID uint, row int, item *ExportAssignment, result *DataImportResult) {
+	fail := func(err error) { result.addError(ImportEntityAssignment, row, err) }
+
+	if item.Priority == "" {
+		item.Priority = "medium"
+	}
+	if err := validation.ValidateAssignmentInput(item.Title, item.Description, item.Subject, item.Priority); err != nil {
+		fail(err)
+		return
+	}
+	if !isValidPriority(item.Priority) {
+		fail(errors.New("priority: low / medium / high のいずれかを指定してください"))
+		return
+	}
+	if item.DueDate.IsZero() {
+		fail(errors.New("due_date: 必須項目です"))
+		return
+	}
+	tagNames := models.ParseTagNames(item.Tags)
+	if err := validation.ValidateTags(tagNames); err != nil {
+		fail(err)
+		return
+	}
+
+	var recurringID *uint
+	if item.RecurringUID != "" {
+		recurring, err := s.recurringRepo.FindByUID(userID, item.RecurringUID)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if recurring != nil {
+			recurringID = &recurring.ID
+		}
+	}
+
+	var existing *models.Assignment
+	if item.UID != "" {
+		var err error
+		if existing, err = s.assignmentRepo.FindByUID(userID, item.UID); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	subject, err := s.importSubjectRef(userID, item.Subject, item.IsArchived)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	assignment := existing
+	if assignment == nil {
+		assignment = &models.Assignment{UserID: userID, ExternalUID: item.UID}
+	}
+	assignment.Title = item.Title
+	assignment.Description = item.Description
+	assignment.SubjectID, assignment.Subject = subjectRef(subject)
+	assignment.Priority = item.Priority
+	if !assignment.DueDate.Equal(item.DueDate) {
+		assignment.OverdueNotifiedAt = nil
+	}
+	assignment.DueDate = item.DueDate.UTC()
+	assignment.IsCompleted = item.IsCompleted
+	assignment.CompletedAt = utcPtr(item.CompletedAt)
+	if assignment.IsCompleted && assignment.CompletedAt == nil {
+		now := time.Now().UTC()
+		assignment.CompletedAt = &now
+	} else if !assignment.IsCompleted {
+		assignment.CompletedAt = nil
+	}
+	assignment.ReminderEnabled = item.ReminderEnabled
+	assignment.ReminderAt = utcPtr(item.ReminderAt)
+	assignment.UrgentReminderEnabled = item.UrgentReminderEnabled
+	assignment.ChecklistAutoComplete = item.ChecklistAutoComplete
+	assignment.RecurringAssignmentID = recurringID
+
+	if existing != nil {
+		if err := s.assignmentRepo.Update(assignment); err != nil {
+			fail(err)
+			return
+		}
+	} else if err := s.assignmentRepo.Create(assignment); err != nil {
+		fail(err)
+		return
+	}
+
+	// タグのない古い形式のファイルで既存の課題のタグを外さないよう、タグがある場合だけ置き換える
+	if existing == nil || len(tagNames) > 0 {
+		tags, err := resolveTags(s.tagRepo, userID, tagNames)
+		if err == nil {
+			err = s.tagRepo.SetAssignmentTags(assignment.ID, tags)
+		}
+		if err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	if existing != nil {
+		s.webhookService.Dispatch(userID, models.WebhookEventAssignmentUpdated, assignment)
+		result.Assignments.Updated++
+		return
+	}
+	s.webhookService.Dispatch(userID, models.WebhookEventAssignmentCreated, assignment)
+	result.Assignments.Created++
+}
+
+func (s *DataTransferService) importRecurring(userID uint, row int, item *ExportRecurringAssignment, result *DataImportResult) {
+	fail := func(err error) { result.addError(ImportEntityRecurringAssignment, row, err) }
+
+	if item.Priority == "" {
+		item.Priority = "medium"
+	}
+	if item.RecurrenceInterval < 1 {
+		item.RecurrenceInterval = 1
+	}
+	if item.EndType == "" {
+		item.EndType = models.EndTypeNever
+	}
+	if item.EditBehavior == "" {
+		item.EditBehavior = models.EditBehaviorThisOnly
+	}
+
+	if err := validation.ValidateAssignmentInput(item.Title, item.Description, item.Subject, item.Priority); err != nil {
+		fail(err)
+		return
+	}
+	if err := validation.ValidateField("checklist", item.Checklist, false); err != nil {
+		fail(err)
+		return
+	}
+	if err := validation.ValidateTags(models.ParseTagNames(item.Tags)); err != nil {
+		fail(err)
+		return
+	}
+	if !isValidPriority(item.Priority) {
+		fail(errors.New("priority: low / medium / high のいずれかを指定してください"))
+		return
+	}
+	if !isValidRecurrenceType(item.RecurrenceType) {
+		fail(errors.New("recurrence_type: 繰り返しタイプが正しくありません"))
+		return
+	}
+	if !isValidEndType(item.EndType) {
+		fail(errors.New("end_type: 終了条件が正しくありません"))
+		return
+	}
+	if _, err := time.Parse("15:04", item.DueTime); err != nil {
+		fail(errors.New("due_time: HH:MM 形式で指定してください"))
+		return
+	}
+	if item.RRule != "" {
+		if _, err := rrule.Parse(item.RRule); err != nil {
+			fail(fmt.Errorf("rrule: %v", err))
+			return
+		}
+	} else if item.RecurrenceType == models.RecurrenceCustom {
+		fail(errors.New("rrule: カスタムの繰り返しには RRULE が必要です"))
+		return
+	}
+	exdates, err := rrule.ParseExDates(item.ExDates, userLocation(s.userRepo, userID))
+	if err != nil {
+		fail(fmt.Errorf("exdates: %v", err))
+		return
+	}
+
+	var existing *models.RecurringAssignment
+	if item.UID != "" {
+		if existing, err = s.recurringRepo.FindByUID(userID, item.UID); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	subject, err := s.importSubjectRef(userID, item.Subject, false)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	recurring := existing
+	if recurring == nil {
+		recurring = &models.RecurringAssignment{UserID: userID, ExternalUID: item.UID}
+	}
+	recurring.Title = item.Title
+	recurring.Description = item.Description
+	recurring.SubjectID, recurring.Subject = subjectRef(subject)
+	recurring.Priority = item.Priority
+	recurring.RecurrenceType = item.RecurrenceType
+	recurring.RecurrenceInterval = item.RecurrenceInterval
+	recurring.RecurrenceWeekday = item.RecurrenceWeekday
+	recurring.RecurrenceDay = item.RecurrenceDay
+	recurring.RRule = item.RRule
+	recurring.ExDates = rrule.FormatExDates(exdates)
+	recurring.StartDate = utcPtr(item.StartDate)
+	recurring.DueTime = item.DueTime
+	recurring.EndType = item.EndType
+	recurring.EndCount = item.EndCount
+	recurring.EndDate = utcPtr(item.EndDate)
+	recurring.GeneratedCount = item.GeneratedCount
+	recurring.EditBehavior = item.EditBehavior
+	recurring.ReminderEnabled = item.ReminderEnabled
+	recurring.ReminderOffset = item.ReminderOffset
+	recurring.UrgentReminderEnabled = item.UrgentReminderEnabled
+	recurring.Checklist = strings.Join(models.ParseChecklistTitles(item.Checklist), "\n")
+	recurring.ChecklistAutoComplete = item.ChecklistAutoComplete
+	if existing == nil || item.Tags != "" {
+		recurring.Tags = joinTagNames(models.ParseTagNames(item.Tags))
+	}
+	recurring.IsActive = item.IsActive
+
+	if existing != nil {
+		if err := s.recurringRepo.Update(recurring); err != nil {
+			fail(err)
+			return
+		}
+		result.RecurringAssignments.Updated++
+		return
+	}
+	if err := s.recurringRepo.Create(recurring); err != nil {
+		fail(err)
+		return
+	}
+	result.RecurringAssignments.Created++
+}
+
+func (s *DataTransferService) importNotificationSettings(userID uint, row int, item *ExportNotificationSettings, result *DataImportResult) {
+	settings, err := s.notificationService.GetUserSettings(userID)
+	if err != nil {
+		result.addError(ImportEntityNotificationSettings, row, err)
+		return
+	}
+	settings.TelegramEnabled = item.TelegramEnabled
+	// ボット連携が有効な場合、Chat ID は連携コードでのみ設定する
+	if s.telegramBot == nil {
+		settings.TelegramChatID = item.TelegramChatID
+	}
+	settings.EmailEnabled = item.EmailEnabled
+	settings.NotifyOnCreate = item.NotifyOnCreate
+	if err := s.notificationService.UpdateUserSettings(userID, settings); err != nil {
+		result.addError(ImportEntityNotificationSettings, row, err)
+		return
+	}
+	result.NotificationSettings = true
+}
+
+// CSV の列は各構造体の json タグ名と同じ。日時は RFC 3339、空欄は未設定を表す。
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func csvColumns(t reflect.Type) []string {
+	columns := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		columns = append(columns, csvColumnName(t.Field(i)))
+	}
+	return columns
+}
+
+func csvColumnName(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("json"), ",")[0]
+}
+
+func writeCSVRows(w io.Writer, rows interface{}) error {
+	v := reflect.ValueOf(rows)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns(v.Type().Elem())); err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		record := make([]string, item.NumField())
+		for j := range record {
+			record[j] = formatCSVValue(item.Field(j))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatCSVValue(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339)
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	default:
+		return v.String()
+	}
+}
+
+// eachRow はヘッダー行を除いた各行を列名で引ける形にして fn に渡す。
+func eachRow(records [][]string, fn func(row int, get func(column string) string)) {
+	if len(records) == 0 {
+		return
+	}
+	index := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		index[strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF"))] = i
+	}
+	for n, record := range records[1:] {
+		fn(n+1, func(column string) string {
+			i, ok := index[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return record[i]
+		})
+	}
+}
+
+func decodeCSVRow(get func(string) string, dst interface{}) error {
+	v := reflect.ValueOf(dst).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := csvColumnName(t.Field(i))
+		raw := get(name)
+		if err := parseCSVValue(v.Field(i), raw); err != nil {
+			return fmt.Errorf("%s: %q は不正な値です", name, raw)
+		}
+	}
+	return nil
+}
+
+func parseCSVValue(field reflect.Value, raw string) error {
+	if field.Kind() != reflect.String {
+		raw = strings.TrimSpace(raw)
+	}
+	if field.Kind() == reflect.Ptr {
+		if raw == "" {
+			return nil
+		}
+		ptr := reflect.New(field.Type().Elem())
+		if err := parseCSVValue(ptr.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if field.Type() == timeType {
+		if raw == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Bool:
+		if raw == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		if raw == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	default:
+		field.SetString(raw)
+	}
+	return nil
+}
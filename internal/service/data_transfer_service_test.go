@@ -0,0 +1,457 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+func intPtr(n int) *int { return &n }
+
+// sampleExportDocument は科目・繰り返し設定・課題・通知設定をすべて含むエクスポートを返す。
+func sampleExportDocument() *ExportDocument {
+	due := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	completedAt := time.Date(2026, 4, 20, 18, 30, 0, 0, time.UTC)
+	reminderAt := time.Date(2026, 4, 30, 9, 0, 0, 0, time.UTC)
+	startDate := time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)
+	return &ExportDocument{
+		Version: ExportFormatVersion,
+		Subjects: []ExportSubject{
+			{Name: "数学", Color: "#0d6efd", Teacher: "佐藤", Room: "201", DefaultPriority: "high", DefaultReminderOffset: intPtr(60)},
+			{Name: "古典", Color: "#6c757d", IsArchived: true},
+		},
+		RecurringAssignments: []ExportRecurringAssignment{
+			{
+				UID: "weekly@example.com", Title: "単語テスト", Subject: "古典", Priority: "low",
+				RecurrenceType: models.RecurrenceCustom, RecurrenceInterval: 1, RRule: "FREQ=WEEKLY;BYDAY=MO,TH",
+				ExDates: "2026-04-09", StartDate: &startDate, DueTime: "08:30", EndType: models.EndTypeCount, EndCount: intPtr(10),
+				GeneratedCount: 2, EditBehavior: models.EditBehaviorAll, ReminderEnabled: true, ReminderOffset: intPtr(30),
+				Checklist: "範囲を確認\n暗記", Tags: "暗記,小テスト", IsActive: true,
+			},
+		},
+		Assignments: []ExportAssignment{
+			{
+				UID: "report@example.com", Title: "レポート", Description: "A4 2枚, \"引用\"あり\n改行", Subject: "数学", Priority: "high",
+				DueDate: due, ReminderEnabled: true, ReminderAt: &reminderAt, UrgentReminderEnabled: true, Tags: "提出物",
+			},
+			{
+				UID: "done@example.com", Title: "単語テスト", Subject: "古典", Priority: "low", DueDate: due.Add(-72 * time.Hour),
+				IsCompleted: true, CompletedAt: &completedAt, IsArchived: true, ChecklistAutoComplete: true, RecurringUID: "weekly@example.com",
+			},
+		},
+		NotificationSettings: &ExportNotificationSettings{TelegramEnabled: true, TelegramChatID: "4242", NotifyOnCreate: true},
+	}
+}
+
+func importDocument(t *testing.T, svc *DataTransferService, userID uint, doc *ExportDocument) *DataImportResult {
+	t.Helper()
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := svc.ImportData(userID, data)
+	if err != nil {
+		t.Fatalf("ImportData: %v", err)
+	}
+	return result
+}
+
+// exportJSON は ExportedAt を除いたエクスポートを比較用の JSON にする。
+func exportJSON(t *testing.T, svc *DataTransferService, userID uint) string {
+	t.Helper()
+	doc, err := svc.BuildExport(userID)
+	if err != nil {
+		t.Fatalf("BuildExport: %v", err)
+	}
+	doc.ExportedAt = time.Time{}
+	var buf bytes.Buffer
+	if err := svc.WriteJSON(&buf, doc); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	return buf.String()
+}
+
+func TestDataTransferRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(s *DataTransferService, w io.Writer, doc *ExportDocument) error
+	}{
+		{"JSON", (*DataTransferService).WriteJSON},
+		{"CSV の ZIP", (*DataTransferService).WriteCSVArchive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			svc := NewDataTransferService(db, nil)
+			src := createTestUser(t, db, "src@example.com")
+			dst := createTestUser(t, db, "dst@example.com")
+
+			if result := importDocument(t, svc, src.ID, sampleExportDocument()); len(result.Errors) != 0 {
+				t.Fatalf("import sample: %+v", result.Errors)
+			}
+			// このサーバーで作成した課題（UID は ID から作られる）も含める
+			createTestAssignment(t, db, &models.Assignment{UserID: src.ID, Title: "ローカル", Subject: "数学", DueDate: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)})
+
+			doc, err := svc.BuildExport(src.ID)
+			if err != nil {
+				t.Fatalf("BuildExport: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := tt.write(svc, &buf, doc); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			data := buf.Bytes()
+
+			result, err := svc.ImportData(dst.ID, data)
+			if err != nil {
+				t.Fatalf("ImportData: %v", err)
+			}
+			want := DataImportResult{
+				Subjects:             DataImportCounts{Created: 2},
+				Assignments:          DataImportCounts{Created: 3},
+				RecurringAssignments: DataImportCounts{Created: 1},
+				NotificationSettings: true,
+				Errors:               []DataImportError{},
+			}
+			if got, _ := json.Marshal(result); string(got) != mustJSON(t, want) {
+				t.Fatalf("result = %s, want %s", got, mustJSON(t, want))
+			}
+			if got, want := exportJSON(t, svc, dst.ID), exportJSON(t, svc, src.ID); got != want {
+				t.Errorf("export after import differs:\n%s\nwant:\n%s", got, want)
+			}
+
+			// 同じファイルをもう一度取り込むと、重複させずに上書きする
+			result, err = svc.ImportData(dst.ID, data)
+			if err != nil {
+				t.Fatalf("ImportData again: %v", err)
+			}
+			if result.Subjects.Created+result.Assignments.Created+result.RecurringAssignments.Created != 0 ||
+				result.Subjects.Updated != 2 || result.Assignments.Updated != 3 || result.RecurringAssignments.Updated != 1 {
+				t.Errorf("second import = %+v, want everything updated", result)
+			}
+			var count int64
+			db.Model(&models.Assignment{}).Where("user_id = ?", dst.ID).Count(&count)
+			if count != 3 {
+				t.Errorf("%d assignment(s) after importing twice, want 3", count)
+			}
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// 不正な行はスキップしてエンティティと行番号を報告し、それ以外の行は取り込む
+func TestDataImportRowErrors(t *testing.T) {
+	due := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	t.Run("JSON", func(t *testing.T) {
+		db := testutil.OpenDB(t)
+		svc := NewDataTransferService(db, nil)
+		user := createTestUser(t, db, "errors@example.com")
+
+		result := importDocument(t, svc, user.ID, &ExportDocument{
+			Version:  ExportFormatVersion,
+			Subjects: []ExportSubject{{Name: "数学"}, {Name: "英語", Color: "blue"}},
+			RecurringAssignments: []ExportRecurringAssignment{
+				{Title: "カスタム", RecurrenceType: models.RecurrenceCustom, DueTime: "08:00"},
+				{Title: "毎日", RecurrenceType: models.RecurrenceDaily, DueTime: "25:00"},
+			},
+			Assignments: []ExportAssignment{
+				{Title: "正しい課題", DueDate: due},
+				{Title: "", DueDate: due},
+				{Title: "優先度", Priority: "urgent", DueDate: due},
+				{Title: "期限なし"},
+			},
+		})
+
+		wantErrors := []struct {
+			entity string
+			row    int
+		}{
+			{ImportEntitySubject, 2},
+			{ImportEntityRecurringAssignment, 1},
+			{ImportEntityRecurringAssignment, 2},
+			{ImportEntityAssignment, 2},
+			{ImportEntityAssignment, 3},
+			{ImportEntityAssignment, 4},
+		}
+		if len(result.Errors) != len(wantErrors) {
+			t.Fatalf("errors = %+v, want %d", result.Errors, len(wantErrors))
+		}
+		for i, want := range wantErrors {
+			if got := result.Errors[i]; got.Entity != want.entity || got.Row != want.row || got.Error == "" {
+				t.Errorf("error %d = %+v, want %s row %d", i, got, want.entity, want.row)
+			}
+		}
+		if result.Subjects.Created != 1 || result.Assignments.Created != 1 || result.RecurringAssignments.Created != 0 {
+			t.Errorf("result = %+v, want the valid rows imported", result)
+		}
+	})
+
+	t.Run("CSV の ZIP", func(t *testing.T) {
+		db := testutil.OpenDB(t)
+		svc := NewDataTransferService(db, nil)
+		user := createTestUser(t, db, "csv-errors@example.com")
+
+		data := csvArchive(t, map[string]string{
+			"export/" + csvAssignmentsFile: "\uFEFFtitle,due_date,is_completed\n" +
+				"正しい課題,2026-05-01T09:00:00+09:00,false\n" +
+				"完了の値が不正,2026-05-01T09:00:00+09:00,maybe\n" +
+				"期限の形式が不正,2026/05/01,false\n",
+			"readme.txt": "取り込まないファイル",
+		})
+		result, err := svc.ImportData(user.ID, data)
+		if err != nil {
+			t.Fatalf("ImportData: %v", err)
+		}
+		if len(result.Errors) != 2 ||
+			result.Errors[0].Row != 2 || !strings.HasPrefix(result.Errors[0].Error, "is_completed:") ||
+			result.Errors[1].Row != 3 || !strings.HasPrefix(result.Errors[1].Error, "due_date:") {
+			t.Fatalf("errors = %+v", result.Errors)
+		}
+		var assignment models.Assignment
+		if err := db.Where("user_id = ?", user.ID).First(&assignment).Error; err != nil || result.Assignments.Created != 1 {
+			t.Fatalf("valid row not imported: %+v, %v", result, err)
+		}
+		if want := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC); !assignment.DueDate.Equal(want) {
+			t.Errorf("due date = %v, want %v", assignment.DueDate, want)
+		}
+	})
+}
+
+func TestDataImportVersion(t *testing.T) {
+	tests := []struct {
+		version int
+		wantErr error
+	}{
+		{0, ErrUnsupportedExportVersion},
+		{1, nil},
+		{ExportFormatVersion, nil},
+		{ExportFormatVersion + 1, ErrUnsupportedExportVersion},
+	}
+	for _, tt := range tests {
+		db := testutil.OpenDB(t)
+		svc := NewDataTransferService(db, nil)
+		user := createTestUser(t, db, "version@example.com")
+
+		data := mustJSON(t, &ExportDocument{
+			Version:     tt.version,
+			Assignments: []ExportAssignment{{Title: "課題", Subject: "古典", IsArchived: true, DueDate: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)}},
+		})
+		result, err := svc.ImportData(user.ID, []byte(data))
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("version %d: error = %v, want %v", tt.version, err, tt.wantErr)
+		}
+		if err != nil {
+			assertNoAssignments(t, db, user.ID)
+			continue
+		}
+		if result.Assignments.Created != 1 {
+			t.Errorf("version %d: result = %+v", tt.version, result)
+		}
+	}
+
+	// バージョン 1 の課題のアーカイブは科目のアーカイブとして取り込む
+	db := testutil.OpenDB(t)
+	svc := NewDataTransferService(db, nil)
+	user := createTestUser(t, db, "v1@example.com")
+	importDocument(t, svc, user.ID, &ExportDocument{
+		Version:     1,
+		Assignments: []ExportAssignment{{Title: "課題", Subject: "古典", IsArchived: true, DueDate: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)}},
+	})
+	var subject models.Subject
+	if err := db.Where("user_id = ? AND name = ?", user.ID, "古典").First(&subject).Error; err != nil || !subject.IsArchived {
+		t.Errorf("subject = %+v, %v, want archived", subject, err)
+	}
+}
+
+func TestDataImportLimits(t *testing.T) {
+	oversized := strings.Repeat("a", MaxDataImportSize+1)
+
+	tests := []struct {
+		name    string
+		data    func(t *testing.T) []byte
+		wantErr error
+	}{
+		{
+			name: "ファイルが大きすぎる",
+			data: func(t *testing.T) []byte {
+				return []byte(`{"version":3,"assignments":[{"title":"` + oversized + `"}]}`)
+			},
+			wantErr: ErrImportTooLarge,
+		},
+		{
+			name: "展開後の CSV が大きすぎる",
+			data: func(t *testing.T) []byte {
+				return csvArchive(t, map[string]string{csvAssignmentsFile: "title,due_date\n" + oversized + ",2026-05-01T00:00:00Z\n"})
+			},
+			wantErr: ErrImportTooLarge,
+		},
+		{
+			name: "展開後のサイズを偽った CSV",
+			data: func(t *testing.T) []byte {
+				return rawZipEntry(t, csvAssignmentsFile, "title,due_date\n課題,2026-05-01T00:00:00Z\n", 10)
+			},
+			wantErr: ErrUnsupportedImportFormat,
+		},
+		{
+			name:    "CSV を含まない ZIP",
+			data:    func(t *testing.T) []byte { return csvArchive(t, map[string]string{"readme.txt": "hello"}) },
+			wantErr: ErrUnsupportedImportFormat,
+		},
+		{
+			name:    "JSON でも ZIP でもない",
+			data:    func(t *testing.T) []byte { return []byte("title,due_date\n") },
+			wantErr: ErrUnsupportedImportFormat,
+		},
+		{
+			name:    "壊れた JSON",
+			data:    func(t *testing.T) []byte { return []byte(`{"version": 3, "assignments": [`) },
+			wantErr: ErrUnsupportedImportFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			svc := NewDataTransferService(db, nil)
+			user := createTestUser(t, db, "limits@example.com")
+
+			if _, err := svc.ImportData(user.ID, tt.data(t)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ImportData error = %v, want %v", err, tt.wantErr)
+			}
+			assertNoAssignments(t, db, user.ID)
+		})
+	}
+}
+
+func assertNoAssignments(t *testing.T, db *gorm.DB, userID uint) {
+	t.Helper()
+	var count int64
+	db.Model(&models.Assignment{}).Where("user_id = ?", userID).Count(&count)
+	if count != 0 {
+		t.Errorf("%d assignment(s) imported, want none", count)
+	}
+}
+
+// csvArchive はファイル名と内容から ZIP を作る。
+func csvArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// rawZipEntry はヘッダーの展開後のサイズを size と偽ったエントリを1つだけ含む ZIP を作る。
+func rawZipEntry(t *testing.T, name, content string, size uint64) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE([]byte(content)),
+		CompressedSize64:   uint64(len(content)),
+		UncompressedSize64: size,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// ボット連携が有効な場合、他人の Chat ID を取り込んで通知を受け取らせることはできない
+func TestDataImportTelegramChatID(t *testing.T) {
+	const csvSettings = "telegram_enabled,telegram_chat_id,email_enabled,notify_on_create\ntrue,4242,false,true\n"
+
+	tests := []struct {
+		name       string
+		botEnabled bool
+		csv        bool
+		wantChatID string
+	}{
+		{"ボット連携なし JSON", false, false, "4242"},
+		{"ボット連携なし CSV", false, true, "4242"},
+		{"ボット連携あり JSON", true, false, "1111"},
+		{"ボット連携あり CSV", true, true, "1111"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			user := createTestUser(t, db, "telegram@example.com")
+			notifications := NewNotificationService(db, config.NotificationConfig{})
+			if err := notifications.UpdateUserSettings(user.ID, &models.UserNotificationSettings{TelegramChatID: "1111"}); err != nil {
+				t.Fatalf("UpdateUserSettings: %v", err)
+			}
+
+			var bot *TelegramBotService
+			if tt.botEnabled {
+				bot = NewTelegramBotService(db, config.NotificationConfig{TelegramBotToken: "123:test-token"}, notifications)
+			}
+			svc := NewDataTransferService(db, bot)
+
+			var result *DataImportResult
+			if tt.csv {
+				var err error
+				result, err = svc.ImportData(user.ID, csvArchive(t, map[string]string{csvNotificationSettingsFile: csvSettings}))
+				if err != nil {
+					t.Fatalf("ImportData: %v", err)
+				}
+			} else {
+				result = importDocument(t, svc, user.ID, &ExportDocument{
+					Version:              ExportFormatVersion,
+					NotificationSettings: &ExportNotificationSettings{TelegramEnabled: true, TelegramChatID: "4242", NotifyOnCreate: true},
+				})
+			}
+			if !result.NotificationSettings || len(result.Errors) != 0 {
+				t.Fatalf("result = %+v", result)
+			}
+
+			settings, err := notifications.GetUserSettings(user.ID)
+			if err != nil {
+				t.Fatalf("GetUserSettings: %v", err)
+			}
+			if settings.TelegramChatID != tt.wantChatID || !settings.TelegramEnabled || !settings.NotifyOnCreate {
+				t.Errorf("settings = %+v, want chat ID %q", settings, tt.wantChatID)
+			}
+		})
+	}
+}
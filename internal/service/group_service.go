@@ -0,0 +1,720 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/validation"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrGroupNotFound           = errors.New("group not found")
+	ErrGroupAssignmentNotFound = errors.New("group assignment not found")
+	ErrNotTeacher              = errors.New("only teachers can do this")
+	ErrInvalidJoinCode         = errors.New("invalid join code")
+	ErrAlreadyMember           = errors.New("already a member of this group")
+	ErrLastTeacher             = errors.New("the last teacher cannot leave the group")
+	ErrCannotPromote           = errors.New("only teacher or admin accounts can become group teachers")
+)
+
+// joinCodeAlphabet は読み間違えやすい文字 (0, O, 1, I) を除いた英数字。
+const joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const joinCodeLength = 8
+
+func generateJoinCode() (string, error) {
+	b := make([]byte, joinCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = joinCodeAlphabet[int(b[i])%len(joinCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// 提出状況
+const (
+	ProgressCompleted     = "completed"      // 期限までに完了
+	ProgressCompletedLate = "completed_late" // 期限を過ぎてから完了
+	ProgressPending       = "pending"        // 未完了（期限前）
+	ProgressOverdue       = "overdue"        // 未完了のまま期限切れ
+	ProgressDeleted       = "deleted"        // 生徒が課題を削除した
+	ProgressNotAssigned   = "not_assigned"   // 期限後に参加したため配布していない
+)
+
+// GroupAssignmentInput は配布する課題の入力。
+type GroupAssignmentInput struct {
+	Title       string
+	Description string
+	Subject     string
+	Priority    string
+	DueDate     time.Time
+}
+
+// GroupAssignmentSummary は配布した課題と生徒の完了数。
+type GroupAssignmentSummary struct {
+	models.GroupAssignment
+	Total     int64 `json:"total"`
+	Completed int64 `json:"completed"`
+}
+
+// MemberProgress は生徒1人の提出状況。
+type MemberProgress struct {
+	UserID       uint       `json:"user_id"`
+	Name         string     `json:"name"`
+	Email        string     `json:"email"`
+	Status       string     `json:"status"`
+	AssignmentID uint       `json:"assignment_id,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+}
+
+// GroupAssignmentProgress は配布した課題の提出状況。生徒は現在のメンバーだけを含める。
+type GroupAssignmentProgress struct {
+	Assignment     *models.GroupAssignment `json:"assignment"`
+	Members        []MemberProgress        `json:"members"`
+	Total          int                     `json:"total"`
+	Completed      int                     `json:"completed"`
+	CompletedLate  int                     `json:"completed_late"`
+	Pending        int                     `json:"pending"`
+	Overdue        int                     `json:"overdue"`
+	CompletionRate float64                 `json:"completion_rate"`
+}
+
+type GroupService struct {
+	db             *gorm.DB
+	groupRepo      *repository.GroupRepository
+	assignmentRepo *repository.AssignmentRepository
+	subjectRepo    *repository.SubjectRepository
+	userRepo       *repository.UserRepository
+	webhookService *WebhookService
+}
+
+func NewGroupService(db *gorm.DB) *GroupService {
+	return &GroupService{
+		db:             db,
+		groupRepo:      repository.NewGroupRepository(db),
+		assignmentRepo: repository.NewAssignmentRepository(db),
+		subjectRepo:    repository.NewSubjectRepository(db),
+		userRepo:       repository.NewUserRepository(db),
+		webhookService: NewWebhookService(db),
+	}
+}
+
+// withTx は tx でデータベースを操作する GroupService を返す。
+func (s *GroupService) withTx(tx *gorm.DB) *GroupService {
+	return &GroupService{
+		db:             tx,
+		groupRepo:      repository.NewGroupRepository(tx),
+		assignmentRepo: repository.NewAssignmentRepository(tx),
+		subjectRepo:    repository.NewSubjectRepository(tx),
+		userRepo:       repository.NewUserRepository(tx),
+		webhookService: s.webhookService,
+	}
+}
+
+// ListForUser はユーザーが参加しているグループを参加順に返す。
+func (s *GroupService) ListForUser(userID uint) ([]models.Membership, error) {
+	return s.groupRepo.FindMembershipsByUserID(userID)
+}
+
+// Create はグループを作成し、作成したユーザーを教師として参加させる。教師・管理者だけが作成できる。
+func (s *GroupService) Create(userID uint, name, description string) (*models.Group, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.CanCreateGroups() {
+		return nil, ErrNotTeacher
+	}
+	name = strings.TrimSpace(name)
+	if err := validation.ValidateField("group", name, true); err != nil {
+		return nil, err
+	}
+	if err := validation.ValidateField("description", description, false); err != nil {
+		return nil, err
+	}
+
+	code, err := generateJoinCode()
+	if err != nil {
+		return nil, err
+	}
+	group := &models.Group{Name: name, Description: description, JoinCode: code}
+	if err := s.groupRepo.Create(group); err != nil {
+		return nil, err
+	}
+	membership := &models.Membership{GroupID: group.ID, UserID: userID, Role: models.MembershipRoleTeacher}
+	if err := s.groupRepo.CreateMembership(membership); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// Get はユーザーが参加しているグループと参加情報を返す。参加していないグループは見つからない扱いにする。
+func (s *GroupService) Get(userID, groupID uint) (*models.Group, *models.Membership, error) {
+	membership, err := s.groupRepo.FindMembership(groupID, userID)
+	if err != nil {
+		return nil, nil, ErrGroupNotFound
+	}
+	group, err := s.groupRepo.FindByID(groupID)
+	if err != nil {
+		return nil, nil, ErrGroupNotFound
+	}
+	return group, membership, nil
+}
+
+// getAsTeacher はユーザーが教師として参加しているグループを返す。
+func (s *GroupService) getAsTeacher(userID, groupID uint) (*models.Group, error) {
+	group, membership, err := s.Get(userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !membership.IsTeacher() {
+		return nil, ErrNotTeacher
+	}
+	return group, nil
+}
+
+// Members はグループのメンバーを教師・生徒の順に返す。教師だけが見られる。
+func (s *GroupService) Members(userID, groupID uint) ([]models.Membership, error) {
+	if _, err := s.getAsTeacher(userID, groupID); err != nil {
+		return nil, err
+	}
+	return s.groupRepo.FindMembers(groupID)
+}
+
+// Join は参加コードでグループに参加する。アカウントの役割にかかわらず生徒として参加し（教師にするには PromoteMember を使う）、
+// 期限前の配布済みの課題を配る（抜ける前に完了していた課題は配り直さない）。参加と配布は1つのトランザクションで行う。
+func (s *GroupService) Join(userID uint, code string) (*models.Membership, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return nil, ErrInvalidJoinCode
+	}
+	group, err := s.groupRepo.FindByJoinCode(code)
+	if err != nil {
+		return nil, ErrInvalidJoinCode
+	}
+	if _, err := s.groupRepo.FindMembership(group.ID, userID); err == nil {
+		return nil, ErrAlreadyMember
+	}
+
+	membership := &models.Membership{GroupID: group.ID, UserID: userID, Role: models.MembershipRoleStudent}
+	var distributed []*models.Assignment
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		txs := s.withTx(tx)
+		if err := txs.groupRepo.CreateMembership(membership); err != nil {
+			return ErrAlreadyMember
+		}
+
+		assignments, err := txs.groupRepo.FindAssignments(group.ID)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		for i := range assignments {
+			if !assignments[i].DueDate.After(now) {
+				continue
+			}
+			has, err := txs.groupRepo.HasCopy(assignments[i].ID, userID)
+			if err != nil {
+				return err
+			}
+			if has {
+				continue
+			}
+			assignment, err := txs.distribute(&assignments[i], userID)
+			if err != nil {
+				return err
+			}
+			distributed = append(distributed, assignment)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	membership.Group = group
+
+	s.dispatchDistributed(distributed)
+	return membership, nil
+}
+
+// Leave はグループから抜ける。最後の教師は抜けられない。
+func (s *GroupService) Leave(userID, groupID uint) error {
+	_, membership, err := s.Get(userID, groupID)
+	if err != nil {
+		return err
+	}
+	return s.removeMembership(membership)
+}
+
+// RemoveMember は教師がメンバーをグループから外す。
+func (s *GroupService) RemoveMember(userID, groupID, memberUserID uint) error {
+	if _, err := s.getAsTeacher(userID, groupID); err != nil {
+		return err
+	}
+	membership, err := s.groupRepo.FindMembership(groupID, memberUserID)
+	if err != nil {
+		return ErrGroupNotFound
+	}
+	return s.removeMembership(membership)
+}
+
+// PromoteMember は教師が生徒として参加しているメンバーを教師にする。教師・管理者のアカウントだけを教師にできる。
+// 生徒として受け取った課題はそのまま残す。
+func (s *GroupService) PromoteMember(userID, groupID, memberUserID uint) error {
+	if _, err := s.getAsTeacher(userID, groupID); err != nil {
+		return err
+	}
+	membership, err := s.groupRepo.FindMembership(groupID, memberUserID)
+	if err != nil {
+		return ErrGroupNotFound
+	}
+	if membership.IsTeacher() {
+		return nil
+	}
+	member, err := s.userRepo.FindByID(memberUserID)
+	if err != nil {
+		return ErrGroupNotFound
+	}
+	if !member.CanCreateGroups() {
+		return ErrCannotPromote
+	}
+	membership.Role = models.MembershipRoleTeacher
+	return s.groupRepo.UpdateMembership(membership)
+}
+
+// removeMembership は参加を取り消し、生徒に配った未完了の課題を削除する。完了した課題は生徒の記録として残す。
+// 最後の教師かの確認と削除は、グループの行をロックした1つのトランザクションで行う（同時に2人の教師が抜けて教師がいなくならないように）。
+func (s *GroupService) removeMembership(membership *models.Membership) error {
+	var copies []models.Assignment
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txs := s.withTx(tx)
+		if _, err := txs.groupRepo.FindByIDForUpdate(membership.GroupID); err != nil {
+			return ErrGroupNotFound
+		}
+		current, err := txs.groupRepo.FindMembership(membership.GroupID, membership.UserID)
+		if err != nil {
+			return ErrGroupNotFound
+		}
+		if current.IsTeacher() {
+			count, err := txs.groupRepo.CountMembers(current.GroupID, models.MembershipRoleTeacher)
+			if err != nil {
+				return err
+			}
+			if count <= 1 {
+				return ErrLastTeacher
+			}
+		}
+
+		copies, err = txs.groupRepo.FindPendingCopiesByUserID(current.GroupID, current.UserID)
+		if err != nil {
+			return err
+		}
+		if err := txs.groupRepo.DeleteMembership(current.ID); err != nil {
+			return err
+		}
+		return txs.deleteCopies(copies)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.dispatchDeleted(copies)
+	return nil
+}
+
+// RegenerateJoinCode は参加コードを作り直す。以前のコードでは参加できなくなる。
+func (s *GroupService) RegenerateJoinCode(userID, groupID uint) (*models.Group, error) {
+	group, err := s.getAsTeacher(userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	code, err := generateJoinCode()
+	if err != nil {
+		return nil, err
+	}
+	group.JoinCode = code
+	if err := s.groupRepo.Update(group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// Delete はグループを削除する。生徒に配った未完了の課題は削除し、完了した課題は残す。
+// 削除は1つのトランザクションで行い、Webhook はコミット後に送る。
+func (s *GroupService) Delete(userID, groupID uint) error {
+	if _, err := s.getAsTeacher(userID, groupID); err != nil {
+		return err
+	}
+	var deleted []models.Assignment
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txs := s.withTx(tx)
+		if _, err := txs.groupRepo.FindByIDForUpdate(groupID); err != nil {
+			return ErrGroupNotFound
+		}
+		assignments, err := txs.groupRepo.FindAssignments(groupID)
+		if err != nil {
+			return err
+		}
+		for _, assignment := range assignments {
+			copies, err := txs.deletePendingCopies(assignment.ID)
+			if err != nil {
+				return err
+			}
+			deleted = append(deleted, copies...)
+		}
+		return txs.groupRepo.Delete(groupID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.dispatchDeleted(deleted)
+	return nil
+}
+
+// ListAssignments は配布した課題を期限の近い順に、生徒の完了数と合わせて返す。教師だけが見られる。
+func (s *GroupService) ListAssignments(userID, groupID uint) ([]GroupAssignmentSummary, error) {
+	if _, err := s.getAsTeacher(userID, groupID); err != nil {
+		return nil, err
+	}
+	assignments, err := s.groupRepo.FindAssignments(groupID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.groupRepo.CountCopies(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]GroupAssignmentSummary, 0, len(assignments))
+	for _, assignment := range assignments {
+		count := counts[assignment.ID]
+		summaries = append(summaries, GroupAssignmentSummary{
+			GroupAssignment: assignment,
+			Total:           count.Total,
+			Completed:       count.Completed,
+		})
+	}
+	return summaries, nil
+}
+
+func validateGroupAssignmentInput(input *GroupAssignmentInput) error {
+	input.Title = strings.TrimSpace(input.Title)
+	input.Subject = strings.TrimSpace(input.Subject)
+	if input.Priority == "" {
+		input.Priority = "medium"
+	}
+	if err := validation.ValidateAssignmentInput(input.Title, input.Description, input.Subject, input.Priority); err != nil {
+		return err
+	}
+	switch input.Priority {
+	case "low", "medium", "high":
+	default:
+		return &validation.ValidationError{Field: "priority", Message: "low・medium・high のいずれかを指定してください"}
+	}
+	return nil
+}
+
+// CreateAssignment はグループに課題を配布する。生徒ごとに課題を作成し、配布元を GroupAssignmentID で参照させる。
+// 配布元と生徒の課題は1つのトランザクションで作成し、Webhook はコミット後に送る。
+func (s *GroupService) CreateAssignment(userID, groupID uint, input GroupAssignmentInput) (*models.GroupAssignment, error) {
+	if _, err := s.getAsTeacher(userID, groupID); err != nil {
+		return nil, err
+	}
+	if err := validateGroupAssignmentInput(&input); err != nil {
+		return nil, err
+	}
+
+	source := &models.GroupAssignment{
+		GroupID:     groupID,
+		CreatedByID: userID,
+		Title:       input.Title,
+		Description: input.Description,
+		Subject:     input.Subject,
+		Priority:    input.Priority,
+		DueDate:     input.DueDate.UTC(),
+	}
+	var distributed []*models.Assignment
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txs := s.withTx(tx)
+		if err := txs.groupRepo.CreateAssignment(source); err != nil {
+			return err
+		}
+
+		members, err := txs.groupRepo.FindMembers(groupID)
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			if member.IsTeacher() {
+				continue
+			}
+			assignment, err := txs.distribute(source, member.UserID)
+			if err != nil {
+				return err
+			}
+			distributed = append(distributed, assignment)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.dispatchDistributed(distributed)
+	return source, nil
+}
+
+// distribute は生徒に配布元の課題のコピーを作成する。科目は生徒の科目を名前で探し、リマインダーは科目の既定に従う。
+// Webhook は送らないため、呼び出し側がコミット後に dispatchDistributed で送る。
+func (s *GroupService) distribute(source *models.GroupAssignment, userID uint) (*models.Assignment, error) {
+	subject, err := resolveSubject(s.subjectRepo, userID, source.Subject)
+	if err != nil {
+		return nil, err
+	}
+	subjectID, subjectName := subjectRef(subject)
+	reminderAt := subject.DefaultReminderAt(source.DueDate)
+	sourceID := source.ID
+	assignment := &models.Assignment{
+		UserID:                userID,
+		Title:                 source.Title,
+		Description:           source.Description,
+		SubjectID:             subjectID,
+		Subject:               subjectName,
+		Priority:              source.Priority,
+		DueDate:               source.DueDate,
+		ReminderEnabled:       reminderAt != nil,
+		ReminderAt:            reminderAt,
+		UrgentReminderEnabled: true,
+		GroupAssignmentID:     &sourceID,
+	}
+	if err := s.assignmentRepo.Create(assignment); err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// dispatchDistributed は distribute で作成した課題の assignment.created の Webhook を送る。
+func (s *GroupService) dispatchDistributed(assignments []*models.Assignment) {
+	for _, assignment := range assignments {
+		s.webhookService.Dispatch(assignment.UserID, models.WebhookEventAssignmentCreated, assignment)
+	}
+}
+
+// GetAssignment は配布した課題を返す。教師だけが見られる。
+func (s *GroupService) GetAssignment(userID, groupID, assignmentID uint) (*models.GroupAssignment, error) {
+	if _, err := s.getAsTeacher(userID, groupID); err != nil {
+		return nil, err
+	}
+	assignment, err := s.groupRepo.FindAssignmentByID(assignmentID)
+	if err != nil || assignment.GroupID != groupID {
+		return nil, ErrGroupAssignmentNotFound
+	}
+	return assignment, nil
+}
+
+// UpdateAssignment は配布元の課題を更新し、editBehavior に従って生徒の課題に反映する。
+// 繰り返し課題の編集と同じく、配布元を繰り返し設定、生徒の課題を生成した課題に見立てる。生徒が完了した課題はどの場合も変更しない。
+//   - EditBehaviorThisOnly: 配布元だけを更新する（これから参加する生徒に配る課題だけが変わる）
+//   - EditBehaviorThisAndFuture: 配布元と、生徒の未完了の課題のうち期限を過ぎていないものを更新する
+//   - EditBehaviorAll: 配布元と、期限切れを含む生徒の未完了の課題をすべて更新する
+//
+// 配布元と生徒の課題は1つのトランザクションで更新し、Webhook はコミット後に送る。更新した生徒の課題の数を返す。
+func (s *GroupService) UpdateAssignment(userID, groupID, assignmentID uint, input GroupAssignmentInput, editBehavior string) (*models.GroupAssignment, int, error) {
+	source, err := s.GetAssignment(userID, groupID, assignmentID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := validateGroupAssignmentInput(&input); err != nil {
+		return nil, 0, err
+	}
+
+	source.Title = input.Title
+	source.Description = input.Description
+	source.Subject = input.Subject
+	source.Priority = input.Priority
+	source.DueDate = input.DueDate.UTC()
+
+	var updated []*models.Assignment
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		txs := s.withTx(tx)
+		if err := txs.groupRepo.UpdateAssignment(source); err != nil {
+			return err
+		}
+
+		var includeOverdue bool
+		switch editBehavior {
+		case models.EditBehaviorThisAndFuture:
+			includeOverdue = false
+		case models.EditBehaviorAll:
+			includeOverdue = true
+		default:
+			return nil
+		}
+
+		copies, err := txs.groupRepo.FindCopies(source.ID, false)
+		if err != nil {
+			return err
+		}
+		for i := range copies {
+			a := &copies[i]
+			if a.IsCompleted || (a.IsOverdue() && !includeOverdue) {
+				continue
+			}
+			subject, err := resolveSubject(txs.subjectRepo, a.UserID, source.Subject)
+			if err != nil {
+				return err
+			}
+			a.Title = source.Title
+			a.Description = source.Description
+			a.SubjectID, a.Subject = subjectRef(subject)
+			a.Priority = source.Priority
+			if !a.DueDate.Equal(source.DueDate) {
+				a.OverdueNotifiedAt = nil
+			}
+			a.DueDate = source.DueDate
+			if err := txs.assignmentRepo.Update(a); err != nil {
+				return err
+			}
+			updated = append(updated, a)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, a := range updated {
+		s.webhookService.Dispatch(a.UserID, models.WebhookEventAssignmentUpdated, a)
+	}
+	return source, len(updated), nil
+}
+
+// DeleteAssignment は配布した課題を削除する。生徒の未完了の課題は削除し、完了した課題は残す。
+func (s *GroupService) DeleteAssignment(userID, groupID, assignmentID uint) error {
+	source, err := s.GetAssignment(userID, groupID, assignmentID)
+	if err != nil {
+		return err
+	}
+	var deleted []models.Assignment
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		txs := s.withTx(tx)
+		deleted, err = txs.deletePendingCopies(source.ID)
+		if err != nil {
+			return err
+		}
+		return txs.groupRepo.DeleteAssignment(source.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.dispatchDeleted(deleted)
+	return nil
+}
+
+// deletePendingCopies は配布した課題の生徒の未完了の課題を削除し、削除した課題を返す。
+func (s *GroupService) deletePendingCopies(groupAssignmentID uint) ([]models.Assignment, error) {
+	copies, err := s.groupRepo.FindCopies(groupAssignmentID, false)
+	if err != nil {
+		return nil, err
+	}
+	pending := copies[:0]
+	for _, a := range copies {
+		if !a.IsCompleted {
+			pending = append(pending, a)
+		}
+	}
+	if err := s.deleteCopies(pending); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// deleteCopies は生徒の課題を削除する。Webhook は送らないため、呼び出し側がコミット後に dispatchDeleted で送る。
+func (s *GroupService) deleteCopies(copies []models.Assignment) error {
+	for i := range copies {
+		if err := s.assignmentRepo.Delete(copies[i].ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatchDeleted は deleteCopies で削除した課題の assignment.deleted の Webhook を送る。
+func (s *GroupService) dispatchDeleted(assignments []models.Assignment) {
+	for i := range assignments {
+		s.webhookService.Dispatch(assignments[i].UserID, models.WebhookEventAssignmentDeleted, &assignments[i])
+	}
+}
+
+// Progress は配布した課題の生徒ごとの提出状況を返す。教師だけが見られる。
+func (s *GroupService) Progress(userID, groupID, assignmentID uint) (*GroupAssignmentProgress, error) {
+	source, err := s.GetAssignment(userID, groupID, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.groupRepo.FindMembers(groupID)
+	if err != nil {
+		return nil, err
+	}
+	copies, err := s.groupRepo.FindCopies(source.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	// 抜けてから参加し直した生徒は削除した課題と新しい課題を持つので、削除していない方を使う
+	byUser := make(map[uint]*models.Assignment, len(copies))
+	for i := range copies {
+		a := &copies[i]
+		if existing, ok := byUser[a.UserID]; ok && !existing.DeletedAt.Valid {
+			continue
+		}
+		byUser[a.UserID] = a
+	}
+
+	progress := &GroupAssignmentProgress{Assignment: source, Members: []MemberProgress{}}
+	for _, member := range members {
+		if member.IsTeacher() {
+			continue
+		}
+		entry := MemberProgress{UserID: member.UserID, Status: ProgressNotAssigned}
+		if member.User != nil {
+			entry.Name = member.User.Name
+			entry.Email = member.User.Email
+		}
+		if a, ok := byUser[member.UserID]; ok {
+			entry.AssignmentID = a.ID
+			entry.CompletedAt = a.CompletedAt
+			switch {
+			case a.DeletedAt.Valid:
+				entry.Status = ProgressDeleted
+			case a.IsCompleted && a.CompletedAt != nil && a.CompletedAt.After(a.DueDate):
+				entry.Status = ProgressCompletedLate
+				progress.CompletedLate++
+			case a.IsCompleted:
+				entry.Status = ProgressCompleted
+				progress.Completed++
+			case a.IsOverdue():
+				entry.Status = ProgressOverdue
+				progress.Overdue++
+			default:
+				entry.Status = ProgressPending
+				progress.Pending++
+			}
+			progress.Total++
+		}
+		progress.Members = append(progress.Members, entry)
+	}
+	if progress.Total > 0 {
+		progress.CompletionRate = float64(progress.Completed+progress.CompletedLate) / float64(progress.Total) * 100
+	}
+	return progress, nil
+}
@@ -0,0 +1,500 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// createTestTeacher は教師のアカウントを作る。
+func createTestTeacher(t *testing.T, db *gorm.DB, email string) *models.User {
+	t.Helper()
+	user := createTestUser(t, db, email)
+	if err := db.Model(user).Update("role", "teacher").Error; err != nil {
+		t.Fatalf("update role: %v", err)
+	}
+	return user
+}
+
+// groupCopies は配布した課題から作った生徒ごとの課題を返す。
+func groupCopies(t *testing.T, db *gorm.DB, source *models.GroupAssignment) map[uint]models.Assignment {
+	t.Helper()
+	var assignments []models.Assignment
+	if err := db.Where("group_assignment_id = ?", source.ID).Find(&assignments).Error; err != nil {
+		t.Fatalf("find copies: %v", err)
+	}
+	copies := make(map[uint]models.Assignment, len(assignments))
+	for _, a := range assignments {
+		copies[a.UserID] = a
+	}
+	return copies
+}
+
+func TestGroupFanOutAndMembership(t *testing.T) {
+	db := testutil.OpenDB(t)
+	teacher := createTestTeacher(t, db, "teacher@example.com")
+	alice := createTestUser(t, db, "alice@example.com")
+	bob := createTestUser(t, db, "bob@example.com")
+	svc := NewGroupService(db)
+
+	if _, err := svc.Create(alice.ID, "2年1組", ""); !errors.Is(err, ErrNotTeacher) {
+		t.Errorf("student creating a group: err = %v, want %v", err, ErrNotTeacher)
+	}
+	group, err := svc.Create(teacher.ID, "2年1組", "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(group.JoinCode) != joinCodeLength {
+		t.Errorf("join code = %q", group.JoinCode)
+	}
+
+	membership, err := svc.Join(alice.ID, " "+strings.ToLower(group.JoinCode)+" ")
+	if err != nil || membership.Role != models.MembershipRoleStudent {
+		t.Fatalf("Join = %+v, %v", membership, err)
+	}
+	if _, err := svc.Join(alice.ID, group.JoinCode); !errors.Is(err, ErrAlreadyMember) {
+		t.Errorf("joining twice: err = %v, want %v", err, ErrAlreadyMember)
+	}
+	if _, err := svc.Join(bob.ID, "WRONG"); !errors.Is(err, ErrInvalidJoinCode) {
+		t.Errorf("wrong code: err = %v, want %v", err, ErrInvalidJoinCode)
+	}
+	if _, err := svc.CreateAssignment(alice.ID, group.ID, GroupAssignmentInput{Title: "x", DueDate: time.Now().Add(time.Hour)}); !errors.Is(err, ErrNotTeacher) {
+		t.Errorf("student distributing: err = %v, want %v", err, ErrNotTeacher)
+	}
+
+	// 生徒の科目の既定のリマインダーが配布した課題に付く
+	offset := 60
+	db.Create(&models.Subject{UserID: alice.ID, Name: "数学", Color: models.DefaultSubjectColor, DefaultReminderOffset: &offset})
+
+	due := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+	source, err := svc.CreateAssignment(teacher.ID, group.ID, GroupAssignmentInput{Title: "問題集 p.10", Subject: "数学", DueDate: due})
+	if err != nil {
+		t.Fatalf("CreateAssignment: %v", err)
+	}
+	past, _ := svc.CreateAssignment(teacher.ID, group.ID, GroupAssignmentInput{Title: "締め切り済み", DueDate: time.Now().Add(-time.Hour)})
+
+	copies := groupCopies(t, db, source)
+	if len(copies) != 1 {
+		t.Fatalf("%d copies, want 1 (teachers get none)", len(copies))
+	}
+	got := copies[alice.ID]
+	if got.Title != "問題集 p.10" || got.Priority != "medium" || got.SubjectID == nil || !got.ReminderEnabled ||
+		got.ReminderAt == nil || !got.ReminderAt.Equal(due.Add(-time.Hour)) || *got.GroupAssignmentID != source.ID {
+		t.Errorf("copy = %+v", got)
+	}
+
+	// 後から参加した生徒には期限前の課題だけを配る
+	if _, err := svc.Join(bob.ID, group.JoinCode); err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	if _, ok := groupCopies(t, db, source)[bob.ID]; !ok {
+		t.Error("late joiner did not receive the pending assignment")
+	}
+	if _, ok := groupCopies(t, db, past)[bob.ID]; ok {
+		t.Error("late joiner received an assignment past its due date")
+	}
+
+	// 抜けると未完了の課題は消え、完了した課題は残る
+	NewAssignmentService(db).ToggleComplete(alice.ID, got.ID)
+	if err := svc.Leave(alice.ID, group.ID); err != nil {
+		t.Fatalf("Leave: %v", err)
+	}
+	if err := svc.RemoveMember(teacher.ID, group.ID, bob.ID); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	copies = groupCopies(t, db, source)
+	if _, ok := copies[bob.ID]; ok || len(copies) != 1 || !copies[alice.ID].IsCompleted {
+		t.Errorf("copies after leaving = %+v", copies)
+	}
+
+	// 参加し直しても完了した課題は配り直さない
+	svc.Join(alice.ID, group.JoinCode)
+	if copies := groupCopies(t, db, source); len(copies) != 1 {
+		t.Errorf("%d copies after rejoining, want 1", len(copies))
+	}
+
+	if err := svc.Leave(teacher.ID, group.ID); !errors.Is(err, ErrLastTeacher) {
+		t.Errorf("last teacher leaving: err = %v, want %v", err, ErrLastTeacher)
+	}
+	oldCode := group.JoinCode
+	if group, err = svc.RegenerateJoinCode(teacher.ID, group.ID); err != nil || group.JoinCode == oldCode {
+		t.Errorf("RegenerateJoinCode = %+v, %v", group, err)
+	}
+	if _, err := svc.Join(bob.ID, oldCode); !errors.Is(err, ErrInvalidJoinCode) {
+		t.Errorf("joining with the old code: err = %v, want %v", err, ErrInvalidJoinCode)
+	}
+}
+
+// 参加コードを知っている教師のアカウントも生徒として参加し、教師にするには既存の教師の操作が要る
+func TestGroupJoinAsStudentAndPromote(t *testing.T) {
+	db := testutil.OpenDB(t)
+	owner := createTestTeacher(t, db, "owner@example.com")
+	coTeacher := createTestTeacher(t, db, "co-teacher@example.com")
+	student := createTestUser(t, db, "student@example.com")
+	svc := NewGroupService(db)
+
+	group, _ := svc.Create(owner.ID, "1年3組", "")
+	membership, err := svc.Join(coTeacher.ID, group.JoinCode)
+	if err != nil || membership.Role != models.MembershipRoleStudent {
+		t.Fatalf("Join with a teacher account = %+v, %v; want a student membership", membership, err)
+	}
+	svc.Join(student.ID, group.JoinCode)
+
+	if err := svc.Delete(coTeacher.ID, group.ID); !errors.Is(err, ErrNotTeacher) {
+		t.Errorf("teacher account deleting a group it joined: err = %v, want %v", err, ErrNotTeacher)
+	}
+	if err := svc.RemoveMember(coTeacher.ID, group.ID, student.ID); !errors.Is(err, ErrNotTeacher) {
+		t.Errorf("teacher account removing a member: err = %v, want %v", err, ErrNotTeacher)
+	}
+	if err := svc.PromoteMember(coTeacher.ID, group.ID, coTeacher.ID); !errors.Is(err, ErrNotTeacher) {
+		t.Errorf("promoting oneself: err = %v, want %v", err, ErrNotTeacher)
+	}
+	if err := svc.PromoteMember(owner.ID, group.ID, student.ID); !errors.Is(err, ErrCannotPromote) {
+		t.Errorf("promoting a student account: err = %v, want %v", err, ErrCannotPromote)
+	}
+
+	if err := svc.PromoteMember(owner.ID, group.ID, coTeacher.ID); err != nil {
+		t.Fatalf("PromoteMember: %v", err)
+	}
+	if _, membership, _ := svc.Get(coTeacher.ID, group.ID); !membership.IsTeacher() {
+		t.Errorf("membership after promotion = %+v", membership)
+	}
+	if _, err := svc.Members(coTeacher.ID, group.ID); err != nil {
+		t.Errorf("co-teacher listing members: %v", err)
+	}
+	// 教師が2人になったので、作成した教師も抜けられる
+	if err := svc.Leave(owner.ID, group.ID); err != nil {
+		t.Errorf("Leave with another teacher: %v", err)
+	}
+}
+
+// failAssignmentCreates は userID の課題の作成を失敗させる。*userID が 0 の間は失敗させない。
+func failAssignmentCreates(t *testing.T, db *gorm.DB, userID *uint) {
+	t.Helper()
+	err := db.Callback().Create().Before("gorm:create").Register("test:fail_assignment", func(tx *gorm.DB) {
+		if a, ok := tx.Statement.Dest.(*models.Assignment); ok && a.UserID == *userID {
+			tx.AddError(errors.New("insert failed"))
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+}
+
+func TestGroupDistributionIsAtomic(t *testing.T) {
+	db := testutil.OpenDB(t)
+	teacher := createTestTeacher(t, db, "teacher@example.com")
+	alice := createTestUser(t, db, "alice@example.com")
+	bob := createTestUser(t, db, "bob@example.com")
+	carol := createTestUser(t, db, "carol@example.com")
+	svc := NewGroupService(db)
+
+	var failFor uint
+	failAssignmentCreates(t, db, &failFor)
+
+	group, err := svc.Create(teacher.ID, "2年1組", "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for _, user := range []*models.User{alice, bob} {
+		if _, err := svc.Join(user.ID, group.JoinCode); err != nil {
+			t.Fatalf("Join: %v", err)
+		}
+	}
+	db.Create(&models.Webhook{UserID: alice.ID, Name: "hook", URL: "http://127.0.0.1:1/hook", Secret: "secret", IsActive: true})
+
+	// 1人でも配布に失敗したら配布元も他の生徒の課題も残さず、Webhook も送らない
+	failFor = bob.ID
+	if _, err := svc.CreateAssignment(teacher.ID, group.ID, GroupAssignmentInput{Title: "問題集", DueDate: time.Now().Add(time.Hour)}); err == nil {
+		t.Fatal("CreateAssignment succeeded although a copy could not be created")
+	}
+	var sources, copies, deliveries int64
+	db.Model(&models.GroupAssignment{}).Count(&sources)
+	db.Model(&models.Assignment{}).Count(&copies)
+	db.Model(&models.WebhookDelivery{}).Count(&deliveries)
+	if sources != 0 || copies != 0 || deliveries != 0 {
+		t.Errorf("after a failed distribution: %d sources, %d copies, %d deliveries, want none", sources, copies, deliveries)
+	}
+
+	// 参加時の配布に失敗したら参加も取り消す
+	failFor = 0
+	if err := svc.Leave(alice.ID, group.ID); err != nil {
+		t.Fatalf("Leave: %v", err)
+	}
+	if err := svc.Leave(bob.ID, group.ID); err != nil {
+		t.Fatalf("Leave: %v", err)
+	}
+	if _, err := svc.CreateAssignment(teacher.ID, group.ID, GroupAssignmentInput{Title: "問題集", DueDate: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("CreateAssignment: %v", err)
+	}
+	failFor = carol.ID
+	if _, err := svc.Join(carol.ID, group.JoinCode); err == nil {
+		t.Fatal("Join succeeded although a copy could not be created")
+	}
+	if _, _, err := svc.Get(carol.ID, group.ID); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("membership after a failed join: err = %v, want %v", err, ErrGroupNotFound)
+	}
+	failFor = 0
+	if _, err := svc.Join(carol.ID, group.JoinCode); err != nil {
+		t.Errorf("joining again: %v", err)
+	}
+}
+
+func TestGroupAssignmentEditBehavior(t *testing.T) {
+	due := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	newDue := due.Add(24 * time.Hour)
+
+	// 完了した課題はどの場合も変えない（繰り返し課題の編集と同じ）
+	tests := []struct {
+		name           string
+		behavior       string
+		wantUpdated    int
+		wantPendingNew bool
+		wantOverdueNew bool
+	}{
+		{"配布元だけ", models.EditBehaviorThisOnly, 0, false, false},
+		{"期限前の未完了の課題", models.EditBehaviorThisAndFuture, 1, true, false},
+		{"すべての未完了の課題", models.EditBehaviorAll, 2, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			teacher := createTestTeacher(t, db, "teacher@example.com")
+			pending := createTestUser(t, db, "pending@example.com")
+			overdue := createTestUser(t, db, "overdue@example.com")
+			done := createTestUser(t, db, "done@example.com")
+			svc := NewGroupService(db)
+
+			group, _ := svc.Create(teacher.ID, "理科", "")
+			for _, u := range []*models.User{pending, overdue, done} {
+				svc.Join(u.ID, group.JoinCode)
+			}
+			source, err := svc.CreateAssignment(teacher.ID, group.ID, GroupAssignmentInput{Title: "実験レポート", DueDate: due})
+			if err != nil {
+				t.Fatalf("CreateAssignment: %v", err)
+			}
+			copies := groupCopies(t, db, source)
+			NewAssignmentService(db).ToggleComplete(done.ID, copies[done.ID].ID)
+			// 生徒が自分の課題の期限を過去に変えた
+			db.Model(&models.Assignment{}).Where("id = ?", copies[overdue.ID].ID).Update("due_date", time.Now().Add(-time.Hour))
+
+			updated, count, err := svc.UpdateAssignment(teacher.ID, group.ID, source.ID, GroupAssignmentInput{
+				Title: "実験レポート（改）", Subject: "理科", Priority: "high", DueDate: newDue,
+			}, tt.behavior)
+			if err != nil {
+				t.Fatalf("UpdateAssignment: %v", err)
+			}
+			if count != tt.wantUpdated || updated.Title != "実験レポート（改）" || !updated.DueDate.Equal(newDue) {
+				t.Errorf("UpdateAssignment = %+v, %d updated, want %d", updated, count, tt.wantUpdated)
+			}
+
+			copies = groupCopies(t, db, source)
+			check := func(user *models.User, want bool) {
+				a := copies[user.ID]
+				changed := a.Title == "実験レポート（改）" && a.Priority == "high" && a.Subject == "理科" && a.SubjectID != nil && a.DueDate.Equal(newDue)
+				if changed != want {
+					t.Errorf("%s: copy = %+v, changed = %v, want %v", user.Email, a, changed, want)
+				}
+			}
+			check(pending, tt.wantPendingNew)
+			check(overdue, tt.wantOverdueNew)
+			check(done, false)
+			if !copies[done.ID].IsCompleted || copies[pending.ID].IsCompleted || copies[overdue.ID].IsCompleted {
+				t.Errorf("completion state changed: %+v", copies)
+			}
+		})
+	}
+}
+
+// 生徒の課題の更新に失敗したら、配布元も他の生徒の課題も更新しない
+func TestGroupAssignmentUpdateIsAtomic(t *testing.T) {
+	db := testutil.OpenDB(t)
+	teacher := createTestTeacher(t, db, "teacher@example.com")
+	alice := createTestUser(t, db, "alice@example.com")
+	bob := createTestUser(t, db, "bob@example.com")
+	svc := NewGroupService(db)
+
+	group, _ := svc.Create(teacher.ID, "国語", "")
+	svc.Join(alice.ID, group.JoinCode)
+	svc.Join(bob.ID, group.JoinCode)
+	source, err := svc.CreateAssignment(teacher.ID, group.ID, GroupAssignmentInput{Title: "作文", DueDate: time.Now().Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateAssignment: %v", err)
+	}
+
+	// 2人目の生徒の課題の保存で失敗させる
+	var saves int
+	err = db.Callback().Update().Before("gorm:update").Register("test:fail_second_copy", func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Dest.(*models.Assignment); !ok {
+			return
+		}
+		if saves++; saves == 2 {
+			tx.AddError(errors.New("update failed"))
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	if _, _, err := svc.UpdateAssignment(teacher.ID, group.ID, source.ID, GroupAssignmentInput{
+		Title: "作文（改）", DueDate: time.Now().Add(48 * time.Hour),
+	}, models.EditBehaviorAll); err == nil {
+		t.Fatal("UpdateAssignment succeeded, want an error")
+	}
+
+	var stored models.GroupAssignment
+	db.First(&stored, source.ID)
+	if stored.Title != "作文" {
+		t.Errorf("source title = %q after a failed update, want it unchanged", stored.Title)
+	}
+	for userID, a := range groupCopies(t, db, source) {
+		if a.Title != "作文" {
+			t.Errorf("user %d: copy title = %q after a failed update, want it unchanged", userID, a.Title)
+		}
+	}
+}
+
+func TestGroupProgressAndDelete(t *testing.T) {
+	db := testutil.OpenDB(t)
+	teacher := createTestTeacher(t, db, "teacher@example.com")
+	onTime := createTestUser(t, db, "ontime@example.com")
+	late := createTestUser(t, db, "late@example.com")
+	overdue := createTestUser(t, db, "overdue@example.com")
+	deleted := createTestUser(t, db, "deleted@example.com")
+	other := createTestTeacher(t, db, "other@example.com")
+	svc := NewGroupService(db)
+	assignments := NewAssignmentService(db)
+
+	group, _ := svc.Create(teacher.ID, "3年2組", "")
+	for _, u := range []*models.User{onTime, late, overdue, deleted} {
+		svc.Join(u.ID, group.JoinCode)
+	}
+	source, _ := svc.CreateAssignment(teacher.ID, group.ID, GroupAssignmentInput{Title: "読書感想文", DueDate: time.Now().Add(time.Hour)})
+	copies := groupCopies(t, db, source)
+
+	// 期限を過去にしてから、期限後に完了・未完了・削除の状態を作る
+	db.Model(&models.Assignment{}).Where("group_assignment_id = ?", source.ID).Update("due_date", time.Now().Add(-time.Hour))
+	early := time.Now().Add(-2 * time.Hour)
+	db.Model(&models.Assignment{}).Where("id = ?", copies[onTime.ID].ID).Updates(map[string]interface{}{"is_completed": true, "completed_at": early})
+	assignments.ToggleComplete(late.ID, copies[late.ID].ID)
+	assignments.Delete(deleted.ID, copies[deleted.ID].ID)
+
+	if _, err := svc.Progress(other.ID, group.ID, source.ID); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("outsider viewing progress: err = %v, want %v", err, ErrGroupNotFound)
+	}
+	if _, err := svc.Progress(onTime.ID, group.ID, source.ID); !errors.Is(err, ErrNotTeacher) {
+		t.Errorf("student viewing progress: err = %v, want %v", err, ErrNotTeacher)
+	}
+	progress, err := svc.Progress(teacher.ID, group.ID, source.ID)
+	if err != nil {
+		t.Fatalf("Progress: %v", err)
+	}
+	want := map[uint]string{
+		onTime.ID:  ProgressCompleted,
+		late.ID:    ProgressCompletedLate,
+		overdue.ID: ProgressOverdue,
+		deleted.ID: ProgressDeleted,
+	}
+	if len(progress.Members) != len(want) {
+		t.Fatalf("%d members, want %d", len(progress.Members), len(want))
+	}
+	for _, m := range progress.Members {
+		if m.Status != want[m.UserID] {
+			t.Errorf("%s: status = %s, want %s", m.Email, m.Status, want[m.UserID])
+		}
+	}
+	if progress.Total != 4 || progress.Completed != 1 || progress.CompletedLate != 1 || progress.Overdue != 1 || progress.CompletionRate != 50 {
+		t.Errorf("progress = %+v", progress)
+	}
+
+	summaries, _ := svc.ListAssignments(teacher.ID, group.ID)
+	if len(summaries) != 1 || summaries[0].Total != 3 || summaries[0].Completed != 2 {
+		t.Errorf("summaries = %+v", summaries)
+	}
+
+	// グループを削除すると未完了の課題は消え、完了した課題は残る
+	if err := svc.Delete(onTime.ID, group.ID); !errors.Is(err, ErrNotTeacher) {
+		t.Errorf("student deleting the group: err = %v, want %v", err, ErrNotTeacher)
+	}
+	if err := svc.Delete(teacher.ID, group.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	remaining := groupCopies(t, db, source)
+	if len(remaining) != 2 || !remaining[onTime.ID].IsCompleted || !remaining[late.ID].IsCompleted {
+		t.Errorf("copies after deleting the group = %+v", remaining)
+	}
+	if memberships, _ := svc.ListForUser(onTime.ID); len(memberships) != 0 {
+		t.Errorf("memberships after deleting the group = %+v", memberships)
+	}
+}
+
+// 教師のアカウントを削除すると、他に教師がいないクラスは削除し、共同の教師がいるクラスは引き継がせる
+func TestDeleteTeacherAccount(t *testing.T) {
+	db := testutil.OpenDB(t)
+	admin := createTestUser(t, db, "admin@example.com")
+	teacher := createTestTeacher(t, db, "teacher@example.com")
+	coTeacher := createTestTeacher(t, db, "co-teacher@example.com")
+	pending := createTestUser(t, db, "pending@example.com")
+	done := createTestUser(t, db, "done@example.com")
+	svc := NewGroupService(db)
+
+	solo, _ := svc.Create(teacher.ID, "1年1組", "")
+	shared, _ := svc.Create(teacher.ID, "1年2組", "")
+	svc.Join(coTeacher.ID, shared.JoinCode)
+	if err := svc.PromoteMember(teacher.ID, shared.ID, coTeacher.ID); err != nil {
+		t.Fatalf("PromoteMember: %v", err)
+	}
+	for _, u := range []*models.User{pending, done} {
+		svc.Join(u.ID, solo.JoinCode)
+		svc.Join(u.ID, shared.JoinCode)
+	}
+	due := time.Now().Add(24 * time.Hour)
+	soloSource, _ := svc.CreateAssignment(teacher.ID, solo.ID, GroupAssignmentInput{Title: "日記", DueDate: due})
+	sharedSource, _ := svc.CreateAssignment(teacher.ID, shared.ID, GroupAssignmentInput{Title: "音読", DueDate: due})
+	NewAssignmentService(db).ToggleComplete(done.ID, groupCopies(t, db, soloSource)[done.ID].ID)
+
+	attachments, _ := newTestAttachmentService(t, db)
+	if err := NewAdminService(db, attachments).DeleteUser(admin.ID, teacher.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	// 教師のいないクラスは、メンバーと配布元ごと削除する。生徒の完了した課題だけが残る
+	if err := db.First(&models.Group{}, solo.ID).Error; err == nil {
+		t.Error("group without another teacher is left")
+	}
+	var count int64
+	db.Model(&models.Membership{}).Where("group_id = ?", solo.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("%d membership(s) left in the deleted group", count)
+	}
+	if err := db.First(&models.GroupAssignment{}, soloSource.ID).Error; err == nil {
+		t.Error("group assignment of the deleted group is left")
+	}
+	copies := groupCopies(t, db, soloSource)
+	if _, ok := copies[pending.ID]; ok || !copies[done.ID].IsCompleted {
+		t.Errorf("copies after deleting the teacher = %+v, want only the completed one", copies)
+	}
+
+	// 共同の教師がいるクラスは残り、配布元の作成者を付け替える
+	group, membership, err := svc.Get(coTeacher.ID, shared.ID)
+	if err != nil || !membership.IsTeacher() {
+		t.Fatalf("shared group = %+v, %+v, %v", group, membership, err)
+	}
+	var source models.GroupAssignment
+	db.First(&source, sharedSource.ID)
+	if source.CreatedByID != coTeacher.ID {
+		t.Errorf("created_by_id = %d, want the co-teacher %d", source.CreatedByID, coTeacher.ID)
+	}
+	if copies := groupCopies(t, db, sharedSource); len(copies) != 2 {
+		t.Errorf("%d copies in the shared group, want 2", len(copies))
+	}
+	if _, err := svc.Members(coTeacher.ID, shared.ID); err != nil {
+		t.Errorf("Members: %v", err)
+	}
+}
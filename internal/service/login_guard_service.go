@@ -0,0 +1,398 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/sessionstore"
+
+	"gorm.io/gorm"
+)
+
+// ログイン試行の方法
+const (
+	LoginMethodPassword     = "password"
+	LoginMethodTOTP         = "totp"
+	LoginMethodRecoveryCode = "recovery_code"
+	LoginMethodPasskey      = "passkey"
+	LoginMethodOIDC         = "oidc"
+	LoginMethodAdmin        = "admin"
+)
+
+// ログイン試行を拒否・失敗した理由
+const (
+	LoginReasonInvalidCredentials = "invalid_credentials"
+	LoginReasonInvalidCode        = "invalid_code"
+	LoginReasonLocked             = "locked"
+	LoginReasonThrottled          = "throttled"
+	LoginReasonCaptcha            = "captcha"
+)
+
+// failureWindow より前の失敗は連続失敗回数に数えない。
+const failureWindow = 24 * time.Hour
+
+// loginAttemptRetention はログイン試行の記録を保持する期間。
+const loginAttemptRetention = 90 * 24 * time.Hour
+
+// LoginBlockedError はアカウントのロック中、または次の試行までの待ち時間中に返す。
+type LoginBlockedError struct {
+	Locked bool // true はロック中、false は待ち時間中
+	Until  time.Time
+}
+
+func (e *LoginBlockedError) Error() string {
+	if e.Locked {
+		return "account is temporarily locked"
+	}
+	return "too many failed login attempts"
+}
+
+// RetryAfter は次に試行できるまでの時間を返す。
+func (e *LoginBlockedError) RetryAfter() time.Duration {
+	if d := time.Until(e.Until); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// LoginMeta はログイン試行の接続元。
+type LoginMeta struct {
+	IP        string
+	UserAgent string
+}
+
+// LoginGuardService はアカウントごとのログイン失敗を記録し、待ち時間・一時的なロック・CAPTCHA の要否を判定する。
+// 失敗回数はメールアドレスごとに数えるため、存在しないアカウントへの試行も同じように扱われる。
+type LoginGuardService struct {
+	cfg            config.LockoutConfig
+	captchaEnabled bool
+	attemptRepo    *repository.LoginAttemptRepository
+	userRepo       *repository.UserRepository
+	authService    *AuthService
+	auditService   *AuditService
+	now            func() time.Time
+}
+
+func NewLoginGuardService(db *gorm.DB, sessions sessionstore.Backend, cfg config.LockoutConfig, captchaCfg config.CaptchaConfig) *LoginGuardService {
+	return &LoginGuardService{
+		cfg:            cfg,
+		captchaEnabled: captchaCfg.Enabled,
+		attemptRepo:    repository.NewLoginAttemptRepository(db),
+		userRepo:       repository.NewUserRepository(db),
+		authService:    NewAuthService(db, sessions),
+		auditService:   NewAuditService(db),
+		now:            func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// failures はメールアドレスの連続失敗回数と最後に失敗した日時を返す。
+func (s *LoginGuardService) failures(email string, now time.Time) (int64, time.Time, error) {
+	since := now.Add(-failureWindow)
+	reset, err := s.attemptRepo.LastReset(email)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if reset.After(since) {
+		since = reset
+	}
+	return s.attemptRepo.FailuresSince(email, since)
+}
+
+// blocked は連続失敗回数からロック・待ち時間を判定する。試行できる場合は nil を返す。
+func (s *LoginGuardService) blocked(failures int64, lastFailure, now time.Time) *LoginBlockedError {
+	if s.cfg.Threshold > 0 && failures >= int64(s.cfg.Threshold) {
+		until := lastFailure.Add(time.Duration(s.cfg.Duration) * time.Minute)
+		if now.Before(until) {
+			return &LoginBlockedError{Locked: true, Until: until}
+		}
+		return nil
+	}
+	if s.cfg.DelayAfter > 0 && failures >= int64(s.cfg.DelayAfter) {
+		until := lastFailure.Add(s.delay(failures))
+		if now.Before(until) {
+			return &LoginBlockedError{Until: until}
+		}
+	}
+	return nil
+}
+
+// delay は連続失敗回数に応じた待ち時間。DelayAfter 回目の失敗で 1 秒、以降は倍々にして MaxDelay で打ち切る。
+func (s *LoginGuardService) delay(failures int64) time.Duration {
+	maxDelay := time.Duration(s.cfg.MaxDelay) * time.Second
+	delay := time.Second
+	for i := int64(s.cfg.DelayAfter); i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// Status はメールアドレスのロック・待ち時間の状態を返す。試行できる場合は nil を返す。
+func (s *LoginGuardService) Status(email string) *LoginBlockedError {
+	now := s.now()
+	failures, lastFailure, err := s.failures(normalizeLoginEmail(email), now)
+	if err != nil {
+		log.Printf("Failed to count login failures: %v", err)
+		return nil
+	}
+	return s.blocked(failures, lastFailure, now)
+}
+
+// CaptchaRequired は CAPTCHA を求めるかを返す。アカウント（email）または IP で CaptchaAfter 回以上失敗している場合に true。
+// email が空の場合は IP だけで判定する（ログイン画面の表示時）。
+func (s *LoginGuardService) CaptchaRequired(email, ip string) bool {
+	if !s.captchaEnabled {
+		return false
+	}
+	if s.cfg.CaptchaAfter == 0 {
+		return true
+	}
+	now := s.now()
+	if email != "" {
+		failures, _, err := s.failures(normalizeLoginEmail(email), now)
+		if err != nil || failures >= int64(s.cfg.CaptchaAfter) {
+			return true
+		}
+	}
+	if ip != "" {
+		count, err := s.attemptRepo.CountIPFailuresSince(ip, now.Add(-time.Duration(s.cfg.Duration)*time.Minute))
+		if err != nil || count >= int64(s.cfg.CaptchaAfter) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate はロック・待ち時間を確認してからパスワードを検証し、結果を記録する。
+// ロック中・待ち時間中は *LoginBlockedError、パスワードが違う場合は ErrInvalidCredentials を返す。
+// 成功時は記録しない（2 段階認証を終えてから RecordSuccess で記録する）。
+func (s *LoginGuardService) Authenticate(email, password string, meta LoginMeta) (*models.User, error) {
+	var userID *uint
+	if user, err := s.userRepo.FindByEmail(email); err == nil {
+		userID = &user.ID
+	}
+
+	if blocked := s.Status(email); blocked != nil {
+		s.record(userID, email, LoginMethodPassword, models.LoginResultBlocked, blockedReason(blocked), meta)
+		return nil, blocked
+	}
+
+	user, err := s.authService.Login(email, password)
+	if err != nil {
+		s.record(userID, email, LoginMethodPassword, models.LoginResultFailure, LoginReasonInvalidCredentials, meta)
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// CheckSecondFactor は 2 段階認証の前にロック・待ち時間を確認する。拒否した場合は記録して *LoginBlockedError を返す。
+func (s *LoginGuardService) CheckSecondFactor(user *models.User, method string, meta LoginMeta) error {
+	if blocked := s.Status(user.Email); blocked != nil {
+		s.record(&user.ID, user.Email, method, models.LoginResultBlocked, blockedReason(blocked), meta)
+		return blocked
+	}
+	return nil
+}
+
+// RecordFailure は 2 段階認証の失敗を記録する。パスワードの失敗と同じく連続失敗回数に数える。
+func (s *LoginGuardService) RecordFailure(user *models.User, method string, meta LoginMeta) {
+	s.record(&user.ID, user.Email, method, models.LoginResultFailure, LoginReasonInvalidCode, meta)
+}
+
+// RecordCaptchaFailure は CAPTCHA の検証に失敗した試行を記録する。連続失敗回数には数えない。
+func (s *LoginGuardService) RecordCaptchaFailure(email string, meta LoginMeta) {
+	var userID *uint
+	if user, err := s.userRepo.FindByEmail(email); err == nil {
+		userID = &user.ID
+	}
+	s.record(userID, email, LoginMethodPassword, models.LoginResultBlocked, LoginReasonCaptcha, meta)
+}
+
+// RecordSuccess はログインの完了を記録し、連続失敗回数をリセットする。
+func (s *LoginGuardService) RecordSuccess(user *models.User, method string, meta LoginMeta) {
+	s.record(&user.ID, user.Email, method, models.LoginResultSuccess, "", meta)
+}
+
+// Unlock は管理者がアカウントのロックと連続失敗回数をリセットする。
+func (s *LoginGuardService) Unlock(userID uint, meta LoginMeta) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	return s.attemptRepo.Create(s.newAttempt(&user.ID, user.Email, LoginMethodAdmin, models.LoginResultUnlocked, "", meta))
+}
+
+// LockedUsers は users のうちロック中のユーザーについて、ロックが解除される日時を返す。
+func (s *LoginGuardService) LockedUsers(users []models.User) map[uint]time.Time {
+	locked := make(map[uint]time.Time)
+	for _, user := range users {
+		if blocked := s.Status(user.Email); blocked != nil && blocked.Locked {
+			locked[user.ID] = blocked.Until
+		}
+	}
+	return locked
+}
+
+func (s *LoginGuardService) RecentAttempts(filter repository.LoginAttemptFilter, limit int) ([]models.LoginAttempt, error) {
+	filter.Email = normalizeLoginEmail(filter.Email)
+	filter.IP = strings.TrimSpace(filter.IP)
+	return s.attemptRepo.FindRecent(filter, limit)
+}
+
+func (s *LoginGuardService) record(userID *uint, email, method, result, reason string, meta LoginMeta) {
+	attempt := s.newAttempt(userID, email, method, result, reason, meta)
+	if err := s.attemptRepo.Create(attempt); err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+	s.audit(attempt)
+}
+
+// audit はログイン試行を監査ログにも残す。存在しないアカウントへの試行は操作者を入力されたメールアドレスだけで記録する。
+func (s *LoginGuardService) audit(attempt *models.LoginAttempt) {
+	var action string
+	switch attempt.Result {
+	case models.LoginResultSuccess:
+		action = models.AuditLoginSuccess
+	case models.LoginResultFailure:
+		action = models.AuditLoginFailure
+	case models.LoginResultBlocked:
+		action = models.AuditLoginBlocked
+	default:
+		return
+	}
+
+	target := AuditTarget{Type: models.AuditTargetUser, Label: attempt.Email}
+	if attempt.UserID != nil {
+		target.ID = strconv.FormatUint(uint64(*attempt.UserID), 10)
+	}
+	details := map[string]interface{}{"method": attempt.Method}
+	if attempt.Reason != "" {
+		details["reason"] = attempt.Reason
+	}
+	s.auditService.Record(AuditActor{
+		UserID:    attempt.UserID,
+		Email:     attempt.Email,
+		IP:        attempt.IP,
+		UserAgent: attempt.UserAgent,
+	}, action, target, details)
+}
+
+func (s *LoginGuardService) newAttempt(userID *uint, email, method, result, reason string, meta LoginMeta) *models.LoginAttempt {
+	email = normalizeLoginEmail(email)
+	if len(email) > 255 {
+		email = email[:255]
+	}
+	userAgent := meta.UserAgent
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	return &models.LoginAttempt{
+		UserID:    userID,
+		Email:     email,
+		IP:        meta.IP,
+		UserAgent: userAgent,
+		Method:    method,
+		Result:    result,
+		Reason:    reason,
+		CreatedAt: s.now(),
+	}
+}
+
+func blockedReason(blocked *LoginBlockedError) string {
+	if blocked.Locked {
+		return LoginReasonLocked
+	}
+	return LoginReasonThrottled
+}
+
+// StartCleanupScheduler は保持期間を過ぎたログイン試行の記録を定期的に削除する。
+func (s *LoginGuardService) StartCleanupScheduler(interval time.Duration) {
+	go func() {
+		s.runCleanup()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.runCleanup()
+		}
+	}()
+}
+
+func (s *LoginGuardService) runCleanup() {
+	if _, err := s.attemptRepo.DeleteBefore(s.now().Add(-loginAttemptRetention)); err != nil {
+		log.Printf("Error deleting old login attempts: %v", err)
+	}
+}
+
+// BlockedMessage はロック・待ち時間中に表示するメッセージを返す。
+func BlockedMessage(err error) string {
+	var blocked *LoginBlockedError
+	if !errors.As(err, &blocked) {
+		return ""
+	}
+	retry := blocked.RetryAfter()
+	if blocked.Locked {
+		minutes := int((retry + time.Minute - 1) / time.Minute)
+		if minutes < 1 {
+			minutes = 1
+		}
+		return fmt.Sprintf("ログインの失敗が続いたため、このアカウントは一時的にロックされています。約%d分後にもう一度お試しください", minutes)
+	}
+	seconds := int((retry + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return fmt.Sprintf("ログインの失敗が続いています。%d秒後にもう一度お試しください", seconds)
+}
+
+// GetLoginMethodLabel はログイン方法の表示名を返す。
+func GetLoginMethodLabel(method string) string {
+	switch method {
+	case LoginMethodPassword:
+		return "パスワード"
+	case LoginMethodTOTP:
+		return "認証コード"
+	case LoginMethodRecoveryCode:
+		return "リカバリーコード"
+	case LoginMethodPasskey:
+		return "パスキー"
+	case LoginMethodOIDC:
+		return "シングルサインオン"
+	case LoginMethodAdmin:
+		return "管理者"
+	default:
+		return method
+	}
+}
+
+// GetLoginReasonLabel はログインを拒否・失敗した理由の表示名を返す。
+func GetLoginReasonLabel(reason string) string {
+	switch reason {
+	case LoginReasonInvalidCredentials:
+		return "メールアドレスまたはパスワードの誤り"
+	case LoginReasonInvalidCode:
+		return "認証コードの誤り"
+	case LoginReasonLocked:
+		return "アカウントのロック中"
+	case LoginReasonThrottled:
+		return "待ち時間中"
+	case LoginReasonCaptcha:
+		return "CAPTCHA の失敗"
+	default:
+		return reason
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/sessionstore"
+	"homework-manager/internal/testutil"
+)
+
+const (
+	loginGuardEmail    = "guard@example.com"
+	loginGuardPassword = "password123"
+)
+
+// loginStep は elapsed の時刻に password でログインを試みる手順。want は "ok", "invalid", "throttled", "locked" のいずれか。
+// unlock が true の場合はログインの代わりに管理者がロックを解除する。
+type loginStep struct {
+	elapsed   time.Duration
+	password  string
+	want      string
+	wantRetry time.Duration // want が throttled・locked の場合の次に試行できるまでの時間
+	unlock    bool
+}
+
+func TestLoginGuardAuthenticate(t *testing.T) {
+	const wrong = "wrong-password"
+
+	tests := []struct {
+		name  string
+		cfg   config.LockoutConfig
+		steps []loginStep
+	}{
+		{
+			name: "連続失敗でロック",
+			cfg:  config.LockoutConfig{Threshold: 3, Duration: 15},
+			steps: []loginStep{
+				{elapsed: 0, password: wrong, want: "invalid"},
+				{elapsed: time.Minute, password: wrong, want: "invalid"},
+				{elapsed: 2 * time.Minute, password: wrong, want: "invalid"},
+				// ロック中は正しいパスワードでも拒否する
+				{elapsed: 3 * time.Minute, password: loginGuardPassword, want: "locked", wantRetry: 14 * time.Minute},
+				{elapsed: 16 * time.Minute, password: loginGuardPassword, want: "locked", wantRetry: time.Minute},
+			},
+		},
+		{
+			name: "ロックの期限切れ",
+			cfg:  config.LockoutConfig{Threshold: 2, Duration: 15},
+			steps: []loginStep{
+				{elapsed: 0, password: wrong, want: "invalid"},
+				{elapsed: time.Minute, password: wrong, want: "invalid"},
+				{elapsed: 15*time.Minute + time.Minute - time.Second, password: loginGuardPassword, want: "locked", wantRetry: time.Second},
+				{elapsed: 16 * time.Minute, password: loginGuardPassword, want: "ok"},
+				// 成功で連続失敗回数はリセットされる
+				{elapsed: 17 * time.Minute, password: wrong, want: "invalid"},
+				{elapsed: 18 * time.Minute, password: loginGuardPassword, want: "ok"},
+			},
+		},
+		{
+			name: "管理者によるロック解除",
+			cfg:  config.LockoutConfig{Threshold: 2, Duration: 15},
+			steps: []loginStep{
+				{elapsed: 0, password: wrong, want: "invalid"},
+				{elapsed: time.Minute, password: wrong, want: "invalid"},
+				{elapsed: 2 * time.Minute, unlock: true},
+				{elapsed: 3 * time.Minute, password: loginGuardPassword, want: "ok"},
+			},
+		},
+		{
+			name: "失敗が続くと待ち時間が倍々に増える",
+			cfg:  config.LockoutConfig{DelayAfter: 2, MaxDelay: 4},
+			steps: []loginStep{
+				{elapsed: 0, password: wrong, want: "invalid"},
+				{elapsed: time.Minute, password: wrong, want: "invalid"},
+				{elapsed: time.Minute, password: loginGuardPassword, want: "throttled", wantRetry: time.Second},
+				{elapsed: time.Minute + time.Second, password: wrong, want: "invalid"},
+				{elapsed: time.Minute + 2*time.Second, password: wrong, want: "throttled", wantRetry: time.Second},
+				{elapsed: time.Minute + 3*time.Second, password: wrong, want: "invalid"},
+				{elapsed: time.Minute + 5*time.Second, password: wrong, want: "throttled", wantRetry: 2 * time.Second},
+				{elapsed: time.Minute + 7*time.Second, password: wrong, want: "invalid"},
+				// 上限の4秒で打ち切る
+				{elapsed: time.Minute + 11*time.Second, password: wrong, want: "invalid"},
+				{elapsed: time.Minute + 14*time.Second, password: wrong, want: "throttled", wantRetry: time.Second},
+				{elapsed: time.Minute + 15*time.Second, password: loginGuardPassword, want: "ok"},
+			},
+		},
+		{
+			name: "24時間より前の失敗は数えない",
+			cfg:  config.LockoutConfig{Threshold: 2, Duration: 15},
+			steps: []loginStep{
+				{elapsed: 0, password: wrong, want: "invalid"},
+				{elapsed: 25 * time.Hour, password: wrong, want: "invalid"},
+				{elapsed: 25*time.Hour + time.Minute, password: loginGuardPassword, want: "ok"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			sessions := sessionstore.NewMemoryBackend()
+			user, err := NewAuthService(db, sessions).Register(loginGuardEmail, loginGuardPassword, "ロック")
+			if err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+
+			base := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+			now := base
+			guard := NewLoginGuardService(db, sessions, tt.cfg, config.CaptchaConfig{})
+			guard.now = func() time.Time { return now }
+			meta := LoginMeta{IP: "192.0.2.1", UserAgent: "test"}
+
+			for i, step := range tt.steps {
+				now = base.Add(step.elapsed)
+				if step.unlock {
+					if err := guard.Unlock(user.ID, meta); err != nil {
+						t.Fatalf("step %d: Unlock: %v", i, err)
+					}
+					continue
+				}
+
+				got, err := guard.Authenticate(loginGuardEmail, step.password, meta)
+				var blocked *LoginBlockedError
+				switch step.want {
+				case "ok":
+					if err != nil || got == nil || got.ID != user.ID {
+						t.Fatalf("step %d: Authenticate = %v, %v, want success", i, got, err)
+					}
+					guard.RecordSuccess(got, LoginMethodPassword, meta)
+				case "invalid":
+					if !errors.Is(err, ErrInvalidCredentials) {
+						t.Fatalf("step %d: Authenticate error = %v, want ErrInvalidCredentials", i, err)
+					}
+				case "throttled", "locked":
+					if !errors.As(err, &blocked) || blocked.Locked != (step.want == "locked") {
+						t.Fatalf("step %d: Authenticate error = %v, want %s", i, err, step.want)
+					}
+					if retry := blocked.Until.Sub(now); retry != step.wantRetry {
+						t.Errorf("step %d: retry after %v, want %v", i, retry, step.wantRetry)
+					}
+				}
+			}
+		})
+	}
+}
+
+// 拒否した試行も記録するが、連続失敗回数には数えない
+func TestLoginGuardRecordsBlockedAttempts(t *testing.T) {
+	db := testutil.OpenDB(t)
+	sessions := sessionstore.NewMemoryBackend()
+	if _, err := NewAuthService(db, sessions).Register(loginGuardEmail, loginGuardPassword, "ロック"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+	guard := NewLoginGuardService(db, sessions, config.LockoutConfig{Threshold: 1, Duration: 15}, config.CaptchaConfig{})
+	guard.now = func() time.Time { return now }
+	meta := LoginMeta{IP: "192.0.2.1", UserAgent: "test"}
+
+	guard.Authenticate(loginGuardEmail, "wrong-password", meta)
+	now = now.Add(10 * time.Minute)
+	guard.Authenticate(loginGuardEmail, loginGuardPassword, meta)
+
+	attempts, err := guard.RecentAttempts(repository.LoginAttemptFilter{Email: loginGuardEmail}, 10)
+	if err != nil {
+		t.Fatalf("RecentAttempts: %v", err)
+	}
+	if len(attempts) != 2 || attempts[0].Result != models.LoginResultBlocked || attempts[0].Reason != LoginReasonLocked ||
+		attempts[1].Result != models.LoginResultFailure || attempts[1].Reason != LoginReasonInvalidCredentials {
+		t.Fatalf("attempts = %+v", attempts)
+	}
+
+	// ロックは最後の失敗から数えるため、拒否した試行で延びない
+	now = now.Add(5 * time.Minute)
+	if blocked := guard.Status(loginGuardEmail); blocked != nil {
+		t.Errorf("Status = %+v after the lock expired", blocked)
+	}
+}
+
+func TestLoginGuardCaptchaRequired(t *testing.T) {
+	const ip = "192.0.2.1"
+
+	tests := []struct {
+		name           string
+		captchaEnabled bool
+		captchaAfter   int
+		emailFailures  int           // loginGuardEmail への失敗回数（ip 以外から）
+		ipFailures     int           // ip からの別のアカウントへの失敗回数
+		failuresAgo    time.Duration // 失敗した時刻が現在からどれだけ前か
+		email          string
+		ip             string
+		want           bool
+	}{
+		{name: "CAPTCHA が無効", captchaAfter: 0, email: loginGuardEmail, ip: ip, want: false},
+		{name: "回数の指定がなければ常に求める", captchaEnabled: true, captchaAfter: 0, email: loginGuardEmail, ip: ip, want: true},
+		{name: "失敗なし", captchaEnabled: true, captchaAfter: 3, email: loginGuardEmail, ip: ip, want: false},
+		{name: "アカウントの失敗が回数未満", captchaEnabled: true, captchaAfter: 3, emailFailures: 2, email: loginGuardEmail, ip: ip, want: false},
+		{name: "アカウントの失敗が回数に達した", captchaEnabled: true, captchaAfter: 3, emailFailures: 3, email: loginGuardEmail, ip: ip, want: true},
+		{name: "メールアドレスは大文字小文字を区別しない", captchaEnabled: true, captchaAfter: 3, emailFailures: 3, email: "Guard@Example.com", want: true},
+		{name: "IP の失敗が回数に達した", captchaEnabled: true, captchaAfter: 3, ipFailures: 3, email: loginGuardEmail, ip: ip, want: true},
+		{name: "ログイン画面は IP だけで判定", captchaEnabled: true, captchaAfter: 3, ipFailures: 3, ip: ip, want: true},
+		{name: "別の IP", captchaEnabled: true, captchaAfter: 3, ipFailures: 3, ip: "198.51.100.1", want: false},
+		{name: "IP の失敗はロック時間を過ぎると数えない", captchaEnabled: true, captchaAfter: 3, ipFailures: 3, failuresAgo: 16 * time.Minute, ip: ip, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			sessions := sessionstore.NewMemoryBackend()
+			now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+			guard := NewLoginGuardService(db, sessions, config.LockoutConfig{Duration: 15, CaptchaAfter: tt.captchaAfter}, config.CaptchaConfig{Enabled: tt.captchaEnabled})
+
+			guard.now = func() time.Time { return now.Add(-tt.failuresAgo) }
+			for i := 0; i < tt.emailFailures; i++ {
+				guard.Authenticate(loginGuardEmail, "wrong-password", LoginMeta{IP: "203.0.113.1"})
+			}
+			for i := 0; i < tt.ipFailures; i++ {
+				guard.Authenticate("other@example.com", "wrong-password", LoginMeta{IP: ip})
+			}
+
+			guard.now = func() time.Time { return now }
+			if got := guard.CaptchaRequired(tt.email, tt.ip); got != tt.want {
+				t.Errorf("CaptchaRequired(%q, %q) = %v, want %v", tt.email, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
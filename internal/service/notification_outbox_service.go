@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// NotificationOutboxService は送信キュー (notification_outbox) の履歴の参照とデッドレターの再送を扱う。送信は NotificationService が行う。
+type NotificationOutboxService struct {
+	outboxRepo *repository.NotificationOutboxRepository
+	now        func() time.Time
+}
+
+func NewNotificationOutboxService(db *gorm.DB) *NotificationOutboxService {
+	return &NotificationOutboxService{
+		outboxRepo: repository.NewNotificationOutboxRepository(db),
+		now:        func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func GetNotificationKindLabel(kind string) string {
+	switch kind {
+	case models.NotificationKindReminder:
+		return "リマインダー"
+	case models.NotificationKindUrgentReminder:
+		return "督促通知"
+	case models.NotificationKindAssignmentCreated:
+		return "課題追加"
+	default:
+		return kind
+	}
+}
+
+func (s *NotificationOutboxService) GetRecentByUser(userID uint, limit int) ([]models.NotificationOutbox, error) {
+	return s.outboxRepo.FindRecentByUserID(userID, limit)
+}
+
+type NotificationOutboxPage struct {
+	Entries     []models.NotificationOutbox
+	TotalCount  int64
+	TotalPages  int
+	CurrentPage int
+	PageSize    int
+}
+
+func (s *NotificationOutboxService) Search(status, channel string, page, pageSize int) (*NotificationOutboxPage, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 50
+	}
+
+	entries, totalCount, err := s.outboxRepo.Search(status, channel, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int((totalCount + int64(pageSize) - 1) / int64(pageSize))
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	return &NotificationOutboxPage{
+		Entries:     entries,
+		TotalCount:  totalCount,
+		TotalPages:  totalPages,
+		CurrentPage: page,
+		PageSize:    pageSize,
+	}, nil
+}
+
+func (s *NotificationOutboxService) CountByStatus() (map[string]int64, error) {
+	return s.outboxRepo.CountByStatus()
+}
+
+// Requeue はデッドレターの通知を送信待ちに戻す。次のワーカーの実行時に再送される。
+func (s *NotificationOutboxService) Requeue(id uint) error {
+	ok, err := s.outboxRepo.Requeue(id, s.now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/testutil"
+)
+
+// flakyNotifier は最初の failures 回の送信を失敗させるテスト用のチャネル。
+type flakyNotifier struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	sent     int
+}
+
+func (n *flakyNotifier) Name() string                          { return "flaky" }
+func (n *flakyNotifier) Enabled(r *NotificationRecipient) bool { return true }
+
+func (n *flakyNotifier) Send(r *NotificationRecipient, msg *NotificationMessage) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.attempts++
+	if n.attempts <= n.failures {
+		return errors.New("temporary failure")
+	}
+	n.sent++
+	return nil
+}
+
+func (n *flakyNotifier) counts() (attempts, sent int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.attempts, n.sent
+}
+
+// createOutboxEntry は now に送信予定の通知を1件登録する。
+func createOutboxEntry(t *testing.T, svc *NotificationService, userID uint, now time.Time) *models.NotificationOutbox {
+	t.Helper()
+	entry := &models.NotificationOutbox{
+		UserID:        userID,
+		Kind:          models.NotificationKindReminder,
+		Channel:       "flaky",
+		Subject:       "リマインダー",
+		Text:          "本文",
+		Status:        models.NotificationStatusPending,
+		NextAttemptAt: &now,
+	}
+	if err := svc.outboxRepo.Create(entry); err != nil {
+		t.Fatalf("create outbox entry: %v", err)
+	}
+	return entry
+}
+
+func reloadOutboxEntry(t *testing.T, svc *NotificationService, id uint) *models.NotificationOutbox {
+	t.Helper()
+	entry, err := svc.outboxRepo.FindByID(id)
+	if err != nil {
+		t.Fatalf("find outbox entry: %v", err)
+	}
+	return entry
+}
+
+func TestNotificationRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, time.Hour},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := notificationRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("notificationRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// リマインダーの処理では有効なチャネルごとに送信待ちの通知を登録し、送信はワーカーが行う
+func TestOutboxEnqueueFromReminders(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "enqueue@example.com")
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+	reminderAt := now.Add(-time.Minute)
+	assignment := createTestAssignment(t, db, &models.Assignment{
+		UserID:          user.ID,
+		DueDate:         now.Add(24 * time.Hour),
+		ReminderEnabled: true,
+		ReminderAt:      &reminderAt,
+	})
+
+	recording := &recordingNotifier{}
+	flaky := &flakyNotifier{}
+	svc := NewNotificationService(db, config.NotificationConfig{})
+	svc.RegisterNotifier(recording)
+	svc.RegisterNotifier(flaky)
+	svc.now = func() time.Time { return now }
+
+	svc.ProcessPendingReminders()
+
+	var entries []models.NotificationOutbox
+	db.Where("assignment_id = ?", assignment.ID).Order("channel").Find(&entries)
+	if len(entries) != 2 {
+		t.Fatalf("queued %d notification(s), want one per channel", len(entries))
+	}
+	for i, channel := range []string{"flaky", "test"} {
+		entry := entries[i]
+		if entry.Channel != channel || entry.Status != models.NotificationStatusPending || entry.Attempts != 0 ||
+			entry.NextAttemptAt == nil || !entry.NextAttemptAt.Equal(now) {
+			t.Errorf("entry for %s = %+v", channel, entry)
+		}
+	}
+	if _, sent := flaky.counts(); sent != 0 || recording.count() != 0 {
+		t.Fatal("sent before the outbox was processed")
+	}
+
+	svc.ProcessOutbox()
+	if _, sent := flaky.counts(); sent != 1 || recording.count() != 1 {
+		t.Errorf("sent %d and %d notification(s), want 1 on each channel", sent, recording.count())
+	}
+}
+
+// 失敗した送信は1分から倍々の間隔で再送する
+func TestOutboxExponentialBackoff(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "backoff@example.com")
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	flaky := &flakyNotifier{failures: 3}
+	svc := NewNotificationService(db, config.NotificationConfig{MaxAttempts: 5})
+	svc.RegisterNotifier(flaky)
+	entry := createOutboxEntry(t, svc, user.ID, now)
+
+	current := now
+	svc.now = func() time.Time { return current }
+	for attempt, wantDelay := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		svc.ProcessOutbox()
+		stored := reloadOutboxEntry(t, svc, entry.ID)
+		if stored.Status != models.NotificationStatusPending || stored.Attempts != attempt+1 || stored.Error != "temporary failure" ||
+			stored.NextAttemptAt == nil || !stored.NextAttemptAt.Equal(current.Add(wantDelay)) {
+			t.Fatalf("after attempt %d: %+v, want retry after %v", attempt+1, stored, wantDelay)
+		}
+
+		// 待ち時間が過ぎる前は再送しない
+		current = current.Add(wantDelay - time.Second)
+		svc.ProcessOutbox()
+		if attempts, _ := flaky.counts(); attempts != attempt+1 {
+			t.Fatalf("retried before the %v backoff elapsed", wantDelay)
+		}
+		current = current.Add(time.Second)
+	}
+
+	svc.ProcessOutbox()
+	stored := reloadOutboxEntry(t, svc, entry.ID)
+	if stored.Status != models.NotificationStatusSent || stored.Attempts != 4 || stored.SentAt == nil || stored.NextAttemptAt != nil || stored.Error != "" {
+		t.Errorf("after delivery: %+v", stored)
+	}
+}
+
+// 試行回数の上限に達した通知はデッドレターになり、再送の操作で送信待ちに戻る
+func TestOutboxDeadLetter(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "dead@example.com")
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	flaky := &flakyNotifier{failures: 3}
+	svc := NewNotificationService(db, config.NotificationConfig{MaxAttempts: 3})
+	svc.RegisterNotifier(flaky)
+	entry := createOutboxEntry(t, svc, user.ID, now)
+
+	current := now
+	svc.now = func() time.Time { return current }
+	for i := 0; i < 3; i++ {
+		svc.ProcessOutbox()
+		current = current.Add(notificationRetryMaxDelay)
+	}
+
+	stored := reloadOutboxEntry(t, svc, entry.ID)
+	if stored.Status != models.NotificationStatusDead || stored.Attempts != 3 || stored.NextAttemptAt != nil || stored.Error != "temporary failure" {
+		t.Fatalf("after max attempts: %+v", stored)
+	}
+	svc.ProcessOutbox()
+	if attempts, _ := flaky.counts(); attempts != 3 {
+		t.Fatalf("dead letter was retried: %d attempt(s)", attempts)
+	}
+
+	outbox := NewNotificationOutboxService(db)
+	outbox.now = func() time.Time { return current }
+	if err := outbox.Requeue(entry.ID); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	stored = reloadOutboxEntry(t, svc, entry.ID)
+	if stored.Status != models.NotificationStatusPending || stored.Attempts != 0 || stored.Error != "" ||
+		stored.NextAttemptAt == nil || !stored.NextAttemptAt.Equal(current) {
+		t.Fatalf("after requeue: %+v", stored)
+	}
+	// 送信待ちの通知は再送の対象にならない
+	if err := outbox.Requeue(entry.ID); !errors.Is(err, ErrNotificationNotFound) {
+		t.Errorf("Requeue of a pending notification: %v, want ErrNotificationNotFound", err)
+	}
+
+	svc.ProcessOutbox()
+	stored = reloadOutboxEntry(t, svc, entry.ID)
+	if _, sent := flaky.counts(); sent != 1 || stored.Status != models.NotificationStatusSent {
+		t.Errorf("after requeue and delivery: sent %d, %+v", sent, stored)
+	}
+}
+
+// 送信中にワーカーが停止した通知は、確保の期限が切れるまで再起動したワーカーも送らず、その後1回だけ送る
+func TestOutboxNoDuplicateDeliveryAfterRestart(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "restart@example.com")
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	crashed := NewNotificationService(db, config.NotificationConfig{})
+	entry := createOutboxEntry(t, crashed, user.ID, now)
+	// 確保した直後に停止し、送信結果を保存しなかった
+	if ok, err := crashed.outboxRepo.Claim(entry, now.Add(notificationOutboxLease)); err != nil || !ok {
+		t.Fatalf("Claim = %v, %v", ok, err)
+	}
+
+	flaky := &flakyNotifier{}
+	current := now.Add(time.Minute)
+	restarted := NewNotificationService(db, config.NotificationConfig{})
+	restarted.RegisterNotifier(flaky)
+	restarted.now = func() time.Time { return current }
+
+	restarted.ProcessOutbox()
+	if attempts, _ := flaky.counts(); attempts != 0 {
+		t.Fatal("delivered a notification still claimed by the stopped worker")
+	}
+
+	// 古い状態を持ったままのワーカーは確保に失敗する
+	stale := *entry
+	stale.Attempts = 0
+	restarted.deliver(&stale)
+	if attempts, _ := flaky.counts(); attempts != 0 {
+		t.Fatal("delivered with a stale claim")
+	}
+
+	current = now.Add(notificationOutboxLease)
+	restarted.ProcessOutbox()
+	restarted.ProcessOutbox()
+	if _, sent := flaky.counts(); sent != 1 {
+		t.Fatalf("sent %d time(s) after the lease expired, want 1", sent)
+	}
+	stored := reloadOutboxEntry(t, restarted, entry.ID)
+	if stored.Status != models.NotificationStatusSent || stored.Attempts != 2 {
+		t.Errorf("after delivery: %+v", stored)
+	}
+
+	// 送信済みの通知は次の実行でも送らない
+	current = current.Add(notificationRetryMaxDelay)
+	restarted.ProcessOutbox()
+	if _, sent := flaky.counts(); sent != 1 {
+		t.Errorf("sent %d time(s) after running again, want 1", sent)
+	}
+}
@@ -2,30 +2,89 @@ package service
 
 import (
 	"bytes"
-	"encoding/json"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"html/template"
 	"log"
-	"net/http"
 	"strings"
 	"time"
 
-	"homework-manager/internal/database"
+	"homework-manager/internal/config"
 	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/telegram"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// notificationOutboxLease は送信中の通知を他のワーカーが取得しないようにする時間。
+	// 送信中にプロセスが停止した通知は、この時間が過ぎると再送される
+	notificationOutboxLease     = 2 * time.Minute
+	notificationRetryBaseDelay  = 1 * time.Minute
+	notificationRetryMaxDelay   = 1 * time.Hour
+	notificationOutboxBatchSize = 100
 )
 
+// TelegramLinkCodeTTL は Telegram 連携コードの有効期間。
+const TelegramLinkCodeTTL = 15 * time.Minute
+
+var (
+	ErrInvalidTelegramLinkCode = errors.New("invalid or expired telegram link code")
+	ErrTelegramChatNotLinked   = errors.New("telegram chat is not linked")
+)
+
+// errNotificationUndeliverable は再送しても送れない通知（チャネル未設定・ユーザーが無効化）を表す。
+var errNotificationUndeliverable = errors.New("notification is undeliverable")
+
 type NotificationService struct {
-	telegramBotToken string
+	db          *gorm.DB
+	notifiers   []Notifier
+	outboxRepo  *repository.NotificationOutboxRepository
+	maxAttempts int
+	now         func() time.Time
+}
+
+// NewNotificationService は Telegram チャネルを登録した状態で返す。その他のチャネルは RegisterNotifier で追加する。
+func NewNotificationService(db *gorm.DB, cfg config.NotificationConfig) *NotificationService {
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 5
+	}
+	s := &NotificationService{
+		db:          db,
+		outboxRepo:  repository.NewNotificationOutboxRepository(db),
+		maxAttempts: maxAttempts,
+		now:         func() time.Time { return time.Now().UTC() },
+	}
+	s.RegisterNotifier(NewTelegramNotifier(telegram.NewClient(cfg.TelegramBotToken, cfg.TelegramAPIURL)))
+	return s
+}
+
+func (s *NotificationService) RegisterNotifier(n Notifier) {
+	s.notifiers = append(s.notifiers, n)
+}
+
+// HasChannel は name のチャネルが登録されているかを返す。
+func (s *NotificationService) HasChannel(name string) bool {
+	return s.notifier(name) != nil
 }
 
-func NewNotificationService(telegramBotToken string) *NotificationService {
-	return &NotificationService{
-		telegramBotToken: telegramBotToken,
+func (s *NotificationService) notifier(name string) Notifier {
+	for _, n := range s.notifiers {
+		if n.Name() == name {
+			return n
+		}
 	}
+	return nil
 }
 
 func (s *NotificationService) GetUserSettings(userID uint) (*models.UserNotificationSettings, error) {
 	var settings models.UserNotificationSettings
-	result := database.GetDB().Where("user_id = ?", userID).First(&settings)
+	result := s.db.Where("user_id = ?", userID).First(&settings)
 	if result.Error != nil {
 		if result.RowsAffected == 0 {
 			return &models.UserNotificationSettings{
@@ -41,117 +100,320 @@ func (s *NotificationService) UpdateUserSettings(userID uint, settings *models.U
 	settings.UserID = userID
 
 	var existing models.UserNotificationSettings
-	result := database.GetDB().Where("user_id = ?", userID).First(&existing)
+	result := s.db.Where("user_id = ?", userID).First(&existing)
 
 	if result.RowsAffected == 0 {
-		return database.GetDB().Create(settings).Error
+		return s.db.Create(settings).Error
 	}
 
 	settings.ID = existing.ID
-	return database.GetDB().Save(settings).Error
+	return s.db.Save(settings).Error
 }
-func (s *NotificationService) SendTelegramNotification(chatID, message string) error {
-	if s.telegramBotToken == "" {
-		return fmt.Errorf("telegram bot token is not configured")
+
+// telegramLinkCodeAlphabet は読み間違えやすい文字 (0, O, 1, I) を除いた英数字。
+const telegramLinkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func hashTelegramLinkCode(code string) string {
+	hash := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(hash[:])
+}
+
+// CreateTelegramLinkCode はボットとの連携に使うワンタイムコードを発行する。以前に発行したコードは無効になる。
+// コードはハッシュだけを保存するため、平文を返すのはこのときだけ。
+func (s *NotificationService) CreateTelegramLinkCode(userID uint) (string, time.Time, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
 	}
-	if chatID == "" {
-		return fmt.Errorf("telegram chat ID is empty")
+	for i := range b {
+		b[i] = telegramLinkCodeAlphabet[int(b[i])%len(telegramLinkCodeAlphabet)]
 	}
+	code := string(b)
+	expiresAt := s.now().Add(TelegramLinkCodeTTL)
 
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", s.telegramBotToken)
+	settings, err := s.GetUserSettings(userID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	settings.TelegramLinkCodeHash = hashTelegramLinkCode(code)
+	settings.TelegramLinkCodeExpiresAt = &expiresAt
+	if err := s.UpdateUserSettings(userID, settings); err != nil {
+		return "", time.Time{}, err
+	}
+	return code, expiresAt, nil
+}
 
-	payload := map[string]string{
-		"chat_id":    chatID,
-		"text":       message,
-		"parse_mode": "HTML",
+// LinkTelegramChat は連携コードに対応するユーザーに chatID を紐付け、Telegram 通知を有効にする。
+// コードは1回だけ使える。同じチャットが別のユーザーに紐付いていた場合はそちらの連携を解除する。
+func (s *NotificationService) LinkTelegramChat(code, chatID string) (uint, error) {
+	if strings.TrimSpace(code) == "" || chatID == "" {
+		return 0, ErrInvalidTelegramLinkCode
 	}
 
-	jsonPayload, err := json.Marshal(payload)
+	var userID uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var settings models.UserNotificationSettings
+		err := tx.Where("telegram_link_code_hash = ? AND telegram_link_code_expires_at > ?", hashTelegramLinkCode(code), s.now().UTC()).
+			First(&settings).Error
+		if err != nil {
+			return ErrInvalidTelegramLinkCode
+		}
+
+		if err := tx.Model(&models.UserNotificationSettings{}).
+			Where("telegram_chat_id = ? AND user_id <> ?", chatID, settings.UserID).
+			Updates(map[string]interface{}{"telegram_chat_id": "", "telegram_enabled": false}).Error; err != nil {
+			return err
+		}
+
+		userID = settings.UserID
+		return tx.Model(&settings).Updates(map[string]interface{}{
+			"telegram_chat_id":              chatID,
+			"telegram_enabled":              true,
+			"telegram_link_code_hash":       "",
+			"telegram_link_code_expires_at": nil,
+		}).Error
+	})
 	if err != nil {
-		return err
+		return 0, err
 	}
+	return userID, nil
+}
 
-	resp, err := http.Post(apiURL, "application/json", bytes.NewBuffer(jsonPayload))
+// UnlinkTelegram は Telegram との連携を解除し、Telegram 通知を無効にする。
+func (s *NotificationService) UnlinkTelegram(userID uint) error {
+	return s.db.Model(&models.UserNotificationSettings{}).Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"telegram_chat_id": "", "telegram_enabled": false}).Error
+}
+
+// FindUserIDByTelegramChatID は chatID が紐付いているユーザーの ID を返す。
+func (s *NotificationService) FindUserIDByTelegramChatID(chatID string) (uint, error) {
+	var settings models.UserNotificationSettings
+	err := s.db.Where("telegram_chat_id = ?", chatID).First(&settings).Error
 	if err != nil {
-		return err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrTelegramChatNotLinked
+		}
+		return 0, err
 	}
-	defer resp.Body.Close()
+	return settings.UserID, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("telegram API returned status %d", resp.StatusCode)
+func (s *NotificationService) recipient(userID uint) (*NotificationRecipient, error) {
+	settings, err := s.GetUserSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
 	}
+	return &NotificationRecipient{User: &user, Settings: settings}, nil
+}
 
-	return nil
+// Notify はユーザーが有効にしている全チャネル宛ての通知を送信キュー (notification_outbox) に登録し、すぐに送信を試みる。
+// 送信に失敗した通知はワーカーがバックオフしながら再送する。
+func (s *NotificationService) Notify(userID uint, assignmentID *uint, kind string, msg *NotificationMessage) error {
+	recipient, err := s.recipient(userID)
+	if err != nil {
+		return err
+	}
+	return s.notifyRecipient(recipient, assignmentID, kind, msg)
 }
 
-func (s *NotificationService) SendAssignmentReminder(userID uint, assignment *models.Assignment) error {
-	settings, err := s.GetUserSettings(userID)
+func (s *NotificationService) notifyRecipient(recipient *NotificationRecipient, assignmentID *uint, kind string, msg *NotificationMessage) error {
+	entries, err := s.enqueue(s.outboxRepo, recipient, assignmentID, kind, msg)
 	if err != nil {
 		return err
 	}
+	for i := range entries {
+		go s.deliver(&entries[i])
+	}
+	return nil
+}
 
-	message := fmt.Sprintf(
-		"📚 課題リマインダー\n\n【%s】\n科目: %s\n期限: %s\n\n%s",
-		assignment.Title,
-		assignment.Subject,
-		assignment.DueDate.Format("2006/01/02 15:04"),
-		assignment.Description,
-	)
+// enqueue は受信者が有効にしているチャネルごとに通知を1行ずつ登録する。
+func (s *NotificationService) enqueue(repo *repository.NotificationOutboxRepository, recipient *NotificationRecipient, assignmentID *uint, kind string, msg *NotificationMessage) ([]models.NotificationOutbox, error) {
+	now := s.now()
+	var entries []models.NotificationOutbox
+	for _, n := range s.notifiers {
+		if !n.Enabled(recipient) {
+			continue
+		}
+		entry := models.NotificationOutbox{
+			UserID:        recipient.User.ID,
+			AssignmentID:  assignmentID,
+			Kind:          kind,
+			Channel:       n.Name(),
+			Subject:       msg.Subject,
+			Text:          msg.Text,
+			HTML:          msg.HTML,
+			Status:        models.NotificationStatusPending,
+			NextAttemptAt: &now,
+		}
+		if err := repo.Create(&entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
 
-	var errors []string
+// notificationRetryDelay は attempts 回目の失敗後に次の送信まで待つ時間（1分から倍々、最大1時間）。
+func notificationRetryDelay(attempts int) time.Duration {
+	delay := notificationRetryBaseDelay
+	for i := 1; i < attempts && delay < notificationRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > notificationRetryMaxDelay {
+		delay = notificationRetryMaxDelay
+	}
+	return delay
+}
 
-	if settings.TelegramEnabled && settings.TelegramChatID != "" {
-		if err := s.SendTelegramNotification(settings.TelegramChatID, message); err != nil {
-			errors = append(errors, fmt.Sprintf("Telegram: %v", err))
+func (s *NotificationService) deliver(entry *models.NotificationOutbox) {
+	now := s.now()
+	claimed, err := s.outboxRepo.Claim(entry, now.Add(notificationOutboxLease))
+	if err != nil {
+		log.Printf("Error claiming notification %d: %v", entry.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	entry.LastAttemptAt = &now
+
+	err = s.send(entry)
+	if err == nil {
+		sentAt := s.now()
+		entry.Status = models.NotificationStatusSent
+		entry.SentAt = &sentAt
+		entry.NextAttemptAt = nil
+		entry.Error = ""
+	} else {
+		entry.Error = err.Error()
+		if errors.Is(err, errNotificationUndeliverable) || entry.Attempts >= s.maxAttempts {
+			entry.Status = models.NotificationStatusDead
+			entry.NextAttemptAt = nil
+			log.Printf("Notification %d (%s) moved to dead letter after %d attempts: %v", entry.ID, entry.Channel, entry.Attempts, err)
+		} else {
+			next := s.now().Add(notificationRetryDelay(entry.Attempts))
+			entry.NextAttemptAt = &next
 		}
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("notification errors: %s", strings.Join(errors, "; "))
+	if err := s.outboxRepo.Update(entry); err != nil {
+		log.Printf("Error saving notification %d: %v", entry.ID, err)
 	}
-
-	return nil
 }
 
-func (s *NotificationService) SendAssignmentCreatedNotification(userID uint, assignment *models.Assignment) error {
-	settings, err := s.GetUserSettings(userID)
+func (s *NotificationService) send(entry *models.NotificationOutbox) error {
+	n := s.notifier(entry.Channel)
+	if n == nil {
+		return fmt.Errorf("%w: channel %q is not configured", errNotificationUndeliverable, entry.Channel)
+	}
+	recipient, err := s.recipient(entry.UserID)
 	if err != nil {
 		return err
 	}
+	if !n.Enabled(recipient) {
+		return fmt.Errorf("%w: channel %q is disabled by the user", errNotificationUndeliverable, entry.Channel)
+	}
+	return n.Send(recipient, &NotificationMessage{
+		Subject:      entry.Subject,
+		Text:         entry.Text,
+		HTML:         entry.HTML,
+		AssignmentID: entry.AssignmentID,
+	})
+}
 
-	if !settings.NotifyOnCreate {
-		return nil
+// ProcessOutbox は送信時刻を過ぎた通知を送信する。
+func (s *NotificationService) ProcessOutbox() {
+	entries, err := s.outboxRepo.FindDue(s.now(), notificationOutboxBatchSize)
+	if err != nil {
+		log.Printf("Error fetching notification outbox: %v", err)
+		return
+	}
+	for i := range entries {
+		s.deliver(&entries[i])
 	}
+}
 
-	if !settings.TelegramEnabled {
-		return nil
+var notificationHTMLTemplate = template.Must(template.New("notification").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<body style="font-family: sans-serif; color: #212529;">
+<h2 style="font-size: 18px;">{{.Heading}}</h2>
+<p style="font-size: 16px; font-weight: bold;">{{.Title}}</p>
+<table style="border-collapse: collapse;">
+{{range .Rows}}<tr><th style="text-align: left; padding: 2px 12px 2px 0; color: #6c757d;">{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{if .Description}}<p style="white-space: pre-wrap;">{{.Description}}</p>{{end}}
+{{if .Footer}}<p style="color: #6c757d;">{{.Footer}}</p>{{end}}
+</body>
+</html>
+`))
+
+// assignmentMessage は課題通知の本文をテキストと HTML の両方で組み立てる。rows は「項目名, 値」の組。
+func assignmentMessage(heading, title, description string, rows [][2]string, footer string) *NotificationMessage {
+	var text strings.Builder
+	text.WriteString(heading + "\n\n【" + title + "】\n")
+	for _, row := range rows {
+		text.WriteString(row[0] + ": " + row[1] + "\n")
+	}
+	if description != "" {
+		text.WriteString("\n" + description)
+	}
+	if footer != "" {
+		text.WriteString("\n" + footer)
 	}
 
-	message := fmt.Sprintf(
-		"新しい課題が追加されました\n\n【%s】\n科目: %s\n優先度: %s\n期限: %s\n\n%s",
-		assignment.Title,
-		assignment.Subject,
-		getPriorityLabel(assignment.Priority),
-		assignment.DueDate.Format("2006/01/02 15:04"),
-		assignment.Description,
-	)
+	var htmlBody bytes.Buffer
+	err := notificationHTMLTemplate.Execute(&htmlBody, map[string]interface{}{
+		"Heading":     heading,
+		"Title":       title,
+		"Rows":        rows,
+		"Description": description,
+		"Footer":      footer,
+	})
+	if err != nil {
+		htmlBody.Reset()
+	}
 
-	var errors []string
+	return &NotificationMessage{
+		Subject: heading + ": " + title,
+		Text:    strings.TrimRight(text.String(), "\n"),
+		HTML:    htmlBody.String(),
+	}
+}
 
-	if settings.TelegramEnabled && settings.TelegramChatID != "" {
-		if err := s.SendTelegramNotification(settings.TelegramChatID, message); err != nil {
-			errors = append(errors, fmt.Sprintf("Telegram: %v", err))
-		}
+// assignmentReminderMessage はリマインダーの本文を組み立てる。期限は受信者のタイムゾーン loc で表示する。
+func assignmentReminderMessage(assignment *models.Assignment, loc *time.Location) *NotificationMessage {
+	return assignmentMessage("📚 課題リマインダー", assignment.Title, assignment.Description, [][2]string{
+		{"科目", assignment.Subject},
+		{"期限", assignment.DueDate.In(loc).Format("2006/01/02 15:04")},
+	}, "")
+}
+
+func (s *NotificationService) SendAssignmentCreatedNotification(userID uint, assignment *models.Assignment) error {
+	recipient, err := s.recipient(userID)
+	if err != nil {
+		return err
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("notification errors: %s", strings.Join(errors, "; "))
+	if !recipient.Settings.NotifyOnCreate {
+		return nil
 	}
 
-	return nil
+	msg := assignmentMessage("新しい課題が追加されました", assignment.Title, assignment.Description, [][2]string{
+		{"科目", assignment.Subject},
+		{"優先度", GetPriorityLabel(assignment.Priority)},
+		{"期限", assignment.DueDate.In(recipient.User.Location()).Format("2006/01/02 15:04")},
+	}, "")
+
+	return s.notifyRecipient(recipient, &assignment.ID, models.NotificationKindAssignmentCreated, msg)
 }
 
-func getPriorityLabel(priority string) string {
+// GetPriorityLabel は重要度の表示名を返す。
+func GetPriorityLabel(priority string) string {
 	switch priority {
 	case "high":
 		return "大"
@@ -164,12 +426,8 @@ func getPriorityLabel(priority string) string {
 	}
 }
 
-func (s *NotificationService) SendUrgentReminder(userID uint, assignment *models.Assignment) error {
-	settings, err := s.GetUserSettings(userID)
-	if err != nil {
-		return err
-	}
-
+// urgentReminderMessage は督促通知の本文を組み立てる。期限は受信者のタイムゾーン loc で表示する。
+func urgentReminderMessage(assignment *models.Assignment, loc *time.Location) *NotificationMessage {
 	timeRemaining := time.Until(assignment.DueDate)
 	var timeStr string
 	if timeRemaining < 0 {
@@ -190,28 +448,27 @@ func (s *NotificationService) SendUrgentReminder(userID uint, assignment *models
 		priorityEmoji = "📌"
 	}
 
-	message := fmt.Sprintf(
-		"%s 督促通知！\n\n【%s】\n科目: %s\n期限: %s (%s)\n\n完了したらアプリで完了ボタンを押してください！",
-		priorityEmoji,
-		assignment.Title,
-		assignment.Subject,
-		assignment.DueDate.Format("2006/01/02 15:04"),
-		timeStr,
-	)
-
-	var errors []string
-
-	if settings.TelegramEnabled && settings.TelegramChatID != "" {
-		if err := s.SendTelegramNotification(settings.TelegramChatID, message); err != nil {
-			errors = append(errors, fmt.Sprintf("Telegram: %v", err))
-		}
-	}
+	return assignmentMessage(priorityEmoji+" 督促通知！", assignment.Title, "", [][2]string{
+		{"科目", assignment.Subject},
+		{"期限", fmt.Sprintf("%s (%s)", assignment.DueDate.In(loc).Format("2006/01/02 15:04"), timeStr)},
+	}, "完了したらアプリで完了ボタンを押してください！")
+}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("notification errors: %s", strings.Join(errors, "; "))
+// queueAssignmentNotification は通知を送信キューに登録し、同じトランザクションで課題の送信済みフラグを更新する。
+// 登録とフラグ更新が同時に確定するため、途中でプロセスが停止しても通知が失われたり二重に登録されたりしない。
+// 本文は受信者のタイムゾーンを渡して buildMessage で組み立てる。
+func (s *NotificationService) queueAssignmentNotification(assignment *models.Assignment, kind string, buildMessage func(*models.Assignment, *time.Location) *NotificationMessage, column string, value interface{}) error {
+	recipient, err := s.recipient(assignment.UserID)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	msg := buildMessage(assignment, recipient.User.Location())
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.enqueue(s.outboxRepo.WithTx(tx), recipient, &assignment.ID, kind, msg); err != nil {
+			return err
+		}
+		return tx.Model(assignment).Update(column, value).Error
+	})
 }
 
 func getUrgentReminderInterval(priority string) time.Duration {
@@ -228,10 +485,10 @@ func getUrgentReminderInterval(priority string) time.Duration {
 }
 
 func (s *NotificationService) ProcessPendingReminders() {
-	now := time.Now()
+	now := s.now().UTC()
 
 	var assignments []models.Assignment
-	result := database.GetDB().Where(
+	result := s.db.Where(
 		"reminder_enabled = ? AND reminder_sent = ? AND reminder_at <= ? AND is_completed = ?",
 		true, false, now, false,
 	).Find(&assignments)
@@ -242,24 +499,24 @@ func (s *NotificationService) ProcessPendingReminders() {
 	}
 
 	for _, assignment := range assignments {
-		if err := s.SendAssignmentReminder(assignment.UserID, &assignment); err != nil {
-			log.Printf("Error sending reminder for assignment %d: %v", assignment.ID, err)
+		err := s.queueAssignmentNotification(&assignment, models.NotificationKindReminder,
+			assignmentReminderMessage, "reminder_sent", true)
+		if err != nil {
+			log.Printf("Error queueing reminder for assignment %d: %v", assignment.ID, err)
 			continue
 		}
-
-		database.GetDB().Model(&assignment).Update("reminder_sent", true)
-		log.Printf("Sent reminder for assignment %d to user %d", assignment.ID, assignment.UserID)
+		log.Printf("Queued reminder for assignment %d to user %d", assignment.ID, assignment.UserID)
 	}
 }
 
 func (s *NotificationService) ProcessUrgentReminders() {
-	now := time.Now()
+	now := s.now().UTC()
 	urgentStartTime := 3 * time.Hour
 
 	var assignments []models.Assignment
-	result := database.GetDB().Where(
-		"urgent_reminder_enabled = ? AND is_completed = ? AND due_date > ?",
-		true, false, now,
+	result := s.db.Where(
+		"urgent_reminder_enabled = ? AND is_completed = ? AND due_date > ? AND (snoozed_until IS NULL OR snoozed_until <= ?)",
+		true, false, now, now,
 	).Find(&assignments)
 
 	if result.Error != nil {
@@ -283,13 +540,13 @@ func (s *NotificationService) ProcessUrgentReminders() {
 			}
 		}
 
-		if err := s.SendUrgentReminder(assignment.UserID, &assignment); err != nil {
-			log.Printf("Error sending urgent reminder for assignment %d: %v", assignment.ID, err)
+		err := s.queueAssignmentNotification(&assignment, models.NotificationKindUrgentReminder,
+			urgentReminderMessage, "last_urgent_reminder_sent", now)
+		if err != nil {
+			log.Printf("Error queueing urgent reminder for assignment %d: %v", assignment.ID, err)
 			continue
 		}
-
-		database.GetDB().Model(&assignment).Update("last_urgent_reminder_sent", now)
-		log.Printf("Sent urgent reminder for assignment %d (priority: %s) to user %d",
+		log.Printf("Queued urgent reminder for assignment %d (priority: %s) to user %d",
 			assignment.ID, assignment.Priority, assignment.UserID)
 	}
 }
@@ -302,7 +559,8 @@ func (s *NotificationService) StartReminderScheduler() {
 		for range ticker.C {
 			s.ProcessPendingReminders()
 			s.ProcessUrgentReminders()
+			s.ProcessOutbox()
 		}
 	}()
-	log.Println("Reminder scheduler started (one-time + urgent reminders)")
+	log.Println("Reminder scheduler started (one-time + urgent reminders, notification outbox)")
 }
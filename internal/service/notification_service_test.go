@@ -0,0 +1,292 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/telegram/telegramtest"
+	"homework-manager/internal/testutil"
+	"homework-manager/internal/timezone"
+)
+
+// recordingNotifier は送信した通知を記録するテスト用のチャネル。
+type recordingNotifier struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (n *recordingNotifier) Name() string                          { return "test" }
+func (n *recordingNotifier) Enabled(r *NotificationRecipient) bool { return true }
+
+func (n *recordingNotifier) Send(r *NotificationRecipient, msg *NotificationMessage) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, msg.Subject)
+	return nil
+}
+
+func (n *recordingNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.sent)
+}
+
+func TestProcessReminders(t *testing.T) {
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name       string
+		assignment models.Assignment
+		wantKind   string // 空の場合は通知しない
+	}{
+		{
+			name:       "リマインダー時刻を過ぎた",
+			assignment: models.Assignment{DueDate: now.Add(24 * time.Hour), ReminderEnabled: true, ReminderAt: at(-time.Minute)},
+			wantKind:   models.NotificationKindReminder,
+		},
+		{
+			name:       "リマインダー時刻の前",
+			assignment: models.Assignment{DueDate: now.Add(24 * time.Hour), ReminderEnabled: true, ReminderAt: at(time.Minute)},
+		},
+		{
+			name:       "リマインダー送信済み",
+			assignment: models.Assignment{DueDate: now.Add(24 * time.Hour), ReminderEnabled: true, ReminderAt: at(-time.Hour), ReminderSent: true},
+		},
+		{
+			name:       "完了済み",
+			assignment: models.Assignment{DueDate: now.Add(time.Hour), IsCompleted: true, ReminderEnabled: true, ReminderAt: at(-time.Hour)},
+		},
+		{
+			name:       "期限の3時間前から督促",
+			assignment: models.Assignment{DueDate: now.Add(2 * time.Hour), Priority: "high", UrgentReminderEnabled: true},
+			wantKind:   models.NotificationKindUrgentReminder,
+		},
+		{
+			name:       "期限まで3時間以上",
+			assignment: models.Assignment{DueDate: now.Add(4 * time.Hour), Priority: "high", UrgentReminderEnabled: true},
+		},
+		{
+			name:       "期限切れは督促しない",
+			assignment: models.Assignment{DueDate: now.Add(-time.Hour), Priority: "high", UrgentReminderEnabled: true},
+		},
+		{
+			name:       "優先度高は10分間隔",
+			assignment: models.Assignment{DueDate: now.Add(time.Hour), Priority: "high", UrgentReminderEnabled: true, LastUrgentReminderSent: at(-5 * time.Minute)},
+		},
+		{
+			name:       "優先度中は30分間隔",
+			assignment: models.Assignment{DueDate: now.Add(time.Hour), Priority: "medium", UrgentReminderEnabled: true, LastUrgentReminderSent: at(-31 * time.Minute)},
+			wantKind:   models.NotificationKindUrgentReminder,
+		},
+		{
+			name:       "スヌーズ中",
+			assignment: models.Assignment{DueDate: now.Add(time.Hour), Priority: "high", UrgentReminderEnabled: true, SnoozedUntil: at(10 * time.Minute)},
+		},
+		{
+			name:       "スヌーズ終了後",
+			assignment: models.Assignment{DueDate: now.Add(time.Hour), Priority: "high", UrgentReminderEnabled: true, SnoozedUntil: at(-time.Minute)},
+			wantKind:   models.NotificationKindUrgentReminder,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			user := createTestUser(t, db, "reminder@example.com")
+
+			assignment := tt.assignment
+			assignment.UserID = user.ID
+			createTestAssignment(t, db, &assignment)
+			// 既定値が true の列は、false を指定しても作成時に既定値になるため更新し直す
+			if !tt.assignment.UrgentReminderEnabled {
+				db.Model(&assignment).Update("urgent_reminder_enabled", false)
+			}
+
+			notifier := &recordingNotifier{}
+			svc := NewNotificationService(db, config.NotificationConfig{})
+			svc.RegisterNotifier(notifier)
+			svc.now = func() time.Time { return now }
+
+			svc.ProcessPendingReminders()
+			svc.ProcessUrgentReminders()
+
+			var entries []models.NotificationOutbox
+			if err := db.Where("assignment_id = ?", assignment.ID).Find(&entries).Error; err != nil {
+				t.Fatalf("find outbox: %v", err)
+			}
+			if tt.wantKind == "" {
+				if len(entries) != 0 {
+					t.Fatalf("queued %d notification(s), want none", len(entries))
+				}
+				return
+			}
+			if len(entries) != 1 || entries[0].Kind != tt.wantKind || entries[0].Channel != "test" {
+				t.Fatalf("queued %+v, want one %s notification", entries, tt.wantKind)
+			}
+
+			svc.ProcessOutbox()
+			if notifier.count() != 1 {
+				t.Errorf("sent %d notification(s), want 1", notifier.count())
+			}
+
+			// 次の実行では同じ通知を重ねて登録しない
+			svc.ProcessPendingReminders()
+			svc.ProcessUrgentReminders()
+			var count int64
+			db.Model(&models.NotificationOutbox{}).Where("assignment_id = ?", assignment.ID).Count(&count)
+			if count != 1 {
+				t.Errorf("queued %d notification(s) after second run, want 1", count)
+			}
+		})
+	}
+}
+
+func TestProcessUrgentRemindersRepeatsAfterInterval(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "urgent@example.com")
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+	assignment := createTestAssignment(t, db, &models.Assignment{
+		UserID:                user.ID,
+		DueDate:               now.Add(2 * time.Hour),
+		Priority:              "high",
+		UrgentReminderEnabled: true,
+	})
+
+	svc := NewNotificationService(db, config.NotificationConfig{})
+	svc.RegisterNotifier(&recordingNotifier{})
+
+	// 優先度高は10分ごと。5分進めても送らず、10分進めると再び送る
+	for _, step := range []struct {
+		elapsed time.Duration
+		want    int64
+	}{
+		{0, 1},
+		{5 * time.Minute, 1},
+		{10 * time.Minute, 2},
+		{15 * time.Minute, 2},
+		{20 * time.Minute, 3},
+	} {
+		svc.now = func() time.Time { return now.Add(step.elapsed) }
+		svc.ProcessUrgentReminders()
+
+		var count int64
+		db.Model(&models.NotificationOutbox{}).Where("assignment_id = ?", assignment.ID).Count(&count)
+		if count != step.want {
+			t.Errorf("after %v: queued %d notification(s), want %d", step.elapsed, count, step.want)
+		}
+	}
+}
+
+// ユーザーのタイムゾーンで入力したリマインダーは、オフセットの分だけ遅れずにその時刻に送る
+func TestProcessRemindersInUserTimezone(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "tokyo@example.com")
+	db.Model(user).Update("timezone", "Asia/Tokyo")
+
+	tokyo := timezone.Load("Asia/Tokyo")
+	now := time.Now().In(tokyo)
+	reminderAt := now.Add(-time.Minute)
+	assignment, err := NewAssignmentService(db).Create(user.ID, "レポート", "", "", "medium", now.Add(time.Hour), true, &reminderAt, false)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	svc := NewNotificationService(db, config.NotificationConfig{})
+	svc.RegisterNotifier(&recordingNotifier{})
+	svc.ProcessPendingReminders()
+
+	var count int64
+	db.Model(&models.NotificationOutbox{}).Where("assignment_id = ? AND kind = ?", assignment.ID, models.NotificationKindReminder).Count(&count)
+	if count != 1 {
+		t.Errorf("queued %d reminder(s), want 1", count)
+	}
+}
+
+func TestTelegramReminderDelivery(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "telegram@example.com")
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+	reminderAt := now.Add(-time.Minute)
+	assignment := createTestAssignment(t, db, &models.Assignment{
+		UserID:          user.ID,
+		Title:           "英単語 <Unit 3>",
+		DueDate:         now.Add(24 * time.Hour),
+		ReminderEnabled: true,
+		ReminderAt:      &reminderAt,
+	})
+
+	bot := telegramtest.New("123:test-token", "homework_bot")
+	server := httptest.NewServer(bot)
+	t.Cleanup(server.Close)
+
+	svc := NewNotificationService(db, config.NotificationConfig{TelegramBotToken: bot.Token, TelegramAPIURL: server.URL})
+	svc.now = func() time.Time { return now }
+	if err := svc.UpdateUserSettings(user.ID, &models.UserNotificationSettings{TelegramEnabled: true, TelegramChatID: "4242"}); err != nil {
+		t.Fatalf("UpdateUserSettings: %v", err)
+	}
+
+	svc.ProcessPendingReminders()
+	var stored models.Assignment
+	db.First(&stored, assignment.ID)
+	if !stored.ReminderSent {
+		t.Error("reminder_sent not set after queueing")
+	}
+	var entry models.NotificationOutbox
+	if err := db.Where("assignment_id = ?", assignment.ID).First(&entry).Error; err != nil || entry.Channel != ChannelTelegram {
+		t.Fatalf("outbox entry = %+v, %v", entry, err)
+	}
+
+	// Bot API がエラーを返した送信は待ってから再送する
+	bot.Fail("sendMessage", http.StatusTooManyRequests, "Too Many Requests: retry after 5")
+	svc.ProcessOutbox()
+	db.First(&entry, entry.ID)
+	if entry.Status != models.NotificationStatusPending || entry.Attempts != 1 || !strings.Contains(entry.Error, "Too Many Requests") ||
+		entry.NextAttemptAt == nil || !entry.NextAttemptAt.Equal(now.Add(notificationRetryBaseDelay)) {
+		t.Fatalf("after a failed send: %+v", entry)
+	}
+	svc.ProcessOutbox()
+	if len(bot.Sent()) != 0 {
+		t.Fatal("retried before the backoff elapsed")
+	}
+
+	svc.now = func() time.Time { return now.Add(notificationRetryBaseDelay) }
+	svc.ProcessOutbox()
+	sent := bot.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d message(s), want 1", len(sent))
+	}
+	msg := sent[0]
+	if msg.ChatID != "4242" || msg.ParseMode != "HTML" || !strings.Contains(msg.Text, "英単語 &lt;Unit 3&gt;") {
+		t.Errorf("message = %+v", msg)
+	}
+	wantCallback := fmt.Sprintf("done:%d", assignment.ID)
+	if msg.ReplyMarkup == nil || len(msg.ReplyMarkup.InlineKeyboard) != 1 || msg.ReplyMarkup.InlineKeyboard[0][0].CallbackData != wantCallback {
+		t.Errorf("reply markup = %+v, want callback %q", msg.ReplyMarkup, wantCallback)
+	}
+
+	var delivered models.NotificationOutbox
+	db.First(&delivered, entry.ID)
+	if delivered.Status != models.NotificationStatusSent || delivered.Attempts != 2 || delivered.SentAt == nil || delivered.Error != "" || delivered.NextAttemptAt != nil {
+		t.Errorf("after delivery: %+v", delivered)
+	}
+
+	// 送信済みのリマインダーは再び登録しない
+	svc.ProcessPendingReminders()
+	svc.ProcessOutbox()
+	var count int64
+	db.Model(&models.NotificationOutbox{}).Where("assignment_id = ?", assignment.ID).Count(&count)
+	if count != 1 || len(bot.Sent()) != 1 {
+		t.Errorf("%d outbox entries and %d message(s) after running again", count, len(bot.Sent()))
+	}
+}
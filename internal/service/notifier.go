@@ -0,0 +1,89 @@
+package service
+
+import (
+	"html"
+
+	"homework-manager/internal/mail"
+	"homework-manager/internal/models"
+	"homework-manager/internal/telegram"
+)
+
+const (
+	ChannelTelegram = "telegram"
+	ChannelEmail    = "email"
+)
+
+// NotificationMessage はチャネルに依存しない通知内容。HTML を解釈できるチャネルは HTML を、それ以外は Text を使う。
+type NotificationMessage struct {
+	Subject string
+	Text    string
+	HTML    string
+	// AssignmentID が設定されていると、Telegram では「完了にする」ボタンを付ける
+	AssignmentID *uint
+}
+
+// NotificationRecipient は通知先ユーザーとその通知設定。
+type NotificationRecipient struct {
+	User     *models.User
+	Settings *models.UserNotificationSettings
+}
+
+// Notifier は通知チャネル。NotificationService.RegisterNotifier で登録する。
+type Notifier interface {
+	// Name はチャネル名（"telegram"、"email" など）
+	Name() string
+	// Enabled は受信者がこのチャネルでの通知を有効にしていて、送信先が設定されているかを返す
+	Enabled(r *NotificationRecipient) bool
+	Send(r *NotificationRecipient, msg *NotificationMessage) error
+}
+
+type TelegramNotifier struct {
+	client *telegram.Client
+}
+
+func NewTelegramNotifier(client *telegram.Client) *TelegramNotifier {
+	return &TelegramNotifier{client: client}
+}
+
+func (n *TelegramNotifier) Name() string {
+	return ChannelTelegram
+}
+
+func (n *TelegramNotifier) Enabled(r *NotificationRecipient) bool {
+	return r.Settings.TelegramEnabled && r.Settings.TelegramChatID != ""
+}
+
+func (n *TelegramNotifier) Send(r *NotificationRecipient, msg *NotificationMessage) error {
+	var markup *telegram.InlineKeyboardMarkup
+	if msg.AssignmentID != nil {
+		markup = completeButtonMarkup(*msg.AssignmentID)
+	}
+	// parse_mode=HTML で送るため、プレーンテキストをエスケープする
+	return n.client.SendMessage(r.Settings.TelegramChatID, html.EscapeString(msg.Text), markup)
+}
+
+// EmailNotifier はアカウントのメールアドレスに SMTP で通知する。
+type EmailNotifier struct {
+	sender *mail.Sender
+}
+
+func NewEmailNotifier(sender *mail.Sender) *EmailNotifier {
+	return &EmailNotifier{sender: sender}
+}
+
+func (n *EmailNotifier) Name() string {
+	return ChannelEmail
+}
+
+func (n *EmailNotifier) Enabled(r *NotificationRecipient) bool {
+	return r.Settings.EmailEnabled && r.User != nil && r.User.Email != ""
+}
+
+func (n *EmailNotifier) Send(r *NotificationRecipient, msg *NotificationMessage) error {
+	return n.sender.Send(&mail.Message{
+		To:      r.User.Email,
+		Subject: msg.Subject,
+		Text:    msg.Text,
+		HTML:    msg.HTML,
+	})
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/oidc"
+	"homework-manager/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOIDCDisabled         = errors.New("oidc login is disabled")
+	ErrOIDCMissingEmail     = errors.New("identity provider did not return an email address")
+	ErrOIDCEmailNotVerified = errors.New("email address is not verified by the identity provider")
+	ErrOIDCAccountNotFound  = errors.New("no account is linked to this identity")
+	ErrOIDCAccountConflict  = errors.New("account is already linked to another identity")
+)
+
+// OIDCAuthRequest は認可エンドポイントへのリダイレクト先と、コールバックで照合するためにセッションへ保存する値。
+type OIDCAuthRequest struct {
+	URL      string
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+type OIDCService struct {
+	cfg      config.OIDCConfig
+	provider *oidc.Provider
+	userRepo *repository.UserRepository
+}
+
+func NewOIDCService(db *gorm.DB, cfg config.OIDCConfig) *OIDCService {
+	return &OIDCService{
+		cfg:      cfg,
+		provider: oidc.NewProvider(cfg),
+		userRepo: repository.NewUserRepository(db),
+	}
+}
+
+func (s *OIDCService) Enabled() bool {
+	return s.cfg.Enabled && s.provider.Configured()
+}
+
+func (s *OIDCService) ProviderName() string {
+	return s.cfg.ProviderName
+}
+
+// BeginLogin は state、nonce、PKCE の code_verifier を生成し、認可エンドポイントの URL を組み立てる。
+func (s *OIDCService) BeginLogin() (*OIDCAuthRequest, error) {
+	if !s.Enabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	req := &OIDCAuthRequest{}
+	for _, v := range []*string{&req.State, &req.Nonce, &req.Verifier} {
+		value, err := oidc.RandomString()
+		if err != nil {
+			return nil, err
+		}
+		*v = value
+	}
+
+	url, err := s.provider.AuthCodeURL(req.State, req.Nonce, req.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = url
+	return req, nil
+}
+
+// CompleteLogin は認可コードをトークンと交換して ID トークンを検証し、対応するユーザーを返す。
+// 連携済みのユーザーがいなければ、検証済みメールアドレスで既存アカウントに紐付けるか、新しく作成する。
+func (s *OIDCService) CompleteLogin(code, verifier, nonce string) (*models.User, error) {
+	if !s.Enabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	token, err := s.provider.Exchange(code, verifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.provider.VerifyIDToken(token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Email == "" {
+		if err := s.provider.UserInfo(token.AccessToken, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.resolveUser(claims)
+}
+
+func (s *OIDCService) resolveUser(claims *oidc.Claims) (*models.User, error) {
+	user, err := s.userRepo.FindByOIDCSubject(claims.Subject)
+	if err == nil {
+		return s.syncRole(user, claims)
+	}
+
+	if claims.Email == "" {
+		return nil, ErrOIDCMissingEmail
+	}
+
+	if existing, err := s.userRepo.FindByEmail(claims.Email); err == nil {
+		if !s.cfg.LinkByEmail {
+			return nil, ErrOIDCAccountNotFound
+		}
+		// 未検証のメールアドレスで紐付けると、他人のアカウントを乗っ取れてしまう
+		if !claims.EmailVerified {
+			return nil, ErrOIDCEmailNotVerified
+		}
+		if existing.OIDCLinked() {
+			return nil, ErrOIDCAccountConflict
+		}
+		existing.OIDCSubject = claims.Subject
+		if existing.EmailVerifiedAt == nil {
+			now := time.Now().UTC()
+			existing.EmailVerifiedAt = &now
+		}
+		return s.syncRole(existing, claims)
+	}
+
+	if !s.cfg.AutoProvision {
+		return nil, ErrOIDCAccountNotFound
+	}
+	return s.provision(claims)
+}
+
+func (s *OIDCService) provision(claims *oidc.Claims) (*models.User, error) {
+	name := claims.Name
+	if name == "" {
+		name = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+
+	role := "user"
+	if s.cfg.RoleClaim != "" {
+		role = s.mapRole(claims)
+	} else if count, _ := s.userRepo.Count(); count == 0 {
+		role = "admin"
+	}
+
+	user := &models.User{
+		Email:       claims.Email,
+		Name:        name,
+		Role:        role,
+		OIDCSubject: claims.Subject,
+	}
+	if claims.EmailVerified {
+		now := time.Now().UTC()
+		user.EmailVerifiedAt = &now
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// syncRole は role_claim が設定されている場合に、ログインのたびにロールを IdP の値に合わせて保存する。
+func (s *OIDCService) syncRole(user *models.User, claims *oidc.Claims) (*models.User, error) {
+	if s.cfg.RoleClaim != "" {
+		user.Role = s.mapRole(claims)
+	}
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *OIDCService) mapRole(claims *oidc.Claims) string {
+	values := claims.StringValues(s.cfg.RoleClaim)
+	for _, value := range values {
+		for _, adminValue := range s.cfg.AdminValues {
+			if value == adminValue {
+				return "admin"
+			}
+		}
+	}
+	for _, value := range values {
+		for _, teacherValue := range s.cfg.TeacherValues {
+			if value == teacherValue {
+				return "teacher"
+			}
+		}
+	}
+	return "user"
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/oidc"
+	"homework-manager/internal/oidc/oidctest"
+	"homework-manager/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+func newTestOIDCConfig(t *testing.T) (config.OIDCConfig, *oidctest.IdP) {
+	t.Helper()
+	idp, server := oidctest.NewServer("homework-manager")
+	t.Cleanup(server.Close)
+	return config.OIDCConfig{
+		Enabled:       true,
+		ProviderName:  "Mock IdP",
+		Issuer:        server.URL,
+		ClientID:      "homework-manager",
+		RedirectURL:   "http://homework.test/auth/oidc/callback",
+		Scopes:        []string{"openid", "email", "profile"},
+		AutoProvision: true,
+		LinkByEmail:   true,
+	}, idp
+}
+
+// authorizeOIDC は BeginLogin の URL で認可し、リダイレクト先の code を返す。state が一致しない場合はテストを失敗させる。
+func authorizeOIDC(t *testing.T, svc *OIDCService) (string, *OIDCAuthRequest) {
+	t.Helper()
+	req, err := svc.BeginLogin()
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(req.URL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d, location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if state := location.Query().Get("state"); state != req.State {
+		t.Fatalf("state = %q, want %q", state, req.State)
+	}
+	return location.Query().Get("code"), req
+}
+
+// loginOIDC は IdP に claims のユーザーとしてログインする。
+func loginOIDC(t *testing.T, svc *OIDCService, idp *oidctest.IdP, claims map[string]interface{}) (*models.User, error) {
+	t.Helper()
+	idp.SetClaims(claims)
+	code, req := authorizeOIDC(t, svc)
+	return svc.CompleteLogin(code, req.Verifier, req.Nonce)
+}
+
+func reloadUser(t *testing.T, db *gorm.DB, id uint) *models.User {
+	t.Helper()
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		t.Fatalf("reload user: %v", err)
+	}
+	return &user
+}
+
+func TestOIDCLoginVerifiesPKCEAndNonce(t *testing.T) {
+	db := testutil.OpenDB(t)
+	cfg, _ := newTestOIDCConfig(t)
+	svc := NewOIDCService(db, cfg)
+
+	code, req := authorizeOIDC(t, svc)
+	var tokenErr *oidc.TokenError
+	if _, err := svc.CompleteLogin(code, "wrong-verifier", req.Nonce); !errors.As(err, &tokenErr) || tokenErr.Code != "invalid_grant" {
+		t.Errorf("wrong verifier: err = %v, want invalid_grant", err)
+	}
+
+	code, req = authorizeOIDC(t, svc)
+	if _, err := svc.CompleteLogin(code, req.Verifier, "another-nonce"); !errors.Is(err, oidc.ErrNonceMismatch) {
+		t.Errorf("wrong nonce: err = %v, want %v", err, oidc.ErrNonceMismatch)
+	}
+
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d users were created by rejected logins", count)
+	}
+}
+
+func TestOIDCAutoProvision(t *testing.T) {
+	db := testutil.OpenDB(t)
+	cfg, idp := newTestOIDCConfig(t)
+	svc := NewOIDCService(db, cfg)
+
+	// 最初のユーザーは管理者になる
+	first, err := loginOIDC(t, svc, idp, map[string]interface{}{"sub": "sub-1", "email": "first@example.com", "email_verified": true, "name": "First"})
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if first.Role != "admin" || first.OIDCSubject != "sub-1" || first.Name != "First" || first.EmailVerifiedAt == nil {
+		t.Errorf("first user = %+v", first)
+	}
+
+	// 名前がなければメールアドレスのローカル部を使う。未検証のメールアドレスは未確認のままにする
+	second, err := loginOIDC(t, svc, idp, map[string]interface{}{"sub": "sub-2", "email": "second@example.com", "email_verified": false})
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if second.Role != "user" || second.Name != "second" || second.EmailVerifiedAt != nil {
+		t.Errorf("second user = %+v", second)
+	}
+
+	// 2 回目以降は sub で同じユーザーになる
+	again, err := loginOIDC(t, svc, idp, map[string]interface{}{"sub": "sub-1", "email": "changed@example.com", "email_verified": true})
+	if err != nil || again.ID != first.ID {
+		t.Errorf("returning login = %+v, %v, want user %d", again, err, first.ID)
+	}
+
+	// メールアドレスがなければ作成しない
+	if _, err := loginOIDC(t, svc, idp, map[string]interface{}{"sub": "sub-3"}); !errors.Is(err, ErrOIDCMissingEmail) {
+		t.Errorf("missing email: err = %v, want %v", err, ErrOIDCMissingEmail)
+	}
+
+	cfg.AutoProvision = false
+	if _, err := loginOIDC(t, NewOIDCService(db, cfg), idp, map[string]interface{}{"sub": "sub-4", "email": "fourth@example.com", "email_verified": true}); !errors.Is(err, ErrOIDCAccountNotFound) {
+		t.Errorf("auto provisioning disabled: err = %v, want %v", err, ErrOIDCAccountNotFound)
+	}
+}
+
+func TestOIDCLinkByEmail(t *testing.T) {
+	db := testutil.OpenDB(t)
+	cfg, idp := newTestOIDCConfig(t)
+	svc := NewOIDCService(db, cfg)
+	user := createTestUser(t, db, "student@example.com")
+
+	// 未検証のメールアドレスでは既存アカウントに紐付けない
+	if _, err := loginOIDC(t, svc, idp, map[string]interface{}{"sub": "attacker", "email": "student@example.com", "email_verified": false}); !errors.Is(err, ErrOIDCEmailNotVerified) {
+		t.Errorf("unverified email: err = %v, want %v", err, ErrOIDCEmailNotVerified)
+	}
+	if got := reloadUser(t, db, user.ID); got.OIDCLinked() {
+		t.Errorf("linked to %q with an unverified email", got.OIDCSubject)
+	}
+
+	// link_by_email が無効なら紐付けない
+	disabled := cfg
+	disabled.LinkByEmail = false
+	if _, err := loginOIDC(t, NewOIDCService(db, disabled), idp, map[string]interface{}{"sub": "sub-1", "email": "student@example.com", "email_verified": true}); !errors.Is(err, ErrOIDCAccountNotFound) {
+		t.Errorf("linking disabled: err = %v, want %v", err, ErrOIDCAccountNotFound)
+	}
+
+	linked, err := loginOIDC(t, svc, idp, map[string]interface{}{"sub": "sub-1", "email": "student@example.com", "email_verified": true})
+	if err != nil || linked.ID != user.ID {
+		t.Fatalf("link = %+v, %v, want user %d", linked, err, user.ID)
+	}
+	if got := reloadUser(t, db, user.ID); got.OIDCSubject != "sub-1" || got.EmailVerifiedAt == nil || got.Role != "user" {
+		t.Errorf("linked user = %+v", got)
+	}
+
+	// 別の sub で同じメールアドレスのアカウントは乗っ取れない
+	if _, err := loginOIDC(t, svc, idp, map[string]interface{}{"sub": "sub-2", "email": "student@example.com", "email_verified": true}); !errors.Is(err, ErrOIDCAccountConflict) {
+		t.Errorf("second identity: err = %v, want %v", err, ErrOIDCAccountConflict)
+	}
+}
+
+func TestOIDCRoleClaim(t *testing.T) {
+	db := testutil.OpenDB(t)
+	cfg, idp := newTestOIDCConfig(t)
+	cfg.RoleClaim = "realm_access.roles"
+	cfg.AdminValues = []string{"hm-admin"}
+	cfg.TeacherValues = []string{"hm-teacher"}
+	svc := NewOIDCService(db, cfg)
+
+	claims := func(roles ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"sub":            "sub-1",
+			"email":          "teacher@example.com",
+			"email_verified": true,
+			"realm_access":   map[string]interface{}{"roles": roles},
+		}
+	}
+
+	// role_claim を設定している場合、最初のユーザーでも IdP の値でロールを決める
+	user, err := loginOIDC(t, svc, idp, claims("hm-teacher"))
+	if err != nil {
+		t.Fatalf("provision: %v", err)
+	}
+	if user.Role != "teacher" {
+		t.Errorf("provisioned role = %q, want teacher", user.Role)
+	}
+
+	// ログインのたびに同期し、admin の値を teacher の値より優先する
+	tests := []struct {
+		roles []interface{}
+		want  string
+	}{
+		{[]interface{}{"hm-teacher", "hm-admin"}, "admin"},
+		{[]interface{}{"hm-teacher"}, "teacher"},
+		{[]interface{}{"other"}, "user"},
+		{nil, "user"},
+	}
+	for _, tt := range tests {
+		if _, err := loginOIDC(t, svc, idp, claims(tt.roles...)); err != nil {
+			t.Fatalf("roles %v: %v", tt.roles, err)
+		}
+		if got := reloadUser(t, db, user.ID).Role; got != tt.want {
+			t.Errorf("roles %v: role = %q, want %q", tt.roles, got, tt.want)
+		}
+	}
+
+	// メールアドレスで紐付けた既存ユーザーのロールも同期する
+	existing := createTestUser(t, db, "admin@example.com")
+	if _, err := loginOIDC(t, svc, idp, map[string]interface{}{
+		"sub": "sub-2", "email": "admin@example.com", "email_verified": true,
+		"realm_access": map[string]interface{}{"roles": []interface{}{"hm-admin"}},
+	}); err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	if got := reloadUser(t, db, existing.ID).Role; got != "admin" {
+		t.Errorf("linked user role = %q, want admin", got)
+	}
+}
+
+func TestOIDCDisabled(t *testing.T) {
+	db := testutil.OpenDB(t)
+	cfg, _ := newTestOIDCConfig(t)
+	cfg.Enabled = false
+	svc := NewOIDCService(db, cfg)
+	if _, err := svc.BeginLogin(); !errors.Is(err, ErrOIDCDisabled) {
+		t.Errorf("BeginLogin: err = %v, want %v", err, ErrOIDCDisabled)
+	}
+	if _, err := svc.CompleteLogin("code", "verifier", "nonce"); !errors.Is(err, ErrOIDCDisabled) {
+		t.Errorf("CompleteLogin: err = %v, want %v", err, ErrOIDCDisabled)
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// RecoveryCodeCount は一度に発行するリカバリーコードの数。
+	RecoveryCodeCount = 10
+	// recoveryCodeLength はハイフンを除いたコードの文字数（base32 で 50 ビット）。
+	recoveryCodeLength = 10
+)
+
+type RecoveryCodeService struct {
+	repo *repository.RecoveryCodeRepository
+}
+
+func NewRecoveryCodeService(db *gorm.DB) *RecoveryCodeService {
+	return &RecoveryCodeService{
+		repo: repository.NewRecoveryCodeRepository(db),
+	}
+}
+
+// Generate は新しいリカバリーコードを発行し、平文を返す。以前のコードはすべて無効になる。
+func (s *RecoveryCodeService) Generate(userID uint) ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		})
+	}
+	if err := s.repo.Replace(userID, records); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Remaining は未使用のリカバリーコードの数を返す。
+func (s *RecoveryCodeService) Remaining(userID uint) int64 {
+	count, err := s.repo.CountUnused(userID)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+// Use はリカバリーコードを検証して使用済みにする。各コードは 1 回だけ使える。
+func (s *RecoveryCodeService) Use(userID uint, code string) bool {
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryCodeLength {
+		return false
+	}
+	used, err := s.repo.MarkUsed(userID, hashRecoveryCode(normalized), time.Now())
+	return err == nil && used
+}
+
+// generateRecoveryCode は読み取りやすいように "xxxxx-xxxxx" 形式のコードを生成する。
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:recoveryCodeLength]
+	return code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:], nil
+}
+
+// normalizeRecoveryCode は入力のハイフン・空白と大文字小文字の違いを吸収する。
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '-' || r == ' ' || r == '\t':
+			return -1
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return r
+	}, strings.TrimSpace(code))
+}
+
+func hashRecoveryCode(code string) string {
+	hash := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"homework-manager/internal/testutil"
+)
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "recovery@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	svc := NewRecoveryCodeService(db)
+
+	codes, err := svc.Generate(user.ID)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if len(codes) != RecoveryCodeCount || svc.Remaining(user.ID) != RecoveryCodeCount {
+		t.Fatalf("generated %d code(s), %d remaining", len(codes), svc.Remaining(user.ID))
+	}
+
+	tests := []struct {
+		name   string
+		userID uint
+		code   string
+		want   bool
+	}{
+		{"未使用のコード", user.ID, codes[0], true},
+		{"使用済みのコード", user.ID, codes[0], false},
+		{"大文字・ハイフンなしでも使える", user.ID, strings.ToUpper(strings.ReplaceAll(codes[1], "-", "")), true},
+		{"別のユーザーのコード", other.ID, codes[2], false},
+		{"形式が違う", user.ID, "abc", false},
+		{"存在しないコード", user.ID, "aaaaa-aaaaa", false},
+	}
+	for _, tt := range tests {
+		if got := svc.Use(tt.userID, tt.code); got != tt.want {
+			t.Errorf("%s: Use(%d, %q) = %v, want %v", tt.name, tt.userID, tt.code, got, tt.want)
+		}
+	}
+	if remaining := svc.Remaining(user.ID); remaining != RecoveryCodeCount-2 {
+		t.Errorf("%d code(s) remaining, want %d", remaining, RecoveryCodeCount-2)
+	}
+}
+
+// 再発行すると、使っていないものも含めて以前のコードはすべて使えなくなる
+func TestRecoveryCodeRegenerationInvalidatesOldCodes(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "regenerate@example.com")
+	svc := NewRecoveryCodeService(db)
+
+	oldCodes, err := svc.Generate(user.ID)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !svc.Use(user.ID, oldCodes[0]) {
+		t.Fatal("Use of a new code failed")
+	}
+
+	newCodes, err := svc.Generate(user.ID)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if remaining := svc.Remaining(user.ID); remaining != RecoveryCodeCount {
+		t.Errorf("%d code(s) remaining after regeneration, want %d", remaining, RecoveryCodeCount)
+	}
+	for _, code := range oldCodes[1:] {
+		if svc.Use(user.ID, code) {
+			t.Errorf("old code %q is still valid", code)
+		}
+	}
+	if !svc.Use(user.ID, newCodes[0]) {
+		t.Error("new code is not valid")
+	}
+}
@@ -3,12 +3,16 @@ package service
 import (
 	"errors"
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
 	"time"
 
 	"homework-manager/internal/models"
 	"homework-manager/internal/repository"
+	"homework-manager/internal/rrule"
+
+	"gorm.io/gorm"
 )
 
 var (
@@ -16,17 +20,27 @@ var (
 	ErrRecurringUnauthorized       = errors.New("unauthorized")
 	ErrInvalidRecurrenceType       = errors.New("invalid recurrence type")
 	ErrInvalidEndType              = errors.New("invalid end type")
+	ErrInvalidRecurrenceRule       = errors.New("invalid recurrence rule")
+	ErrInvalidExDates              = errors.New("invalid exception dates")
 )
 
 type RecurringAssignmentService struct {
 	recurringRepo  *repository.RecurringAssignmentRepository
 	assignmentRepo *repository.AssignmentRepository
+	subjectRepo    *repository.SubjectRepository
+	tagRepo        *repository.TagRepository
+	userRepo       *repository.UserRepository
+	webhookService *WebhookService
 }
 
-func NewRecurringAssignmentService() *RecurringAssignmentService {
+func NewRecurringAssignmentService(db *gorm.DB) *RecurringAssignmentService {
 	return &RecurringAssignmentService{
-		recurringRepo:  repository.NewRecurringAssignmentRepository(),
-		assignmentRepo: repository.NewAssignmentRepository(),
+		recurringRepo:  repository.NewRecurringAssignmentRepository(db),
+		assignmentRepo: repository.NewAssignmentRepository(db),
+		subjectRepo:    repository.NewSubjectRepository(db),
+		tagRepo:        repository.NewTagRepository(db),
+		userRepo:       repository.NewUserRepository(db),
+		webhookService: NewWebhookService(db),
 	}
 }
 
@@ -38,7 +52,11 @@ type CreateRecurringAssignmentInput struct {
 	RecurrenceType        string
 	RecurrenceInterval    int
 	RecurrenceWeekday     *int
+	RecurrenceWeekdays    []int
 	RecurrenceDay         *int
+	RecurrenceOrdinal     *int
+	RRule                 string
+	ExDates               string
 	DueTime               string
 	EndType               string
 	EndCount              *int
@@ -47,6 +65,9 @@ type CreateRecurringAssignmentInput struct {
 	ReminderEnabled       bool
 	ReminderOffset        *int
 	UrgentReminderEnabled bool
+	Checklist             string
+	ChecklistAutoComplete bool
+	Tags                  []string
 	FirstDueDate          time.Time
 }
 
@@ -66,33 +87,74 @@ func (s *RecurringAssignmentService) Create(userID uint, input CreateRecurringAs
 		input.EditBehavior = models.EditBehaviorThisOnly
 	}
 
+	weekdays := input.RecurrenceWeekdays
+	if len(weekdays) == 0 && input.RecurrenceWeekday != nil {
+		weekdays = []int{*input.RecurrenceWeekday}
+	}
+	spec := RecurrenceSpec{
+		Type:     input.RecurrenceType,
+		Interval: input.RecurrenceInterval,
+		Weekdays: weekdays,
+		Day:      input.RecurrenceDay,
+		Ordinal:  input.RecurrenceOrdinal,
+		RRule:    input.RRule,
+	}
+
+	subject, err := resolveSubject(s.subjectRepo, userID, input.Subject)
+	if err != nil {
+		return nil, err
+	}
+	subjectID, subjectName := subjectRef(subject)
+	priority := input.Priority
+	reminderOffset := input.ReminderOffset
+	if subject != nil {
+		if priority == "" {
+			priority = subject.DefaultPriority
+		}
+		if input.ReminderEnabled && reminderOffset == nil {
+			reminderOffset = subject.DefaultReminderOffset
+		}
+	}
+
+	// 期限の時刻（DueTime）は所有者のタイムゾーンの時刻として適用する
+	loc := userLocation(s.userRepo, userID)
+	firstDueDate := input.FirstDueDate.In(loc)
+	startDate := applyDueTime(input.DueTime, firstDueDate).UTC()
 	recurring := &models.RecurringAssignment{
 		UserID:                userID,
 		Title:                 input.Title,
 		Description:           input.Description,
-		Subject:               input.Subject,
-		Priority:              input.Priority,
-		RecurrenceType:        input.RecurrenceType,
-		RecurrenceInterval:    input.RecurrenceInterval,
-		RecurrenceWeekday:     input.RecurrenceWeekday,
-		RecurrenceDay:         input.RecurrenceDay,
+		SubjectID:             subjectID,
+		Subject:               subjectName,
+		Priority:              priority,
+		StartDate:             &startDate,
 		DueTime:               input.DueTime,
 		EndType:               input.EndType,
 		EndCount:              input.EndCount,
-		EndDate:               input.EndDate,
+		EndDate:               utcPtr(input.EndDate),
 		EditBehavior:          input.EditBehavior,
 		ReminderEnabled:       input.ReminderEnabled,
-		ReminderOffset:        input.ReminderOffset,
+		ReminderOffset:        reminderOffset,
 		UrgentReminderEnabled: input.UrgentReminderEnabled,
+		Checklist:             strings.Join(models.ParseChecklistTitles(input.Checklist), "\n"),
+		ChecklistAutoComplete: input.ChecklistAutoComplete,
+		Tags:                  joinTagNames(input.Tags),
 		IsActive:              true,
 		GeneratedCount:        0,
 	}
 
+	if err := applyRecurrence(recurring, spec, loc); err != nil {
+		return nil, err
+	}
+	if err := setExDates(recurring, input.ExDates, loc); err != nil {
+		return nil, err
+	}
+
 	if err := s.recurringRepo.Create(recurring); err != nil {
 		return nil, err
 	}
 
-	if err := s.generateAssignment(recurring, input.FirstDueDate); err != nil {
+	if _, err := s.generateAssignment(recurring, firstDueDate); err != nil {
 		return nil, err
 	}
 
@@ -128,7 +190,11 @@ type UpdateRecurringInput struct {
 	RecurrenceType        *string
 	RecurrenceInterval    *int
 	RecurrenceWeekday     *int
+	RecurrenceWeekdays    []int
 	RecurrenceDay         *int
+	RecurrenceOrdinal     *int // 0 で曜日指定を解除
+	RRule                 *string
+	ExDates               *string
 	DueTime               *string
 	EndType               *string
 	EndCount              *int
@@ -137,6 +203,9 @@ type UpdateRecurringInput struct {
 	ReminderEnabled       *bool
 	ReminderOffset        *int
 	UrgentReminderEnabled *bool
+	Checklist             *string
+	ChecklistAutoComplete *bool
+	Tags                  *[]string
 }
 
 func (s *RecurringAssignmentService) Update(userID, recurringID uint, input UpdateRecurringInput) (*models.RecurringAssignment, error) {
@@ -152,7 +221,11 @@ func (s *RecurringAssignmentService) Update(userID, recurringID uint, input Upda
 		recurring.Description = *input.Description
 	}
 	if input.Subject != nil {
-		recurring.Subject = *input.Subject
+		subject, err := resolveSubject(s.subjectRepo, userID, *input.Subject)
+		if err != nil {
+			return nil, err
+		}
+		recurring.SubjectID, recurring.Subject = subjectRef(subject)
 	}
 	if input.Priority != nil {
 		recurring.Priority = *input.Priority
@@ -172,18 +245,41 @@ func (s *RecurringAssignmentService) Update(userID, recurringID uint, input Upda
 	if input.UrgentReminderEnabled != nil {
 		recurring.UrgentReminderEnabled = *input.UrgentReminderEnabled
 	}
+	if input.Checklist != nil {
+		recurring.Checklist = strings.Join(models.ParseChecklistTitles(*input.Checklist), "\n")
+	}
+	if input.ChecklistAutoComplete != nil {
+		recurring.ChecklistAutoComplete = *input.ChecklistAutoComplete
+	}
+	if input.Tags != nil {
+		recurring.Tags = joinTagNames(*input.Tags)
+	}
+
+	recurrenceChanged := input.RecurrenceType != nil || input.RecurrenceInterval != nil ||
+		input.RecurrenceWeekday != nil || input.RecurrenceWeekdays != nil || input.RecurrenceDay != nil ||
+		input.RecurrenceOrdinal != nil || input.RRule != nil ||
+		input.EndType != nil || input.EndCount != nil || input.EndDate != nil
+	spec := RecurrenceSpecFor(recurring)
 
 	if input.RecurrenceType != nil && *input.RecurrenceType != "" && isValidRecurrenceType(*input.RecurrenceType) {
-		recurring.RecurrenceType = *input.RecurrenceType
+		spec.Type = *input.RecurrenceType
 	}
 	if input.RecurrenceInterval != nil && *input.RecurrenceInterval > 0 {
-		recurring.RecurrenceInterval = *input.RecurrenceInterval
+		spec.Interval = *input.RecurrenceInterval
 	}
-	if input.RecurrenceWeekday != nil {
-		recurring.RecurrenceWeekday = input.RecurrenceWeekday
+	if input.RecurrenceWeekdays != nil {
+		spec.Weekdays = input.RecurrenceWeekdays
+	} else if input.RecurrenceWeekday != nil {
+		spec.Weekdays = []int{*input.RecurrenceWeekday}
 	}
 	if input.RecurrenceDay != nil {
-		recurring.RecurrenceDay = input.RecurrenceDay
+		spec.Day = input.RecurrenceDay
+	}
+	if input.RecurrenceOrdinal != nil {
+		spec.Ordinal = input.RecurrenceOrdinal
+	}
+	if input.RRule != nil {
+		spec.RRule = *input.RRule
 	}
 
 	if input.EndType != nil && isValidEndType(*input.EndType) {
@@ -193,7 +289,19 @@ func (s *RecurringAssignmentService) Update(userID, recurringID uint, input Upda
 		recurring.EndCount = input.EndCount
 	}
 	if input.EndDate != nil {
-		recurring.EndDate = input.EndDate
+		recurring.EndDate = utcPtr(input.EndDate)
+	}
+
+	loc := userLocation(s.userRepo, userID)
+	if recurrenceChanged {
+		if err := applyRecurrence(recurring, spec, loc); err != nil {
+			return nil, err
+		}
+	}
+	if input.ExDates != nil {
+		if err := setExDates(recurring, *input.ExDates, loc); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := s.recurringRepo.Update(recurring); err != nil {
@@ -216,13 +324,18 @@ func (s *RecurringAssignmentService) SetActive(userID, recurringID uint, isActiv
 func (s *RecurringAssignmentService) UpdateAssignmentWithBehavior(
 	userID uint,
 	assignment *models.Assignment,
-	title, description, subject, priority string,
+	title, description, subjectName, priority string,
 	dueDate time.Time,
 	reminderEnabled bool,
 	reminderAt *time.Time,
 	urgentReminderEnabled bool,
 	editBehavior string,
 ) error {
+	subject, err := resolveSubject(s.subjectRepo, userID, subjectName)
+	if err != nil {
+		return err
+	}
+
 	if assignment.RecurringAssignmentID == nil {
 		return s.updateSingleAssignment(assignment, title, description, subject, priority, dueDate, reminderEnabled, reminderAt, urgentReminderEnabled)
 	}
@@ -242,7 +355,7 @@ func (s *RecurringAssignmentService) UpdateAssignmentWithBehavior(
 		}
 		recurring.Title = title
 		recurring.Description = description
-		recurring.Subject = subject
+		recurring.SubjectID, recurring.Subject = subjectRef(subject)
 		recurring.Priority = priority
 		recurring.UrgentReminderEnabled = urgentReminderEnabled
 		if err := s.recurringRepo.Update(recurring); err != nil {
@@ -253,7 +366,7 @@ func (s *RecurringAssignmentService) UpdateAssignmentWithBehavior(
 	case models.EditBehaviorAll:
 		recurring.Title = title
 		recurring.Description = description
-		recurring.Subject = subject
+		recurring.SubjectID, recurring.Subject = subjectRef(subject)
 		recurring.Priority = priority
 		recurring.UrgentReminderEnabled = urgentReminderEnabled
 		if err := s.recurringRepo.Update(recurring); err != nil {
@@ -268,7 +381,9 @@ func (s *RecurringAssignmentService) UpdateAssignmentWithBehavior(
 
 func (s *RecurringAssignmentService) updateSingleAssignment(
 	assignment *models.Assignment,
-	title, description, subject, priority string,
+	title, description string,
+	subject *models.Subject,
+	priority string,
 	dueDate time.Time,
 	reminderEnabled bool,
 	reminderAt *time.Time,
@@ -276,19 +391,29 @@ func (s *RecurringAssignmentService) updateSingleAssignment(
 ) error {
 	assignment.Title = title
 	assignment.Description = description
-	assignment.Subject = subject
+	assignment.SubjectID, assignment.Subject = subjectRef(subject)
 	assignment.Priority = priority
-	assignment.DueDate = dueDate
+	if !assignment.DueDate.Equal(dueDate) {
+		assignment.OverdueNotifiedAt = nil
+	}
+	assignment.DueDate = dueDate.UTC()
 	assignment.ReminderEnabled = reminderEnabled
-	assignment.ReminderAt = reminderAt
+	assignment.ReminderAt = utcPtr(reminderAt)
 	assignment.UrgentReminderEnabled = urgentReminderEnabled
-	return s.assignmentRepo.Update(assignment)
+	if err := s.assignmentRepo.Update(assignment); err != nil {
+		return err
+	}
+
+	s.webhookService.Dispatch(assignment.UserID, models.WebhookEventAssignmentUpdated, assignment)
+	return nil
 }
 
 func (s *RecurringAssignmentService) updateFutureAssignments(
 	recurringID uint,
 	fromDate time.Time,
-	title, description, subject, priority string,
+	title, description string,
+	subject *models.Subject,
+	priority string,
 	urgentReminderEnabled bool,
 ) error {
 	assignments, err := s.recurringRepo.GetFutureAssignmentsByRecurringID(recurringID, fromDate)
@@ -302,19 +427,22 @@ func (s *RecurringAssignmentService) updateFutureAssignments(
 		}
 		a.Title = title
 		a.Description = description
-		a.Subject = subject
+		a.SubjectID, a.Subject = subjectRef(subject)
 		a.Priority = priority
 		a.UrgentReminderEnabled = urgentReminderEnabled
 		if err := s.assignmentRepo.Update(&a); err != nil {
 			return err
 		}
+		s.webhookService.Dispatch(a.UserID, models.WebhookEventAssignmentUpdated, &a)
 	}
 	return nil
 }
 
 func (s *RecurringAssignmentService) updateAllPendingAssignments(
 	recurringID uint,
-	title, description, subject, priority string,
+	title, description string,
+	subject *models.Subject,
+	priority string,
 	urgentReminderEnabled bool,
 ) error {
 	assignments, err := s.recurringRepo.GetAssignmentsByRecurringID(recurringID)
@@ -328,12 +456,13 @@ func (s *RecurringAssignmentService) updateAllPendingAssignments(
 		}
 		a.Title = title
 		a.Description = description
-		a.Subject = subject
+		a.SubjectID, a.Subject = subjectRef(subject)
 		a.Priority = priority
 		a.UrgentReminderEnabled = urgentReminderEnabled
 		if err := s.assignmentRepo.Update(&a); err != nil {
 			return err
 		}
+		s.webhookService.Dispatch(a.UserID, models.WebhookEventAssignmentUpdated, &a)
 	}
 	return nil
 }
@@ -350,8 +479,11 @@ func (s *RecurringAssignmentService) Delete(userID, recurringID uint, deleteFutu
 			return err
 		}
 		for _, a := range assignments {
-			if !a.IsCompleted {
-				s.assignmentRepo.Delete(a.ID)
+			if a.IsCompleted {
+				continue
+			}
+			if err := s.assignmentRepo.Delete(a.ID); err == nil {
+				s.webhookService.Dispatch(a.UserID, models.WebhookEventAssignmentDeleted, &a)
 			}
 		}
 	}
@@ -359,49 +491,126 @@ func (s *RecurringAssignmentService) Delete(userID, recurringID uint, deleteFutu
 	return s.recurringRepo.Delete(recurring.ID)
 }
 
-func (s *RecurringAssignmentService) GenerateNextAssignments() error {
+// 1回のパスで1つの繰り返し設定から生成する最大件数（長期停止後の暴走防止）
+const maxCatchUpPerPass = 100
+
+// GenerateNextAssignments は直近の回が完了済み、または期限を過ぎた繰り返し設定について次回以降の課題を生成する。
+// サーバー停止中に経過した回もまとめて生成し、同じ期限の回が既にあれば作成しない。
+func (s *RecurringAssignmentService) GenerateNextAssignments() (int, error) {
 	recurrings, err := s.recurringRepo.FindDueForGeneration()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	for _, recurring := range recurrings {
-		pendingCount, err := s.recurringRepo.CountPendingByRecurringID(recurring.ID)
+	generated := 0
+	for i := range recurrings {
+		n, err := s.generateDueInstances(&recurrings[i], time.Now())
+		generated += n
 		if err != nil {
-			continue
+			log.Printf("Error generating recurring assignment %d: %v", recurrings[i].ID, err)
 		}
+	}
 
-		if pendingCount == 0 {
-			latest, err := s.recurringRepo.GetLatestAssignmentByRecurringID(recurring.ID)
-			if err != nil {
-				continue
-			}
+	return generated, nil
+}
 
-			var nextDueDate time.Time
-			if latest != nil {
-				nextDueDate = recurring.CalculateNextDueDate(latest.DueDate)
-			} else {
-				nextDueDate = time.Now()
-			}
+func (s *RecurringAssignmentService) generateDueInstances(recurring *models.RecurringAssignment, now time.Time) (int, error) {
+	latest, err := s.recurringRepo.GetLatestAssignmentByRecurringID(recurring.ID)
+	if err != nil || latest == nil {
+		return 0, err
+	}
 
-			if nextDueDate.After(time.Now()) {
-				s.generateAssignment(&recurring, nextDueDate)
+	// 繰り返しの展開は所有者のタイムゾーンで行う（「毎週月曜」の月曜はユーザーにとっての月曜）
+	loc := userLocation(s.userRepo, recurring.UserID)
+	generated := 0
+	for generated < maxCatchUpPerPass {
+		if !recurring.ShouldGenerateNext() {
+			break
+		}
+		if !latest.IsCompleted && !latest.DeletedAt.Valid && latest.DueDate.After(now) {
+			break
+		}
+
+		nextDueDate := applyDueTime(recurring.DueTime, recurring.CalculateNextDueDate(latest.DueDate.In(loc)))
+		if !nextDueDate.After(latest.DueDate) {
+			break
+		}
+		if recurring.EndType == models.EndTypeDate && recurring.EndDate != nil {
+			if nextDueDate.After(models.EndOfDay(recurring.EndDate.In(nextDueDate.Location()))) {
+				recurring.IsActive = false
+				return generated, s.recurringRepo.Update(recurring)
 			}
 		}
+
+		existing, err := s.recurringRepo.FindAssignmentByDueDate(recurring.ID, nextDueDate)
+		if err != nil {
+			return generated, err
+		}
+		if existing != nil {
+			latest = existing
+			continue
+		}
+
+		assignment, err := s.generateAssignment(recurring, nextDueDate)
+		if err != nil {
+			return generated, err
+		}
+
+		log.Printf("Generated assignment %d from recurring %d for user %d (due: %s)",
+			assignment.ID, recurring.ID, recurring.UserID, assignment.DueDate.Format("2006/01/02 15:04"))
+		generated++
+		latest = assignment
 	}
 
-	return nil
+	return generated, nil
+}
+
+// StartGenerationScheduler は繰り返し課題の生成を定期実行する。起動直後にも1回実行し、停止中に経過した回を補完する。
+func (s *RecurringAssignmentService) StartGenerationScheduler(interval time.Duration) {
+	go func() {
+		s.runGenerationPass()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.runGenerationPass()
+		}
+	}()
+	log.Printf("Recurring assignment scheduler started (interval: %s)", interval)
+}
+
+func (s *RecurringAssignmentService) runGenerationPass() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered from panic in recurring assignment scheduler: %v", r)
+		}
+	}()
+
+	generated, err := s.GenerateNextAssignments()
+	if err != nil {
+		log.Printf("Error generating recurring assignments: %v", err)
+		return
+	}
+	if generated > 0 {
+		log.Printf("Generated %d recurring assignment(s)", generated)
+	}
 }
 
-func (s *RecurringAssignmentService) generateAssignment(recurring *models.RecurringAssignment, dueDate time.Time) error {
-	if recurring.DueTime != "" {
-		parts := strings.Split(recurring.DueTime, ":")
+func applyDueTime(dueTime string, dueDate time.Time) time.Time {
+	if dueTime != "" {
+		parts := strings.Split(dueTime, ":")
 		if len(parts) == 2 {
 			hour, _ := strconv.Atoi(parts[0])
 			minute, _ := strconv.Atoi(parts[1])
 			dueDate = time.Date(dueDate.Year(), dueDate.Month(), dueDate.Day(), hour, minute, 0, 0, dueDate.Location())
 		}
 	}
+	return dueDate
+}
+
+func (s *RecurringAssignmentService) generateAssignment(recurring *models.RecurringAssignment, dueDate time.Time) (*models.Assignment, error) {
+	dueDate = applyDueTime(recurring.DueTime, dueDate)
 
 	var reminderAt *time.Time
 	if recurring.ReminderEnabled && recurring.ReminderOffset != nil {
@@ -409,25 +618,39 @@ func (s *RecurringAssignmentService) generateAssignment(recurring *models.Recurr
 		reminderAt = &t
 	}
 
+	tags, err := resolveTags(s.tagRepo, recurring.UserID, recurring.TagNames())
+	if err != nil {
+		return nil, err
+	}
+
 	assignment := &models.Assignment{
 		UserID:                userID(recurring.UserID),
 		Title:                 recurring.Title,
 		Description:           recurring.Description,
+		SubjectID:             recurring.SubjectID,
 		Subject:               recurring.Subject,
 		Priority:              recurring.Priority,
-		DueDate:               dueDate,
+		DueDate:               dueDate.UTC(),
 		ReminderEnabled:       recurring.ReminderEnabled,
-		ReminderAt:            reminderAt,
+		ReminderAt:            utcPtr(reminderAt),
 		UrgentReminderEnabled: recurring.UrgentReminderEnabled,
+		ChecklistAutoComplete: recurring.ChecklistAutoComplete,
+		ChecklistItems:        checklistItemsFromTitles(recurring.ChecklistTitles()),
+		Tags:                  tags,
 		RecurringAssignmentID: &recurring.ID,
 	}
 
 	if err := s.assignmentRepo.Create(assignment); err != nil {
-		return err
+		return nil, err
 	}
 
 	recurring.GeneratedCount++
-	return s.recurringRepo.Update(recurring)
+	if err := s.recurringRepo.Update(recurring); err != nil {
+		return nil, err
+	}
+
+	s.webhookService.Dispatch(assignment.UserID, models.WebhookEventRecurringGenerated, assignment)
+	return assignment, nil
 }
 
 func userID(id uint) uint {
@@ -436,7 +659,7 @@ func userID(id uint) uint {
 
 func isValidRecurrenceType(t string) bool {
 	switch t {
-	case models.RecurrenceNone, models.RecurrenceDaily, models.RecurrenceWeekly, models.RecurrenceMonthly:
+	case models.RecurrenceNone, models.RecurrenceDaily, models.RecurrenceWeekly, models.RecurrenceMonthly, models.RecurrenceCustom:
 		return true
 	}
 	return false
@@ -458,6 +681,8 @@ func GetRecurrenceTypeLabel(t string) string {
 		return "毎週"
 	case models.RecurrenceMonthly:
 		return "毎月"
+	case models.RecurrenceCustom:
+		return "カスタム"
 	default:
 		return "なし"
 	}
@@ -474,37 +699,18 @@ func GetEndTypeLabel(t string) string {
 	}
 }
 
-func FormatRecurringSummary(recurring *models.RecurringAssignment) string {
+// FormatRecurringSummary は繰り返し設定の概要を返す。終了日は loc の日付で表示する。
+func FormatRecurringSummary(recurring *models.RecurringAssignment, loc *time.Location) string {
 	if recurring.RecurrenceType == models.RecurrenceNone {
 		return ""
 	}
 
-	var parts []string
-
-	typeLabel := GetRecurrenceTypeLabel(recurring.RecurrenceType)
-	if recurring.RecurrenceInterval > 1 {
-		switch recurring.RecurrenceType {
-		case models.RecurrenceDaily:
-			parts = append(parts, fmt.Sprintf("%d日ごと", recurring.RecurrenceInterval))
-		case models.RecurrenceWeekly:
-			parts = append(parts, fmt.Sprintf("%d週間ごと", recurring.RecurrenceInterval))
-		case models.RecurrenceMonthly:
-			parts = append(parts, fmt.Sprintf("%dヶ月ごと", recurring.RecurrenceInterval))
-		}
-	} else {
-		parts = append(parts, typeLabel)
-	}
-
-	if recurring.RecurrenceType == models.RecurrenceWeekly && recurring.RecurrenceWeekday != nil {
-		weekdays := []string{"日", "月", "火", "水", "木", "金", "土"}
-		if *recurring.RecurrenceWeekday >= 0 && *recurring.RecurrenceWeekday < 7 {
-			parts = append(parts, fmt.Sprintf("(%s曜日)", weekdays[*recurring.RecurrenceWeekday]))
-		}
+	rule, err := recurring.Rule()
+	if err != nil {
+		return recurring.RRule
 	}
 
-	if recurring.RecurrenceType == models.RecurrenceMonthly && recurring.RecurrenceDay != nil {
-		parts = append(parts, fmt.Sprintf("(%d日)", *recurring.RecurrenceDay))
-	}
+	parts := []string{rule.Summary()}
 
 	switch recurring.EndType {
 	case models.EndTypeCount:
@@ -513,9 +719,187 @@ func FormatRecurringSummary(recurring *models.RecurringAssignment) string {
 		}
 	case models.EndTypeDate:
 		if recurring.EndDate != nil {
-			parts = append(parts, fmt.Sprintf("/ %sまで", recurring.EndDate.Format("2006/01/02")))
+			parts = append(parts, fmt.Sprintf("/ %sまで", recurring.EndDate.In(loc).Format("2006/01/02")))
 		}
 	}
 
+	if exdates, err := rrule.ParseExDates(recurring.ExDates, time.Local); err == nil && len(exdates) > 0 {
+		parts = append(parts, fmt.Sprintf("/ 除外日%d件", len(exdates)))
+	}
+
 	return strings.Join(parts, " ")
 }
+
+// RecurrenceSpec は入力フォーム・API の繰り返し指定。BuildRule で RRULE に変換する。
+type RecurrenceSpec struct {
+	Type     string
+	Interval int
+	Weekdays []int // 0 = 日曜日
+	Day      *int  // 毎月の日付指定
+	Ordinal  *int  // 毎月の「第N曜日」指定 (-1 = 最終)
+	RRule    string
+}
+
+// RecurrenceSpecFor は保存済みの繰り返し設定をフォーム向けの指定に戻す。
+func RecurrenceSpecFor(recurring *models.RecurringAssignment) RecurrenceSpec {
+	spec := RecurrenceSpec{
+		Type:     recurring.RecurrenceType,
+		Interval: recurring.RecurrenceInterval,
+		Day:      recurring.RecurrenceDay,
+		RRule:    recurring.RRule,
+	}
+
+	rule, err := recurring.Rule()
+	if err != nil {
+		return spec
+	}
+	for _, wd := range rule.ByDay {
+		spec.Weekdays = append(spec.Weekdays, int(wd.Day))
+		if wd.N != 0 {
+			n := wd.N
+			spec.Ordinal = &n
+		}
+	}
+	if len(rule.ByDay) > 0 && len(rule.ByMonthDay) == 0 && len(rule.BySetPos) == 1 {
+		n := rule.BySetPos[0]
+		spec.Ordinal = &n
+	}
+	if spec.Day == nil && len(rule.ByMonthDay) > 0 {
+		day := rule.ByMonthDay[len(rule.ByMonthDay)-1]
+		spec.Day = &day
+	}
+	return spec
+}
+
+func (spec RecurrenceSpec) HasWeekday(day int) bool {
+	for _, wd := range spec.Weekdays {
+		if wd == day {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWeekdays は月〜金がすべて指定されている（「平日」指定）かどうかを返す。
+func (spec RecurrenceSpec) IsWeekdays() bool {
+	if len(spec.Weekdays) != 5 {
+		return false
+	}
+	for day := 1; day <= 5; day++ {
+		if !spec.HasWeekday(day) {
+			return false
+		}
+	}
+	return true
+}
+
+func (spec RecurrenceSpec) ByOrdinal() bool {
+	return spec.Ordinal != nil && *spec.Ordinal != 0 && len(spec.Weekdays) > 0
+}
+
+// BuildRule は終了条件を含まない RRULE を組み立てる。タイムゾーン指定のない UNTIL は loc の時刻として扱う。
+func (spec RecurrenceSpec) BuildRule(loc *time.Location) (*rrule.Rule, error) {
+	if spec.Type == models.RecurrenceCustom {
+		rule, err := rrule.ParseInLocation(spec.RRule, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrenceRule, err)
+		}
+		return rule, nil
+	}
+
+	rule := &rrule.Rule{Interval: spec.Interval, WeekStart: time.Monday}
+	if rule.Interval < 1 {
+		rule.Interval = 1
+	}
+
+	var byDay []rrule.Weekday
+	for _, wd := range spec.Weekdays {
+		if wd < 0 || wd > 6 {
+			return nil, ErrInvalidRecurrenceRule
+		}
+		byDay = append(byDay, rrule.Weekday{Day: time.Weekday(wd)})
+	}
+
+	switch spec.Type {
+	case models.RecurrenceNone, models.RecurrenceDaily:
+		rule.Freq = rrule.Daily
+	case models.RecurrenceWeekly:
+		rule.Freq = rrule.Weekly
+		rule.ByDay = byDay
+	case models.RecurrenceMonthly:
+		rule.Freq = rrule.Monthly
+		if spec.ByOrdinal() {
+			n := *spec.Ordinal
+			if n < -1 || n > 5 {
+				return nil, ErrInvalidRecurrenceRule
+			}
+			if len(byDay) == 1 {
+				byDay[0].N = n
+			} else {
+				rule.BySetPos = []int{n}
+			}
+			rule.ByDay = byDay
+		} else if spec.Day != nil {
+			if *spec.Day < 1 || *spec.Day > 31 {
+				return nil, ErrInvalidRecurrenceRule
+			}
+			rule.ByMonthDay, rule.BySetPos = rrule.ClampedMonthDay(*spec.Day)
+		}
+	default:
+		return nil, ErrInvalidRecurrenceType
+	}
+	return rule, nil
+}
+
+// applyRecurrence は spec と終了条件から RRULE を組み立てて recurring に保存する。
+// カスタム RRULE に COUNT / UNTIL がある場合はそちらを終了条件として優先する。終了日は loc の日付として扱う。
+func applyRecurrence(recurring *models.RecurringAssignment, spec RecurrenceSpec, loc *time.Location) error {
+	rule, err := spec.BuildRule(loc)
+	if err != nil {
+		return err
+	}
+
+	if spec.Type == models.RecurrenceCustom && (rule.Count > 0 || !rule.Until.IsZero()) {
+		if rule.Count > 0 {
+			count := rule.Count
+			recurring.EndType = models.EndTypeCount
+			recurring.EndCount = &count
+		} else {
+			until := rule.Until.UTC()
+			recurring.EndType = models.EndTypeDate
+			recurring.EndDate = &until
+		}
+	} else {
+		rule.Count, rule.Until = 0, time.Time{}
+		switch recurring.EndType {
+		case models.EndTypeCount:
+			if recurring.EndCount != nil && *recurring.EndCount > 0 {
+				rule.Count = *recurring.EndCount
+			}
+		case models.EndTypeDate:
+			if recurring.EndDate != nil {
+				rule.Until = models.EndOfDay(recurring.EndDate.In(loc))
+			}
+		}
+	}
+
+	recurring.RecurrenceType = spec.Type
+	recurring.RecurrenceInterval = rule.Interval
+	recurring.RecurrenceWeekday = nil
+	if len(spec.Weekdays) > 0 {
+		weekday := spec.Weekdays[0]
+		recurring.RecurrenceWeekday = &weekday
+	}
+	recurring.RecurrenceDay = spec.Day
+	recurring.RRule = rule.String()
+	return nil
+}
+
+func setExDates(recurring *models.RecurringAssignment, value string, loc *time.Location) error {
+	exdates, err := rrule.ParseExDates(value, loc)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidExDates, err)
+	}
+	recurring.ExDates = rrule.FormatExDates(exdates)
+	return nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/testutil"
+)
+
+func TestUpdateAssignmentWithBehavior(t *testing.T) {
+	base := time.Date(2026, 4, 10, 9, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	// 各ケースで更新後のタイトルを確認する課題: 過去の回・編集する回・以降の回・以降の完了済みの回
+	tests := []struct {
+		name          string
+		behavior      string
+		wantTitles    [4]string
+		wantRecurring string
+	}{
+		{
+			name:          "この回のみ",
+			behavior:      models.EditBehaviorThisOnly,
+			wantTitles:    [4]string{"元", "新", "元", "元"},
+			wantRecurring: "元",
+		},
+		{
+			name:          "この回以降",
+			behavior:      models.EditBehaviorThisAndFuture,
+			wantTitles:    [4]string{"元", "新", "新", "元"},
+			wantRecurring: "新",
+		},
+		{
+			name:          "すべての回",
+			behavior:      models.EditBehaviorAll,
+			wantTitles:    [4]string{"新", "新", "新", "元"},
+			wantRecurring: "新",
+		},
+		{
+			name:          "不明な指定はこの回のみ",
+			behavior:      "unknown",
+			wantTitles:    [4]string{"元", "新", "元", "元"},
+			wantRecurring: "元",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			user := createTestUser(t, db, "recurring@example.com")
+			svc := NewRecurringAssignmentService(db)
+
+			recurring, err := svc.Create(user.ID, CreateRecurringAssignmentInput{
+				Title:          "元",
+				Subject:        "数学",
+				Priority:       "medium",
+				RecurrenceType: models.RecurrenceDaily,
+				DueTime:        "09:00",
+				EndType:        models.EndTypeNever,
+				FirstDueDate:   base,
+			})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			var target models.Assignment
+			if err := db.Where("recurring_assignment_id = ?", recurring.ID).First(&target).Error; err != nil {
+				t.Fatalf("find generated assignment: %v", err)
+			}
+			completedAt := base
+			past := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, Title: "元", RecurringAssignmentID: &recurring.ID, DueDate: base.Add(-2 * day)})
+			future := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, Title: "元", RecurringAssignmentID: &recurring.ID, DueDate: base.Add(2 * day)})
+			done := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, Title: "元", RecurringAssignmentID: &recurring.ID, DueDate: base.Add(3 * day), IsCompleted: true, CompletedAt: &completedAt})
+
+			err = svc.UpdateAssignmentWithBehavior(user.ID, &target, "新", "", "数学", "high", target.DueDate, false, nil, true, tt.behavior)
+			if err != nil {
+				t.Fatalf("UpdateAssignmentWithBehavior: %v", err)
+			}
+
+			for i, id := range []uint{past.ID, target.ID, future.ID, done.ID} {
+				var got models.Assignment
+				if err := db.First(&got, id).Error; err != nil {
+					t.Fatalf("find assignment %d: %v", id, err)
+				}
+				if got.Title != tt.wantTitles[i] {
+					t.Errorf("assignment[%d].Title = %q, want %q", i, got.Title, tt.wantTitles[i])
+				}
+			}
+
+			updated, err := svc.GetByID(user.ID, recurring.ID)
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if updated.Title != tt.wantRecurring {
+				t.Errorf("recurring.Title = %q, want %q", updated.Title, tt.wantRecurring)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/sessionstore"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// ActiveSession はプロフィール画面に表示するログイン中のセッション。
+type ActiveSession struct {
+	models.Session
+	Device  string // ユーザーエージェントから判定したブラウザと OS
+	Current bool   // この画面を表示しているセッション
+}
+
+// SessionService はサーバー側に保存したログインセッションを管理する。
+// セッション ID は Cookie に入っている値（sessions.Session の ID()）をそのまま受け取る。
+type SessionService struct {
+	backend sessionstore.Backend
+}
+
+func NewSessionService(backend sessionstore.Backend) *SessionService {
+	return &SessionService{backend: backend}
+}
+
+// ListForUser はユーザーの有効なセッションを返す。currentID のセッションには Current を付けて先頭にする。
+func (s *SessionService) ListForUser(userID uint, currentID string) ([]ActiveSession, error) {
+	sessions, err := s.backend.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	current := ""
+	if currentID != "" {
+		current = sessionstore.HashID(currentID)
+	}
+	result := make([]ActiveSession, 0, len(sessions))
+	for _, session := range sessions {
+		active := ActiveSession{
+			Session: session,
+			Device:  DescribeUserAgent(session.UserAgent),
+			Current: session.ID == current,
+		}
+		if active.Current {
+			result = append([]ActiveSession{active}, result...)
+		} else {
+			result = append(result, active)
+		}
+	}
+	return result, nil
+}
+
+// Revoke はユーザーのセッションを 1 つ無効化する。id は ListForUser で返した ActiveSession の ID。
+func (s *SessionService) Revoke(userID uint, id string) error {
+	session, err := s.backend.Find(id)
+	if err != nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	return s.backend.Delete(id)
+}
+
+// RevokeOthers は currentID 以外のユーザーのセッションをすべて無効化し、無効化した数を返す。
+func (s *SessionService) RevokeOthers(userID uint, currentID string) (int64, error) {
+	except := ""
+	if currentID != "" {
+		except = sessionstore.HashID(currentID)
+	}
+	return s.backend.DeleteByUser(userID, except)
+}
+
+// RevokeAll はユーザーのセッションをすべて無効化する。
+func (s *SessionService) RevokeAll(userID uint) (int64, error) {
+	return s.backend.DeleteByUser(userID, "")
+}
+
+// Touch はセッションの最終アクセス日時と接続元を記録する。
+func (s *SessionService) Touch(sessionID, ip, userAgent string) error {
+	if sessionID == "" {
+		return nil
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	return s.backend.Touch(sessionstore.HashID(sessionID), ip, userAgent, time.Now())
+}
+
+// StartCleanupScheduler は期限切れのセッションを定期的に削除する。
+func (s *SessionService) StartCleanupScheduler(interval time.Duration) {
+	go func() {
+		s.runCleanup()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.runCleanup()
+		}
+	}()
+	log.Printf("Session cleanup scheduler started (interval: %s)", interval)
+}
+
+func (s *SessionService) runCleanup() {
+	deleted, err := s.backend.DeleteExpired(time.Now())
+	if err != nil {
+		log.Printf("Error deleting expired sessions: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Deleted %d expired sessions", deleted)
+	}
+}
+
+// DescribeUserAgent はユーザーエージェントを「Chrome (Windows)」のような表示用の文字列にする。
+func DescribeUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "不明なデバイス"
+	}
+
+	browser := ""
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"), strings.Contains(userAgent, "FxiOS/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"), strings.Contains(userAgent, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		os = "iOS"
+	case strings.Contains(userAgent, "Android"):
+		os = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		os = "Windows"
+	case strings.Contains(userAgent, "CrOS"):
+		os = "ChromeOS"
+	case strings.Contains(userAgent, "Mac OS X"), strings.Contains(userAgent, "Macintosh"):
+		os = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		os = "Linux"
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " (" + os + ")"
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	}
+	if len(userAgent) > 60 {
+		return userAgent[:60] + "…"
+	}
+	return userAgent
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/sessionstore"
+	"homework-manager/internal/testutil"
+)
+
+// createTestSessions は Cookie の ID ごとにユーザーのセッションを作る。
+func createTestSessions(t *testing.T, backend sessionstore.Backend, userID uint, cookieIDs ...string) {
+	t.Helper()
+	for _, id := range cookieIDs {
+		session := &models.Session{
+			ID:        sessionstore.HashID(id),
+			UserID:    userID,
+			ExpiresAt: time.Now().Add(time.Hour),
+		}
+		if err := backend.Create(session); err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+	}
+}
+
+// remainingSessions はユーザーの有効なセッションの Cookie の ID を返す。
+func remainingSessions(t *testing.T, backend sessionstore.Backend, userID uint, cookieIDs ...string) []string {
+	t.Helper()
+	var remaining []string
+	for _, id := range cookieIDs {
+		if session, err := backend.Find(sessionstore.HashID(id)); err == nil && session.UserID == userID {
+			remaining = append(remaining, id)
+		}
+	}
+	sort.Strings(remaining)
+	return remaining
+}
+
+func TestSessionRevocation(t *testing.T) {
+	const password = "password123"
+
+	tests := []struct {
+		name          string
+		revoke        func(t *testing.T, auth *AuthService, sessions *SessionService, userID uint) error
+		wantRemaining []string
+	}{
+		{
+			name: "パスワードの変更",
+			revoke: func(t *testing.T, auth *AuthService, sessions *SessionService, userID uint) error {
+				return auth.ChangePassword(userID, "current", password, "new-password")
+			},
+			wantRemaining: []string{"current"},
+		},
+		{
+			name: "2段階認証の無効化",
+			revoke: func(t *testing.T, auth *AuthService, sessions *SessionService, userID uint) error {
+				if err := auth.EnableTOTP(userID, "JBSWY3DPEHPK3PXP", 0); err != nil {
+					t.Fatalf("EnableTOTP: %v", err)
+				}
+				return auth.DisableTOTP(userID, "current")
+			},
+			wantRemaining: []string{"current"},
+		},
+		{
+			name: "パスワードの再設定",
+			revoke: func(t *testing.T, auth *AuthService, sessions *SessionService, userID uint) error {
+				return auth.ResetPassword(userID, "new-password")
+			},
+			wantRemaining: nil,
+		},
+		{
+			name: "他のセッションをすべてログアウト",
+			revoke: func(t *testing.T, auth *AuthService, sessions *SessionService, userID uint) error {
+				revoked, err := sessions.RevokeOthers(userID, "current")
+				if revoked != 2 {
+					t.Errorf("RevokeOthers revoked %d session(s), want 2", revoked)
+				}
+				return err
+			},
+			wantRemaining: []string{"current"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			backend := sessionstore.NewMemoryBackend()
+			auth := NewAuthService(db, backend)
+			user, err := auth.Register("sessions@example.com", password, "セッション")
+			if err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+			other := createTestUser(t, db, "other@example.com")
+
+			createTestSessions(t, backend, user.ID, "current", "laptop", "phone")
+			createTestSessions(t, backend, other.ID, "other")
+
+			if err := tt.revoke(t, auth, NewSessionService(backend), user.ID); err != nil {
+				t.Fatalf("revoke: %v", err)
+			}
+
+			got := remainingSessions(t, backend, user.ID, "current", "laptop", "phone")
+			if strings.Join(got, ",") != strings.Join(tt.wantRemaining, ",") {
+				t.Errorf("remaining sessions = %v, want %v", got, tt.wantRemaining)
+			}
+			// 他のユーザーのセッションは残る
+			if got := remainingSessions(t, backend, other.ID, "other"); len(got) != 1 {
+				t.Errorf("other user's sessions = %v, want [other]", got)
+			}
+		})
+	}
+}
+
+// パスワードが違う場合は変更せず、セッションも残す
+func TestChangePasswordWithWrongPasswordKeepsSessions(t *testing.T) {
+	db := testutil.OpenDB(t)
+	backend := sessionstore.NewMemoryBackend()
+	auth := NewAuthService(db, backend)
+	user, err := auth.Register("wrong@example.com", "password123", "セッション")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	createTestSessions(t, backend, user.ID, "current", "laptop")
+
+	if err := auth.ChangePassword(user.ID, "current", "wrong-password", "new-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("ChangePassword error = %v, want ErrInvalidCredentials", err)
+	}
+	if got := remainingSessions(t, backend, user.ID, "current", "laptop"); len(got) != 2 {
+		t.Errorf("remaining sessions = %v, want both", got)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	db := testutil.OpenDB(t)
+	backend := sessionstore.NewMemoryBackend()
+	user := createTestUser(t, db, "revoke@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	createTestSessions(t, backend, user.ID, "current", "laptop")
+	createTestSessions(t, backend, other.ID, "other")
+	svc := NewSessionService(backend)
+
+	list, err := svc.ListForUser(user.ID, "current")
+	if err != nil || len(list) != 2 || !list[0].Current || list[1].Current {
+		t.Fatalf("ListForUser = %+v, %v, want the current session first", list, err)
+	}
+
+	// 他のユーザーのセッションは無効化できない
+	if err := svc.Revoke(user.ID, sessionstore.HashID("other")); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Revoke of another user's session: %v, want ErrSessionNotFound", err)
+	}
+	if err := svc.Revoke(user.ID, list[1].ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if got := remainingSessions(t, backend, user.ID, "current", "laptop"); len(got) != 1 || got[0] != "current" {
+		t.Errorf("remaining sessions = %v, want [current]", got)
+	}
+	if got := remainingSessions(t, backend, other.ID, "other"); len(got) != 1 {
+		t.Errorf("other user's sessions = %v, want [other]", got)
+	}
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/validation"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSubjectNotFound  = errors.New("subject not found")
+	ErrSubjectNameTaken = errors.New("subject name already exists")
+	ErrSubjectMergeSelf = errors.New("cannot merge a subject into itself")
+)
+
+// SubjectReminderOffsets は科目の既定のリマインダーとして画面で選べる、期限の何分前かの一覧。
+var SubjectReminderOffsets = []int{30, 60, 180, 720, 1440, 2880, 4320, 10080}
+
+// FormatReminderOffset は期限の何分前かを「3時間前」「1日前」のような表示にする。
+func FormatReminderOffset(minutes int) string {
+	switch {
+	case minutes == 0:
+		return "期限の時刻"
+	case minutes%(24*60) == 0:
+		return fmt.Sprintf("%d日前", minutes/(24*60))
+	case minutes%60 == 0:
+		return fmt.Sprintf("%d時間前", minutes/60)
+	default:
+		return fmt.Sprintf("%d分前", minutes)
+	}
+}
+
+// SubjectInput は科目の作成・更新の入力。Color が空の場合は既定の色にする。
+type SubjectInput struct {
+	Name                  string
+	Color                 string
+	Teacher               string
+	Room                  string
+	DefaultPriority       string
+	DefaultReminderOffset *int
+}
+
+type SubjectService struct {
+	subjectRepo *repository.SubjectRepository
+}
+
+func NewSubjectService(db *gorm.DB) *SubjectService {
+	return &SubjectService{
+		subjectRepo: repository.NewSubjectRepository(db),
+	}
+}
+
+// List はユーザーの科目を名前順に返す。
+func (s *SubjectService) List(userID uint, includeArchived bool) ([]models.Subject, error) {
+	return s.subjectRepo.FindByUserID(userID, includeArchived)
+}
+
+// Counts は科目ごとの課題の数を返す。
+func (s *SubjectService) Counts(userID uint) map[uint]int64 {
+	counts, err := s.subjectRepo.CountAssignmentsByUserID(userID)
+	if err != nil {
+		return map[uint]int64{}
+	}
+	return counts
+}
+
+// Colors は科目名ごとの色を返す。一覧画面で課題の科目を色分けするのに使う。
+func (s *SubjectService) Colors(userID uint) map[string]string {
+	subjects, err := s.subjectRepo.FindByUserID(userID, true)
+	colors := make(map[string]string, len(subjects))
+	if err != nil {
+		return colors
+	}
+	for _, subject := range subjects {
+		colors[subject.Name] = subject.Color
+	}
+	return colors
+}
+
+func (s *SubjectService) Get(userID, subjectID uint) (*models.Subject, error) {
+	subject, err := s.subjectRepo.FindByID(subjectID)
+	if err != nil || subject.UserID != userID {
+		return nil, ErrSubjectNotFound
+	}
+	return subject, nil
+}
+
+// FindByName は名前の科目を返す。ない場合は nil を返す。
+func (s *SubjectService) FindByName(userID uint, name string) *models.Subject {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	subject, err := s.subjectRepo.FindByName(userID, name)
+	if err != nil {
+		return nil
+	}
+	return subject
+}
+
+func (s *SubjectService) Create(userID uint, input SubjectInput) (*models.Subject, error) {
+	input.Name = strings.TrimSpace(input.Name)
+	if err := validateSubjectInput(input); err != nil {
+		return nil, err
+	}
+	if _, err := s.subjectRepo.FindByName(userID, input.Name); err == nil {
+		return nil, ErrSubjectNameTaken
+	}
+
+	subject := &models.Subject{UserID: userID}
+	applySubjectInput(subject, input)
+	if err := s.subjectRepo.Create(subject); err != nil {
+		return nil, err
+	}
+	return subject, nil
+}
+
+// Update は科目を更新する。名前を変えた場合は、課題・繰り返し設定に保持している科目名も書き換える。
+func (s *SubjectService) Update(userID, subjectID uint, input SubjectInput) (*models.Subject, error) {
+	subject, err := s.Get(userID, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if err := validateSubjectInput(input); err != nil {
+		return nil, err
+	}
+
+	renamed := input.Name != subject.Name
+	if renamed {
+		if existing, err := s.subjectRepo.FindByName(userID, input.Name); err == nil && existing.ID != subject.ID {
+			return nil, ErrSubjectNameTaken
+		}
+	}
+
+	applySubjectInput(subject, input)
+	if renamed {
+		err = s.subjectRepo.Rename(subject)
+	} else {
+		err = s.subjectRepo.Update(subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return subject, nil
+}
+
+// SetArchived は科目をアーカイブする（解除する）。アーカイブした科目の課題は統計・カレンダーに含めない。
+func (s *SubjectService) SetArchived(userID, subjectID uint, archived bool) (*models.Subject, error) {
+	subject, err := s.Get(userID, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	subject.IsArchived = archived
+	if err := s.subjectRepo.Update(subject); err != nil {
+		return nil, err
+	}
+	return subject, nil
+}
+
+// Merge は sourceID の科目の課題・繰り返し設定を targetID の科目に移し、sourceID の科目を削除する。
+func (s *SubjectService) Merge(userID, sourceID, targetID uint) (*models.Subject, error) {
+	if sourceID == targetID {
+		return nil, ErrSubjectMergeSelf
+	}
+	source, err := s.Get(userID, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.Get(userID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.subjectRepo.Merge(source, target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// Delete は科目を削除する。課題・繰り返し設定は削除せず、科目なしにする。
+func (s *SubjectService) Delete(userID, subjectID uint) (*models.Subject, error) {
+	subject, err := s.Get(userID, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.subjectRepo.Delete(subject.ID); err != nil {
+		return nil, err
+	}
+	return subject, nil
+}
+
+func validateSubjectInput(input SubjectInput) error {
+	return validation.ValidateSubjectInput(input.Name, input.Color, input.Teacher, input.Room, input.DefaultPriority, input.DefaultReminderOffset)
+}
+
+func applySubjectInput(subject *models.Subject, input SubjectInput) {
+	subject.Name = input.Name
+	subject.Color = strings.ToLower(input.Color)
+	if subject.Color == "" {
+		subject.Color = models.DefaultSubjectColor
+	}
+	subject.Teacher = strings.TrimSpace(input.Teacher)
+	subject.Room = strings.TrimSpace(input.Room)
+	subject.DefaultPriority = input.DefaultPriority
+	subject.DefaultReminderOffset = input.DefaultReminderOffset
+}
+
+// resolveSubject は課題・繰り返し設定に付ける科目を名前から探し、なければ作成する。名前が空の場合は nil を返す。
+func resolveSubject(subjectRepo *repository.SubjectRepository, userID uint, name string) (*models.Subject, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	if subject, err := subjectRepo.FindByName(userID, name); err == nil {
+		return subject, nil
+	}
+
+	subject := &models.Subject{UserID: userID, Name: name, Color: models.DefaultSubjectColor}
+	if err := subjectRepo.Create(subject); err != nil {
+		// 同時に同じ科目が作られた場合は作られた方を使う
+		if existing, findErr := subjectRepo.FindByName(userID, name); findErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return subject, nil
+}
+
+// subjectRef は課題・繰り返し設定に保存する科目IDと科目名を返す。
+func subjectRef(subject *models.Subject) (*uint, string) {
+	if subject == nil {
+		return nil, ""
+	}
+	id := subject.ID
+	return &id, subject.Name
+}
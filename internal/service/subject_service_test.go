@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/testutil"
+)
+
+func TestSubjectRenameAndMerge(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "subjects@example.com")
+	svc := NewSubjectService(db)
+	assignments := NewAssignmentService(db)
+
+	due := time.Now().Add(24 * time.Hour)
+	math, err := assignments.Create(user.ID, "計算ドリル", "", "数学", "", due, false, nil, true)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	algebra, _ := assignments.Create(user.ID, "因数分解", "", "代数", "", due, false, nil, true)
+	recurring, err := NewRecurringAssignmentService(db).Create(user.ID, CreateRecurringAssignmentInput{
+		Title: "小テスト", Subject: "代数", RecurrenceType: models.RecurrenceWeekly, RecurrenceInterval: 1,
+		DueTime: "09:00", EndType: models.EndTypeNever, FirstDueDate: due,
+	})
+	if err != nil {
+		t.Fatalf("Create recurring: %v", err)
+	}
+
+	mathSubject := svc.FindByName(user.ID, "数学")
+	if mathSubject == nil || math.SubjectID == nil || *math.SubjectID != mathSubject.ID {
+		t.Fatalf("assignment refers to %v, want subject %+v", math.SubjectID, mathSubject)
+	}
+
+	// 名前の変更は課題に保持している科目名も書き換える
+	renamed, err := svc.Update(user.ID, mathSubject.ID, SubjectInput{Name: "数学I", Color: "#FF0000"})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if renamed.Color != "#ff0000" {
+		t.Errorf("Color = %q, want lower case", renamed.Color)
+	}
+	if got, _ := assignments.GetByID(user.ID, math.ID); got.Subject != "数学I" {
+		t.Errorf("assignment subject after rename = %q, want 数学I", got.Subject)
+	}
+	if _, err := svc.Update(user.ID, mathSubject.ID, SubjectInput{Name: "代数"}); !errors.Is(err, ErrSubjectNameTaken) {
+		t.Errorf("rename to an existing name: err = %v, want %v", err, ErrSubjectNameTaken)
+	}
+
+	// 統合は課題・繰り返し設定を移して統合元を削除する
+	algebraSubject := svc.FindByName(user.ID, "代数")
+	if _, err := svc.Merge(user.ID, algebraSubject.ID, algebraSubject.ID); !errors.Is(err, ErrSubjectMergeSelf) {
+		t.Errorf("merge into itself: err = %v, want %v", err, ErrSubjectMergeSelf)
+	}
+	if _, err := svc.Merge(user.ID, algebraSubject.ID, mathSubject.ID); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	got, _ := assignments.GetByID(user.ID, algebra.ID)
+	if got.Subject != "数学I" || got.SubjectID == nil || *got.SubjectID != mathSubject.ID {
+		t.Errorf("merged assignment = %v/%q, want %d/数学I", got.SubjectID, got.Subject, mathSubject.ID)
+	}
+	var merged models.RecurringAssignment
+	db.First(&merged, recurring.ID)
+	if merged.Subject != "数学I" || merged.SubjectID == nil || *merged.SubjectID != mathSubject.ID {
+		t.Errorf("merged recurring assignment = %v/%q, want %d/数学I", merged.SubjectID, merged.Subject, mathSubject.ID)
+	}
+	if _, err := svc.Get(user.ID, algebraSubject.ID); !errors.Is(err, ErrSubjectNotFound) {
+		t.Errorf("source subject after merge: err = %v, want %v", err, ErrSubjectNotFound)
+	}
+	// 繰り返し設定の最初の課題を含む
+	if count := svc.Counts(user.ID)[mathSubject.ID]; count != 3 {
+		t.Errorf("count after merge = %d, want 3", count)
+	}
+
+	// 削除しても課題は残り、科目なしになる
+	if _, err := svc.Delete(user.ID, mathSubject.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got, err := assignments.GetByID(user.ID, math.ID); err != nil || got.Subject != "" || got.SubjectID != nil {
+		t.Errorf("assignment after deleting the subject = %+v, %v", got, err)
+	}
+}
+
+func TestSubjectDefaults(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "defaults@example.com")
+	svc := NewSubjectService(db)
+
+	offset := 24 * 60
+	if _, err := svc.Create(user.ID, SubjectInput{Name: "英語", DefaultPriority: "high", DefaultReminderOffset: &offset}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	due := time.Date(2030, 1, 10, 9, 0, 0, 0, time.UTC)
+	assignment, err := NewAssignmentService(db).Create(user.ID, "単語", "", "英語", "", due, false, nil, true)
+	if err != nil {
+		t.Fatalf("Create assignment: %v", err)
+	}
+	if assignment.Priority != "high" {
+		t.Errorf("Priority = %q, want the subject's default", assignment.Priority)
+	}
+	explicit, _ := NewAssignmentService(db).Create(user.ID, "作文", "", "英語", "low", due, false, nil, true)
+	if explicit.Priority != "low" {
+		t.Errorf("Priority = %q, want the given priority", explicit.Priority)
+	}
+
+	recurring, err := NewRecurringAssignmentService(db).Create(user.ID, CreateRecurringAssignmentInput{
+		Title: "小テスト", Subject: "英語", RecurrenceType: models.RecurrenceWeekly, RecurrenceInterval: 1,
+		DueTime: "09:00", EndType: models.EndTypeNever, FirstDueDate: due, ReminderEnabled: true,
+	})
+	if err != nil {
+		t.Fatalf("Create recurring: %v", err)
+	}
+	if recurring.Priority != "high" || recurring.ReminderOffset == nil || *recurring.ReminderOffset != offset {
+		t.Errorf("recurring priority/offset = %q/%v, want high/%d", recurring.Priority, recurring.ReminderOffset, offset)
+	}
+
+	if got := svc.FindByName(user.ID, "英語").DefaultReminderAt(due); got == nil || !got.Equal(due.Add(-24*time.Hour)) {
+		t.Errorf("DefaultReminderAt = %v, want a day before the due date", got)
+	}
+}
+
+func TestSubjectOwnership(t *testing.T) {
+	db := testutil.OpenDB(t)
+	owner := createTestUser(t, db, "owner@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	svc := NewSubjectService(db)
+
+	subject, err := svc.Create(owner.ID, SubjectInput{Name: "物理"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	otherSubject, _ := svc.Create(other.ID, SubjectInput{Name: "物理"})
+
+	if _, err := svc.Get(other.ID, subject.ID); !errors.Is(err, ErrSubjectNotFound) {
+		t.Errorf("Get: err = %v, want %v", err, ErrSubjectNotFound)
+	}
+	if _, err := svc.Update(other.ID, subject.ID, SubjectInput{Name: "化学"}); !errors.Is(err, ErrSubjectNotFound) {
+		t.Errorf("Update: err = %v, want %v", err, ErrSubjectNotFound)
+	}
+	if _, err := svc.Merge(other.ID, otherSubject.ID, subject.ID); !errors.Is(err, ErrSubjectNotFound) {
+		t.Errorf("Merge into another user's subject: err = %v, want %v", err, ErrSubjectNotFound)
+	}
+	if _, err := svc.Delete(other.ID, subject.ID); !errors.Is(err, ErrSubjectNotFound) {
+		t.Errorf("Delete: err = %v, want %v", err, ErrSubjectNotFound)
+	}
+	if _, err := svc.Create(owner.ID, SubjectInput{Name: " 物理 "}); !errors.Is(err, ErrSubjectNameTaken) {
+		t.Errorf("Create with the same name: err = %v, want %v", err, ErrSubjectNameTaken)
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/validation"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTagNotFound  = errors.New("tag not found")
+	ErrTagNameTaken = errors.New("tag name already exists")
+)
+
+// ParseTagFilter は検索条件のタグ（カンマ区切り）と絞り込み方 (any / all / none) から TagFilter を作る。
+func ParseTagFilter(names, match string) repository.TagFilter {
+	switch match {
+	case repository.TagMatchAll, repository.TagMatchNone:
+	default:
+		match = repository.TagMatchAny
+	}
+	return repository.TagFilter{Names: models.ParseTagNames(names), Match: match}
+}
+
+type TagService struct {
+	tagRepo        *repository.TagRepository
+	assignmentRepo *repository.AssignmentRepository
+}
+
+func NewTagService(db *gorm.DB) *TagService {
+	return &TagService{
+		tagRepo:        repository.NewTagRepository(db),
+		assignmentRepo: repository.NewAssignmentRepository(db),
+	}
+}
+
+// List はユーザーのタグを名前順に返す。
+func (s *TagService) List(userID uint) ([]models.Tag, error) {
+	return s.tagRepo.FindByUserID(userID)
+}
+
+// Counts はタグごとの課題の数を返す。
+func (s *TagService) Counts(userID uint) map[uint]int64 {
+	counts, err := s.tagRepo.CountAssignmentsByUserID(userID)
+	if err != nil {
+		return map[uint]int64{}
+	}
+	return counts
+}
+
+func (s *TagService) Get(userID, tagID uint) (*models.Tag, error) {
+	tag, err := s.tagRepo.FindByID(tagID)
+	if err != nil || tag.UserID != userID {
+		return nil, ErrTagNotFound
+	}
+	return tag, nil
+}
+
+func (s *TagService) Create(userID uint, name string) (*models.Tag, error) {
+	name = strings.TrimSpace(name)
+	if err := validation.ValidateTags([]string{name}); err != nil {
+		return nil, err
+	}
+	if _, err := s.tagRepo.FindByName(userID, name); err == nil {
+		return nil, ErrTagNameTaken
+	}
+
+	tag := &models.Tag{UserID: userID, Name: name}
+	if err := s.tagRepo.Create(tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// Rename はタグの名前を変える。
+func (s *TagService) Rename(userID, tagID uint, name string) (*models.Tag, error) {
+	tag, err := s.Get(userID, tagID)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if err := validation.ValidateTags([]string{name}); err != nil {
+		return nil, err
+	}
+	if existing, err := s.tagRepo.FindByName(userID, name); err == nil && existing.ID != tag.ID {
+		return nil, ErrTagNameTaken
+	}
+
+	tag.Name = name
+	if err := s.tagRepo.Update(tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// Delete はタグを削除する。課題は削除せず、タグだけを外す。
+func (s *TagService) Delete(userID, tagID uint) (*models.Tag, error) {
+	tag, err := s.Get(userID, tagID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tagRepo.Delete(tag.ID); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// SetAssignmentTags は課題のタグを names に置き換える。まだないタグは作成する。付けたタグを名前順に返す。
+func (s *TagService) SetAssignmentTags(userID, assignmentID uint, names []string) ([]models.Tag, error) {
+	assignment, err := s.assignmentRepo.FindByID(assignmentID)
+	if err != nil {
+		return nil, ErrAssignmentNotFound
+	}
+	if assignment.UserID != userID {
+		return nil, ErrUnauthorized
+	}
+	if err := validation.ValidateTags(names); err != nil {
+		return nil, err
+	}
+
+	tags, err := resolveTags(s.tagRepo, userID, names)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tagRepo.SetAssignmentTags(assignment.ID, tags); err != nil {
+		return nil, err
+	}
+
+	assignment, err = s.assignmentRepo.FindByID(assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	return assignment.Tags, nil
+}
+
+// resolveTags は課題・繰り返し設定に付けるタグを名前から探し、なければ作成する。同じ名前は1つにまとめる。
+func resolveTags(tagRepo *repository.TagRepository, userID uint, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tag, err := tagRepo.FindByName(userID, name)
+		if err != nil {
+			tag = &models.Tag{UserID: userID, Name: name}
+			if err := tagRepo.Create(tag); err != nil {
+				// 同時に同じタグが作られた場合は作られた方を使う
+				existing, findErr := tagRepo.FindByName(userID, name)
+				if findErr != nil {
+					return nil, err
+				}
+				tag = existing
+			}
+		}
+		tags = append(tags, *tag)
+	}
+	return tags, nil
+}
+
+// joinTagNames は繰り返し設定に保存するタグ（カンマ区切り）を返す。
+func joinTagNames(names []string) string {
+	return strings.Join(models.ParseTagNames(strings.Join(names, ",")), ", ")
+}
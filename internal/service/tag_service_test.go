@@ -0,0 +1,209 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/testutil"
+)
+
+func TestTagSearch(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "tags@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	svc := NewTagService(db)
+	assignments := NewAssignmentService(db)
+
+	due := time.Now().Add(48 * time.Hour)
+	both := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, Title: "両方", DueDate: due})
+	testOnly := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, Title: "テストだけ", DueDate: due})
+	untagged := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, Title: "タグなし", DueDate: due})
+	othersAssignment := createTestAssignment(t, db, &models.Assignment{UserID: other.ID, Title: "他のユーザー", DueDate: due})
+
+	tags, err := svc.SetAssignmentTags(user.ID, both.ID, []string{"提出物", "テスト", "テスト"})
+	if err != nil {
+		t.Fatalf("SetAssignmentTags: %v", err)
+	}
+	if got := models.TagNames(tags); len(got) != 2 || got[0] != "テスト" || got[1] != "提出物" {
+		t.Errorf("tags = %v, want [テスト 提出物]", got)
+	}
+	svc.SetAssignmentTags(user.ID, testOnly.ID, []string{"テスト"})
+	svc.SetAssignmentTags(other.ID, othersAssignment.ID, []string{"テスト"})
+	if _, err := svc.SetAssignmentTags(other.ID, untagged.ID, []string{"テスト"}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("tagging another user's assignment: err = %v, want %v", err, ErrUnauthorized)
+	}
+
+	tests := []struct {
+		name  string
+		names string
+		match string
+		want  []uint
+	}{
+		{"いずれか", "テスト, 提出物", "any", []uint{both.ID, testOnly.ID}},
+		{"すべて", "テスト, 提出物", "all", []uint{both.ID}},
+		{"除く", "提出物", "none", []uint{testOnly.ID, untagged.ID}},
+		{"指定なしはいずれか", "テスト", "", []uint{both.ID, testOnly.ID}},
+		{"存在しないタグ", "部活", "any", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := assignments.SearchAssignments(user.ID, "", "", "all", ParseTagFilter(tt.names, tt.match), 1, 20)
+			if err != nil {
+				t.Fatalf("SearchAssignments: %v", err)
+			}
+			got := map[uint]bool{}
+			for _, a := range result.Assignments {
+				got[a.ID] = true
+			}
+			if len(got) != len(tt.want) || result.TotalCount != int64(len(tt.want)) {
+				t.Fatalf("got %v (total %d), want %v", got, result.TotalCount, tt.want)
+			}
+			for _, id := range tt.want {
+				if !got[id] {
+					t.Errorf("assignment %d missing from %v", id, got)
+				}
+			}
+		})
+	}
+
+	// 絞り込みは読み込み済みの課題でも同じ結果になる
+	loaded, _ := assignments.GetByID(user.ID, both.ID)
+	if !ParseTagFilter("テスト,提出物", "all").Matches(loaded.Tags) || ParseTagFilter("テスト", "none").Matches(loaded.Tags) {
+		t.Errorf("Matches(%v) disagrees with the search", models.TagNames(loaded.Tags))
+	}
+
+	// 課題の更新でタグは消えない
+	if _, err := assignments.Update(user.ID, both.ID, "両方（改）", "", "", "medium", due, false, nil, true); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if loaded, _ = assignments.GetByID(user.ID, both.ID); len(loaded.Tags) != 2 {
+		t.Errorf("tags after update = %v, want 2 tags", models.TagNames(loaded.Tags))
+	}
+}
+
+func TestTagRenameAndDelete(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "rename@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	svc := NewTagService(db)
+	assignments := NewAssignmentService(db)
+
+	assignment := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: time.Now().Add(time.Hour)})
+	svc.SetAssignmentTags(user.ID, assignment.ID, []string{"テスト", "予習"})
+	tag, err := repository.NewTagRepository(db).FindByName(user.ID, "テスト")
+	if err != nil {
+		t.Fatalf("FindByName: %v", err)
+	}
+
+	if _, err := svc.Rename(user.ID, tag.ID, "予習"); !errors.Is(err, ErrTagNameTaken) {
+		t.Errorf("rename to an existing name: err = %v, want %v", err, ErrTagNameTaken)
+	}
+	if _, err := svc.Rename(other.ID, tag.ID, "小テスト"); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("rename another user's tag: err = %v, want %v", err, ErrTagNotFound)
+	}
+	if _, err := svc.Rename(user.ID, tag.ID, "小テスト"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if got, _ := assignments.GetByID(user.ID, assignment.ID); len(got.Tags) != 2 || got.Tags[0].Name != "予習" || got.Tags[1].Name != "小テスト" {
+		t.Errorf("tags after rename = %v", models.TagNames(got.Tags))
+	}
+	if count := svc.Counts(user.ID)[tag.ID]; count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+
+	if _, err := svc.Delete(user.ID, tag.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	got, err := assignments.GetByID(user.ID, assignment.ID)
+	if err != nil || len(got.Tags) != 1 || got.Tags[0].Name != "予習" {
+		t.Errorf("assignment after deleting the tag = %v, %v", got, err)
+	}
+}
+
+func TestTagStatisticsAndRecurring(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "stats@example.com")
+	svc := NewTagService(db)
+	assignments := NewAssignmentService(db)
+
+	now := time.Now()
+	completedAt := now.Add(-time.Hour)
+	done := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: now.Add(time.Hour), IsCompleted: true, CompletedAt: &completedAt})
+	overdue := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: now.Add(-time.Hour)})
+	createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: now.Add(time.Hour)})
+	svc.SetAssignmentTags(user.ID, done.ID, []string{"テスト"})
+	svc.SetAssignmentTags(user.ID, overdue.ID, []string{"テスト", "提出物"})
+
+	stats, err := assignments.GetStatistics(user.ID, StatisticsFilter{})
+	if err != nil {
+		t.Fatalf("GetStatistics: %v", err)
+	}
+	if len(stats.Tags) != 2 || stats.Tags[0].Tag != "テスト" || stats.Tags[0].Total != 2 || stats.Tags[0].Completed != 1 ||
+		stats.Tags[0].Overdue != 1 || stats.Tags[1].Tag != "提出物" || stats.Tags[1].Total != 1 {
+		t.Errorf("tag breakdown = %+v", stats.Tags)
+	}
+
+	filtered, _ := assignments.GetStatistics(user.ID, StatisticsFilter{Tag: "テスト"})
+	if filtered.TotalAssignments != 2 || filtered.Tags != nil || filtered.Filter == nil || *filtered.Filter.Tag != "テスト" {
+		t.Errorf("statistics filtered by tag = %+v", filtered)
+	}
+	if unknown, _ := assignments.GetStatistics(user.ID, StatisticsFilter{Tag: "部活"}); unknown.TotalAssignments != 0 {
+		t.Errorf("statistics for an unknown tag: total = %d, want 0", unknown.TotalAssignments)
+	}
+
+	// 繰り返し設定のタグは生成する課題に付く
+	recurring, err := NewRecurringAssignmentService(db).Create(user.ID, CreateRecurringAssignmentInput{
+		Title: "小テスト", RecurrenceType: models.RecurrenceWeekly, RecurrenceInterval: 1,
+		DueTime: "09:00", EndType: models.EndTypeNever, FirstDueDate: now.Add(24 * time.Hour),
+		Tags: []string{"テスト", "毎週"},
+	})
+	if err != nil {
+		t.Fatalf("Create recurring: %v", err)
+	}
+	if recurring.Tags != "テスト, 毎週" {
+		t.Errorf("recurring tags = %q", recurring.Tags)
+	}
+	var generated models.Assignment
+	if err := db.Preload("Tags").Where("recurring_assignment_id = ?", recurring.ID).First(&generated).Error; err != nil {
+		t.Fatalf("find generated assignment: %v", err)
+	}
+	if names := models.TagNames(generated.Tags); len(names) != 2 {
+		t.Errorf("generated assignment tags = %v, want [テスト 毎週]", names)
+	}
+	if tags, _ := svc.List(user.ID); len(tags) != 3 {
+		t.Errorf("%d tag(s), want the existing テスト to be reused", len(tags))
+	}
+}
+
+func TestTagExportImport(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "export@example.com")
+	other := createTestUser(t, db, "import@example.com")
+
+	assignment := createTestAssignment(t, db, &models.Assignment{UserID: user.ID, DueDate: time.Now().Add(time.Hour)})
+	NewTagService(db).SetAssignmentTags(user.ID, assignment.ID, []string{"テスト", "数学"})
+
+	transfer := NewDataTransferService(db, nil)
+	doc, err := transfer.BuildExport(user.ID)
+	if err != nil {
+		t.Fatalf("BuildExport: %v", err)
+	}
+	if len(doc.Assignments) != 1 || doc.Assignments[0].Tags != "テスト, 数学" {
+		t.Fatalf("exported assignments = %+v", doc.Assignments)
+	}
+
+	var buf bytes.Buffer
+	transfer.WriteJSON(&buf, doc)
+	result, err := transfer.ImportData(other.ID, buf.Bytes())
+	if err != nil || result.Assignments.Created != 1 || len(result.Errors) != 0 {
+		t.Fatalf("ImportData = %+v, %v", result, err)
+	}
+	imported, _ := NewAssignmentService(db).GetAllByUser(other.ID)
+	if len(imported) != 1 || len(imported[0].Tags) != 2 || imported[0].Tags[0].UserID != other.ID {
+		t.Errorf("imported assignment = %+v", imported)
+	}
+}
@@ -0,0 +1,550 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/telegram"
+	"homework-manager/internal/validation"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TelegramBotModeOff     = "off"
+	TelegramBotModePolling = "polling"
+	TelegramBotModeWebhook = "webhook"
+)
+
+const (
+	// telegramCallbackComplete は「完了にする」ボタンの callback_data の接頭辞（"done:<課題ID>"）
+	telegramCallbackComplete = "done:"
+	telegramPollTimeout      = 50
+	telegramListLimit        = 30
+	telegramButtonLimit      = 10
+	telegramMaxSnooze        = 30 * 24 * time.Hour
+)
+
+var (
+	errBotUsage    = errors.New("usage")
+	errInvalidDue  = errors.New("invalid due date")
+	errInvalidSpan = errors.New("invalid duration")
+)
+
+// TelegramBotService は Telegram ボットに届いたコマンドとボタン操作を処理する。
+// 更新はロングポーリング (getUpdates) または Webhook (/telegram/webhook) で受け取る。
+type TelegramBotService struct {
+	client              *telegram.Client
+	notificationService *NotificationService
+	assignmentService   *AssignmentService
+	userRepo            *repository.UserRepository
+	mode                string
+	webhookURL          string
+	webhookSecret       string
+	now                 func() time.Time
+
+	mu       sync.RWMutex
+	username string
+}
+
+func NewTelegramBotService(db *gorm.DB, cfg config.NotificationConfig, notificationService *NotificationService) *TelegramBotService {
+	return &TelegramBotService{
+		client:              telegram.NewClient(cfg.TelegramBotToken, cfg.TelegramAPIURL),
+		notificationService: notificationService,
+		assignmentService:   NewAssignmentService(db),
+		userRepo:            repository.NewUserRepository(db),
+		mode:                cfg.TelegramBotMode,
+		webhookURL:          cfg.TelegramWebhookURL,
+		webhookSecret:       cfg.TelegramWebhookSecret,
+		now:                 time.Now,
+	}
+}
+
+func completeButtonMarkup(assignmentID uint) *telegram.InlineKeyboardMarkup {
+	return &telegram.InlineKeyboardMarkup{InlineKeyboard: [][]telegram.InlineKeyboardButton{{
+		{Text: "✅ 完了にする", CallbackData: fmt.Sprintf("%s%d", telegramCallbackComplete, assignmentID)},
+	}}}
+}
+
+// Username はボットのユーザー名（@ なし）。Start 前または取得に失敗した場合は空。
+func (s *TelegramBotService) Username() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.username
+}
+
+// LinkURL はボットを開いて連携コードを送る t.me のURLを返す。ユーザー名が不明な場合は空。
+func (s *TelegramBotService) LinkURL(code string) string {
+	username := s.Username()
+	if username == "" {
+		return ""
+	}
+	return "https://t.me/" + username + "?start=" + code
+}
+
+// Start はボットのユーザー名を取得し、設定されたモードで更新の受信を始める。
+func (s *TelegramBotService) Start() error {
+	if me, err := s.client.GetMe(); err != nil {
+		log.Printf("Telegram getMe failed: %v", err)
+	} else {
+		s.mu.Lock()
+		s.username = me.Username
+		s.mu.Unlock()
+	}
+
+	switch s.mode {
+	case TelegramBotModePolling:
+		// Webhook が登録されていると getUpdates が使えないため解除する
+		if err := s.client.DeleteWebhook(); err != nil {
+			log.Printf("Telegram deleteWebhook failed: %v", err)
+		}
+		go s.poll()
+		log.Println("Telegram bot started (long polling)")
+	case TelegramBotModeWebhook:
+		if s.webhookURL == "" {
+			return errors.New("telegram_webhook_url is required for webhook mode")
+		}
+		if s.webhookSecret == "" {
+			return errors.New("telegram_webhook_secret is required for webhook mode")
+		}
+		if err := s.client.SetWebhook(s.webhookURL, s.webhookSecret); err != nil {
+			return err
+		}
+		log.Printf("Telegram bot started (webhook: %s)", s.webhookURL)
+	default:
+		return fmt.Errorf("unknown telegram bot mode %q", s.mode)
+	}
+	return nil
+}
+
+func (s *TelegramBotService) poll() {
+	var offset int64
+	backoff := time.Second
+	for {
+		updates, err := s.client.GetUpdates(offset, telegramPollTimeout)
+		if err != nil {
+			log.Printf("Telegram getUpdates failed: %v", err)
+			time.Sleep(backoff)
+			if backoff < time.Minute {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+		for i := range updates {
+			s.HandleUpdate(&updates[i])
+			offset = updates[i].UpdateID + 1
+		}
+	}
+}
+
+// VerifyWebhookSecret は Webhook リクエストのシークレットトークンを検証する。シークレット未設定の場合は常に false。
+func (s *TelegramBotService) VerifyWebhookSecret(token string) bool {
+	if s.webhookSecret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.webhookSecret)) == 1
+}
+
+func (s *TelegramBotService) HandleUpdate(update *telegram.Update) {
+	switch {
+	case update.CallbackQuery != nil:
+		s.handleCallback(update.CallbackQuery)
+	case update.Message != nil && strings.HasPrefix(update.Message.Text, "/"):
+		s.handleCommand(update.Message)
+	}
+}
+
+func (s *TelegramBotService) reply(chatID, text string, markup *telegram.InlineKeyboardMarkup) {
+	if err := s.client.SendMessage(chatID, text, markup); err != nil {
+		log.Printf("Telegram sendMessage to %s failed: %v", chatID, err)
+	}
+}
+
+const telegramHelpText = `<b>使えるコマンド</b>
+/list - 未完了の課題
+/today - 今日が期限の課題
+/week - 7日以内が期限の課題
+/overdue - 期限切れの課題
+/done &lt;ID&gt; - 課題を完了にする
+/add &lt;タイトル&gt; &lt;期限&gt; - 課題を追加（例: /add 数学プリント 明日 18:00）
+/snooze &lt;ID&gt; &lt;時間&gt; - 督促通知を止めて後で再通知（例: /snooze 12 2h）
+/unlink - 連携を解除`
+
+const telegramNotLinkedText = "このチャットはまだアカウントと連携されていません。\nプロフィール画面の「Telegram連携」で連携コードを発行し、<code>/link コード</code> を送信してください。"
+
+func (s *TelegramBotService) handleCommand(msg *telegram.Message) {
+	chatID := strconv.FormatInt(msg.Chat.ID, 10)
+	fields := strings.Fields(msg.Text)
+	command := strings.ToLower(fields[0])
+	// グループでは "/list@BotName" の形式で届く
+	if i := strings.Index(command, "@"); i >= 0 {
+		command = command[:i]
+	}
+	args := strings.TrimSpace(strings.TrimPrefix(msg.Text, fields[0]))
+
+	switch command {
+	case "/start", "/link":
+		if args != "" {
+			s.link(chatID, args)
+			return
+		}
+		if _, err := s.notificationService.FindUserIDByTelegramChatID(chatID); err != nil {
+			s.reply(chatID, telegramNotLinkedText, nil)
+			return
+		}
+		s.reply(chatID, telegramHelpText, nil)
+		return
+	case "/help":
+		s.reply(chatID, telegramHelpText, nil)
+		return
+	}
+
+	userID, err := s.notificationService.FindUserIDByTelegramChatID(chatID)
+	if err != nil {
+		s.reply(chatID, telegramNotLinkedText, nil)
+		return
+	}
+
+	// 日時の解釈と表示はユーザーのタイムゾーンで行う
+	loc := userLocation(s.userRepo, userID)
+	switch command {
+	case "/list":
+		assignments, err := s.assignmentService.GetPendingByUser(userID)
+		s.replyList(chatID, "📋 未完了の課題", assignments, loc, err)
+	case "/today":
+		assignments, err := s.assignmentService.GetDueTodayByUser(userID)
+		s.replyList(chatID, "📅 今日が期限の課題", assignments, loc, err)
+	case "/week":
+		assignments, err := s.assignmentService.GetDueThisWeekByUser(userID)
+		s.replyList(chatID, "🗓 7日以内が期限の課題", assignments, loc, err)
+	case "/overdue":
+		assignments, err := s.assignmentService.GetOverdueByUser(userID)
+		s.replyList(chatID, "⚠️ 期限切れの課題", assignments, loc, err)
+	case "/done":
+		s.done(chatID, userID, args)
+	case "/add":
+		s.add(chatID, userID, args, loc)
+	case "/snooze":
+		s.snooze(chatID, userID, args, loc)
+	case "/unlink":
+		if err := s.notificationService.UnlinkTelegram(userID); err != nil {
+			s.reply(chatID, "連携の解除に失敗しました。", nil)
+			return
+		}
+		s.reply(chatID, "連携を解除しました。", nil)
+	default:
+		s.reply(chatID, "不明なコマンドです。\n\n"+telegramHelpText, nil)
+	}
+}
+
+func (s *TelegramBotService) link(chatID, code string) {
+	userID, err := s.notificationService.LinkTelegramChat(code, chatID)
+	if err != nil {
+		s.reply(chatID, "連携コードが正しくないか、有効期限が切れています。プロフィール画面で新しいコードを発行してください。", nil)
+		return
+	}
+	name := ""
+	if recipient, err := s.notificationService.recipient(userID); err == nil {
+		name = recipient.User.Name
+	}
+	s.reply(chatID, fmt.Sprintf("✅ %s さんのアカウントと連携しました。課題の通知がこのチャットに届きます。\n\n%s", html.EscapeString(name), telegramHelpText), nil)
+}
+
+func formatAssignmentLine(a *models.Assignment, loc *time.Location) string {
+	line := fmt.Sprintf("<b>#%d</b> ", a.ID)
+	if a.Subject != "" {
+		line += "[" + html.EscapeString(a.Subject) + "] "
+	}
+	return line + html.EscapeString(a.Title) + "\n　期限: " + a.DueDate.In(loc).Format("2006/01/02 15:04")
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+func (s *TelegramBotService) replyList(chatID, heading string, assignments []models.Assignment, loc *time.Location, err error) {
+	if err != nil {
+		s.reply(chatID, "課題の取得に失敗しました。", nil)
+		return
+	}
+	if len(assignments) == 0 {
+		s.reply(chatID, "<b>"+heading+"</b>\n\n該当する課題はありません。", nil)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("<b>" + heading + "</b>（" + strconv.Itoa(len(assignments)) + "件）\n")
+	markup := &telegram.InlineKeyboardMarkup{}
+	for i := range assignments {
+		if i == telegramListLimit {
+			fmt.Fprintf(&b, "\nほか %d 件", len(assignments)-telegramListLimit)
+			break
+		}
+		b.WriteString("\n" + formatAssignmentLine(&assignments[i], loc))
+		if i < telegramButtonLimit {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, []telegram.InlineKeyboardButton{{
+				Text:         fmt.Sprintf("✅ #%d %s", assignments[i].ID, truncateRunes(assignments[i].Title, 20)),
+				CallbackData: fmt.Sprintf("%s%d", telegramCallbackComplete, assignments[i].ID),
+			}})
+		}
+	}
+	s.reply(chatID, b.String(), markup)
+}
+
+func parseAssignmentIDArg(arg string) (uint, bool) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(arg, "#"), 10, 32)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// complete は課題を完了にする。すでに完了している場合は false を返す。
+func (s *TelegramBotService) complete(userID, assignmentID uint) (*models.Assignment, bool, error) {
+	assignment, err := s.assignmentService.GetByID(userID, assignmentID)
+	if err != nil {
+		return nil, false, err
+	}
+	if assignment.IsCompleted {
+		return assignment, false, nil
+	}
+	assignment, err = s.assignmentService.ToggleComplete(userID, assignmentID)
+	if err != nil {
+		return nil, false, err
+	}
+	return assignment, true, nil
+}
+
+func (s *TelegramBotService) done(chatID string, userID uint, args string) {
+	assignmentID, ok := parseAssignmentIDArg(args)
+	if !ok {
+		s.reply(chatID, "使い方: <code>/done 課題ID</code>（IDは /list で確認できます）", nil)
+		return
+	}
+	assignment, changed, err := s.complete(userID, assignmentID)
+	if err != nil {
+		s.reply(chatID, "課題が見つかりません。", nil)
+		return
+	}
+	if !changed {
+		s.reply(chatID, fmt.Sprintf("#%d はすでに完了しています。", assignment.ID), nil)
+		return
+	}
+	s.reply(chatID, "✅ 完了にしました: "+html.EscapeString(assignment.Title), nil)
+}
+
+func (s *TelegramBotService) add(chatID string, userID uint, args string, loc *time.Location) {
+	title, due, err := parseAddArgs(args, s.now().In(loc))
+	if err != nil {
+		s.reply(chatID, "使い方: <code>/add タイトル 期限</code>\n期限の例: <code>2025-01-31</code>、<code>1/31 18:00</code>、<code>今日</code>、<code>明日 9:00</code>、<code>3日後</code>（時刻を省略すると 23:59）", nil)
+		return
+	}
+	if err := validation.ValidateAssignmentInput(title, "", "", "medium"); err != nil {
+		s.reply(chatID, "入力が正しくありません: "+html.EscapeString(err.Error()), nil)
+		return
+	}
+
+	assignment, err := s.assignmentService.Create(userID, title, "", "", "medium", due, false, nil, true)
+	if err != nil {
+		s.reply(chatID, "課題の追加に失敗しました。", nil)
+		return
+	}
+	s.reply(chatID, "📝 課題を追加しました\n\n"+formatAssignmentLine(assignment, loc), completeButtonMarkup(assignment.ID))
+}
+
+func (s *TelegramBotService) snooze(chatID string, userID uint, args string, loc *time.Location) {
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		s.reply(chatID, "使い方: <code>/snooze 課題ID 時間</code>（例: <code>/snooze 12 30m</code>、<code>2h</code>、<code>1d</code>）", nil)
+		return
+	}
+	assignmentID, ok := parseAssignmentIDArg(fields[0])
+	if !ok {
+		s.reply(chatID, "課題IDが正しくありません。", nil)
+		return
+	}
+	d, err := parseSnoozeDuration(fields[1])
+	if err != nil {
+		s.reply(chatID, "時間は <code>30m</code>、<code>2h</code>、<code>1d</code> のように指定してください（最大30日）。", nil)
+		return
+	}
+
+	until := s.now().In(loc).Add(d)
+	assignment, err := s.assignmentService.Snooze(userID, assignmentID, until)
+	if err != nil {
+		s.reply(chatID, "課題が見つかりません。", nil)
+		return
+	}
+	s.reply(chatID, fmt.Sprintf("⏰ #%d を %s までスヌーズしました。その時刻にもう一度通知します。",
+		assignment.ID, until.Format("2006/01/02 15:04")), nil)
+}
+
+func (s *TelegramBotService) handleCallback(cq *telegram.CallbackQuery) {
+	chatID := strconv.FormatInt(cq.From.ID, 10)
+	if cq.Message != nil {
+		chatID = strconv.FormatInt(cq.Message.Chat.ID, 10)
+	}
+
+	if !strings.HasPrefix(cq.Data, telegramCallbackComplete) {
+		s.client.AnswerCallbackQuery(cq.ID, "")
+		return
+	}
+
+	userID, err := s.notificationService.FindUserIDByTelegramChatID(chatID)
+	if err != nil {
+		s.client.AnswerCallbackQuery(cq.ID, "アカウントと連携されていません")
+		return
+	}
+	assignmentID, ok := parseAssignmentIDArg(strings.TrimPrefix(cq.Data, telegramCallbackComplete))
+	if !ok {
+		s.client.AnswerCallbackQuery(cq.ID, "")
+		return
+	}
+
+	answer := "✅ 完了にしました"
+	assignment, changed, err := s.complete(userID, assignmentID)
+	if err != nil {
+		answer = "課題が見つかりません"
+	} else if !changed {
+		answer = fmt.Sprintf("#%d はすでに完了しています", assignment.ID)
+	}
+	if err := s.client.AnswerCallbackQuery(cq.ID, answer); err != nil {
+		log.Printf("Telegram answerCallbackQuery failed: %v", err)
+	}
+
+	// 押されたボタンをメッセージから取り除く
+	if cq.Message != nil && err == nil {
+		markup := removeButton(cq.Message.ReplyMarkup, cq.Data)
+		if err := s.client.EditMessageReplyMarkup(chatID, cq.Message.MessageID, markup); err != nil {
+			log.Printf("Telegram editMessageReplyMarkup failed: %v", err)
+		}
+	}
+}
+
+func removeButton(markup *telegram.InlineKeyboardMarkup, callbackData string) *telegram.InlineKeyboardMarkup {
+	if markup == nil {
+		return nil
+	}
+	result := &telegram.InlineKeyboardMarkup{}
+	for _, row := range markup.InlineKeyboard {
+		var kept []telegram.InlineKeyboardButton
+		for _, button := range row {
+			if button.CallbackData != callbackData {
+				kept = append(kept, button)
+			}
+		}
+		if len(kept) > 0 {
+			result.InlineKeyboard = append(result.InlineKeyboard, kept)
+		}
+	}
+	if len(result.InlineKeyboard) == 0 {
+		return nil
+	}
+	return result
+}
+
+// parseAddArgs は "/add" の引数を「タイトル 期限」に分ける。期限は末尾の「日付」または「日付 時刻」。
+func parseAddArgs(args string, now time.Time) (string, time.Time, error) {
+	fields := strings.Fields(args)
+	if len(fields) >= 3 {
+		if hour, minute, ok := parseBotTime(fields[len(fields)-1]); ok {
+			if date, ok := parseBotDate(fields[len(fields)-2], now); ok {
+				due := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, now.Location())
+				return strings.Join(fields[:len(fields)-2], " "), due, nil
+			}
+		}
+	}
+	if len(fields) >= 2 {
+		if date, ok := parseBotDate(fields[len(fields)-1], now); ok {
+			due := time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 0, 0, now.Location())
+			return strings.Join(fields[:len(fields)-1], " "), due, nil
+		}
+	}
+	if len(fields) == 0 {
+		return "", time.Time{}, errBotUsage
+	}
+	return "", time.Time{}, errInvalidDue
+}
+
+func parseBotDate(s string, now time.Time) (time.Time, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch strings.ToLower(s) {
+	case "today", "今日", "きょう":
+		return today, true
+	case "tomorrow", "明日", "あした":
+		return today.AddDate(0, 0, 1), true
+	case "明後日", "あさって":
+		return today.AddDate(0, 0, 2), true
+	}
+	if strings.HasSuffix(s, "日後") {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, "日後")); err == nil && n >= 0 && n <= 366 {
+			return today.AddDate(0, 0, n), true
+		}
+	}
+	if strings.HasPrefix(s, "+") && strings.HasSuffix(s, "d") {
+		if n, err := strconv.Atoi(s[1 : len(s)-1]); err == nil && n >= 0 && n <= 366 {
+			return today.AddDate(0, 0, n), true
+		}
+	}
+	for _, layout := range []string{"2006-01-02", "2006/01/02", "2006/1/2"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, true
+		}
+	}
+	// 年を省略した場合は、今日以降で最も近い日付
+	for _, layout := range []string{"1/2", "01/02", "1-2", "01-02"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			date := time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location())
+			if date.Before(today) {
+				date = date.AddDate(1, 0, 0)
+			}
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseBotTime(s string) (int, int, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, false
+	}
+	return t.Hour(), t.Minute(), true
+}
+
+// parseSnoozeDuration は "30m"、"2h"、"1h30m" のほか、日数 "1d" を受け付ける。
+func parseSnoozeDuration(s string) (time.Duration, error) {
+	var d time.Duration
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, errInvalidSpan
+		}
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, errInvalidSpan
+		}
+		d = parsed
+	}
+	if d <= 0 || d > telegramMaxSnooze {
+		return 0, errInvalidSpan
+	}
+	return d, nil
+}
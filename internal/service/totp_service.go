@@ -2,19 +2,26 @@ package service
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"image/png"
 	"net/url"
+	"time"
 
 	"github.com/pquerna/otp"
 	totplib "github.com/pquerna/otp/totp"
 )
 
-type TOTPService struct{}
+// totpPeriod は TOTP のタイムステップの長さ（秒）。認証アプリの既定値に合わせる。
+const totpPeriod = 30
+
+type TOTPService struct {
+	now func() time.Time
+}
 
 func NewTOTPService() *TOTPService {
-	return &TOTPService{}
+	return &TOTPService{now: time.Now}
 }
 
 type TOTPSetupData struct {
@@ -69,3 +76,28 @@ func (s *TOTPService) buildSetupData(key *otp.Key) (*TOTPSetupData, error) {
 func (s *TOTPService) Validate(secret, code string) bool {
 	return totplib.Validate(code, secret)
 }
+
+// ValidateStep はコードを検証し、一致したタイムステップを返す。
+// 時計のずれを考慮して前後 1 ステップまで受け付けるが、lastStep 以前のステップは再利用とみなして拒否する。
+func (s *TOTPService) ValidateStep(secret, code string, lastStep int64) (int64, bool) {
+	if len(code) != 6 {
+		return 0, false
+	}
+	now := s.now()
+	for skew := -1; skew <= 1; skew++ {
+		t := now.Add(time.Duration(skew*totpPeriod) * time.Second)
+		expected, err := totplib.GenerateCodeCustom(secret, t, totplib.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		step := t.Unix() / totpPeriod
+		if step > lastStep && subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/webauthn"
+
+	"gorm.io/gorm"
+)
+
+const maxWebAuthnCredentialName = 100
+
+var (
+	ErrWebAuthnDisabled           = errors.New("webauthn is disabled")
+	ErrWebAuthnCredentialNotFound = errors.New("webauthn credential not found")
+	ErrWebAuthnCredentialExists   = errors.New("webauthn credential is already registered")
+	ErrWebAuthnVerification       = errors.New("webauthn verification failed")
+	ErrWebAuthnNameTooLong        = errors.New("webauthn credential name is too long")
+)
+
+type WebAuthnService struct {
+	cfg      config.WebAuthnConfig
+	rp       *webauthn.RelyingParty
+	credRepo *repository.WebAuthnCredentialRepository
+	userRepo *repository.UserRepository
+}
+
+func NewWebAuthnService(db *gorm.DB, cfg config.WebAuthnConfig) *WebAuthnService {
+	return &WebAuthnService{
+		cfg:      cfg,
+		rp:       &webauthn.RelyingParty{ID: cfg.RPID, Name: cfg.RPName, Origin: cfg.Origin},
+		credRepo: repository.NewWebAuthnCredentialRepository(db),
+		userRepo: repository.NewUserRepository(db),
+	}
+}
+
+func (s *WebAuthnService) Enabled() bool {
+	return s.cfg.Enabled
+}
+
+// PasswordlessEnabled はパスキーだけでのログインを受け付けるかを返す。
+func (s *WebAuthnService) PasswordlessEnabled() bool {
+	return s.cfg.Enabled && s.cfg.Passwordless
+}
+
+// HasCredentials はユーザーが 2 段階認証に使えるクレデンシャルを登録しているかを返す。
+func (s *WebAuthnService) HasCredentials(userID uint) bool {
+	if !s.cfg.Enabled {
+		return false
+	}
+	count, err := s.credRepo.CountByUserID(userID)
+	return err == nil && count > 0
+}
+
+func (s *WebAuthnService) GetCredentials(userID uint) ([]models.WebAuthnCredential, error) {
+	return s.credRepo.FindByUserID(userID)
+}
+
+// CountCredentialsByUser はユーザーごとの登録数を返す。管理画面の一覧で使う。
+func (s *WebAuthnService) CountCredentialsByUser() (map[uint]int64, error) {
+	return s.credRepo.CountGroupedByUser()
+}
+
+// BeginRegistration は登録用のオプションとチャレンジを返す。チャレンジは FinishRegistration まで呼び出し側で保持する。
+func (s *WebAuthnService) BeginRegistration(userID uint) (*webauthn.CreationOptions, string, error) {
+	if !s.cfg.Enabled {
+		return nil, "", ErrWebAuthnDisabled
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, "", ErrUserNotFound
+	}
+	credentials, err := s.credRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, "", err
+	}
+	options := s.rp.CreationOptions(challenge, webauthn.UserEntity{
+		ID:          userHandle(user.ID),
+		Name:        user.Email,
+		DisplayName: user.Name,
+	}, descriptors(credentials))
+	return options, challenge, nil
+}
+
+// FinishRegistration は登録結果を検証してクレデンシャルを保存する。name が空の場合は連番の名前を付ける。
+func (s *WebAuthnService) FinishRegistration(userID uint, name string, resp *webauthn.RegistrationResponse, challenge string) (*models.WebAuthnCredential, error) {
+	if !s.cfg.Enabled {
+		return nil, ErrWebAuthnDisabled
+	}
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxWebAuthnCredentialName {
+		return nil, ErrWebAuthnNameTooLong
+	}
+
+	verified, err := s.rp.VerifyRegistration(resp, challenge)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebAuthnVerification, err)
+	}
+	if _, err := s.credRepo.FindByCredentialID(verified.ID); err == nil {
+		return nil, ErrWebAuthnCredentialExists
+	}
+
+	if name == "" {
+		count, _ := s.credRepo.CountByUserID(userID)
+		name = fmt.Sprintf("パスキー %d", count+1)
+	}
+
+	credential := &models.WebAuthnCredential{
+		UserID:       userID,
+		Name:         name,
+		CredentialID: verified.ID,
+		PublicKey:    verified.PublicKey,
+		SignCount:    verified.SignCount,
+		Transports:   strings.Join(verified.Transports, " "),
+	}
+	if err := s.credRepo.Create(credential); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// BeginLogin は 2 段階認証用のオプションを返す。ユーザーが登録したクレデンシャルだけを許可する。
+func (s *WebAuthnService) BeginLogin(userID uint) (*webauthn.RequestOptions, string, error) {
+	if !s.cfg.Enabled {
+		return nil, "", ErrWebAuthnDisabled
+	}
+	credentials, err := s.credRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(credentials) == 0 {
+		return nil, "", ErrWebAuthnCredentialNotFound
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, "", err
+	}
+	return s.rp.RequestOptions(challenge, descriptors(credentials), "discouraged"), challenge, nil
+}
+
+// FinishLogin は 2 段階認証の結果を検証する。パスワードで本人確認済みのため、認証器での本人確認は求めない。
+func (s *WebAuthnService) FinishLogin(userID uint, resp *webauthn.AssertionResponse, challenge string) error {
+	if !s.cfg.Enabled {
+		return ErrWebAuthnDisabled
+	}
+	credential, err := s.credRepo.FindByCredentialID(resp.ID)
+	if err != nil || credential.UserID != userID {
+		return ErrWebAuthnCredentialNotFound
+	}
+	return s.verifyAssertion(credential, resp, challenge, false)
+}
+
+// BeginPasswordlessLogin はパスキーでのログイン用のオプションを返す。認証器に保存されたパスキーから利用者が選ぶ。
+func (s *WebAuthnService) BeginPasswordlessLogin() (*webauthn.RequestOptions, string, error) {
+	if !s.PasswordlessEnabled() {
+		return nil, "", ErrWebAuthnDisabled
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, "", err
+	}
+	return s.rp.RequestOptions(challenge, nil, "required"), challenge, nil
+}
+
+// FinishPasswordlessLogin はパスキーでのログインを検証してユーザーを返す。
+// 認証器での本人確認（生体認証や PIN）を必須にするため、パスワードと 2 段階認証の両方を兼ねる。
+func (s *WebAuthnService) FinishPasswordlessLogin(resp *webauthn.AssertionResponse, challenge string) (*models.User, error) {
+	if !s.PasswordlessEnabled() {
+		return nil, ErrWebAuthnDisabled
+	}
+	credential, err := s.credRepo.FindByCredentialID(resp.ID)
+	if err != nil {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+	// userHandle が返された場合は、クレデンシャルの所有者と一致することを確認する
+	if handle, err := resp.UserHandle(); err == nil && len(handle) > 0 {
+		if userID, ok := userIDFromHandle(handle); !ok || userID != credential.UserID {
+			return nil, ErrWebAuthnCredentialNotFound
+		}
+	}
+	if err := s.verifyAssertion(credential, resp, challenge, true); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(credential.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *WebAuthnService) verifyAssertion(credential *models.WebAuthnCredential, resp *webauthn.AssertionResponse, challenge string, requireUV bool) error {
+	signCount, err := s.rp.VerifyAssertion(resp, challenge, credential.PublicKey, credential.SignCount, requireUV)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWebAuthnVerification, err)
+	}
+	now := time.Now().UTC()
+	credential.SignCount = signCount
+	credential.LastUsedAt = &now
+	return s.credRepo.Update(credential)
+}
+
+func (s *WebAuthnService) RenameCredential(userID, id uint, name string) error {
+	credential, err := s.credRepo.FindByID(id)
+	if err != nil || credential.UserID != userID {
+		return ErrWebAuthnCredentialNotFound
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrWebAuthnCredentialNotFound
+	}
+	if utf8.RuneCountInString(name) > maxWebAuthnCredentialName {
+		return ErrWebAuthnNameTooLong
+	}
+	credential.Name = name
+	return s.credRepo.Update(credential)
+}
+
+func (s *WebAuthnService) DeleteCredential(userID, id uint) error {
+	credential, err := s.credRepo.FindByID(id)
+	if err != nil || credential.UserID != userID {
+		return ErrWebAuthnCredentialNotFound
+	}
+	return s.credRepo.Delete(credential.ID)
+}
+
+// userHandle は WebAuthn のユーザーハンドル。メールアドレスなどの個人情報を含めないよう、ユーザーIDを 8 バイトで表す。
+func userHandle(userID uint) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+func userIDFromHandle(handle []byte) (uint, bool) {
+	if len(handle) != 8 {
+		return 0, false
+	}
+	return uint(binary.BigEndian.Uint64(handle)), true
+}
+
+func descriptors(credentials []models.WebAuthnCredential) []webauthn.CredentialDescriptor {
+	list := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, c := range credentials {
+		list = append(list, webauthn.CredentialDescriptor{
+			Type:       "public-key",
+			ID:         c.CredentialID,
+			Transports: c.TransportList(),
+		})
+	}
+	return list
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/models"
+	"homework-manager/internal/testutil"
+	"homework-manager/internal/webauthn"
+	"homework-manager/internal/webauthn/webauthntest"
+
+	"gorm.io/gorm"
+)
+
+var testWebAuthnConfig = config.WebAuthnConfig{
+	Enabled:      true,
+	RPID:         "homework.example.com",
+	RPName:       "Super-HomeworkManager",
+	Origin:       "https://homework.example.com",
+	Passwordless: true,
+}
+
+func newTestAuthenticator(alg int) *webauthntest.Authenticator {
+	return webauthntest.New(alg, testWebAuthnConfig.RPID, testWebAuthnConfig.Origin)
+}
+
+func decodeAssertion(t *testing.T, data []byte) *webauthn.AssertionResponse {
+	t.Helper()
+	var resp webauthn.AssertionResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("decode assertion: %v", err)
+	}
+	return &resp
+}
+
+// registerAuthenticator は BeginRegistration・FinishRegistration で認証器を登録する。
+func registerAuthenticator(t *testing.T, svc *WebAuthnService, userID uint, a *webauthntest.Authenticator) *models.WebAuthnCredential {
+	t.Helper()
+	options, challenge, err := svc.BeginRegistration(userID)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	// パスキーでのログインで使うユーザーハンドルを認証器に保存する
+	if a.UserHandle, err = base64.RawURLEncoding.DecodeString(options.User["id"]); err != nil {
+		t.Fatalf("decode user handle: %v", err)
+	}
+
+	var resp webauthn.RegistrationResponse
+	if err := json.Unmarshal(a.Register(options.Challenge), &resp); err != nil {
+		t.Fatalf("decode registration: %v", err)
+	}
+	credential, err := svc.FinishRegistration(userID, "", &resp, challenge)
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	return credential
+}
+
+func reloadCredential(t *testing.T, db *gorm.DB, id uint) *models.WebAuthnCredential {
+	t.Helper()
+	var credential models.WebAuthnCredential
+	if err := db.First(&credential, id).Error; err != nil {
+		t.Fatalf("reload credential: %v", err)
+	}
+	return &credential
+}
+
+func TestWebAuthnRegistrationAndLogin(t *testing.T) {
+	for _, alg := range []int{webauthn.AlgES256, webauthn.AlgRS256} {
+		db := testutil.OpenDB(t)
+		user := createTestUser(t, db, "passkey@example.com")
+		svc := NewWebAuthnService(db, testWebAuthnConfig)
+
+		a := newTestAuthenticator(alg)
+		credential := registerAuthenticator(t, svc, user.ID, a)
+		if credential.Name != "パスキー 1" || credential.CredentialID != a.CredentialID() || credential.Transports != "internal" {
+			t.Errorf("alg %d: credential = %+v", alg, credential)
+		}
+		if !svc.HasCredentials(user.ID) {
+			t.Errorf("alg %d: HasCredentials = false after registration", alg)
+		}
+
+		// 同じ認証器は二重に登録できず、登録オプションで除外される
+		options, challenge, _ := svc.BeginRegistration(user.ID)
+		if len(options.ExcludeCredentials) != 1 || options.ExcludeCredentials[0].ID != a.CredentialID() {
+			t.Errorf("alg %d: excludeCredentials = %+v", alg, options.ExcludeCredentials)
+		}
+		var again webauthn.RegistrationResponse
+		json.Unmarshal(a.Register(options.Challenge), &again)
+		if _, err := svc.FinishRegistration(user.ID, "", &again, challenge); !errors.Is(err, ErrWebAuthnCredentialExists) {
+			t.Errorf("alg %d: registering twice: err = %v, want %v", alg, err, ErrWebAuthnCredentialExists)
+		}
+
+		// 2 段階認証では署名カウンターと最終使用日時を更新する
+		_, challenge, err := svc.BeginLogin(user.ID)
+		if err != nil {
+			t.Fatalf("alg %d: BeginLogin: %v", alg, err)
+		}
+		if err := svc.FinishLogin(user.ID, decodeAssertion(t, a.Assert(challenge)), challenge); err != nil {
+			t.Fatalf("alg %d: FinishLogin: %v", alg, err)
+		}
+		if got := reloadCredential(t, db, credential.ID); got.SignCount != 1 || got.LastUsedAt == nil {
+			t.Errorf("alg %d: after login SignCount = %d, LastUsedAt = %v", alg, got.SignCount, got.LastUsedAt)
+		}
+
+		// パスキーでのログインはユーザーハンドルからユーザーを特定する
+		_, challenge, _ = svc.BeginPasswordlessLogin()
+		got, err := svc.FinishPasswordlessLogin(decodeAssertion(t, a.Assert(challenge)), challenge)
+		if err != nil || got.ID != user.ID {
+			t.Errorf("alg %d: FinishPasswordlessLogin = %+v, %v", alg, got, err)
+		}
+	}
+}
+
+func TestWebAuthnLoginErrors(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "passkey@example.com")
+	other := createTestUser(t, db, "other@example.com")
+	svc := NewWebAuthnService(db, testWebAuthnConfig)
+	a := newTestAuthenticator(webauthn.AlgES256)
+	credential := registerAuthenticator(t, svc, user.ID, a)
+
+	// 複製された認証器（署名カウンターが戻る）は拒否し、保存したカウンターは変えない
+	_, challenge, _ := svc.BeginLogin(user.ID)
+	if err := svc.FinishLogin(user.ID, decodeAssertion(t, a.Assert(challenge)), challenge); err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	a.SignCount = 0
+	_, challenge, _ = svc.BeginLogin(user.ID)
+	if err := svc.FinishLogin(user.ID, decodeAssertion(t, a.Assert(challenge)), challenge); !errors.Is(err, ErrWebAuthnVerification) {
+		t.Errorf("regressed counter: err = %v, want %v", err, ErrWebAuthnVerification)
+	}
+	if got := reloadCredential(t, db, credential.ID); got.SignCount != 1 {
+		t.Errorf("SignCount = %d after a rejected login, want 1", got.SignCount)
+	}
+	a.SignCount = 1
+
+	// 別のユーザーのクレデンシャルは使えない
+	_, challenge, _ = svc.BeginLogin(user.ID)
+	if err := svc.FinishLogin(other.ID, decodeAssertion(t, a.Assert(challenge)), challenge); !errors.Is(err, ErrWebAuthnCredentialNotFound) {
+		t.Errorf("other user's credential: err = %v, want %v", err, ErrWebAuthnCredentialNotFound)
+	}
+	if _, _, err := svc.BeginLogin(other.ID); !errors.Is(err, ErrWebAuthnCredentialNotFound) {
+		t.Errorf("BeginLogin without credentials: err = %v, want %v", err, ErrWebAuthnCredentialNotFound)
+	}
+
+	// 2 段階認証では UV を求めないが、パスキーでのログインでは必須
+	a.Flags = webauthntest.FlagUserPresent
+	_, challenge, _ = svc.BeginLogin(user.ID)
+	if err := svc.FinishLogin(user.ID, decodeAssertion(t, a.Assert(challenge)), challenge); err != nil {
+		t.Errorf("second factor without UV: %v", err)
+	}
+	_, challenge, _ = svc.BeginPasswordlessLogin()
+	if _, err := svc.FinishPasswordlessLogin(decodeAssertion(t, a.Assert(challenge)), challenge); !errors.Is(err, ErrWebAuthnVerification) {
+		t.Errorf("passwordless without UV: err = %v, want %v", err, ErrWebAuthnVerification)
+	}
+	a.Flags = webauthntest.FlagUserPresent | webauthntest.FlagUserVerified
+
+	// ユーザーハンドルがクレデンシャルの所有者と違う
+	a.UserHandle = userHandle(other.ID)
+	_, challenge, _ = svc.BeginPasswordlessLogin()
+	if _, err := svc.FinishPasswordlessLogin(decodeAssertion(t, a.Assert(challenge)), challenge); !errors.Is(err, ErrWebAuthnCredentialNotFound) {
+		t.Errorf("mismatched user handle: err = %v, want %v", err, ErrWebAuthnCredentialNotFound)
+	}
+
+	// 別のチャレンジへの応答は使えない
+	_, challenge, _ = svc.BeginLogin(user.ID)
+	_, otherChallenge, _ := svc.BeginLogin(user.ID)
+	if err := svc.FinishLogin(user.ID, decodeAssertion(t, a.Assert(otherChallenge)), challenge); !errors.Is(err, ErrWebAuthnVerification) {
+		t.Errorf("replayed challenge: err = %v, want %v", err, ErrWebAuthnVerification)
+	}
+}
+
+func TestWebAuthnDisabled(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "passkey@example.com")
+	enabled := NewWebAuthnService(db, testWebAuthnConfig)
+	registerAuthenticator(t, enabled, user.ID, newTestAuthenticator(webauthn.AlgES256))
+
+	cfg := testWebAuthnConfig
+	cfg.Enabled = false
+	svc := NewWebAuthnService(db, cfg)
+	if svc.HasCredentials(user.ID) {
+		t.Error("HasCredentials = true while WebAuthn is disabled")
+	}
+	if _, _, err := svc.BeginRegistration(user.ID); !errors.Is(err, ErrWebAuthnDisabled) {
+		t.Errorf("BeginRegistration: err = %v, want %v", err, ErrWebAuthnDisabled)
+	}
+	if _, _, err := svc.BeginLogin(user.ID); !errors.Is(err, ErrWebAuthnDisabled) {
+		t.Errorf("BeginLogin: err = %v, want %v", err, ErrWebAuthnDisabled)
+	}
+
+	cfg = testWebAuthnConfig
+	cfg.Passwordless = false
+	if _, _, err := NewWebAuthnService(db, cfg).BeginPasswordlessLogin(); !errors.Is(err, ErrWebAuthnDisabled) {
+		t.Errorf("BeginPasswordlessLogin: err = %v, want %v", err, ErrWebAuthnDisabled)
+	}
+}
@@ -0,0 +1,496 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/repository"
+	"homework-manager/internal/timezone"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidWebhookURL       = errors.New("invalid webhook URL")
+	ErrInvalidWebhookEvent     = errors.New("invalid webhook event")
+	// ErrWebhookAddressNotAllowed は送信先がループバック・プライベート・リンクローカル・未指定のアドレスの場合に返す。
+	ErrWebhookAddressNotAllowed = errors.New("webhook destination address is not allowed")
+)
+
+// WebhookEventPing は疎通確認用のイベント。購読設定に関係なく送信する。
+const WebhookEventPing = "ping"
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// MaxWebhookAttempts は1つの配信を試行する最大回数（初回を含む）。
+const MaxWebhookAttempts = 6
+
+// webhookRetryDelays は n 回目の失敗後、次の試行までの待ち時間。
+var webhookRetryDelays = []time.Duration{
+	1 * time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	6 * time.Hour,
+}
+
+const (
+	webhookTimeout         = 10 * time.Second
+	webhookDeliveryLease   = time.Minute
+	webhookMaxResponseBody = 2048
+	// 期限切れイベントは期限からこの時間以内の課題にだけ送る（Webhook 登録前の古い課題に一斉送信しないため）
+	webhookOverdueWindow = 24 * time.Hour
+)
+
+func GetWebhookEventLabel(event string) string {
+	switch event {
+	case models.WebhookEventAssignmentCreated:
+		return "課題の作成"
+	case models.WebhookEventAssignmentUpdated:
+		return "課題の更新"
+	case models.WebhookEventAssignmentCompleted:
+		return "課題の完了"
+	case models.WebhookEventAssignmentUncompleted:
+		return "課題の完了取り消し"
+	case models.WebhookEventAssignmentDeleted:
+		return "課題の削除"
+	case models.WebhookEventAssignmentOverdue:
+		return "課題の期限切れ"
+	case models.WebhookEventRecurringGenerated:
+		return "繰り返し課題の生成"
+	case WebhookEventPing:
+		return "疎通確認"
+	default:
+		return event
+	}
+}
+
+type WebhookPayload struct {
+	Event      string             `json:"event"`
+	OccurredAt time.Time          `json:"occurred_at"`
+	Assignment *models.Assignment `json:"assignment,omitempty"`
+}
+
+type WebhookService struct {
+	webhookRepo *repository.WebhookRepository
+	userRepo    *repository.UserRepository
+	client      *http.Client
+	now         func() time.Time
+}
+
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	return &WebhookService{
+		webhookRepo: repository.NewWebhookRepository(db),
+		userRepo:    repository.NewUserRepository(db),
+		client:      newWebhookClient(),
+		now:         func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// newWebhookClient は内部ネットワークへ送信しない HTTP クライアントを返す。
+// 接続先は名前解決後のアドレスで検査し、リダイレクトには従わない（3xx はそのまま失敗として記録する）。
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: webhookDialControl,
+	}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			// プロキシを経由すると接続先の検査がプロキシのアドレスに対して行われるため使わない
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isAllowedWebhookIP(ip) {
+		return ErrWebhookAddressNotAllowed
+	}
+	return nil
+}
+
+func isAllowedWebhookIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// SignWebhookPayload は "<timestamp>.<body>" の HMAC-SHA256 を "sha256=<hex>" 形式で返す。
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func validateWebhookInput(rawURL string, events []string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", ErrInvalidWebhookURL
+	}
+	// 名前解決後の検査は送信時に行う。ここではアドレスやホスト名で明らかに内部向けのものを先に弾く
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return "", ErrWebhookAddressNotAllowed
+	}
+	if ip := net.ParseIP(host); ip != nil && !isAllowedWebhookIP(ip) {
+		return "", ErrWebhookAddressNotAllowed
+	}
+	for _, e := range events {
+		valid := false
+		for _, known := range models.WebhookEvents {
+			if e == known {
+				valid = true
+			}
+		}
+		if !valid {
+			return "", ErrInvalidWebhookEvent
+		}
+	}
+	return u.String(), nil
+}
+
+func (s *WebhookService) Create(userID uint, name, rawURL string, events []string) (*models.Webhook, error) {
+	webhookURL, err := validateWebhookInput(rawURL, events)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &models.Webhook{
+		UserID:   userID,
+		Name:     name,
+		URL:      webhookURL,
+		Secret:   secret,
+		Events:   strings.Join(events, ","),
+		IsActive: true,
+	}
+	if err := s.webhookRepo.Create(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *WebhookService) GetByID(userID, webhookID uint) (*models.Webhook, error) {
+	webhook, err := s.webhookRepo.FindByID(webhookID)
+	if err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	if webhook.UserID != userID {
+		return nil, ErrUnauthorized
+	}
+	return webhook, nil
+}
+
+func (s *WebhookService) GetAllByUser(userID uint) ([]models.Webhook, error) {
+	return s.webhookRepo.FindByUserID(userID)
+}
+
+func (s *WebhookService) Update(userID, webhookID uint, name, rawURL string, events []string, isActive bool) (*models.Webhook, error) {
+	webhook, err := s.GetByID(userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	webhookURL, err := validateWebhookInput(rawURL, events)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Name = name
+	webhook.URL = webhookURL
+	webhook.Events = strings.Join(events, ",")
+	webhook.IsActive = isActive
+	if err := s.webhookRepo.Update(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *WebhookService) RotateSecret(userID, webhookID uint) (*models.Webhook, error) {
+	webhook, err := s.GetByID(userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = secret
+	if err := s.webhookRepo.Update(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *WebhookService) Delete(userID, webhookID uint) error {
+	webhook, err := s.GetByID(userID, webhookID)
+	if err != nil {
+		return err
+	}
+	return s.webhookRepo.Delete(webhook.ID)
+}
+
+type WebhookDeliveryPage struct {
+	Deliveries  []models.WebhookDelivery
+	TotalCount  int64
+	TotalPages  int
+	CurrentPage int
+	PageSize    int
+}
+
+func (s *WebhookService) GetDeliveries(userID, webhookID uint, page, pageSize int) (*WebhookDeliveryPage, error) {
+	if _, err := s.GetByID(userID, webhookID); err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	deliveries, totalCount, err := s.webhookRepo.FindDeliveriesByWebhookID(webhookID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+	totalPages := int((totalCount + int64(pageSize) - 1) / int64(pageSize))
+	return &WebhookDeliveryPage{
+		Deliveries:  deliveries,
+		TotalCount:  totalCount,
+		TotalPages:  totalPages,
+		CurrentPage: page,
+		PageSize:    pageSize,
+	}, nil
+}
+
+// Redeliver は過去の配信と同じ内容を新しい配信として送り直す。
+func (s *WebhookService) Redeliver(userID, deliveryID uint) (*models.WebhookDelivery, error) {
+	original, err := s.webhookRepo.FindDeliveryByID(deliveryID)
+	if err != nil {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	webhook, err := s.GetByID(userID, original.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueue(webhook, original.Event, []byte(original.Payload))
+}
+
+// Ping は疎通確認用の ping イベントを送る。
+func (s *WebhookService) Ping(userID, webhookID uint) (*models.WebhookDelivery, error) {
+	webhook, err := s.GetByID(userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	body, err := timezone.MarshalJSON(WebhookPayload{Event: WebhookEventPing, OccurredAt: s.now()}, userLocation(s.userRepo, userID))
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueue(webhook, WebhookEventPing, body)
+}
+
+// Dispatch はユーザーの有効な Webhook のうち event を購読しているものへの配信を登録し、非同期に送信する。
+// 送信に失敗した配信はワーカーがバックオフしながら再送する。
+func (s *WebhookService) Dispatch(userID uint, event string, assignment *models.Assignment) {
+	webhooks, err := s.webhookRepo.FindActiveByUserID(userID)
+	if err != nil {
+		log.Printf("Error loading webhooks for user %d: %v", userID, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	// 日時はユーザーのタイムゾーンのオフセット付きで送る（表すタイミングは変わらない）
+	payload := WebhookPayload{Event: event, OccurredAt: s.now(), Assignment: assignment}
+	body, err := timezone.MarshalJSON(payload, userLocation(s.userRepo, userID))
+	if err != nil {
+		log.Printf("Error encoding webhook payload: %v", err)
+		return
+	}
+
+	for i := range webhooks {
+		if !webhooks[i].Subscribes(event) {
+			continue
+		}
+		if _, err := s.enqueue(&webhooks[i], event, body); err != nil {
+			log.Printf("Error enqueueing webhook %d delivery: %v", webhooks[i].ID, err)
+		}
+	}
+}
+
+func (s *WebhookService) enqueue(webhook *models.Webhook, event string, body []byte) (*models.WebhookDelivery, error) {
+	now := s.now()
+	delivery := &models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		Event:         event,
+		Payload:       string(body),
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: &now,
+	}
+	if err := s.webhookRepo.CreateDelivery(delivery); err != nil {
+		return nil, err
+	}
+
+	go s.attempt(delivery)
+	return delivery, nil
+}
+
+func (s *WebhookService) attempt(delivery *models.WebhookDelivery) {
+	now := s.now()
+	claimed, err := s.webhookRepo.ClaimDelivery(delivery, now.Add(webhookDeliveryLease))
+	if err != nil {
+		log.Printf("Error claiming webhook delivery %d: %v", delivery.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	delivery.LastAttemptAt = &now
+	webhook, err := s.webhookRepo.FindByID(delivery.WebhookID)
+	if err != nil || !webhook.IsActive {
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+		delivery.Error = "webhook is deleted or disabled"
+		s.saveDelivery(delivery)
+		return
+	}
+
+	status, responseBody, err := s.send(webhook, delivery)
+	delivery.ResponseStatus = status
+	delivery.ResponseBody = responseBody
+	if err == nil && (status < 200 || status >= 300) {
+		err = fmt.Errorf("endpoint returned status %d", status)
+	}
+
+	if err == nil {
+		delivered := s.now()
+		delivery.Status = models.WebhookDeliverySuccess
+		delivery.DeliveredAt = &delivered
+		delivery.NextAttemptAt = nil
+		delivery.Error = ""
+	} else {
+		delivery.Error = err.Error()
+		if delivery.Attempts >= MaxWebhookAttempts {
+			delivery.Status = models.WebhookDeliveryFailed
+			delivery.NextAttemptAt = nil
+		} else {
+			next := s.now().Add(webhookRetryDelays[delivery.Attempts-1])
+			delivery.NextAttemptAt = &next
+		}
+	}
+	s.saveDelivery(delivery)
+}
+
+func (s *WebhookService) saveDelivery(delivery *models.WebhookDelivery) {
+	if err := s.webhookRepo.UpdateDelivery(delivery); err != nil {
+		log.Printf("Error saving webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+func (s *WebhookService) send(webhook *models.Webhook, delivery *models.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	timestamp := s.now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Super-HomeworkManager-Webhook/1.0")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxResponseBody))
+	return resp.StatusCode, string(responseBody), nil
+}
+
+// ProcessDueDeliveries は再送時刻を過ぎた配信を送信する。
+func (s *WebhookService) ProcessDueDeliveries() {
+	deliveries, err := s.webhookRepo.FindDueDeliveries(s.now(), 100)
+	if err != nil {
+		log.Printf("Error fetching webhook deliveries: %v", err)
+		return
+	}
+	for i := range deliveries {
+		s.attempt(&deliveries[i])
+	}
+}
+
+// ProcessOverdueAssignments は期限を過ぎた未完了の課題について期限切れイベントを1回だけ送る。
+func (s *WebhookService) ProcessOverdueAssignments() {
+	now := s.now()
+	assignments, err := s.webhookRepo.FindNewlyOverdueAssignments(now.Add(-webhookOverdueWindow), now)
+	if err != nil {
+		log.Printf("Error fetching overdue assignments for webhooks: %v", err)
+		return
+	}
+	for i := range assignments {
+		if err := s.webhookRepo.MarkOverdueNotified(assignments[i].ID, now); err != nil {
+			log.Printf("Error marking assignment %d as overdue notified: %v", assignments[i].ID, err)
+			continue
+		}
+		s.Dispatch(assignments[i].UserID, models.WebhookEventAssignmentOverdue, &assignments[i])
+	}
+}
+
+func (s *WebhookService) StartDeliveryWorker(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.ProcessOverdueAssignments()
+			s.ProcessDueDeliveries()
+		}
+	}()
+	log.Printf("Webhook delivery worker started (interval: %s)", interval)
+}
@@ -0,0 +1,422 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"homework-manager/internal/models"
+	"homework-manager/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// waitForWebhookAttempt は非同期の初回送信が終わり、結果が保存されるまで待つ。
+func waitForWebhookAttempt(t *testing.T, db *gorm.DB, deliveryID uint) *models.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var delivery models.WebhookDelivery
+		if err := db.First(&delivery, deliveryID).Error; err != nil {
+			t.Fatalf("find delivery: %v", err)
+		}
+		if delivery.LastAttemptAt != nil {
+			return &delivery
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery %d was not attempted", deliveryID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebhookRejectsInternalURL(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "webhook@example.com")
+	svc := NewWebhookService(db)
+
+	for _, rawURL := range []string{
+		"http://127.0.0.1/hook",
+		"http://localhost:8080/hook",
+		"http://api.localhost/hook",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.10/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		if _, err := svc.Create(user.ID, "internal", rawURL, nil); !errors.Is(err, ErrWebhookAddressNotAllowed) {
+			t.Errorf("Create(%q) error = %v, want ErrWebhookAddressNotAllowed", rawURL, err)
+		}
+	}
+	if _, err := svc.Create(user.ID, "public", "https://example.com/hook", nil); err != nil {
+		t.Errorf("Create with a public URL: %v", err)
+	}
+}
+
+// 登録時の検査をすり抜けた（名前解決の結果が変わった）場合も、送信時に接続先のアドレスで拒否する
+func TestWebhookDoesNotConnectToLoopback(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "loopback@example.com")
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	t.Cleanup(server.Close)
+
+	webhook := &models.Webhook{UserID: user.ID, Name: "loopback", URL: server.URL, Secret: "whsec_test", IsActive: true}
+	if err := db.Create(webhook).Error; err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+
+	svc := NewWebhookService(db)
+	delivery, err := svc.Ping(user.ID, webhook.ID)
+	if err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	got := waitForWebhookAttempt(t, db, delivery.ID)
+	if hits.Load() != 0 {
+		t.Fatal("request reached the loopback server")
+	}
+	if got.Status != models.WebhookDeliveryPending || got.ResponseStatus != 0 || !strings.Contains(got.Error, ErrWebhookAddressNotAllowed.Error()) {
+		t.Errorf("delivery = %+v, want a failed attempt rejected before connecting", got)
+	}
+}
+
+func TestWebhookClientDoesNotFollowRedirects(t *testing.T) {
+	var hits atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	t.Cleanup(target.Close)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	t.Cleanup(redirect.Close)
+
+	// 転送先の検査とは別に確かめるため、接続先の制限だけを外したクライアントを使う
+	client := newWebhookClient()
+	client.Transport = http.DefaultTransport
+	resp, err := client.Post(redirect.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || hits.Load() != 0 {
+		t.Errorf("status = %d, redirect target hit %d time(s); want 302 and no hits", resp.StatusCode, hits.Load())
+	}
+}
+
+// newWebhookTestServer は受信したイベント名を記録するテスト用の送信先を作り、有効な Webhook として登録する。
+// 送信先はループバックのため、svc のクライアントは接続先の制限を外したものに差し替える。
+func newWebhookTestServer(t *testing.T, db *gorm.DB, svc *WebhookService, userID uint, events string) (*models.Webhook, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.Header.Get(WebhookEventHeader))
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	svc.client = server.Client()
+
+	webhook := &models.Webhook{UserID: userID, Name: "test", URL: server.URL, Secret: "whsec_test", Events: events, IsActive: true}
+	if err := db.Create(webhook).Error; err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	return webhook, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), received...)
+	}
+}
+
+// webhookDeliveryEvents は Webhook の配信を登録順に返す。非同期の初回送信が終わるまで待つ。
+func webhookDeliveryEvents(t *testing.T, db *gorm.DB, webhookID uint) []string {
+	t.Helper()
+	var deliveries []models.WebhookDelivery
+	if err := db.Where("webhook_id = ?", webhookID).Order("id ASC").Find(&deliveries).Error; err != nil {
+		t.Fatalf("find deliveries: %v", err)
+	}
+	events := make([]string, len(deliveries))
+	for i := range deliveries {
+		waitForWebhookAttempt(t, db, deliveries[i].ID)
+		events[i] = deliveries[i].Event
+	}
+	return events
+}
+
+func TestImportsDispatchWebhooks(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "import-webhook@example.com")
+
+	calendar := NewCalendarService(db)
+	webhook, _ := newWebhookTestServer(t, db, calendar.webhookService, user.ID, "")
+
+	ics := func(due string) []byte {
+		return []byte("BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:task-1@example.com\r\nSUMMARY:レポート\r\nDUE:" + due + "\r\nEND:VTODO\r\nEND:VCALENDAR\r\n")
+	}
+	if _, err := calendar.ImportICal(user.ID, ics("20260501T090000Z"), false); err != nil {
+		t.Fatalf("ImportICal: %v", err)
+	}
+
+	// 期限切れイベントの送信済みの課題の期限を変えると、再び送信対象になる
+	notified := time.Now().UTC()
+	db.Model(&models.Assignment{}).Where("user_id = ?", user.ID).Update("overdue_notified_at", notified)
+	if _, err := calendar.ImportICal(user.ID, ics("20260502T090000Z"), false); err != nil {
+		t.Fatalf("ImportICal (update): %v", err)
+	}
+	var stored models.Assignment
+	db.Where("user_id = ?", user.ID).First(&stored)
+	if stored.OverdueNotifiedAt != nil {
+		t.Error("overdue_notified_at not cleared after the due date changed")
+	}
+
+	transfer := NewDataTransferService(db, nil)
+	transfer.webhookService.client = calendar.webhookService.client
+	doc := `{"version":1,"assignments":[` +
+		`{"uid":"task-1@example.com","title":"レポート（改訂）","priority":"medium","due_date":"2026-05-02T09:00:00Z"},` +
+		`{"uid":"task-2@example.com","title":"問題集","priority":"low","due_date":"2026-05-03T09:00:00Z"}]}`
+	if result, err := transfer.ImportData(user.ID, []byte(doc)); err != nil || len(result.Errors) != 0 {
+		t.Fatalf("ImportData = %+v, %v", result, err)
+	}
+
+	want := []string{
+		models.WebhookEventAssignmentCreated,
+		models.WebhookEventAssignmentUpdated,
+		models.WebhookEventAssignmentUpdated,
+		models.WebhookEventAssignmentCreated,
+	}
+	if got := webhookDeliveryEvents(t, db, webhook.ID); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("deliveries = %v, want %v", got, want)
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "signature@example.com")
+
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header.Clone(), body: body}
+	}))
+	t.Cleanup(server.Close)
+
+	svc := NewWebhookService(db)
+	svc.client = server.Client()
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	webhook := &models.Webhook{UserID: user.ID, Name: "signed", URL: server.URL, Secret: "whsec_0123456789", IsActive: true}
+	if err := db.Create(webhook).Error; err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	delivery, err := svc.Ping(user.ID, webhook.ID)
+	if err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	req := <-requests
+	if got := waitForWebhookAttempt(t, db, delivery.ID); got.Status != models.WebhookDeliverySuccess {
+		t.Errorf("delivery status = %q, want success", got.Status)
+	}
+
+	timestamp := req.header.Get(WebhookTimestampHeader)
+	if timestamp != strconv.FormatInt(now.Unix(), 10) {
+		t.Errorf("timestamp = %q, want %d", timestamp, now.Unix())
+	}
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write([]byte(timestamp + "." + string(req.body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.header.Get(WebhookSignatureHeader); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if req.header.Get(WebhookEventHeader) != WebhookEventPing || req.header.Get(WebhookDeliveryHeader) != strconv.FormatUint(uint64(delivery.ID), 10) {
+		t.Errorf("event / delivery headers = %q / %q", req.header.Get(WebhookEventHeader), req.header.Get(WebhookDeliveryHeader))
+	}
+
+	// 本文を改ざんすると署名が一致しない
+	if SignWebhookPayload(webhook.Secret, now.Unix(), append(req.body, ' ')) == want {
+		t.Error("signature does not depend on the body")
+	}
+}
+
+func TestWebhookRetrySchedule(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "retry@example.com")
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	svc := NewWebhookService(db)
+	svc.client = server.Client()
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	webhook := &models.Webhook{UserID: user.ID, Name: "failing", URL: server.URL, Secret: "whsec_test", IsActive: true}
+	if err := db.Create(webhook).Error; err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	created, err := svc.Ping(user.ID, webhook.ID)
+	if err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	delivery := waitForWebhookAttempt(t, db, created.ID)
+
+	elapsed := time.Duration(0)
+	for attempt := 1; attempt < MaxWebhookAttempts; attempt++ {
+		delay := webhookRetryDelays[attempt-1]
+		wantNext := now.Add(elapsed + delay)
+		if delivery.Status != models.WebhookDeliveryPending || delivery.Attempts != attempt || delivery.ResponseStatus != http.StatusServiceUnavailable ||
+			delivery.NextAttemptAt == nil || !delivery.NextAttemptAt.Equal(wantNext) {
+			t.Fatalf("after attempt %d: %+v, want pending until %v", attempt, delivery, wantNext)
+		}
+
+		// 再送時刻の前には送らない
+		svc.now = func() time.Time { return wantNext.Add(-time.Second) }
+		svc.ProcessDueDeliveries()
+		if int(hits.Load()) != attempt {
+			t.Fatalf("retried %d time(s) before %v", int(hits.Load())-attempt, wantNext)
+		}
+
+		elapsed += delay
+		svc.now = func() time.Time { return wantNext }
+		svc.ProcessDueDeliveries()
+		delivery = &models.WebhookDelivery{}
+		if err := db.First(delivery, created.ID).Error; err != nil {
+			t.Fatalf("find delivery: %v", err)
+		}
+	}
+
+	// 最後の試行に失敗すると failed になり、以後は再送しない
+	if delivery.Status != models.WebhookDeliveryFailed || delivery.Attempts != MaxWebhookAttempts || delivery.NextAttemptAt != nil ||
+		!strings.Contains(delivery.Error, "status 503") {
+		t.Fatalf("after the last attempt: %+v", delivery)
+	}
+	svc.now = func() time.Time { return now.Add(48 * time.Hour) }
+	svc.ProcessDueDeliveries()
+	if int(hits.Load()) != MaxWebhookAttempts {
+		t.Errorf("endpoint hit %d time(s), want %d", hits.Load(), MaxWebhookAttempts)
+	}
+}
+
+// 再送待ちの間に無効にした Webhook の配信は送らずに failed にする
+func TestWebhookDisabledBeforeRetry(t *testing.T) {
+	db := testutil.OpenDB(t)
+	user := createTestUser(t, db, "disabled@example.com")
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	svc := NewWebhookService(db)
+	svc.client = server.Client()
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	webhook := &models.Webhook{UserID: user.ID, Name: "flaky", URL: server.URL, Secret: "whsec_test", IsActive: true}
+	if err := db.Create(webhook).Error; err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	created, err := svc.Ping(user.ID, webhook.ID)
+	if err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	waitForWebhookAttempt(t, db, created.ID)
+
+	if _, err := svc.Update(user.ID, webhook.ID, webhook.Name, "https://example.com/hook", nil, false); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	svc.now = func() time.Time { return now.Add(webhookRetryDelays[0]) }
+	svc.ProcessDueDeliveries()
+
+	var delivery models.WebhookDelivery
+	db.First(&delivery, created.ID)
+	if delivery.Status != models.WebhookDeliveryFailed || delivery.NextAttemptAt != nil || hits.Load() != 1 {
+		t.Errorf("delivery = %+v after %d request(s), want failed without another request", delivery, hits.Load())
+	}
+}
+
+func TestWebhookEventFiltering(t *testing.T) {
+	tests := []struct {
+		name   string
+		events string
+		active bool
+		want   []string
+	}{
+		{
+			name:   "全イベント",
+			active: true,
+			want:   []string{models.WebhookEventAssignmentCreated, models.WebhookEventAssignmentCompleted, models.WebhookEventAssignmentDeleted},
+		},
+		{
+			name:   "完了だけを購読",
+			events: models.WebhookEventAssignmentCompleted,
+			active: true,
+			want:   []string{models.WebhookEventAssignmentCompleted},
+		},
+		{
+			name:   "作成と削除を購読",
+			events: models.WebhookEventAssignmentCreated + "," + models.WebhookEventAssignmentDeleted,
+			active: true,
+			want:   []string{models.WebhookEventAssignmentCreated, models.WebhookEventAssignmentDeleted},
+		},
+		{
+			name: "無効",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.OpenDB(t)
+			user := createTestUser(t, db, "filter@example.com")
+
+			assignments := NewAssignmentService(db)
+			webhook, received := newWebhookTestServer(t, db, assignments.webhookService, user.ID, tt.events)
+			if !tt.active {
+				db.Model(webhook).Update("is_active", false)
+			}
+
+			assignment, err := assignments.Create(user.ID, "レポート", "", "", "medium", time.Now().Add(24*time.Hour), false, nil, false)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if _, err := assignments.ToggleComplete(user.ID, assignment.ID); err != nil {
+				t.Fatalf("ToggleComplete: %v", err)
+			}
+			if err := assignments.Delete(user.ID, assignment.ID); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+
+			got := webhookDeliveryEvents(t, db, webhook.ID)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("deliveries = %v, want %v", got, tt.want)
+			}
+			if len(received()) != len(tt.want) {
+				t.Errorf("endpoint received %v, want %v", received(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package sessionstore
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"homework-manager/internal/models"
+)
+
+// MemoryBackend はプロセス内のメモリにセッションを保存する。再起動するとすべてのセッションが失われるため、テストや開発用。
+type MemoryBackend struct {
+	mu       sync.Mutex
+	sessions map[string]models.Session
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{sessions: make(map[string]models.Session)}
+}
+
+func (b *MemoryBackend) Find(id string) (*models.Session, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	session, ok := b.sessions[id]
+	if !ok || !session.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+func (b *MemoryBackend) Create(session *models.Session) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sessions[session.ID] = *session
+	return nil
+}
+
+func (b *MemoryBackend) Update(session *models.Session) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stored, ok := b.sessions[session.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.UserID = session.UserID
+	stored.Data = session.Data
+	stored.ExpiresAt = session.ExpiresAt
+	b.sessions[session.ID] = stored
+	return nil
+}
+
+func (b *MemoryBackend) Delete(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sessions, id)
+	return nil
+}
+
+func (b *MemoryBackend) Touch(id, ip, userAgent string, seenAt time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	session, ok := b.sessions[id]
+	if !ok {
+		return nil
+	}
+	if session.IP == ip && session.UserAgent == userAgent && seenAt.Sub(session.LastSeenAt) < TouchInterval {
+		return nil
+	}
+	session.IP = ip
+	session.UserAgent = userAgent
+	session.LastSeenAt = seenAt
+	b.sessions[id] = session
+	return nil
+}
+
+func (b *MemoryBackend) ListByUser(userID uint) ([]models.Session, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	var sessions []models.Session
+	for _, session := range b.sessions {
+		if session.UserID == userID && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+func (b *MemoryBackend) DeleteByUser(userID uint, exceptID string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var deleted int64
+	for id, session := range b.sessions {
+		if session.UserID == userID && id != exceptID {
+			delete(b.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (b *MemoryBackend) DeleteExpired(now time.Time) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var deleted int64
+	for id, session := range b.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(b.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,220 @@
+// Package sessionstore はセッションの値をサーバー側に保存する gin-contrib/sessions 用のストア。
+// Cookie には署名付きのランダムな ID だけを入れるため、サーバー側でセッションを無効化できる。
+package sessionstore
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"homework-manager/internal/models"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+)
+
+// TouchInterval より短い間隔のアクセスでは最終アクセス日時を更新しない（リクエストごとの書き込みを避ける）。
+const TouchInterval = time.Minute
+
+// anonymousTTL はログイン前のセッション（CSRF トークンや 2 段階認証の途中など）をサーバーに保持する期間。
+const anonymousTTL = 24 * time.Hour
+
+const maxUserAgentLength = 255
+
+var ErrNotFound = errors.New("session not found")
+
+// Backend はセッションの保存先。ID はすべて HashID で変換した値を使う。
+type Backend interface {
+	// Find は有効期限内のセッションを返す。見つからない場合は ErrNotFound を返す。
+	Find(id string) (*models.Session, error)
+	Create(session *models.Session) error
+	// Update は UserID、Data、ExpiresAt を更新する。セッションが削除されていた場合は ErrNotFound を返す。
+	Update(session *models.Session) error
+	Delete(id string) error
+	// Touch は最終アクセス日時・IP・ユーザーエージェントを記録する。TouchInterval 以内で IP とユーザーエージェントが同じ場合は何もしない。
+	Touch(id, ip, userAgent string, seenAt time.Time) error
+	// ListByUser はユーザーの有効なセッションを最終アクセスの新しい順に返す。
+	ListByUser(userID uint) ([]models.Session, error)
+	// DeleteByUser はユーザーのセッションを exceptID 以外すべて削除する。
+	DeleteByUser(userID uint, exceptID string) (int64, error)
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+// HashID は Cookie に入れる ID から保存用の ID を求める。
+func HashID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+var base32RawStdEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func newID() string {
+	return base32RawStdEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+}
+
+// Store は sessions.Store の実装。
+type Store struct {
+	Codecs    []securecookie.Codec
+	options   *gsessions.Options
+	backend   Backend
+	userIDKey string
+}
+
+// NewStore はストアを作成する。userIDKey はログイン中のユーザー ID（uint）を入れるセッションのキーで、
+// 値が変わったときにセッション ID を振り直す（セッション固定攻撃対策）のと、ユーザーごとのセッション一覧に使う。
+func NewStore(backend Backend, userIDKey string, keyPairs ...[]byte) *Store {
+	s := &Store{
+		Codecs:    securecookie.CodecsFromPairs(keyPairs...),
+		options:   &gsessions.Options{Path: "/", MaxAge: 86400 * 30},
+		backend:   backend,
+		userIDKey: userIDKey,
+	}
+	s.setMaxAge(s.options.MaxAge)
+	return s
+}
+
+func (s *Store) Options(options sessions.Options) {
+	s.options = options.ToGorillaOptions()
+	s.setMaxAge(s.options.MaxAge)
+}
+
+func (s *Store) setMaxAge(age int) {
+	for _, codec := range s.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
+
+func (s *Store) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New は Cookie の ID に対応するセッションを読み込む。Cookie が不正な場合や、セッションが無効化・期限切れの場合は空のセッションを返す。
+func (s *Store) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	opts := *s.options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var id string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &id, s.Codecs...); err != nil {
+		return session, nil
+	}
+	record, err := s.backend.Find(HashID(id))
+	if errors.Is(err, ErrNotFound) {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	if err := securecookie.DecodeMulti(name, record.Data, &session.Values, s.Codecs...); err != nil {
+		return session, nil
+	}
+	session.ID = id
+	session.IsNew = false
+	return session, nil
+}
+
+// Save はセッションを保存して Cookie を送る。値が空（ログアウト）か MaxAge が 0 以下の場合はセッションを削除する。
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options.MaxAge <= 0 || len(session.Values) == 0 {
+		if session.ID == "" {
+			return nil
+		}
+		if err := s.backend.Delete(HashID(session.ID)); err != nil {
+			return err
+		}
+		s.expireCookie(w, session)
+		return nil
+	}
+
+	userID, _ := session.Values[s.userIDKey].(uint)
+	now := time.Now().UTC()
+
+	var record *models.Session
+	if session.ID != "" {
+		found, err := s.backend.Find(HashID(session.ID))
+		switch {
+		case errors.Is(err, ErrNotFound):
+			// リクエストの処理中に別の端末から無効化された。保存し直すとセッションが復活するので値は破棄する
+			s.expireCookie(w, session)
+			return nil
+		case err != nil:
+			return err
+		case found.UserID != userID:
+			// ログインでユーザーが変わったら ID を振り直す
+			if err := s.backend.Delete(found.ID); err != nil {
+				return err
+			}
+		default:
+			record = found
+		}
+	}
+
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	expiresAt := now.Add(s.lifetime(session, userID))
+
+	if record != nil {
+		record.Data = data
+		record.ExpiresAt = expiresAt
+		if err := s.backend.Update(record); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				s.expireCookie(w, session)
+				return nil
+			}
+			return err
+		}
+	} else {
+		session.ID = newID()
+		userAgent := r.UserAgent()
+		if len(userAgent) > maxUserAgentLength {
+			userAgent = userAgent[:maxUserAgentLength]
+		}
+		record = &models.Session{
+			ID:         HashID(session.ID),
+			UserID:     userID,
+			Data:       data,
+			UserAgent:  userAgent,
+			LastSeenAt: now,
+			ExpiresAt:  expiresAt,
+			CreatedAt:  now,
+		}
+		if err := s.backend.Create(record); err != nil {
+			return err
+		}
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+func (s *Store) lifetime(session *gsessions.Session, userID uint) time.Duration {
+	lifetime := time.Duration(session.Options.MaxAge) * time.Second
+	if userID == 0 && lifetime > anonymousTTL {
+		return anonymousTTL
+	}
+	return lifetime
+}
+
+func (s *Store) expireCookie(w http.ResponseWriter, session *gsessions.Session) {
+	opts := *session.Options
+	opts.MaxAge = -1
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), "", &opts))
+	session.ID = ""
+}
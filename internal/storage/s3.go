@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// unsignedPayload は本文をハッシュせずに送るときの x-amz-content-sha256。アップロードを読み込み直さずにそのまま送れる。
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// emptyPayloadHash は本文が空のときの SHA-256。
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config は S3 互換のオブジェクトストレージの接続先。
+type S3Config struct {
+	Endpoint  string // https://s3.ap-northeast-1.amazonaws.com、MinIO などは http://localhost:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // バケット名をホスト名ではなくパスに含める（MinIO などで必要）
+}
+
+// S3 は S3 互換のオブジェクトストレージに保存する。リクエストには AWS Signature Version 4 で署名する。
+type S3 struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("storage: S3 endpoint, bucket, access key and secret key are required")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("storage: invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &S3{
+		config:   cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+func (s *S3) objectURL(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+	path := strings.Join(segments, "/")
+
+	if s.config.PathStyle {
+		return fmt.Sprintf("%s://%s%s/%s/%s", s.endpoint.Scheme, s.endpoint.Host, s.endpoint.Path, awsEscape(s.config.Bucket), path)
+	}
+	return fmt.Sprintf("%s://%s.%s%s/%s", s.endpoint.Scheme, s.config.Bucket, s.endpoint.Host, s.endpoint.Path, path)
+}
+
+func (s *S3) do(method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	if !validKey(key) {
+		return nil, ErrInvalidKey
+	}
+	req, err := http.NewRequest(method, s.objectURL(key), body)
+	if err != nil {
+		return nil, err
+	}
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		req.ContentLength = size
+		payloadHash = unsignedPayload
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	signV4(req, payloadHash, s.config.AccessKey, s.config.SecretKey, s.config.Region, s.now())
+	return s.client.Do(req)
+}
+
+func (s *S3) Put(key string, r io.Reader, size int64, contentType string) error {
+	resp, err := s.do(http.MethodPut, key, r, size, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s3Error(resp, http.MethodPut, key)
+	}
+	return nil
+}
+
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, s3Error(resp, http.MethodGet, key)
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp, http.MethodDelete, key)
+	}
+	return nil
+}
+
+func s3Error(resp *http.Response, method, key string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("storage: S3 %s %s: %s %s", method, key, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// signV4 は AWS Signature Version 4 で req に署名する。Host と req.Header のすべてのヘッダーを署名に含める。
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	names := []string{"host"}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		names = append(names, lower)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex(canonicalRequest)}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(values url.Values) string {
+	var pairs []string
+	for name, list := range values {
+		for _, value := range list {
+			pairs = append(pairs, awsEscape(name)+"="+awsEscape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape は RFC 3986 の非予約文字以外をパーセントエンコードする（AWS の URI エンコード）。
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,169 @@
+// Package s3test はテストとローカル開発用のモック S3（MinIO のようなパス形式の S3 互換サーバー）。
+// PutObject・GetObject・DeleteObject だけに対応し、オブジェクトはメモリに保持する。
+package s3test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type object struct {
+	data        []byte
+	contentType string
+}
+
+// Server はモック S3。http.Handler として任意のサーバーに載せられる。リクエストの署名（AWS Signature Version 4）を検証する。
+type Server struct {
+	AccessKey string
+	SecretKey string
+	Region    string
+
+	mu      sync.Mutex
+	buckets map[string]map[string]*object
+}
+
+// New は bucket を 1 つ持つモック S3 を返す。
+func New(accessKey, secretKey, region, bucket string) *Server {
+	return &Server{
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Region:    region,
+		buckets:   map[string]map[string]*object{bucket: {}},
+	}
+}
+
+// Object はオブジェクトの内容を返す。存在しない場合は ok が false。
+func (s *Server) Object(bucket, key string) (data []byte, contentType string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.buckets[bucket][key]
+	if !ok {
+		return nil, "", false
+	}
+	return obj.data, obj.contentType, true
+}
+
+// Len はバケット内のオブジェクトの数を返す。
+func (s *Server) Len(bucket string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets[bucket])
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.verify(r) {
+		s3Error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bucket, ok := s.buckets[bucketName]
+	if !ok {
+		s3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if key == "" {
+		s3Error(w, http.StatusBadRequest, "InvalidRequest")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			s3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		bucket[key] = &object{data: data, contentType: r.Header.Get("Content-Type")}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		obj, ok := bucket[key]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Write(obj.data)
+	case http.MethodDelete:
+		delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// verify は Authorization ヘッダーの署名を検証する。
+func (s *Server) verify(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	params, ok := strings.CutPrefix(auth, "AWS4-HMAC-SHA256 ")
+	if !ok {
+		return false
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		fields[name] = value
+	}
+
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 5 || credential[0] != s.AccessKey || credential[2] != s.Region || credential[3] != "s3" || credential[4] != "aws4_request" {
+		return false
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, credential[1]) {
+		return false
+	}
+
+	signedHeaders := strings.Split(fields["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signedHeaders) {
+		return false
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	scope := strings.Join(credential[1:], "/")
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + s.SecretKey)
+	for _, part := range []string{credential[1], credential[2], "s3", "aws4_request"} {
+		key = sum(key, part)
+	}
+	want := hex.EncodeToString(sum(key, stringToSign))
+	return hmac.Equal([]byte(want), []byte(fields["Signature"]))
+}
+
+func sum(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func s3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<Error><Code>%s</Code></Error>", code)
+}
@@ -0,0 +1,63 @@
+// Package storage は添付ファイルの保存先。ローカルのファイルシステムと S3 互換のオブジェクトストレージに対応する。
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"homework-manager/internal/config"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Storage はファイルの保存先。キーは "/" 区切りの相対パス（例: 1/23/0a1b2c）。
+type Storage interface {
+	// Put は r から size バイトを読み取り、key に保存する。既に存在する場合は上書きする。
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get は key の内容を返す。見つからない場合は ErrNotFound を返す。
+	Get(key string) (io.ReadCloser, error)
+	// Delete は key を削除する。存在しない場合も成功とする。
+	Delete(key string) error
+}
+
+// New は設定に応じた保存先を返す。
+func New(cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Backend {
+	case BackendLocal:
+		return NewLocal(cfg.LocalPath)
+	case BackendS3:
+		return NewS3(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+// validKey は key が保存先の外を指さない相対パスかを返す。
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,214 @@
+// Package telegram は Telegram Bot API のクライアント。通知の送信とボットの更新の受信 (getUpdates / Webhook) に使う。
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DefaultAPIURL = "https://api.telegram.org"
+
+// SecretTokenHeader は setWebhook で指定したシークレットが入るヘッダー。
+const SecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+var ErrNotConfigured = errors.New("telegram bot token is not configured")
+
+type User struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	Username  string `json:"username"`
+}
+
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+type Message struct {
+	MessageID   int64                 `json:"message_id"`
+	From        *User                 `json:"from,omitempty"`
+	Chat        Chat                  `json:"chat"`
+	Date        int64                 `json:"date"`
+	Text        string                `json:"text"`
+	ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+type CallbackQuery struct {
+	ID      string   `json:"id"`
+	From    User     `json:"from"`
+	Message *Message `json:"message,omitempty"`
+	Data    string   `json:"data"`
+}
+
+type Update struct {
+	UpdateID      int64          `json:"update_id"`
+	Message       *Message       `json:"message,omitempty"`
+	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+}
+
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data,omitempty"`
+	URL          string `json:"url,omitempty"`
+}
+
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+// APIError は Bot API が ok=false を返したときのエラー。
+type APIError struct {
+	Method      string
+	StatusCode  int
+	Description string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram %s failed (status %d): %s", e.Method, e.StatusCode, e.Description)
+}
+
+type Client struct {
+	token  string
+	apiURL string
+	http   *http.Client
+}
+
+// NewClient は apiURL（空の場合は https://api.telegram.org）の Bot API を呼び出すクライアントを返す。
+// テストでは apiURL に偽の API サーバーを指定する。
+func NewClient(token, apiURL string) *Client {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	return &Client{
+		token:  token,
+		apiURL: strings.TrimRight(apiURL, "/"),
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *Client) Configured() bool {
+	return c.token != ""
+}
+
+func (c *Client) call(method string, params interface{}, result interface{}, timeout time.Duration) error {
+	if c.token == "" {
+		return ErrNotConfigured
+	}
+
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	client := c.http
+	if timeout > 0 {
+		client = &http.Client{Timeout: timeout}
+	}
+	resp, err := client.Post(fmt.Sprintf("%s/bot%s/%s", c.apiURL, c.token, method), "application/json", bytes.NewReader(body))
+	if err != nil {
+		// URL にトークンが含まれるため、エラーからは取り除く
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("telegram %s: %w", method, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		Description string          `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil || !envelope.OK {
+		description := envelope.Description
+		if description == "" {
+			description = http.StatusText(resp.StatusCode)
+		}
+		return &APIError{Method: method, StatusCode: resp.StatusCode, Description: description}
+	}
+	if result != nil {
+		return json.Unmarshal(envelope.Result, result)
+	}
+	return nil
+}
+
+func (c *Client) GetMe() (*User, error) {
+	var user User
+	if err := c.call("getMe", struct{}{}, &user, 0); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUpdates は offset 以降の更新をロングポーリングで取得する。timeout は秒。
+func (c *Client) GetUpdates(offset int64, timeout int) ([]Update, error) {
+	params := map[string]interface{}{
+		"offset":          offset,
+		"timeout":         timeout,
+		"allowed_updates": []string{"message", "callback_query"},
+	}
+	var updates []Update
+	if err := c.call("getUpdates", params, &updates, time.Duration(timeout+10)*time.Second); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// SendMessage は parse_mode=HTML でメッセージを送信する。markup が nil の場合はボタンを付けない。
+func (c *Client) SendMessage(chatID, text string, markup *InlineKeyboardMarkup) error {
+	if chatID == "" {
+		return errors.New("telegram chat ID is empty")
+	}
+	params := map[string]interface{}{
+		"chat_id":    chatID,
+		"text":       text,
+		"parse_mode": "HTML",
+	}
+	if markup != nil {
+		params["reply_markup"] = markup
+	}
+	return c.call("sendMessage", params, nil, 0)
+}
+
+func (c *Client) AnswerCallbackQuery(callbackQueryID, text string) error {
+	return c.call("answerCallbackQuery", map[string]interface{}{
+		"callback_query_id": callbackQueryID,
+		"text":              text,
+	}, nil, 0)
+}
+
+// EditMessageReplyMarkup はメッセージのボタンを差し替える。markup が nil の場合はボタンを消す。
+func (c *Client) EditMessageReplyMarkup(chatID string, messageID int64, markup *InlineKeyboardMarkup) error {
+	if markup == nil {
+		markup = &InlineKeyboardMarkup{InlineKeyboard: [][]InlineKeyboardButton{}}
+	}
+	return c.call("editMessageReplyMarkup", map[string]interface{}{
+		"chat_id":      chatID,
+		"message_id":   messageID,
+		"reply_markup": markup,
+	}, nil, 0)
+}
+
+// SetWebhook は更新の送信先を url に設定する。secretToken は SecretTokenHeader で送られてくる。
+func (c *Client) SetWebhook(webhookURL, secretToken string) error {
+	params := map[string]interface{}{
+		"url":             webhookURL,
+		"allowed_updates": []string{"message", "callback_query"},
+	}
+	if secretToken != "" {
+		params["secret_token"] = secretToken
+	}
+	return c.call("setWebhook", params, nil, 0)
+}
+
+func (c *Client) DeleteWebhook() error {
+	return c.call("deleteWebhook", struct{}{}, nil, 0)
+}
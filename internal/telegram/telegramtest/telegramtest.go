@@ -0,0 +1,134 @@
+// Package telegramtest はテストとローカル開発用の偽の Telegram Bot API。
+// 送信されたメッセージをメモリに記録し、getUpdates では Push で積んだ更新を返す。
+package telegramtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"homework-manager/internal/telegram"
+)
+
+// SentMessage は sendMessage で送られたメッセージ。
+type SentMessage struct {
+	ChatID      string
+	Text        string
+	ParseMode   string
+	ReplyMarkup *telegram.InlineKeyboardMarkup
+}
+
+type failure struct {
+	status      int
+	description string
+}
+
+// Server は偽の Bot API。http.Handler として任意のサーバーに載せられる。URL のトークンが Token と一致しないリクエストは 401 にする。
+type Server struct {
+	Token    string
+	Username string
+
+	mu       sync.Mutex
+	sent     []SentMessage
+	calls    []string
+	updates  []telegram.Update
+	failures map[string][]failure
+}
+
+func New(token, username string) *Server {
+	return &Server{Token: token, Username: username, failures: make(map[string][]failure)}
+}
+
+// Sent は送信されたメッセージを送信順に返す。
+func (s *Server) Sent() []SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SentMessage(nil), s.sent...)
+}
+
+// Calls は呼び出されたメソッド名を呼び出し順に返す（失敗したものを含む）。
+func (s *Server) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+// Push は次の getUpdates で返す更新を積む。update_id は呼び出し側で設定する。
+func (s *Server) Push(update telegram.Update) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updates = append(s.updates, update)
+}
+
+// Fail は次の method の呼び出しを status と description で失敗させる。複数回呼ぶとその回数だけ失敗させる。
+func (s *Server) Fail(method string, status int, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = append(s.failures[method], failure{status: status, description: description})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+	if !ok || token != s.Token {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var params map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: invalid JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, method)
+	if queued := s.failures[method]; len(queued) > 0 {
+		s.failures[method] = queued[1:]
+		writeError(w, queued[0].status, queued[0].description)
+		return
+	}
+
+	switch method {
+	case "getMe":
+		writeResult(w, telegram.User{ID: 1, IsBot: true, FirstName: "HomeworkBot", Username: s.Username})
+	case "sendMessage":
+		var msg SentMessage
+		json.Unmarshal(params["chat_id"], &msg.ChatID)
+		json.Unmarshal(params["text"], &msg.Text)
+		json.Unmarshal(params["parse_mode"], &msg.ParseMode)
+		if raw, ok := params["reply_markup"]; ok {
+			msg.ReplyMarkup = &telegram.InlineKeyboardMarkup{}
+			json.Unmarshal(raw, msg.ReplyMarkup)
+		}
+		if msg.ChatID == "" || msg.Text == "" {
+			writeError(w, http.StatusBadRequest, "Bad Request: chat_id and text are required")
+			return
+		}
+		s.sent = append(s.sent, msg)
+		writeResult(w, telegram.Message{MessageID: int64(len(s.sent)), Text: msg.Text})
+	case "getUpdates":
+		updates := s.updates
+		s.updates = nil
+		if updates == nil {
+			updates = []telegram.Update{}
+		}
+		writeResult(w, updates)
+	case "answerCallbackQuery", "editMessageReplyMarkup", "setWebhook", "deleteWebhook":
+		writeResult(w, true)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found: method not found")
+	}
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+func writeError(w http.ResponseWriter, status int, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error_code": status, "description": description})
+}
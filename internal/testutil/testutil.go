@@ -0,0 +1,81 @@
+// Package testutil はテスト用のデータベースと設定を用意する。
+package testutil
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"homework-manager/internal/config"
+	"homework-manager/internal/database"
+
+	"gorm.io/gorm"
+)
+
+var dbSeq atomic.Int64
+
+// OpenDB はマイグレーション済みのインメモリ SQLite を返す。テストごとに別のデータベースになり、テストの終了時に閉じる。
+func OpenDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	// 名前付きの共有キャッシュにして、接続プールの全接続から同じデータベースを見えるようにする
+	path := fmt.Sprintf("file:testdb%d?mode=memory&cache=shared&_pragma=busy_timeout(5000)", dbSeq.Add(1))
+	db, err := database.Connect(config.DatabaseConfig{Driver: "sqlite", Path: path}, false)
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get test database handle: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
+
+// Config はテスト用の設定を返す。既定値は config.Load と同じで、繰り返し課題の定期生成・レート制限・ログイン失敗時の待ち時間は無効にしてある。
+// 添付ファイルの保存先（Storage.LocalPath）は呼び出し側でテストごとのディレクトリを設定する。
+func Config() *config.Config {
+	return &config.Config{
+		Port:                 "0",
+		SessionSecret:        "test-session-secret-0123456789abcdef",
+		SessionStore:         "memory",
+		AllowRegistration:    true,
+		PasswordResetTTL:     60,
+		EmailVerificationTTL: 2880,
+		AccountMailLimit:     3,
+		CSRFSecret:           "test-csrf-secret-0123456789abcdef",
+		RateLimit: config.RateLimitConfig{
+			Store:     "memory",
+			Algorithm: "sliding_window",
+			Web:       config.RateLimitPolicyConfig{Requests: 100, Window: 60, By: "ip"},
+			Login:     config.RateLimitPolicyConfig{Requests: 10, Window: 60, By: "ip"},
+			API:       config.RateLimitPolicyConfig{Requests: 300, Window: 60, By: "api_key"},
+		},
+		Lockout: config.LockoutConfig{
+			Threshold: 10,
+			Duration:  15,
+			MaxDelay:  30,
+		},
+		Database: config.DatabaseConfig{Driver: "sqlite"},
+		Notification: config.NotificationConfig{
+			TelegramBotMode: "off",
+			MaxAttempts:     5,
+		},
+		Recurring: config.RecurringConfig{GenerationInterval: 15},
+		Captcha:   config.CaptchaConfig{Type: "image"},
+		WebAuthn: config.WebAuthnConfig{
+			RPName:       "Super-HomeworkManager",
+			Passwordless: true,
+		},
+		Storage: config.StorageConfig{
+			Backend:     "local",
+			S3Region:    "us-east-1",
+			MaxFileSize: 10,
+			UserQuota:   100,
+		},
+	}
+}
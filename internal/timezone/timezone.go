@@ -0,0 +1,94 @@
+// Package timezone はユーザーごとのタイムゾーンを扱う。
+package timezone
+
+import (
+	"encoding/json"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var (
+	cacheMu sync.RWMutex
+	cache   = map[string]*time.Location{}
+)
+
+// Load は IANA タイムゾーン名から Location を返す。空文字列や不正な名前はサーバーのローカルタイムゾーンになる。
+func Load(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+
+	cacheMu.RLock()
+	loc, ok := cache[name]
+	cacheMu.RUnlock()
+	if ok {
+		return loc
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	cacheMu.Lock()
+	cache[name] = loc
+	cacheMu.Unlock()
+	return loc
+}
+
+// Valid は name が IANA タイムゾーン名として読み込めるかを返す。"Local" は受け付けない。
+func Valid(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// StartOfDay は t と同じタイムゾーンでの t の日の 0 時を返す。
+func StartOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// MarshalJSON は v を JSON にし、RFC 3339 の日時を loc のオフセット付きで書き出す。v 自体は変更しない。
+// 書き換えるのは time.Time の MarshalJSON が出力する形式の文字列の値だけで、オブジェクトのキーやそれ以外の値はそのまま残す。
+func MarshalJSON(v interface{}, loc *time.Location) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || loc == nil {
+		return data, err
+	}
+
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != '"' {
+			out = append(out, data[i])
+			continue
+		}
+		end := i + 1
+		for data[end] != '"' {
+			if data[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		s := data[i+1 : end]
+		// json.Marshal の出力は空白を含まないため、直後が ':' の文字列はキー
+		isKey := end+1 < len(data) && data[end+1] == ':'
+		if t, ok := parseTimestamp(s); ok && !isKey {
+			s = []byte(t.In(loc).Format(time.RFC3339Nano))
+		}
+		out = append(append(append(out, '"'), s...), '"')
+		i = end
+	}
+	return out, nil
+}
+
+var timestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d{1,9})?(Z|[+-]\d{2}:\d{2})$`)
+
+func parseTimestamp(s []byte) (time.Time, bool) {
+	if !timestampPattern.Match(s) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(s))
+	return t, err == nil
+}
@@ -0,0 +1,32 @@
+package timezone
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	tokyo := Load("Asia/Tokyo")
+	due := time.Date(2026, 4, 10, 15, 0, 0, 0, time.UTC)
+	v := map[string]interface{}{
+		"due_date": due,
+		"reminder": &due,
+		"items":    []time.Time{due.Add(30 * time.Second)},
+		"title":    `"引用符" と \ を含む`,
+		"note":     "2026-04-10",
+		"null":     nil,
+	}
+
+	got, err := MarshalJSON(v, tokyo)
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	want := `{"due_date":"2026-04-11T00:00:00+09:00","items":["2026-04-11T00:00:30+09:00"],"note":"2026-04-10","null":null,` +
+		`"reminder":"2026-04-11T00:00:00+09:00","title":"\"引用符\" と \\ を含む"}`
+	if string(got) != want {
+		t.Errorf("MarshalJSON =\n%s\nwant\n%s", got, want)
+	}
+	if due.Location() != time.UTC {
+		t.Error("MarshalJSON changed the value it encoded")
+	}
+}
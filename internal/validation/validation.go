@@ -11,9 +11,22 @@ var MaxLengths = map[string]int{
 	"title":       200,
 	"description": 5000,
 	"subject":     100,
+	"teacher":     100,
+	"room":        100,
 	"priority":    20,
+	"checklist":   5000,
+	"tag":         50,
+	"group":       100,
 }
 
+// MaxTagsPerAssignment は1つの課題に付けられるタグの数の上限。
+const MaxTagsPerAssignment = 10
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// maxReminderOffset はリマインダーを期限の何分前まで設定できるか（30日）。
+const maxReminderOffset = 30 * 24 * 60
+
 var xssPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`(?i)<\s*script`),
 	regexp.MustCompile(`(?i)</\s*script`),
@@ -98,6 +111,47 @@ func ValidateAssignmentInput(title, description, subject, priority string) error
 	return nil
 }
 
+// ValidateSubjectInput は科目の入力を検証する。color・priority・reminderOffset は空（nil）なら指定なし。
+func ValidateSubjectInput(name, color, teacher, room, priority string, reminderOffset *int) error {
+	if err := ValidateField("subject", name, true); err != nil {
+		return err
+	}
+	if color != "" && !colorPattern.MatchString(color) {
+		return &ValidationError{Field: "color", Message: "#rrggbb の形式で指定してください"}
+	}
+	if err := ValidateField("teacher", teacher, false); err != nil {
+		return err
+	}
+	if err := ValidateField("room", room, false); err != nil {
+		return err
+	}
+	switch priority {
+	case "", "low", "medium", "high":
+	default:
+		return &ValidationError{Field: "priority", Message: "low・medium・high のいずれかを指定してください"}
+	}
+	if reminderOffset != nil && (*reminderOffset < 0 || *reminderOffset > maxReminderOffset) {
+		return &ValidationError{Field: "reminder_offset", Message: fmt.Sprintf("0〜%d分の範囲で指定してください", maxReminderOffset)}
+	}
+	return nil
+}
+
+// ValidateTags は課題に付けるタグの名前を検証する。
+func ValidateTags(names []string) error {
+	if len(names) > MaxTagsPerAssignment {
+		return &ValidationError{Field: "tags", Message: fmt.Sprintf("タグは%d個までです", MaxTagsPerAssignment)}
+	}
+	for _, name := range names {
+		if err := ValidateField("tag", name, true); err != nil {
+			return err
+		}
+		if strings.ContainsAny(name, ",、") {
+			return &ValidationError{Field: "tag", Message: "タグの名前にカンマは使えません"}
+		}
+	}
+	return nil
+}
+
 func ValidateField(fieldName, value string, required bool) error {
 	if required && strings.TrimSpace(value) == "" {
 		return &ValidationError{Field: fieldName, Message: "必須項目です"}
@@ -0,0 +1,178 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidateField(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    string
+		value    string
+		required bool
+		wantErr  string // 空の場合はエラーなし
+	}{
+		{"通常の文字列", "title", "数学のプリント", true, ""},
+		{"任意項目は空でもよい", "subject", "", false, ""},
+		{"必須項目が空", "title", "", true, "必須項目です"},
+		{"必須項目が空白だけ", "title", "   ", true, "必須項目です"},
+		{"上限ちょうど", "subject", strings.Repeat("a", 100), false, ""},
+		{"上限を超える", "subject", strings.Repeat("a", 101), false, "最大100文字までです"},
+		{"上限はバイト数で数える", "title", strings.Repeat("あ", 67), true, "最大200文字までです"},
+		{"制御文字", "title", "abc\x07", true, "不正な制御文字が含まれています"},
+		{"説明は改行を許可", "description", "1行目\n2行目\tタブ", false, ""},
+		{"script タグ", "title", "<script>x</script>", true, "潜在的に危険なHTMLタグまたはスクリプトが含まれています"},
+		{"javascript スキーム", "description", "JavaScript:void(0)", false, "潜在的に危険なHTMLタグまたはスクリプトが含まれています"},
+		{"イベントハンドラー属性", "title", `<img src=x onerror=1>`, true, "潜在的に危険なHTMLタグまたはスクリプトが含まれています"},
+		{"SQL の OR 条件", "title", "' or 1", true, "潜在的に危険なSQL構文が含まれています"},
+		{"UNION SELECT", "subject", "x union select password", false, "潜在的に危険なSQL構文が含まれています"},
+		{"パストラバーサル", "subject", "../etc/passwd", false, "不正なパス文字列が含まれています"},
+		{"コマンド置換", "title", "$(whoami)", true, "潜在的に危険なコマンド構文が含まれています"},
+		{"パイプ", "title", "a | cat", true, "潜在的に危険なコマンド構文が含まれています"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateField(tt.field, tt.value, tt.required)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateField(%q, %q) = %v, want nil", tt.field, tt.value, err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("ValidateField(%q, %q) = %v, want *ValidationError", tt.field, tt.value, err)
+			}
+			if verr.Field != tt.field || verr.Message != tt.wantErr {
+				t.Errorf("ValidateField(%q, %q) = %q / %q, want %q / %q", tt.field, tt.value, verr.Field, verr.Message, tt.field, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAssignmentInput(t *testing.T) {
+	tests := []struct {
+		name      string
+		title     string
+		desc      string
+		subject   string
+		priority  string
+		wantField string // 空の場合はエラーなし
+	}{
+		{"すべて正常", "レポート", "3ページ以上", "国語", "high", ""},
+		{"タイトルなし", "", "", "", "", "title"},
+		{"説明が不正", "レポート", "<script>", "", "", "description"},
+		{"科目が長すぎる", "レポート", "", strings.Repeat("x", 101), "", "subject"},
+		{"優先度が長すぎる", "レポート", "", "", strings.Repeat("x", 21), "priority"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAssignmentInput(tt.title, tt.desc, tt.subject, tt.priority)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("ValidateAssignmentInput() = %v, want nil", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) || verr.Field != tt.wantField {
+				t.Errorf("ValidateAssignmentInput() = %v, want error on %q", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestValidateSubjectInput(t *testing.T) {
+	offset := func(minutes int) *int { return &minutes }
+	tests := []struct {
+		name      string
+		subject   string
+		color     string
+		priority  string
+		offset    *int
+		wantField string // 空の場合はエラーなし
+	}{
+		{"すべて正常", "数学", "#1E88e5", "high", offset(60), ""},
+		{"指定なし", "数学", "", "", nil, ""},
+		{"科目名なし", " ", "", "", nil, "subject"},
+		{"色の形式が不正", "数学", "blue", "", nil, "color"},
+		{"色の桁が足りない", "数学", "#fff", "", nil, "color"},
+		{"重要度が不正", "数学", "", "urgent", nil, "priority"},
+		{"リマインダーが負", "数学", "", "", offset(-1), "reminder_offset"},
+		{"リマインダーが長すぎる", "数学", "", "", offset(maxReminderOffset + 1), "reminder_offset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSubjectInput(tt.subject, tt.color, "", "", tt.priority, tt.offset)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("ValidateSubjectInput() = %v, want nil", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) || verr.Field != tt.wantField {
+				t.Errorf("ValidateSubjectInput() = %v, want error on %q", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	tooMany := make([]string, MaxTagsPerAssignment+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("タグ%d", i)
+	}
+	tests := []struct {
+		name      string
+		tags      []string
+		wantField string // 空の場合はエラーなし
+	}{
+		{"正常", []string{"テスト", "グループワーク"}, ""},
+		{"なし", nil, ""},
+		{"空の名前", []string{" "}, "tag"},
+		{"長すぎる", []string{strings.Repeat("a", 51)}, "tag"},
+		{"カンマ", []string{"a,b"}, "tag"},
+		{"多すぎる", tooMany, "tags"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTags(tt.tags)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("ValidateTags() = %v, want nil", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) || verr.Field != tt.wantField {
+				t.Errorf("ValidateTags() = %v, want error on %q", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestSanitizeString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"  数学  ", "数学"},
+		{"a\x00b", "ab"},
+		{"\x00 \x00", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := SanitizeString(tt.in); got != tt.want {
+			t.Errorf("SanitizeString(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package webauthn
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{"小さい整数", []byte{0x17}, int64(23)},
+		{"1 バイトの整数", []byte{0x18, 0x64}, int64(100)},
+		{"4 バイトの整数", []byte{0x1a, 0x00, 0x0f, 0x42, 0x40}, int64(1000000)},
+		{"負の整数", []byte{0x38, 0x63}, int64(-100)},
+		{"バイト列", []byte{0x43, 0x01, 0x02, 0x03}, []byte{1, 2, 3}},
+		{"文字列", []byte{0x64, 'n', 'o', 'n', 'e'}, "none"},
+		{"配列", []byte{0x82, 0x01, 0x61, 'a'}, []interface{}{int64(1), "a"}},
+		{"マップ", []byte{0xa2, 0x01, 0x02, 0x20, 0x41, 0x07}, map[interface{}]interface{}{int64(1): int64(2), int64(-1): []byte{7}}},
+		{"タグは無視", []byte{0xc2, 0x41, 0xff}, []byte{0xff}},
+		{"真偽値", []byte{0xf5}, true},
+		{"null", []byte{0xf6}, nil},
+		{"倍精度の浮動小数点数", []byte{0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, 1.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 後ろに続くデータは読まない
+			got, n, err := decodeCBOR(append(append([]byte{}, tt.data...), 0x00))
+			if err != nil {
+				t.Fatalf("decodeCBOR: %v", err)
+			}
+			if n != len(tt.data) {
+				t.Errorf("read %d bytes, want %d", n, len(tt.data))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCBOR = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"空", nil},
+		{"引数が途中で切れている", []byte{0x19, 0x01}},
+		{"バイト列が途中で切れている", []byte{0x45, 0x01, 0x02}},
+		{"巨大な長さのバイト列", []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"巨大な要素数の配列", []byte{0x9b, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"巨大な要素数のマップ", []byte{0xba, 0xff, 0xff, 0xff, 0xff}},
+		{"要素が足りない配列", []byte{0x83, 0x01, 0x02}},
+		{"値がないマップ", []byte{0xa1, 0x01}},
+		{"int64 を超える整数", []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"int64 を超える負の整数", []byte{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"不定長", []byte{0x5f, 0x41, 0x01, 0xff}},
+		{"予約された追加情報", []byte{0x1c}},
+		{"未対応の単純値", []byte{0xf8, 0x20}},
+		{"バイト列のマップのキー", []byte{0xa1, 0x41, 0x01, 0x01}},
+		{"深すぎる入れ子", bytes.Repeat([]byte{0x81}, maxCBORDepth+2)},
+		{"タグの連続", bytes.Repeat([]byte{0xc6}, maxCBORDepth+2)},
+		{"浮動小数点数が途中で切れている", []byte{0xfb, 0x3f, 0xf8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v, _, err := decodeCBOR(tt.data); err == nil {
+				t.Errorf("decodeCBOR = %#v, want an error", v)
+			}
+		})
+	}
+}
+
+func TestParseCOSEKeyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"マップでない", []byte{0x01}},
+		{"kty がない", []byte{0xa1, 0x03, 0x26}},
+		{"EC2 で alg が違う", []byte{0xa2, 0x01, 0x02, 0x03, 0x27}},
+		{"EC2 で座標がない", []byte{0xa3, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01}},
+		{"OKP で鍵の長さが違う", []byte{0xa4, 0x01, 0x01, 0x03, 0x27, 0x20, 0x06, 0x21, 0x41, 0x00}},
+		{"RSA で n が短い", []byte{0xa4, 0x01, 0x03, 0x03, 0x39, 0x01, 0x00, 0x20, 0x41, 0x01, 0x21, 0x43, 0x01, 0x00, 0x01}},
+		{"未対応の kty", []byte{0xa1, 0x01, 0x04}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseCOSEKey(tt.data); err == nil {
+				t.Error("parseCOSEKey accepted an invalid key")
+			}
+		})
+	}
+
+	// 曲線上にない点
+	key := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21, 0x58, 0x20}
+	key = append(key, bytes.Repeat([]byte{0x01}, 32)...)
+	key = append(key, 0x22, 0x58, 0x20)
+	key = append(key, bytes.Repeat([]byte{0x02}, 32)...)
+	if _, _, err := parseCOSEKey(key); err == nil {
+		t.Error("parseCOSEKey accepted a point that is not on the curve")
+	}
+}
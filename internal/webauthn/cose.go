@@ -0,0 +1,112 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE アルゴリズム識別子 (RFC 9053)
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgorithms は登録時に pubKeyCredParams で提示するアルゴリズム（優先順）。
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+const (
+	coseKeyKty = 1
+	coseKeyAlg = 3
+	coseKeyCrv = -1 // EC2 / OKP の曲線。RSA では n
+	coseKeyX   = -2 // EC2 / OKP の x 座標。RSA では e
+	coseKeyY   = -3
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+// parseCOSEKey は COSE_Key 形式の公開鍵を読み込む。alg は鍵に含まれるアルゴリズム。
+func parseCOSEKey(data []byte) (key crypto.PublicKey, alg int, err error) {
+	v, _, err := decodeCBOR(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("cose: key is not a map")
+	}
+
+	kty, _ := m[int64(coseKeyKty)].(int64)
+	alg64, _ := m[int64(coseKeyAlg)].(int64)
+	alg = int(alg64)
+
+	switch kty {
+	case coseKtyEC2:
+		crv, _ := m[int64(coseKeyCrv)].(int64)
+		x, _ := m[int64(coseKeyX)].([]byte)
+		y, _ := m[int64(coseKeyY)].([]byte)
+		if alg != AlgES256 || crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("cose: unsupported EC2 key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, errors.New("cose: point is not on the curve")
+		}
+		return pub, alg, nil
+	case coseKtyOKP:
+		crv, _ := m[int64(coseKeyCrv)].(int64)
+		x, _ := m[int64(coseKeyX)].([]byte)
+		if alg != AlgEdDSA || crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("cose: unsupported OKP key")
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case coseKtyRSA:
+		n, _ := m[int64(coseKeyCrv)].([]byte)
+		e, _ := m[int64(coseKeyX)].([]byte)
+		if alg != AlgRS256 || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("cose: unsupported RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, alg, nil
+	}
+	return nil, 0, fmt.Errorf("cose: unsupported key type %d", kty)
+}
+
+// verifyCOSESignature は COSE_Key 形式の公開鍵で signed に対する署名を検証する。
+func verifyCOSESignature(coseKey, signed, signature []byte) error {
+	key, alg, err := parseCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+
+	switch alg {
+	case AlgES256:
+		digest := sha256.Sum256(signed)
+		if !ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest[:], signature) {
+			return ErrInvalidSignature
+		}
+	case AlgEdDSA:
+		if !ed25519.Verify(key.(ed25519.PublicKey), signed, signature) {
+			return ErrInvalidSignature
+		}
+	case AlgRS256:
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature); err != nil {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("cose: unsupported algorithm %d", alg)
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+// Package webauthn は WebAuthn (パスキー / セキュリティキー) のリライングパーティ側の検証を行う。
+// 登録時の attestation は "none" として扱い（認証器の証明書は検証しない）、署名アルゴリズムは ES256、EdDSA、RS256 に対応する。
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidResponse   = errors.New("webauthn: invalid response")
+	ErrChallengeMismatch = errors.New("webauthn: challenge does not match")
+	ErrOriginMismatch    = errors.New("webauthn: origin does not match")
+	ErrRPIDMismatch      = errors.New("webauthn: relying party ID does not match")
+	ErrUserNotPresent    = errors.New("webauthn: user presence flag is not set")
+	ErrUserNotVerified   = errors.New("webauthn: user verification is required")
+	ErrInvalidSignature  = errors.New("webauthn: invalid signature")
+	ErrCounterRegressed  = errors.New("webauthn: signature counter did not increase (possible cloned authenticator)")
+)
+
+// authenticator data のフラグ
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+)
+
+// RelyingParty はこのアプリケーション（リライングパーティ）の識別情報。
+type RelyingParty struct {
+	ID     string // ドメイン名（例: homework.example.com）
+	Name   string
+	Origin string // https://homework.example.com
+}
+
+// UserEntity は登録オプションに含めるユーザー情報。ID はユーザーハンドル（個人情報を含まない不透明な値）。
+type UserEntity struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+// Credential は登録を検証して得られた公開鍵クレデンシャル。
+type Credential struct {
+	ID         string // base64url
+	PublicKey  []byte // COSE_Key
+	SignCount  uint32
+	Transports []string
+}
+
+// CreationOptions は navigator.credentials.create() に渡す publicKey オプション。バイナリは base64url で、ブラウザ側で変換する。
+type CreationOptions struct {
+	Challenge              string                   `json:"challenge"`
+	RP                     map[string]string        `json:"rp"`
+	User                   map[string]string        `json:"user"`
+	PubKeyCredParams       []map[string]interface{} `json:"pubKeyCredParams"`
+	Timeout                int                      `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor   `json:"excludeCredentials"`
+	AuthenticatorSelection map[string]string        `json:"authenticatorSelection"`
+	Attestation            string                   `json:"attestation"`
+}
+
+// RequestOptions は navigator.credentials.get() に渡す publicKey オプション。
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int                    `json:"timeout"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// RegistrationResponse はブラウザから送られてくる登録結果（PublicKeyCredential を base64url で JSON にしたもの）。
+type RegistrationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse はブラウザから送られてくる認証結果。
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// UserHandle は userHandle をデコードして返す。パスキーでのログイン時に、どのユーザーのクレデンシャルかを特定するのに使う。
+func (r *AssertionResponse) UserHandle() ([]byte, error) {
+	return decodeBase64URL(r.Response.UserHandle)
+}
+
+// NewChallenge はチャレンジに使う乱数を base64url で返す。
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreationOptions は登録用のオプションを返す。exclude には登録済みのクレデンシャルを渡し、同じ認証器の二重登録を防ぐ。
+// residentKey を "preferred" にするため、対応する認証器ではパスキー（パスワードなしログイン）としても使える。
+func (rp *RelyingParty) CreationOptions(challenge string, user UserEntity, exclude []CredentialDescriptor) *CreationOptions {
+	params := make([]map[string]interface{}, 0, len(SupportedAlgorithms))
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, map[string]interface{}{"type": "public-key", "alg": alg})
+	}
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+	return &CreationOptions{
+		Challenge: challenge,
+		RP:        map[string]string{"id": rp.ID, "name": rp.Name},
+		User: map[string]string{
+			"id":          base64.RawURLEncoding.EncodeToString(user.ID),
+			"name":        user.Name,
+			"displayName": user.DisplayName,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            120000,
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: map[string]string{
+			"residentKey":      "preferred",
+			"userVerification": "preferred",
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions は認証用のオプションを返す。allow が空の場合は、認証器に保存されたパスキーから利用者が選ぶ。
+func (rp *RelyingParty) RequestOptions(challenge string, allow []CredentialDescriptor, userVerification string) *RequestOptions {
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+	return &RequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.ID,
+		Timeout:          120000,
+		AllowCredentials: allow,
+		UserVerification: userVerification,
+	}
+}
+
+// VerifyRegistration は登録結果を検証し、保存するクレデンシャルを返す。
+func (rp *RelyingParty) VerifyRegistration(resp *RegistrationResponse, challenge string) (*Credential, error) {
+	if resp.Type != "public-key" {
+		return nil, ErrInvalidResponse
+	}
+	if _, err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	attestation, err := decodeBase64URL(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrInvalidResponse
+	}
+	v, _, err := decodeCBOR(attestation)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	obj, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+	authData, ok := obj["authData"].([]byte)
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+
+	parsed, err := rp.parseAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.flags&flagAttestedCredData == 0 || len(parsed.credentialID) == 0 {
+		return nil, fmt.Errorf("%w: attested credential data is missing", ErrInvalidResponse)
+	}
+	if _, _, err := parseCOSEKey(parsed.publicKey); err != nil {
+		return nil, err
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(parsed.credentialID)
+	if rawID, err := decodeBase64URL(resp.RawID); err != nil || !bytes.Equal(rawID, parsed.credentialID) {
+		return nil, fmt.Errorf("%w: credential ID does not match", ErrInvalidResponse)
+	}
+
+	return &Credential{
+		ID:         credentialID,
+		PublicKey:  parsed.publicKey,
+		SignCount:  parsed.signCount,
+		Transports: resp.Response.Transports,
+	}, nil
+}
+
+// VerifyAssertion は認証結果の署名を publicKey で検証し、新しい署名カウンターを返す。
+// requireUV が true の場合は認証器での本人確認（生体認証や PIN）を必須にする。
+func (rp *RelyingParty) VerifyAssertion(resp *AssertionResponse, challenge string, publicKey []byte, storedSignCount uint32, requireUV bool) (uint32, error) {
+	if resp.Type != "public-key" {
+		return 0, ErrInvalidResponse
+	}
+	clientData, err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	authData, err := decodeBase64URL(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, ErrInvalidResponse
+	}
+	parsed, err := rp.parseAuthenticatorData(authData)
+	if err != nil {
+		return 0, err
+	}
+	if requireUV && parsed.flags&flagUserVerified == 0 {
+		return 0, ErrUserNotVerified
+	}
+
+	signature, err := decodeBase64URL(resp.Response.Signature)
+	if err != nil {
+		return 0, ErrInvalidResponse
+	}
+	clientDataHash := sha256.Sum256(clientData)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	if err := verifyCOSESignature(publicKey, signed, signature); err != nil {
+		return 0, err
+	}
+
+	// カウンターに対応していない認証器は常に 0 を返す
+	if (parsed.signCount != 0 || storedSignCount != 0) && parsed.signCount <= storedSignCount {
+		return 0, ErrCounterRegressed
+	}
+	return parsed.signCount, nil
+}
+
+// verifyClientData は clientDataJSON の type、challenge、origin を検証し、デコードした JSON を返す。
+func (rp *RelyingParty) verifyClientData(encoded, expectedType, challenge string) ([]byte, error) {
+	raw, err := decodeBase64URL(encoded)
+	if err != nil {
+		return nil, ErrInvalidResponse
+	}
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	if clientData.Type != expectedType {
+		return nil, fmt.Errorf("%w: unexpected type %q", ErrInvalidResponse, clientData.Type)
+	}
+	if challenge == "" || strings.TrimRight(clientData.Challenge, "=") != challenge {
+		return nil, ErrChallengeMismatch
+	}
+	if clientData.Origin != rp.Origin {
+		return nil, ErrOriginMismatch
+	}
+	return raw, nil
+}
+
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+func (rp *RelyingParty) parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data is too short", ErrInvalidResponse)
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data[:32], rpIDHash[:]) {
+		return nil, ErrRPIDMismatch
+	}
+
+	parsed := &authenticatorData{
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if parsed.flags&flagUserPresent == 0 {
+		return nil, ErrUserNotPresent
+	}
+
+	if parsed.flags&flagAttestedCredData != 0 {
+		rest := data[37:]
+		// AAGUID (16) + クレデンシャルIDの長さ (2)
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data is too short", ErrInvalidResponse)
+		}
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+			return nil, fmt.Errorf("%w: invalid credential ID length", ErrInvalidResponse)
+		}
+		parsed.credentialID = append([]byte(nil), rest[:idLen]...)
+		rest = rest[idLen:]
+
+		// 公開鍵の後ろに拡張データが続くことがあるため、CBOR の長さで切り出す
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+		parsed.publicKey = append([]byte(nil), rest[:n]...)
+	}
+	return parsed, nil
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
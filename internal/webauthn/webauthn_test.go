@@ -0,0 +1,263 @@
+package webauthn
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"homework-manager/internal/webauthn/webauthntest"
+)
+
+const (
+	testRPID   = "homework.example.com"
+	testOrigin = "https://homework.example.com"
+)
+
+var testRP = &RelyingParty{ID: testRPID, Name: "Super-HomeworkManager", Origin: testOrigin}
+
+var testAlgorithms = []struct {
+	name string
+	alg  int
+}{
+	{"ES256", AlgES256},
+	{"EdDSA", AlgEdDSA},
+	{"RS256", AlgRS256},
+}
+
+func registrationResponse(t *testing.T, data []byte) *RegistrationResponse {
+	t.Helper()
+	var resp RegistrationResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("decode registration: %v", err)
+	}
+	return &resp
+}
+
+func assertionResponse(t *testing.T, data []byte) *AssertionResponse {
+	t.Helper()
+	var resp AssertionResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("decode assertion: %v", err)
+	}
+	return &resp
+}
+
+// register は認証器を登録し、保存するクレデンシャルを返す。
+func register(t *testing.T, a *webauthntest.Authenticator) *Credential {
+	t.Helper()
+	credential, err := testRP.VerifyRegistration(registrationResponse(t, a.Register("register-challenge")), "register-challenge")
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	return credential
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	for _, tt := range testAlgorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			a := webauthntest.New(tt.alg, testRPID, testOrigin)
+			credential := register(t, a)
+			if credential.ID != a.CredentialID() || credential.SignCount != 0 ||
+				len(credential.Transports) != 1 || credential.Transports[0] != "internal" {
+				t.Errorf("credential = %+v", credential)
+			}
+			if _, alg, err := parseCOSEKey(credential.PublicKey); err != nil || alg != tt.alg {
+				t.Errorf("parseCOSEKey = %d, %v, want %d", alg, err, tt.alg)
+			}
+
+			stored := credential.SignCount
+			for want := uint32(1); want <= 2; want++ {
+				count, err := testRP.VerifyAssertion(assertionResponse(t, a.Assert("login-challenge")), "login-challenge", credential.PublicKey, stored, true)
+				if err != nil {
+					t.Fatalf("VerifyAssertion: %v", err)
+				}
+				if count != want {
+					t.Errorf("sign count = %d, want %d", count, want)
+				}
+				stored = count
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(a *webauthntest.Authenticator)
+		challenge string
+		want      error
+	}{
+		{"チャレンジが違う", nil, "other-challenge", ErrChallengeMismatch},
+		{"チャレンジが空", nil, "", ErrChallengeMismatch},
+		{"オリジンが違う", func(a *webauthntest.Authenticator) { a.Origin = "https://evil.example.com" }, "register-challenge", ErrOriginMismatch},
+		{"rpIdHash が違う", func(a *webauthntest.Authenticator) { a.RPID = "evil.example.com" }, "register-challenge", ErrRPIDMismatch},
+		{"UP フラグなし", func(a *webauthntest.Authenticator) { a.Flags = webauthntest.FlagUserVerified }, "register-challenge", ErrUserNotPresent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := webauthntest.New(AlgES256, testRPID, testOrigin)
+			if tt.modify != nil {
+				tt.modify(a)
+			}
+			resp := registrationResponse(t, a.Register("register-challenge"))
+			if _, err := testRP.VerifyRegistration(resp, tt.challenge); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("認証結果を登録に使う", func(t *testing.T) {
+		a := webauthntest.New(AlgES256, testRPID, testOrigin)
+		var resp RegistrationResponse
+		json.Unmarshal(a.Assert("register-challenge"), &resp)
+		if _, err := testRP.VerifyRegistration(&resp, "register-challenge"); !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidResponse)
+		}
+	})
+
+	t.Run("rawId が違う", func(t *testing.T) {
+		a := webauthntest.New(AlgES256, testRPID, testOrigin)
+		resp := registrationResponse(t, a.Register("register-challenge"))
+		resp.RawID = webauthntest.New(AlgES256, testRPID, testOrigin).CredentialID()
+		if _, err := testRP.VerifyRegistration(resp, "register-challenge"); !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidResponse)
+		}
+	})
+}
+
+func TestVerifyAssertionErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(a *webauthntest.Authenticator)
+		challenge string
+		stored    uint32 // 保存済みの署名カウンター
+		requireUV bool
+		want      error
+	}{
+		{"チャレンジが違う", nil, "other-challenge", 0, false, ErrChallengeMismatch},
+		{"オリジンが違う", func(a *webauthntest.Authenticator) { a.Origin = "https://homework.example.com:8443" }, "login-challenge", 0, false, ErrOriginMismatch},
+		{"rpIdHash が違う", func(a *webauthntest.Authenticator) { a.RPID = "example.com" }, "login-challenge", 0, false, ErrRPIDMismatch},
+		{"UP フラグなし", func(a *webauthntest.Authenticator) { a.Flags = webauthntest.FlagUserVerified }, "login-challenge", 0, false, ErrUserNotPresent},
+		{"UV が必須で UV フラグなし", func(a *webauthntest.Authenticator) { a.Flags = webauthntest.FlagUserPresent }, "login-challenge", 0, true, ErrUserNotVerified},
+		{"UV が任意で UV フラグなし", func(a *webauthntest.Authenticator) { a.Flags = webauthntest.FlagUserPresent }, "login-challenge", 0, false, nil},
+		// Assert は SignCount を 1 増やしてから署名する
+		{"カウンターが同じ", func(a *webauthntest.Authenticator) { a.SignCount = 4 }, "login-challenge", 5, false, ErrCounterRegressed},
+		{"カウンターが戻った", func(a *webauthntest.Authenticator) { a.SignCount = 1 }, "login-challenge", 5, false, ErrCounterRegressed},
+		{"カウンターが増えた", func(a *webauthntest.Authenticator) { a.SignCount = 9 }, "login-challenge", 5, false, nil},
+	}
+	for _, tt := range testAlgorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, tc := range tests {
+				t.Run(tc.name, func(t *testing.T) {
+					a := webauthntest.New(tt.alg, testRPID, testOrigin)
+					credential := register(t, a)
+					if tc.modify != nil {
+						tc.modify(a)
+					}
+					resp := assertionResponse(t, a.Assert("login-challenge"))
+					if _, err := testRP.VerifyAssertion(resp, tc.challenge, credential.PublicKey, tc.stored, tc.requireUV); !errors.Is(err, tc.want) {
+						t.Errorf("err = %v, want %v", err, tc.want)
+					}
+				})
+			}
+		})
+	}
+
+	t.Run("カウンター非対応の認証器", func(t *testing.T) {
+		a := webauthntest.New(AlgES256, testRPID, testOrigin)
+		credential := register(t, a)
+		a.SignCount = ^uint32(0) // Assert で 0 に戻る
+		count, err := testRP.VerifyAssertion(assertionResponse(t, a.Assert("login-challenge")), "login-challenge", credential.PublicKey, 0, false)
+		if err != nil || count != 0 {
+			t.Errorf("VerifyAssertion = %d, %v, want 0, nil", count, err)
+		}
+	})
+
+	t.Run("別の鍵の署名", func(t *testing.T) {
+		a := webauthntest.New(AlgES256, testRPID, testOrigin)
+		other := webauthntest.New(AlgES256, testRPID, testOrigin)
+		resp := assertionResponse(t, other.Assert("login-challenge"))
+		if _, err := testRP.VerifyAssertion(resp, "login-challenge", a.PublicKey(), 0, false); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidSignature)
+		}
+	})
+
+	t.Run("署名後に書き換えた clientDataJSON", func(t *testing.T) {
+		a := webauthntest.New(AlgRS256, testRPID, testOrigin)
+		resp := assertionResponse(t, a.Assert("login-challenge"))
+		resp.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(
+			[]byte(`{"type":"webauthn.get","challenge":"login-challenge","origin":"` + testOrigin + `","crossOrigin":false}`))
+		if _, err := testRP.VerifyAssertion(resp, "login-challenge", a.PublicKey(), 0, false); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidSignature)
+		}
+	})
+}
+
+// TestTruncatedResponses は途中で切れた attestationObject・authenticatorData をエラーにする（panic しない）ことを確かめる。
+func TestTruncatedResponses(t *testing.T) {
+	for _, tt := range testAlgorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			a := webauthntest.New(tt.alg, testRPID, testOrigin)
+			registration := registrationResponse(t, a.Register("register-challenge"))
+			attestation, _ := decodeBase64URL(registration.Response.AttestationObject)
+			for n := 0; n < len(attestation); n++ {
+				resp := *registration
+				resp.Response.AttestationObject = base64.RawURLEncoding.EncodeToString(attestation[:n])
+				if _, err := testRP.VerifyRegistration(&resp, "register-challenge"); err == nil {
+					t.Fatalf("attestationObject truncated to %d bytes was accepted", n)
+				}
+			}
+
+			credential := register(t, a)
+			assertion := assertionResponse(t, a.Assert("login-challenge"))
+			authData, _ := decodeBase64URL(assertion.Response.AuthenticatorData)
+			for n := 0; n < len(authData); n++ {
+				resp := *assertion
+				resp.Response.AuthenticatorData = base64.RawURLEncoding.EncodeToString(authData[:n])
+				if _, err := testRP.VerifyAssertion(&resp, "login-challenge", credential.PublicKey, 0, false); err == nil {
+					t.Fatalf("authenticatorData truncated to %d bytes was accepted", n)
+				}
+			}
+			for n := 0; n < len(credential.PublicKey); n++ {
+				if err := verifyCOSESignature(credential.PublicKey[:n], nil, nil); err == nil {
+					t.Fatalf("COSE key truncated to %d bytes was accepted", n)
+				}
+			}
+		})
+	}
+}
+
+func TestMalformedResponses(t *testing.T) {
+	a := webauthntest.New(AlgES256, testRPID, testOrigin)
+	valid := registrationResponse(t, a.Register("register-challenge"))
+
+	tests := []struct {
+		name   string
+		modify func(resp *RegistrationResponse)
+	}{
+		{"type が public-key でない", func(resp *RegistrationResponse) { resp.Type = "password" }},
+		{"clientDataJSON が base64url でない", func(resp *RegistrationResponse) { resp.Response.ClientDataJSON = "!!" }},
+		{"clientDataJSON が JSON でない", func(resp *RegistrationResponse) {
+			resp.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString([]byte("{"))
+		}},
+		{"attestationObject がマップでない", func(resp *RegistrationResponse) {
+			resp.Response.AttestationObject = base64.RawURLEncoding.EncodeToString([]byte{0x83, 0x01, 0x02, 0x03})
+		}},
+		{"authData がない", func(resp *RegistrationResponse) {
+			resp.Response.AttestationObject = base64.RawURLEncoding.EncodeToString([]byte{0xa1, 0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e'})
+		}},
+		{"attestationObject が巨大な長さを宣言", func(resp *RegistrationResponse) {
+			resp.Response.AttestationObject = base64.RawURLEncoding.EncodeToString([]byte{0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := *valid
+			tt.modify(&resp)
+			if _, err := testRP.VerifyRegistration(&resp, "register-challenge"); !errors.Is(err, ErrInvalidResponse) {
+				t.Errorf("err = %v, want %v", err, ErrInvalidResponse)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package webauthntest
+
+import "encoding/binary"
+
+// encodedMap は CBOR で書き出したマップ。ほかのマップの値にするとそのまま埋め込む。
+type encodedMap []byte
+
+// encodeMap はキーと値を交互に並べた kv を、その順序のまま CBOR のマップにする。
+// 値には int、string、[]byte、encodedMap を使える。
+func encodeMap(kv ...interface{}) encodedMap {
+	out := appendHead(nil, 5, uint64(len(kv)/2))
+	for _, v := range kv {
+		out = appendValue(out, v)
+	}
+	return out
+}
+
+func appendValue(out []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return appendHead(out, 1, uint64(-1-v))
+		}
+		return appendHead(out, 0, uint64(v))
+	case string:
+		return append(appendHead(out, 3, uint64(len(v))), v...)
+	case []byte:
+		return append(appendHead(out, 2, uint64(len(v))), v...)
+	case encodedMap:
+		return append(out, v...)
+	}
+	panic("webauthntest: unsupported CBOR value")
+}
+
+func appendHead(out []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(out, major<<5|byte(n))
+	case n <= 0xff:
+		return append(out, major<<5|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(out, major<<5|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(out, major<<5|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(out, major<<5|27), n)
+}
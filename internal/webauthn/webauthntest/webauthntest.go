@@ -0,0 +1,194 @@
+// Package webauthntest はテスト用のソフトウェア認証器。ブラウザーが送る PublicKeyCredential の JSON を作る。
+// attestation は "none" で、署名アルゴリズムは ES256、EdDSA、RS256 に対応する。
+package webauthntest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// COSE アルゴリズム識別子 (RFC 9053)
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// authenticator data のフラグ
+const (
+	FlagUserPresent      = 0x01
+	FlagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+)
+
+// Authenticator はソフトウェア認証器。フィールドを書き換えると、次に作る応答に反映される。
+type Authenticator struct {
+	RPID       string // authenticator data の rpIdHash に使う
+	Origin     string // clientDataJSON の origin
+	Flags      byte   // authenticator data のフラグ。New は UP と UV を立てる
+	SignCount  uint32 // Assert は 1 増やしてから署名する
+	UserHandle []byte // 認証結果の userHandle。nil の場合は送らない
+	Transports []string
+
+	credentialID []byte
+	signer       crypto.Signer
+	coseKey      []byte
+}
+
+// New は alg の鍵を持つ認証器を返す。
+func New(alg int, rpID, origin string) *Authenticator {
+	a := &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		Flags:        FlagUserPresent | FlagUserVerified,
+		Transports:   []string{"internal"},
+		credentialID: make([]byte, 16),
+	}
+	rand.Read(a.credentialID)
+
+	var err error
+	switch alg {
+	case AlgES256:
+		var key *ecdsa.PrivateKey
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err == nil {
+			a.signer = key
+			a.coseKey = encodeMap(
+				1, 2, // kty: EC2
+				3, AlgES256,
+				-1, 1, // crv: P-256
+				-2, key.X.FillBytes(make([]byte, 32)),
+				-3, key.Y.FillBytes(make([]byte, 32)),
+			)
+		}
+	case AlgEdDSA:
+		var pub ed25519.PublicKey
+		var key ed25519.PrivateKey
+		if pub, key, err = ed25519.GenerateKey(rand.Reader); err == nil {
+			a.signer = key
+			a.coseKey = encodeMap(
+				1, 1, // kty: OKP
+				3, AlgEdDSA,
+				-1, 6, // crv: Ed25519
+				-2, []byte(pub),
+			)
+		}
+	case AlgRS256:
+		var key *rsa.PrivateKey
+		if key, err = rsa.GenerateKey(rand.Reader, 2048); err == nil {
+			a.signer = key
+			a.coseKey = encodeMap(
+				1, 3, // kty: RSA
+				3, AlgRS256,
+				-1, key.N.Bytes(),
+				-2, big.NewInt(int64(key.E)).Bytes(),
+			)
+		}
+	default:
+		err = fmt.Errorf("unsupported algorithm %d", alg)
+	}
+	if err != nil {
+		panic("webauthntest: failed to generate key: " + err.Error())
+	}
+	return a
+}
+
+// CredentialID はクレデンシャルIDを base64url で返す。
+func (a *Authenticator) CredentialID() string {
+	return base64.RawURLEncoding.EncodeToString(a.credentialID)
+}
+
+// PublicKey は COSE_Key 形式の公開鍵を返す。
+func (a *Authenticator) PublicKey() []byte {
+	return append([]byte(nil), a.coseKey...)
+}
+
+// Register は navigator.credentials.create() の結果の JSON を返す。
+func (a *Authenticator) Register(challenge string) []byte {
+	authData := a.authenticatorData(a.Flags | flagAttestedCredData)
+	idLen := make([]byte, 2)
+	binary.BigEndian.PutUint16(idLen, uint16(len(a.credentialID)))
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = append(authData, idLen...)
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, a.coseKey...)
+
+	attestation := encodeMap("fmt", "none", "attStmt", encodeMap(), "authData", authData)
+	return a.credential(map[string]interface{}{
+		"clientDataJSON":    encode(a.clientData("webauthn.create", challenge)),
+		"attestationObject": encode(attestation),
+		"transports":        a.Transports,
+	})
+}
+
+// Assert は navigator.credentials.get() の結果の JSON を返す。
+func (a *Authenticator) Assert(challenge string) []byte {
+	a.SignCount++
+	authData := a.authenticatorData(a.Flags)
+	clientData := a.clientData("webauthn.get", challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	signature := a.sign(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	response := map[string]interface{}{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+	}
+	if a.UserHandle != nil {
+		response["userHandle"] = encode(a.UserHandle)
+	}
+	return a.credential(response)
+}
+
+func (a *Authenticator) credential(response map[string]interface{}) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"id":       a.CredentialID(),
+		"rawId":    a.CredentialID(),
+		"type":     "public-key",
+		"response": response,
+	})
+	return data
+}
+
+func (a *Authenticator) clientData(typ, challenge string) []byte {
+	data, _ := json.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": a.Origin})
+	return data
+}
+
+func (a *Authenticator) authenticatorData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
+
+func (a *Authenticator) sign(message []byte) []byte {
+	var signature []byte
+	var err error
+	switch key := a.signer.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, message)
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(message)
+		signature, err = ecdsa.SignASN1(rand.Reader, key, digest[:])
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256(message)
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	}
+	if err != nil {
+		panic("webauthntest: failed to sign: " + err.Error())
+	}
+	return signature
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,107 @@
+// パスキー（WebAuthn）の登録と認証。サーバーとは base64url 文字列でバイナリをやり取りする。
+const WebAuthnClient = {
+    supported: function () {
+        return !!(window.PublicKeyCredential && navigator.credentials);
+    },
+
+    toBuffer: function (value) {
+        const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+        const padded = base64 + '='.repeat((4 - base64.length % 4) % 4);
+        const binary = atob(padded);
+        const bytes = new Uint8Array(binary.length);
+        for (let i = 0; i < binary.length; i++) {
+            bytes[i] = binary.charCodeAt(i);
+        }
+        return bytes.buffer;
+    },
+
+    toBase64URL: function (buffer) {
+        if (!buffer) return '';
+        const bytes = new Uint8Array(buffer);
+        let binary = '';
+        for (let i = 0; i < bytes.length; i++) {
+            binary += String.fromCharCode(bytes[i]);
+        }
+        return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+    },
+
+    post: async function (url, csrfToken, body) {
+        const res = await fetch(url, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': csrfToken },
+            body: JSON.stringify(body || {}),
+            credentials: 'same-origin'
+        });
+        let data = {};
+        try {
+            data = await res.json();
+        } catch (e) {
+            data = {};
+        }
+        if (!res.ok) {
+            throw new Error(data.error || '通信に失敗しました');
+        }
+        return data;
+    },
+
+    // register は新しいパスキーを登録する。password はパスワードのあるアカウントの本人確認に使う。
+    register: async function (csrfToken, name, password) {
+        const begin = await this.post('/profile/webauthn/register/begin', csrfToken, { password: password });
+        const options = begin.publicKey;
+        options.challenge = this.toBuffer(options.challenge);
+        options.user.id = this.toBuffer(options.user.id);
+        options.excludeCredentials = options.excludeCredentials.map(c => Object.assign({}, c, { id: this.toBuffer(c.id) }));
+
+        const credential = await navigator.credentials.create({ publicKey: options });
+        const response = credential.response;
+        return this.post('/profile/webauthn/register/finish', csrfToken, {
+            name: name,
+            credential: {
+                id: credential.id,
+                rawId: this.toBase64URL(credential.rawId),
+                type: credential.type,
+                response: {
+                    clientDataJSON: this.toBase64URL(response.clientDataJSON),
+                    attestationObject: this.toBase64URL(response.attestationObject),
+                    transports: response.getTransports ? response.getTransports() : []
+                }
+            }
+        });
+    },
+
+    // authenticate はパスキーで認証し、サーバーが返す遷移先を返す。
+    authenticate: async function (beginURL, finishURL, csrfToken) {
+        const begin = await this.post(beginURL, csrfToken);
+        const options = begin.publicKey;
+        options.challenge = this.toBuffer(options.challenge);
+        options.allowCredentials = options.allowCredentials.map(c => Object.assign({}, c, { id: this.toBuffer(c.id) }));
+
+        const credential = await navigator.credentials.get({ publicKey: options });
+        const response = credential.response;
+        const result = await this.post(finishURL, csrfToken, {
+            id: credential.id,
+            rawId: this.toBase64URL(credential.rawId),
+            type: credential.type,
+            response: {
+                clientDataJSON: this.toBase64URL(response.clientDataJSON),
+                authenticatorData: this.toBase64URL(response.authenticatorData),
+                signature: this.toBase64URL(response.signature),
+                userHandle: this.toBase64URL(response.userHandle)
+            }
+        });
+        return result.redirect || '/';
+    },
+
+    // errorMessage はブラウザの例外を利用者向けのメッセージにする。
+    errorMessage: function (err) {
+        if (err && err.name === 'NotAllowedError') {
+            return '操作がキャンセルされたか、時間切れになりました';
+        }
+        if (err && err.name === 'InvalidStateError') {
+            return 'この認証器は既に登録されています';
+        }
+        return (err && err.message) || 'パスキーの操作に失敗しました';
+    }
+};
+
+window.WebAuthnClient = WebAuthnClient;